@@ -9,6 +9,7 @@ import (
 	"flag"
 	"fmt"
 	"io/fs"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -16,13 +17,16 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+	certaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/cert_access"
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/credcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/remoteconfig"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/store"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
@@ -53,6 +57,18 @@ func init() {
 func main() {
 	fmt.Printf("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s\n", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
 
+	// Subcommands parse their own flags and never start the proxy service.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bench":
+			os.Exit(cmd.RunBench(os.Args[2:]))
+		case "mock":
+			os.Exit(cmd.RunMock(os.Args[2:]))
+		case "conformance":
+			os.Exit(cmd.RunConformance(os.Args[2:]))
+		}
+	}
+
 	// Command-line flags to control the application's behavior.
 	var login bool
 	var codexLogin bool
@@ -65,6 +81,7 @@ func main() {
 	var projectID string
 	var vertexImport string
 	var configPath string
+	var configURL string
 	var password string
 
 	// Define command-line flags for different operation modes.
@@ -78,6 +95,7 @@ func main() {
 	flag.BoolVar(&antigravityLogin, "antigravity-login", false, "Login to Antigravity using OAuth")
 	flag.StringVar(&projectID, "project_id", "", "Project ID (Gemini only, not required)")
 	flag.StringVar(&configPath, "config", DefaultConfigPath, "Configure File Path")
+	flag.StringVar(&configURL, "config-url", "", "Remote config source (http(s)://, s3://bucket/key or gs://bucket/key)")
 	flag.StringVar(&vertexImport, "vertex-import", "", "Import Vertex service account key JSON file")
 	flag.StringVar(&password, "password", "", "")
 
@@ -106,6 +124,10 @@ func main() {
 			}
 			_, _ = fmt.Fprint(out, s+"\n")
 		})
+		_, _ = fmt.Fprintf(out, "\nSubcommands:\n  auth\n    Log in to a provider and list, refresh, remove, export or import stored credentials (%s [-config path] auth help)\n", os.Args[0])
+		_, _ = fmt.Fprintf(out, "  bench\n    Load-test a proxy or OpenAI-compatible upstream (%s bench -h)\n", os.Args[0])
+		_, _ = fmt.Fprintf(out, "  mock\n    Serve a scripted OpenAI/Claude/Gemini upstream for offline testing (%s mock -h)\n", os.Args[0])
+		_, _ = fmt.Fprintf(out, "  conformance\n    Check translators against golden fixtures, -update regenerates them (%s conformance -h)\n", os.Args[0])
 	}
 
 	// Parse the command-line flags.
@@ -135,6 +157,7 @@ func main() {
 		objectStoreBucket    string
 		objectStoreLocalPath string
 		objectStoreInst      *store.ObjectTokenStore
+		remoteConfigSyncer   *remoteconfig.Syncer
 	)
 
 	wd, err := os.Getwd()
@@ -211,6 +234,12 @@ func main() {
 		objectStoreLocalPath = value
 	}
 
+	if configURL == "" {
+		if value, ok := lookupEnv("CONFIG_URL", "config_url"); ok {
+			configURL = value
+		}
+	}
+
 	// Check for cloud deploy mode only on first execution
 	// Read env var name in uppercase: DEPLOY
 	deployEnv := os.Getenv("DEPLOY")
@@ -362,6 +391,34 @@ func main() {
 			cfg.AuthDir = gitStoreInst.AuthDir()
 			log.Infof("git-backed token store enabled, repository path: %s", gitStoreRoot)
 		}
+	} else if configURL != "" {
+		configFilePath = configPath
+		if configFilePath == "" {
+			base := writableBase
+			if base == "" {
+				base = wd
+			}
+			configFilePath = filepath.Join(base, "remote-config", "config.yaml")
+		}
+		remoteConfigSyncer, err = newRemoteConfigSyncer(configURL, configFilePath, lookupEnv)
+		if err != nil {
+			log.Errorf("failed to initialize remote config source: %v", err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if _, errSync := remoteConfigSyncer.Sync(ctx); errSync != nil {
+			if _, errStat := os.Stat(configFilePath); errStat != nil {
+				cancel()
+				log.Errorf("failed to fetch remote config and no cached copy exists: %v", errSync)
+				return
+			}
+			log.Warnf("failed to fetch remote config, using cached copy %s: %v", configFilePath, errSync)
+		}
+		cancel()
+		cfg, err = config.LoadConfigOptional(configFilePath, isCloudDeploy)
+		if err == nil {
+			log.Infof("remote config source enabled, local copy: %s", configFilePath)
+		}
 	} else if configPath != "" {
 		configFilePath = configPath
 		cfg, err = config.LoadConfigOptional(configPath, isCloudDeploy)
@@ -404,6 +461,10 @@ func main() {
 	}
 	usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 	coreauth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	if errCrypt := credcrypt.Configure(cfg.CredentialEncryption); errCrypt != nil {
+		log.Errorf("failed to configure credential encryption: %v", errCrypt)
+		return
+	}
 
 	if err = logging.ConfigureLogOutput(cfg); err != nil {
 		log.Errorf("failed to configure log output: %v", err)
@@ -441,10 +502,14 @@ func main() {
 
 	// Register built-in access providers before constructing services.
 	configaccess.Register()
+	certaccess.Register()
 
 	// Handle different command modes based on the provided flags.
 
-	if vertexImport != "" {
+	if flag.NArg() > 0 && flag.Arg(0) == "auth" {
+		// Handle credential management subcommands
+		os.Exit(cmd.RunAuth(cfg, flag.Args()[1:], options))
+	} else if vertexImport != "" {
 		// Handle Vertex service account import
 		cmd.DoVertexImport(cfg, vertexImport)
 	} else if login {
@@ -473,7 +538,51 @@ func main() {
 			return
 		}
 		// Start the main proxy service
+		if remoteConfigSyncer != nil {
+			go remoteConfigSyncer.Run(context.Background())
+		}
 		managementasset.StartAutoUpdater(context.Background(), configFilePath)
 		cmd.StartService(cfg, configFilePath, password)
 	}
 }
+
+// newRemoteConfigSyncer builds the remote config syncer from the CONFIG_* environment variables.
+func newRemoteConfigSyncer(rawURL, localPath string, lookupEnv func(...string) (string, bool)) (*remoteconfig.Syncer, error) {
+	opts := remoteconfig.Options{URL: rawURL}
+	if value, ok := lookupEnv("CONFIG_SIGNATURE_URL", "config_signature_url"); ok {
+		opts.SignatureURL = value
+	}
+	if value, ok := lookupEnv("CONFIG_PUBLIC_KEY", "config_public_key"); ok {
+		if data, errRead := os.ReadFile(value); errRead == nil {
+			value = string(data)
+		}
+		key, errKey := remoteconfig.ParsePublicKey(value)
+		if errKey != nil {
+			return nil, errKey
+		}
+		opts.PublicKey = key
+	}
+	if value, ok := lookupEnv("CONFIG_POLL_INTERVAL", "config_poll_interval"); ok {
+		interval, errParse := time.ParseDuration(value)
+		if errParse != nil {
+			return nil, fmt.Errorf("invalid CONFIG_POLL_INTERVAL %q: %w", value, errParse)
+		}
+		opts.Interval = interval
+	}
+	if value, ok := lookupEnv("CONFIG_AUTHORIZATION", "config_authorization"); ok {
+		opts.Header = http.Header{"Authorization": []string{value}}
+	}
+	if value, ok := lookupEnv("CONFIG_OBJECTSTORE_ENDPOINT", "config_objectstore_endpoint"); ok {
+		opts.Endpoint = value
+	}
+	if value, ok := lookupEnv("CONFIG_OBJECTSTORE_ACCESS_KEY", "config_objectstore_access_key"); ok {
+		opts.AccessKey = value
+	}
+	if value, ok := lookupEnv("CONFIG_OBJECTSTORE_SECRET_KEY", "config_objectstore_secret_key"); ok {
+		opts.SecretKey = value
+	}
+	if value, ok := lookupEnv("CONFIG_OBJECTSTORE_REGION", "config_objectstore_region"); ok {
+		opts.Region = value
+	}
+	return remoteconfig.New(opts, localPath)
+}
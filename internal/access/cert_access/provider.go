@@ -0,0 +1,139 @@
+// Package certaccess authenticates clients by the TLS certificate they presented to a listener
+// running with mutual TLS, mapping certificate identities to principals that behave like API keys.
+package certaccess
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+var registerOnce sync.Once
+
+// Register ensures the client-certificate provider is available to the access manager.
+func Register() {
+	registerOnce.Do(func() {
+		sdkaccess.RegisterProvider(sdkconfig.AccessProviderTypeClientCertificate, newProvider)
+	})
+}
+
+type identity struct {
+	kind      string
+	value     string
+	principal string
+}
+
+type provider struct {
+	name          string
+	identities    []identity
+	allowUnmapped bool
+}
+
+// newProvider reads config.identities, a list of {match, principal} entries where match is one
+// of "cn:", "dns:", "email:", "uri:" or "sha256:" followed by the expected value, and
+// config.allow-unmapped, which admits any verified certificate as "cert:<common name>".
+func newProvider(cfg *sdkconfig.AccessProvider, _ *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+	p := &provider{name: cfg.Name}
+	if p.name == "" {
+		p.name = sdkconfig.AccessProviderTypeClientCertificate
+	}
+	if v, ok := cfg.Config["allow-unmapped"].(bool); ok {
+		p.allowUnmapped = v
+	}
+	rawIdentities, _ := cfg.Config["identities"].([]any)
+	for i, raw := range rawIdentities {
+		entry, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("identities[%d] must be a mapping", i)
+		}
+		match, _ := entry["match"].(string)
+		principal, _ := entry["principal"].(string)
+		kind, value, found := strings.Cut(strings.TrimSpace(match), ":")
+		kind = strings.ToLower(kind)
+		if !found || value == "" || strings.TrimSpace(principal) == "" {
+			return nil, fmt.Errorf("identities[%d] requires match \"<kind>:<value>\" and principal", i)
+		}
+		switch kind {
+		case "cn", "dns", "email", "uri":
+		case "sha256":
+			value = strings.ToLower(strings.ReplaceAll(value, ":", ""))
+		default:
+			return nil, fmt.Errorf("identities[%d]: unsupported match kind %q", i, kind)
+		}
+		p.identities = append(p.identities, identity{kind: kind, value: value, principal: strings.TrimSpace(principal)})
+	}
+	if len(p.identities) == 0 && !p.allowUnmapped {
+		return nil, fmt.Errorf("client-certificate provider requires identities or allow-unmapped")
+	}
+	return p, nil
+}
+
+func (p *provider) Identifier() string {
+	if p == nil || p.name == "" {
+		return sdkconfig.AccessProviderTypeClientCertificate
+	}
+	return p.name
+}
+
+// Authenticate only trusts certificates the TLS stack verified against tls.client-ca.
+func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if p == nil {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, sdkaccess.ErrNoCredentials
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+	metadata := map[string]string{
+		"source":      "client-certificate",
+		"subject":     cert.Subject.String(),
+		"fingerprint": fingerprint,
+	}
+	for _, id := range p.identities {
+		if matches(id, cert, fingerprint) {
+			return &sdkaccess.Result{Provider: p.Identifier(), Principal: id.principal, Metadata: metadata}, nil
+		}
+	}
+	if p.allowUnmapped && cert.Subject.CommonName != "" {
+		return &sdkaccess.Result{Provider: p.Identifier(), Principal: "cert:" + cert.Subject.CommonName, Metadata: metadata}, nil
+	}
+	return nil, sdkaccess.ErrInvalidCredential
+}
+
+func matches(id identity, cert *x509.Certificate, fingerprint string) bool {
+	switch id.kind {
+	case "cn":
+		return cert.Subject.CommonName == id.value
+	case "dns":
+		for _, name := range cert.DNSNames {
+			if strings.EqualFold(name, id.value) {
+				return true
+			}
+		}
+	case "email":
+		for _, addr := range cert.EmailAddresses {
+			if strings.EqualFold(addr, id.value) {
+				return true
+			}
+		}
+	case "uri":
+		for _, uri := range cert.URIs {
+			if uri.String() == id.value {
+				return true
+			}
+		}
+	case "sha256":
+		return fingerprint == id.value
+	}
+	return false
+}
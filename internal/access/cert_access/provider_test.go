@@ -0,0 +1,103 @@
+package certaccess
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func newTestCert(t *testing.T, cn string, dns ...string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     dns,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestAuthenticateMapsCertificateIdentities(t *testing.T) {
+	svc := newTestCert(t, "build-bot", "ci.corp.example")
+	sum := sha256.Sum256(svc.Raw)
+
+	p, err := newProvider(&sdkconfig.AccessProvider{
+		Name: "mtls",
+		Type: sdkconfig.AccessProviderTypeClientCertificate,
+		Config: map[string]any{"identities": []any{
+			map[string]any{"match": "dns:ci.corp.example", "principal": "ci-key"},
+			map[string]any{"match": "sha256:" + hex.EncodeToString(sum[:]), "principal": "unused"},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	if _, err = p.Authenticate(context.Background(), req); !errors.Is(err, sdkaccess.ErrNoCredentials) {
+		t.Fatalf("expected ErrNoCredentials without TLS, got %v", err)
+	}
+
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{svc}}}
+	res, err := p.Authenticate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if res.Principal != "ci-key" || res.Provider != "mtls" || res.Metadata["subject"] != "CN=build-bot" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{newTestCert(t, "stranger")}}}
+	if _, err = p.Authenticate(context.Background(), req); !errors.Is(err, sdkaccess.ErrInvalidCredential) {
+		t.Fatalf("expected ErrInvalidCredential for unmapped cert, got %v", err)
+	}
+
+	// Unverified peer certificates are never trusted.
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{svc}}
+	if _, err = p.Authenticate(context.Background(), req); !errors.Is(err, sdkaccess.ErrNoCredentials) {
+		t.Fatalf("expected ErrNoCredentials for unverified cert, got %v", err)
+	}
+}
+
+func TestAllowUnmappedUsesCommonName(t *testing.T) {
+	p, err := newProvider(&sdkconfig.AccessProvider{Config: map[string]any{"allow-unmapped": true}}, nil)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/v1/models", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{newTestCert(t, "alice")}}}
+	res, err := p.Authenticate(context.Background(), req)
+	if err != nil || res.Principal != "cert:alice" {
+		t.Fatalf("unexpected result %+v, %v", res, err)
+	}
+	if _, err = newProvider(&sdkconfig.AccessProvider{}, nil); err == nil {
+		t.Fatalf("expected error without identities")
+	}
+}
@@ -0,0 +1,248 @@
+// Package analytics tees completed conversations to an offline sink for evaluation and
+// fine-tuning datasets.
+//
+// A record holds the client request, the final response reassembled from the streamed events or
+// the buffered body, and the tool calls the model made. Records are sampled, optionally redacted,
+// and delivered in batches from a bounded queue so the tee never delays a response.
+package analytics
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+)
+
+// Record is one teed conversation.
+type Record struct {
+	ID         string          `json:"id"`
+	RequestID  string          `json:"request_id,omitempty"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Format     string          `json:"format"`
+	Model      string          `json:"model"`
+	Stream     bool            `json:"stream"`
+	DurationMs int64           `json:"duration_ms"`
+	Request    json.RawMessage `json:"request"`
+	Response   Output          `json:"response"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Output is the final model output of a response.
+type Output struct {
+	Text         string     `json:"text"`
+	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+	FinishReason string     `json:"finish_reason,omitempty"`
+}
+
+// ToolCall is one tool invocation made by the model. Arguments is the JSON argument document.
+type ToolCall struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Assembler rebuilds the final output of a response from its streamed events in any supported
+// client format: OpenAI chat completions, Claude messages, OpenAI responses and Gemini.
+type Assembler struct {
+	text   strings.Builder
+	calls  map[string]*ToolCall
+	order  []string
+	finish string
+}
+
+// NewAssembler returns an empty assembler.
+func NewAssembler() *Assembler {
+	return &Assembler{calls: make(map[string]*ToolCall)}
+}
+
+// Add consumes one chunk as forwarded to the client.
+func (a *Assembler) Add(chunk []byte) {
+	for _, block := range sse.SplitBlocks(chunk) {
+		if len(block.Payload) > 0 && block.Payload[0] == '{' {
+			a.addEvent(gjson.ParseBytes(block.Payload))
+		}
+	}
+}
+
+// Output returns the output assembled so far.
+func (a *Assembler) Output() Output {
+	out := Output{Text: a.text.String(), FinishReason: a.finish}
+	for _, key := range a.order {
+		out.ToolCalls = append(out.ToolCalls, *a.calls[key])
+	}
+	return out
+}
+
+// call returns the tool call tracked under key, creating it on first use.
+func (a *Assembler) call(key string) *ToolCall {
+	if c, ok := a.calls[key]; ok {
+		return c
+	}
+	c := &ToolCall{}
+	a.calls[key] = c
+	a.order = append(a.order, key)
+	return c
+}
+
+func (a *Assembler) addEvent(event gjson.Result) {
+	switch event.Get("type").String() {
+	case "content_block_start":
+		if block := event.Get("content_block"); block.Get("type").String() == "tool_use" {
+			c := a.call("claude:" + event.Get("index").String())
+			c.ID, c.Name = block.Get("id").String(), block.Get("name").String()
+		}
+		return
+	case "content_block_delta":
+		switch delta := event.Get("delta"); delta.Get("type").String() {
+		case "text_delta":
+			a.text.WriteString(delta.Get("text").String())
+		case "input_json_delta":
+			a.call("claude:" + event.Get("index").String()).Arguments += delta.Get("partial_json").String()
+		}
+		return
+	case "message_delta":
+		if reason := event.Get("delta.stop_reason").String(); reason != "" {
+			a.finish = reason
+		}
+		return
+	case "response.output_text.delta":
+		a.text.WriteString(event.Get("delta").String())
+		return
+	case "response.output_item.done":
+		if item := event.Get("item"); item.Get("type").String() == "function_call" {
+			c := a.call("responses:" + item.Get("call_id").String())
+			c.ID, c.Name, c.Arguments = item.Get("call_id").String(), item.Get("name").String(), item.Get("arguments").String()
+		}
+		return
+	case "response.completed", "response.incomplete", "response.failed":
+		a.finish = event.Get("response.status").String()
+		return
+	}
+
+	if choice := event.Get("choices.0"); choice.Exists() {
+		a.text.WriteString(choice.Get("delta.content").String())
+		a.text.WriteString(choice.Get("text").String())
+		choice.Get("delta.tool_calls").ForEach(func(_, tc gjson.Result) bool {
+			c := a.call("openai:" + tc.Get("index").String())
+			if id := tc.Get("id").String(); id != "" {
+				c.ID = id
+			}
+			if name := tc.Get("function.name").String(); name != "" {
+				c.Name = name
+			}
+			c.Arguments += tc.Get("function.arguments").String()
+			return true
+		})
+		if reason := choice.Get("finish_reason").String(); reason != "" {
+			a.finish = reason
+		}
+		return
+	}
+
+	candidate := event.Get("candidates.0")
+	if !candidate.Exists() {
+		candidate = event.Get("response.candidates.0")
+	}
+	a.addCandidate(candidate)
+}
+
+// addCandidate consumes a Gemini candidate; thought parts are not part of the output.
+func (a *Assembler) addCandidate(candidate gjson.Result) {
+	candidate.Get("content.parts").ForEach(func(_, part gjson.Result) bool {
+		if fc := part.Get("functionCall"); fc.Exists() {
+			c := a.call("gemini:" + strconv.Itoa(len(a.order)))
+			c.ID, c.Name = fc.Get("id").String(), fc.Get("name").String()
+			c.Arguments = fc.Get("args").Raw
+			if c.Arguments == "" {
+				c.Arguments = "{}"
+			}
+		} else if !part.Get("thought").Bool() {
+			a.text.WriteString(part.Get("text").String())
+		}
+		return true
+	})
+	if reason := candidate.Get("finishReason").String(); reason != "" {
+		a.finish = reason
+	}
+}
+
+// Assemble extracts the final output of a buffered (non-streaming) response body.
+func Assemble(body []byte) Output {
+	root := gjson.ParseBytes(body)
+	a := NewAssembler()
+	switch {
+	case root.Get("type").String() == "message" && root.Get("content").IsArray():
+		root.Get("content").ForEach(func(i, block gjson.Result) bool {
+			switch block.Get("type").String() {
+			case "text":
+				a.text.WriteString(block.Get("text").String())
+			case "tool_use":
+				c := a.call("claude:" + i.String())
+				c.ID, c.Name, c.Arguments = block.Get("id").String(), block.Get("name").String(), block.Get("input").Raw
+			}
+			return true
+		})
+		a.finish = root.Get("stop_reason").String()
+	case root.Get("object").String() == "response":
+		root.Get("output").ForEach(func(_, item gjson.Result) bool {
+			switch item.Get("type").String() {
+			case "message":
+				item.Get("content").ForEach(func(_, part gjson.Result) bool {
+					if part.Get("type").String() == "output_text" {
+						a.text.WriteString(part.Get("text").String())
+					}
+					return true
+				})
+			case "function_call":
+				c := a.call("responses:" + item.Get("call_id").String())
+				c.ID, c.Name, c.Arguments = item.Get("call_id").String(), item.Get("name").String(), item.Get("arguments").String()
+			}
+			return true
+		})
+		a.finish = root.Get("status").String()
+	case root.Get("choices.0").Exists():
+		choice := root.Get("choices.0")
+		message := choice.Get("message")
+		if content := message.Get("content"); content.IsArray() {
+			content.ForEach(func(_, part gjson.Result) bool {
+				if part.Get("type").String() == "text" {
+					a.text.WriteString(part.Get("text").String())
+				}
+				return true
+			})
+		} else {
+			a.text.WriteString(content.String())
+		}
+		a.text.WriteString(choice.Get("text").String())
+		message.Get("tool_calls").ForEach(func(i, tc gjson.Result) bool {
+			c := a.call("openai:" + i.String())
+			c.ID, c.Name, c.Arguments = tc.Get("id").String(), tc.Get("function.name").String(), tc.Get("function.arguments").String()
+			return true
+		})
+		a.finish = choice.Get("finish_reason").String()
+	default:
+		candidate := root.Get("candidates.0")
+		if !candidate.Exists() {
+			candidate = root.Get("response.candidates.0")
+		}
+		a.addCandidate(candidate)
+	}
+	return a.Output()
+}
+
+// matchModel reports whether model matches any pattern; an empty list matches every model.
+func matchModel(patterns []string, model string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if util.MatchModelWildcard(strings.TrimSpace(pattern), model) {
+			return true
+		}
+	}
+	return false
+}
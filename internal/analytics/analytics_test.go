@@ -0,0 +1,120 @@
+package analytics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func assemble(chunks ...string) Output {
+	a := NewAssembler()
+	for _, chunk := range chunks {
+		a.Add([]byte(chunk))
+	}
+	return a.Output()
+}
+
+func TestAssemblerStreams(t *testing.T) {
+	cases := []struct {
+		name   string
+		chunks []string
+	}{
+		{"openai", []string{
+			`{"choices":[{"index":0,"delta":{"content":"Hel"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"lo","tool_calls":[{"index":0,"id":"call_1","function":{"name":"lookup","arguments":"{\"q\":"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"1}"}}]},"finish_reason":"tool_calls"}]}`,
+		}},
+		{"claude", []string{
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n" +
+				"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"call_1\",\"name\":\"lookup\",\"input\":{}}}\n\n",
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"q\\\":1}\"}}\n\n",
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"}}\n\n",
+		}},
+		{"responses", []string{
+			"event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"Hello\"}",
+			"event: response.output_item.done\ndata: {\"type\":\"response.output_item.done\",\"item\":{\"type\":\"function_call\",\"call_id\":\"call_1\",\"name\":\"lookup\",\"arguments\":\"{\\\"q\\\":1}\"}}",
+			"event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{\"status\":\"completed\"}}",
+		}},
+		{"gemini", []string{
+			`{"candidates":[{"content":{"parts":[{"text":"thinking","thought":true},{"text":"Hello"}]}}]}`,
+			`{"candidates":[{"content":{"parts":[{"functionCall":{"name":"lookup","args":{"q":1}}}]},"finishReason":"STOP"}]}`,
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := assemble(tc.chunks...)
+			if out.Text != "Hello" {
+				t.Fatalf("text = %q", out.Text)
+			}
+			if len(out.ToolCalls) != 1 || out.ToolCalls[0].Name != "lookup" || gjson.Get(out.ToolCalls[0].Arguments, "q").Int() != 1 {
+				t.Fatalf("tool calls = %+v", out.ToolCalls)
+			}
+			if out.FinishReason == "" {
+				t.Fatal("finish reason missing")
+			}
+		})
+	}
+}
+
+func TestAssembleBufferedBodies(t *testing.T) {
+	bodies := map[string]string{
+		"openai":    `{"choices":[{"message":{"content":"Hello","tool_calls":[{"id":"c","function":{"name":"lookup","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`,
+		"claude":    `{"type":"message","content":[{"type":"text","text":"Hello"},{"type":"tool_use","id":"c","name":"lookup","input":{}}],"stop_reason":"tool_use"}`,
+		"responses": `{"object":"response","status":"completed","output":[{"type":"message","content":[{"type":"output_text","text":"Hello"}]},{"type":"function_call","call_id":"c","name":"lookup","arguments":"{}"}]}`,
+		"gemini":    `{"candidates":[{"content":{"parts":[{"text":"Hello"},{"functionCall":{"name":"lookup","args":{}}}]},"finishReason":"STOP"}]}`,
+	}
+	for name, body := range bodies {
+		out := Assemble([]byte(body))
+		if out.Text != "Hello" || len(out.ToolCalls) != 1 || out.ToolCalls[0].Name != "lookup" || out.FinishReason == "" {
+			t.Fatalf("%s: output = %+v", name, out)
+		}
+	}
+}
+
+type memorySink struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (s *memorySink) Write(_ context.Context, lines [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, line := range lines {
+		s.lines = append(s.lines, string(line))
+	}
+	return nil
+}
+
+func (s *memorySink) Close() error { return nil }
+
+func TestTeeRedactsAndDelivers(t *testing.T) {
+	sink := &memorySink{}
+	tee, err := newTee(config.AnalyticsTeeConfig{Redact: true, Models: []string{"claude-*"}}, config.PIIRedactionConfig{Detectors: []string{"email"}}, sink)
+	if err != nil {
+		t.Fatalf("newTee: %v", err)
+	}
+	if tee.Sample("gpt-4o") || !tee.Sample("claude-sonnet-4") {
+		t.Fatal("model filter not applied")
+	}
+	tee.Submit(Record{
+		Model:    "claude-sonnet-4",
+		Request:  []byte(`{"messages":[{"role":"user","content":"mail bob@example.com"}]}`),
+		Response: Output{Text: "sent to bob@example.com"},
+	})
+	tee.Close()
+
+	if len(sink.lines) != 1 {
+		t.Fatalf("delivered %d records, want 1", len(sink.lines))
+	}
+	line := sink.lines[0]
+	if strings.Contains(line, "bob@example.com") {
+		t.Fatalf("record not redacted: %s", line)
+	}
+	if gjson.Get(line, "id").String() == "" || gjson.Get(line, "request.messages.0.role").String() != "user" {
+		t.Fatalf("unexpected record: %s", line)
+	}
+}
@@ -0,0 +1,148 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Sink delivers batches of records encoded as JSON lines.
+type Sink interface {
+	Write(ctx context.Context, lines [][]byte) error
+	Close() error
+}
+
+// NewSink builds the sink selected by cfg.
+func NewSink(cfg config.AnalyticsSinkConfig) (Sink, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Type)) {
+	case "file", "":
+		return newFileSink(cfg.Path)
+	case "http":
+		if strings.TrimSpace(cfg.URL) == "" {
+			return nil, fmt.Errorf("analytics: http sink requires url")
+		}
+		return &httpSink{url: cfg.URL, headers: cfg.Headers, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	case "s3":
+		return newS3Sink(cfg.S3)
+	default:
+		return nil, fmt.Errorf("analytics: unknown sink type %q", cfg.Type)
+	}
+}
+
+func joinLines(lines [][]byte) []byte {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// fileSink appends records to a local JSON lines file.
+type fileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("analytics: file sink requires path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("analytics: create directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("analytics: open %s: %w", path, err)
+	}
+	return &fileSink{file: file}, nil
+}
+
+func (s *fileSink) Write(_ context.Context, lines [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.file.Write(joinLines(lines))
+	return err
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// httpSink posts each batch as an application/x-ndjson body.
+type httpSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (s *httpSink) Write(ctx context.Context, lines [][]byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(joinLines(lines)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error { return nil }
+
+// s3Sink writes each batch as one object under a date-partitioned key.
+type s3Sink struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func newS3Sink(cfg config.ObjectStorageConfig) (*s3Sink, error) {
+	if strings.TrimSpace(cfg.Endpoint) == "" || strings.TrimSpace(cfg.Bucket) == "" {
+		return nil, fmt.Errorf("analytics: s3 sink requires endpoint and bucket")
+	}
+	options := &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	}
+	if cfg.PathStyle {
+		options.BucketLookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(cfg.Endpoint, options)
+	if err != nil {
+		return nil, fmt.Errorf("analytics: create s3 client: %w", err)
+	}
+	return &s3Sink{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+func (s *s3Sink) Write(ctx context.Context, lines [][]byte) error {
+	data := joinLines(lines)
+	key := s.prefix + time.Now().UTC().Format("2006/01/02/150405-") + uuid.NewString() + ".jsonl"
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: "application/x-ndjson"})
+	return err
+}
+
+func (s *s3Sink) Close() error { return nil }
@@ -0,0 +1,181 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pii"
+	log "github.com/sirupsen/logrus"
+)
+
+// queueSize bounds the records waiting for delivery; overflow is dropped and logged so a slow
+// sink never stalls request handling.
+const queueSize = 1024
+
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = 30 * time.Second
+	deliveryTimeout      = time.Minute
+)
+
+// Tee samples conversations and delivers them to a sink on a background goroutine.
+type Tee struct {
+	sampleRate float64
+	models     []string
+	redactor   *pii.Redactor
+	sink       Sink
+	batchSize  int
+	interval   time.Duration
+
+	queue   chan Record
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+// New builds a tee and its sink. piiCfg supplies the detectors used when cfg.Redact is set.
+func New(cfg config.AnalyticsTeeConfig, piiCfg config.PIIRedactionConfig) (*Tee, error) {
+	sink, err := NewSink(cfg.Sink)
+	if err != nil {
+		return nil, err
+	}
+	t, err := newTee(cfg, piiCfg, sink)
+	if err != nil {
+		_ = sink.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func newTee(cfg config.AnalyticsTeeConfig, piiCfg config.PIIRedactionConfig, sink Sink) (*Tee, error) {
+	t := &Tee{
+		sampleRate: cfg.SampleRate,
+		models:     cfg.Models,
+		sink:       sink,
+		batchSize:  cfg.BatchSize,
+		interval:   time.Duration(cfg.FlushIntervalSeconds) * time.Second,
+		queue:      make(chan Record, queueSize),
+		done:       make(chan struct{}),
+	}
+	if t.sampleRate <= 0 || t.sampleRate > 1 {
+		t.sampleRate = 1
+	}
+	if t.batchSize <= 0 {
+		t.batchSize = defaultBatchSize
+	}
+	if t.interval <= 0 {
+		t.interval = defaultFlushInterval
+	}
+	if cfg.Redact {
+		redactor, err := pii.New(piiCfg)
+		if err != nil {
+			return nil, err
+		}
+		t.redactor = redactor
+	}
+	go t.run()
+	return t, nil
+}
+
+// Sample reports whether a request for model should be recorded.
+func (t *Tee) Sample(model string) bool {
+	if t == nil || !matchModel(t.models, model) {
+		return false
+	}
+	return t.sampleRate >= 1 || rand.Float64() < t.sampleRate
+}
+
+// Submit queues a record for delivery without blocking.
+func (t *Tee) Submit(rec Record) {
+	if t == nil {
+		return
+	}
+	if rec.ID == "" {
+		rec.ID = uuid.NewString()
+	}
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = time.Now().UTC()
+	}
+	select {
+	case t.queue <- rec:
+	default:
+		if dropped := t.dropped.Add(1); dropped == 1 || dropped%1000 == 0 {
+			log.Warnf("analytics tee queue full, %d records dropped", dropped)
+		}
+	}
+}
+
+// Close delivers the queued records and closes the sink.
+func (t *Tee) Close() {
+	if t == nil {
+		return
+	}
+	t.once.Do(func() {
+		close(t.queue)
+		select {
+		case <-t.done:
+		case <-time.After(deliveryTimeout):
+			log.Warn("analytics tee: timed out delivering queued records")
+		}
+		if err := t.sink.Close(); err != nil {
+			log.Warnf("analytics tee: close sink: %v", err)
+		}
+	})
+}
+
+func (t *Tee) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	batch := make([][]byte, 0, t.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+		if err := t.sink.Write(ctx, batch); err != nil {
+			log.Warnf("analytics tee: deliver %d records: %v", len(batch), err)
+		}
+		cancel()
+		batch = make([][]byte, 0, t.batchSize)
+	}
+	for {
+		select {
+		case rec, ok := <-t.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if line := t.encode(rec); line != nil {
+				batch = append(batch, line)
+			}
+			if len(batch) >= t.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// encode marshals a record, redacting every string value when redaction is enabled.
+func (t *Tee) encode(rec Record) []byte {
+	if len(rec.Request) == 0 || !json.Valid(rec.Request) {
+		rec.Request = json.RawMessage("null")
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Warnf("analytics tee: encode record: %v", err)
+		return nil
+	}
+	if t.redactor != nil {
+		data = t.redactor.NewSession().RedactJSON(data)
+	}
+	return data
+}
@@ -0,0 +1,10 @@
+// Package adminui embeds the operator dashboard served at /admin/ui.
+package adminui
+
+import _ "embed"
+
+//go:embed index.html
+var indexHTML []byte
+
+// Index returns the dashboard page.
+func Index() []byte { return indexHTML }
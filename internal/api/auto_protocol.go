@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/claude"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// protocolHeader reports which client protocol /v1/auto detected.
+const protocolHeader = "X-CLIProxy-Protocol"
+
+const (
+	protocolOpenAI          = "openai"
+	protocolOpenAIResponses = "openai-response"
+	protocolClaude          = "claude"
+	protocolGemini          = "gemini"
+)
+
+// detectProtocol infers the client protocol from the shape of a request body. Gemini requests
+// carry "contents", Responses API requests carry "input", and chat requests carry "messages";
+// those are told apart by fields only one of OpenAI and Claude defines, with a bare
+// messages+max_tokens body treated as Claude, whose API requires max_tokens.
+func detectProtocol(header http.Header, body []byte) string {
+	root := gjson.ParseBytes(body)
+	switch {
+	case root.Get("contents").Exists():
+		return protocolGemini
+	case !root.Get("messages").Exists() && root.Get("input").Exists():
+		return protocolOpenAIResponses
+	case !root.Get("messages").Exists():
+		return ""
+	}
+	if header.Get("anthropic-version") != "" || header.Get("anthropic-beta") != "" {
+		return protocolClaude
+	}
+	for _, field := range []string{"system", "stop_sequences", "top_k", "thinking", "anthropic_version"} {
+		if root.Get(field).Exists() {
+			return protocolClaude
+		}
+	}
+	for _, field := range []string{"max_completion_tokens", "response_format", "stream_options", "n", "logprobs", "reasoning_effort"} {
+		if root.Get(field).Exists() {
+			return protocolOpenAI
+		}
+	}
+	claudeShape, openaiShape := false, false
+	root.Get("tools").ForEach(func(_, tool gjson.Result) bool {
+		claudeShape = claudeShape || tool.Get("input_schema").Exists()
+		openaiShape = openaiShape || tool.Get("function").Exists()
+		return true
+	})
+	root.Get("messages").ForEach(func(_, msg gjson.Result) bool {
+		switch msg.Get("role").String() {
+		case "system", "developer", "tool", "function":
+			openaiShape = true
+		}
+		openaiShape = openaiShape || msg.Get("tool_calls").Exists()
+		msg.Get("content").ForEach(func(_, part gjson.Result) bool {
+			switch part.Get("type").String() {
+			case "tool_use", "tool_result", "thinking", "document":
+				claudeShape = true
+			case "image":
+				claudeShape = claudeShape || part.Get("source").Exists()
+			case "image_url", "input_audio", "file":
+				openaiShape = true
+			}
+			return true
+		})
+		return true
+	})
+	switch {
+	case claudeShape && !openaiShape:
+		return protocolClaude
+	case openaiShape:
+		return protocolOpenAI
+	case root.Get("max_tokens").Exists():
+		return protocolClaude
+	default:
+		return protocolOpenAI
+	}
+}
+
+// autoProtocolHandler serves /v1/auto, dispatching each request to the OpenAI, Responses,
+// Claude or Gemini handler according to the detected body shape. Gemini bodies name their
+// model in a "model" field or query parameter and stream when "stream" is true.
+func (s *Server) autoProtocolHandler(openaiHandler *openai.OpenAIAPIHandler, responsesHandler *openai.OpenAIResponsesAPIHandler, claudeHandler *claude.ClaudeCodeAPIHandler, geminiHandler *gemini.GeminiAPIHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{Message: "failed to read request body", Type: "invalid_request_error"},
+			})
+			return
+		}
+		protocol := detectProtocol(c.Request.Header, body)
+		if protocol == "" {
+			c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{
+					Message: "unable to detect the request protocol: expected messages, input or contents",
+					Type:    "invalid_request_error",
+				},
+			})
+			return
+		}
+		trace.Record(c.Request.Context(), "protocol", "detected "+protocol, nil)
+		c.Header(protocolHeader, protocol)
+
+		switch protocol {
+		case protocolGemini:
+			model := strings.TrimSpace(gjson.GetBytes(body, "model").String())
+			if model == "" {
+				model = strings.TrimSpace(c.Query("model"))
+			}
+			model = strings.TrimPrefix(model, "models/")
+			if model == "" {
+				c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+					Error: handlers.ErrorDetail{Message: "model is required for Gemini requests", Type: "invalid_request_error"},
+				})
+				return
+			}
+			method := "generateContent"
+			if gjson.GetBytes(body, "stream").Bool() {
+				method = "streamGenerateContent"
+			}
+			body, _ = sjson.DeleteBytes(body, "model")
+			body, _ = sjson.DeleteBytes(body, "stream")
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Params = append(c.Params, gin.Param{Key: "action", Value: "/" + model + ":" + method})
+			geminiHandler.GeminiHandler(c)
+		case protocolOpenAIResponses:
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			responsesHandler.Responses(c)
+		case protocolClaude:
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			claudeHandler.ClaudeMessages(c)
+		default:
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			openaiHandler.ChatCompletions(c)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDetectProtocol(t *testing.T) {
+	cases := []struct {
+		name   string
+		header http.Header
+		body   string
+		want   string
+	}{
+		{"gemini contents", nil, `{"model":"gemini-2.5-pro","contents":[{"role":"user","parts":[{"text":"hi"}]}]}`, protocolGemini},
+		{"responses input", nil, `{"model":"gpt-5","input":"hi"}`, protocolOpenAIResponses},
+		{"claude max_tokens", nil, `{"model":"claude-sonnet-4","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`, protocolClaude},
+		{"claude system field", nil, `{"model":"m","system":"be brief","messages":[{"role":"user","content":"hi"}]}`, protocolClaude},
+		{"claude header", http.Header{"Anthropic-Version": {"2023-06-01"}}, `{"model":"m","messages":[{"role":"user","content":"hi"}]}`, protocolClaude},
+		{"claude tool blocks", nil, `{"model":"m","messages":[{"role":"user","content":[{"type":"tool_result","tool_use_id":"t","content":"ok"}]}]}`, protocolClaude},
+		{"openai plain", nil, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, protocolOpenAI},
+		{"openai system role", nil, `{"model":"gpt-4o","max_tokens":64,"messages":[{"role":"system","content":"x"},{"role":"user","content":"hi"}]}`, protocolOpenAI},
+		{"openai function tools", nil, `{"model":"gpt-4o","max_tokens":64,"tools":[{"type":"function","function":{"name":"f"}}],"messages":[{"role":"user","content":"hi"}]}`, protocolOpenAI},
+		{"unknown", nil, `{"prompt":"hi"}`, ""},
+	}
+	for _, tc := range cases {
+		header := tc.header
+		if header == nil {
+			header = http.Header{}
+		}
+		if got := detectProtocol(header, []byte(tc.body)); got != tc.want {
+			t.Errorf("%s: detectProtocol = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/grpcapi"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const defaultGRPCAddress = ":8319"
+
+// startGRPC serves the gRPC surface, dispatching calls to the API routes of handler. tlsConfig,
+// when non-nil, carries the certificates used when grpc.tls is enabled.
+func (s *Server) startGRPC(handler http.Handler, tlsConfig *tls.Config) error {
+	address := strings.TrimSpace(s.cfg.GRPC.Address)
+	if address == "" {
+		address = defaultGRPCAddress
+	}
+	var opts []grpc.ServerOption
+	if s.cfg.GRPC.TLS {
+		if tlsConfig == nil {
+			return fmt.Errorf("grpc %s: tls requested but tls is not configured", address)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("grpc %s: %w", address, err)
+	}
+	s.grpcServer = grpcapi.NewServer(scopeHandler(handler, serveAPI), opts...)
+	log.Infof("serving gRPC on %s", ln.Addr())
+	go func(srv *grpc.Server, ln net.Listener) {
+		if errServe := srv.Serve(ln); errServe != nil {
+			log.Errorf("grpc listener %s stopped: %v", ln.Addr(), errServe)
+		}
+	}(s.grpcServer, ln)
+	return nil
+}
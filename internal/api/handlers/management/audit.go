@@ -0,0 +1,23 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+)
+
+// VerifyAuditLog walks the audit log and reports whether its hash chain is intact.
+func (h *Handler) VerifyAuditLog(c *gin.Context) {
+	path := audit.DefaultPath()
+	if path == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "audit logging is disabled"})
+		return
+	}
+	result, err := audit.Verify(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"path": path, "result": result})
+}
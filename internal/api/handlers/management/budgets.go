@@ -0,0 +1,42 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/budget"
+)
+
+// GetSpendBudgets reports the current-period spend of every key and tenant under a spend budget.
+func (h *Handler) GetSpendBudgets(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"budgets": budget.Default().Snapshot()})
+}
+
+// ResetSpendBudget clears the current-period spend of a budget, for one key or tenant or for
+// every subject when neither is given.
+func (h *Handler) ResetSpendBudget(c *gin.Context) {
+	var body struct {
+		Budget  string `json:"budget"`
+		APIKey  string `json:"api-key"`
+		Tenant  string `json:"tenant"`
+		Subject string `json:"subject"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.Budget) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "budget is required"})
+		return
+	}
+	subject := strings.TrimSpace(body.Subject)
+	switch {
+	case body.APIKey != "":
+		subject = budget.KeySubject(body.APIKey)
+	case body.Tenant != "":
+		subject = budget.TenantSubject(body.Tenant)
+	}
+	reset, ok := budget.Default().Reset(c.Request.Context(), strings.TrimSpace(body.Budget), subject)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "budget not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "reset": reset})
+}
@@ -120,31 +120,12 @@ func (h *Handler) PutConfigYAML(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_yaml", "message": err.Error()})
 		return
 	}
-	// Validate config using LoadConfigOptional with optional=false to enforce parsing
-	tmpDir := filepath.Dir(h.configFilePath)
-	tmpFile, err := os.CreateTemp(tmpDir, "config-validate-*.yaml")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": err.Error()})
-		return
-	}
-	tempFile := tmpFile.Name()
-	if _, errWrite := tmpFile.Write(body); errWrite != nil {
-		_ = tmpFile.Close()
-		_ = os.Remove(tempFile)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": errWrite.Error()})
-		return
-	}
-	if errClose := tmpFile.Close(); errClose != nil {
-		_ = os.Remove(tempFile)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": errClose.Error()})
-		return
-	}
-	defer func() {
-		_ = os.Remove(tempFile)
-	}()
-	_, err = config.LoadConfigOptional(tempFile, false)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid_config", "message": err.Error()})
+	if _, status, errValidate := h.validateConfigCandidate(body); errValidate != nil {
+		code := "write_failed"
+		if status == http.StatusUnprocessableEntity {
+			code = "invalid_config"
+		}
+		c.JSON(status, gin.H{"error": code, "message": errValidate.Error()})
 		return
 	}
 	h.mu.Lock()
@@ -163,6 +144,33 @@ func (h *Handler) PutConfigYAML(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true, "changed": []string{"config"}})
 }
 
+// validateConfigCandidate parses body through the regular config loader using a temporary file
+// next to the active config. The returned status describes the failure kind.
+func (h *Handler) validateConfigCandidate(body []byte) (*config.Config, int, error) {
+	// Validate config using LoadConfigOptional with optional=false to enforce parsing
+	tmpDir := filepath.Dir(h.configFilePath)
+	tmpFile, err := os.CreateTemp(tmpDir, "config-validate-*.yaml")
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	tempFile := tmpFile.Name()
+	defer func() {
+		_ = os.Remove(tempFile)
+	}()
+	if _, errWrite := tmpFile.Write(body); errWrite != nil {
+		_ = tmpFile.Close()
+		return nil, http.StatusInternalServerError, errWrite
+	}
+	if errClose := tmpFile.Close(); errClose != nil {
+		return nil, http.StatusInternalServerError, errClose
+	}
+	cfg, err := config.LoadConfigOptional(tempFile, false)
+	if err != nil {
+		return nil, http.StatusUnprocessableEntity, err
+	}
+	return cfg, http.StatusOK, nil
+}
+
 // GetConfigYAML returns the raw config.yaml file bytes without re-encoding.
 // It preserves comments and original formatting/styles.
 func (h *Handler) GetConfigYAML(c *gin.Context) {
@@ -267,6 +275,8 @@ func normalizeRoutingStrategy(strategy string) (string, bool) {
 		return "round-robin", true
 	case "fill-first", "fillfirst", "ff":
 		return "fill-first", true
+	case "latency", "least-latency", "fastest":
+		return "latency", true
 	default:
 		return "", false
 	}
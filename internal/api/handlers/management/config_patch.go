@@ -0,0 +1,81 @@
+package management
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// PatchConfig applies a JSON merge patch (RFC 7386) to the running configuration. Patch keys are
+// the YAML option names used in config.yaml; a null value resets the option to its default.
+// The merged configuration is validated with the regular loader before it is persisted.
+func (h *Handler) PatchConfig(c *gin.Context) {
+	var patch map[string]any
+	if err := c.ShouldBindJSON(&patch); err != nil || len(patch) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_patch", "message": "body must be a non-empty JSON object"})
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	current, err := yaml.Marshal(h.cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encode_failed", "message": err.Error()})
+		return
+	}
+	var doc map[string]any
+	if err = yaml.Unmarshal(current, &doc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encode_failed", "message": err.Error()})
+		return
+	}
+	merged, err := yaml.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_patch", "message": err.Error()})
+		return
+	}
+	newCfg, status, err := h.validateConfigCandidate(merged)
+	if err != nil {
+		code := "write_failed"
+		if status == http.StatusUnprocessableEntity {
+			code = "invalid_config"
+		}
+		c.JSON(status, gin.H{"error": code, "message": err.Error()})
+		return
+	}
+	if err = config.SaveConfigPreserveComments(h.configFilePath, newCfg); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": err.Error()})
+		return
+	}
+	h.cfg = newCfg
+
+	changed := make([]string, 0, len(patch))
+	for key := range patch {
+		changed = append(changed, key)
+	}
+	sort.Strings(changed)
+	c.JSON(http.StatusOK, gin.H{"ok": true, "changed": changed})
+}
+
+// mergePatch applies an RFC 7386 merge patch to target and returns the result.
+func mergePatch(target any, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = make(map[string]any, len(patchObj))
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
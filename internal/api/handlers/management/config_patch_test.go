@@ -0,0 +1,53 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestPatchConfigMergesAndPersists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	initial := "# listen port\nport: 8317\nrequest-retry: 1\napi-keys:\n  - \"k1\"\n"
+	if err := os.WriteFile(path, []byte(initial), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(cfg, path, nil)
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/v0/management/config", strings.NewReader(`{"request-retry":3,"api-keys":["k2"]}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h.PatchConfig(c)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if h.cfg.RequestRetry != 3 || h.cfg.Port != 8317 || len(h.cfg.APIKeys) != 1 || h.cfg.APIKeys[0] != "k2" {
+		t.Fatalf("unexpected config after patch: retry=%d port=%d keys=%v", h.cfg.RequestRetry, h.cfg.Port, h.cfg.APIKeys)
+	}
+	saved, _ := os.ReadFile(path)
+	if !strings.Contains(string(saved), "# listen port") || !strings.Contains(string(saved), "request-retry: 3") {
+		t.Fatalf("config not persisted with comments:\n%s", saved)
+	}
+
+	rec = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/v0/management/config", strings.NewReader(`{"port":"not-a-number"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h.PatchConfig(c)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid patch status = %d, body %s", rec.Code, rec.Body.String())
+	}
+}
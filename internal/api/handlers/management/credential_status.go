@@ -0,0 +1,137 @@
+package management
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+type credentialModelStatus struct {
+	Model          string              `json:"model"`
+	Status         coreauth.Status     `json:"status"`
+	StatusMessage  string              `json:"status_message,omitempty"`
+	NextRetryAfter time.Time           `json:"next_retry_after,omitzero"`
+	Quota          coreauth.QuotaState `json:"quota"`
+}
+
+type credentialStatus struct {
+	ID             string                     `json:"id"`
+	AuthIndex      string                     `json:"auth_index,omitempty"`
+	Provider       string                     `json:"provider"`
+	Label          string                     `json:"label,omitempty"`
+	Status         coreauth.Status            `json:"status"`
+	StatusMessage  string                     `json:"status_message,omitempty"`
+	Disabled       bool                       `json:"disabled"`
+	Unavailable    bool                       `json:"unavailable"`
+	NextRetryAfter time.Time                  `json:"next_retry_after,omitzero"`
+	Quota          coreauth.QuotaState        `json:"quota"`
+	LastError      *coreauth.Error            `json:"last_error,omitempty"`
+	Health         *coreauth.CredentialHealth `json:"health,omitempty"`
+	BlockedModels  []credentialModelStatus    `json:"blocked_models,omitempty"`
+}
+
+type providerStatusSummary struct {
+	Total       int `json:"total"`
+	Available   int `json:"available"`
+	Unavailable int `json:"unavailable"`
+	Disabled    int `json:"disabled"`
+	Unhealthy   int `json:"unhealthy"`
+}
+
+// GetCredentialStatus reports the runtime health of every registered credential, including
+// cooldowns and per-model blocks, with a per-provider summary.
+func (h *Handler) GetCredentialStatus(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	now := time.Now()
+	auths := h.authManager.List()
+	credentials := make([]credentialStatus, 0, len(auths))
+	summary := make(map[string]*providerStatusSummary)
+	for _, auth := range auths {
+		if auth == nil {
+			continue
+		}
+		entry := credentialStatus{
+			ID:            auth.ID,
+			AuthIndex:     auth.Index,
+			Provider:      auth.Provider,
+			Label:         auth.Label,
+			Status:        auth.Status,
+			StatusMessage: auth.StatusMessage,
+			Disabled:      auth.Disabled,
+			Unavailable:   auth.Unavailable && auth.NextRetryAfter.After(now),
+			Quota:         auth.Quota,
+			LastError:     auth.LastError,
+		}
+		if auth.NextRetryAfter.After(now) {
+			entry.NextRetryAfter = auth.NextRetryAfter
+		}
+		if auth.Health.ConsecutiveFailures > 0 || auth.Health.Unhealthy {
+			health := auth.Health
+			entry.Health = &health
+		}
+		for model, state := range auth.ModelStates {
+			if state == nil || !state.Unavailable || !state.NextRetryAfter.After(now) {
+				continue
+			}
+			entry.BlockedModels = append(entry.BlockedModels, credentialModelStatus{
+				Model:          model,
+				Status:         state.Status,
+				StatusMessage:  state.StatusMessage,
+				NextRetryAfter: state.NextRetryAfter,
+				Quota:          state.Quota,
+			})
+		}
+		sort.Slice(entry.BlockedModels, func(i, j int) bool { return entry.BlockedModels[i].Model < entry.BlockedModels[j].Model })
+		credentials = append(credentials, entry)
+
+		sum := summary[auth.Provider]
+		if sum == nil {
+			sum = &providerStatusSummary{}
+			summary[auth.Provider] = sum
+		}
+		sum.Total++
+		switch {
+		case entry.Disabled:
+			sum.Disabled++
+		case auth.Health.Unhealthy:
+			sum.Unhealthy++
+		case entry.Unavailable:
+			sum.Unavailable++
+		default:
+			sum.Available++
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		if credentials[i].Provider != credentials[j].Provider {
+			return credentials[i].Provider < credentials[j].Provider
+		}
+		return credentials[i].ID < credentials[j].ID
+	})
+	c.JSON(http.StatusOK, gin.H{"credentials": credentials, "providers": summary})
+}
+
+// ResetCredentialHealth returns a credential that was taken out of rotation for repeated
+// authentication or quota failures to rotation immediately.
+func (h *Handler) ResetCredentialHealth(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	id := strings.TrimSpace(c.Query("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		return
+	}
+	if !h.authManager.ResetCredentialHealth(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "credential not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "id": id})
+}
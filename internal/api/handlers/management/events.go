@@ -0,0 +1,65 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/events"
+)
+
+const (
+	eventStreamBuffer    = 256
+	eventStreamKeepAlive = 15 * time.Second
+)
+
+// StreamEvents streams live proxy events as server-sent events until the client disconnects.
+// Query parameters filter the stream: types (comma separated), key or key_id, model and upstream.
+func (h *Handler) StreamEvents(c *gin.Context) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming not supported"})
+		return
+	}
+	sub := events.NewChannelSubscriber(events.ParseFilter(c.Request.URL.Query()), eventStreamBuffer)
+	unsubscribe := events.Subscribe(sub)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	_, _ = fmt.Fprint(c.Writer, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	var reportedDrops int64
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			// Tell the client when events were dropped because it could not keep up.
+			if dropped := sub.Dropped(); dropped != reportedDrops {
+				_, _ = fmt.Fprintf(c.Writer, "event: dropped\ndata: {\"dropped\":%d}\n\n", dropped-reportedDrops)
+				reportedDrops = dropped
+			} else {
+				_, _ = fmt.Fprint(c.Writer, ": ping\n\n")
+			}
+			flusher.Flush()
+		case event := <-sub.Events():
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err = fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
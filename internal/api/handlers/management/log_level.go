@@ -0,0 +1,37 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetLogLevel reports the active log level.
+func (h *Handler) GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"log-level": log.GetLevel().String()})
+}
+
+// PutLogLevel changes the log level at runtime. The change is not persisted: a config reload or
+// a debug toggle restores the level derived from the "debug" setting.
+func (h *Handler) PutLogLevel(c *gin.Context) {
+	var body struct {
+		Value *string `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	level, err := log.ParseLevel(strings.TrimSpace(*body.Value))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid log level", "message": err.Error()})
+		return
+	}
+	previous := log.GetLevel()
+	log.SetLevel(level)
+	if previous != level {
+		log.Infof("log level changed from %s to %s via management API", previous, level)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "log-level": level.String()})
+}
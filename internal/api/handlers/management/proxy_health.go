@@ -0,0 +1,13 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+)
+
+// GetProxyHealth reports the latest health check result of each upstream proxy.
+func (h *Handler) GetProxyHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"proxies": executor.ProxyHealthSnapshot()})
+}
@@ -0,0 +1,37 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetUpstreamQueues reports in-flight and queued request counts for every concurrency-limited upstream.
+func (h *Handler) GetUpstreamQueues(c *gin.Context) {
+	queues := []coreauth.QueueStats{}
+	if h.authManager != nil {
+		queues = append(queues, h.authManager.QueueStats()...)
+	}
+	var inFlight, queued int
+	for _, q := range queues {
+		inFlight += q.InFlight
+		queued += q.Queued
+	}
+	c.JSON(http.StatusOK, gin.H{"queues": queues, "in_flight": inFlight, "queued": queued})
+}
+
+// GetUpstreamLatency reports rolling time-to-first-token percentiles and throughput per credential.
+func (h *Handler) GetUpstreamLatency(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"credentials": coreauth.LatencySnapshot()})
+}
+
+// GetHedgeStats reports how many streams were hedged and how often the second request won.
+func (h *Handler) GetHedgeStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"hedging": coreauth.GetHedgeStats()})
+}
+
+// GetQuotaWindows reports the rolling usage windows subscription upstreams returned per credential.
+func (h *Handler) GetQuotaWindows(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"credentials": coreauth.QuotaWindowSnapshot()})
+}
@@ -0,0 +1,18 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+)
+
+// GetRequestTrace returns the routing decision trace of a traced request.
+func (h *Handler) GetRequestTrace(c *gin.Context) {
+	t, ok := trace.DefaultStore().Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "trace not found"})
+		return
+	}
+	c.JSON(http.StatusOK, t.Snapshot())
+}
@@ -0,0 +1,29 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/streams"
+)
+
+// GetActiveStreams lists the streaming responses currently being proxied.
+func (h *Handler) GetActiveStreams(c *gin.Context) {
+	list := streams.GetRegistry().List()
+	c.JSON(http.StatusOK, gin.H{"streams": list, "count": len(list)})
+}
+
+// CancelStream aborts an active stream and its upstream request.
+func (h *Handler) CancelStream(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		return
+	}
+	if !streams.GetRegistry().Cancel(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stream not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "id": id})
+}
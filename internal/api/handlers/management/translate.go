@@ -0,0 +1,134 @@
+package management
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// providerFormats maps provider identifiers to the upstream schema their executors speak, so
+// the translate preview accepts either a provider name or a format name as its target.
+var providerFormats = map[string]sdktranslator.Format{
+	"aistudio":             sdktranslator.FormatGemini,
+	"vertex":               sdktranslator.FormatGemini,
+	"gemini-vertex":        sdktranslator.FormatGemini,
+	"qwen":                 sdktranslator.FormatOpenAI,
+	"iflow":                sdktranslator.FormatOpenAI,
+	"openai-compatibility": sdktranslator.FormatOpenAI,
+}
+
+func translateFormat(name string) sdktranslator.Format {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if format, ok := providerFormats[name]; ok {
+		return format
+	}
+	return sdktranslator.FromString(name)
+}
+
+// translatePayload returns raw as JSON when it parses and as a string otherwise.
+func translatePayload(raw []byte) any {
+	raw = bytes.TrimSpace(raw)
+	if json.Valid(raw) {
+		return json.RawMessage(raw)
+	}
+	return string(raw)
+}
+
+// PreviewTranslation runs the registered translators on a client request, or on an upstream
+// response, without contacting any upstream. "from" is the client schema and "to" the target
+// provider or upstream schema for both directions.
+func (h *Handler) PreviewTranslation(c *gin.Context) {
+	var body struct {
+		Direction string          `json:"direction"`
+		From      string          `json:"from"`
+		To        string          `json:"to"`
+		Model     string          `json:"model"`
+		Stream    bool            `json:"stream"`
+		Request   json.RawMessage `json:"request"`
+		Response  json.RawMessage `json:"response"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	from, to := translateFormat(body.From), translateFormat(body.To)
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required"})
+		return
+	}
+	if len(body.Request) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request is required"})
+		return
+	}
+	model := strings.TrimSpace(body.Model)
+	if model == "" {
+		model = gjson.GetBytes(body.Request, "model").String()
+	}
+	stream := body.Stream || gjson.GetBytes(body.Request, "stream").Bool()
+	translated := sdktranslator.TranslateRequest(from, to, model, bytes.Clone(body.Request), stream)
+
+	result := gin.H{
+		"from":   from,
+		"to":     to,
+		"model":  model,
+		"stream": stream,
+	}
+	if route := sdktranslator.Route(from, to); len(route) > 2 {
+		result["route"] = route
+	}
+	switch strings.ToLower(strings.TrimSpace(body.Direction)) {
+	case "", "request":
+		result["direction"] = "request"
+		result["translator"] = sdktranslator.HasRequestTransformer(from, to)
+		result["translated"] = translatePayload(translated)
+	case "response":
+		if len(body.Response) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "response is required"})
+			return
+		}
+		result["direction"] = "response"
+		result["translator"] = sdktranslator.HasResponseTransformer(to, from)
+		ctx := c.Request.Context()
+		var param any
+		// Upstream bodies that are not a single JSON document (Claude answers are read as
+		// event text even for non-stream requests) are given as a JSON string.
+		raw := []byte(body.Response)
+		var text string
+		if err := json.Unmarshal(body.Response, &text); err == nil {
+			raw = []byte(text)
+		}
+		if !stream {
+			out := sdktranslator.TranslateNonStream(ctx, to, from, model, body.Request, translated, raw, &param)
+			result["translated"] = translatePayload([]byte(out))
+			break
+		}
+		// A streamed response is given as the raw upstream event text, either as a JSON
+		// string or as an array of lines.
+		var lines []string
+		if text != "" {
+			scanner := bufio.NewScanner(strings.NewReader(text))
+			scanner.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+		} else if err := json.Unmarshal(body.Response, &lines); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "stream response must be a string or an array of lines"})
+			return
+		}
+		chunks := make([]string, 0, len(lines))
+		for _, line := range lines {
+			chunks = append(chunks, sdktranslator.TranslateStream(ctx, to, from, model, body.Request, translated, []byte(line), &param)...)
+		}
+		result["translated"] = chunks
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "direction must be request or response"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,65 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+)
+
+func previewTranslation(t *testing.T, body string) (int, []byte) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/v0/translate", (&Handler{}).PreviewTranslation)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v0/translate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.Bytes()
+}
+
+func TestPreviewTranslationRequest(t *testing.T) {
+	code, out := previewTranslation(t, `{"from":"openai","to":"claude","request":{"model":"claude-sonnet-4","max_tokens":64,"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}}`)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", code, out)
+	}
+	result := gjson.ParseBytes(out)
+	if !result.Get("translator").Bool() || result.Get("model").String() != "claude-sonnet-4" {
+		t.Fatalf("unexpected result %s", out)
+	}
+	if result.Get("translated.system.0.text").String() != "be brief" || result.Get("translated.messages.0.content.0.text").String() != "hi" {
+		t.Fatalf("request not translated to claude: %s", out)
+	}
+}
+
+func TestPreviewTranslationResponse(t *testing.T) {
+	code, out := previewTranslation(t, `{"direction":"response","from":"openai","to":"aistudio","request":{"model":"gemini-2.5-pro","messages":[{"role":"user","content":"hi"}]},"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"hello"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":1,"totalTokenCount":4}}}`)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", code, out)
+	}
+	if got := gjson.GetBytes(out, "translated.choices.0.message.content").String(); got != "hello" {
+		t.Fatalf("content = %q, body %s", got, out)
+	}
+}
+
+func TestPreviewTranslationRejectsMissingFormats(t *testing.T) {
+	if code, _ := previewTranslation(t, `{"request":{}}`); code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", code)
+	}
+}
+
+func TestPreviewTranslationStreamResponse(t *testing.T) {
+	code, out := previewTranslation(t, `{"direction":"response","from":"openai","to":"claude","stream":true,"request":{"model":"claude-sonnet-4","messages":[{"role":"user","content":"hi"}]},"response":"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude-sonnet-4\"}}\n\nevent: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n"}`)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", code, out)
+	}
+	if !strings.Contains(gjson.GetBytes(out, "translated").Raw, "hello") {
+		t.Fatalf("stream chunks missing text: %s", out)
+	}
+}
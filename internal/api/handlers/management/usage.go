@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	openaiclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/claude"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 )
 
@@ -32,6 +33,17 @@ func (h *Handler) GetUsageStatistics(c *gin.Context) {
 	})
 }
 
+// GetToolIDMappingStats reports size and hit/miss counters of the Claude tool_use ID mapping
+// used when proxying Claude clients to OpenAI-compatible upstreams.
+func (h *Handler) GetToolIDMappingStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"tool-id-mapping": openaiclaude.GetToolIDMappingStats()})
+}
+
+// GetStreamDedupeStats reports how often streamed frames were deduplicated per upstream and stream mode.
+func (h *Handler) GetStreamDedupeStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"stream-dedupe": openaiclaude.GetStreamDedupeStats()})
+}
+
 // ExportUsageStatistics returns a complete usage snapshot for backup/migration.
 func (h *Handler) ExportUsageStatistics(c *gin.Context) {
 	var snapshot usage.StatisticsSnapshot
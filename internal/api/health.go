@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// healthSummary aggregates the latest upstream probe results.
+type healthSummary struct {
+	Status    string `json:"status"`
+	Probing   bool   `json:"probing"`
+	Rounds    int    `json:"rounds"`
+	Healthy   int    `json:"healthy"`
+	Unhealthy int    `json:"unhealthy"`
+	Ready     bool   `json:"ready"`
+}
+
+// upstreamHealth returns the probe results and their summary. Without health probes the server
+// reports ok and ready as soon as it serves requests.
+func (s *Server) upstreamHealth() ([]coreauth.UpstreamHealth, healthSummary) {
+	summary := healthSummary{Status: "ok", Ready: true}
+	var manager *coreauth.Manager
+	if s.handlers != nil {
+		manager = s.handlers.AuthManager
+	}
+	if !manager.HealthProbesRunning() {
+		return nil, summary
+	}
+	results, rounds := manager.UpstreamHealth()
+	summary.Probing = true
+	summary.Rounds = rounds
+	for _, status := range results {
+		if status.Healthy {
+			summary.Healthy++
+		} else {
+			summary.Unhealthy++
+		}
+	}
+	minHealthy := 1
+	if s.cfg != nil && s.cfg.HealthCheck.MinHealthy > 0 {
+		minHealthy = s.cfg.HealthCheck.MinHealthy
+	}
+	summary.Ready = rounds > 0 && summary.Healthy >= minHealthy
+	switch {
+	case rounds == 0:
+		summary.Status = "starting"
+	case summary.Healthy < minHealthy:
+		summary.Status = "unhealthy"
+	case summary.Unhealthy > 0:
+		summary.Status = "degraded"
+	}
+	return results, summary
+}
+
+// handleHealth reports aggregate upstream health. It always answers 200 so it can serve as a
+// liveness probe; use /ready to gate traffic on upstream availability.
+func (s *Server) handleHealth(c *gin.Context) {
+	_, summary := s.upstreamHealth()
+	c.JSON(http.StatusOK, summary)
+}
+
+// handleReady answers 200 when enough upstream credentials passed their last probe and 503
+// otherwise, for use as a readiness probe.
+func (s *Server) handleReady(c *gin.Context) {
+	_, summary := s.upstreamHealth()
+	code := http.StatusOK
+	if !summary.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, summary)
+}
+
+// handleUpstreamHealth lists the last probe result of every credential the caller's tenant may
+// use. The summary still covers all credentials.
+func (s *Server) handleUpstreamHealth(c *gin.Context) {
+	results, summary := s.upstreamHealth()
+	visible := make([]coreauth.UpstreamHealth, 0, len(results))
+	tenant := c.GetString("tenant")
+	for _, status := range results {
+		auth, ok := s.handlers.AuthManager.GetByID(status.AuthID)
+		if ok && coreauth.TenantAllows(tenant, auth) {
+			visible = append(visible, status)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"summary": summary, "upstreams": visible})
+}
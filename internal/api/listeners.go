@@ -0,0 +1,172 @@
+package api
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Listener scopes select which routes a listener exposes.
+const (
+	serveAll        = "all"
+	serveAPI        = "api"
+	serveManagement = "management"
+	serveNone       = "none"
+)
+
+// managementPathPrefixes are the routes belonging to the management scope.
+var managementPathPrefixes = []string{"/v0/management", "/v0/translate", "/management.html", "/admin"}
+
+func isManagementPath(path string) bool {
+	for _, prefix := range managementPathPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeServe(serve string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(serve)); v {
+	case "":
+		return serveAll, nil
+	case serveAll, serveAPI, serveManagement, serveNone:
+		return v, nil
+	default:
+		return "", fmt.Errorf("unsupported serve scope %q", serve)
+	}
+}
+
+// scopeHandler hides the routes outside serve behind a 404.
+func scopeHandler(next http.Handler, serve string) http.Handler {
+	if serve == serveAll {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isManagementPath(r.URL.Path) != (serve == serveManagement) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unixLocalHandler marks unix socket peers as loopback clients, so local-only checks such as
+// management access treat them like connections from 127.0.0.1.
+func unixLocalHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.RemoteAddr = "127.0.0.1:0"
+		next.ServeHTTP(w, r)
+	})
+}
+
+// openListener creates the network listener for l. Stale unix socket files are replaced.
+func openListener(l config.ListenerConfig) (net.Listener, error) {
+	network := strings.ToLower(strings.TrimSpace(l.Network))
+	address := strings.TrimSpace(l.Address)
+	if address == "" {
+		return nil, fmt.Errorf("listener address is empty")
+	}
+	switch network {
+	case "", "tcp":
+		return net.Listen("tcp", address)
+	case "unix":
+		if info, err := os.Lstat(address); err == nil {
+			if info.Mode()&fs.ModeSocket == 0 {
+				return nil, fmt.Errorf("%s exists and is not a socket", address)
+			}
+			if conn, errDial := net.DialTimeout("unix", address, time.Second); errDial == nil {
+				_ = conn.Close()
+				return nil, fmt.Errorf("socket %s is in use", address)
+			}
+			_ = os.Remove(address)
+		}
+		ln, err := net.Listen("unix", address)
+		if err != nil {
+			return nil, err
+		}
+		mode := os.FileMode(0o600)
+		if raw := strings.TrimSpace(l.SocketMode); raw != "" {
+			parsed, errMode := strconv.ParseUint(raw, 8, 32)
+			if errMode != nil {
+				_ = ln.Close()
+				return nil, fmt.Errorf("invalid socket-mode %q", raw)
+			}
+			mode = os.FileMode(parsed)
+		}
+		if err = os.Chmod(address, mode); err != nil {
+			_ = ln.Close()
+			return nil, fmt.Errorf("chmod socket: %w", err)
+		}
+		return ln, nil
+	default:
+		return nil, fmt.Errorf("unsupported listener network %q", l.Network)
+	}
+}
+
+// startExtraListeners serves handler on every configured additional listener. tlsConfig, when
+// non-nil, carries the certificates used by listeners with tls enabled.
+func (s *Server) startExtraListeners(handler http.Handler, tlsConfig *tls.Config) error {
+	for _, l := range s.cfg.Listeners {
+		serve, err := normalizeServe(l.Serve)
+		if err != nil || serve == serveNone {
+			return fmt.Errorf("listener %s: unsupported serve scope %q", l.Address, l.Serve)
+		}
+		ln, err := openListener(l)
+		if err != nil {
+			return fmt.Errorf("listener %s: %w", l.Address, err)
+		}
+		scoped := scopeHandler(handler, serve)
+		isUnix := strings.EqualFold(strings.TrimSpace(l.Network), "unix")
+		if isUnix {
+			scoped = unixLocalHandler(scoped)
+		} else if l.TLS {
+			if tlsConfig == nil {
+				_ = ln.Close()
+				return fmt.Errorf("listener %s: tls requested but tls is not configured", l.Address)
+			}
+			ln = tls.NewListener(ln, tlsConfig)
+		}
+		srv := &http.Server{Handler: scoped, ReadHeaderTimeout: s.server.ReadHeaderTimeout}
+		s.extraServers = append(s.extraServers, srv)
+		log.Infof("listening on %s %s (serve: %s)", ln.Addr().Network(), ln.Addr().String(), serve)
+		go func(srv *http.Server, ln net.Listener) {
+			if errServe := srv.Serve(ln); errServe != nil && !errors.Is(errServe, http.ErrServerClosed) {
+				log.Errorf("listener %s stopped: %v", ln.Addr(), errServe)
+			}
+		}(srv, ln)
+	}
+	return nil
+}
+
+// listenerTLSConfig returns a TLS configuration carrying the listener certificates, for use with
+// listeners that are not started through ListenAndServeTLS.
+func listenerTLSConfig(base *tls.Config, certFile, keyFile string) (*tls.Config, error) {
+	var cfg *tls.Config
+	if base != nil {
+		cfg = base.Clone()
+	} else {
+		cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if len(cfg.NextProtos) == 0 {
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestUnixListenerServesScopedRoutes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket permissions are not meaningful on windows")
+	}
+	socket := filepath.Join(t.TempDir(), "proxy.sock")
+	ln, err := openListener(config.ListenerConfig{Network: "unix", Address: socket, SocketMode: "0660"})
+	if err != nil {
+		t.Fatalf("open listener: %v", err)
+	}
+	info, err := os.Stat(socket)
+	if err != nil || info.Mode().Perm() != 0o660 {
+		t.Fatalf("unexpected socket mode: %v, %v", info.Mode(), err)
+	}
+	if _, err = openListener(config.ListenerConfig{Network: "unix", Address: socket}); err == nil {
+		t.Fatalf("expected error for a socket in use")
+	}
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.RemoteAddr)
+	})
+	srv := &http.Server{Handler: unixLocalHandler(scopeHandler(inner, serveAPI))}
+	go func() { _ = srv.Serve(ln) }()
+	defer func() { _ = srv.Shutdown(context.Background()) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://unix/v1/models")
+	if err != nil {
+		t.Fatalf("api request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "127.0.0.1:0" {
+		t.Fatalf("unexpected api response %d %q", resp.StatusCode, body)
+	}
+	resp, err = client.Get("http://unix/v0/management/config")
+	if err != nil {
+		t.Fatalf("management request: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("management route must be hidden on an api listener, got %d", resp.StatusCode)
+	}
+}
+
+func TestScopeHandlerManagementOnly(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	h := scopeHandler(ok, serveManagement)
+	for path, want := range map[string]int{
+		"/v0/management/usage": http.StatusNoContent,
+		"/admin/ui":            http.StatusNoContent,
+		"/v1/chat/completions": http.StatusNotFound,
+		"/v0/managementx":      http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Fatalf("%s: got %d, want %d", path, rec.Code, want)
+		}
+	}
+	if _, err := normalizeServe("public"); err == nil {
+		t.Fatalf("expected error for unknown scope")
+	}
+}
+
+func TestScopeHandlerKeepsManagementRoutesOffAPIListeners(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	api := scopeHandler(ok, serveAPI)
+	management := scopeHandler(ok, serveManagement)
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/admin/events"},
+		{http.MethodGet, "/admin/requests/req-1"},
+		{http.MethodGet, "/admin/streams"},
+		{http.MethodDelete, "/admin/streams/stream-1"},
+		{http.MethodPost, "/v0/translate"},
+	} {
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(route.method, route.path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s %s must be hidden on an api listener, got %d", route.method, route.path, rec.Code)
+		}
+		rec = httptest.NewRecorder()
+		management.ServeHTTP(rec, httptest.NewRequest(route.method, route.path, nil))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("%s %s must be served on a management listener, got %d", route.method, route.path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mcp"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+)
+
+// newMCPServer builds the embedded MCP server. Model calls go through the OpenAI handler so
+// they are routed, logged and accounted like regular chat completions.
+func (s *Server) newMCPServer(openaiHandlers *openai.OpenAIAPIHandler) *mcp.Server {
+	return &mcp.Server{
+		Name:    "cliproxy",
+		Version: buildinfo.Version,
+		Ask: func(ctx context.Context, model string, request []byte) ([]byte, error) {
+			resp, errMsg := openaiHandlers.ExecuteWithAuthManager(ctx, openaiHandlers.HandlerType(), model, request, "")
+			if errMsg != nil {
+				return nil, errMsg.Error
+			}
+			return resp, nil
+		},
+		Models: func() []string {
+			models := registry.GetGlobalRegistry().GetAvailableModels(openaiHandlers.HandlerType())
+			ids := make([]string, 0, len(models))
+			for _, model := range models {
+				if id, _ := model["id"].(string); id != "" {
+					ids = append(ids, id)
+				}
+			}
+			sort.Strings(ids)
+			return ids
+		},
+		Allowed: func(model string) bool {
+			patterns := s.cfg.MCPServer.Models
+			if len(patterns) == 0 {
+				return true
+			}
+			for _, pattern := range patterns {
+				if util.MatchModelWildcard(strings.TrimSpace(pattern), model) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// handleMCP serves the MCP endpoint while mcp-server.enable is set. The route is always
+// mounted so the setting can be toggled by a config reload.
+func (s *Server) handleMCP(server *mcp.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.cfg == nil || !s.cfg.MCPServer.Enable {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		requestServer := *server
+		requestServer.DefaultModel = s.cfg.MCPServer.DefaultModel
+		requestServer.Handle(c)
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// auditModelPeekLimit bounds how much of a request body is buffered to read the model name.
+const auditModelPeekLimit = 8 << 20
+
+// AuditMiddleware records one audit entry per request once the handler has finished, including
+// the upstream selected and the tokens consumed. It is a no-op while audit logging is disabled.
+func AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger, includeManagement := audit.Default()
+		if logger == nil {
+			c.Next()
+			return
+		}
+		path := c.Request.URL.Path
+		if !includeManagement && strings.HasPrefix(path, "/v0/management") {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		model := peekModel(c)
+		tally := &coreusage.Tally{}
+		c.Request = c.Request.WithContext(coreusage.WithTally(c.Request.Context(), tally))
+
+		c.Next()
+
+		attempts, _, last, detail := tally.Snapshot()
+		if model == "" {
+			model = last.Model
+		}
+		status := c.Writer.Status()
+		entry := audit.Entry{
+			Time:         start.UTC(),
+			RequestID:    logging.GetGinRequestID(c),
+			ClientIP:     c.ClientIP(),
+			Method:       c.Request.Method,
+			Path:         path,
+			Model:        model,
+			Upstream:     last.Provider,
+			AuthID:       last.AuthID,
+			Attempts:     attempts,
+			Status:       status,
+			Decision:     auditDecision(c, status),
+			InputTokens:  detail.InputTokens,
+			OutputTokens: detail.OutputTokens,
+			TotalTokens:  detail.TotalTokens,
+			DurationMs:   time.Since(start).Milliseconds(),
+		}
+		if v, ok := c.Get("apiKey"); ok {
+			if principal, okStr := v.(string); okStr && principal != "" {
+				entry.Principal = util.HideAPIKey(principal)
+				sum := sha256.Sum256([]byte(principal))
+				entry.KeyID = hex.EncodeToString(sum[:8])
+			}
+		}
+		if v, ok := c.Get("accessProvider"); ok {
+			entry.AccessSource, _ = v.(string)
+		}
+		if err := logger.Record(entry); err != nil {
+			log.Errorf("%v", err)
+		}
+	}
+}
+
+func auditDecision(c *gin.Context, status int) string {
+	if _, blocked := c.Get("guardrailBlocked"); blocked {
+		return audit.DecisionBlocked
+	}
+	switch {
+	case status < http.StatusBadRequest:
+		return audit.DecisionAllowed
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return audit.DecisionDenied
+	case status == http.StatusTooManyRequests:
+		return audit.DecisionThrottled
+	}
+	return audit.DecisionError
+}
+
+// peekModel reads the "model" field of a JSON request body and restores the body for the handler.
+func peekModel(c *gin.Context) string {
+	req := c.Request
+	if req.Body == nil || req.Method == http.MethodGet || !strings.Contains(req.Header.Get("Content-Type"), "json") {
+		return ""
+	}
+	if req.ContentLength > auditModelPeekLimit {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(req.Body, auditModelPeekLimit+1))
+	req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), req.Body))
+	if err != nil || len(data) > auditModelPeekLimit {
+		return ""
+	}
+	return gjson.GetBytes(data, "model").String()
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// DefaultMultipartMemoryBytes is how much of a multipart upload is held in memory when
+// body-limits.multipart-memory-bytes is not set.
+const DefaultMultipartMemoryBytes = 8 << 20
+
+// BodyLimit enforces the body-limits configuration. Update swaps the configuration without
+// restarting.
+type BodyLimit struct {
+	cfg atomic.Pointer[config.BodyLimitsConfig]
+}
+
+// NewBodyLimit builds the middleware state for cfg.
+func NewBodyLimit(cfg config.BodyLimitsConfig) *BodyLimit {
+	b := &BodyLimit{}
+	b.Update(cfg)
+	return b
+}
+
+// Update applies a new configuration.
+func (b *BodyLimit) Update(cfg config.BodyLimitsConfig) {
+	b.cfg.Store(&cfg)
+}
+
+// MultipartMemory returns the in-memory budget for multipart uploads.
+func (b *BodyLimit) MultipartMemory() int64 {
+	if cfg := b.cfg.Load(); cfg != nil && cfg.MultipartMemoryBytes > 0 {
+		return cfg.MultipartMemoryBytes
+	}
+	return DefaultMultipartMemoryBytes
+}
+
+// limitFor returns the body limit for path; 0 means unlimited.
+func (b *BodyLimit) limitFor(path string) int64 {
+	cfg := b.cfg.Load()
+	if cfg == nil {
+		return 0
+	}
+	for _, rule := range cfg.Routes {
+		if routeMatches(rule.Routes, path) {
+			return rule.MaxBytes
+		}
+	}
+	return cfg.MaxBytes
+}
+
+// Handler returns the gin middleware. Requests that declare a larger Content-Length are
+// rejected before any of the body is read. Other bodies are cut off once they cross the
+// limit: the 413 is written at that moment and whatever the handler writes afterwards is
+// discarded.
+func (b *BodyLimit) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := b.limitFor(c.Request.URL.Path)
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			abortBodyTooLarge(c, limit)
+			return
+		}
+		writer := &bodyLimitWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Request.Body = &bodyLimitReader{body: c.Request.Body, remaining: limit, limit: limit, c: c, writer: writer}
+		c.Next()
+	}
+}
+
+func abortBodyTooLarge(c *gin.Context, limit int64) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error": gin.H{
+			"message": fmt.Sprintf("Request body exceeds the maximum size of %d bytes", limit),
+			"type":    "invalid_request_error",
+			"code":    "request_too_large",
+		},
+	})
+}
+
+// bodyLimitReader fails reads past the limit and answers the request with 413 when that
+// happens.
+type bodyLimitReader struct {
+	body      io.ReadCloser
+	remaining int64
+	limit     int64
+	c         *gin.Context
+	writer    *bodyLimitWriter
+}
+
+func (r *bodyLimitReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, &http.MaxBytesError{Limit: r.limit}
+	}
+	// Read one byte past the limit to tell an exact fit from an overflow.
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.body.Read(p)
+	if int64(n) <= r.remaining {
+		r.remaining -= int64(n)
+		return n, err
+	}
+	n = int(r.remaining)
+	r.remaining = -1
+	if !r.writer.Written() {
+		abortBodyTooLarge(r.c, r.limit)
+	}
+	r.writer.closed = true
+	return n, &http.MaxBytesError{Limit: r.limit}
+}
+
+func (r *bodyLimitReader) Close() error {
+	return r.body.Close()
+}
+
+// bodyLimitWriter drops the handler's response once the 413 has been sent.
+type bodyLimitWriter struct {
+	gin.ResponseWriter
+	closed bool
+}
+
+func (w *bodyLimitWriter) WriteHeader(code int) {
+	if !w.closed {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *bodyLimitWriter) Write(data []byte) (int, error) {
+	if w.closed {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyLimitWriter) WriteString(s string) (int, error) {
+	if w.closed {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
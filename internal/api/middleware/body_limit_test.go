@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func newBodyLimitEngine(limit *BodyLimit) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(limit.Handler())
+	engine.POST("/*path", func(c *gin.Context) {
+		data, err := c.GetRawData()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.String(http.StatusOK, fmt.Sprintf("read %d", len(data)))
+	})
+	return engine
+}
+
+func doBodyRequest(engine *gin.Engine, path, body string, chunked bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if chunked {
+		// Hide the length so the limit is enforced while reading.
+		req.ContentLength = -1
+		req.Body = io.NopCloser(strings.NewReader(body))
+	}
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBodyLimitRejectsDeclaredLength(t *testing.T) {
+	engine := newBodyLimitEngine(NewBodyLimit(config.BodyLimitsConfig{MaxBytes: 10}))
+	rec := doBodyRequest(engine, "/v1/chat/completions", strings.Repeat("x", 11), false)
+	if rec.Code != http.StatusRequestEntityTooLarge || gjson.Get(rec.Body.String(), "error.code").String() != "request_too_large" {
+		t.Fatalf("status = %d body = %s", rec.Code, rec.Body.String())
+	}
+	if rec = doBodyRequest(engine, "/v1/chat/completions", strings.Repeat("x", 10), false); rec.Code != http.StatusOK {
+		t.Fatalf("body at the limit rejected: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBodyLimitRejectsUndeclaredLength(t *testing.T) {
+	engine := newBodyLimitEngine(NewBodyLimit(config.BodyLimitsConfig{MaxBytes: 10}))
+	rec := doBodyRequest(engine, "/v1/chat/completions", strings.Repeat("x", 100), true)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d body = %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), `"error":"http`) {
+		t.Fatalf("handler response leaked after 413: %s", rec.Body.String())
+	}
+	if rec = doBodyRequest(engine, "/v1/chat/completions", strings.Repeat("x", 10), true); rec.Code != http.StatusOK || rec.Body.String() != "read 10" {
+		t.Fatalf("body at the limit rejected: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBodyLimitRouteRules(t *testing.T) {
+	limit := NewBodyLimit(config.BodyLimitsConfig{
+		MaxBytes: 10,
+		Routes:   []config.BodyLimitRule{{Routes: []string{"/v1/audio/*"}, MaxBytes: 100}, {Routes: []string{"/v1/files"}, MaxBytes: 0}},
+	})
+	engine := newBodyLimitEngine(limit)
+	if rec := doBodyRequest(engine, "/v1/audio/transcriptions", strings.Repeat("x", 50), false); rec.Code != http.StatusOK {
+		t.Fatalf("route limit not applied: %d", rec.Code)
+	}
+	if rec := doBodyRequest(engine, "/v1/files", strings.Repeat("x", 500), false); rec.Code != http.StatusOK {
+		t.Fatalf("unlimited route rejected: %d", rec.Code)
+	}
+	if rec := doBodyRequest(engine, "/v1/messages", strings.Repeat("x", 50), false); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("global limit not applied: %d", rec.Code)
+	}
+}
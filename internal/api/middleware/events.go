@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/events"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// requestCompletedEvent is the data of a request.completed event.
+type requestCompletedEvent struct {
+	RequestID    string `json:"request_id,omitempty"`
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	Status       int    `json:"status"`
+	Attempts     int    `json:"attempts,omitempty"`
+	Tenant       string `json:"tenant,omitempty"`
+	InputTokens  int64  `json:"input_tokens,omitempty"`
+	OutputTokens int64  `json:"output_tokens,omitempty"`
+	DurationMs   int64  `json:"duration_ms"`
+}
+
+// EventsMiddleware publishes a request.completed event for every proxied request once the
+// handler has finished. It is a no-op while nobody subscribes to the event.
+func EventsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !events.Wanted(events.TypeRequestCompleted) || strings.HasPrefix(path, "/v0/management") || strings.HasPrefix(path, "/admin/") {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		model := peekModel(c)
+		tally := coreusage.TallyFromContext(c.Request.Context())
+		if tally == nil {
+			tally = &coreusage.Tally{}
+			c.Request = c.Request.WithContext(coreusage.WithTally(c.Request.Context(), tally))
+		}
+
+		c.Next()
+
+		attempts, _, last, detail := tally.Snapshot()
+		if model == "" {
+			model = last.Model
+		}
+		data := requestCompletedEvent{
+			RequestID:    logging.GetGinRequestID(c),
+			Method:       c.Request.Method,
+			Path:         path,
+			Status:       c.Writer.Status(),
+			Attempts:     attempts,
+			Tenant:       c.GetString("tenant"),
+			InputTokens:  detail.InputTokens,
+			OutputTokens: detail.OutputTokens,
+			DurationMs:   time.Since(start).Milliseconds(),
+		}
+		event := events.Event{
+			Type:     events.TypeRequestCompleted,
+			Summary:  fmt.Sprintf("%s %s returned %d in %dms", data.Method, path, data.Status, data.DurationMs),
+			Model:    model,
+			Upstream: last.Provider,
+			AuthID:   last.AuthID,
+			Data:     data,
+		}
+		if key := c.GetString("apiKey"); key != "" {
+			event.KeyID, event.APIKey = events.KeyID(key), util.HideAPIKey(key)
+		}
+		events.Publish(event)
+	}
+}
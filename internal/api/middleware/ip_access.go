@@ -0,0 +1,276 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// ipLimiterSweepInterval controls how often idle rate-limit buckets are discarded.
+const ipLimiterSweepInterval = time.Minute
+
+// IPAccess enforces the ip-access configuration: CIDR allow/deny lists and per-address rate
+// limits, globally and per route. Update swaps the configuration without restarting.
+type IPAccess struct {
+	signature string
+	state     atomic.Pointer[ipAccessState]
+	mu        sync.Mutex
+}
+
+type ipAccessState struct {
+	trusted []netip.Prefix
+	global  *ipPolicy
+	rules   []ipAccessRule
+}
+
+type ipAccessRule struct {
+	routes []string
+	policy *ipPolicy
+}
+
+type ipPolicy struct {
+	allow   []netip.Prefix
+	deny    []netip.Prefix
+	limiter *ipLimiter
+}
+
+// NewIPAccess builds the middleware state for cfg.
+func NewIPAccess(cfg config.IPAccessConfig) *IPAccess {
+	a := &IPAccess{}
+	a.Update(cfg)
+	return a
+}
+
+// Update applies a new configuration. Rate-limit state is kept when the configuration is unchanged.
+func (a *IPAccess) Update(cfg config.IPAccessConfig) {
+	raw, _ := json.Marshal(cfg)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.signature == string(raw) && a.state.Load() != nil {
+		return
+	}
+	a.signature = string(raw)
+	state := &ipAccessState{
+		trusted: parsePrefixes("trusted-proxies", cfg.TrustedProxies),
+		global:  newIPPolicy("", cfg.Allow, cfg.Deny, cfg.RateLimit),
+	}
+	for i, rule := range cfg.Rules {
+		label := fmt.Sprintf("rules[%d].", i)
+		state.rules = append(state.rules, ipAccessRule{
+			routes: rule.Routes,
+			policy: newIPPolicy(label, rule.Allow, rule.Deny, rule.RateLimit),
+		})
+	}
+	a.state.Store(state)
+}
+
+func newIPPolicy(label string, allow, deny []string, limit config.IPRateLimit) *ipPolicy {
+	p := &ipPolicy{
+		allow: parsePrefixes(label+"allow", allow),
+		deny:  parsePrefixes(label+"deny", deny),
+	}
+	if limit.RequestsPerMinute > 0 {
+		burst := limit.Burst
+		if burst <= 0 {
+			burst = limit.RequestsPerMinute
+		}
+		p.limiter = newIPLimiter(float64(limit.RequestsPerMinute)/60, float64(burst))
+	}
+	if len(p.allow) == 0 && len(p.deny) == 0 && p.limiter == nil {
+		return nil
+	}
+	return p
+}
+
+func parsePrefixes(label string, values []string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(v); err == nil {
+			out = append(out, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(v); err == nil {
+			addr = addr.Unmap()
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		log.Errorf("ip-access.%s: ignoring invalid address %q", label, v)
+	}
+	return out
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler returns the gin middleware.
+func (a *IPAccess) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := a.state.Load()
+		if state == nil || (state.global == nil && len(state.rules) == 0) {
+			c.Next()
+			return
+		}
+		addr, ok := state.clientAddr(c.Request)
+		if !ok {
+			abortIPAccess(c, http.StatusForbidden, "ip_not_allowed", "Client address could not be determined", 0)
+			return
+		}
+		if !state.global.check(c, addr) {
+			return
+		}
+		path := c.Request.URL.Path
+		for i := range state.rules {
+			rule := &state.rules[i]
+			if routeMatches(rule.routes, path) && !rule.policy.check(c, addr) {
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// check enforces p and aborts the request on violation. It reports whether the request may continue.
+func (p *ipPolicy) check(c *gin.Context, addr netip.Addr) bool {
+	if p == nil {
+		return true
+	}
+	if containsAddr(p.deny, addr) || (len(p.allow) > 0 && !containsAddr(p.allow, addr)) {
+		abortIPAccess(c, http.StatusForbidden, "ip_not_allowed", fmt.Sprintf("Client address %s is not allowed", addr), 0)
+		return false
+	}
+	if p.limiter != nil {
+		if wait := p.limiter.take(addr, time.Now()); wait > 0 {
+			abortIPAccess(c, http.StatusTooManyRequests, "ip_rate_limited", fmt.Sprintf("Too many requests from %s", addr), wait)
+			return false
+		}
+	}
+	return true
+}
+
+func abortIPAccess(c *gin.Context, status int, code, message string, retryAfter time.Duration) {
+	errType := "permission_error"
+	if status == http.StatusTooManyRequests {
+		errType = "rate_limit_error"
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": gin.H{
+		"message": message,
+		"type":    errType,
+		"code":    code,
+	}})
+}
+
+// clientAddr returns the connection peer address, or the first untrusted hop of
+// X-Forwarded-For when the peer is a trusted proxy.
+func (s *ipAccessState) clientAddr(r *http.Request) (netip.Addr, bool) {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	var addr netip.Addr
+	if err == nil {
+		addr = peer.Addr().Unmap()
+	} else if addr, err = netip.ParseAddr(r.RemoteAddr); err != nil {
+		return netip.Addr{}, false
+	} else {
+		addr = addr.Unmap()
+	}
+	if len(s.trusted) == 0 || !containsAddr(s.trusted, addr) {
+		return addr, true
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, errHop := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if errHop != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !containsAddr(s.trusted, addr) {
+			break
+		}
+	}
+	return addr, true
+}
+
+func routeMatches(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if pattern == "*" || pattern == path {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && !strings.Contains(prefix, "*") && strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipLimiter keeps one token bucket per client address.
+type ipLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	buckets   map[netip.Addr]*ipBucket
+	lastSweep time.Time
+}
+
+type ipBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newIPLimiter(rate, burst float64) *ipLimiter {
+	return &ipLimiter{rate: rate, burst: burst, buckets: make(map[netip.Addr]*ipBucket)}
+}
+
+// take consumes a token for addr and returns zero, or how long until a token is available.
+func (l *ipLimiter) take(addr netip.Addr, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= ipLimiterSweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[addr]
+	if !ok {
+		b = &ipBucket{tokens: l.burst, last: now}
+		l.buckets[addr] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely; they behave exactly like new ones.
+func (l *ipLimiter) sweep(now time.Time) {
+	l.lastSweep = now
+	for addr, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, addr)
+		}
+	}
+}
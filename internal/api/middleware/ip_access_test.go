@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func newIPAccessEngine(access *IPAccess) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(access.Handler())
+	engine.Any("/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return engine
+}
+
+func doIPRequest(engine *gin.Engine, path, remote, xff string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	req.RemoteAddr = remote
+	if xff != "" {
+		req.Header.Set("X-Forwarded-For", xff)
+	}
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIPAccessAllowDenyAndTrustedProxies(t *testing.T) {
+	access := NewIPAccess(config.IPAccessConfig{
+		Allow:          []string{"10.0.0.0/8", "192.0.2.7"},
+		Deny:           []string{"10.9.0.0/16"},
+		TrustedProxies: []string{"10.255.0.1"},
+		Rules: []config.IPAccessRule{{
+			Routes: []string{"/v0/management/*"},
+			Allow:  []string{"10.1.0.0/16"},
+		}},
+	})
+	engine := newIPAccessEngine(access)
+
+	cases := []struct {
+		path, remote, xff string
+		want              int
+	}{
+		{"/v1/chat/completions", "10.2.3.4:5000", "", http.StatusOK},
+		{"/v1/chat/completions", "203.0.113.5:5000", "", http.StatusForbidden},
+		{"/v1/chat/completions", "10.9.1.1:5000", "", http.StatusForbidden},
+		{"/v1/chat/completions", "[::ffff:192.0.2.7]:443", "", http.StatusOK},
+		// Forwarded headers are honoured only from trusted proxies.
+		{"/v1/chat/completions", "10.255.0.1:80", "203.0.113.5", http.StatusForbidden},
+		{"/v1/chat/completions", "10.2.3.4:80", "10.9.9.9", http.StatusOK},
+		{"/v0/management/config", "10.2.3.4:5000", "", http.StatusForbidden},
+		{"/v0/management/config", "10.255.0.1:80", "203.0.113.5, 10.1.2.3", http.StatusOK},
+	}
+	for _, tc := range cases {
+		rec := doIPRequest(engine, tc.path, tc.remote, tc.xff)
+		if rec.Code != tc.want {
+			t.Fatalf("%s from %s (xff %q): got %d, want %d", tc.path, tc.remote, tc.xff, rec.Code, tc.want)
+		}
+		if tc.want == http.StatusForbidden && gjson.Get(rec.Body.String(), "error.code").String() != "ip_not_allowed" {
+			t.Fatalf("unexpected error body: %s", rec.Body.String())
+		}
+	}
+}
+
+func TestIPAccessRateLimit(t *testing.T) {
+	access := NewIPAccess(config.IPAccessConfig{RateLimit: config.IPRateLimit{RequestsPerMinute: 60, Burst: 2}})
+	engine := newIPAccessEngine(access)
+
+	for i := 0; i < 2; i++ {
+		if rec := doIPRequest(engine, "/v1/messages", "198.51.100.1:1", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d throttled early: %d", i, rec.Code)
+		}
+	}
+	rec := doIPRequest(engine, "/v1/messages", "198.51.100.1:1", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 429 with Retry-After 1, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec = doIPRequest(engine, "/v1/messages", "198.51.100.2:1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("other addresses must have their own bucket, got %d", rec.Code)
+	}
+
+	// Reapplying an identical configuration keeps the buckets.
+	access.Update(config.IPAccessConfig{RateLimit: config.IPRateLimit{RequestsPerMinute: 60, Burst: 2}})
+	if rec = doIPRequest(engine, "/v1/messages", "198.51.100.1:1", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("identical update reset the limiter: %d", rec.Code)
+	}
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+)
+
+// NDJSONContentType is the Content-Type of streams converted to newline-delimited JSON.
+const NDJSONContentType = "application/x-ndjson"
+
+// NDJSONStreams lets clients receive streaming responses as newline-delimited JSON instead of
+// server-sent events, by sending "Accept: application/x-ndjson" or the "stream_format=ndjson"
+// query parameter. Each event's data becomes one line; comments, keep-alives and OpenAI's
+// [DONE] marker are dropped. Non-streaming responses are unaffected.
+func NDJSONStreams() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !wantsNDJSON(c) {
+			c.Next()
+			return
+		}
+		w := &ndjsonWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		w.finish()
+	}
+}
+
+func wantsNDJSON(c *gin.Context) bool {
+	if format := c.Query("stream_format"); format != "" {
+		return strings.EqualFold(format, "ndjson") || strings.EqualFold(format, "jsonl")
+	}
+	accept := strings.ToLower(c.GetHeader("Accept"))
+	return strings.Contains(accept, NDJSONContentType) || strings.Contains(accept, "application/jsonl")
+}
+
+// ndjsonWriter converts a text/event-stream body into NDJSON as it is written. Events are
+// converted once complete; a partial event is held until the rest arrives.
+type ndjsonWriter struct {
+	gin.ResponseWriter
+	decided bool
+	convert bool
+	pending []byte
+}
+
+// decide checks the Content-Type the handler chose, just before the headers are sent.
+func (w *ndjsonWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	if w.ResponseWriter.Written() {
+		return
+	}
+	if strings.HasPrefix(strings.ToLower(w.Header().Get("Content-Type")), "text/event-stream") {
+		w.convert = true
+		w.Header().Set("Content-Type", NDJSONContentType)
+	}
+}
+
+func (w *ndjsonWriter) WriteHeader(code int) {
+	w.decide()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *ndjsonWriter) WriteHeaderNow() {
+	w.decide()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *ndjsonWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if !w.convert {
+		return w.ResponseWriter.Write(data)
+	}
+	w.pending = append(w.pending, data...)
+	for {
+		end, next := eventEnd(w.pending)
+		if end < 0 {
+			break
+		}
+		if err := w.writeEvent(w.pending[:end]); err != nil {
+			return 0, err
+		}
+		w.pending = w.pending[next:]
+	}
+	return len(data), nil
+}
+
+func (w *ndjsonWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *ndjsonWriter) Flush() {
+	w.decide()
+	w.ResponseWriter.Flush()
+}
+
+// finish converts an unterminated last event once the handler returns.
+func (w *ndjsonWriter) finish() {
+	if !w.convert || len(bytes.TrimSpace(w.pending)) == 0 {
+		return
+	}
+	_ = w.writeEvent(w.pending)
+	w.pending = nil
+	w.ResponseWriter.Flush()
+}
+
+// writeEvent writes the data of one SSE event as a single line.
+func (w *ndjsonWriter) writeEvent(event []byte) error {
+	var data [][]byte
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		if payload, ok := sse.Data(bytes.TrimRight(line, "\r")); ok {
+			data = append(data, payload)
+		}
+	}
+	payload := bytes.TrimSpace(bytes.Join(data, []byte("\n")))
+	if len(payload) == 0 || sse.IsDone(payload) {
+		return nil
+	}
+	// Newlines inside a multi-line data field would split the record.
+	payload = bytes.ReplaceAll(payload, []byte("\n"), []byte(" "))
+	_, err := w.ResponseWriter.Write(append(payload, '\n'))
+	return err
+}
+
+// eventEnd returns the end of the first complete event in buf and the start of the next one,
+// or -1 when no event is complete yet.
+func eventEnd(buf []byte) (end, next int) {
+	lf := bytes.Index(buf, []byte("\n\n"))
+	crlf := bytes.Index(buf, []byte("\r\n\r\n"))
+	switch {
+	case crlf >= 0 && (lf < 0 || crlf < lf):
+		return crlf, crlf + 4
+	case lf >= 0:
+		return lf, lf + 2
+	default:
+		return -1, -1
+	}
+}
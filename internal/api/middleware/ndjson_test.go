@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNDJSONStreams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(NDJSONStreams())
+	engine.POST("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		_, _ = c.Writer.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\"}\n\n: keep-alive\n\n"))
+		c.Writer.Flush()
+		_, _ = c.Writer.Write([]byte("data: {\"choices\":"))
+		_, _ = c.Writer.Write([]byte("[]}\n\ndata: [DONE]\n\n"))
+		_, _ = c.Writer.Write([]byte("data: {\"tail\":true}"))
+	})
+	engine.POST("/json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	do := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/stream", "application/x-ndjson")
+	if got := rec.Header().Get("Content-Type"); got != NDJSONContentType {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	want := "{\"type\":\"message_start\"}\n{\"choices\":[]}\n{\"tail\":true}\n"
+	if rec.Body.String() != want {
+		t.Fatalf("body = %q, want %q", rec.Body.String(), want)
+	}
+
+	if rec = do("/stream?stream_format=ndjson", ""); rec.Body.String() != want {
+		t.Fatalf("query opt-in body = %q", rec.Body.String())
+	}
+	if rec = do("/stream", ""); rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream converted without opt-in: %q", rec.Body.String())
+	}
+	if rec = do("/json", "application/x-ndjson"); rec.Body.String() != `{"ok":true}` {
+		t.Fatalf("non-streaming body = %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+)
+
+// RateLimitHeaders adds anthropic-ratelimit-* or x-ratelimit-* headers to Claude and OpenAI API
+// responses, computed from the proxy's own limiters and the upstream's reported limits. Update
+// toggles it without restarting.
+type RateLimitHeaders struct {
+	enabled atomic.Bool
+}
+
+// NewRateLimitHeaders builds the middleware state.
+func NewRateLimitHeaders(enabled bool) *RateLimitHeaders {
+	r := &RateLimitHeaders{}
+	r.Update(enabled)
+	return r
+}
+
+// Update applies a new configuration.
+func (r *RateLimitHeaders) Update(enabled bool) {
+	r.enabled.Store(enabled)
+}
+
+// Handler returns the gin middleware.
+func (r *RateLimitHeaders) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dialect, ok := ratelimit.DialectForPath(c.Request.URL.Path)
+		if !r.enabled.Load() || !ok {
+			c.Next()
+			return
+		}
+		state := ratelimit.NewState()
+		c.Request = c.Request.WithContext(ratelimit.WithState(c.Request.Context(), state))
+		c.Writer = &rateLimitWriter{ResponseWriter: c.Writer, state: state, dialect: dialect}
+		c.Next()
+	}
+}
+
+// rateLimitWriter sets the rate-limit headers just before the response headers are sent, once
+// the upstream has answered.
+type rateLimitWriter struct {
+	gin.ResponseWriter
+	state   *ratelimit.State
+	dialect ratelimit.Dialect
+	written bool
+}
+
+func (w *rateLimitWriter) setHeaders() {
+	if w.written || w.ResponseWriter.Written() {
+		return
+	}
+	w.written = true
+	w.state.WriteHeaders(w.Header(), w.dialect, time.Now())
+}
+
+func (w *rateLimitWriter) WriteHeader(code int) {
+	w.setHeaders()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *rateLimitWriter) WriteHeaderNow() {
+	w.setHeaders()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *rateLimitWriter) Write(data []byte) (int, error) {
+	w.setHeaders()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *rateLimitWriter) WriteString(s string) (int, error) {
+	w.setHeaders()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *rateLimitWriter) Flush() {
+	w.setHeaders()
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	headers := NewRateLimitHeaders(true)
+	tenants := NewTenants([]config.TenantConfig{{Name: "a", APIKeys: []string{"key-a"}, Quota: config.TenantQuota{RequestsPerMinute: 60, Burst: 5}}})
+	engine := gin.New()
+	engine.Use(headers.Handler(), func(c *gin.Context) { c.Set("apiKey", c.GetHeader("Authorization")) }, tenants.Handler())
+	engine.POST("/*path", func(c *gin.Context) {
+		upstream := http.Header{}
+		upstream.Set("anthropic-ratelimit-tokens-limit", "8000")
+		upstream.Set("anthropic-ratelimit-tokens-remaining", "7000")
+		upstream.Set("anthropic-ratelimit-tokens-reset", time.Now().Add(time.Minute).UTC().Format(time.RFC3339))
+		ratelimit.FromContext(c.Request.Context()).ObserveUpstream(upstream, time.Now())
+		c.String(http.StatusOK, "ok")
+	})
+
+	do := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", "key-a")
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/v1/messages")
+	if rec.Header().Get("anthropic-ratelimit-requests-limit") != "60" || rec.Header().Get("anthropic-ratelimit-requests-remaining") != "4" {
+		t.Fatalf("request headers = %v", rec.Header())
+	}
+	if rec.Header().Get("anthropic-ratelimit-tokens-remaining") != "7000" {
+		t.Fatalf("upstream token headers missing: %v", rec.Header())
+	}
+
+	rec = do("/v1/chat/completions")
+	if rec.Header().Get("x-ratelimit-remaining-requests") != "3" || rec.Header().Get("x-ratelimit-remaining-tokens") != "7000" {
+		t.Fatalf("openai headers = %v", rec.Header())
+	}
+
+	headers.Update(false)
+	if rec = do("/v1/chat/completions"); rec.Header().Get("x-ratelimit-remaining-requests") != "" {
+		t.Fatalf("headers written while disabled: %v", rec.Header())
+	}
+}
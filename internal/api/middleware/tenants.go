@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+)
+
+// Tenants resolves the tenant of authenticated requests from the hostname or client key,
+// rejects keys used against another tenant's hostname and enforces tenant quotas. The tenant
+// name is stored in the gin context under "tenant". It must run after authentication; Update
+// swaps the configuration without restarting.
+type Tenants struct {
+	signature string
+	state     atomic.Pointer[tenantState]
+	mu        sync.Mutex
+}
+
+type tenantState struct {
+	tenants []*tenantEntry
+	byKey   map[string]*tenantEntry
+}
+
+type tenantEntry struct {
+	name        string
+	hosts       []string
+	keyPrefixes []string
+	quota       *tenantQuota
+}
+
+// NewTenants builds the middleware state for the configured tenants.
+func NewTenants(tenants []config.TenantConfig) *Tenants {
+	t := &Tenants{}
+	t.Update(tenants)
+	return t
+}
+
+// Update applies a new tenant list. Quota state is kept when the configuration is unchanged.
+func (t *Tenants) Update(tenants []config.TenantConfig) {
+	raw, _ := json.Marshal(tenants)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.signature == string(raw) && t.state.Load() != nil {
+		return
+	}
+	t.signature = string(raw)
+	state := &tenantState{byKey: make(map[string]*tenantEntry)}
+	for _, cfg := range tenants {
+		name := strings.TrimSpace(cfg.Name)
+		if name == "" {
+			continue
+		}
+		entry := &tenantEntry{name: name, quota: newTenantQuota(cfg.Quota)}
+		for _, host := range cfg.Hosts {
+			if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+				entry.hosts = append(entry.hosts, host)
+			}
+		}
+		for _, prefix := range cfg.KeyPrefixes {
+			if prefix != "" {
+				entry.keyPrefixes = append(entry.keyPrefixes, prefix)
+			}
+		}
+		for _, key := range cfg.APIKeys {
+			if _, taken := state.byKey[key]; key != "" && !taken {
+				state.byKey[key] = entry
+			}
+		}
+		state.tenants = append(state.tenants, entry)
+	}
+	t.state.Store(state)
+}
+
+// Handler returns the gin middleware.
+func (t *Tenants) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := t.state.Load()
+		if state == nil || len(state.tenants) == 0 {
+			c.Next()
+			return
+		}
+		key := ""
+		if v, ok := c.Get("apiKey"); ok {
+			key, _ = v.(string)
+		}
+		byHost := state.forHost(c.Request.Host)
+		byKey := state.forKey(key)
+		tenant := byKey
+		if byHost != nil {
+			if key != "" && byKey != byHost {
+				abortTenant(c, http.StatusForbidden, "tenant_mismatch", "API key does not belong to this tenant", 0)
+				return
+			}
+			tenant = byHost
+		}
+		if tenant == nil {
+			c.Next()
+			return
+		}
+		now := time.Now()
+		wait, reason := tenant.quota.take(now)
+		tenant.quota.report(ratelimit.FromContext(c.Request.Context()), now)
+		if reason != "" {
+			abortTenant(c, http.StatusTooManyRequests, "tenant_quota_exceeded", fmt.Sprintf("Tenant %s exceeded its %s", tenant.name, reason), wait)
+			return
+		}
+		c.Set("tenant", tenant.name)
+		c.Next()
+	}
+}
+
+func (s *tenantState) forHost(hostport string) *tenantEntry {
+	host := strings.ToLower(strings.TrimSpace(hostport))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		return nil
+	}
+	for _, entry := range s.tenants {
+		for _, pattern := range entry.hosts {
+			if pattern == host {
+				return entry
+			}
+			if suffix, ok := strings.CutPrefix(pattern, "*."); ok && strings.HasSuffix(host, "."+suffix) {
+				return entry
+			}
+		}
+	}
+	return nil
+}
+
+func (s *tenantState) forKey(key string) *tenantEntry {
+	if key == "" {
+		return nil
+	}
+	if entry, ok := s.byKey[key]; ok {
+		return entry
+	}
+	for _, entry := range s.tenants {
+		for _, prefix := range entry.keyPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return entry
+			}
+		}
+	}
+	return nil
+}
+
+func abortTenant(c *gin.Context, status int, code, message string, retryAfter time.Duration) {
+	errType := "permission_error"
+	if status == http.StatusTooManyRequests {
+		errType = "rate_limit_error"
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": gin.H{
+		"message": message,
+		"type":    errType,
+		"code":    code,
+	}})
+}
+
+// tenantQuota combines a token bucket for the per-minute rate with a per-day counter.
+type tenantQuota struct {
+	mu       sync.Mutex
+	rate     float64 // tokens per second
+	burst    float64
+	tokens   float64
+	last     time.Time
+	perDay   int
+	day      string
+	dayCount int
+}
+
+func newTenantQuota(cfg config.TenantQuota) *tenantQuota {
+	if cfg.RequestsPerMinute <= 0 && cfg.RequestsPerDay <= 0 {
+		return nil
+	}
+	q := &tenantQuota{perDay: max(cfg.RequestsPerDay, 0)}
+	if cfg.RequestsPerMinute > 0 {
+		burst := cfg.Burst
+		if burst <= 0 {
+			burst = cfg.RequestsPerMinute
+		}
+		q.rate = float64(cfg.RequestsPerMinute) / 60
+		q.burst = float64(burst)
+		q.tokens = q.burst
+	}
+	return q
+}
+
+// take consumes one request. It returns the limit that was hit and how long until it frees up.
+func (q *tenantQuota) take(now time.Time) (time.Duration, string) {
+	if q == nil {
+		return 0, ""
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	day := now.UTC().Format("2006-01-02")
+	if q.perDay > 0 {
+		if q.day != day {
+			q.day, q.dayCount = day, 0
+		}
+		if q.dayCount >= q.perDay {
+			next := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+			return next.Sub(now), "daily request quota"
+		}
+	}
+	if q.rate > 0 {
+		if !q.last.IsZero() {
+			q.tokens = math.Min(q.burst, q.tokens+now.Sub(q.last).Seconds()*q.rate)
+		}
+		q.last = now
+		if q.tokens < 1 {
+			return time.Duration((1 - q.tokens) / q.rate * float64(time.Second)), "request rate limit"
+		}
+		q.tokens--
+	}
+	if q.perDay > 0 {
+		q.dayCount++
+	}
+	return 0, ""
+}
+
+// report adds the per-minute and per-day request windows to the rate-limit header state.
+func (q *tenantQuota) report(state *ratelimit.State, now time.Time) {
+	if q == nil || state == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.rate > 0 {
+		refill := time.Duration((q.burst - q.tokens) / q.rate * float64(time.Second))
+		state.Limit(ratelimit.Requests, ratelimit.Window{
+			Limit:     int64(math.Round(q.rate * 60)),
+			Remaining: int64(q.tokens),
+			Reset:     now.Add(refill),
+		})
+	}
+	if q.perDay > 0 {
+		state.Limit(ratelimit.Requests, ratelimit.Window{
+			Limit:     int64(q.perDay),
+			Remaining: int64(q.perDay - q.dayCount),
+			Reset:     now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour),
+		})
+	}
+}
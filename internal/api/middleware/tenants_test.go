@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func newTenantEngine(tenants *Tenants) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		if key := c.GetHeader("Authorization"); key != "" {
+			c.Set("apiKey", key)
+		}
+	}, tenants.Handler())
+	engine.Any("/*path", func(c *gin.Context) { c.String(http.StatusOK, c.GetString("tenant")) })
+	return engine
+}
+
+func doTenantRequest(engine *gin.Engine, host, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Host = host
+	if key != "" {
+		req.Header.Set("Authorization", key)
+	}
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTenantsResolveByHostAndKey(t *testing.T) {
+	engine := newTenantEngine(NewTenants([]config.TenantConfig{
+		{Name: "a", Hosts: []string{"a.example.com"}, APIKeys: []string{"key-a"}},
+		{Name: "b", Hosts: []string{"*.b.example.com"}, KeyPrefixes: []string{"tb-"}},
+	}))
+
+	cases := []struct {
+		host, key  string
+		wantStatus int
+		wantTenant string
+	}{
+		{"a.example.com:8317", "key-a", http.StatusOK, "a"},
+		{"proxy.local", "key-a", http.StatusOK, "a"},
+		{"eu.b.example.com", "tb-123", http.StatusOK, "b"},
+		{"proxy.local", "tb-123", http.StatusOK, "b"},
+		{"proxy.local", "global-key", http.StatusOK, ""},
+		// Keys of another tenant, or global keys, are rejected on a tenant hostname.
+		{"a.example.com", "tb-123", http.StatusForbidden, ""},
+		{"a.example.com", "global-key", http.StatusForbidden, ""},
+		// Without authentication the hostname alone selects the tenant.
+		{"a.example.com", "", http.StatusOK, "a"},
+	}
+	for _, tc := range cases {
+		rec := doTenantRequest(engine, tc.host, tc.key)
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("host=%s key=%s: status %d, want %d", tc.host, tc.key, rec.Code, tc.wantStatus)
+		}
+		if tc.wantStatus == http.StatusOK && rec.Body.String() != tc.wantTenant {
+			t.Fatalf("host=%s key=%s: tenant %q, want %q", tc.host, tc.key, rec.Body.String(), tc.wantTenant)
+		}
+		if tc.wantStatus == http.StatusForbidden && gjson.Get(rec.Body.String(), "error.code").String() != "tenant_mismatch" {
+			t.Fatalf("unexpected error body: %s", rec.Body.String())
+		}
+	}
+}
+
+func TestTenantsQuota(t *testing.T) {
+	tenants := NewTenants([]config.TenantConfig{
+		{Name: "a", APIKeys: []string{"key-a"}, Quota: config.TenantQuota{RequestsPerMinute: 60, Burst: 2}},
+		{Name: "b", APIKeys: []string{"key-b"}, Quota: config.TenantQuota{RequestsPerDay: 1}},
+	})
+	engine := newTenantEngine(tenants)
+
+	for i := 0; i < 2; i++ {
+		if rec := doTenantRequest(engine, "proxy.local", "key-a"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within burst: status %d", i, rec.Code)
+		}
+	}
+	rec := doTenantRequest(engine, "proxy.local", "key-a")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After after burst, got %d", rec.Code)
+	}
+	if code := gjson.Get(rec.Body.String(), "error.code").String(); code != "tenant_quota_exceeded" {
+		t.Fatalf("unexpected error code %q", code)
+	}
+
+	if rec = doTenantRequest(engine, "proxy.local", "key-b"); rec.Code != http.StatusOK {
+		t.Fatalf("first daily request: status %d", rec.Code)
+	}
+	if rec = doTenantRequest(engine, "proxy.local", "key-b"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected daily quota to be enforced, got %d", rec.Code)
+	}
+
+	// Unchanged configuration keeps quota state.
+	tenants.Update([]config.TenantConfig{
+		{Name: "a", APIKeys: []string{"key-a"}, Quota: config.TenantQuota{RequestsPerMinute: 60, Burst: 2}},
+		{Name: "b", APIKeys: []string{"key-b"}, Quota: config.TenantQuota{RequestsPerDay: 1}},
+	})
+	if rec = doTenantRequest(engine, "proxy.local", "key-b"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected quota state to survive an unchanged update, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+)
+
+// Request headers that opt a request into tracing. The trace ID is returned in TraceIDHeader.
+const (
+	TraceHeader   = "X-CLIProxy-Trace"
+	TraceIDHeader = "X-CLIProxy-Trace-Id"
+)
+
+// maxTraceIDLength bounds client-supplied trace IDs.
+const maxTraceIDLength = 64
+
+// RequestTrace attaches a routing decision trace to requests that ask for one and stores it for
+// retrieval once the request finishes. Update swaps the configuration without restarting.
+type RequestTrace struct {
+	always atomic.Bool
+	store  *trace.Store
+}
+
+// NewRequestTrace builds the middleware state for cfg using the process-wide trace store.
+func NewRequestTrace(cfg config.RequestTraceConfig) *RequestTrace {
+	t := &RequestTrace{store: trace.DefaultStore()}
+	t.Update(cfg)
+	return t
+}
+
+// Update applies a new configuration.
+func (t *RequestTrace) Update(cfg config.RequestTraceConfig) {
+	t.always.Store(cfg.Always)
+	t.store.SetLimits(cfg.MaxEntries, time.Duration(cfg.RetentionMinutes)*time.Minute)
+}
+
+// Handler returns the gin middleware.
+func (t *RequestTrace) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		requestedID := strings.TrimSpace(c.GetHeader(TraceIDHeader))
+		optIn := requestedID != "" || isTruthy(c.GetHeader(TraceHeader))
+		if (!optIn && !t.always.Load()) || strings.HasPrefix(path, "/v0/management") || strings.HasPrefix(path, "/admin/") {
+			c.Next()
+			return
+		}
+		id := requestedID
+		if !validTraceID(id) {
+			id = uuid.NewString()
+		} else if _, exists := t.store.Get(id); exists {
+			// Never let a request overwrite another request's trace.
+			id = uuid.NewString()
+		}
+		tr := trace.New(id, c.Request.Method, path)
+		t.store.Put(tr)
+		c.Header(TraceIDHeader, id)
+		c.Request = c.Request.WithContext(trace.WithTrace(c.Request.Context(), tr))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		data := map[string]any{"status": status}
+		if key := c.GetString("apiKey"); key != "" {
+			data["api_key"] = util.HideAPIKey(key)
+		}
+		if tenant := c.GetString("tenant"); tenant != "" {
+			data["tenant"] = tenant
+		}
+		tr.Add("response", fmt.Sprintf("responded with status %d", status), data)
+		tr.Finish(status)
+	}
+}
+
+func isTruthy(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// validTraceID accepts IDs made of letters, digits, '-', '_' and '.'.
+func validTraceID(id string) bool {
+	if id == "" || len(id) > maxTraceIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
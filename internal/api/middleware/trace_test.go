@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+)
+
+func newTraceEngine(rt *RequestTrace) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(rt.Handler())
+	engine.Any("/*path", func(c *gin.Context) {
+		trace.Record(c.Request.Context(), "route", "handled", nil)
+		c.Status(http.StatusAccepted)
+	})
+	return engine
+}
+
+func doTraceRequest(engine *gin.Engine, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequestTraceIsOptIn(t *testing.T) {
+	rt := &RequestTrace{store: trace.NewStore(10, 0)}
+	engine := newTraceEngine(rt)
+
+	if rec := doTraceRequest(engine, nil); rec.Header().Get(TraceIDHeader) != "" {
+		t.Fatal("requests without the trace header must not be traced")
+	}
+
+	rec := doTraceRequest(engine, map[string]string{TraceHeader: "1"})
+	id := rec.Header().Get(TraceIDHeader)
+	if id == "" {
+		t.Fatal("missing trace id")
+	}
+	tr, ok := rt.store.Get(id)
+	if !ok {
+		t.Fatalf("trace %s not stored", id)
+	}
+	snap := tr.Snapshot()
+	if !snap.Complete || snap.Status != http.StatusAccepted || len(snap.Steps) != 2 || snap.Steps[0].Kind != "route" {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+
+	rt.Update(config.RequestTraceConfig{Always: true})
+	if rec = doTraceRequest(engine, nil); rec.Header().Get(TraceIDHeader) == "" {
+		t.Fatal("always should trace every request")
+	}
+}
+
+func TestRequestTraceHonoursClientIDWithoutOverwriting(t *testing.T) {
+	rt := &RequestTrace{store: trace.NewStore(10, 0)}
+	engine := newTraceEngine(rt)
+
+	if got := doTraceRequest(engine, map[string]string{TraceIDHeader: "my-trace.1"}).Header().Get(TraceIDHeader); got != "my-trace.1" {
+		t.Fatalf("trace id = %q; want the client's id", got)
+	}
+	if got := doTraceRequest(engine, map[string]string{TraceIDHeader: "my-trace.1"}).Header().Get(TraceIDHeader); got == "my-trace.1" {
+		t.Fatal("a reused id must not replace the existing trace")
+	}
+	if got := doTraceRequest(engine, map[string]string{TraceIDHeader: "bad id!"}).Header().Get(TraceIDHeader); got == "" || got == "bad id!" {
+		t.Fatalf("invalid id should be replaced, got %q", got)
+	}
+}
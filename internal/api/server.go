@@ -7,6 +7,7 @@ package api
 import (
 	"context"
 	"crypto/subtle"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/http"
@@ -19,15 +20,20 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/adminui"
 	managementHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/budget"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/webhook"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/claude"
@@ -36,6 +42,7 @@ import (
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 	"gopkg.in/yaml.v3"
 )
 
@@ -119,6 +126,34 @@ type Server struct {
 	// server is the underlying HTTP server.
 	server *http.Server
 
+	// extraServers serve the additional listeners configured under listeners.
+	extraServers []*http.Server
+
+	// stopped is closed by Stop; Start waits on it when the main listener is disabled.
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	// ipAccess enforces client IP allow/deny lists and rate limits.
+	ipAccess *middleware.IPAccess
+
+	// requestTrace records routing decision traces for requests that opt in.
+	requestTrace *middleware.RequestTrace
+
+	// rateLimitHeaders synthesizes client rate-limit headers.
+	rateLimitHeaders *middleware.RateLimitHeaders
+
+	// bodyLimit enforces request body size limits.
+	bodyLimit *middleware.BodyLimit
+
+	// tenants resolves request tenants and enforces their quotas.
+	tenants *middleware.Tenants
+
+	// acmeServer answers ACME HTTP-01 challenges when automatic certificates are enabled.
+	acmeServer *http.Server
+
+	// grpcServer serves the gRPC surface when grpc is enabled.
+	grpcServer *grpc.Server
+
 	// handlers contains the API handlers for processing requests.
 	handlers *handlers.BaseAPIHandler
 
@@ -202,6 +237,11 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	// Add middleware
 	engine.Use(logging.GinLogrusLogger())
 	engine.Use(logging.GinLogrusRecovery())
+	ipAccess := middleware.NewIPAccess(cfg.IPAccess)
+	engine.Use(ipAccess.Handler())
+	bodyLimit := middleware.NewBodyLimit(cfg.BodyLimits)
+	engine.Use(bodyLimit.Handler())
+	engine.MaxMultipartMemory = bodyLimit.MultipartMemory()
 	for _, mw := range optionState.extraMiddleware {
 		engine.Use(mw)
 	}
@@ -223,6 +263,13 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 
 	engine.Use(corsMiddleware())
+	engine.Use(middleware.AuditMiddleware())
+	engine.Use(middleware.EventsMiddleware())
+	requestTrace := middleware.NewRequestTrace(cfg.RequestTrace)
+	engine.Use(requestTrace.Handler())
+	rateLimitHeaders := middleware.NewRateLimitHeaders(cfg.RateLimitHeaders)
+	engine.Use(rateLimitHeaders.Handler())
+	engine.Use(middleware.NDJSONStreams())
 	wd, err := os.Getwd()
 	if err != nil {
 		wd = configFilePath
@@ -235,6 +282,12 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	// Create server instance
 	s := &Server{
 		engine:              engine,
+		ipAccess:            ipAccess,
+		requestTrace:        requestTrace,
+		rateLimitHeaders:    rateLimitHeaders,
+		bodyLimit:           bodyLimit,
+		tenants:             middleware.NewTenants(cfg.Tenants),
+		stopped:             make(chan struct{}),
 		handlers:            handlers.NewBaseAPIHandlers(&cfg.SDKConfig, authManager),
 		cfg:                 cfg,
 		accessManager:       accessManager,
@@ -254,16 +307,21 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 	managementasset.SetCurrentConfig(cfg)
 	auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	auth.SetQuotaReserve(quotaReserveConfig(cfg.QuotaWindows))
+	auth.SetTenants(tenantCredentials(cfg.Tenants))
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
 	if optionState.localPassword != "" {
 		s.mgmt.SetLocalPassword(optionState.localPassword)
 	}
-	logDir := filepath.Join(s.currentPath, "logs")
-	if base := util.WritablePath(); base != "" {
-		logDir = filepath.Join(base, "logs")
-	}
+	logDir := s.logDirectory()
 	s.mgmt.SetLogDirectory(logDir)
+	audit.Configure(cfg.AuditLog, logDir)
+	pricing.Configure(cfg.Pricing)
+	budget.Configure(cfg.SpendBudgets)
+	webhook.Configure(cfg.Webhooks)
+	util.SetFinishReasonOverrides(cfg.FinishReasonOverrides)
+	util.SetMaxTokensOverrides(cfg.MaxTokens)
 	s.localPassword = optionState.localPassword
 
 	// Setup routes
@@ -306,31 +364,66 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	return s
 }
 
+// logDirectory returns the directory holding request and audit logs.
+func (s *Server) logDirectory() string {
+	if base := util.WritablePath(); base != "" {
+		return filepath.Join(base, "logs")
+	}
+	return filepath.Join(s.currentPath, "logs")
+}
+
 // setupRoutes configures the API routes for the server.
 // It defines the endpoints and associates them with their respective handlers.
 func (s *Server) setupRoutes() {
 	s.engine.GET("/management.html", s.serveManagementControlPanel)
+	s.engine.GET("/admin/ui", s.serveAdminDashboard)
+	s.engine.GET("/admin/ui/*path", s.serveAdminDashboard)
 	openaiHandlers := openai.NewOpenAIAPIHandler(s.handlers)
 	geminiHandlers := gemini.NewGeminiAPIHandler(s.handlers)
 	geminiCLIHandlers := gemini.NewGeminiCLIAPIHandler(s.handlers)
 	claudeCodeHandlers := claude.NewClaudeCodeAPIHandler(s.handlers)
+	openaiBatchHandlers := openai.NewOpenAIBatchAPIHandler(s.handlers)
 	openaiResponsesHandlers := openai.NewOpenAIResponsesAPIHandler(s.handlers)
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
-	v1.Use(AuthMiddleware(s.accessManager))
+	v1.Use(AuthMiddleware(s.accessManager), s.tenants.Handler())
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
+		v1.GET("/models/:id", s.unifiedModelHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/moderations", openaiHandlers.Moderations)
+		v1.POST("/images/generations", openaiHandlers.ImageGenerations)
+		v1.POST("/audio/transcriptions", openaiHandlers.AudioTranscriptions)
+		v1.POST("/audio/speech", openaiHandlers.AudioSpeech)
+		v1.POST("/rerank", openaiHandlers.Rerank)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
+		v1.POST("/messages/batches", claudeCodeHandlers.CreateMessageBatch)
+		v1.GET("/messages/batches", claudeCodeHandlers.ListMessageBatches)
+		v1.GET("/messages/batches/:id", claudeCodeHandlers.GetMessageBatch)
+		v1.GET("/messages/batches/:id/results", claudeCodeHandlers.GetMessageBatchResults)
+		v1.POST("/messages/batches/:id/cancel", claudeCodeHandlers.CancelMessageBatch)
+		v1.DELETE("/messages/batches/:id", claudeCodeHandlers.DeleteMessageBatch)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
+		// google-genai clients configured for API version v1 call the Gemini surface here.
+		v1.POST("/models/*action", geminiHandlers.GeminiHandler)
+		v1.POST("/auto", s.autoProtocolHandler(openaiHandlers, openaiResponsesHandlers, claudeCodeHandlers, geminiHandlers))
+		v1.POST("/files", openaiBatchHandlers.UploadFile)
+		v1.GET("/files", openaiBatchHandlers.ListFiles)
+		v1.GET("/files/:id", openaiBatchHandlers.GetFile)
+		v1.GET("/files/:id/content", openaiBatchHandlers.GetFileContent)
+		v1.DELETE("/files/:id", openaiBatchHandlers.DeleteFile)
+		v1.POST("/batches", openaiBatchHandlers.CreateBatch)
+		v1.GET("/batches", openaiBatchHandlers.ListBatches)
+		v1.GET("/batches/:id", openaiBatchHandlers.GetBatch)
+		v1.POST("/batches/:id/cancel", openaiBatchHandlers.CancelBatch)
 	}
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
-	v1beta.Use(AuthMiddleware(s.accessManager))
+	v1beta.Use(AuthMiddleware(s.accessManager), s.tenants.Handler())
 	{
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/*action", geminiHandlers.GeminiHandler)
@@ -350,6 +443,16 @@ func (s *Server) setupRoutes() {
 	})
 	s.engine.POST("/v1internal:method", geminiCLIHandlers.CLIHandler)
 
+	// Embedded MCP server exposing routed models as tools
+	mcpServer := s.newMCPServer(openaiHandlers)
+	s.engine.POST("/mcp", AuthMiddleware(s.accessManager), s.tenants.Handler(), s.handleMCP(mcpServer))
+	s.engine.GET("/mcp", AuthMiddleware(s.accessManager), s.tenants.Handler(), s.handleMCP(mcpServer))
+
+	// Health endpoints for liveness and readiness probes
+	s.engine.GET("/health", s.handleHealth)
+	s.engine.GET("/ready", s.handleReady)
+	s.engine.GET("/health/upstreams", AuthMiddleware(s.accessManager), s.tenants.Handler(), s.handleUpstreamHealth)
+
 	// OAuth callback endpoints (reuse main server port)
 	// These endpoints receive provider redirects and persist
 	// the short-lived code/state for the waiting goroutine.
@@ -473,13 +576,36 @@ func (s *Server) registerManagementRoutes() {
 
 	log.Info("management routes registered after secret key configuration")
 
+	s.engine.GET("/admin/events", s.managementAvailabilityMiddleware(), s.mgmt.Middleware(), s.mgmt.StreamEvents)
+	s.engine.GET("/admin/streams", s.managementAvailabilityMiddleware(), s.mgmt.Middleware(), s.mgmt.GetActiveStreams)
+	s.engine.DELETE("/admin/streams/:id", s.managementAvailabilityMiddleware(), s.mgmt.Middleware(), s.mgmt.CancelStream)
+	s.engine.GET("/admin/requests/:id", s.managementAvailabilityMiddleware(), s.mgmt.Middleware(), s.mgmt.GetRequestTrace)
+	s.engine.POST("/v0/translate", s.managementAvailabilityMiddleware(), s.mgmt.Middleware(), s.mgmt.PreviewTranslation)
+
 	mgmt := s.engine.Group("/v0/management")
 	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
 	{
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
 		mgmt.GET("/usage/export", s.mgmt.ExportUsageStatistics)
 		mgmt.POST("/usage/import", s.mgmt.ImportUsageStatistics)
+		mgmt.GET("/tool-id-mapping", s.mgmt.GetToolIDMappingStats)
+		mgmt.GET("/stream-dedupe", s.mgmt.GetStreamDedupeStats)
+		mgmt.GET("/streams", s.mgmt.GetActiveStreams)
+		mgmt.DELETE("/streams/:id", s.mgmt.CancelStream)
+		mgmt.GET("/credentials/status", s.mgmt.GetCredentialStatus)
+		mgmt.POST("/credentials/health/reset", s.mgmt.ResetCredentialHealth)
+		mgmt.GET("/queues", s.mgmt.GetUpstreamQueues)
+		mgmt.GET("/hedging", s.mgmt.GetHedgeStats)
+		mgmt.GET("/latency", s.mgmt.GetUpstreamLatency)
+		mgmt.GET("/quota-windows", s.mgmt.GetQuotaWindows)
+		mgmt.GET("/events", s.mgmt.StreamEvents)
+		mgmt.GET("/requests/:id", s.mgmt.GetRequestTrace)
+		mgmt.GET("/budgets", s.mgmt.GetSpendBudgets)
+		mgmt.POST("/budgets/reset", s.mgmt.ResetSpendBudget)
+		mgmt.GET("/proxy-health", s.mgmt.GetProxyHealth)
+		mgmt.GET("/audit/verify", s.mgmt.VerifyAuditLog)
 		mgmt.GET("/config", s.mgmt.GetConfig)
+		mgmt.PATCH("/config", s.mgmt.PatchConfig)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
 		mgmt.GET("/latest-version", s.mgmt.GetLatestVersion)
@@ -488,6 +614,10 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PUT("/debug", s.mgmt.PutDebug)
 		mgmt.PATCH("/debug", s.mgmt.PutDebug)
 
+		mgmt.GET("/log-level", s.mgmt.GetLogLevel)
+		mgmt.PUT("/log-level", s.mgmt.PutLogLevel)
+		mgmt.PATCH("/log-level", s.mgmt.PutLogLevel)
+
 		mgmt.GET("/logging-to-file", s.mgmt.GetLoggingToFile)
 		mgmt.PUT("/logging-to-file", s.mgmt.PutLoggingToFile)
 		mgmt.PATCH("/logging-to-file", s.mgmt.PutLoggingToFile)
@@ -662,6 +792,18 @@ func (s *Server) serveManagementControlPanel(c *gin.Context) {
 	c.File(filePath)
 }
 
+// serveAdminDashboard serves the embedded operator dashboard. The page itself is static; all
+// data is fetched from the management API with the operator's management key.
+func (s *Server) serveAdminDashboard(c *gin.Context) {
+	cfg := s.cfg
+	if cfg == nil || cfg.RemoteManagement.DisableControlPanel {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", adminui.Index())
+}
+
 func (s *Server) enableKeepAlive(timeout time.Duration, onTimeout func()) {
 	if timeout <= 0 || onTimeout == nil {
 		return
@@ -746,10 +888,8 @@ func (s *Server) watchKeepAlive() {
 // otherwise it routes to OpenAI handler.
 func (s *Server) unifiedModelsHandler(openaiHandler *openai.OpenAIAPIHandler, claudeHandler *claude.ClaudeCodeAPIHandler) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userAgent := c.GetHeader("User-Agent")
-
-		// Route to Claude handler if User-Agent starts with "claude-cli"
-		if strings.HasPrefix(userAgent, "claude-cli") {
+		// Route to Claude handler for claude-cli and Anthropic SDK clients
+		if anthropicClient(c) {
 			// log.Debugf("Routing /v1/models to Claude handler for User-Agent: %s", userAgent)
 			claudeHandler.ClaudeModels(c)
 		} else {
@@ -759,6 +899,30 @@ func (s *Server) unifiedModelsHandler(openaiHandler *openai.OpenAIAPIHandler, cl
 	}
 }
 
+// unifiedModelHandler serves GET /v1/models/{id} in the Anthropic or OpenAI schema, using the
+// same client detection as unifiedModelsHandler.
+func (s *Server) unifiedModelHandler(openaiHandler *openai.OpenAIAPIHandler, claudeHandler *claude.ClaudeCodeAPIHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if anthropicClient(c) {
+			claudeHandler.ClaudeModel(c)
+		} else {
+			openaiHandler.OpenAIModel(c)
+		}
+	}
+}
+
+// anthropicClient reports whether the request comes from claude-cli or an Anthropic SDK, which
+// always sends anthropic-version.
+func anthropicClient(c *gin.Context) bool {
+	return strings.HasPrefix(c.GetHeader("User-Agent"), "claude-cli") || c.GetHeader("anthropic-version") != ""
+}
+
+// Handler returns the HTTP handler serving every route, for programs that mount the proxy in
+// their own HTTP server instead of calling Start.
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler
+}
+
 // Start begins listening for and serving HTTP or HTTPS requests.
 // It's a blocking call and will only return on an unrecoverable error.
 //
@@ -768,14 +932,72 @@ func (s *Server) Start() error {
 	if s == nil || s.server == nil {
 		return fmt.Errorf("failed to start HTTP server: server not initialized")
 	}
+	serve, errServe := normalizeServe(s.cfg.Serve)
+	if errServe != nil {
+		return fmt.Errorf("failed to start HTTP server: %v", errServe)
+	}
+	handler := s.server.Handler
+	s.server.Handler = scopeHandler(handler, serve)
 
 	useTLS := s.cfg != nil && s.cfg.TLS.Enable
+	var cert, key string
+	var tlsConfig *tls.Config
 	if useTLS {
-		cert := strings.TrimSpace(s.cfg.TLS.Cert)
-		key := strings.TrimSpace(s.cfg.TLS.Key)
-		if cert == "" || key == "" {
+		useACME := s.cfg.TLS.ACME.Enable
+		cert = strings.TrimSpace(s.cfg.TLS.Cert)
+		key = strings.TrimSpace(s.cfg.TLS.Key)
+		if !useACME && (cert == "" || key == "") {
 			return fmt.Errorf("failed to start HTTPS server: tls.cert or tls.key is empty")
 		}
+		var errTLS error
+		tlsConfig, errTLS = serverTLSConfig(s.cfg.TLS)
+		if errTLS != nil {
+			return fmt.Errorf("failed to start HTTPS server: %v", errTLS)
+		}
+		if tlsConfig != nil {
+			log.Infof("mutual TLS enabled (client-auth: %s)", tlsConfig.ClientAuth)
+		}
+		if useACME {
+			manager, errACME := acmeManager(s.cfg.TLS.ACME, s.cfg.AuthDir)
+			if errACME != nil {
+				return fmt.Errorf("failed to start HTTPS server: %v", errACME)
+			}
+			tlsConfig = acmeTLSConfig(manager, tlsConfig)
+			// Certificates come from GetCertificate, so no files are passed to ListenAndServeTLS.
+			cert, key = "", ""
+			s.acmeServer = startACMEChallengeServer(manager, s.cfg.TLS.ACME)
+			log.Infof("automatic TLS certificates enabled for %s", strings.Join(s.cfg.TLS.ACME.Domains, ", "))
+		}
+		if tlsConfig != nil {
+			s.server.TLSConfig = tlsConfig
+		}
+	}
+
+	var extraTLS *tls.Config
+	if useTLS && (len(s.cfg.Listeners) > 0 || s.cfg.GRPC.Enable) {
+		var errTLS error
+		if extraTLS, errTLS = listenerTLSConfig(tlsConfig, cert, key); errTLS != nil {
+			return fmt.Errorf("failed to start listeners: %v", errTLS)
+		}
+	}
+	if len(s.cfg.Listeners) > 0 {
+		if errListeners := s.startExtraListeners(handler, extraTLS); errListeners != nil {
+			return fmt.Errorf("failed to start listeners: %v", errListeners)
+		}
+	}
+	if s.cfg.GRPC.Enable {
+		if errGRPC := s.startGRPC(handler, extraTLS); errGRPC != nil {
+			return fmt.Errorf("failed to start gRPC server: %v", errGRPC)
+		}
+	}
+
+	if serve == serveNone {
+		log.Debugf("Main listener %s disabled (serve: none)", s.server.Addr)
+		<-s.stopped
+		return nil
+	}
+
+	if useTLS {
 		log.Debugf("Starting API server on %s with TLS", s.server.Addr)
 		if errServeTLS := s.server.ListenAndServeTLS(cert, key); errServeTLS != nil && !errors.Is(errServeTLS, http.ErrServerClosed) {
 			return fmt.Errorf("failed to start HTTPS server: %v", errServeTLS)
@@ -809,10 +1031,32 @@ func (s *Server) Stop(ctx context.Context) error {
 		}
 	}
 
+	if s.acmeServer != nil {
+		_ = s.acmeServer.Shutdown(ctx)
+	}
+	for _, srv := range s.extraServers {
+		_ = srv.Shutdown(ctx)
+	}
+	if s.grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			s.grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.grpcServer.Stop()
+		}
+	}
+	s.stopOnce.Do(func() { close(s.stopped) })
+
 	// Shutdown the HTTP server.
 	if err := s.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
+	audit.Close()
+	webhook.Close()
 
 	log.Debug("API server stopped")
 	return nil
@@ -838,6 +1082,25 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// quotaReserveConfig converts the quota-windows settings into the auth package form.
+func quotaReserveConfig(cfg config.QuotaWindowsConfig) auth.QuotaReserveConfig {
+	providers := make(map[string]float64, len(cfg.Providers))
+	for provider, percent := range cfg.Providers {
+		providers[provider] = percent / 100
+	}
+	return auth.QuotaReserveConfig{Enabled: cfg.Enable, Reserve: cfg.ReservePercent / 100, Providers: providers}
+}
+
+func tenantCredentials(tenants []config.TenantConfig) []auth.TenantCredentials {
+	out := make([]auth.TenantCredentials, 0, len(tenants))
+	for _, tenant := range tenants {
+		if name := strings.TrimSpace(tenant.Name); name != "" {
+			out = append(out, auth.TenantCredentials{Name: name, Patterns: tenant.Credentials, Shared: tenant.SharedCredentials})
+		}
+	}
+	return out
+}
+
 func (s *Server) applyAccessConfig(oldCfg, newCfg *config.Config) {
 	if s == nil || s.accessManager == nil || newCfg == nil {
 		return
@@ -895,6 +1158,19 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	audit.Configure(cfg.AuditLog, s.logDirectory())
+	pricing.Configure(cfg.Pricing)
+	budget.Configure(cfg.SpendBudgets)
+	webhook.Configure(cfg.Webhooks)
+	util.SetFinishReasonOverrides(cfg.FinishReasonOverrides)
+	util.SetMaxTokensOverrides(cfg.MaxTokens)
+	s.ipAccess.Update(cfg.IPAccess)
+	s.requestTrace.Update(cfg.RequestTrace)
+	s.rateLimitHeaders.Update(cfg.RateLimitHeaders)
+	s.bodyLimit.Update(cfg.BodyLimits)
+	s.tenants.Update(cfg.Tenants)
+	auth.SetTenants(tenantCredentials(cfg.Tenants))
+
 	if oldCfg == nil || oldCfg.UsageStatisticsEnabled != cfg.UsageStatisticsEnabled {
 		usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 		if oldCfg != nil {
@@ -904,6 +1180,8 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	auth.SetQuotaReserve(quotaReserveConfig(cfg.QuotaWindows))
+
 	if oldCfg == nil || oldCfg.DisableCooling != cfg.DisableCooling {
 		auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 		if oldCfg != nil {
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -109,3 +110,47 @@ func TestAmpProviderModelRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestGeminiNativeRoutes(t *testing.T) {
+	server := newTestServer(t)
+	for _, path := range []string{"/v1beta/models/gemini-2.5-pro:embedText", "/v1/models/gemini-2.5-pro:embedText"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"contents":[]}`))
+		req.Header.Set("X-Goog-Api-Key", "test-key")
+
+		rr := httptest.NewRecorder()
+		server.engine.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "generateContent") {
+			t.Fatalf("unexpected response for %s: %d %s", path, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// bearerProvider accepts any bearer token as the client key.
+type bearerProvider struct{}
+
+func (bearerProvider) Identifier() string { return "bearer" }
+
+func (bearerProvider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	return &sdkaccess.Result{Provider: "bearer", Principal: strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")}, nil
+}
+
+func TestTenantChecksApplyToMCPAndUpstreamHealth(t *testing.T) {
+	server := newTestServer(t)
+	server.accessManager.SetProviders([]sdkaccess.Provider{bearerProvider{}})
+	server.tenants.Update([]proxyconfig.TenantConfig{{Name: "team-a", Hosts: []string{"a.example.com"}, KeyPrefixes: []string{"team-a-"}}})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{}`)),
+		httptest.NewRequest(http.MethodGet, "/mcp", nil),
+		httptest.NewRequest(http.MethodGet, "/health/upstreams", nil),
+	} {
+		req.Host = "a.example.com"
+		req.Header.Set("Authorization", "Bearer test-key")
+		rr := httptest.NewRecorder()
+		server.engine.ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "tenant_mismatch") {
+			t.Fatalf("%s %s with another tenant's host: got %d %s", req.Method, req.URL.Path, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// defaultACMEChallengePort is the port ACME CAs use for HTTP-01 validation.
+const defaultACMEChallengePort = 80
+
+// serverTLSConfig returns the listener TLS configuration for mutual TLS, or nil when no client
+// CA is configured.
+func serverTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	caPath := strings.TrimSpace(cfg.ClientCA)
+	if caPath == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(caPath)
+	if err != nil {
+		return nil, fmt.Errorf("read tls.client-ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("tls.client-ca %s contains no PEM certificates", caPath)
+	}
+	clientAuth := tls.RequireAndVerifyClientCert
+	switch strings.ToLower(strings.TrimSpace(cfg.ClientAuth)) {
+	case "", "require":
+	case "optional":
+		clientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("unsupported tls.client-auth %q", cfg.ClientAuth)
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientCAs:  pool,
+		ClientAuth: clientAuth,
+	}, nil
+}
+
+// acmeManager builds the autocert manager for cfg. Certificates are obtained on the first TLS
+// handshake for each domain and renewed in the background before they expire.
+func acmeManager(cfg config.ACMEConfig, authDir string) (*autocert.Manager, error) {
+	var domains []string
+	for _, d := range cfg.Domains {
+		if d = strings.TrimSpace(d); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("tls.acme.domains is empty")
+	}
+	cacheDir := strings.TrimSpace(cfg.CacheDir)
+	if cacheDir == "" {
+		resolved, err := util.ResolveAuthDir(authDir)
+		if err != nil {
+			return nil, fmt.Errorf("resolve auth-dir for acme cache: %w", err)
+		}
+		cacheDir = filepath.Join(resolved, "acme")
+	}
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		return nil, fmt.Errorf("create acme cache dir: %w", err)
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cacheDir),
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Email:      strings.TrimSpace(cfg.Email),
+	}
+	if dir := strings.TrimSpace(cfg.DirectoryURL); dir != "" {
+		manager.Client = &acme.Client{DirectoryURL: dir}
+	}
+	return manager, nil
+}
+
+// startACMEChallengeServer serves HTTP-01 challenges and redirects other plain HTTP requests to
+// HTTPS. It returns nil when the challenge listener is disabled.
+func startACMEChallengeServer(manager *autocert.Manager, cfg config.ACMEConfig) *http.Server {
+	port := cfg.HTTPChallengePort
+	if port < 0 {
+		return nil
+	}
+	if port == 0 {
+		port = defaultACMEChallengePort
+	}
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           manager.HTTPHandler(nil),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("acme http-01 listener on %s stopped: %v", srv.Addr, err)
+		}
+	}()
+	log.Infof("acme http-01 challenges served on %s", srv.Addr)
+	return srv
+}
+
+// acmeTLSConfig combines the autocert TLS configuration with the mutual TLS settings of mtls.
+// TLS-ALPN-01 validation handshakes carry no client certificate, so they skip client auth.
+func acmeTLSConfig(manager *autocert.Manager, mtls *tls.Config) *tls.Config {
+	cfg := manager.TLSConfig()
+	if mtls == nil {
+		return cfg
+	}
+	cfg.ClientCAs = mtls.ClientCAs
+	cfg.ClientAuth = mtls.ClientAuth
+	challenge := cfg.Clone()
+	challenge.ClientAuth = tls.NoClientCert
+	challenge.NextProtos = []string{acme.ALPNProto}
+	cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto {
+			return challenge, nil
+		}
+		return nil, nil
+	}
+	return cfg
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/crypto/acme"
+)
+
+func TestACMETLSConfigRelaxesClientAuthForChallenges(t *testing.T) {
+	if _, err := acmeManager(config.ACMEConfig{Enable: true}, t.TempDir()); err == nil {
+		t.Fatalf("expected error without domains")
+	}
+	manager, err := acmeManager(config.ACMEConfig{Enable: true, Domains: []string{"proxy.example.com"}, CacheDir: t.TempDir()}, "")
+	if err != nil {
+		t.Fatalf("acme manager: %v", err)
+	}
+
+	plain := acmeTLSConfig(manager, nil)
+	if plain.GetCertificate == nil || plain.GetConfigForClient != nil {
+		t.Fatalf("unexpected plain acme tls config")
+	}
+
+	cfg := acmeTLSConfig(manager, &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert})
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Fatalf("client auth not carried over: %v", cfg.ClientAuth)
+	}
+	challenge, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{SupportedProtos: []string{acme.ALPNProto}})
+	if err != nil || challenge == nil || challenge.ClientAuth != tls.NoClientCert {
+		t.Fatalf("challenge handshake must skip client auth, got %+v, %v", challenge, err)
+	}
+	regular, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{SupportedProtos: []string{"h2", "http/1.1"}})
+	if err != nil || regular != nil {
+		t.Fatalf("regular handshakes must use the base config")
+	}
+}
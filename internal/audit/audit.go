@@ -0,0 +1,227 @@
+// Package audit writes a tamper-evident, append-only record of proxied requests.
+//
+// Every entry stores the hash of the previous entry and its own hash over its content, forming a
+// chain: editing, reordering or deleting a line invalidates every hash after it, which Verify
+// detects. Entries can additionally be forwarded to syslog or an HTTP SIEM collector.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Decision values describe how the proxy handled a request.
+const (
+	DecisionAllowed   = "allowed"
+	DecisionDenied    = "denied"
+	DecisionBlocked   = "blocked"
+	DecisionThrottled = "throttled"
+	DecisionError     = "error"
+)
+
+// Entry is one audit record. Field order is fixed so the hashed JSON is stable.
+type Entry struct {
+	Seq          uint64    `json:"seq"`
+	Time         time.Time `json:"time"`
+	RequestID    string    `json:"request_id,omitempty"`
+	ClientIP     string    `json:"client_ip,omitempty"`
+	Principal    string    `json:"principal,omitempty"`
+	KeyID        string    `json:"key_id,omitempty"`
+	AccessSource string    `json:"access_source,omitempty"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	Model        string    `json:"model,omitempty"`
+	Upstream     string    `json:"upstream,omitempty"`
+	AuthID       string    `json:"auth_id,omitempty"`
+	Attempts     int       `json:"attempts,omitempty"`
+	Status       int       `json:"status"`
+	Decision     string    `json:"decision"`
+	InputTokens  int64     `json:"input_tokens,omitempty"`
+	OutputTokens int64     `json:"output_tokens,omitempty"`
+	TotalTokens  int64     `json:"total_tokens,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	PrevHash     string    `json:"prev_hash"`
+	Hash         string    `json:"hash"`
+}
+
+// computeHash hashes the entry with its Hash field cleared.
+func computeHash(e Entry) string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Logger appends chained entries to a file and forwards them to exporters.
+type Logger struct {
+	mu        sync.Mutex
+	file      *os.File
+	seq       uint64
+	lastHash  string
+	exporters []exporter
+}
+
+// Open opens or creates the audit log at path and resumes the chain from its last entry.
+func Open(path string) (*Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("audit: create directory: %w", err)
+	}
+	l := &Logger{}
+	if last, err := readLastEntry(path); err != nil {
+		return nil, err
+	} else if last != nil {
+		l.seq = last.Seq
+		l.lastHash = last.Hash
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: open log: %w", err)
+	}
+	l.file = file
+	return l, nil
+}
+
+func readLastEntry(path string) (*Entry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("audit: read log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	var last []byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("audit: read log: %w", err)
+	}
+	if last == nil {
+		return nil, nil
+	}
+	var entry Entry
+	if err = json.Unmarshal(last, &entry); err != nil {
+		return nil, fmt.Errorf("audit: last entry is corrupt: %w", err)
+	}
+	return &entry, nil
+}
+
+// Record assigns the next sequence number, chains e to the previous entry and appends it.
+func (l *Logger) Record(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	l.seq++
+	e.Seq = l.seq
+	e.PrevHash = l.lastHash
+	e.Hash = computeHash(e)
+	data, _ := json.Marshal(e)
+	_, err := l.file.Write(append(data, '\n'))
+	if err == nil {
+		l.lastHash = e.Hash
+	} else {
+		l.seq--
+	}
+	exporters := l.exporters
+	l.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("audit: write entry: %w", err)
+	}
+	for _, exp := range exporters {
+		exp.export(e, data)
+	}
+	return nil
+}
+
+// Close flushes exporters and closes the log file.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, exp := range l.exporters {
+		exp.close()
+	}
+	l.exporters = nil
+	return l.file.Close()
+}
+
+// VerifyResult reports the outcome of a chain verification.
+type VerifyResult struct {
+	Entries  int    `json:"entries"`
+	Valid    bool   `json:"valid"`
+	BrokenAt uint64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify walks the audit log at path and checks sequence numbers and hash links. The chain must
+// start at sequence 1 with an empty previous hash, so truncating the head of the log is detected.
+func Verify(path string) (VerifyResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	defer func() { _ = file.Close() }()
+	return verify(file), nil
+}
+
+func verify(r io.Reader) VerifyResult {
+	res := VerifyResult{Valid: true}
+	var prevHash string
+	var prevSeq uint64
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return broken(res, prevSeq+1, "entry is not valid JSON")
+		}
+		switch {
+		case res.Entries == 0 && entry.Seq != 1:
+			return broken(res, entry.Seq, "log does not start at sequence 1")
+		case res.Entries == 0 && entry.PrevHash != "":
+			return broken(res, entry.Seq, "first entry links to a previous hash")
+		case res.Entries > 0 && entry.Seq != prevSeq+1:
+			return broken(res, entry.Seq, fmt.Sprintf("expected sequence %d", prevSeq+1))
+		case res.Entries > 0 && entry.PrevHash != prevHash:
+			return broken(res, entry.Seq, "previous hash does not match")
+		case computeHash(entry) != entry.Hash:
+			return broken(res, entry.Seq, "entry hash does not match its content")
+		}
+		res.Entries++
+		prevSeq = entry.Seq
+		prevHash = entry.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return broken(res, prevSeq+1, err.Error())
+	}
+	return res
+}
+
+func broken(res VerifyResult, seq uint64, reason string) VerifyResult {
+	res.Valid = false
+	res.BrokenAt = seq
+	res.Reason = reason
+	return res
+}
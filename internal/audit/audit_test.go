@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChainResumesAndDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, model := range []string{"gpt-5", "claude-sonnet"} {
+		if err = logger.Record(Entry{Method: "POST", Path: "/v1/chat/completions", Model: model, Status: 200, Decision: DecisionAllowed}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	if err = logger.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// Reopening continues the sequence and links to the last hash.
+	logger, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if err = logger.Record(Entry{Method: "POST", Path: "/v1/messages", Status: 401, Decision: DecisionDenied}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	_ = logger.Close()
+
+	res, err := Verify(path)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !res.Valid || res.Entries != 3 {
+		t.Fatalf("expected intact chain of 3 entries, got %+v", res)
+	}
+
+	data, _ := os.ReadFile(path)
+	tampered := strings.Replace(string(data), `"model":"claude-sonnet"`, `"model":"gpt-4o"`, 1)
+	if err = os.WriteFile(path, []byte(tampered), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	res, _ = Verify(path)
+	if res.Valid || res.BrokenAt != 2 {
+		t.Fatalf("expected tampering at entry 2, got %+v", res)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	dropped := lines[0] + "\n" + lines[2] + "\n"
+	_ = os.WriteFile(path, []byte(dropped), 0o600)
+	res, _ = Verify(path)
+	if res.Valid || res.BrokenAt != 3 {
+		t.Fatalf("expected deletion detected at entry 3, got %+v", res)
+	}
+
+	headless := lines[1] + "\n" + lines[2] + "\n"
+	_ = os.WriteFile(path, []byte(headless), 0o600)
+	res, _ = Verify(path)
+	if res.Valid || res.BrokenAt != 2 {
+		t.Fatalf("expected a dropped first entry to be detected, got %+v", res)
+	}
+}
@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+var defaultState struct {
+	mu                sync.RWMutex
+	signature         string
+	logger            *Logger
+	path              string
+	includeManagement bool
+}
+
+// Configure opens, reopens or closes the process-wide audit logger to match cfg. logDir is used
+// for the default file location.
+func Configure(cfg config.AuditLogConfig, logDir string) {
+	path := strings.TrimSpace(cfg.Path)
+	if path == "" {
+		path = filepath.Join(logDir, "audit.log")
+	}
+	raw, _ := json.Marshal(cfg)
+	signature := path + "\x00" + string(raw)
+	if !cfg.Enable {
+		signature = ""
+	}
+
+	defaultState.mu.Lock()
+	defer defaultState.mu.Unlock()
+	if signature == defaultState.signature {
+		return
+	}
+	if defaultState.logger != nil {
+		if err := defaultState.logger.Close(); err != nil {
+			log.Warnf("audit: close log: %v", err)
+		}
+	}
+	defaultState.logger, defaultState.path, defaultState.signature = nil, "", signature
+	if !cfg.Enable {
+		return
+	}
+	logger, err := Open(path)
+	if err != nil {
+		log.Errorf("audit logging disabled: %v", err)
+		return
+	}
+	if strings.TrimSpace(cfg.Syslog.Address) != "" {
+		logger.exporters = append(logger.exporters, newSyslogExporter(cfg.Syslog))
+	}
+	if strings.TrimSpace(cfg.HTTP.URL) != "" {
+		logger.exporters = append(logger.exporters, newHTTPExporter(cfg.HTTP))
+	}
+	defaultState.logger = logger
+	defaultState.path = path
+	defaultState.includeManagement = cfg.IncludeManagement
+	log.Infof("audit logging to %s", path)
+}
+
+// Default returns the process-wide audit logger, or nil when auditing is disabled, and whether
+// management requests should be recorded.
+func Default() (*Logger, bool) {
+	defaultState.mu.RLock()
+	defer defaultState.mu.RUnlock()
+	return defaultState.logger, defaultState.includeManagement
+}
+
+// DefaultPath returns the file used by the process-wide audit logger, or "" when disabled.
+func DefaultPath() string {
+	defaultState.mu.RLock()
+	defer defaultState.mu.RUnlock()
+	return defaultState.path
+}
+
+// Close closes the process-wide audit logger.
+func Close() {
+	defaultState.mu.Lock()
+	defer defaultState.mu.Unlock()
+	if defaultState.logger != nil {
+		if err := defaultState.logger.Close(); err != nil {
+			log.Warnf("audit: close log: %v", err)
+		}
+	}
+	defaultState.logger, defaultState.path, defaultState.signature = nil, "", ""
+}
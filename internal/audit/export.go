@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// exportQueueSize bounds the entries buffered per exporter; overflow is dropped and logged so a
+// slow collector never stalls request handling. The local file remains the source of truth.
+const exportQueueSize = 1024
+
+const exportTimeout = 10 * time.Second
+
+type exporter interface {
+	export(e Entry, data []byte)
+	close()
+}
+
+// asyncExporter delivers entries from a bounded queue on a background goroutine.
+type asyncExporter struct {
+	name    string
+	queue   chan []byte
+	send    func(data []byte) error
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+func newAsyncExporter(name string, send func(data []byte) error) *asyncExporter {
+	a := &asyncExporter{name: name, queue: make(chan []byte, exportQueueSize), send: send, done: make(chan struct{})}
+	go a.run()
+	return a
+}
+
+func (a *asyncExporter) export(_ Entry, data []byte) {
+	select {
+	case a.queue <- data:
+	default:
+		if dropped := a.dropped.Add(1); dropped == 1 || dropped%1000 == 0 {
+			log.Warnf("audit %s export queue full, %d entries dropped", a.name, dropped)
+		}
+	}
+}
+
+func (a *asyncExporter) run() {
+	defer close(a.done)
+	for data := range a.queue {
+		if err := a.send(data); err != nil {
+			log.Warnf("audit %s export failed: %v", a.name, err)
+		}
+	}
+}
+
+func (a *asyncExporter) close() {
+	a.once.Do(func() {
+		close(a.queue)
+		select {
+		case <-a.done:
+		case <-time.After(exportTimeout):
+		}
+	})
+}
+
+// newSyslogExporter forwards entries as RFC 5424 messages with the JSON entry as the message.
+func newSyslogExporter(cfg config.AuditSyslogConfig) exporter {
+	network := strings.ToLower(strings.TrimSpace(cfg.Network))
+	if network == "" {
+		network = "udp"
+	}
+	tag := strings.TrimSpace(cfg.Tag)
+	if tag == "" {
+		tag = "cliproxy"
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	var conn net.Conn
+	dial := func() (net.Conn, error) {
+		dialer := &net.Dialer{Timeout: exportTimeout}
+		if network == "tcp+tls" {
+			return tls.DialWithDialer(dialer, "tcp", cfg.Address, &tls.Config{MinVersion: tls.VersionTLS12})
+		}
+		return dialer.Dial(network, cfg.Address)
+	}
+	return newAsyncExporter("syslog", func(data []byte) error {
+		// PRI 110 = facility local5 (21) * 8 + severity informational (6).
+		msg := fmt.Sprintf("<110>1 %s %s %s %d - - %s", time.Now().UTC().Format(time.RFC3339Nano), hostname, tag, os.Getpid(), data)
+		if network != "udp" {
+			// Octet-counting framing (RFC 6587) for stream transports.
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		}
+		for attempt := 0; attempt < 2; attempt++ {
+			if conn == nil {
+				c, err := dial()
+				if err != nil {
+					return err
+				}
+				conn = c
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(exportTimeout))
+			if _, err := conn.Write([]byte(msg)); err == nil {
+				return nil
+			}
+			_ = conn.Close()
+			conn = nil
+		}
+		return fmt.Errorf("write to %s failed", cfg.Address)
+	})
+}
+
+// newHTTPExporter POSTs each entry as JSON to a SIEM collector.
+func newHTTPExporter(cfg config.AuditHTTPConfig) exporter {
+	client := &http.Client{Timeout: exportTimeout}
+	return newAsyncExporter("http", func(data []byte) error {
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range cfg.Headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("collector returned status %d", resp.StatusCode)
+		}
+		return nil
+	})
+}
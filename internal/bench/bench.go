@@ -0,0 +1,364 @@
+// Package bench drives synthetic chat completion load at an OpenAI-compatible endpoint and
+// summarises latency, time-to-first-token, token throughput and the local cost of translating
+// the responses into the other client formats.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultConcurrency = 8
+	defaultRequests    = 100
+	defaultPrompt      = "Write a short paragraph about load testing."
+	defaultTimeout     = 2 * time.Minute
+
+	// translationBudget bounds how long the translation measurement replays the sample.
+	translationBudget = 50 * time.Millisecond
+)
+
+// translationTargets are the client formats the sampled OpenAI response is translated into.
+var translationTargets = []sdktranslator.Format{sdktranslator.FormatClaude, sdktranslator.FormatGemini}
+
+// Options configures a benchmark run.
+type Options struct {
+	// BaseURL is the OpenAI-compatible API root, e.g. http://127.0.0.1:8317/v1.
+	BaseURL string
+	APIKey  string
+	Model   string
+	Prompt  string
+	// MaxTokens is sent as max_tokens when positive.
+	MaxTokens int
+	Stream    bool
+	// Concurrency is the number of requests kept in flight.
+	Concurrency int
+	// Requests is the total number of requests; ignored when Duration is set.
+	Requests int
+	// Duration keeps issuing requests until it elapses.
+	Duration time.Duration
+	// Timeout bounds a single request.
+	Timeout    time.Duration
+	HTTPClient *http.Client
+}
+
+// Percentiles summarises a latency distribution.
+type Percentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// TranslationCost is the measured cost of translating the sampled response into one client format.
+type TranslationCost struct {
+	Target   string
+	Chunks   int
+	PerChunk time.Duration
+}
+
+// Report is the outcome of a benchmark run.
+type Report struct {
+	Requests int
+	Failures int
+	Elapsed  time.Duration
+	Latency  Percentiles
+	// TTFT is the time to the first content delta for streaming runs and equals Latency otherwise.
+	TTFT Percentiles
+	// CompletionTokens is the total across successful requests.
+	CompletionTokens int
+	// TokensPerSecond is the mean per-request decode rate after the first token.
+	TokensPerSecond float64
+	// AggregateTokensPerSecond is CompletionTokens divided by the wall-clock run time.
+	AggregateTokensPerSecond float64
+	Translation              []TranslationCost
+	FirstError               string
+}
+
+type result struct {
+	latency time.Duration
+	ttft    time.Duration
+	tokens  int
+	err     error
+	sample  [][]byte
+}
+
+// Run executes the benchmark described by opts.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if strings.TrimSpace(opts.BaseURL) == "" {
+		return nil, errors.New("bench: base URL is required")
+	}
+	if strings.TrimSpace(opts.Model) == "" {
+		return nil, errors.New("bench: model is required")
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+	if opts.Requests <= 0 && opts.Duration <= 0 {
+		opts.Requests = defaultRequests
+	}
+	if opts.Prompt == "" {
+		opts.Prompt = defaultPrompt
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConnsPerHost: opts.Concurrency,
+		}}
+	}
+	body := requestBody(opts)
+
+	runCtx := ctx
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	jobs := make(chan struct{})
+	go func() {
+		defer close(jobs)
+		for i := 0; opts.Duration > 0 || i < opts.Requests; i++ {
+			select {
+			case jobs <- struct{}{}:
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu      sync.Mutex
+		results []result
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				res := doRequest(ctx, opts, body)
+				mu.Lock()
+				results = append(results, res)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	report := summarise(results, elapsed)
+	for _, res := range results {
+		if res.err == nil && len(res.sample) > 0 {
+			report.Translation = measureTranslation(opts, body, res.sample)
+			break
+		}
+	}
+	return report, nil
+}
+
+func requestBody(opts Options) []byte {
+	payload := map[string]any{
+		"model":    opts.Model,
+		"messages": []any{map[string]any{"role": "user", "content": opts.Prompt}},
+		"stream":   opts.Stream,
+	}
+	if opts.MaxTokens > 0 {
+		payload["max_tokens"] = opts.MaxTokens
+	}
+	if opts.Stream {
+		payload["stream_options"] = map[string]any{"include_usage": true}
+	}
+	data, _ := json.Marshal(payload)
+	return data
+}
+
+func doRequest(ctx context.Context, opts Options, body []byte) result {
+	reqCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	url := strings.TrimRight(opts.BaseURL, "/") + "/chat/completions"
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return result{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.APIKey)
+	}
+
+	start := time.Now()
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		return result{err: err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return result{err: fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))}
+	}
+
+	if !opts.Stream {
+		data, errRead := io.ReadAll(resp.Body)
+		latency := time.Since(start)
+		if errRead != nil {
+			return result{err: errRead}
+		}
+		return result{
+			latency: latency,
+			ttft:    latency,
+			tokens:  int(gjson.GetBytes(data, "usage.completion_tokens").Int()),
+			sample:  [][]byte{data},
+		}
+	}
+
+	var res result
+	contentChunks, usageTokens := 0, int64(-1)
+	scanner := sse.NewScanner(resp.Body)
+	defer scanner.Release()
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		payload, ok := sse.Data(line)
+		if !ok || len(payload) == 0 {
+			continue
+		}
+		res.sample = append(res.sample, bytes.Clone(line))
+		if sse.IsDone(payload) {
+			break
+		}
+		if gjson.GetBytes(payload, "choices.0.delta.content").String() != "" {
+			if contentChunks == 0 {
+				res.ttft = time.Since(start)
+			}
+			contentChunks++
+		}
+		if usage := gjson.GetBytes(payload, "usage.completion_tokens"); usage.Exists() {
+			usageTokens = usage.Int()
+		}
+	}
+	res.latency = time.Since(start)
+	if err = scanner.Err(); err != nil {
+		return result{err: err}
+	}
+	if contentChunks == 0 {
+		return result{err: errors.New("stream ended without content")}
+	}
+	res.tokens = contentChunks
+	if usageTokens >= 0 {
+		res.tokens = int(usageTokens)
+	}
+	return res
+}
+
+func summarise(results []result, elapsed time.Duration) *Report {
+	report := &Report{Requests: len(results), Elapsed: elapsed}
+	var latencies, ttfts []time.Duration
+	var rateSum float64
+	var rateCount int
+	for _, res := range results {
+		if res.err != nil {
+			report.Failures++
+			if report.FirstError == "" {
+				report.FirstError = res.err.Error()
+			}
+			continue
+		}
+		latencies = append(latencies, res.latency)
+		ttfts = append(ttfts, res.ttft)
+		report.CompletionTokens += res.tokens
+		if decode := res.latency - res.ttft; decode > 0 && res.tokens > 1 {
+			rateSum += float64(res.tokens-1) / decode.Seconds()
+			rateCount++
+		}
+	}
+	report.Latency = percentiles(latencies)
+	report.TTFT = percentiles(ttfts)
+	if rateCount > 0 {
+		report.TokensPerSecond = rateSum / float64(rateCount)
+	}
+	if elapsed > 0 {
+		report.AggregateTokensPerSecond = float64(report.CompletionTokens) / elapsed.Seconds()
+	}
+	return report
+}
+
+func percentiles(values []time.Duration) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	at := func(q float64) time.Duration {
+		idx := int(q*float64(len(values)-1) + 0.5)
+		return values[idx]
+	}
+	return Percentiles{P50: at(0.50), P90: at(0.90), P99: at(0.99), Max: values[len(values)-1]}
+}
+
+// measureTranslation replays a sampled OpenAI response through the registered translators, the
+// same work the proxy does when a Claude or Gemini client is served by an OpenAI upstream.
+func measureTranslation(opts Options, openAIRequest []byte, sample [][]byte) []TranslationCost {
+	ctx := context.Background()
+	costs := make([]TranslationCost, 0, len(translationTargets))
+	for _, target := range translationTargets {
+		original := sdktranslator.TranslateRequest(sdktranslator.FormatOpenAI, target, opts.Model, openAIRequest, opts.Stream)
+		passes, chunks := 0, 0
+		start := time.Now()
+		for passes == 0 || time.Since(start) < translationBudget {
+			var param any
+			for _, chunk := range sample {
+				if opts.Stream {
+					sdktranslator.TranslateStream(ctx, sdktranslator.FormatOpenAI, target, opts.Model, original, openAIRequest, chunk, &param)
+				} else {
+					sdktranslator.TranslateNonStream(ctx, sdktranslator.FormatOpenAI, target, opts.Model, original, openAIRequest, chunk, &param)
+				}
+				chunks++
+			}
+			passes++
+		}
+		costs = append(costs, TranslationCost{
+			Target:   target.String(),
+			Chunks:   len(sample),
+			PerChunk: time.Since(start) / time.Duration(chunks),
+		})
+	}
+	return costs
+}
+
+// Write renders the report as a human-readable summary.
+func (r *Report) Write(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "requests:        %d (%d failed) in %s, %.1f req/s\n",
+		r.Requests, r.Failures, r.Elapsed.Round(time.Millisecond), float64(r.Requests)/max(r.Elapsed.Seconds(), 1e-9))
+	_, _ = fmt.Fprintf(w, "latency:         %s\n", r.Latency)
+	_, _ = fmt.Fprintf(w, "ttft:            %s\n", r.TTFT)
+	_, _ = fmt.Fprintf(w, "tokens:          %d completion, %.1f tok/s per request, %.1f tok/s aggregate\n",
+		r.CompletionTokens, r.TokensPerSecond, r.AggregateTokensPerSecond)
+	for _, cost := range r.Translation {
+		_, _ = fmt.Fprintf(w, "%-17s%s/chunk over %d chunks\n", "to "+cost.Target+":", cost.PerChunk, cost.Chunks)
+	}
+	if r.FirstError != "" {
+		_, _ = fmt.Fprintf(w, "first error:     %s\n", r.FirstError)
+	}
+}
+
+// String formats the percentiles for the report.
+func (p Percentiles) String() string {
+	round := func(d time.Duration) time.Duration { return d.Round(10 * time.Microsecond) }
+	return fmt.Sprintf("p50 %s  p90 %s  p99 %s  max %s", round(p.P50), round(p.P90), round(p.P99), round(p.Max))
+}
@@ -0,0 +1,59 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mock"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+)
+
+func TestRunAgainstMock(t *testing.T) {
+	upstream := httptest.NewServer(mock.NewHandler(mock.Options{Text: "one two three four", TokenDelay: time.Millisecond}))
+	defer upstream.Close()
+
+	for _, stream := range []bool{true, false} {
+		report, err := Run(context.Background(), Options{
+			BaseURL:     upstream.URL + "/v1",
+			Model:       "mock-model",
+			Stream:      stream,
+			Concurrency: 3,
+			Requests:    9,
+		})
+		if err != nil {
+			t.Fatalf("stream=%t: %v", stream, err)
+		}
+		if report.Requests != 9 || report.Failures != 0 {
+			t.Fatalf("stream=%t: requests=%d failures=%d (%s)", stream, report.Requests, report.Failures, report.FirstError)
+		}
+		if report.CompletionTokens != 9*4 {
+			t.Fatalf("stream=%t: completion tokens = %d, want 36", stream, report.CompletionTokens)
+		}
+		if report.TTFT.P50 <= 0 || report.TTFT.P50 > report.Latency.P50 {
+			t.Fatalf("stream=%t: ttft %v, latency %v", stream, report.TTFT.P50, report.Latency.P50)
+		}
+		if len(report.Translation) != len(translationTargets) {
+			t.Fatalf("stream=%t: translation costs = %+v", stream, report.Translation)
+		}
+
+		var out bytes.Buffer
+		report.Write(&out)
+		if !strings.Contains(out.String(), "to claude:") {
+			t.Fatalf("report missing translation line:\n%s", out.String())
+		}
+	}
+}
+
+func TestRunReportsUpstreamErrors(t *testing.T) {
+	report, err := Run(context.Background(), Options{BaseURL: "http://127.0.0.1:1/v1", Model: "m", Requests: 2, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Failures != 2 || report.FirstError == "" {
+		t.Fatalf("failures=%d first error=%q", report.Failures, report.FirstError)
+	}
+}
@@ -0,0 +1,98 @@
+package budget
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/events"
+	log "github.com/sirupsen/logrus"
+)
+
+const alertTimeout = 10 * time.Second
+
+// Alert is sent when a subject's spend crosses one of its budget's alert thresholds.
+type Alert struct {
+	Event     string    `json:"event"`
+	Budget    string    `json:"budget"`
+	Subject   string    `json:"subject"`
+	Label     string    `json:"label,omitempty"`
+	Period    string    `json:"period"`
+	Threshold int       `json:"threshold_percent"`
+	SpentUSD  float64   `json:"spent_usd"`
+	LimitUSD  float64   `json:"limit_usd"`
+	HardStop  bool      `json:"hard_stop"`
+	Time      time.Time `json:"time"`
+}
+
+func (a Alert) summary() string {
+	who := a.Label
+	if who == "" {
+		who = a.Subject
+	}
+	return fmt.Sprintf("Budget %q for %s reached %d%%: $%.2f of $%.2f (%s)", a.Budget, who, a.Threshold, a.SpentUSD, a.LimitUSD, a.Period)
+}
+
+// deliver sends the alert to the configured webhook and email recipients in the background.
+func (t *Tracker) deliver(a Alert) {
+	t.mu.Lock()
+	cfg := t.cfg
+	t.mu.Unlock()
+	log.Warn(a.summary())
+	event := events.Event{Type: events.TypeBudgetThreshold, Summary: a.summary(), Data: a}
+	if strings.HasPrefix(a.Subject, "key:") {
+		event.KeyID, event.APIKey = strings.TrimPrefix(a.Subject, "key:"), a.Label
+	}
+	events.Publish(event)
+	if url := strings.TrimSpace(cfg.AlertWebhook); url != "" {
+		go func() {
+			if err := postAlert(url, a); err != nil {
+				log.Warnf("spend budgets: alert webhook: %v", err)
+			}
+		}()
+	}
+	if email := cfg.AlertEmail; strings.TrimSpace(email.SMTPAddr) != "" && len(email.To) > 0 {
+		go func() {
+			var auth smtp.Auth
+			if email.Username != "" {
+				host := email.SMTPAddr
+				if idx := strings.LastIndexByte(host, ':'); idx >= 0 {
+					host = host[:idx]
+				}
+				auth = smtp.PlainAuth("", email.Username, email.Password, host)
+			}
+			msg := "From: " + email.From + "\r\n" +
+				"To: " + strings.Join(email.To, ", ") + "\r\n" +
+				"Subject: " + a.summary() + "\r\n\r\n" +
+				a.summary() + "\r\n"
+			if err := smtp.SendMail(email.SMTPAddr, auth, email.From, email.To, []byte(msg)); err != nil {
+				log.Warnf("spend budgets: alert email: %v", err)
+			}
+		}()
+	}
+}
+
+func postAlert(url string, a Alert) error {
+	body, _ := json.Marshal(a)
+	ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+// Package budget tracks the estimated spend of client keys and tenants against the configured
+// spend budgets, raises alerts when thresholds are crossed and reports budgets that stop traffic.
+//
+// Spend is estimated from usage records with the pricing table. Counters are kept per budget,
+// subject and period and persisted to the state store when one is configured.
+package budget
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/events"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/state"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Period values accepted by SpendBudget.Period.
+const (
+	PeriodMonthly = "monthly"
+	PeriodDaily   = "daily"
+	PeriodTotal   = "total"
+)
+
+var defaultThresholds = []int{50, 80, 100}
+
+// Exceeded describes a hard-stop budget whose limit has been reached.
+type Exceeded struct {
+	Budget   string
+	Subject  string
+	SpentUSD float64
+	LimitUSD float64
+}
+
+// Status is the spend of one subject against one budget in the current period.
+type Status struct {
+	Budget    string  `json:"budget"`
+	Subject   string  `json:"subject"`
+	Label     string  `json:"label,omitempty"`
+	Period    string  `json:"period"`
+	SpentUSD  float64 `json:"spent_usd"`
+	LimitUSD  float64 `json:"limit_usd"`
+	Percent   float64 `json:"percent"`
+	HardStop  bool    `json:"hard_stop"`
+	Exhausted bool    `json:"exhausted"`
+}
+
+type counter struct {
+	Spent   float64 `json:"spent"`
+	Alerted int     `json:"alerted"`
+	Label   string  `json:"label,omitempty"`
+	loaded  bool
+}
+
+// Tracker accumulates spend for a set of budgets.
+type Tracker struct {
+	mu       sync.Mutex
+	cfg      config.SpendBudgetsConfig
+	counters map[string]*counter
+	notify   func(Alert)
+	now      func() time.Time
+}
+
+var defaultTracker = NewTracker()
+
+func init() {
+	coreusage.RegisterPlugin(defaultTracker)
+}
+
+// NewTracker returns an empty tracker that delivers alerts to the configured webhook and email.
+func NewTracker() *Tracker {
+	t := &Tracker{counters: make(map[string]*counter), now: time.Now}
+	t.notify = t.deliver
+	return t
+}
+
+// Default returns the process-wide tracker fed by usage records.
+func Default() *Tracker { return defaultTracker }
+
+// Configure replaces the process-wide budget configuration.
+func Configure(cfg config.SpendBudgetsConfig) { defaultTracker.Configure(cfg) }
+
+// Configure replaces the budget configuration. Accumulated spend is kept for budgets that keep
+// their name.
+func (t *Tracker) Configure(cfg config.SpendBudgetsConfig) {
+	t.mu.Lock()
+	t.cfg = cfg
+	t.mu.Unlock()
+}
+
+// subjects returns the subject IDs and display labels the budget applies to for a request made
+// with key on behalf of tenant.
+func subjects(b config.SpendBudget, key, tenant string) (ids, labels []string) {
+	if key != "" && matches(b.APIKeys, key) {
+		ids = append(ids, KeySubject(key))
+		labels = append(labels, util.HideAPIKey(key))
+	}
+	if tenant != "" && matches(b.Tenants, tenant) {
+		ids = append(ids, TenantSubject(tenant))
+		labels = append(labels, tenant)
+	}
+	return ids, labels
+}
+
+func matches(list []string, value string) bool {
+	for _, v := range list {
+		if v = strings.TrimSpace(v); v == "*" || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// KeySubject returns the subject ID of a client key. Keys are hashed so they are never stored;
+// the hash is the key_id published with live events.
+func KeySubject(key string) string {
+	return "key:" + events.KeyID(key)
+}
+
+// TenantSubject returns the subject ID of a tenant.
+func TenantSubject(tenant string) string { return "tenant:" + tenant }
+
+// periodKey names the period containing at, or "" for budgets that never reset.
+func periodKey(period string, at time.Time) string {
+	at = at.UTC()
+	switch strings.ToLower(strings.TrimSpace(period)) {
+	case PeriodDaily:
+		return at.Format("2006-01-02")
+	case PeriodTotal:
+		return "total"
+	default:
+		return at.Format("2006-01")
+	}
+}
+
+func retention(period string) time.Duration {
+	switch strings.ToLower(strings.TrimSpace(period)) {
+	case PeriodDaily:
+		return 2 * 24 * time.Hour
+	case PeriodTotal:
+		return 0
+	default:
+		return 62 * 24 * time.Hour
+	}
+}
+
+func counterKey(budget, subject, period string) string {
+	return budget + ":" + subject + ":" + period
+}
+
+// load returns the counter for key, reading it from the state store the first time. Callers
+// must hold t.mu; the lock is released while the store is read.
+func (t *Tracker) load(ctx context.Context, key string) *counter {
+	if c := t.counters[key]; c != nil && c.loaded {
+		return c
+	}
+	var persisted counter
+	if store := state.Default(); store != nil {
+		t.mu.Unlock()
+		raw, ok, err := store.Get(ctx, state.NamespaceSpendBudgets, key)
+		t.mu.Lock()
+		if err != nil {
+			log.Warnf("spend budgets: load counter: %v", err)
+		} else if ok {
+			_ = json.Unmarshal(raw, &persisted)
+		}
+	}
+	c := t.counters[key]
+	if c == nil {
+		c = &counter{}
+		t.counters[key] = c
+	}
+	if !c.loaded {
+		c.Spent += persisted.Spent
+		if persisted.Alerted > c.Alerted {
+			c.Alerted = persisted.Alerted
+		}
+		if c.Label == "" {
+			c.Label = persisted.Label
+		}
+		c.loaded = true
+	}
+	return c
+}
+
+func persist(ctx context.Context, key string, c counter, ttl time.Duration) {
+	store := state.Default()
+	if store == nil {
+		return
+	}
+	raw, _ := json.Marshal(c)
+	if err := store.Put(ctx, state.NamespaceSpendBudgets, key, raw, ttl); err != nil {
+		log.Warnf("spend budgets: persist counter: %v", err)
+	}
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (t *Tracker) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if record.APIKey == "" && record.Tenant == "" {
+		return
+	}
+	cost, ok := pricing.Cost(record.Model, record.Detail)
+	if !ok || cost <= 0 {
+		return
+	}
+	at := record.RequestedAt
+	if at.IsZero() {
+		at = t.now()
+	}
+	// The request may already be finished; counters must still be persisted.
+	t.add(context.WithoutCancel(ctx), record.APIKey, record.Tenant, at, cost)
+}
+
+func (t *Tracker) add(ctx context.Context, key, tenant string, at time.Time, cost float64) {
+	t.mu.Lock()
+	budgets := t.cfg.Budgets
+	var alerts []Alert
+	for _, b := range budgets {
+		if b.LimitUSD <= 0 {
+			continue
+		}
+		ids, labels := subjects(b, key, tenant)
+		period := periodKey(b.Period, at)
+		for i, id := range ids {
+			ck := counterKey(b.Name, id, period)
+			c := t.load(ctx, ck)
+			c.Spent += cost
+			c.Label = labels[i]
+			if threshold := crossed(b, c); threshold > 0 {
+				alerts = append(alerts, Alert{
+					Event: "budget.threshold_crossed", Budget: b.Name, Subject: id, Label: c.Label,
+					Period: period, Threshold: threshold, SpentUSD: c.Spent, LimitUSD: b.LimitUSD,
+					HardStop: b.HardStop, Time: at.UTC(),
+				})
+			}
+			snapshot := *c
+			t.mu.Unlock()
+			persist(ctx, ck, snapshot, retention(b.Period))
+			t.mu.Lock()
+		}
+	}
+	notify := t.notify
+	t.mu.Unlock()
+	for _, a := range alerts {
+		notify(a)
+	}
+}
+
+// crossed records and returns the highest alert threshold the counter newly reached, or 0.
+func crossed(b config.SpendBudget, c *counter) int {
+	thresholds := b.AlertThresholds
+	if len(thresholds) == 0 {
+		thresholds = defaultThresholds
+	}
+	percent := c.Spent / b.LimitUSD * 100
+	highest := 0
+	for _, th := range thresholds {
+		if th > c.Alerted && float64(th) <= percent && th > highest {
+			highest = th
+		}
+	}
+	if highest > 0 {
+		c.Alerted = highest
+	}
+	return highest
+}
+
+// Check returns the first hard-stop budget that the key or tenant has exhausted, or nil.
+func Check(ctx context.Context, key, tenant string) *Exceeded {
+	return defaultTracker.Check(ctx, key, tenant)
+}
+
+// Check returns the first hard-stop budget that the key or tenant has exhausted, or nil.
+func (t *Tracker) Check(ctx context.Context, key, tenant string) *Exceeded {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for _, b := range t.cfg.Budgets {
+		if !b.HardStop || b.LimitUSD <= 0 {
+			continue
+		}
+		ids, _ := subjects(b, key, tenant)
+		for _, id := range ids {
+			c := t.load(ctx, counterKey(b.Name, id, periodKey(b.Period, now)))
+			if c.Spent >= b.LimitUSD {
+				return &Exceeded{Budget: b.Name, Subject: id, SpentUSD: c.Spent, LimitUSD: b.LimitUSD}
+			}
+		}
+	}
+	return nil
+}
+
+// Snapshot reports the current-period spend of every subject seen for the configured budgets.
+func (t *Tracker) Snapshot() []Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	out := []Status{}
+	for _, b := range t.cfg.Budgets {
+		period := periodKey(b.Period, now)
+		prefix := b.Name + ":"
+		suffix := ":" + period
+		for key, c := range t.counters {
+			if !strings.HasPrefix(key, prefix) || !strings.HasSuffix(key, suffix) {
+				continue
+			}
+			subject := strings.TrimSuffix(strings.TrimPrefix(key, prefix), suffix)
+			s := Status{
+				Budget: b.Name, Subject: subject, Label: c.Label, Period: period,
+				SpentUSD: c.Spent, LimitUSD: b.LimitUSD, HardStop: b.HardStop,
+			}
+			if b.LimitUSD > 0 {
+				s.Percent = c.Spent / b.LimitUSD * 100
+				s.Exhausted = c.Spent >= b.LimitUSD
+			}
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Budget != out[j].Budget {
+			return out[i].Budget < out[j].Budget
+		}
+		return out[i].Subject < out[j].Subject
+	})
+	return out
+}
+
+// Reset clears the current-period spend and alert state of budget. An empty subject resets
+// every subject of the budget. It reports how many counters were reset and whether the budget
+// exists.
+func (t *Tracker) Reset(ctx context.Context, budget, subject string) (int, bool) {
+	t.mu.Lock()
+	var target *config.SpendBudget
+	for i := range t.cfg.Budgets {
+		if t.cfg.Budgets[i].Name == budget {
+			target = &t.cfg.Budgets[i]
+			break
+		}
+	}
+	if target == nil {
+		t.mu.Unlock()
+		return 0, false
+	}
+	period := periodKey(target.Period, t.now())
+	ttl := retention(target.Period)
+	var keys []string
+	if subject != "" {
+		keys = append(keys, counterKey(budget, subject, period))
+	} else {
+		prefix, suffix := budget+":", ":"+period
+		for key := range t.counters {
+			if strings.HasPrefix(key, prefix) && strings.HasSuffix(key, suffix) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	cleared := make(map[string]counter, len(keys))
+	for _, key := range keys {
+		c := t.counters[key]
+		if c == nil {
+			c = &counter{}
+			t.counters[key] = c
+		}
+		c.Spent, c.Alerted, c.loaded = 0, 0, true
+		cleared[key] = *c
+	}
+	t.mu.Unlock()
+	for key, c := range cleared {
+		persist(ctx, key, c, ttl)
+	}
+	return len(cleared), true
+}
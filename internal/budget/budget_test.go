@@ -0,0 +1,86 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func newTestTracker(cfg config.SpendBudgetsConfig) (*Tracker, *[]Alert) {
+	pricing.Configure([]config.ModelPrice{{Model: "m", Input: 1, Output: 1}})
+	t := NewTracker()
+	t.now = func() time.Time { return time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC) }
+	var alerts []Alert
+	t.notify = func(a Alert) { alerts = append(alerts, a) }
+	t.Configure(cfg)
+	return t, &alerts
+}
+
+// spend records usage costing usd dollars under the test price of $1 per million tokens.
+func spend(t *Tracker, key, tenant string, usd float64) {
+	t.HandleUsage(context.Background(), coreusage.Record{
+		APIKey: key, Tenant: tenant, Model: "m", RequestedAt: t.now(),
+		Detail: coreusage.Detail{InputTokens: int64(usd * 1e6)},
+	})
+}
+
+func TestThresholdAlertsFireOncePerPeriod(t *testing.T) {
+	defer pricing.Configure(nil)
+	tr, alerts := newTestTracker(config.SpendBudgetsConfig{Budgets: []config.SpendBudget{
+		{Name: "team", APIKeys: []string{"*"}, LimitUSD: 10},
+	}})
+	spend(tr, "k1", "", 4)
+	if len(*alerts) != 0 {
+		t.Fatalf("unexpected alerts below 50%%: %+v", *alerts)
+	}
+	spend(tr, "k1", "", 4.5)
+	if len(*alerts) != 1 || (*alerts)[0].Threshold != 80 {
+		t.Fatalf("alerts after 85%% = %+v; want one at 80", *alerts)
+	}
+	spend(tr, "k1", "", 0.1)
+	if len(*alerts) != 1 {
+		t.Fatalf("threshold alert repeated: %+v", *alerts)
+	}
+	spend(tr, "k2", "", 6)
+	if len(*alerts) != 2 || (*alerts)[1].Subject != KeySubject("k2") {
+		t.Fatalf("each key should have its own spend: %+v", *alerts)
+	}
+}
+
+func TestHardStopAndReset(t *testing.T) {
+	defer pricing.Configure(nil)
+	tr, _ := newTestTracker(config.SpendBudgetsConfig{Budgets: []config.SpendBudget{
+		{Name: "soft", Tenants: []string{"acme"}, LimitUSD: 1},
+		{Name: "hard", Tenants: []string{"acme"}, LimitUSD: 5, HardStop: true, Period: PeriodDaily},
+	}})
+	ctx := context.Background()
+	spend(tr, "k", "acme", 3)
+	if ex := tr.Check(ctx, "k", "acme"); ex != nil {
+		t.Fatalf("soft budget must not stop traffic: %+v", ex)
+	}
+	spend(tr, "k", "acme", 2)
+	ex := tr.Check(ctx, "k", "acme")
+	if ex == nil || ex.Budget != "hard" || ex.Subject != TenantSubject("acme") {
+		t.Fatalf("Check = %+v; want hard budget exceeded for tenant", ex)
+	}
+	if tr.Check(ctx, "k", "other") != nil {
+		t.Fatal("other tenants are not limited")
+	}
+	if n, ok := tr.Reset(ctx, "hard", TenantSubject("acme")); !ok || n != 1 {
+		t.Fatalf("Reset = %d, %v", n, ok)
+	}
+	if ex := tr.Check(ctx, "k", "acme"); ex != nil {
+		t.Fatalf("Check after reset = %+v", ex)
+	}
+	if _, ok := tr.Reset(ctx, "missing", ""); ok {
+		t.Fatal("resetting an unknown budget should fail")
+	}
+	snapshot := tr.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].Budget != "hard" || snapshot[0].SpentUSD != 0 || snapshot[1].SpentUSD != 5 {
+		t.Fatalf("Snapshot = %+v", snapshot)
+	}
+}
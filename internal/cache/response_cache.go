@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/state"
+)
+
+const (
+	// DefaultResponseCacheTTL is applied when no TTL is configured.
+	DefaultResponseCacheTTL = 5 * time.Minute
+
+	// DefaultResponseCacheMaxEntries bounds the memory backend when no limit is configured.
+	DefaultResponseCacheMaxEntries = 1000
+
+	// DefaultResponseCacheRedisPrefix namespaces redis keys when no prefix is configured.
+	DefaultResponseCacheRedisPrefix = "cliproxy:response-cache:"
+)
+
+// ResponseStore persists cached response payloads keyed by an opaque string.
+type ResponseStore interface {
+	// Get returns the cached payload for key and whether it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores payload under key for the given TTL.
+	Set(ctx context.Context, key string, payload []byte, ttl time.Duration) error
+	// Close releases backend resources.
+	Close() error
+}
+
+type memoryResponseEntry struct {
+	key       string
+	payload   []byte
+	expiresAt time.Time
+}
+
+// MemoryResponseStore is an in-process LRU response store with per-entry expiry.
+type MemoryResponseStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+}
+
+// NewMemoryResponseStore constructs an LRU store bounded to maxEntries.
+func NewMemoryResponseStore(maxEntries int) *MemoryResponseStore {
+	if maxEntries <= 0 {
+		maxEntries = DefaultResponseCacheMaxEntries
+	}
+	return &MemoryResponseStore{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements ResponseStore.
+func (s *MemoryResponseStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := elem.Value.(*memoryResponseEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		s.order.Remove(elem)
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(elem)
+	out := make([]byte, len(entry.payload))
+	copy(out, entry.payload)
+	return out, true, nil
+}
+
+// Set implements ResponseStore.
+func (s *MemoryResponseStore) Set(_ context.Context, key string, payload []byte, ttl time.Duration) error {
+	cloned := make([]byte, len(payload))
+	copy(cloned, payload)
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*memoryResponseEntry)
+		entry.payload = cloned
+		entry.expiresAt = expiresAt
+		s.order.MoveToFront(elem)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(&memoryResponseEntry{key: key, payload: cloned, expiresAt: expiresAt})
+	for s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		if oldest == nil {
+			break
+		}
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryResponseEntry).key)
+	}
+	return nil
+}
+
+// Len reports the number of entries currently held, including expired ones not yet evicted.
+func (s *MemoryResponseStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// Close implements ResponseStore.
+func (s *MemoryResponseStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.order.Init()
+	s.entries = make(map[string]*list.Element)
+	return nil
+}
+
+// RedisResponseStoreConfig captures connection settings for RedisResponseStore.
+type RedisResponseStoreConfig struct {
+	Addr      string
+	Password  string
+	DB        int
+	KeyPrefix string
+}
+
+// RedisResponseStore shares cached responses across proxy instances through redis.
+type RedisResponseStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisResponseStore constructs a redis-backed response store.
+func NewRedisResponseStore(cfg RedisResponseStoreConfig) (*RedisResponseStore, error) {
+	addr := strings.TrimSpace(cfg.Addr)
+	if addr == "" {
+		return nil, errors.New("response cache: redis addr is required")
+	}
+	prefix := cfg.KeyPrefix
+	if prefix == "" {
+		prefix = DefaultResponseCacheRedisPrefix
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return &RedisResponseStore{client: client, prefix: prefix}, nil
+}
+
+// Get implements ResponseStore.
+func (s *RedisResponseStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	payload, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return payload, true, nil
+}
+
+// Set implements ResponseStore.
+func (s *RedisResponseStore) Set(ctx context.Context, key string, payload []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, payload, ttl).Err()
+}
+
+// Close implements ResponseStore.
+func (s *RedisResponseStore) Close() error {
+	return s.client.Close()
+}
+
+// StateResponseStore keeps cached responses in the durable state store.
+type StateResponseStore struct {
+	store state.Store
+}
+
+// NewStateResponseStore wraps store as a response cache backend.
+func NewStateResponseStore(store state.Store) *StateResponseStore {
+	return &StateResponseStore{store: store}
+}
+
+// Get implements ResponseStore.
+func (s *StateResponseStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return s.store.Get(ctx, state.NamespaceResponseCache, key)
+}
+
+// Set implements ResponseStore.
+func (s *StateResponseStore) Set(ctx context.Context, key string, payload []byte, ttl time.Duration) error {
+	return s.store.Put(ctx, state.NamespaceResponseCache, key, payload, ttl)
+}
+
+// Close implements ResponseStore. The shared state store is owned by the service.
+func (s *StateResponseStore) Close() error { return nil }
@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// DefaultSemanticCacheThreshold is the minimum cosine similarity for a hit when none is configured.
+	DefaultSemanticCacheThreshold = 0.95
+
+	// DefaultSemanticCacheTTL is applied when no TTL is configured.
+	DefaultSemanticCacheTTL = time.Hour
+
+	// DefaultSemanticCacheMaxEntries bounds each partition when no limit is configured.
+	DefaultSemanticCacheMaxEntries = 500
+
+	// DefaultOpenAIEmbeddingModel is used by OpenAIEmbedder when no model is configured.
+	DefaultOpenAIEmbeddingModel = "text-embedding-3-small"
+
+	hashingEmbedderDimensions = 512
+)
+
+// Embedder converts prompt text into a vector suitable for cosine similarity.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// HashingEmbedder produces normalised feature-hashed vectors from word unigrams and bigrams.
+// It needs no network access and is good at matching prompts that differ only in casing,
+// punctuation or a few words, which covers most FAQ-style traffic.
+type HashingEmbedder struct{}
+
+// Embed implements Embedder.
+func (HashingEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	vec := make([]float32, hashingEmbedderDimensions)
+	add := func(feature string, weight float32) {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(feature))
+		sum := h.Sum64()
+		idx := sum % hashingEmbedderDimensions
+		if sum&(1<<63) != 0 {
+			weight = -weight
+		}
+		vec[idx] += weight
+	}
+	for i, word := range words {
+		add(word, 1)
+		if i > 0 {
+			add(words[i-1]+" "+word, 0.5)
+		}
+	}
+	return normalizeVector(vec), nil
+}
+
+// OpenAIEmbedder calls an OpenAI-compatible /embeddings endpoint.
+type OpenAIEmbedder struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Client  *http.Client
+}
+
+// Embed implements Embedder.
+func (e *OpenAIEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	baseURL := strings.TrimRight(strings.TrimSpace(e.BaseURL), "/")
+	if baseURL == "" {
+		return nil, errors.New("semantic cache: embedding base-url is required")
+	}
+	model := strings.TrimSpace(e.Model)
+	if model == "" {
+		model = DefaultOpenAIEmbeddingModel
+	}
+	body, err := json.Marshal(map[string]any{"model": model, "input": text})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("semantic cache: embedding request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var parsed struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err = json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("semantic cache: decode embedding response: %w", err)
+	}
+	if len(parsed.Data) == 0 || len(parsed.Data[0].Embedding) == 0 {
+		return nil, errors.New("semantic cache: embedding response contained no vectors")
+	}
+	return normalizeVector(parsed.Data[0].Embedding), nil
+}
+
+func normalizeVector(vec []float32) []float32 {
+	var sum float64
+	for _, v := range vec {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vec
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vec {
+		vec[i] /= norm
+	}
+	return vec
+}
+
+// cosine assumes both vectors are already normalised.
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+type semanticEntry struct {
+	vector    []float32
+	payload   []byte
+	expiresAt time.Time
+}
+
+// SemanticCache stores responses alongside prompt embeddings and returns the closest cached
+// response within a partition (typically handler type + model) above a similarity threshold.
+type SemanticCache struct {
+	mu         sync.Mutex
+	threshold  float64
+	maxEntries int
+	partitions map[string][]*semanticEntry
+}
+
+// NewSemanticCache constructs a semantic cache. Non-positive arguments fall back to defaults.
+func NewSemanticCache(threshold float64, maxEntries int) *SemanticCache {
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultSemanticCacheThreshold
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultSemanticCacheMaxEntries
+	}
+	return &SemanticCache{
+		threshold:  threshold,
+		maxEntries: maxEntries,
+		partitions: make(map[string][]*semanticEntry),
+	}
+}
+
+// Lookup returns the payload of the most similar live entry and its similarity score.
+func (c *SemanticCache) Lookup(partition string, vector []float32) ([]byte, float64, bool) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := c.partitions[partition]
+	live := entries[:0]
+	var best *semanticEntry
+	bestScore := 0.0
+	for _, entry := range entries {
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			continue
+		}
+		live = append(live, entry)
+		if score := cosine(vector, entry.vector); score > bestScore {
+			best, bestScore = entry, score
+		}
+	}
+	for i := len(live); i < len(entries); i++ {
+		entries[i] = nil
+	}
+	c.partitions[partition] = live
+	if best == nil || bestScore < c.threshold {
+		return nil, bestScore, false
+	}
+	out := make([]byte, len(best.payload))
+	copy(out, best.payload)
+	return out, bestScore, true
+}
+
+// Store records payload under vector, evicting the oldest entries beyond the partition bound.
+func (c *SemanticCache) Store(partition string, vector []float32, payload []byte, ttl time.Duration) {
+	entry := &semanticEntry{vector: vector, payload: append([]byte(nil), payload...)}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := append(c.partitions[partition], entry)
+	if over := len(entries) - c.maxEntries; over > 0 {
+		entries = append(entries[:0:0], entries[over:]...)
+	}
+	c.partitions[partition] = entries
+}
+
+// Len reports the total number of entries across partitions.
+func (c *SemanticCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	total := 0
+	for _, entries := range c.partitions {
+		total += len(entries)
+	}
+	return total
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSemanticCache_NearDuplicateHit(t *testing.T) {
+	embedder := HashingEmbedder{}
+	ctx := context.Background()
+	c := NewSemanticCache(0.8, 10)
+
+	stored, _ := embedder.Embed(ctx, "How do I reset my VPN password?")
+	c.Store("openai|m", stored, []byte(`{"answer":1}`), time.Minute)
+
+	near, _ := embedder.Embed(ctx, "how do i reset my vpn password")
+	if payload, score, ok := c.Lookup("openai|m", near); !ok || string(payload) != `{"answer":1}` {
+		t.Fatalf("expected near-duplicate hit, got ok=%v score=%.3f", ok, score)
+	}
+
+	far, _ := embedder.Embed(ctx, "What is the cafeteria menu for Friday?")
+	if _, score, ok := c.Lookup("openai|m", far); ok {
+		t.Fatalf("expected miss for unrelated prompt, got score %.3f", score)
+	}
+
+	if _, _, ok := c.Lookup("openai|other", near); ok {
+		t.Fatal("expected partitions to be isolated")
+	}
+}
+
+func TestSemanticCache_ExpiryAndBound(t *testing.T) {
+	embedder := HashingEmbedder{}
+	ctx := context.Background()
+	c := NewSemanticCache(0.9, 2)
+
+	for _, prompt := range []string{"alpha", "beta", "gamma"} {
+		vec, _ := embedder.Embed(ctx, prompt)
+		c.Store("p", vec, []byte(prompt), time.Minute)
+	}
+	if got := c.Len(); got != 2 {
+		t.Fatalf("Len() = %d, want 2", got)
+	}
+	oldest, _ := embedder.Embed(ctx, "alpha")
+	if _, _, ok := c.Lookup("p", oldest); ok {
+		t.Fatal("expected oldest entry to be evicted")
+	}
+
+	expired, _ := embedder.Embed(ctx, "delta")
+	c.Store("q", expired, []byte("delta"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, _, ok := c.Lookup("q", expired); ok {
+		t.Fatal("expected expired entry to miss")
+	}
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// Gemini attaches an opaque thoughtSignature to the functionCall parts of thinking models and
+// rejects follow-up turns that replay the call without it. Claude and OpenAI clients have no
+// field to carry the signature back, so it is remembered here keyed by the tool call ID the
+// client was given and restored when the client sends that call back in its history.
+
+type thoughtSignatureEntry struct {
+	key       string
+	signature string
+	expiresAt time.Time
+}
+
+var (
+	thoughtSignatureTTL        = 2 * time.Hour
+	thoughtSignatureMaxEntries = 10000
+
+	thoughtSignatureMu    sync.Mutex
+	thoughtSignatures     = make(map[string]*list.Element)
+	thoughtSignatureOrder = list.New()
+)
+
+// CacheToolCallThoughtSignature remembers the Gemini thoughtSignature of the function call that
+// was surfaced to the client as toolCallID.
+func CacheToolCallThoughtSignature(toolCallID, signature string) {
+	toolCallID = strings.TrimSpace(toolCallID)
+	if toolCallID == "" || signature == "" {
+		return
+	}
+
+	if store := state.Default(); store != nil {
+		if err := store.Put(context.Background(), state.NamespaceThoughtSigs, toolCallID, []byte(signature), thoughtSignatureTTL); err != nil {
+			log.Debugf("failed to persist thought signature: %v", err)
+		}
+	}
+	storeThoughtSignature(toolCallID, signature, time.Now())
+}
+
+func storeThoughtSignature(key, signature string, now time.Time) {
+	thoughtSignatureMu.Lock()
+	defer thoughtSignatureMu.Unlock()
+
+	if elem, ok := thoughtSignatures[key]; ok {
+		entry := elem.Value.(*thoughtSignatureEntry)
+		entry.signature = signature
+		entry.expiresAt = now.Add(thoughtSignatureTTL)
+		thoughtSignatureOrder.MoveToFront(elem)
+		return
+	}
+	thoughtSignatures[key] = thoughtSignatureOrder.PushFront(&thoughtSignatureEntry{
+		key:       key,
+		signature: signature,
+		expiresAt: now.Add(thoughtSignatureTTL),
+	})
+
+	// Evict expired entries from the tail, then enforce the LRU bound.
+	for elem := thoughtSignatureOrder.Back(); elem != nil; elem = thoughtSignatureOrder.Back() {
+		entry := elem.Value.(*thoughtSignatureEntry)
+		if thoughtSignatureOrder.Len() <= thoughtSignatureMaxEntries && !now.After(entry.expiresAt) {
+			break
+		}
+		thoughtSignatureOrder.Remove(elem)
+		delete(thoughtSignatures, entry.key)
+	}
+}
+
+// GetToolCallThoughtSignature returns the thoughtSignature cached for toolCallID, or "" when
+// none is known.
+func GetToolCallThoughtSignature(toolCallID string) string {
+	toolCallID = strings.TrimSpace(toolCallID)
+	if toolCallID == "" {
+		return ""
+	}
+
+	now := time.Now()
+	thoughtSignatureMu.Lock()
+	if elem, ok := thoughtSignatures[toolCallID]; ok {
+		entry := elem.Value.(*thoughtSignatureEntry)
+		if !now.After(entry.expiresAt) {
+			thoughtSignatureOrder.MoveToFront(elem)
+			thoughtSignatureMu.Unlock()
+			return entry.signature
+		}
+		thoughtSignatureOrder.Remove(elem)
+		delete(thoughtSignatures, toolCallID)
+	}
+	thoughtSignatureMu.Unlock()
+
+	// Fall back to the durable store so signatures survive restarts and are shared between instances.
+	if store := state.Default(); store != nil {
+		value, found, err := store.Get(context.Background(), state.NamespaceThoughtSigs, toolCallID)
+		if err != nil {
+			log.Debugf("failed to read thought signature: %v", err)
+		}
+		if found && len(value) > 0 {
+			storeThoughtSignature(toolCallID, string(value), now)
+			return string(value)
+		}
+	}
+	return ""
+}
+
+// ToolCallThoughtSignature returns the signature to send with a replayed function call: the
+// cached one when the call came from Gemini through this proxy, otherwise fallback.
+func ToolCallThoughtSignature(toolCallID, fallback string) string {
+	if signature := GetToolCallThoughtSignature(toolCallID); signature != "" {
+		return signature
+	}
+	return fallback
+}
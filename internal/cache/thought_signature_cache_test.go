@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToolCallThoughtSignature(t *testing.T) {
+	CacheToolCallThoughtSignature("get_weather-1-1", "sig-weather")
+
+	if got := GetToolCallThoughtSignature("get_weather-1-1"); got != "sig-weather" {
+		t.Fatalf("cached signature = %q", got)
+	}
+	if got := ToolCallThoughtSignature("unknown", "skip_thought_signature_validator"); got != "skip_thought_signature_validator" {
+		t.Fatalf("fallback = %q", got)
+	}
+	if got := ToolCallThoughtSignature("get_weather-1-1", "skip_thought_signature_validator"); got != "sig-weather" {
+		t.Fatalf("resolved signature = %q", got)
+	}
+}
+
+func TestThoughtSignatureEviction(t *testing.T) {
+	prevMax := thoughtSignatureMaxEntries
+	thoughtSignatureMaxEntries = 2
+	defer func() { thoughtSignatureMaxEntries = prevMax }()
+
+	now := time.Now()
+	storeThoughtSignature("evict-a", "a", now)
+	storeThoughtSignature("evict-b", "b", now)
+	storeThoughtSignature("evict-c", "c", now)
+
+	if got := GetToolCallThoughtSignature("evict-a"); got != "" {
+		t.Fatalf("oldest entry not evicted: %q", got)
+	}
+	if got := GetToolCallThoughtSignature("evict-c"); got != "c" {
+		t.Fatalf("newest entry = %q", got)
+	}
+
+	storeThoughtSignature("expired", "x", now.Add(-2*thoughtSignatureTTL))
+	if got := GetToolCallThoughtSignature("expired"); got != "" {
+		t.Fatalf("expired entry returned: %q", got)
+	}
+}
@@ -0,0 +1,321 @@
+// Package cassette records upstream HTTP exchanges to files and replays them. Recordings are
+// sanitized before they are written: credential headers, credential query parameters and token
+// fields in JSON or form bodies are replaced, so cassettes can be committed as test fixtures.
+package cassette
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// ModeRecord calls the upstream and saves every exchange.
+	ModeRecord = "record"
+	// ModeReplay serves saved exchanges and fails on a miss.
+	ModeReplay = "replay"
+	// ModeAuto replays when a recording exists and records otherwise.
+	ModeAuto = "auto"
+
+	// Redacted replaces sanitized values.
+	Redacted = "REDACTED"
+
+	formatVersion = 1
+)
+
+// ErrNotRecorded is returned by replay transports when no cassette matches a request.
+var ErrNotRecorded = errors.New("cassette: no recording for request")
+
+var (
+	defaultRedactHeaders = []string{
+		"Authorization", "Proxy-Authorization", "X-Api-Key", "Api-Key", "X-Goog-Api-Key",
+		"Cookie", "Set-Cookie", "Chatgpt-Account-Id",
+	}
+	redactQueryParams = map[string]bool{"key": true, "api_key": true, "access_token": true, "token": true}
+	redactBodyFields  = map[string]bool{
+		"access_token": true, "refresh_token": true, "id_token": true, "client_secret": true,
+		"api_key": true, "apiKey": true, "password": true, "private_key": true,
+	}
+)
+
+// Interaction is one recorded request/response pair.
+type Interaction struct {
+	Version    int       `json:"version"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Request    Request   `json:"request"`
+	Response   Response  `json:"response"`
+}
+
+// Request is the sanitized upstream request.
+type Request struct {
+	Method  string          `json:"method"`
+	URL     string          `json:"url"`
+	Headers http.Header     `json:"headers,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is the upstream response. Chunks are the body as it arrived, each stamped with its
+// offset from the response headers, so streams can be replayed with their original pacing.
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Chunks  []Chunk     `json:"chunks"`
+}
+
+// Chunk is one read of the response body.
+type Chunk struct {
+	OffsetMs int64  `json:"offset_ms"`
+	Data     string `json:"data"`
+	// Base64 marks Data as base64 encoded, used for compressed or otherwise binary bodies.
+	Base64 bool `json:"base64,omitempty"`
+}
+
+// Bytes decodes the chunk payload.
+func (c Chunk) Bytes() []byte {
+	if !c.Base64 {
+		return []byte(c.Data)
+	}
+	data, err := base64.StdEncoding.DecodeString(c.Data)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func newChunk(offset time.Duration, data []byte) Chunk {
+	if utf8.Valid(data) {
+		return Chunk{OffsetMs: offset.Milliseconds(), Data: string(data)}
+	}
+	return Chunk{OffsetMs: offset.Milliseconds(), Data: base64.StdEncoding.EncodeToString(data), Base64: true}
+}
+
+// Body concatenates the recorded response chunks.
+func (r Response) Body() []byte {
+	var out []byte
+	for _, chunk := range r.Chunks {
+		out = append(out, chunk.Bytes()...)
+	}
+	return out
+}
+
+// Load reads a cassette file.
+func Load(path string) (*Interaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interaction Interaction
+	if err = json.Unmarshal(data, &interaction); err != nil {
+		return nil, fmt.Errorf("cassette: parse %s: %w", path, err)
+	}
+	return &interaction, nil
+}
+
+// Save writes a cassette file atomically.
+func Save(path string, interaction *Interaction) error {
+	data, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Options configures the recording and replay transports.
+type Options struct {
+	Mode string
+	Dir  string
+	// ReplayTiming paces replayed chunks with their recorded offsets.
+	ReplayTiming bool
+	// RedactHeaders extends the built-in credential header list.
+	RedactHeaders []string
+	// IgnoreFields are JSON body paths excluded from the match key.
+	IgnoreFields []string
+}
+
+func (o Options) redactHeader(name string) bool {
+	for _, header := range defaultRedactHeaders {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+	for _, header := range o.RedactHeaders {
+		if strings.EqualFold(strings.TrimSpace(header), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Options) sanitizeHeaders(header http.Header) http.Header {
+	out := make(http.Header, len(header))
+	for name, values := range header {
+		if o.redactHeader(name) {
+			out[name] = []string{Redacted}
+			continue
+		}
+		out[name] = append([]string(nil), values...)
+	}
+	return out
+}
+
+// SanitizeURL replaces credential query parameters and strips user info.
+func SanitizeURL(u *url.URL) string {
+	clean := *u
+	clean.User = nil
+	query := clean.Query()
+	for name := range query {
+		if redactQueryParams[strings.ToLower(name)] {
+			query.Set(name, Redacted)
+		}
+	}
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
+
+// SanitizeBody redacts credential fields in JSON and form encoded bodies. Other bodies are
+// returned unchanged.
+func SanitizeBody(body []byte) []byte {
+	trimmed := strings.TrimSpace(string(body))
+	if trimmed == "" {
+		return body
+	}
+	if gjson.Valid(trimmed) {
+		out := body
+		walkJSON(gjson.ParseBytes(body), "", func(path string) {
+			if updated, err := sjson.SetBytes(out, path, Redacted); err == nil {
+				out = updated
+			}
+		})
+		return out
+	}
+	if values, err := url.ParseQuery(trimmed); err == nil && strings.Contains(trimmed, "=") {
+		changed := false
+		for name := range values {
+			if redactBodyFields[name] {
+				values.Set(name, Redacted)
+				changed = true
+			}
+		}
+		if changed {
+			return []byte(values.Encode())
+		}
+	}
+	return body
+}
+
+// walkJSON reports the paths of sensitive string fields anywhere in value.
+func walkJSON(value gjson.Result, prefix string, redact func(path string)) {
+	if !value.IsObject() && !value.IsArray() {
+		return
+	}
+	index := 0
+	value.ForEach(func(key, child gjson.Result) bool {
+		var path string
+		if value.IsArray() {
+			path = joinPath(prefix, fmt.Sprint(index))
+			index++
+		} else {
+			path = joinPath(prefix, escapePath(key.String()))
+			if redactBodyFields[key.String()] && child.Type == gjson.String {
+				redact(path)
+				return true
+			}
+		}
+		walkJSON(child, path, redact)
+		return true
+	})
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func escapePath(key string) string {
+	replacer := strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`, "|", `\|`, "#", `\#`, "@", `\@`)
+	return replacer.Replace(key)
+}
+
+// key derives the file name for a sanitized request.
+func (o Options) key(method, sanitizedURL string, sanitizedBody []byte) string {
+	body := sanitizedBody
+	for _, field := range o.IgnoreFields {
+		if updated, err := sjson.DeleteBytes(body, strings.TrimSpace(field)); err == nil {
+			body = updated
+		}
+	}
+	h := sha256.New()
+	_, _ = h.Write([]byte(method))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(sanitizedURL))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(canonicalJSON(body))
+	return hex.EncodeToString(h.Sum(nil))[:24]
+}
+
+// canonicalJSON re-encodes JSON bodies with sorted object keys so field order does not affect
+// matching. Non-JSON bodies are used verbatim.
+func canonicalJSON(body []byte) []byte {
+	if len(body) == 0 || !gjson.ValidBytes(body) {
+		return body
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return body
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// path returns the cassette file for a request, named after the upstream host for readability.
+func (o Options) path(u *url.URL, key string) string {
+	host := strings.NewReplacer(":", "_", "/", "_").Replace(u.Host)
+	if host == "" {
+		host = "upstream"
+	}
+	return filepath.Join(o.dir(), host+"-"+key+".json")
+}
+
+func (o Options) dir() string {
+	if strings.TrimSpace(o.Dir) == "" {
+		return "cassettes"
+	}
+	return o.Dir
+}
+
+// bodyJSON stores a request body as JSON when it is JSON and as a JSON string otherwise.
+func bodyJSON(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if gjson.ValidBytes(body) {
+		return json.RawMessage(body)
+	}
+	encoded, _ := json.Marshal(string(body))
+	return encoded
+}
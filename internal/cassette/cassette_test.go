@@ -0,0 +1,124 @@
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sseUpstream(t *testing.T, calls *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			_, _ = fmt.Fprintf(w, "data: {\"n\":%d}\n\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+}
+
+func roundTrip(t *testing.T, rt http.RoundTripper, url, body string) (string, error) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-secret")
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	calls := 0
+	upstream := sseUpstream(t, &calls)
+	dir := t.TempDir()
+	url := upstream.URL + "/v1/chat?key=secret-key"
+	body := `{"model":"m","stream":true,"session":"a"}`
+
+	recorder := NewTransport(Options{Mode: ModeRecord, Dir: dir}, http.DefaultTransport)
+	recorded, err := roundTrip(t, recorder, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("cassette files = %v", files)
+	}
+	raw, _ := os.ReadFile(files[0])
+	if strings.Contains(string(raw), "sk-secret") || strings.Contains(string(raw), "secret-key") {
+		t.Fatalf("cassette leaks credentials:\n%s", raw)
+	}
+	interaction, err := Load(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(interaction.Response.Chunks) < 2 || interaction.Response.Chunks[len(interaction.Response.Chunks)-1].OffsetMs < 5 {
+		t.Fatalf("stream timing not captured: %+v", interaction.Response.Chunks)
+	}
+
+	upstream.Close()
+	replayer := NewTransport(Options{Mode: ModeReplay, Dir: dir, ReplayTiming: true}, http.DefaultTransport)
+	start := time.Now()
+	replayed, err := roundTrip(t, replayer, url, `{"stream":true, "model":"m","session":"a"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != recorded {
+		t.Fatalf("replayed %q, recorded %q", replayed, recorded)
+	}
+	if time.Since(start) < 5*time.Millisecond {
+		t.Fatal("replay timing was not applied")
+	}
+	if calls != 1 {
+		t.Fatalf("upstream calls = %d, want 1", calls)
+	}
+
+	if _, err = roundTrip(t, replayer, url, `{"model":"other"}`); !errors.Is(err, ErrNotRecorded) {
+		t.Fatalf("miss error = %v", err)
+	}
+}
+
+func TestAutoModeAndIgnoreFields(t *testing.T) {
+	calls := 0
+	upstream := sseUpstream(t, &calls)
+	defer upstream.Close()
+
+	rt := NewTransport(Options{Mode: ModeAuto, Dir: t.TempDir(), IgnoreFields: []string{"session"}}, http.DefaultTransport)
+	for _, session := range []string{"a", "b", "c"} {
+		if _, err := roundTrip(t, rt, upstream.URL+"/v1/chat", `{"model":"m","session":"`+session+`"}`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("upstream calls = %d, want 1 (later requests replayed)", calls)
+	}
+}
+
+func TestSanitizeBody(t *testing.T) {
+	jsonBody := SanitizeBody([]byte(`{"grant_type":"refresh_token","refresh_token":"rt-1","nested":[{"access_token":"at-1"}]}`))
+	if strings.Contains(string(jsonBody), "rt-1") || strings.Contains(string(jsonBody), "at-1") || !strings.Contains(string(jsonBody), `"grant_type":"refresh_token"`) {
+		t.Fatalf("json body = %s", jsonBody)
+	}
+	formBody := SanitizeBody([]byte("grant_type=refresh_token&refresh_token=rt-2&client_id=abc"))
+	if strings.Contains(string(formBody), "rt-2") || !strings.Contains(string(formBody), "client_id=abc") {
+		t.Fatalf("form body = %s", formBody)
+	}
+}
+
+func TestDisabledModeIsPassthrough(t *testing.T) {
+	if rt := NewTransport(Options{}, http.DefaultTransport); rt != http.DefaultTransport {
+		t.Fatal("empty mode should return the wrapped transport")
+	}
+}
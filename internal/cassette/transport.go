@@ -0,0 +1,185 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// NewTransport wraps next according to opts.Mode. An empty or unknown mode returns next.
+func NewTransport(opts Options, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	switch strings.ToLower(strings.TrimSpace(opts.Mode)) {
+	case ModeRecord, ModeReplay, ModeAuto:
+		opts.Mode = strings.ToLower(strings.TrimSpace(opts.Mode))
+		return &transport{opts: opts, next: next}
+	default:
+		return next
+	}
+}
+
+type transport struct {
+	opts Options
+	next http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+
+	sanitizedURL := SanitizeURL(req.URL)
+	sanitizedBody := SanitizeBody(body)
+	path := t.opts.path(req.URL, t.opts.key(req.Method, sanitizedURL, sanitizedBody))
+
+	if t.opts.Mode != ModeRecord {
+		interaction, err := Load(path)
+		if err == nil {
+			return t.replay(req, interaction), nil
+		}
+		if t.opts.Mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s (expected %s)", ErrNotRecorded, req.Method, sanitizedURL, path)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	interaction := &Interaction{
+		Version:    formatVersion,
+		RecordedAt: time.Now().UTC(),
+		Request: Request{
+			Method:  req.Method,
+			URL:     sanitizedURL,
+			Headers: t.opts.sanitizeHeaders(req.Header),
+			Body:    bodyJSON(sanitizedBody),
+		},
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: t.opts.sanitizeHeaders(resp.Header),
+		},
+	}
+	resp.Body = &recordingBody{body: resp.Body, start: time.Now(), interaction: interaction, path: path}
+	return resp, nil
+}
+
+func (t *transport) replay(req *http.Request, interaction *Interaction) *http.Response {
+	header := interaction.Response.Headers.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+		StatusCode:    interaction.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          &replayBody{chunks: interaction.Response.Chunks, timed: t.opts.ReplayTiming, start: time.Now(), req: req},
+		ContentLength: -1,
+		Request:       req,
+	}
+}
+
+// recordingBody captures each read with its offset and saves the cassette once the body is
+// fully read or closed.
+type recordingBody struct {
+	body        io.ReadCloser
+	start       time.Time
+	interaction *Interaction
+	path        string
+	once        sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.interaction.Response.Chunks = append(b.interaction.Response.Chunks, newChunk(time.Since(b.start), p[:n]))
+	}
+	if err == io.EOF {
+		b.save()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.save()
+	return b.body.Close()
+}
+
+func (b *recordingBody) save() {
+	b.once.Do(func() {
+		sanitizeResponse(&b.interaction.Response)
+		if err := Save(b.path, b.interaction); err != nil {
+			log.Warnf("cassette: save %s: %v", b.path, err)
+			return
+		}
+		log.Debugf("cassette: recorded %s %s to %s", b.interaction.Request.Method, b.interaction.Request.URL, b.path)
+	})
+}
+
+// sanitizeResponse redacts credentials in single-document JSON responses such as token
+// endpoints. Streams are left untouched so their chunk boundaries survive.
+func sanitizeResponse(resp *Response) {
+	body := resp.Body()
+	sanitized := SanitizeBody(body)
+	if bytes.Equal(body, sanitized) || len(resp.Chunks) == 0 {
+		return
+	}
+	last := resp.Chunks[len(resp.Chunks)-1].OffsetMs
+	resp.Chunks = []Chunk{newChunk(time.Duration(last)*time.Millisecond, sanitized)}
+}
+
+// replayBody serves recorded chunks one read at a time, optionally at their recorded offsets.
+type replayBody struct {
+	chunks  []Chunk
+	current []byte
+	timed   bool
+	start   time.Time
+	req     *http.Request
+}
+
+func (b *replayBody) Read(p []byte) (int, error) {
+	for len(b.current) == 0 {
+		if len(b.chunks) == 0 {
+			return 0, io.EOF
+		}
+		next := b.chunks[0]
+		b.chunks = b.chunks[1:]
+		if b.timed {
+			if wait := time.Until(b.start.Add(time.Duration(next.OffsetMs) * time.Millisecond)); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-b.req.Context().Done():
+					timer.Stop()
+					return 0, b.req.Context().Err()
+				}
+			}
+		}
+		b.current = next.Bytes()
+	}
+	n := copy(p, b.current)
+	b.current = b.current[n:]
+	return n, nil
+}
+
+func (b *replayBody) Close() error { return nil }
@@ -0,0 +1,382 @@
+// Package cmd contains CLI helpers. This file implements the "auth" subcommand, which logs in to
+// the OAuth providers and lists, refreshes and removes the credentials in the token store.
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/credcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+const authUsage = `Usage: %[1]s [-config path] auth <command> [arguments]
+
+Commands:
+  login <provider> [-no-browser] [-project-id id]
+      Run the OAuth flow of claude (anthropic), gemini (google), codex (openai), qwen, iflow or
+      antigravity and save the tokens to the auth store.
+  list [-provider name] [-json]
+      List stored credentials.
+  refresh [-all] [id...]
+      Refresh the tokens of the given credentials, or of every credential with -all.
+  remove <id...>
+      Delete credentials from the auth store.
+  export [-o file] [-provider name] [id...]
+      Write the given credentials, or all of them, to a passphrase-encrypted bundle for another
+      machine. The passphrase is read from CLIPROXY_BUNDLE_PASSPHRASE or prompted for.
+  import [-overwrite] <file|->
+      Add the credentials of an exported bundle to the auth store.
+  encrypt
+      Encrypt the tokens and keys of every credential file in the auth directory
+      (requires credential-encryption).
+  decrypt
+      Rewrite every credential file in the auth directory in plaintext.
+`
+
+// loginProviders maps the accepted provider names to their login flows.
+var loginProviders = map[string]func(cfg *config.Config, projectID string, options *LoginOptions){
+	"claude":      func(cfg *config.Config, _ string, options *LoginOptions) { DoClaudeLogin(cfg, options) },
+	"anthropic":   func(cfg *config.Config, _ string, options *LoginOptions) { DoClaudeLogin(cfg, options) },
+	"gemini":      DoLogin,
+	"gemini-cli":  DoLogin,
+	"google":      DoLogin,
+	"codex":       func(cfg *config.Config, _ string, options *LoginOptions) { DoCodexLogin(cfg, options) },
+	"openai":      func(cfg *config.Config, _ string, options *LoginOptions) { DoCodexLogin(cfg, options) },
+	"qwen":        func(cfg *config.Config, _ string, options *LoginOptions) { DoQwenLogin(cfg, options) },
+	"iflow":       func(cfg *config.Config, _ string, options *LoginOptions) { DoIFlowLogin(cfg, options) },
+	"antigravity": func(cfg *config.Config, _ string, options *LoginOptions) { DoAntigravityLogin(cfg, options) },
+}
+
+// RunAuth runs the auth subcommand against the token store registered by the caller. It returns
+// the process exit code.
+func RunAuth(cfg *config.Config, args []string, options *LoginOptions) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		fmt.Fprintf(os.Stderr, authUsage, os.Args[0])
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+	if options == nil {
+		options = &LoginOptions{}
+	}
+	store := sdkAuth.GetTokenStore()
+	if dirSetter, ok := store.(interface{ SetBaseDir(string) }); ok {
+		dirSetter.SetBaseDir(cfg.AuthDir)
+	}
+
+	var err error
+	switch args[0] {
+	case "login":
+		err = authLogin(cfg, args[1:], options)
+	case "list":
+		err = authList(store, args[1:])
+	case "refresh":
+		err = authRefresh(cfg, store, args[1:])
+	case "remove":
+		err = authRemove(store, args[1:])
+	case "export":
+		err = authExport(store, args[1:])
+	case "import":
+		err = authImport(store, args[1:])
+	case "encrypt":
+		err = authRewriteFiles(cfg.AuthDir, true)
+	case "decrypt":
+		err = authRewriteFiles(cfg.AuthDir, false)
+	default:
+		fmt.Fprintf(os.Stderr, "auth: unknown command %q\n\n", args[0])
+		fmt.Fprintf(os.Stderr, authUsage, os.Args[0])
+		return 2
+	}
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "auth %s: %v\n", args[0], err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// parseInterspersed parses fs and lets flags follow positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func authLogin(cfg *config.Config, args []string, options *LoginOptions) error {
+	fs := flag.NewFlagSet("auth login", flag.ContinueOnError)
+	noBrowser := fs.Bool("no-browser", options.NoBrowser, "Print the login URL instead of opening a browser")
+	projectID := fs.String("project-id", "", "Google Cloud project ID (gemini only)")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("expected exactly one provider")
+	}
+	provider := strings.ToLower(strings.TrimSpace(positional[0]))
+	login, ok := loginProviders[provider]
+	if !ok {
+		return fmt.Errorf("unsupported provider %q", positional[0])
+	}
+	loginOptions := *options
+	loginOptions.NoBrowser = *noBrowser
+	login(cfg, *projectID, &loginOptions)
+	return nil
+}
+
+// storedAuths lists the credentials of the token store sorted by provider and ID.
+func storedAuths(store coreauth.Store) ([]*coreauth.Auth, error) {
+	auths, err := store.List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(auths, func(i, j int) bool {
+		if auths[i].Provider != auths[j].Provider {
+			return auths[i].Provider < auths[j].Provider
+		}
+		return auths[i].ID < auths[j].ID
+	})
+	return auths, nil
+}
+
+type authListEntry struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	Account   string    `json:"account,omitempty"`
+	Disabled  bool      `json:"disabled"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+func authList(store coreauth.Store, args []string) error {
+	fs := flag.NewFlagSet("auth list", flag.ContinueOnError)
+	provider := fs.String("provider", "", "Only list credentials of this provider")
+	asJSON := fs.Bool("json", false, "Print JSON instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	auths, err := storedAuths(store)
+	if err != nil {
+		return err
+	}
+	entries := make([]authListEntry, 0, len(auths))
+	for _, auth := range auths {
+		if *provider != "" && !strings.EqualFold(auth.Provider, *provider) {
+			continue
+		}
+		_, account := auth.AccountInfo()
+		entry := authListEntry{ID: auth.ID, Provider: auth.Provider, Account: account, Disabled: auth.Disabled}
+		if expiresAt, ok := auth.ExpirationTime(); ok {
+			entry.ExpiresAt = expiresAt
+		}
+		entries = append(entries, entry)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tPROVIDER\tACCOUNT\tSTATUS\tEXPIRES")
+	for _, entry := range entries {
+		status := "enabled"
+		if entry.Disabled {
+			status = "disabled"
+		}
+		expires := "-"
+		if !entry.ExpiresAt.IsZero() {
+			expires = entry.ExpiresAt.Local().Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.ID, entry.Provider, entry.Account, status, expires)
+	}
+	return w.Flush()
+}
+
+// refreshExecutor returns the executor that refreshes the tokens of provider.
+func refreshExecutor(cfg *config.Config, provider string) coreauth.ProviderExecutor {
+	switch strings.ToLower(provider) {
+	case "claude":
+		return executor.NewClaudeExecutor(cfg)
+	case "codex":
+		return executor.NewCodexExecutor(cfg)
+	case "gemini-cli":
+		return executor.NewGeminiCLIExecutor(cfg)
+	case "antigravity":
+		return executor.NewAntigravityExecutor(cfg)
+	case "qwen":
+		return executor.NewQwenExecutor(cfg)
+	case "iflow":
+		return executor.NewIFlowExecutor(cfg)
+	default:
+		return nil
+	}
+}
+
+func authRefresh(cfg *config.Config, store coreauth.Store, args []string) error {
+	fs := flag.NewFlagSet("auth refresh", flag.ContinueOnError)
+	all := fs.Bool("all", false, "Refresh every stored credential")
+	ids, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if !*all && len(ids) == 0 {
+		return errors.New("expected credential IDs or -all")
+	}
+	auths, err := storedAuths(store)
+	if err != nil {
+		return err
+	}
+	targets, err := selectAuths(auths, ids, *all)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	failed := 0
+	for _, auth := range targets {
+		exec := refreshExecutor(cfg, auth.Provider)
+		if exec == nil {
+			fmt.Printf("%s: skipped, %s credentials have no refresh flow\n", auth.ID, auth.Provider)
+			continue
+		}
+		target := auth.Clone()
+		if target.Metadata, err = credcrypt.Open(target.Metadata); err != nil {
+			failed++
+			fmt.Printf("%s: %v\n", auth.ID, err)
+			continue
+		}
+		updated, errRefresh := exec.Refresh(ctx, target)
+		if errRefresh != nil {
+			failed++
+			fmt.Printf("%s: refresh failed: %v\n", auth.ID, errRefresh)
+			continue
+		}
+		if updated == nil {
+			updated = target
+		}
+		now := time.Now()
+		updated.LastRefreshedAt = now
+		updated.UpdatedAt = now
+		if _, errSave := store.Save(ctx, updated); errSave != nil {
+			failed++
+			fmt.Printf("%s: save failed: %v\n", auth.ID, errSave)
+			continue
+		}
+		fmt.Printf("%s: refreshed\n", auth.ID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d credentials failed", failed, len(targets))
+	}
+	return nil
+}
+
+func authRemove(store coreauth.Store, args []string) error {
+	fs := flag.NewFlagSet("auth remove", flag.ContinueOnError)
+	ids, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return errors.New("expected credential IDs")
+	}
+	auths, err := storedAuths(store)
+	if err != nil {
+		return err
+	}
+	targets, err := selectAuths(auths, ids, false)
+	if err != nil {
+		return err
+	}
+	for _, auth := range targets {
+		if errDelete := store.Delete(context.Background(), auth.ID); errDelete != nil {
+			return fmt.Errorf("%s: %w", auth.ID, errDelete)
+		}
+		fmt.Printf("%s: removed\n", auth.ID)
+	}
+	return nil
+}
+
+// selectAuths returns the credentials named by ids, or all of them when all is set.
+func selectAuths(auths []*coreauth.Auth, ids []string, all bool) ([]*coreauth.Auth, error) {
+	if all {
+		return auths, nil
+	}
+	byID := make(map[string]*coreauth.Auth, len(auths))
+	for _, auth := range auths {
+		byID[auth.ID] = auth
+	}
+	targets := make([]*coreauth.Auth, 0, len(ids))
+	for _, id := range ids {
+		auth, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("credential %q not found", id)
+		}
+		targets = append(targets, auth)
+	}
+	return targets, nil
+}
+
+// authRewriteFiles encrypts or decrypts the secret fields of every credential file in dir.
+func authRewriteFiles(dir string, encrypt bool) error {
+	if encrypt && !credcrypt.Enabled() {
+		return errors.New("credential-encryption is not enabled or has no key")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			return errRead
+		}
+		if _, current := credcrypt.Plaintext(data); encrypt && current {
+			continue
+		}
+		out, errConvert := credcrypt.OpenJSON(data)
+		if errConvert == nil && encrypt {
+			out, errConvert = credcrypt.SealJSON(out)
+		}
+		if errConvert != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), errConvert)
+		}
+		if bytes.Equal(bytes.TrimSpace(out), bytes.TrimSpace(data)) {
+			continue
+		}
+		tmp := path + ".tmp"
+		if errWrite := os.WriteFile(tmp, out, 0o600); errWrite != nil {
+			return errWrite
+		}
+		if errRename := os.Rename(tmp, path); errRename != nil {
+			return errRename
+		}
+		fmt.Printf("%s: rewritten\n", entry.Name())
+	}
+	return nil
+}
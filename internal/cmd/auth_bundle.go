@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/credcrypt"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// defaultBundlePassphraseEnv holds the bundle passphrase for non-interactive use.
+const defaultBundlePassphraseEnv = "CLIPROXY_BUNDLE_PASSPHRASE"
+
+// credentialBundle is the decrypted content of an export bundle.
+type credentialBundle struct {
+	ExportedAt  time.Time          `json:"exported_at"`
+	Credentials []bundleCredential `json:"credentials"`
+}
+
+type bundleCredential struct {
+	ID       string         `json:"id"`
+	Provider string         `json:"provider"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+// bundlePassphrase reads the passphrase from env, or prompts for it on the terminal.
+func bundlePassphrase(env string, allowPrompt bool) (string, error) {
+	if passphrase := os.Getenv(env); passphrase != "" {
+		return passphrase, nil
+	}
+	if !allowPrompt {
+		return "", fmt.Errorf("set %s when the bundle is read from stdin", env)
+	}
+	fmt.Fprint(os.Stderr, "Bundle passphrase: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func authExport(store coreauth.Store, args []string) error {
+	fs := flag.NewFlagSet("auth export", flag.ContinueOnError)
+	output := fs.String("o", "-", "Bundle file, - for stdout")
+	provider := fs.String("provider", "", "Only export credentials of this provider")
+	passphraseEnv := fs.String("passphrase-env", defaultBundlePassphraseEnv, "Environment variable holding the bundle passphrase")
+	ids, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	auths, err := storedAuths(store)
+	if err != nil {
+		return err
+	}
+	targets, err := selectAuths(auths, ids, len(ids) == 0)
+	if err != nil {
+		return err
+	}
+	bundle := credentialBundle{ExportedAt: time.Now().UTC()}
+	for _, auth := range targets {
+		if *provider != "" && !strings.EqualFold(auth.Provider, *provider) {
+			continue
+		}
+		metadata, errOpen := credcrypt.Open(auth.Metadata)
+		if errOpen != nil {
+			return fmt.Errorf("%s: %w", auth.ID, errOpen)
+		}
+		bundle.Credentials = append(bundle.Credentials, bundleCredential{ID: auth.ID, Provider: auth.Provider, Metadata: metadata})
+	}
+	if len(bundle.Credentials) == 0 {
+		return errors.New("no credentials to export")
+	}
+	plain, err := json.Marshal(bundle)
+	if err != nil {
+		return err
+	}
+	passphrase, err := bundlePassphrase(*passphraseEnv, true)
+	if err != nil {
+		return err
+	}
+	sealed, err := credcrypt.SealBundle(passphrase, plain)
+	if err != nil {
+		return err
+	}
+	if *output == "-" {
+		_, err = os.Stdout.Write(append(sealed, '\n'))
+		return err
+	}
+	if err = os.WriteFile(*output, append(sealed, '\n'), 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d credentials to %s\n", len(bundle.Credentials), *output)
+	return nil
+}
+
+func authImport(store coreauth.Store, args []string) error {
+	fs := flag.NewFlagSet("auth import", flag.ContinueOnError)
+	overwrite := fs.Bool("overwrite", false, "Replace credentials that already exist")
+	passphraseEnv := fs.String("passphrase-env", defaultBundlePassphraseEnv, "Environment variable holding the bundle passphrase")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("expected the bundle file, - for stdin")
+	}
+	var data []byte
+	if positional[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(positional[0])
+	}
+	if err != nil {
+		return err
+	}
+	passphrase, err := bundlePassphrase(*passphraseEnv, positional[0] != "-")
+	if err != nil {
+		return err
+	}
+	plain, err := credcrypt.OpenBundle(passphrase, data)
+	if err != nil {
+		return err
+	}
+	var bundle credentialBundle
+	if err = json.Unmarshal(plain, &bundle); err != nil {
+		return fmt.Errorf("decode bundle: %w", err)
+	}
+
+	existing, err := storedAuths(store)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, auth := range existing {
+		known[auth.ID] = true
+	}
+	imported := 0
+	for _, cred := range bundle.Credentials {
+		id, errID := bundleCredentialID(cred.ID)
+		if errID != nil {
+			fmt.Printf("%s: skipped, %v\n", cred.ID, errID)
+			continue
+		}
+		if known[id] && !*overwrite {
+			fmt.Printf("%s: skipped, already exists (use -overwrite)\n", id)
+			continue
+		}
+		auth := &coreauth.Auth{
+			ID:         id,
+			Provider:   cred.Provider,
+			FileName:   id,
+			Status:     coreauth.StatusActive,
+			Attributes: map[string]string{},
+			Metadata:   cred.Metadata,
+		}
+		if _, errSave := store.Save(context.Background(), auth); errSave != nil {
+			return fmt.Errorf("%s: %w", id, errSave)
+		}
+		imported++
+		fmt.Printf("%s: imported\n", id)
+	}
+	fmt.Printf("Imported %d of %d credentials exported at %s\n", imported, len(bundle.Credentials), bundle.ExportedAt.Local().Format(time.RFC3339))
+	return nil
+}
+
+// bundleCredentialID keeps imported credentials inside the auth directory.
+func bundleCredentialID(id string) (string, error) {
+	clean := filepath.Clean(strings.TrimSpace(id))
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errors.New("invalid credential id")
+	}
+	if !strings.HasSuffix(strings.ToLower(clean), ".json") {
+		clean += ".json"
+	}
+	return clean, nil
+}
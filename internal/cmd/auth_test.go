@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/credcrypt"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+)
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+	defer func() { os.Stdout = stdout }()
+	fn()
+	_ = w.Close()
+	return <-done
+}
+
+// newAuthDir writes the credential files to a temporary auth directory.
+func newAuthDir(t *testing.T, files map[string]string) (string, *sdkAuth.FileTokenStore) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	store := sdkAuth.NewFileTokenStore()
+	store.SetBaseDir(dir)
+	return dir, store
+}
+
+var testCredentials = map[string]string{
+	"claude-a.json": `{"type":"claude","email":"a@example.com","access_token":"token-a"}`,
+	"codex-b.json":  `{"type":"codex","email":"b@example.com","access_token":"token-b"}`,
+}
+
+func TestParseInterspersed(t *testing.T) {
+	cases := []struct {
+		name       string
+		args       []string
+		positional []string
+		all        bool
+		provider   string
+		wantErr    bool
+	}{
+		{name: "flags first", args: []string{"-all", "-provider", "claude", "a", "b"}, positional: []string{"a", "b"}, all: true, provider: "claude"},
+		{name: "flags after positional", args: []string{"a", "-all", "b", "-provider=codex"}, positional: []string{"a", "b"}, all: true, provider: "codex"},
+		{name: "terminator keeps dashes", args: []string{"a", "--", "-b"}, positional: []string{"a", "-b"}},
+		{name: "no arguments", args: nil},
+		{name: "unknown flag", args: []string{"a", "-bogus"}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			all := fs.Bool("all", false, "")
+			provider := fs.String("provider", "", "")
+			positional, err := parseInterspersed(fs, tc.args)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseInterspersed: %v", err)
+			}
+			if !reflect.DeepEqual(positional, tc.positional) || *all != tc.all || *provider != tc.provider {
+				t.Fatalf("got %q all=%v provider=%q", positional, *all, *provider)
+			}
+		})
+	}
+}
+
+func TestAuthListAndRemove(t *testing.T) {
+	dir, store := newAuthDir(t, testCredentials)
+
+	var entries []authListEntry
+	out := captureStdout(t, func() {
+		if err := authList(store, []string{"-json"}); err != nil {
+			t.Errorf("authList: %v", err)
+		}
+	})
+	if err := json.Unmarshal([]byte(out), &entries); err != nil {
+		t.Fatalf("decode list output %q: %v", out, err)
+	}
+	if len(entries) != 2 || entries[0].ID != "claude-a.json" || entries[0].Account != "a@example.com" || entries[1].Provider != "codex" {
+		t.Fatalf("list entries = %+v", entries)
+	}
+	out = captureStdout(t, func() {
+		if err := authList(store, []string{"-provider", "CODEX"}); err != nil {
+			t.Errorf("authList: %v", err)
+		}
+	})
+	if !strings.Contains(out, "codex-b.json") || strings.Contains(out, "claude-a.json") {
+		t.Fatalf("filtered list = %q", out)
+	}
+
+	if err := authRemove(store, []string{"missing.json"}); err == nil {
+		t.Fatal("removing an unknown credential succeeded")
+	}
+	if err := authRemove(store, nil); err == nil {
+		t.Fatal("remove without IDs succeeded")
+	}
+	out = captureStdout(t, func() {
+		if err := authRemove(store, []string{"claude-a.json"}); err != nil {
+			t.Errorf("authRemove: %v", err)
+		}
+	})
+	if !strings.Contains(out, "claude-a.json: removed") {
+		t.Fatalf("remove output = %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "claude-a.json")); !os.IsNotExist(err) {
+		t.Fatalf("removed credential still exists: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "codex-b.json")); err != nil {
+		t.Fatalf("other credential removed: %v", err)
+	}
+}
+
+func TestAuthExportImportRoundTrip(t *testing.T) {
+	_, source := newAuthDir(t, testCredentials)
+	bundle := filepath.Join(t.TempDir(), "bundle.enc")
+	t.Setenv(defaultBundlePassphraseEnv, "correct horse")
+	captureStdout(t, func() {
+		if err := authExport(source, []string{"-o", bundle, "-provider", "claude"}); err != nil {
+			t.Errorf("authExport: %v", err)
+		}
+	})
+	raw, err := os.ReadFile(bundle)
+	if err != nil {
+		t.Fatalf("read bundle: %v", err)
+	}
+	if strings.Contains(string(raw), "token-a") {
+		t.Fatal("bundle contains a plaintext token")
+	}
+
+	targetDir, target := newAuthDir(t, map[string]string{"claude-a.json": `{"type":"claude","access_token":"old"}`})
+	t.Setenv(defaultBundlePassphraseEnv, "wrong")
+	if err = authImport(target, []string{bundle}); err == nil {
+		t.Fatal("import with a wrong passphrase succeeded")
+	}
+
+	t.Setenv(defaultBundlePassphraseEnv, "correct horse")
+	out := captureStdout(t, func() {
+		if err := authImport(target, []string{bundle}); err != nil {
+			t.Errorf("authImport: %v", err)
+		}
+	})
+	if !strings.Contains(out, "claude-a.json: skipped, already exists") {
+		t.Fatalf("import without -overwrite output = %q", out)
+	}
+	captureStdout(t, func() {
+		if err := authImport(target, []string{bundle, "-overwrite"}); err != nil {
+			t.Errorf("authImport -overwrite: %v", err)
+		}
+	})
+	data, err := os.ReadFile(filepath.Join(targetDir, "claude-a.json"))
+	if err != nil {
+		t.Fatalf("read imported credential: %v", err)
+	}
+	if !strings.Contains(string(data), "token-a") {
+		t.Fatalf("imported credential = %s", data)
+	}
+	if _, err = os.Stat(filepath.Join(targetDir, "codex-b.json")); !os.IsNotExist(err) {
+		t.Fatal("credential excluded by -provider was imported")
+	}
+}
+
+func TestAuthEncryptDecryptRewritesFiles(t *testing.T) {
+	dir, _ := newAuthDir(t, testCredentials)
+	cfg := &config.Config{AuthDir: dir}
+	path := filepath.Join(dir, "claude-a.json")
+
+	t.Setenv("TEST_CREDENTIAL_KEY", "")
+	_ = credcrypt.Configure(config.CredentialEncryptionConfig{KeyEnv: "TEST_CREDENTIAL_KEY"})
+	if code := RunAuth(cfg, []string{"encrypt"}, nil); code != 1 {
+		t.Fatalf("encrypt without a key exit code = %d, want 1", code)
+	}
+
+	t.Setenv("TEST_CREDENTIAL_KEY", "test passphrase")
+	if err := credcrypt.Configure(config.CredentialEncryptionConfig{Enable: true, KeyEnv: "TEST_CREDENTIAL_KEY"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	t.Cleanup(func() {
+		t.Setenv("TEST_CREDENTIAL_KEY", "")
+		_ = credcrypt.Configure(config.CredentialEncryptionConfig{KeyEnv: "TEST_CREDENTIAL_KEY"})
+	})
+
+	out := captureStdout(t, func() {
+		if code := RunAuth(cfg, []string{"encrypt"}, nil); code != 0 {
+			t.Errorf("encrypt exit code = %d", code)
+		}
+	})
+	if strings.Count(out, "rewritten") != 2 {
+		t.Fatalf("encrypt output = %q", out)
+	}
+	sealed, _ := os.ReadFile(path)
+	if strings.Contains(string(sealed), "token-a") || !strings.Contains(string(sealed), credcrypt.Prefix) {
+		t.Fatalf("encrypted file = %s", sealed)
+	}
+
+	// Files that are already encrypted are skipped rather than sealed again.
+	out = captureStdout(t, func() {
+		if code := RunAuth(cfg, []string{"encrypt"}, nil); code != 0 {
+			t.Errorf("second encrypt exit code = %d", code)
+		}
+	})
+	if again, _ := os.ReadFile(path); out != "" || string(again) != string(sealed) {
+		t.Fatalf("second encrypt rewrote files: %q", out)
+	}
+
+	out = captureStdout(t, func() {
+		if code := RunAuth(cfg, []string{"decrypt"}, nil); code != 0 {
+			t.Errorf("decrypt exit code = %d", code)
+		}
+	})
+	if strings.Count(out, "rewritten") != 2 {
+		t.Fatalf("decrypt output = %q", out)
+	}
+	plain, _ := os.ReadFile(path)
+	if !strings.Contains(string(plain), `"token-a"`) || strings.Contains(string(plain), credcrypt.Prefix) {
+		t.Fatalf("decrypted file = %s", plain)
+	}
+}
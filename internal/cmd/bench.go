@@ -0,0 +1,110 @@
+// Package cmd contains CLI helpers. This file implements the "bench" subcommand, which fires
+// synthetic chat completion load at a proxy, any OpenAI-compatible upstream or the built-in mock.
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/bench"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mock"
+)
+
+// RunBench parses the bench subcommand arguments, runs the load test and prints a report.
+// It returns the process exit code.
+func RunBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	var (
+		opts           bench.Options
+		configPath     string
+		useMock        bool
+		mockFirstDelay time.Duration
+		mockTokenDelay time.Duration
+	)
+	fs.StringVar(&opts.BaseURL, "url", "", "OpenAI-compatible API root to load, e.g. http://127.0.0.1:8317/v1")
+	fs.StringVar(&opts.APIKey, "api-key", "", "Bearer token sent with every request")
+	fs.StringVar(&opts.Model, "model", "", "Model to request (default mock-model with -mock)")
+	fs.StringVar(&opts.Prompt, "prompt", "", "User prompt sent with every request")
+	fs.IntVar(&opts.MaxTokens, "max-tokens", 0, "max_tokens sent with every request (0 omits it)")
+	fs.BoolVar(&opts.Stream, "stream", true, "Use streaming requests")
+	fs.IntVar(&opts.Concurrency, "c", 8, "Concurrent requests")
+	fs.IntVar(&opts.Requests, "n", 100, "Total requests (ignored with -duration)")
+	fs.DurationVar(&opts.Duration, "duration", 0, "Run for this long instead of a fixed request count")
+	fs.DurationVar(&opts.Timeout, "timeout", 2*time.Minute, "Per-request timeout")
+	fs.StringVar(&configPath, "config", "", "Derive -url and -api-key from this proxy config when they are not set")
+	fs.BoolVar(&useMock, "mock", false, "Target an in-process mock upstream instead of -url")
+	fs.DurationVar(&mockFirstDelay, "mock-first-token-delay", 50*time.Millisecond, "Mock delay before the first token")
+	fs.DurationVar(&mockTokenDelay, "mock-token-delay", 5*time.Millisecond, "Mock delay between tokens")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if useMock {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bench: start mock upstream: %v\n", err)
+			return 1
+		}
+		server := &http.Server{
+			Handler:           mock.NewHandler(mock.Options{FirstTokenDelay: mockFirstDelay, TokenDelay: mockTokenDelay}),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() { _ = server.Serve(listener) }()
+		defer func() { _ = server.Close() }()
+		opts.BaseURL = "http://" + listener.Addr().String() + "/v1"
+		if opts.Model == "" {
+			opts.Model = "mock-model"
+		}
+	} else if opts.BaseURL == "" && configPath != "" {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bench: load config: %v\n", err)
+			return 1
+		}
+		opts.BaseURL = proxyBaseURL(cfg)
+		if opts.APIKey == "" && len(cfg.APIKeys) > 0 {
+			opts.APIKey = cfg.APIKeys[0]
+		}
+	}
+	if opts.BaseURL == "" {
+		fmt.Fprintln(os.Stderr, "bench: one of -url, -config or -mock is required")
+		fs.Usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("bench: %s model=%s stream=%t concurrency=%d\n", opts.BaseURL, opts.Model, opts.Stream, opts.Concurrency)
+	report, err := bench.Run(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+		return 1
+	}
+	report.Write(os.Stdout)
+	if report.Requests > 0 && report.Failures == report.Requests {
+		return 1
+	}
+	return 0
+}
+
+// proxyBaseURL returns the local OpenAI-compatible API root served by a proxy using cfg.
+func proxyBaseURL(cfg *config.Config) string {
+	scheme := "http"
+	if cfg.TLS.Enable {
+		scheme = "https"
+	}
+	host := strings.TrimSpace(cfg.Host)
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return fmt.Sprintf("%s://%s/v1", scheme, net.JoinHostPort(host, fmt.Sprint(cfg.Port)))
+}
@@ -0,0 +1,60 @@
+// Package cmd contains CLI helpers. This file implements the "conformance" subcommand, which
+// checks the registered translators against the golden fixtures and regenerates them on request.
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/conformance"
+)
+
+// RunConformance parses the conformance subcommand arguments, runs the fixtures and prints one
+// line per case. It returns the process exit code.
+func RunConformance(args []string) int {
+	fs := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	var (
+		dir     string
+		update  bool
+		filter  string
+		verbose bool
+	)
+	fs.StringVar(&dir, "dir", conformance.DefaultDir, "Fixture directory")
+	fs.BoolVar(&update, "update", false, "Rewrite goldens that differ from the current translator output")
+	fs.StringVar(&filter, "run", "", "Only run fixtures or cases whose name contains this value")
+	fs.BoolVar(&verbose, "v", false, "Print passing cases")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	results, err := conformance.Check(dir, update, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "conformance: %v\n", err)
+		return 1
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(os.Stderr, "conformance: no cases found in %s\n", dir)
+		return 1
+	}
+
+	failed, updated := 0, 0
+	for _, result := range results {
+		name := result.Fixture + "/" + result.Case
+		switch {
+		case result.Updated:
+			updated++
+			fmt.Printf("UPDATED %s\n", name)
+		case result.Failed():
+			failed++
+			fmt.Printf("FAIL    %s\n%s\n", name, result.Diff)
+		case verbose:
+			fmt.Printf("PASS    %s\n", name)
+		}
+	}
+	fmt.Printf("%d cases, %d failed, %d updated\n", len(results), failed, updated)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+// Package cmd contains CLI helpers. This file implements the "mock" subcommand, which serves the
+// scripted OpenAI, Claude and Gemini upstream emulation for offline client development.
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mock"
+)
+
+// RunMock parses the mock subcommand arguments and serves the mock upstream until interrupted.
+// It returns the process exit code.
+func RunMock(args []string) int {
+	fs := flag.NewFlagSet("mock", flag.ContinueOnError)
+	var (
+		addr       string
+		scriptPath string
+		models     string
+		opts       mock.Options
+	)
+	fs.StringVar(&addr, "addr", "127.0.0.1:8399", "Listen address")
+	fs.StringVar(&scriptPath, "script", "", "YAML script mapping requests to responses")
+	fs.StringVar(&models, "models", "mock-model", "Comma-separated model names returned by the model listings")
+	fs.StringVar(&opts.Text, "text", "", "Default assistant reply")
+	fs.DurationVar(&opts.FirstTokenDelay, "first-token-delay", 0, "Delay before the first token")
+	fs.DurationVar(&opts.TokenDelay, "token-delay", 20*time.Millisecond, "Delay between streamed tokens")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if scriptPath != "" {
+		script, err := mock.LoadScript(scriptPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mock: %v\n", err)
+			return 1
+		}
+		opts.Script = script
+	}
+	for _, model := range strings.Split(models, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			opts.Models = append(opts.Models, model)
+		}
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mock.NewHandler(opts),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("mock upstream listening on http://%s\n", addr)
+	fmt.Printf("  OpenAI: /v1/chat/completions  Claude: /v1/messages  Gemini: /v1beta/models/{model}:streamGenerateContent\n")
+	fmt.Printf("  select a scenario per request with the %s header\n", mock.ScenarioHeader)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "mock: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,125 @@
+// Package codeexec runs model-written code in a local, short-lived process. It backs the
+// emulated code execution tool for upstreams without a server-side interpreter.
+//
+// The process runs in a fresh temporary directory with a minimal environment, a wall-clock
+// timeout and capped output. That is not an isolation boundary: operators who enable emulation
+// should point Command at a real sandbox (nsjail, bwrap, a throwaway container).
+package codeexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	defaultTimeout        = 10 * time.Second
+	defaultMaxOutputBytes = 64 << 10
+)
+
+// DefaultCommand runs Python in isolated mode, reading the program from stdin.
+var DefaultCommand = []string{"python3", "-I", "-"}
+
+// Runner executes programs with a fixed command line. The program is written to the command's
+// standard input.
+type Runner struct {
+	// Command is the interpreter command line. Defaults to DefaultCommand.
+	Command []string
+	// Timeout bounds each run. Defaults to 10 seconds.
+	Timeout time.Duration
+	// MaxOutputBytes caps the captured stdout and stderr, each. Defaults to 64 KiB.
+	MaxOutputBytes int
+}
+
+// Result is the outcome of one run.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	TimedOut bool
+}
+
+// Run executes code and returns its output. Errors are reserved for failures to start the
+// interpreter; a program that fails or times out yields a Result with a non-zero ExitCode.
+func (r Runner) Run(ctx context.Context, code string) (Result, error) {
+	command := r.Command
+	if len(command) == 0 {
+		command = DefaultCommand
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	limit := r.MaxOutputBytes
+	if limit <= 0 {
+		limit = defaultMaxOutputBytes
+	}
+
+	dir, err := os.MkdirTemp("", "cliproxy-codeexec-")
+	if err != nil {
+		return Result{}, fmt.Errorf("codeexec: create work dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + dir, "TMPDIR=" + dir, "LANG=C.UTF-8"}
+	cmd.Stdin = bytes.NewReader([]byte(code))
+	stdout := &cappedBuffer{limit: limit}
+	stderr := &cappedBuffer{limit: limit}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	result := Result{Stdout: stdout.String(), Stderr: stderr.String()}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+		result.ExitCode = -1
+		result.Stderr += fmt.Sprintf("\nexecution timed out after %s", timeout)
+		return result, nil
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		return Result{}, fmt.Errorf("codeexec: run %s: %w", command[0], err)
+	}
+	return result, nil
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the rest.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
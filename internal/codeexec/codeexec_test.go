@@ -0,0 +1,48 @@
+package codeexec
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCapturesOutputAndExitCode(t *testing.T) {
+	r := Runner{Command: []string{"sh", "-s"}}
+	res, err := r.Run(context.Background(), "echo out; echo err >&2; exit 3")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.Stdout != "out\n" || res.Stderr != "err\n" || res.ExitCode != 3 || res.TimedOut {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestRunTimesOut(t *testing.T) {
+	r := Runner{Command: []string{"sh", "-s"}, Timeout: 100 * time.Millisecond}
+	res, err := r.Run(context.Background(), "sleep 5")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !res.TimedOut || res.ExitCode == 0 || !strings.Contains(res.Stderr, "timed out") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestRunTruncatesOutput(t *testing.T) {
+	r := Runner{Command: []string{"sh", "-s"}, MaxOutputBytes: 4}
+	res, err := r.Run(context.Background(), "echo 0123456789")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.Stdout != "0123\n[output truncated]" {
+		t.Fatalf("stdout = %q", res.Stdout)
+	}
+}
+
+func TestRunMissingInterpreter(t *testing.T) {
+	r := Runner{Command: []string{"cliproxy-no-such-interpreter"}}
+	if _, err := r.Run(context.Background(), "print(1)"); err == nil {
+		t.Fatal("expected an error for a missing interpreter")
+	}
+}
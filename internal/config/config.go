@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"syscall"
 
@@ -27,6 +28,17 @@ type Config struct {
 	// Port is the network port on which the API server will listen.
 	Port int `yaml:"port" json:"-"`
 
+	// Serve restricts the routes exposed on host:port: "all" (default), "api", "management" or
+	// "none" to rely on Listeners only.
+	Serve string `yaml:"serve,omitempty" json:"-"`
+
+	// Listeners adds TCP or unix socket listeners, each serving all routes, client API routes
+	// only, or management routes only.
+	Listeners []ListenerConfig `yaml:"listeners,omitempty" json:"-"`
+
+	// GRPC serves the completion endpoints over gRPC for internal callers.
+	GRPC GRPCConfig `yaml:"grpc,omitempty" json:"-"`
+
 	// TLS config controls HTTPS server settings.
 	TLS TLSConfig `yaml:"tls" json:"tls"`
 
@@ -55,6 +67,28 @@ type Config struct {
 	// DisableCooling disables quota cooldown scheduling when true.
 	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
 
+	// SystemPromptRules prepend, append or replace the system prompt of translated requests.
+	SystemPromptRules []SystemPromptRule `yaml:"system-prompt-rules,omitempty" json:"system-prompt-rules,omitempty"`
+
+	// ReasoningRules set a default reasoning depth and thinking budget limits for translated
+	// requests to matching upstream models.
+	ReasoningRules []ReasoningRule `yaml:"reasoning-rules,omitempty" json:"reasoning-rules,omitempty"`
+
+	// FinishReasonOverrides replace entries of the built-in finish_reason/stop_reason mapping
+	// between client and upstream protocols.
+	FinishReasonOverrides []FinishReasonOverride `yaml:"finish-reason-overrides,omitempty" json:"finish-reason-overrides,omitempty"`
+
+	// MaxTokens sets per-model output token limits and defaults applied when translated requests
+	// omit or exceed them, overriding the built-in model catalog.
+	MaxTokens []ModelMaxTokens `yaml:"max-tokens,omitempty" json:"max-tokens,omitempty"`
+
+	// MCPServer exposes the proxy's models as Model Context Protocol tools at /mcp.
+	MCPServer MCPServerConfig `yaml:"mcp-server,omitempty" json:"mcp-server,omitempty"`
+
+	// DisableHistoryRepair sends translated requests with orphaned tool calls or tool results
+	// upstream unchanged instead of repairing them.
+	DisableHistoryRepair bool `yaml:"disable-history-repair,omitempty" json:"disable-history-repair,omitempty"`
+
 	// RequestRetry defines the retry times when the request failed.
 	RequestRetry int `yaml:"request-retry" json:"request-retry"`
 	// MaxRetryInterval defines the maximum wait time in seconds before retrying a cooled-down credential.
@@ -63,6 +97,10 @@ type Config struct {
 	// QuotaExceeded defines the behavior when a quota is exceeded.
 	QuotaExceeded QuotaExceeded `yaml:"quota-exceeded" json:"quota-exceeded"`
 
+	// QuotaWindows rotates away from subscription credentials close to the limit of a rolling
+	// usage window.
+	QuotaWindows QuotaWindowsConfig `yaml:"quota-windows,omitempty" json:"quota-windows,omitempty"`
+
 	// Routing controls credential selection behavior.
 	Routing RoutingConfig `yaml:"routing" json:"routing"`
 
@@ -102,7 +140,307 @@ type Config struct {
 	// Payload defines default and override rules for provider payload parameters.
 	Payload PayloadConfig `yaml:"payload" json:"payload"`
 
+	// Concurrency caps in-flight requests per upstream provider and queues the overflow.
+	Concurrency ConcurrencyConfig `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+
+	// Hedging re-sends streaming requests that have not produced a first chunk in time through a
+	// second credential and relays whichever answers first.
+	Hedging HedgingConfig `yaml:"hedging,omitempty" json:"hedging,omitempty"`
+
+	// StateStore configures the durable backend shared by the tool_use ID mapping, the response
+	// cache ("state" backend) and usage accounting. Changes require a restart.
+	StateStore StateStoreConfig `yaml:"state-store,omitempty" json:"state-store,omitempty"`
+
+	// IPAccess restricts and throttles clients by source IP address.
+	IPAccess IPAccessConfig `yaml:"ip-access,omitempty" json:"ip-access,omitempty"`
+
+	// BodyLimits caps request body sizes, globally and per route.
+	BodyLimits BodyLimitsConfig `yaml:"body-limits,omitempty" json:"body-limits,omitempty"`
+
+	// UpstreamTLS configures client certificates for upstreams that require mutual TLS.
+	UpstreamTLS []UpstreamTLSConfig `yaml:"upstream-tls,omitempty" json:"upstream-tls,omitempty"`
+
+	// Cassette records sanitized upstream HTTP exchanges to files or replays them instead of
+	// calling the providers.
+	Cassette CassetteConfig `yaml:"cassette,omitempty" json:"cassette,omitempty"`
+
+	// UpstreamProxies routes specific providers or upstream hosts through dedicated outbound proxies.
+	UpstreamProxies []UpstreamProxyConfig `yaml:"upstream-proxies,omitempty" json:"upstream-proxies,omitempty"`
+
+	// ProxyHealthCheck periodically probes the upstream-proxies and skips unreachable ones.
+	ProxyHealthCheck ProxyHealthCheckConfig `yaml:"proxy-health-check,omitempty" json:"proxy-health-check,omitempty"`
+
+	// Fingerprints make requests to specific providers resemble their first-party clients through
+	// headers, the user agent and TLS handshake settings.
+	Fingerprints []FingerprintConfig `yaml:"fingerprints,omitempty" json:"fingerprints,omitempty"`
+
+	// HealthCheck actively probes every credential and exposes the results at /health and /ready.
+	HealthCheck HealthCheckConfig `yaml:"health-check,omitempty" json:"health-check,omitempty"`
+
+	// CredentialEncryption encrypts tokens and API keys in stored credentials at rest.
+	CredentialEncryption CredentialEncryptionConfig `yaml:"credential-encryption,omitempty" json:"credential-encryption,omitempty"`
+
+	// CredentialHealth removes credentials that keep failing authentication or quota checks from
+	// rotation and re-checks them in the background.
+	CredentialHealth CredentialHealthConfig `yaml:"credential-health,omitempty" json:"credential-health,omitempty"`
+
+	// UpstreamTransport tunes the shared HTTP/2 transports and the Vertex AI gRPC client.
+	UpstreamTransport UpstreamTransportConfig `yaml:"upstream-transport,omitempty" json:"upstream-transport,omitempty"`
+
+	// AuditLog records request metadata to a hash-chained, append-only audit log.
+	AuditLog AuditLogConfig `yaml:"audit-log,omitempty" json:"audit-log,omitempty"`
+
+	// Pricing overrides or extends the built-in per-model token prices used for spend accounting.
+	Pricing []ModelPrice `yaml:"pricing,omitempty" json:"pricing,omitempty"`
+
+	// SpendBudgets caps the estimated spend of client keys and tenants, with alerts at thresholds.
+	SpendBudgets SpendBudgetsConfig `yaml:"spend-budgets,omitempty" json:"spend-budgets,omitempty"`
+
+	// Webhooks deliver signed event notifications such as completed requests, upstream failovers
+	// and expired credentials to external endpoints.
+	Webhooks []WebhookConfig `yaml:"webhooks,omitempty" json:"webhooks,omitempty"`
+
+	// RequestTrace keeps routing decision traces of requests that ask for one, retrievable from
+	// /admin/requests/{id}.
+	RequestTrace RequestTraceConfig `yaml:"request-trace,omitempty" json:"request-trace,omitempty"`
+
+	// RateLimitHeaders adds anthropic-ratelimit-* and x-ratelimit-* headers to Claude and OpenAI
+	// API responses, derived from the proxy's tenant quotas and key budgets and the upstream's
+	// reported limits, so client SDK backoff works behind the proxy.
+	RateLimitHeaders bool `yaml:"rate-limit-headers,omitempty" json:"rate-limit-headers,omitempty"`
+
 	legacyMigrationPending bool `yaml:"-" json:"-"`
+
+	// secretRefs tracks ${env:...} and ${file:...} references resolved at load time.
+	secretRefs *secretRefs `yaml:"-" json:"-"`
+}
+
+// ConcurrencyConfig configures per-upstream concurrency limits.
+type ConcurrencyConfig struct {
+	// Upstreams lists the limits per provider. Requests to providers without an entry are not limited.
+	Upstreams []UpstreamConcurrency `yaml:"upstreams,omitempty" json:"upstreams,omitempty"`
+
+	// FairQueuing serves queued requests by weighted fair queuing across client API keys instead
+	// of first in, first out, so one busy key cannot hold every slot of a limited upstream.
+	FairQueuing bool `yaml:"fair-queuing,omitempty" json:"fair-queuing,omitempty"`
+
+	// KeyWeights maps client API keys to their fair queuing weight. Keys without an entry weigh 1.
+	KeyWeights map[string]int `yaml:"key-weights,omitempty" json:"key-weights,omitempty"`
+}
+
+// HedgingConfig configures hedged streaming requests.
+type HedgingConfig struct {
+	// Enable turns hedging on.
+	Enable bool `yaml:"enable" json:"enable"`
+	// FirstTokenMs is how long a stream may go without its first chunk before the request is
+	// hedged. Default is 3000.
+	FirstTokenMs int `yaml:"first-token-ms,omitempty" json:"first-token-ms,omitempty"`
+	// Providers limits hedging to these providers. Empty hedges every provider.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+// UpstreamConcurrency limits the requests in flight to one upstream provider.
+type UpstreamConcurrency struct {
+	// Provider is the provider identifier (e.g. "claude", "gemini", an openai-compatibility name),
+	// or "*" to apply the limit to every provider without its own entry.
+	Provider string `yaml:"provider" json:"provider"`
+
+	// MaxInFlight is the maximum number of concurrent upstream requests. Zero disables the limit.
+	MaxInFlight int `yaml:"max-in-flight" json:"max-in-flight"`
+
+	// QueueSize bounds the number of requests waiting for a slot; further requests are rejected
+	// with 429 and a Retry-After header. Zero rejects immediately when every slot is busy.
+	QueueSize int `yaml:"queue-size,omitempty" json:"queue-size,omitempty"`
+
+	// QueueTimeoutSeconds bounds how long a request waits in the queue. Default is 30.
+	QueueTimeoutSeconds int `yaml:"queue-timeout-seconds,omitempty" json:"queue-timeout-seconds,omitempty"`
+}
+
+// AuditLogConfig configures the tamper-evident audit log. Each entry carries the hash of the
+// previous one, so editing or removing a line breaks the chain from that point on.
+type AuditLogConfig struct {
+	// Enable turns on audit logging.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Path is the audit log file. Default is audit.log inside the logs directory.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// IncludeManagement also records management API requests.
+	IncludeManagement bool `yaml:"include-management,omitempty" json:"include-management,omitempty"`
+
+	// Syslog forwards entries to a syslog collector (RFC 5424).
+	Syslog AuditSyslogConfig `yaml:"syslog,omitempty" json:"syslog,omitempty"`
+
+	// HTTP forwards entries to a SIEM collector as JSON.
+	HTTP AuditHTTPConfig `yaml:"http,omitempty" json:"http,omitempty"`
+}
+
+// AuditSyslogConfig configures syslog export of audit entries.
+type AuditSyslogConfig struct {
+	// Network is "udp" (default), "tcp" or "tcp+tls".
+	Network string `yaml:"network,omitempty" json:"network,omitempty"`
+
+	// Address is the collector host:port. Empty disables syslog export.
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+
+	// Tag is the syslog APP-NAME. Default is "cliproxy".
+	Tag string `yaml:"tag,omitempty" json:"tag,omitempty"`
+}
+
+// AuditHTTPConfig configures HTTP export of audit entries.
+type AuditHTTPConfig struct {
+	// URL receives a POST with one JSON entry per request. Empty disables HTTP export.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// Headers are added to every export request, e.g. an Authorization token.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// ModelPrice sets the token prices of the models matching Model, in USD per million tokens.
+type ModelPrice struct {
+	// Model is a model name; "*" matches any sequence of characters.
+	Model string `yaml:"model" json:"model"`
+
+	// Input is the price of uncached input tokens.
+	Input float64 `yaml:"input" json:"input"`
+
+	// Output is the price of output and reasoning tokens.
+	Output float64 `yaml:"output" json:"output"`
+
+	// CachedInput is the price of input tokens served from the prompt cache. Zero bills them as input.
+	CachedInput float64 `yaml:"cached-input,omitempty" json:"cached-input,omitempty"`
+}
+
+// SpendBudgetsConfig configures monetary budgets and where threshold alerts are delivered.
+type SpendBudgetsConfig struct {
+	// AlertWebhook receives a POST with a JSON body whenever a budget crosses an alert threshold.
+	AlertWebhook string `yaml:"alert-webhook,omitempty" json:"alert-webhook,omitempty"`
+
+	// AlertEmail sends threshold alerts by email.
+	AlertEmail SpendAlertEmailConfig `yaml:"alert-email,omitempty" json:"alert-email,omitempty"`
+
+	// Budgets lists the budgets. Each one applies separately to every key and tenant it names.
+	Budgets []SpendBudget `yaml:"budgets,omitempty" json:"budgets,omitempty"`
+}
+
+// SpendAlertEmailConfig configures SMTP delivery of budget alerts.
+type SpendAlertEmailConfig struct {
+	// SMTPAddr is the mail server host:port. Empty disables email alerts.
+	SMTPAddr string `yaml:"smtp-addr,omitempty" json:"smtp-addr,omitempty"`
+
+	// Username and Password authenticate with PLAIN auth when set.
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+
+	// From is the sender address.
+	From string `yaml:"from,omitempty" json:"from,omitempty"`
+
+	// To lists the recipients.
+	To []string `yaml:"to,omitempty" json:"to,omitempty"`
+}
+
+// SpendBudget limits the estimated spend of client keys or tenants over a period.
+type SpendBudget struct {
+	// Name identifies the budget in alerts and the management API.
+	Name string `yaml:"name" json:"name"`
+
+	// APIKeys lists the client keys the budget applies to, each with its own spend; "*" matches every key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Tenants lists the tenants the budget applies to, each with its own spend; "*" matches every tenant.
+	Tenants []string `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+
+	// LimitUSD is the budget in US dollars.
+	LimitUSD float64 `yaml:"limit-usd" json:"limit-usd"`
+
+	// Period is "monthly" (default), "daily" or "total". Periods follow UTC calendar boundaries.
+	Period string `yaml:"period,omitempty" json:"period,omitempty"`
+
+	// AlertThresholds are percentages of LimitUSD that trigger an alert once per period.
+	// Default is 50, 80 and 100.
+	AlertThresholds []int `yaml:"alert-thresholds,omitempty" json:"alert-thresholds,omitempty"`
+
+	// HardStop rejects further requests with a budget_exceeded error once the limit is reached.
+	HardStop bool `yaml:"hard-stop,omitempty" json:"hard-stop,omitempty"`
+}
+
+// WebhookConfig configures one webhook endpoint.
+type WebhookConfig struct {
+	// Name identifies the webhook in logs.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// URL receives a POST for every subscribed event.
+	URL string `yaml:"url" json:"url"`
+
+	// Secret signs each delivery with HMAC-SHA256; the signature is sent in X-CLIProxy-Signature.
+	Secret string `yaml:"secret,omitempty" json:"secret,omitempty"`
+
+	// Events lists the subscribed event types. Empty or "*" subscribes to every event.
+	Events []string `yaml:"events,omitempty" json:"events,omitempty"`
+
+	// Format is "json" (default) for the full event or "slack" for a {"text": ...} message.
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+
+	// Headers are added to every delivery, e.g. an Authorization token.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// MaxRetries bounds the redeliveries after a failed attempt. Default is 3; negative disables them.
+	MaxRetries int `yaml:"max-retries,omitempty" json:"max-retries,omitempty"`
+}
+
+// RequestTraceConfig configures per-request routing decision traces.
+type RequestTraceConfig struct {
+	// Always traces every request, not only those sending X-CLIProxy-Trace or X-CLIProxy-Trace-Id.
+	Always bool `yaml:"always,omitempty" json:"always,omitempty"`
+
+	// MaxEntries bounds the traces kept in memory. Default is 1000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// RetentionMinutes is how long traces stay retrievable. Default is 60.
+	RetentionMinutes int `yaml:"retention-minutes,omitempty" json:"retention-minutes,omitempty"`
+}
+
+// StateStoreConfig selects the durable state backend.
+type StateStoreConfig struct {
+	// Enable toggles the durable state store. When false, subsystems keep state in memory only.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Driver selects the backend: "sqlite" (default) or "postgres".
+	Driver string `yaml:"driver,omitempty" json:"driver,omitempty"`
+
+	// Path is the SQLite database file. Defaults to state.db inside the auth directory.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// DSN is the Postgres connection string.
+	DSN string `yaml:"dsn,omitempty" json:"-"`
+
+	// UsageFlushSeconds controls how often usage statistics are persisted. Default is 60.
+	UsageFlushSeconds int `yaml:"usage-flush-seconds,omitempty" json:"usage-flush-seconds,omitempty"`
+}
+
+// ListenerConfig describes an additional listener.
+type ListenerConfig struct {
+	// Network is "tcp" (default) or "unix".
+	Network string `yaml:"network,omitempty" json:"network,omitempty"`
+	// Address is host:port for tcp or the socket path for unix.
+	Address string `yaml:"address" json:"address"`
+	// Serve selects the exposed routes: "all" (default), "api" or "management".
+	Serve string `yaml:"serve,omitempty" json:"serve,omitempty"`
+	// TLS serves HTTPS with the certificates configured under tls. Ignored for unix sockets.
+	TLS bool `yaml:"tls,omitempty" json:"tls,omitempty"`
+	// SocketMode sets the unix socket file permissions, e.g. "0660". Default is 0600.
+	SocketMode string `yaml:"socket-mode,omitempty" json:"socket-mode,omitempty"`
+}
+
+// GRPCConfig configures the gRPC listener. Calls go through the same authentication, quotas
+// and routing as the HTTP API. Changes take effect on restart.
+type GRPCConfig struct {
+	// Enable starts the gRPC listener.
+	Enable bool `yaml:"enable" json:"enable"`
+	// Address is the host:port to listen on. Default is ":8319".
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+	// TLS serves the listener with the certificates configured under tls.
+	TLS bool `yaml:"tls,omitempty" json:"tls,omitempty"`
 }
 
 // TLSConfig holds HTTPS server settings.
@@ -113,6 +451,347 @@ type TLSConfig struct {
 	Cert string `yaml:"cert" json:"cert"`
 	// Key is the path to the TLS private key file.
 	Key string `yaml:"key" json:"key"`
+	// ClientCA is the path to a PEM bundle of CAs trusted to sign client certificates.
+	// Setting it enables mutual TLS on the listener.
+	ClientCA string `yaml:"client-ca,omitempty" json:"client-ca,omitempty"`
+	// ClientAuth is "require" (default when ClientCA is set) to reject connections without a
+	// valid client certificate, or "optional" to verify certificates only when presented.
+	ClientAuth string `yaml:"client-auth,omitempty" json:"client-auth,omitempty"`
+	// ACME provisions and renews the listener certificate automatically. When enabled, Cert and
+	// Key are not used.
+	ACME ACMEConfig `yaml:"acme,omitempty" json:"acme,omitempty"`
+}
+
+// ACMEConfig configures automatic certificates from an ACME CA such as Let's Encrypt.
+type ACMEConfig struct {
+	// Enable turns on automatic certificate management.
+	Enable bool `yaml:"enable" json:"enable"`
+	// Domains lists the host names certificates may be issued for.
+	Domains []string `yaml:"domains" json:"domains"`
+	// Email is the contact address registered with the CA.
+	Email string `yaml:"email,omitempty" json:"email,omitempty"`
+	// CacheDir stores account keys and certificates. Default is "acme" inside auth-dir.
+	CacheDir string `yaml:"cache-dir,omitempty" json:"cache-dir,omitempty"`
+	// DirectoryURL selects the ACME directory. Default is Let's Encrypt production.
+	DirectoryURL string `yaml:"directory-url,omitempty" json:"directory-url,omitempty"`
+	// HTTPChallengePort serves HTTP-01 challenges and redirects plain HTTP to HTTPS. Default is 80;
+	// -1 disables it, leaving TLS-ALPN-01 on the TLS listener as the only challenge.
+	HTTPChallengePort int `yaml:"http-challenge-port,omitempty" json:"http-challenge-port,omitempty"`
+}
+
+// IPAccessConfig configures CIDR-based access control and per-IP rate limiting. The global
+// settings apply to every request; each matching rule applies in addition.
+type IPAccessConfig struct {
+	// Allow lists permitted client CIDRs or addresses. Empty permits every address not denied.
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+	// Deny lists rejected client CIDRs or addresses. Deny wins over Allow.
+	Deny []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+	// RateLimit throttles each client address.
+	RateLimit IPRateLimit `yaml:"rate-limit,omitempty" json:"rate-limit,omitempty"`
+	// TrustedProxies lists CIDRs of reverse proxies whose X-Forwarded-For header is honoured
+	// when resolving the client address. Empty uses the connection peer address only.
+	TrustedProxies []string `yaml:"trusted-proxies,omitempty" json:"trusted-proxies,omitempty"`
+	// Rules apply additional lists and limits to matching routes.
+	Rules []IPAccessRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// IPAccessRule applies access control to the routes it matches.
+type IPAccessRule struct {
+	// Routes lists request paths; a trailing "*" matches any suffix.
+	Routes []string `yaml:"routes" json:"routes"`
+	// Allow lists permitted client CIDRs or addresses for these routes.
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+	// Deny lists rejected client CIDRs or addresses for these routes.
+	Deny []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+	// RateLimit throttles each client address on these routes, separately from the global limit.
+	RateLimit IPRateLimit `yaml:"rate-limit,omitempty" json:"rate-limit,omitempty"`
+}
+
+// BodyLimitsConfig caps request body sizes. Requests over the limit are rejected with 413
+// before the body is read when they declare a Content-Length, and as soon as the limit is
+// crossed otherwise.
+type BodyLimitsConfig struct {
+	// MaxBytes is the limit for every route without a more specific rule. 0 disables it.
+	MaxBytes int64 `yaml:"max-bytes,omitempty" json:"max-bytes,omitempty"`
+	// Routes sets limits for specific request paths; the first matching rule wins.
+	Routes []BodyLimitRule `yaml:"routes,omitempty" json:"routes,omitempty"`
+	// MultipartMemoryBytes is how much of a multipart upload is held in memory; the rest is
+	// spooled to temporary files. Default is 8 MiB; changes take effect on restart.
+	MultipartMemoryBytes int64 `yaml:"multipart-memory-bytes,omitempty" json:"multipart-memory-bytes,omitempty"`
+}
+
+// BodyLimitRule is a per-route body size limit.
+type BodyLimitRule struct {
+	// Routes lists request paths; a trailing "*" matches any suffix.
+	Routes []string `yaml:"routes" json:"routes"`
+	// MaxBytes is the limit for these routes. 0 removes the limit.
+	MaxBytes int64 `yaml:"max-bytes" json:"max-bytes"`
+}
+
+// IPRateLimit is a per-address token bucket. A zero RequestsPerMinute disables it.
+type IPRateLimit struct {
+	// RequestsPerMinute is the sustained request rate.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+	// Burst is the bucket size. Default is RequestsPerMinute.
+	Burst int `yaml:"burst,omitempty" json:"burst,omitempty"`
+}
+
+// UpstreamTLSConfig presents a client certificate to upstream hosts that require mutual TLS.
+type UpstreamTLSConfig struct {
+	// Hosts lists upstream host names the certificate is used for; "*" wildcards are allowed.
+	Hosts []string `yaml:"hosts" json:"hosts"`
+	// Cert is the path to the PEM client certificate.
+	Cert string `yaml:"cert" json:"cert"`
+	// Key is the path to the PEM client private key.
+	Key string `yaml:"key" json:"key"`
+	// CA optionally replaces the system roots with a PEM bundle for verifying the upstream.
+	CA string `yaml:"ca,omitempty" json:"ca,omitempty"`
+}
+
+// CassetteConfig controls recording and replay of upstream HTTP traffic.
+type CassetteConfig struct {
+	// Mode is "record" (call upstream and save every exchange), "replay" (serve saved exchanges
+	// and fail on a miss) or "auto" (replay when a recording exists, record otherwise). Empty
+	// disables cassettes.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+	// Dir holds the cassette files. Default is "cassettes".
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+	// ReplayTiming paces replayed streams with the recorded chunk timing.
+	ReplayTiming bool `yaml:"replay-timing,omitempty" json:"replay-timing,omitempty"`
+	// RedactHeaders adds header names to the built-in list of credentials removed before saving.
+	RedactHeaders []string `yaml:"redact-headers,omitempty" json:"redact-headers,omitempty"`
+	// IgnoreFields lists JSON request body paths (gjson syntax) left out of the match key, for
+	// fields such as generated session ids that differ on every run.
+	IgnoreFields []string `yaml:"ignore-fields,omitempty" json:"ignore-fields,omitempty"`
+}
+
+// UpstreamProxyConfig sends matching upstream traffic through its own outbound proxy.
+// Credential-level proxy-url settings take precedence over these entries, which take precedence
+// over the global proxy-url.
+type UpstreamProxyConfig struct {
+	// Name identifies the entry in logs and the management API.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Providers lists provider identifiers (e.g. "claude", "qwen") routed through the proxy.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+	// Hosts lists upstream host names routed through the proxy; "*" wildcards are allowed.
+	Hosts []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	// ProxyURLs lists socks5://, http:// or https:// proxies with optional user:pass credentials,
+	// in order of preference. Proxies failing the health check are skipped.
+	ProxyURLs []string `yaml:"proxy-urls" json:"proxy-urls"`
+}
+
+// FingerprintConfig is a request profile applied to every upstream request of its providers.
+type FingerprintConfig struct {
+	// Name identifies the profile in logs.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Providers lists provider identifiers (e.g. "claude", "codex", "gemini-cli") using the profile.
+	Providers []string `yaml:"providers" json:"providers"`
+	// UserAgent replaces the User-Agent header.
+	UserAgent string `yaml:"user-agent,omitempty" json:"user-agent,omitempty"`
+	// Headers replace the built-in values of these headers; an empty value removes the header.
+	// Headers configured on the credential itself still take precedence.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// TLS shapes the TLS client handshake.
+	TLS FingerprintTLSConfig `yaml:"tls,omitempty" json:"tls,omitempty"`
+}
+
+// FingerprintTLSConfig adjusts the TLS client settings the standard library sends in its
+// ClientHello. Empty fields keep the defaults.
+type FingerprintTLSConfig struct {
+	// MinVersion and MaxVersion bound the protocol version: "1.2" or "1.3".
+	MinVersion string `yaml:"min-version,omitempty" json:"min-version,omitempty"`
+	MaxVersion string `yaml:"max-version,omitempty" json:"max-version,omitempty"`
+	// CipherSuites lists TLS 1.2 cipher suite names in preference order, e.g.
+	// "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256". TLS 1.3 suites are not configurable.
+	CipherSuites []string `yaml:"cipher-suites,omitempty" json:"cipher-suites,omitempty"`
+	// Curves lists key exchange groups in preference order: "X25519MLKEM768", "X25519", "P256",
+	// "P384" or "P521".
+	Curves []string `yaml:"curves,omitempty" json:"curves,omitempty"`
+	// DisableHTTP2 offers only http/1.1 during ALPN.
+	DisableHTTP2 bool `yaml:"disable-http2,omitempty" json:"disable-http2,omitempty"`
+}
+
+// ProxyHealthCheckConfig controls the upstream proxy health checks.
+type ProxyHealthCheckConfig struct {
+	// Enable turns on periodic proxy health checks.
+	Enable bool `yaml:"enable" json:"enable"`
+	// IntervalSeconds controls how often proxies are probed. Default is 60.
+	IntervalSeconds int `yaml:"interval-seconds,omitempty" json:"interval-seconds,omitempty"`
+	// TimeoutSeconds bounds a single probe. Default is 10.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+	// URL is fetched through each proxy. Default is https://www.gstatic.com/generate_204.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+}
+
+// SystemPromptRule rewrites the system prompt of matching requests after translation.
+type SystemPromptRule struct {
+	// Name identifies the rule in logs.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Models lists upstream model names the rule applies to; "*" wildcards are allowed. Empty
+	// matches every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+	// APIKeys restricts the rule to requests authenticated with these client keys. Empty matches
+	// every client.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+	// Action is "prepend", "append" or "replace".
+	Action string `yaml:"action" json:"action"`
+	// Text is added to or replaces the system prompt. Replacing with an empty text removes the
+	// system prompt.
+	Text string `yaml:"text,omitempty" json:"text,omitempty"`
+}
+
+// ReasoningRule controls reasoning depth for matching upstream models, whatever protocol the
+// client used to request it.
+type ReasoningRule struct {
+	// Name identifies the rule in logs.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Models lists upstream model names the rule applies to; "*" wildcards are allowed. Empty
+	// matches every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+	// DefaultEffort is applied when the request carries no reasoning setting: "none",
+	// "minimal", "low", "medium", "high" or "xhigh".
+	DefaultEffort string `yaml:"default-effort,omitempty" json:"default-effort,omitempty"`
+	// MinBudget and MaxBudget clamp enabled thinking budgets, in tokens. Effort levels are
+	// clamped to the levels these budgets map to. <= 0 leaves that side unbounded.
+	MinBudget int `yaml:"min-budget,omitempty" json:"min-budget,omitempty"`
+	MaxBudget int `yaml:"max-budget,omitempty" json:"max-budget,omitempty"`
+}
+
+// FinishReasonOverride maps one finish reason reported by an upstream protocol to the value
+// reported to clients of another protocol.
+type FinishReasonOverride struct {
+	// From is the upstream dialect: "openai", "claude", "gemini" or "responses".
+	From string `yaml:"from" json:"from"`
+	// To is the client dialect, using the same names as From.
+	To string `yaml:"to" json:"to"`
+	// Reason is the upstream value, matched case-insensitively (e.g. "RECITATION").
+	Reason string `yaml:"reason" json:"reason"`
+	// Value is the finish reason reported to the client (e.g. "refusal").
+	Value string `yaml:"value" json:"value"`
+}
+
+// ModelMaxTokens bounds the output tokens requested from a model.
+type ModelMaxTokens struct {
+	// Model is the model name or client alias; "*" matches any substring.
+	Model string `yaml:"model" json:"model"`
+	// Limit is the largest output token count the upstream accepts; larger requests are lowered to it.
+	Limit int `yaml:"limit,omitempty" json:"limit,omitempty"`
+	// Default is used when a client omits the limit and the upstream requires one.
+	Default int `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// MCPServerConfig configures the embedded MCP server. MCP clients reach it over the
+// streamable HTTP transport at /mcp, authenticated like the other API routes, and get an
+// ask_model tool that sends a prompt to any routed model.
+type MCPServerConfig struct {
+	// Enable mounts the MCP endpoint. Disabled by default.
+	Enable bool `yaml:"enable" json:"enable"`
+	// Models limits which models the tools may query; "*" wildcards are allowed. Empty allows all.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+	// DefaultModel is used when a tool call does not name a model.
+	DefaultModel string `yaml:"default-model,omitempty" json:"default-model,omitempty"`
+}
+
+// HealthCheckConfig controls the background upstream health probes.
+type HealthCheckConfig struct {
+	// Enable turns on periodic probes of every enabled credential.
+	Enable bool `yaml:"enable" json:"enable"`
+	// IntervalSeconds controls how often credentials are probed. Default is 60.
+	IntervalSeconds int `yaml:"interval-seconds,omitempty" json:"interval-seconds,omitempty"`
+	// TimeoutSeconds bounds a single probe. Default is 30.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+	// Models maps a provider to the model used for its probes. Providers without an entry are
+	// probed with the first model registered for the credential.
+	Models map[string]string `yaml:"models,omitempty" json:"models,omitempty"`
+	// MinHealthy is the number of healthy credentials /ready requires. Default is 1.
+	MinHealthy int `yaml:"min-healthy,omitempty" json:"min-healthy,omitempty"`
+}
+
+// CredentialEncryptionConfig controls encryption of stored credentials. The key is re-read when
+// the section changes on config reload; a key that fails to load keeps the previous one active.
+type CredentialEncryptionConfig struct {
+	// Enable encrypts the secret fields of credentials when they are written.
+	Enable bool `yaml:"enable" json:"enable"`
+	// KeyEnv names the environment variable holding the key: 32 bytes in base64 or hex, or a
+	// passphrase. Default is CLIPROXY_CREDENTIAL_KEY.
+	KeyEnv string `yaml:"key-env,omitempty" json:"key-env,omitempty"`
+	// Keychain reads the key from the OS keychain (service "cliproxyapi", account
+	// "credential-key") when the environment variable is unset.
+	Keychain bool `yaml:"keychain,omitempty" json:"keychain,omitempty"`
+}
+
+// CredentialHealthConfig controls automatic disabling of failing credentials.
+type CredentialHealthConfig struct {
+	// Enable turns on tracking of consecutive 401/403 and quota-exhausted responses.
+	Enable bool `yaml:"enable" json:"enable"`
+	// FailureThreshold is the number of consecutive failures that remove a credential from
+	// rotation. Default is 3.
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure-threshold,omitempty"`
+	// RecheckIntervalSeconds is the delay before the first re-check of a removed credential; it
+	// doubles after every failed re-check. Default is 300.
+	RecheckIntervalSeconds int `yaml:"recheck-interval-seconds,omitempty" json:"recheck-interval-seconds,omitempty"`
+	// MaxRecheckIntervalSeconds caps the re-check backoff. Default is 3600.
+	MaxRecheckIntervalSeconds int `yaml:"max-recheck-interval-seconds,omitempty" json:"max-recheck-interval-seconds,omitempty"`
+}
+
+// UpstreamTransportConfig controls how upstream connections are established and reused.
+type UpstreamTransportConfig struct {
+	// DisableHTTP2 keeps upstream connections on HTTP/1.1 instead of negotiating HTTP/2.
+	DisableHTTP2 bool `yaml:"disable-http2,omitempty" json:"disable-http2,omitempty"`
+	// VertexGRPC streams Vertex AI service-account requests over the native gRPC API instead of
+	// REST server-sent events.
+	VertexGRPC bool `yaml:"vertex-grpc,omitempty" json:"vertex-grpc,omitempty"`
+	// MaxIdleConns caps idle connections across all upstream hosts. Default is 256.
+	MaxIdleConns int `yaml:"max-idle-conns,omitempty" json:"max-idle-conns,omitempty"`
+	// MaxIdleConnsPerHost caps idle connections kept per upstream host. Default is 64.
+	MaxIdleConnsPerHost int `yaml:"max-idle-conns-per-host,omitempty" json:"max-idle-conns-per-host,omitempty"`
+	// IdleConnTimeoutSeconds closes connections idle for longer than this. Default is 90.
+	IdleConnTimeoutSeconds int `yaml:"idle-conn-timeout-seconds,omitempty" json:"idle-conn-timeout-seconds,omitempty"`
+	// KeepAliveSeconds sets the TCP keep-alive period of direct upstream connections. Default is 30.
+	KeepAliveSeconds int `yaml:"keep-alive-seconds,omitempty" json:"keep-alive-seconds,omitempty"`
+	// DNSRefreshSeconds periodically drops idle connections so new ones re-resolve DNS. 0 disables.
+	DNSRefreshSeconds int `yaml:"dns-refresh-seconds,omitempty" json:"dns-refresh-seconds,omitempty"`
+	// WarmUp lists upstream URLs to pre-connect at startup and after idle periods.
+	WarmUp []string `yaml:"warm-up,omitempty" json:"warm-up,omitempty"`
+	// WarmUpIntervalSeconds controls how often WarmUp connections are refreshed. Default is
+	// two thirds of the idle timeout so warmed connections never expire.
+	WarmUpIntervalSeconds int `yaml:"warm-up-interval-seconds,omitempty" json:"warm-up-interval-seconds,omitempty"`
+	// Timeouts bounds each phase of an upstream request. Zero fields disable that bound.
+	Timeouts UpstreamTimeoutConfig `yaml:"timeouts,omitempty" json:"timeouts,omitempty"`
+	// Upstreams overrides pool settings for specific upstream hosts.
+	Upstreams []UpstreamPoolConfig `yaml:"upstreams,omitempty" json:"upstreams,omitempty"`
+}
+
+// UpstreamTimeoutConfig sets per-phase upstream timeouts. Each expired phase fails the request
+// with 504 and its own error code.
+type UpstreamTimeoutConfig struct {
+	// ConnectSeconds bounds establishing the connection, including proxy dialing and the TLS
+	// handshake (upstream_connect_timeout).
+	ConnectSeconds int `yaml:"connect-seconds,omitempty" json:"connect-seconds,omitempty"`
+	// FirstByteSeconds bounds the wait for response headers once connected
+	// (upstream_first_byte_timeout).
+	FirstByteSeconds int `yaml:"first-byte-seconds,omitempty" json:"first-byte-seconds,omitempty"`
+	// IdleSeconds bounds the gap between two reads of the response body, i.e. between stream
+	// chunks (upstream_idle_timeout).
+	IdleSeconds int `yaml:"idle-seconds,omitempty" json:"idle-seconds,omitempty"`
+	// TotalSeconds bounds the whole request including the full streamed response
+	// (upstream_duration_exceeded).
+	TotalSeconds int `yaml:"total-seconds,omitempty" json:"total-seconds,omitempty"`
+}
+
+// UpstreamPoolConfig overrides connection pool settings for matching upstream hosts. Zero values
+// inherit the upstream-transport defaults.
+type UpstreamPoolConfig struct {
+	// Hosts lists upstream host names the settings apply to; "*" wildcards are allowed.
+	Hosts []string `yaml:"hosts" json:"hosts"`
+	// MaxIdleConnsPerHost caps idle connections kept per matching host.
+	MaxIdleConnsPerHost int `yaml:"max-idle-conns-per-host,omitempty" json:"max-idle-conns-per-host,omitempty"`
+	// IdleConnTimeoutSeconds closes connections idle for longer than this.
+	IdleConnTimeoutSeconds int `yaml:"idle-conn-timeout-seconds,omitempty" json:"idle-conn-timeout-seconds,omitempty"`
+	// DNSRefreshSeconds periodically drops idle connections so new ones re-resolve DNS.
+	DNSRefreshSeconds int `yaml:"dns-refresh-seconds,omitempty" json:"dns-refresh-seconds,omitempty"`
+	// Timeouts overrides the phase timeouts for matching hosts; zero fields inherit the defaults.
+	Timeouts UpstreamTimeoutConfig `yaml:"timeouts,omitempty" json:"timeouts,omitempty"`
 }
 
 // RemoteManagement holds management API configuration under 'remote-management'.
@@ -121,7 +800,8 @@ type RemoteManagement struct {
 	AllowRemote bool `yaml:"allow-remote"`
 	// SecretKey is the management key (plaintext or bcrypt hashed). YAML key intentionally 'secret-key'.
 	SecretKey string `yaml:"secret-key"`
-	// DisableControlPanel skips serving and syncing the bundled management UI when true.
+	// DisableControlPanel skips serving and syncing the bundled management UI and the embedded
+	// /admin/ui dashboard when true.
 	DisableControlPanel bool `yaml:"disable-control-panel"`
 	// PanelGitHubRepository overrides the GitHub repository used to fetch the management panel asset.
 	// Accepts either a repository URL (https://github.com/org/repo) or an API releases endpoint.
@@ -138,10 +818,22 @@ type QuotaExceeded struct {
 	SwitchPreviewModel bool `yaml:"switch-preview-model" json:"switch-preview-model"`
 }
 
+// QuotaWindowsConfig controls pre-emptive rotation based on the usage windows reported by
+// subscription upstreams (Claude five-hour and weekly windows, Codex plan limits).
+type QuotaWindowsConfig struct {
+	// Enable prefers credentials with headroom over those within the reserve.
+	Enable bool `yaml:"enable" json:"enable"`
+	// ReservePercent is the share of every window kept unused. Default is 10.
+	ReservePercent float64 `yaml:"reserve-percent,omitempty" json:"reserve-percent,omitempty"`
+	// Providers overrides ReservePercent per provider.
+	Providers map[string]float64 `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
 // RoutingConfig configures how credentials are selected for requests.
 type RoutingConfig struct {
 	// Strategy selects the credential selection strategy.
-	// Supported values: "round-robin" (default), "fill-first".
+	// Supported values: "round-robin" (default), "fill-first", "latency" (prefer the credentials
+	// with the lowest time to first token and highest throughput).
 	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
 }
 
@@ -369,6 +1061,16 @@ type OpenAICompatibility struct {
 
 	// Headers optionally adds extra HTTP headers for requests sent to this provider.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// RerankFormat selects the dialect used for /v1/rerank requests sent to this provider:
+	// "cohere" (default), "voyage", or "jina". Requests go to base-url + "/rerank".
+	RerankFormat string `yaml:"rerank-format,omitempty" json:"rerank-format,omitempty"`
+
+	// StreamMode tells response translators how this provider streams text: "delta" (every
+	// frame is new text), "snapshot" (every frame repeats the full text so far) or "auto"
+	// (default, detects snapshots by prefix). Providers that legitimately resend identical text
+	// should use "delta".
+	StreamMode string `yaml:"stream-mode,omitempty" json:"stream-mode,omitempty"`
 }
 
 // OpenAICompatibilityAPIKey represents an API key configuration with optional proxy setting.
@@ -435,6 +1137,11 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	cfg.DisableCooling = false
 	cfg.AmpCode.RestrictManagementToLocalhost = false // Default to false: API key auth is sufficient
 	cfg.RemoteManagement.PanelGitHubRepository = DefaultPanelGitHubRepository
+	// Resolve ${env:NAME} and ${file:/path} secret references before decoding.
+	data, cfg.secretRefs, err = resolveSecretRefs(data, filepath.Dir(configFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config secrets: %w", err)
+	}
 	if err = yaml.Unmarshal(data, &cfg); err != nil {
 		if optional {
 			// In cloud deploy mode, if YAML parsing fails, return empty config instead of error.
@@ -463,11 +1170,17 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		if errHash != nil {
 			return nil, fmt.Errorf("failed to hash remote management key: %w", errHash)
 		}
-		cfg.RemoteManagement.SecretKey = hashed
+		if raw, ok := cfg.secretReference(cfg.RemoteManagement.SecretKey); ok {
+			// Keys supplied through a secret reference are hashed in memory only.
+			cfg.rememberSecretReference(hashed, raw)
+			cfg.RemoteManagement.SecretKey = hashed
+		} else {
+			cfg.RemoteManagement.SecretKey = hashed
 
-		// Persist the hashed value back to the config file to avoid re-hashing on next startup.
-		// Preserve YAML comments and ordering; update only the nested key.
-		_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+			// Persist the hashed value back to the config file to avoid re-hashing on next startup.
+			// Preserve YAML comments and ordering; update only the nested key.
+			_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+		}
 	}
 
 	cfg.RemoteManagement.PanelGitHubRepository = strings.TrimSpace(cfg.RemoteManagement.PanelGitHubRepository)
@@ -804,6 +1517,8 @@ func SaveConfigPreserveComments(configFile string, cfg *Config) error {
 	// Merge generated into original in-place, preserving comments/order of existing nodes.
 	mergeMappingPreserve(original.Content[0], generated.Content[0])
 	normalizeCollectionNodeStyles(original.Content[0])
+	// Keep secret references in place of the values they resolved to.
+	restoreSecretRefs(original.Content[0], cfg)
 
 	// Write back.
 	f, err := os.Create(configFile)
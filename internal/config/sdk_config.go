@@ -25,6 +25,821 @@ type SDKConfig struct {
 
 	// Streaming configures server-side streaming behavior (keep-alives and safe bootstrap retries).
 	Streaming StreamingConfig `yaml:"streaming" json:"streaming"`
+
+	// ResponseCache configures optional caching of deterministic non-streaming responses.
+	ResponseCache ResponseCacheConfig `yaml:"response-cache,omitempty" json:"response-cache,omitempty"`
+
+	// SemanticCache configures optional similarity-based caching of non-streaming responses.
+	SemanticCache SemanticCacheConfig `yaml:"semantic-cache,omitempty" json:"semantic-cache,omitempty"`
+
+	// Transforms lists ordered request/response transformers applied per route.
+	Transforms []TransformRule `yaml:"transforms,omitempty" json:"transforms,omitempty"`
+
+	// RequestPriority configures how requests are classified for upstream queueing.
+	RequestPriority RequestPriorityConfig `yaml:"request-priority,omitempty" json:"request-priority,omitempty"`
+
+	// MessageBatches configures the emulated /v1/messages/batches endpoints.
+	MessageBatches MessageBatchesConfig `yaml:"message-batches,omitempty" json:"message-batches,omitempty"`
+
+	// OpenAIBatches configures the emulated /v1/files and /v1/batches endpoints.
+	OpenAIBatches OpenAIBatchesConfig `yaml:"openai-batches,omitempty" json:"openai-batches,omitempty"`
+
+	// Guardrails configures content moderators for /v1/moderations and inline policy enforcement.
+	Guardrails GuardrailsConfig `yaml:"guardrails,omitempty" json:"guardrails,omitempty"`
+
+	// PIIRedaction masks personal data and secrets in prompts before they are sent upstream.
+	PIIRedaction PIIRedactionConfig `yaml:"pii-redaction,omitempty" json:"pii-redaction,omitempty"`
+
+	// ResponseFilter redacts or blocks configured patterns in model output before it reaches
+	// the client, in both streaming and non-streaming responses.
+	ResponseFilter ResponseFilterConfig `yaml:"response-filter,omitempty" json:"response-filter,omitempty"`
+
+	// RequestOverrides lets trusted client keys pin the model, provider or credential of a single
+	// request with X-CLIProxy-* headers.
+	RequestOverrides RequestOverridesConfig `yaml:"request-overrides,omitempty" json:"request-overrides,omitempty"`
+
+	// ShadowTraffic mirrors a sample of requests to a secondary upstream and logs how the
+	// responses differ. Clients only ever receive the primary response.
+	ShadowTraffic ShadowTrafficConfig `yaml:"shadow-traffic,omitempty" json:"shadow-traffic,omitempty"`
+
+	// AnalyticsTee copies a sample of completed conversations to an offline sink for evaluation
+	// and fine-tuning datasets. Delivery is asynchronous and never delays responses.
+	AnalyticsTee AnalyticsTeeConfig `yaml:"analytics-tee,omitempty" json:"analytics-tee,omitempty"`
+
+	// Transcripts retains complete conversations of opted-in client keys for compliance.
+	Transcripts TranscriptsConfig `yaml:"transcripts,omitempty" json:"transcripts,omitempty"`
+
+	// CanaryRouting sends a share of the traffic for a model to an alternate upstream.
+	CanaryRouting CanaryRoutingConfig `yaml:"canary-routing,omitempty" json:"canary-routing,omitempty"`
+
+	// ScheduledRouting redirects matching requests to another upstream during configured time
+	// windows, such as routing batch traffic to a subscription account overnight.
+	ScheduledRouting ScheduledRoutingConfig `yaml:"scheduled-routing,omitempty" json:"scheduled-routing,omitempty"`
+
+	// SessionAffinity keeps every turn of a conversation on the same upstream credential.
+	SessionAffinity SessionAffinityConfig `yaml:"session-affinity,omitempty" json:"session-affinity,omitempty"`
+
+	// ContextOverflow retries requests rejected for exceeding the context window.
+	ContextOverflow ContextOverflowConfig `yaml:"context-overflow,omitempty" json:"context-overflow,omitempty"`
+
+	// Compaction summarizes older turns of conversations approaching the context window.
+	Compaction CompactionConfig `yaml:"compaction,omitempty" json:"compaction,omitempty"`
+
+	// CodeExecution emulates Claude's code execution tool with a local interpreter for upstreams
+	// that lack a server-side one.
+	CodeExecution CodeExecutionConfig `yaml:"code-execution,omitempty" json:"code-execution,omitempty"`
+
+	// MCPClient advertises the tools of external MCP servers to upstream models and executes
+	// their calls inside the proxy.
+	MCPClient MCPClientConfig `yaml:"mcp-client,omitempty" json:"mcp-client,omitempty"`
+
+	// APIKeyPolicies restricts the models, output size, capabilities and monthly token usage of
+	// individual client keys.
+	APIKeyPolicies []APIKeyPolicy `yaml:"api-key-policies,omitempty" json:"api-key-policies,omitempty"`
+
+	// CostPreflight estimates the cost of each request before dispatch and rejects or downgrades
+	// requests above a per-request cap.
+	CostPreflight CostPreflightConfig `yaml:"cost-preflight,omitempty" json:"cost-preflight,omitempty"`
+
+	// Tenants partitions the proxy into isolated tenants with their own client keys, upstream
+	// credentials, model aliases, quotas and usage accounting.
+	Tenants []TenantConfig `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+}
+
+// APIKeyPolicy limits what the listed client keys may request. The first policy listing a key
+// applies; "*" matches every key.
+type APIKeyPolicy struct {
+	// APIKeys lists the client keys the policy applies to.
+	APIKeys []string `yaml:"api-keys" json:"api-keys"`
+
+	// Models lists allowed model patterns ("*" wildcards). Empty allows every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// MaxTokens caps the requested output tokens (max_tokens and equivalents). Zero disables the cap.
+	MaxTokens int `yaml:"max-tokens,omitempty" json:"max-tokens,omitempty"`
+
+	// Capabilities limits requests to "tools", "vision" and/or "streaming". Empty allows all.
+	Capabilities []string `yaml:"capabilities,omitempty" json:"capabilities,omitempty"`
+
+	// MonthlyTokenBudget rejects requests once the key has used this many tokens in the current
+	// UTC calendar month. Zero disables the budget.
+	MonthlyTokenBudget int64 `yaml:"monthly-token-budget,omitempty" json:"monthly-token-budget,omitempty"`
+
+	// RecordTranscripts stores the key's conversations in the transcript store when transcripts
+	// are enabled.
+	RecordTranscripts bool `yaml:"record-transcripts,omitempty" json:"record-transcripts,omitempty"`
+
+	// MaxRequestCostUSD overrides cost-preflight.max-request-usd for the key. Zero uses the
+	// global cap.
+	MaxRequestCostUSD float64 `yaml:"max-request-cost-usd,omitempty" json:"max-request-cost-usd,omitempty"`
+}
+
+// CostPreflightConfig configures the cost estimate taken before a request is dispatched.
+type CostPreflightConfig struct {
+	// Enable turns on the estimate and the X-CLIProxy-Cost-Estimate response header.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// MaxRequestUSD caps the estimated cost of one request. Zero only reports estimates, unless
+	// the client key's policy sets max-request-cost-usd.
+	MaxRequestUSD float64 `yaml:"max-request-usd,omitempty" json:"max-request-usd,omitempty"`
+
+	// Action is "reject" (default) to fail requests above the cap, or "downgrade" to route them
+	// to the first configured cheaper model that fits and reject only when none does.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+
+	// Downgrades lists the cheaper models tried, in order, for matching requested models.
+	Downgrades []CostDowngrade `yaml:"downgrades,omitempty" json:"downgrades,omitempty"`
+
+	// DefaultOutputTokens is the output size assumed for requests that do not set max_tokens or
+	// an equivalent. Default is 1024.
+	DefaultOutputTokens int `yaml:"default-output-tokens,omitempty" json:"default-output-tokens,omitempty"`
+}
+
+// CostDowngrade routes requests for Models to TargetModel when their estimate exceeds the cap.
+type CostDowngrade struct {
+	// Models lists the requested model names the downgrade applies to; wildcards are supported.
+	Models []string `yaml:"models" json:"models"`
+
+	// TargetModel is the cheaper model to use.
+	TargetModel string `yaml:"target-model" json:"target-model"`
+}
+
+// TenantConfig describes one tenant. A request belongs to a tenant when its Host matches Hosts
+// or its client key is listed in APIKeys or starts with one of KeyPrefixes.
+type TenantConfig struct {
+	// Name identifies the tenant in usage statistics and logs.
+	Name string `yaml:"name" json:"name"`
+
+	// Hosts selects the tenant by request hostname; "*.example.com" matches subdomains.
+	Hosts []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+
+	// KeyPrefixes selects the tenant for client keys starting with one of the prefixes.
+	KeyPrefixes []string `yaml:"key-prefixes,omitempty" json:"key-prefixes,omitempty"`
+
+	// APIKeys are client keys owned by the tenant. They are accepted in addition to api-keys.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Credentials lists the upstream credentials owned by the tenant, matched as wildcard patterns
+	// against the credential ID or file name, or exactly against the credential prefix.
+	// Auth files may also declare "tenant": "<name>". Owned credentials serve only this tenant.
+	Credentials []string `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+
+	// SharedCredentials lets the tenant fall back to credentials not owned by any tenant.
+	SharedCredentials bool `yaml:"shared-credentials,omitempty" json:"shared-credentials,omitempty"`
+
+	// ModelAliases maps model names requested by the tenant's clients to routed models.
+	ModelAliases []TenantModelAlias `yaml:"model-aliases,omitempty" json:"model-aliases,omitempty"`
+
+	// Quota limits the tenant's request rate.
+	Quota TenantQuota `yaml:"quota,omitempty" json:"quota,omitempty"`
+}
+
+// TenantModelAlias maps Alias, as requested by a client, to the routed model Name.
+type TenantModelAlias struct {
+	Name  string `yaml:"name" json:"name"`
+	Alias string `yaml:"alias" json:"alias"`
+}
+
+// TenantQuota bounds the requests of one tenant. Zero values disable the respective limit.
+type TenantQuota struct {
+	// RequestsPerMinute is the sustained request rate, enforced as a token bucket.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// Burst is the bucket size. Default: RequestsPerMinute.
+	Burst int `yaml:"burst,omitempty" json:"burst,omitempty"`
+
+	// RequestsPerDay caps the requests per UTC day.
+	RequestsPerDay int `yaml:"requests-per-day,omitempty" json:"requests-per-day,omitempty"`
+}
+
+// ClientAPIKeys returns the top-level api-keys followed by every tenant's keys, without duplicates.
+func (cfg *SDKConfig) ClientAPIKeys() []string {
+	if cfg == nil {
+		return nil
+	}
+	if len(cfg.Tenants) == 0 {
+		return cfg.APIKeys
+	}
+	seen := make(map[string]struct{}, len(cfg.APIKeys))
+	keys := make([]string, 0, len(cfg.APIKeys))
+	add := func(key string) {
+		if key == "" {
+			return
+		}
+		if _, dup := seen[key]; dup {
+			return
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	for _, key := range cfg.APIKeys {
+		add(key)
+	}
+	for _, tenant := range cfg.Tenants {
+		for _, key := range tenant.APIKeys {
+			add(key)
+		}
+	}
+	return keys
+}
+
+// Tenant returns the tenant with the given name.
+func (cfg *SDKConfig) Tenant(name string) (*TenantConfig, bool) {
+	if cfg == nil || name == "" {
+		return nil, false
+	}
+	for i := range cfg.Tenants {
+		if cfg.Tenants[i].Name == name {
+			return &cfg.Tenants[i], true
+		}
+	}
+	return nil, false
+}
+
+// CodeExecutionConfig configures code execution emulation. When enabled, Claude-format requests
+// that declare the code_execution tool and target a matching model get a function tool instead;
+// the proxy runs each call locally and continues the conversation until the model answers.
+type CodeExecutionConfig struct {
+	// Emulate turns on code execution emulation. Disabled by default.
+	Emulate bool `yaml:"emulate" json:"emulate"`
+
+	// Models lists model aliases to emulate for; "*" wildcards are allowed. When empty, models
+	// whose providers have no native interpreter (anything but Claude and Gemini) are emulated.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Command is the interpreter command line; the program is passed on stdin. Default is
+	// ["python3", "-I", "-"]. Point it at a real sandbox such as nsjail, bwrap or a container.
+	Command []string `yaml:"command,omitempty" json:"command,omitempty"`
+
+	// TimeoutSeconds bounds each run. Default is 10.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// MaxOutputBytes caps the captured stdout and stderr of each run. Default is 65536.
+	MaxOutputBytes int `yaml:"max-output-bytes,omitempty" json:"max-output-bytes,omitempty"`
+
+	// MaxIterations bounds the execute-and-continue rounds per request. Default is 4.
+	MaxIterations int `yaml:"max-iterations,omitempty" json:"max-iterations,omitempty"`
+}
+
+// MCPClientConfig configures server-side MCP tool aggregation. The tools of every configured
+// server are merged into the tools of Claude Messages and OpenAI Chat Completions requests for
+// matching models; calls to them are executed by the proxy and the conversation continues
+// until the model answers or calls one of the client's own tools.
+type MCPClientConfig struct {
+	// Servers lists the MCP servers whose tools are advertised. Empty disables aggregation.
+	Servers []MCPClientServer `yaml:"servers,omitempty" json:"servers,omitempty"`
+
+	// Models lists model aliases that receive the tools; "*" wildcards are allowed. Empty
+	// applies to every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// MaxIterations bounds the call-and-continue rounds per request. Default is 8.
+	MaxIterations int `yaml:"max-iterations,omitempty" json:"max-iterations,omitempty"`
+
+	// TimeoutSeconds bounds each MCP request. Default is 30.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+}
+
+// MCPClientServer is one external MCP server reached over the streamable HTTP transport.
+type MCPClientServer struct {
+	// Name prefixes the server's tools as "<name>__<tool>" so tools from different servers
+	// cannot collide.
+	Name string `yaml:"name" json:"name"`
+
+	// URL is the server's MCP endpoint.
+	URL string `yaml:"url" json:"url"`
+
+	// Headers are sent with every request, typically for authorization.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// Tools limits which of the server's tools are advertised. Empty advertises all of them.
+	Tools []string `yaml:"tools,omitempty" json:"tools,omitempty"`
+}
+
+// CompactionConfig configures conversation compaction. When a request's estimated size exceeds
+// Threshold of the target model's context window, the turns before the most recent ones are
+// summarized by Model and replaced with the summary.
+type CompactionConfig struct {
+	// Enable turns on conversation compaction.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Model is the (cheap) model that writes the summaries.
+	Model string `yaml:"model" json:"model"`
+
+	// Threshold is the fraction of the context window at which compaction starts. Default is 0.8.
+	Threshold float64 `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+
+	// ContextWindows sets the context window in tokens of models, keyed by model alias with "*"
+	// wildcards. It overrides the registry and covers models without registry metadata.
+	ContextWindows map[string]int `yaml:"context-windows,omitempty" json:"context-windows,omitempty"`
+
+	// KeepRecentMessages is the number of trailing messages kept verbatim. Default is 6.
+	KeepRecentMessages int `yaml:"keep-recent-messages,omitempty" json:"keep-recent-messages,omitempty"`
+
+	// MaxSummaryTokens bounds the summary length. Default is 1024.
+	MaxSummaryTokens int `yaml:"max-summary-tokens,omitempty" json:"max-summary-tokens,omitempty"`
+}
+
+// ContextOverflowConfig configures recovery from context-length errors. A matching fallback is
+// tried first; afterwards, when Truncate is set, the oldest turns are dropped between retries.
+type ContextOverflowConfig struct {
+	// Enable turns on context overflow recovery.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Fallbacks re-route overflowing requests to models with a larger context window.
+	Fallbacks []ContextFallback `yaml:"fallbacks,omitempty" json:"fallbacks,omitempty"`
+
+	// Truncate drops the oldest half of the conversation on each retry. System prompts and
+	// the most recent messages are always kept.
+	Truncate bool `yaml:"truncate,omitempty" json:"truncate,omitempty"`
+
+	// KeepRecentMessages is the number of trailing messages truncation never drops. Default is 4.
+	KeepRecentMessages int `yaml:"keep-recent-messages,omitempty" json:"keep-recent-messages,omitempty"`
+
+	// MaxRetries bounds the recovery attempts per request. Default is 3.
+	MaxRetries int `yaml:"max-retries,omitempty" json:"max-retries,omitempty"`
+}
+
+// ContextFallback routes overflowing requests for Models to TargetModel.
+type ContextFallback struct {
+	// Models lists requested model aliases the fallback applies to; "*" wildcards are allowed.
+	Models []string `yaml:"models" json:"models"`
+
+	// TargetModel is the larger-context model to retry with.
+	TargetModel string `yaml:"target-model" json:"target-model"`
+}
+
+// SessionAffinityConfig configures conversation-sticky credential selection.
+type SessionAffinityConfig struct {
+	// Enable turns on session affinity.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// TTLSeconds is how long an idle conversation stays bound to its credential. Default is 3600.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+}
+
+// CanaryRoutingConfig configures percentage-based routing.
+type CanaryRoutingConfig struct {
+	// Rules lists the canary rules; the first rule matching the requested model applies.
+	Rules []CanaryRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// CanaryRule routes Percent of the requests for matching models to TargetModel and/or Provider.
+// Assignment is sticky: requests of the same conversation always land on the same side.
+type CanaryRule struct {
+	// Name identifies the rule in logs and the X-CLIProxy-Canary response header. Changing it
+	// reshuffles the sticky assignment.
+	Name string `yaml:"name" json:"name"`
+
+	// Models lists requested model aliases the rule applies to; "*" wildcards are allowed.
+	Models []string `yaml:"models" json:"models"`
+
+	// Percent is the share of conversations routed to the canary, from 0 to 100.
+	Percent float64 `yaml:"percent" json:"percent"`
+
+	// TargetModel optionally replaces the model for canary requests.
+	TargetModel string `yaml:"target-model,omitempty" json:"target-model,omitempty"`
+
+	// Provider optionally pins canary requests to one provider.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// StickyBy selects the hashing key: "conversation" (default), "api-key" or "request"
+	// (no stickiness).
+	StickyBy string `yaml:"sticky-by,omitempty" json:"sticky-by,omitempty"`
+}
+
+// ScheduledRoutingConfig configures time-of-day and calendar-based routing.
+type ScheduledRoutingConfig struct {
+	// Timezone is the IANA time zone rules are evaluated in unless they set their own. Default is
+	// the server's local time zone.
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// Rules lists the scheduled rules; the first rule matching the request and the current time applies.
+	Rules []ScheduledRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// ScheduledRule routes matching requests to TargetModel, Provider and/or a subset of the
+// credentials while the current time falls within its schedule.
+type ScheduledRule struct {
+	// Name identifies the rule in logs and the X-CLIProxy-Schedule response header.
+	Name string `yaml:"name" json:"name"`
+
+	// Models lists the requested model names the rule applies to; wildcards are supported.
+	Models []string `yaml:"models" json:"models"`
+
+	// APIKeys optionally limits the rule to these client API keys.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Days lists the weekdays the rule is active on ("mon" to "sun", or "weekdays" and
+	// "weekends"). Empty means every day.
+	Days []string `yaml:"days,omitempty" json:"days,omitempty"`
+
+	// Start and End bound the daily window as "HH:MM", end exclusive. A window whose end is
+	// before its start spans midnight and belongs to the day it starts on. Empty means all day.
+	Start string `yaml:"start,omitempty" json:"start,omitempty"`
+	End   string `yaml:"end,omitempty" json:"end,omitempty"`
+
+	// Dates optionally restricts the rule to calendar dates, each "YYYY-MM-DD" or a
+	// "YYYY-MM-DD..YYYY-MM-DD" inclusive range.
+	Dates []string `yaml:"dates,omitempty" json:"dates,omitempty"`
+
+	// ExcludeDates lists dates or ranges, such as holidays, on which the rule is inactive.
+	ExcludeDates []string `yaml:"exclude-dates,omitempty" json:"exclude-dates,omitempty"`
+
+	// Timezone overrides scheduled-routing.timezone for this rule.
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// TargetModel optionally replaces the model while the rule is active.
+	TargetModel string `yaml:"target-model,omitempty" json:"target-model,omitempty"`
+
+	// Provider optionally pins requests to one provider while the rule is active.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// AuthKind optionally restricts selection to "api-key" (metered) or "oauth" (subscription)
+	// credentials.
+	AuthKind string `yaml:"auth-kind,omitempty" json:"auth-kind,omitempty"`
+
+	// Credentials optionally restricts selection to credentials whose ID or file name matches
+	// one of these wildcard patterns, or whose prefix equals one of them.
+	Credentials []string `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+}
+
+// ShadowTrafficConfig configures request mirroring.
+type ShadowTrafficConfig struct {
+	// Rules lists the mirroring rules; the first rule matching the requested model applies.
+	Rules []ShadowRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// LogFile optionally appends every comparison as a JSON line. Comparisons are always logged.
+	LogFile string `yaml:"log-file,omitempty" json:"log-file,omitempty"`
+}
+
+// ShadowRule mirrors a percentage of the requests for matching models to a secondary model.
+type ShadowRule struct {
+	// Name identifies the rule in logs.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Models lists requested model names the rule applies to; "*" wildcards are allowed.
+	Models []string `yaml:"models" json:"models"`
+
+	// Percent is the share of matching requests to mirror, from 0 to 100.
+	Percent float64 `yaml:"percent" json:"percent"`
+
+	// TargetModel is the model the mirrored request is sent to.
+	TargetModel string `yaml:"target-model" json:"target-model"`
+
+	// Provider optionally pins the mirrored request to one provider of TargetModel.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+}
+
+// AnalyticsTeeConfig configures the conversation tee. Each sampled request is recorded with its
+// prompt, the reassembled final response and the tool calls it made, as one JSON line.
+type AnalyticsTeeConfig struct {
+	// Enable turns on the tee.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// SampleRate is the fraction of requests recorded, from 0 to 1. Zero records every request.
+	SampleRate float64 `yaml:"sample-rate,omitempty" json:"sample-rate,omitempty"`
+
+	// Models limits the tee to requested model names; "*" wildcards are allowed. Empty records all.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Redact applies the pii-redaction detectors and patterns to recorded conversations, whether
+	// or not outbound redaction is enabled.
+	Redact bool `yaml:"redact,omitempty" json:"redact,omitempty"`
+
+	// BatchSize is the number of records delivered together. Default 100.
+	BatchSize int `yaml:"batch-size,omitempty" json:"batch-size,omitempty"`
+
+	// FlushIntervalSeconds bounds how long a partial batch waits before delivery. Default 30.
+	FlushIntervalSeconds int `yaml:"flush-interval-seconds,omitempty" json:"flush-interval-seconds,omitempty"`
+
+	// Sink selects where records are delivered.
+	Sink AnalyticsSinkConfig `yaml:"sink" json:"sink"`
+}
+
+// TranscriptsConfig configures the transcript store. Only requests whose client key has an
+// api-key-policies entry with record-transcripts set are stored, one JSON document each.
+type TranscriptsConfig struct {
+	// Enable turns on transcript retention.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Backend is "local" (default), "s3" or "gcs". The gcs backend uses the Cloud Storage
+	// S3-compatible API with HMAC keys.
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// Directory holds transcripts for the local backend. Default "transcripts", under WRITABLE_PATH
+	// when set.
+	Directory string `yaml:"directory,omitempty" json:"directory,omitempty"`
+
+	// Bucket locates the s3 and gcs backends; the gcs endpoint defaults to storage.googleapis.com.
+	Bucket ObjectStorageConfig `yaml:"bucket,omitempty" json:"bucket,omitempty"`
+
+	// RetentionDays deletes transcripts older than this many days. Zero keeps them forever.
+	RetentionDays int `yaml:"retention-days,omitempty" json:"retention-days,omitempty"`
+
+	// Redact applies the pii-redaction detectors and patterns before transcripts are stored.
+	Redact bool `yaml:"redact,omitempty" json:"redact,omitempty"`
+}
+
+// AnalyticsSinkConfig selects the destination of tee records.
+type AnalyticsSinkConfig struct {
+	// Type is "file", "s3" or "http".
+	Type string `yaml:"type" json:"type"`
+
+	// Path is the JSON lines file appended to by the file sink.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// URL receives each batch as an application/x-ndjson POST from the http sink.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// Headers are added to every http sink request.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// S3 configures the s3 sink, which writes each batch as one JSON lines object keyed
+	// <prefix>YYYY/MM/DD/<time>-<id>.jsonl.
+	S3 ObjectStorageConfig `yaml:"s3,omitempty" json:"s3,omitempty"`
+}
+
+// ObjectStorageConfig locates a bucket on S3 or any S3-compatible service.
+type ObjectStorageConfig struct {
+	Endpoint  string `yaml:"endpoint" json:"endpoint"`
+	Bucket    string `yaml:"bucket" json:"bucket"`
+	Region    string `yaml:"region,omitempty" json:"region,omitempty"`
+	AccessKey string `yaml:"access-key,omitempty" json:"access-key,omitempty"`
+	SecretKey string `yaml:"secret-key,omitempty" json:"secret-key,omitempty"`
+	UseSSL    bool   `yaml:"use-ssl,omitempty" json:"use-ssl,omitempty"`
+	PathStyle bool   `yaml:"path-style,omitempty" json:"path-style,omitempty"`
+
+	// Prefix is prepended to every object key.
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+}
+
+// RequestOverridesConfig gates the per-request override headers X-CLIProxy-Target-Model,
+// X-CLIProxy-Provider and X-CLIProxy-Auth. Requests from other keys that send them are rejected.
+type RequestOverridesConfig struct {
+	// APIKeys lists client API keys allowed to send override headers; "*" allows every key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Allow limits the honoured overrides to "model", "provider" and/or "auth". Default is all.
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+}
+
+// PIIRedactionConfig configures the outbound PII redaction pass. Matches are replaced with
+// numbered placeholders such as "[REDACTED_EMAIL_1]" and, unless KeepPlaceholders is set,
+// placeholders echoed back by the model are restored in the response.
+type PIIRedactionConfig struct {
+	// Enable turns on redaction.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Detectors selects built-in detectors: email, api-key, jwt, bearer-token. Empty enables all.
+	Detectors []string `yaml:"detectors,omitempty" json:"detectors,omitempty"`
+
+	// Patterns adds custom regular expressions. When a pattern has a capture group only the
+	// first group is redacted.
+	Patterns []PIIPattern `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+
+	// Providers limits redaction to requests routed to these upstream providers. Empty applies to all.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// KeepPlaceholders leaves placeholders in responses instead of restoring the original values.
+	KeepPlaceholders bool `yaml:"keep-placeholders,omitempty" json:"keep-placeholders,omitempty"`
+}
+
+// PIIPattern is a named custom redaction pattern.
+type PIIPattern struct {
+	// Name labels the placeholder, e.g. "employee-id" yields "[REDACTED_EMPLOYEE_ID_1]".
+	Name string `yaml:"name" json:"name"`
+
+	// Pattern is a Go regular expression.
+	Pattern string `yaml:"pattern" json:"pattern"`
+}
+
+// ResponseFilterConfig configures the outbound response filter. Streaming responses hold back
+// the last HoldbackChars characters of text so matches split across chunks are still caught.
+type ResponseFilterConfig struct {
+	// Enable turns on the filter.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// HoldbackChars is the longest match guaranteed to be caught across chunk boundaries.
+	// Default is 128.
+	HoldbackChars int `yaml:"holdback-chars,omitempty" json:"holdback-chars,omitempty"`
+
+	// Rules lists the patterns to filter.
+	Rules []ResponseFilterRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// ResponseFilterRule is a named set of patterns and the action taken when one matches.
+type ResponseFilterRule struct {
+	// Name identifies the rule in logs and block errors.
+	Name string `yaml:"name" json:"name"`
+
+	// Pattern is a Go regular expression.
+	Pattern string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+
+	// Keywords are matched literally and case-insensitively.
+	Keywords []string `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+
+	// Action is "redact" (default) or "block".
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+
+	// Replacement substitutes redacted matches. Default is "[FILTERED]".
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+}
+
+// GuardrailsConfig declares content moderators and the policies that apply them to traffic.
+type GuardrailsConfig struct {
+	// Moderators lists the named moderator backends.
+	Moderators []GuardrailModerator `yaml:"moderators,omitempty" json:"moderators,omitempty"`
+
+	// EndpointModerators names the moderators consulted by /v1/moderations. Empty uses all of them.
+	EndpointModerators []string `yaml:"endpoint-moderators,omitempty" json:"endpoint-moderators,omitempty"`
+
+	// Policies screen matching requests and responses inline.
+	Policies []GuardrailPolicy `yaml:"policies,omitempty" json:"policies,omitempty"`
+}
+
+// GuardrailModerator configures one moderator backend.
+type GuardrailModerator struct {
+	// Name identifies the moderator in policies and logs.
+	Name string `yaml:"name" json:"name"`
+
+	// Type names a moderator registered via the SDK (built-ins: keyword, http, llm).
+	Type string `yaml:"type" json:"type"`
+
+	// Args passes moderator-specific options.
+	Args map[string]any `yaml:"args,omitempty" json:"args,omitempty"`
+}
+
+// GuardrailPolicy screens prompts and/or responses on matching routes with the named moderators.
+type GuardrailPolicy struct {
+	// Name identifies the policy in logs and error messages.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Routes lists inbound request paths the policy applies to; "*" matches all.
+	Routes []string `yaml:"routes" json:"routes"`
+
+	// Models optionally restricts the policy to model names; supports "*" wildcards. Empty matches all.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Moderators names the moderators to consult.
+	Moderators []string `yaml:"moderators" json:"moderators"`
+
+	// Check selects what is screened: "prompt", "response", or both. Default is prompt only.
+	// Streaming responses are not screened.
+	Check []string `yaml:"check,omitempty" json:"check,omitempty"`
+
+	// Action is "block" (reject with 400) or "annotate" (add the X-CLIProxy-Guardrail header). Default is block.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+}
+
+// OpenAIBatchesConfig tunes the emulated OpenAI Files and Batch APIs. Files and batches are kept
+// in the state store when one is enabled, and in memory otherwise.
+type OpenAIBatchesConfig struct {
+	// MaxConcurrency caps how many requests of one batch execute at once. Default is 4.
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
+
+	// MaxFileBytes caps the size of uploaded files. Default is 100 MiB.
+	MaxFileBytes int64 `yaml:"max-file-bytes,omitempty" json:"max-file-bytes,omitempty"`
+
+	// RetentionHours controls how long files and batches are kept. Default is 720 (30 days).
+	RetentionHours int `yaml:"retention-hours,omitempty" json:"retention-hours,omitempty"`
+}
+
+// MessageBatchesConfig tunes the emulated Anthropic Message Batches API.
+type MessageBatchesConfig struct {
+	// MaxConcurrency caps how many requests of one batch execute at once. Default is 4.
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
+
+	// MaxRequests caps the number of requests accepted in one batch. Default is 10000.
+	MaxRequests int `yaml:"max-requests,omitempty" json:"max-requests,omitempty"`
+
+	// RetentionHours controls how long finished batches and their results are kept. Default is 24.
+	RetentionHours int `yaml:"retention-hours,omitempty" json:"retention-hours,omitempty"`
+}
+
+// RequestPriorityConfig classifies requests as "interactive" or "batch". Interactive requests are
+// served first from upstream queues and may evict queued batch requests when a queue is full.
+type RequestPriorityConfig struct {
+	// BatchAPIKeys lists client API keys whose requests are always treated as batch.
+	BatchAPIKeys []string `yaml:"batch-api-keys,omitempty" json:"batch-api-keys,omitempty"`
+
+	// IgnoreHeader disables the X-CLIProxy-Priority request header.
+	IgnoreHeader bool `yaml:"ignore-header,omitempty" json:"ignore-header,omitempty"`
+}
+
+// TransformRule attaches ordered transformers to requests whose inbound route and model match.
+// Pre-translate steps mutate the client request before translation, request steps mutate the
+// translated upstream payload, and response steps mutate the translated payload returned to the
+// client.
+type TransformRule struct {
+	// Name identifies the rule in logs.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Routes lists inbound request paths (e.g. "/v1/chat/completions") the rule applies to; "*" matches all.
+	Routes []string `yaml:"routes" json:"routes"`
+
+	// Models optionally restricts the rule to model names; supports "*" wildcards. Empty matches all.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// PreTranslate lists transformers applied, in order, to client requests before translation.
+	PreTranslate []TransformStep `yaml:"pre-translate,omitempty" json:"pre-translate,omitempty"`
+
+	// Request lists transformers applied, in order, to translated upstream requests.
+	Request []TransformStep `yaml:"request,omitempty" json:"request,omitempty"`
+
+	// Response lists transformers applied, in order, to translated client responses.
+	Response []TransformStep `yaml:"response,omitempty" json:"response,omitempty"`
+}
+
+// TransformStep selects a registered transformer and its arguments.
+type TransformStep struct {
+	// Type names a transformer registered via the SDK (built-ins: system-preamble, rename-tool,
+	// set, delete, strip-header, starlark).
+	Type string `yaml:"type" json:"type"`
+
+	// Args passes transformer-specific options.
+	Args map[string]any `yaml:"args,omitempty" json:"args,omitempty"`
+}
+
+// SemanticCacheConfig controls the embedding-based semantic cache. A cached response is
+// returned when a new prompt for the same model is at least Threshold similar to a cached one.
+type SemanticCacheConfig struct {
+	// Enable toggles the semantic cache.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Routes lists the request paths the cache applies to (e.g., "/v1/chat/completions").
+	// Empty means no route is cached; use "*" to enable every route.
+	Routes []string `yaml:"routes,omitempty" json:"routes,omitempty"`
+
+	// Threshold is the minimum cosine similarity (0-1] for a cache hit. Default is 0.95.
+	Threshold float64 `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+
+	// TTLSeconds controls how long cached responses remain valid. Default is 3600.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+
+	// MaxEntries bounds the number of cached prompts per model. Default is 500.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// Embedding selects how prompts are converted to vectors.
+	Embedding SemanticCacheEmbeddingConfig `yaml:"embedding,omitempty" json:"embedding,omitempty"`
+}
+
+// SemanticCacheEmbeddingConfig selects the embedding provider for the semantic cache.
+type SemanticCacheEmbeddingConfig struct {
+	// Provider is "local" (default, hashed n-gram vectors computed in-process) or
+	// "openai" (any OpenAI-compatible /embeddings endpoint).
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// BaseURL is the OpenAI-compatible API base, e.g. "https://api.openai.com/v1".
+	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
+
+	// APIKey authenticates embedding requests.
+	APIKey string `yaml:"api-key,omitempty" json:"-"`
+
+	// Model is the embedding model name. Default is "text-embedding-3-small".
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+}
+
+// ResponseCacheConfig controls the exact-match response cache.
+// Only deterministic requests (temperature explicitly 0 and no tools) are cached.
+type ResponseCacheConfig struct {
+	// Enable toggles the response cache.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Backend selects the storage backend. Supported values: "memory" (default), "redis", "state"
+	// (the durable state store).
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// TTLSeconds controls how long cached responses remain valid. Default is 300.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+
+	// MaxEntries bounds the number of cached responses for the memory backend. Default is 1000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// KeyStrategy selects how cache keys are derived.
+	// Supported values: "body" (default, shared across clients), "body-per-key" (scoped to the client API key).
+	KeyStrategy string `yaml:"key-strategy,omitempty" json:"key-strategy,omitempty"`
+
+	// IgnoreFields lists top-level request fields excluded from the cache key (e.g., "user", "metadata").
+	IgnoreFields []string `yaml:"ignore-fields,omitempty" json:"ignore-fields,omitempty"`
+
+	// Redis configures the redis backend.
+	Redis ResponseCacheRedisConfig `yaml:"redis,omitempty" json:"redis,omitempty"`
+}
+
+// ResponseCacheRedisConfig holds connection settings for the redis response cache backend.
+type ResponseCacheRedisConfig struct {
+	// Addr is the redis server address (host:port).
+	Addr string `yaml:"addr" json:"addr"`
+
+	// Password optionally authenticates the connection.
+	Password string `yaml:"password,omitempty" json:"-"`
+
+	// DB selects the redis logical database.
+	DB int `yaml:"db,omitempty" json:"db,omitempty"`
+
+	// KeyPrefix namespaces cache keys. Default is "cliproxy:response-cache:".
+	KeyPrefix string `yaml:"key-prefix,omitempty" json:"key-prefix,omitempty"`
 }
 
 // StreamingConfig holds server streaming behavior configuration.
@@ -37,6 +852,89 @@ type StreamingConfig struct {
 	// to allow auth rotation / transient recovery.
 	// <= 0 disables bootstrap retries. Default is 0.
 	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
+
+	// ContinuationRetries controls how many times a stream that fails after output was sent is
+	// re-issued with that output as an assistant prefill, continuing the same client stream.
+	// Streams that produced tool calls are not continued. <= 0 disables continuation. Default is 0.
+	ContinuationRetries int `yaml:"continuation-retries,omitempty" json:"continuation-retries,omitempty"`
+
+	// Backpressure controls what happens when a client reading a shared (deduplicated) stream
+	// cannot keep up with the upstream.
+	Backpressure StreamBackpressureConfig `yaml:"backpressure,omitempty" json:"backpressure,omitempty"`
+
+	// Coalesce batches small upstream events into fewer flushes to the client.
+	Coalesce StreamCoalesceConfig `yaml:"coalesce,omitempty" json:"coalesce,omitempty"`
+
+	// Pacing re-paces streamed text at a target rate so large buffered upstream chunks reach
+	// the client as a steady flow.
+	Pacing StreamPacingConfig `yaml:"pacing,omitempty" json:"pacing,omitempty"`
+
+	// Cancel controls how long a shared (deduplicated) stream keeps running upstream after its
+	// last client disconnects.
+	Cancel StreamCancelConfig `yaml:"cancel,omitempty" json:"cancel,omitempty"`
+}
+
+// StreamCancelConfig sets the orphan grace period of shared streams, during which a client
+// retrying with the same Idempotency-Key can resume the stream. Streams without an
+// Idempotency-Key are always cancelled as soon as the client disconnects.
+type StreamCancelConfig struct {
+	// OrphanGraceSeconds is the grace period. <= 0 uses the default of 30 seconds.
+	OrphanGraceSeconds int `yaml:"orphan-grace-seconds,omitempty" json:"orphan-grace-seconds,omitempty"`
+
+	// Immediate cancels the upstream request as soon as the last client disconnects.
+	Immediate bool `yaml:"immediate,omitempty" json:"immediate,omitempty"`
+
+	// Routes overrides the grace period for request paths. The first matching entry applies.
+	Routes []StreamCancelRoute `yaml:"routes,omitempty" json:"routes,omitempty"`
+}
+
+// StreamCancelRoute overrides the orphan grace period for one route.
+type StreamCancelRoute struct {
+	// Path is the request path; "*" matches any characters.
+	Path string `yaml:"path" json:"path"`
+
+	OrphanGraceSeconds int  `yaml:"orphan-grace-seconds,omitempty" json:"orphan-grace-seconds,omitempty"`
+	Immediate          bool `yaml:"immediate,omitempty" json:"immediate,omitempty"`
+}
+
+// StreamCoalesceConfig controls flush batching for streamed responses. Events are written
+// unchanged; only the moment they are flushed to the connection is delayed. The first event and
+// events carrying tool calls are always flushed immediately.
+type StreamCoalesceConfig struct {
+	// FlushIntervalMs is the longest an event may wait before being flushed. <= 0 disables
+	// coalescing. Default is 0.
+	FlushIntervalMs int `yaml:"flush-interval-ms,omitempty" json:"flush-interval-ms,omitempty"`
+
+	// MaxBytes flushes early once this many bytes are pending. Default is 4096.
+	MaxBytes int `yaml:"max-bytes,omitempty" json:"max-bytes,omitempty"`
+}
+
+// StreamPacingConfig configures streamed text pacing. Text deltas are split into words and
+// released no faster than TokensPerSecond; tool call deltas and other events pass through at
+// once. Pacing only delays text that arrives faster than the target rate.
+type StreamPacingConfig struct {
+	// TokensPerSecond is the target output rate, estimated at four characters per token.
+	// <= 0 disables pacing. Default is 0.
+	TokensPerSecond int `yaml:"tokens-per-second,omitempty" json:"tokens-per-second,omitempty"`
+}
+
+// StreamBackpressureConfig configures the slow-subscriber policy for shared streams.
+type StreamBackpressureConfig struct {
+	// Policy is one of "drop" (default: end the stream with an error event), "block" (queue in
+	// memory up to MaxBufferBytes and wait up to BlockTimeoutMs per chunk for the client, without
+	// delaying other clients), "grow" (buffer in memory up to MaxBufferBytes) or "spill" (buffer
+	// to a temporary file up to MaxBufferBytes).
+	Policy string `yaml:"policy,omitempty" json:"policy,omitempty"`
+
+	// BlockTimeoutMs bounds how long the "block" policy waits for a slow client. Default is 5000.
+	BlockTimeoutMs int `yaml:"block-timeout-ms,omitempty" json:"block-timeout-ms,omitempty"`
+
+	// MaxBufferBytes caps the overflow buffer for "block", "grow" and "spill". Default is 16 MiB
+	// for "block" and "grow" and 256 MiB for "spill".
+	MaxBufferBytes int `yaml:"max-buffer-bytes,omitempty" json:"max-buffer-bytes,omitempty"`
+
+	// SpillDir is the directory used for "spill" files. Defaults to the system temp directory.
+	SpillDir string `yaml:"spill-dir,omitempty" json:"spill-dir,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
@@ -67,6 +965,10 @@ const (
 	// AccessProviderTypeConfigAPIKey is the built-in provider validating inline API keys.
 	AccessProviderTypeConfigAPIKey = "config-api-key"
 
+	// AccessProviderTypeClientCertificate is the built-in provider mapping verified TLS client
+	// certificates to principals.
+	AccessProviderTypeClientCertificate = "client-certificate"
+
 	// DefaultAccessProviderName is applied when no provider name is supplied.
 	DefaultAccessProviderName = "config-inline"
 )
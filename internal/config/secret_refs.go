@@ -0,0 +1,172 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretRefPattern matches ${env:NAME} and ${file:/path} references inside scalar values.
+var secretRefPattern = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// secretRefs records how secret references in the config file were resolved so
+// that persisted configs keep the reference instead of the resolved value.
+type secretRefs struct {
+	// raw maps a resolved scalar value back to the scalar text as written in the file.
+	raw map[string]string
+	// files lists the absolute paths of every referenced secret file.
+	files []string
+}
+
+// SecretFiles returns the absolute paths of the files referenced through ${file:...}
+// in the loaded configuration. The watcher uses them to reload on secret rotation.
+func (cfg *Config) SecretFiles() []string {
+	if cfg == nil || cfg.secretRefs == nil || len(cfg.secretRefs.files) == 0 {
+		return nil
+	}
+	return append([]string(nil), cfg.secretRefs.files...)
+}
+
+// secretReference returns the original reference text for a resolved value, if any.
+func (cfg *Config) secretReference(value string) (string, bool) {
+	if cfg == nil || cfg.secretRefs == nil || value == "" {
+		return "", false
+	}
+	raw, ok := cfg.secretRefs.raw[value]
+	return raw, ok
+}
+
+// rememberSecretReference maps an additional derived value (e.g. a hashed key) to the reference.
+func (cfg *Config) rememberSecretReference(value, raw string) {
+	if cfg == nil || cfg.secretRefs == nil || value == "" {
+		return
+	}
+	cfg.secretRefs.raw[value] = raw
+}
+
+// resolveSecretRefs substitutes ${env:NAME} and ${file:/path} references in scalar
+// values of the YAML document. Relative file paths are resolved against baseDir.
+// Trailing newlines are trimmed from file contents, matching how mounted secrets are
+// usually written. Data without references is returned unchanged.
+func resolveSecretRefs(data []byte, baseDir string) ([]byte, *secretRefs, error) {
+	if !bytes.Contains(data, []byte("${")) {
+		return data, nil, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		// Let the regular decode path report the syntax error.
+		return data, nil, nil
+	}
+	refs := &secretRefs{raw: make(map[string]string)}
+	seenFiles := make(map[string]struct{})
+	var resolveErr error
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node == nil || resolveErr != nil {
+			return
+		}
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child)
+			}
+		case yaml.MappingNode:
+			// Only values are resolved; keys are left as written.
+			for i := 1; i < len(node.Content); i += 2 {
+				walk(node.Content[i])
+			}
+		case yaml.ScalarNode:
+			if !secretRefPattern.MatchString(node.Value) {
+				return
+			}
+			raw := node.Value
+			resolved := secretRefPattern.ReplaceAllStringFunc(raw, func(match string) string {
+				parts := secretRefPattern.FindStringSubmatch(match)
+				kind, target := parts[1], strings.TrimSpace(parts[2])
+				switch kind {
+				case "env":
+					value, ok := os.LookupEnv(target)
+					if !ok {
+						if resolveErr == nil {
+							resolveErr = fmt.Errorf("environment variable %s referenced at line %d is not set", target, node.Line)
+						}
+						return ""
+					}
+					return value
+				default:
+					path := target
+					if !filepath.IsAbs(path) && baseDir != "" {
+						path = filepath.Join(baseDir, path)
+					}
+					if abs, errAbs := filepath.Abs(path); errAbs == nil {
+						path = abs
+					}
+					content, errRead := os.ReadFile(path)
+					if errRead != nil {
+						if resolveErr == nil {
+							resolveErr = fmt.Errorf("secret file referenced at line %d: %w", node.Line, errRead)
+						}
+						return ""
+					}
+					if _, ok := seenFiles[path]; !ok {
+						seenFiles[path] = struct{}{}
+						refs.files = append(refs.files, path)
+					}
+					return strings.TrimRight(string(content), "\r\n")
+				}
+			})
+			node.Value = resolved
+			if node.Style == 0 {
+				// Let the decoder infer the type so numeric and boolean fields can be referenced too.
+				node.Tag = ""
+			}
+			if resolved != "" {
+				if _, exists := refs.raw[resolved]; !exists {
+					refs.raw[resolved] = raw
+				}
+			}
+		}
+	}
+	walk(&doc)
+	if resolveErr != nil {
+		return nil, nil, resolveErr
+	}
+	if len(refs.raw) == 0 && len(refs.files) == 0 {
+		return data, nil, nil
+	}
+	resolvedData, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(refs.files)
+	return resolvedData, refs, nil
+}
+
+// restoreSecretRefs replaces resolved secret values in a YAML node tree with the
+// references they were loaded from, so secrets never end up inline on save.
+func restoreSecretRefs(node *yaml.Node, cfg *Config) {
+	if node == nil || cfg == nil || cfg.secretRefs == nil {
+		return
+	}
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			restoreSecretRefs(child, cfg)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			restoreSecretRefs(node.Content[i], cfg)
+		}
+	case yaml.ScalarNode:
+		if raw, ok := cfg.secretReference(node.Value); ok {
+			node.Value = raw
+			node.Tag = "!!str"
+		}
+	}
+}
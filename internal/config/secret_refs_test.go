@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigResolvesSecretRefs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "client-key"), []byte("sk-from-file\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	t.Setenv("TEST_SECRET_PORT", "9123")
+	t.Setenv("TEST_SECRET_MGMT", "mgmt-plain")
+	configPath := filepath.Join(dir, "config.yaml")
+	content := "port: ${env:TEST_SECRET_PORT}\n" +
+		"api-keys:\n  - ${file:client-key}\n  - inline-key\n" +
+		"remote-management:\n  secret-key: ${env:TEST_SECRET_MGMT}\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port != 9123 {
+		t.Fatalf("expected port from env, got %d", cfg.Port)
+	}
+	if len(cfg.APIKeys) != 2 || cfg.APIKeys[0] != "sk-from-file" || cfg.APIKeys[1] != "inline-key" {
+		t.Fatalf("unexpected api keys: %v", cfg.APIKeys)
+	}
+	if !looksLikeBcrypt(cfg.RemoteManagement.SecretKey) {
+		t.Fatalf("expected management key to be hashed in memory")
+	}
+	files := cfg.SecretFiles()
+	if len(files) != 1 || filepath.Base(files[0]) != "client-key" {
+		t.Fatalf("unexpected secret files: %v", files)
+	}
+
+	// Loading must not replace the reference with the hashed key.
+	if err = SaveConfigPreserveComments(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+	saved, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read saved config: %v", err)
+	}
+	text := string(saved)
+	for _, want := range []string{"${env:TEST_SECRET_PORT}", "${file:client-key}", "${env:TEST_SECRET_MGMT}", "inline-key"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected saved config to keep %q, got:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "sk-from-file") || strings.Contains(text, "$2a$") {
+		t.Fatalf("expected resolved secrets to stay out of the saved config, got:\n%s", text)
+	}
+}
+
+func TestLoadConfigSecretRefErrors(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte("api-keys:\n  - ${env:TEST_SECRET_MISSING_VAR}\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "TEST_SECRET_MISSING_VAR") {
+		t.Fatalf("expected missing env error, got %v", err)
+	}
+
+	if err := os.WriteFile(configPath, []byte("api-keys:\n  - ${file:/nonexistent/secret}\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Fatalf("expected missing file error")
+	}
+}
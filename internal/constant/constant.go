@@ -22,6 +22,18 @@ const (
 	// OpenaiResponse represents the OpenAI response format identifier.
 	OpenaiResponse = "openai-response"
 
+	// OpenAIImage represents the OpenAI image generation request format identifier.
+	OpenAIImage = "openai-image"
+
+	// OpenAITranscription represents the OpenAI audio transcription request format identifier.
+	OpenAITranscription = "openai-transcription"
+
+	// OpenAISpeech represents the OpenAI text-to-speech request format identifier.
+	OpenAISpeech = "openai-speech"
+
+	// Rerank represents the common rerank request format identifier (Cohere-style schema).
+	Rerank = "rerank"
+
 	// Antigravity represents the Antigravity response format identifier.
 	Antigravity = "antigravity"
 )
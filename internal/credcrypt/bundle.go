@@ -0,0 +1,99 @@
+package credcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	bundleFormat  = "cliproxy-credentials"
+	bundleVersion = 1
+	// MinPassphraseLength is the shortest passphrase accepted for bundles.
+	MinPassphraseLength = 8
+
+	// scrypt cost parameters written by SealBundle. OpenBundle accepts no others, so a crafted
+	// bundle cannot make the import spend unbounded memory or CPU.
+	bundleScryptN = 1 << 15
+	bundleScryptR = 8
+	bundleScryptP = 1
+)
+
+// bundleEnvelope is the on-disk form of a passphrase-encrypted credential bundle.
+type bundleEnvelope struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// SealBundle encrypts plaintext with a key derived from passphrase and a random salt, producing
+// a self-describing bundle that OpenBundle reads on another machine.
+func SealBundle(passphrase string, plaintext []byte) ([]byte, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, fmt.Errorf("credcrypt: bundle passphrase must be at least %d characters", MinPassphraseLength)
+	}
+	env := bundleEnvelope{Format: bundleFormat, Version: bundleVersion, KDF: "scrypt", N: bundleScryptN, R: bundleScryptR, P: bundleScryptP, Salt: make([]byte, 16)}
+	if _, err := io.ReadFull(rand.Reader, env.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := bundleAEAD(passphrase, env)
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, env.Nonce); err != nil {
+		return nil, err
+	}
+	env.Data = aead.Seal(nil, env.Nonce, plaintext, []byte(bundleFormat))
+	return json.MarshalIndent(env, "", "  ")
+}
+
+// OpenBundle decrypts a bundle written by SealBundle.
+func OpenBundle(passphrase string, data []byte) ([]byte, error) {
+	var env bundleEnvelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Format != bundleFormat {
+		return nil, errors.New("credcrypt: not a credential bundle")
+	}
+	if env.Version != bundleVersion || env.KDF != "scrypt" {
+		return nil, fmt.Errorf("credcrypt: unsupported bundle version %d (%s)", env.Version, env.KDF)
+	}
+	aead, err := bundleAEAD(passphrase, env)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, errors.New("credcrypt: malformed bundle")
+	}
+	plain, err := aead.Open(nil, env.Nonce, env.Data, []byte(bundleFormat))
+	if err != nil {
+		return nil, errors.New("credcrypt: wrong passphrase or corrupted bundle")
+	}
+	return plain, nil
+}
+
+func bundleAEAD(passphrase string, env bundleEnvelope) (cipher.AEAD, error) {
+	if env.N != bundleScryptN || env.R != bundleScryptR || env.P != bundleScryptP {
+		return nil, errors.New("credcrypt: unsupported bundle key parameters")
+	}
+	key, err := scrypt.Key([]byte(passphrase), env.Salt, env.N, env.R, env.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
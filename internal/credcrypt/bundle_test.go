@@ -0,0 +1,52 @@
+package credcrypt
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	plain := []byte(`{"credentials":[{"id":"claude-a.json","metadata":{"refresh_token":"rt"}}]}`)
+	bundle, err := SealBundle("long enough passphrase", plain)
+	if err != nil {
+		t.Fatalf("SealBundle() error = %v", err)
+	}
+	if bytes.Contains(bundle, []byte("refresh_token")) {
+		t.Fatal("bundle contains plaintext")
+	}
+	got, err := OpenBundle("long enough passphrase", bundle)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("OpenBundle() = %s, %v", got, err)
+	}
+	if _, err = OpenBundle("wrong passphrase", bundle); err == nil {
+		t.Fatal("OpenBundle() accepted the wrong passphrase")
+	}
+	if _, err = SealBundle("short", plain); err == nil {
+		t.Fatal("SealBundle() accepted a short passphrase")
+	}
+	if _, err = OpenBundle("long enough passphrase", []byte(`{"format":"other"}`)); err == nil {
+		t.Fatal("OpenBundle() accepted a foreign document")
+	}
+}
+
+func TestOpenBundleRejectsForeignKeyParameters(t *testing.T) {
+	bundle, err := SealBundle("long enough passphrase", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("SealBundle() error = %v", err)
+	}
+	var env map[string]any
+	if err = json.Unmarshal(bundle, &env); err != nil {
+		t.Fatal(err)
+	}
+	env["n"] = 1 << 30
+	crafted, _ := json.Marshal(env)
+	start := time.Now()
+	if _, err = OpenBundle("long enough passphrase", crafted); err == nil {
+		t.Fatal("OpenBundle() accepted an oversized scrypt cost")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("OpenBundle() spent %s before rejecting the bundle", elapsed)
+	}
+}
@@ -0,0 +1,349 @@
+// Package credcrypt encrypts the secret fields of stored credentials at rest. Tokens, API keys,
+// cookies and private keys are sealed individually with AES-256-GCM, so auth files keep their
+// provider, email and expiry readable while a copied auth directory is useless without the key.
+package credcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// Prefix marks an encrypted value.
+	Prefix = "enc:v1:"
+	// DefaultKeyEnv is the environment variable holding the key when none is configured.
+	DefaultKeyEnv = "CLIPROXY_CREDENTIAL_KEY"
+
+	keychainService = "cliproxyapi"
+	keychainAccount = "credential-key"
+	// passphraseSalt makes passphrase-derived keys specific to this application. It is fixed
+	// because the derived key must be reproducible without storing anything next to the files.
+	passphraseSalt = "cliproxyapi/credential-key/v1"
+)
+
+// ErrNoKey is returned when encrypted credentials are read without a configured key.
+var ErrNoKey = errors.New("credcrypt: credential is encrypted but no key is configured")
+
+// secretFields are the JSON fields sealed at any depth of a credential.
+var secretFields = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"api_key":       true,
+	"cookie":        true,
+	"private_key":   true,
+	"client_secret": true,
+	"token":         true,
+}
+
+type state struct {
+	aead cipher.AEAD
+	seal bool
+}
+
+var current atomic.Pointer[state]
+
+// Configure loads the key described by cfg. The key is loaded even when encryption is disabled
+// so existing encrypted credentials stay readable; new writes are sealed only when enabled.
+// It is safe to call again on config reload; on error the previously loaded key stays active.
+func Configure(cfg config.CredentialEncryptionConfig) error {
+	secret, err := loadSecret(cfg)
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		if cfg.Enable {
+			return fmt.Errorf("credcrypt: encryption enabled but %s is empty and no keychain entry was found", keyEnv(cfg))
+		}
+		current.Store(nil)
+		return nil
+	}
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return err
+	}
+	current.Store(&state{aead: aead, seal: cfg.Enable})
+	return nil
+}
+
+// Enabled reports whether new credential writes are encrypted.
+func Enabled() bool {
+	st := current.Load()
+	return st != nil && st.seal
+}
+
+func keyEnv(cfg config.CredentialEncryptionConfig) string {
+	if name := strings.TrimSpace(cfg.KeyEnv); name != "" {
+		return name
+	}
+	return DefaultKeyEnv
+}
+
+func loadSecret(cfg config.CredentialEncryptionConfig) (string, error) {
+	if secret := strings.TrimSpace(os.Getenv(keyEnv(cfg))); secret != "" {
+		return secret, nil
+	}
+	if !cfg.Keychain {
+		return "", nil
+	}
+	return keychainSecret()
+}
+
+// keychainSecret reads the key from the macOS keychain or the freedesktop secret service.
+func keychainSecret() (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w")
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", keychainAccount)
+	default:
+		return "", fmt.Errorf("credcrypt: keychain lookup is not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// The tools exit non-zero when the entry does not exist.
+			return "", nil
+		}
+		return "", fmt.Errorf("credcrypt: keychain lookup failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// newAEAD accepts a base64 or hex encoded 32-byte key, or derives one from a passphrase.
+func newAEAD(secret string) (cipher.AEAD, error) {
+	var key []byte
+	if raw, err := base64.StdEncoding.DecodeString(secret); err == nil && len(raw) == 32 {
+		key = raw
+	} else if raw, err = hex.DecodeString(secret); err == nil && len(raw) == 32 {
+		key = raw
+	} else {
+		key, err = scrypt.Key([]byte(secret), []byte(passphraseSalt), 1<<15, 8, 1, 32)
+		if err != nil {
+			return nil, fmt.Errorf("credcrypt: derive key: %w", err)
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealJSON encrypts the secret fields of a credential JSON document when encryption is enabled.
+// Values that are already encrypted are kept.
+func SealJSON(data []byte) ([]byte, error) {
+	st := current.Load()
+	if st == nil || !st.seal {
+		return data, nil
+	}
+	var doc map[string]any
+	if err := decode(data, &doc); err != nil {
+		return nil, err
+	}
+	if err := walk(doc, st.sealValue); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// OpenJSON decrypts the secret fields of a credential JSON document. Documents without
+// encrypted values are returned unchanged.
+func OpenJSON(data []byte) ([]byte, error) {
+	if !bytes.Contains(data, []byte(Prefix)) {
+		return data, nil
+	}
+	var doc map[string]any
+	if err := decode(data, &doc); err != nil {
+		return nil, err
+	}
+	if err := walk(doc, openValue); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// Open returns metadata with its secret fields decrypted. Metadata without encrypted values is
+// returned as is; otherwise a copy is decrypted so the caller's maps stay untouched.
+func Open(metadata map[string]any) (map[string]any, error) {
+	if !Encrypted(metadata) {
+		return metadata, nil
+	}
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	if err = json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	if err = walk(out, openValue); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Plaintext decrypts an existing credential file for comparison with a pending write. current
+// reports whether the file is already stored the way SealJSON would write it; a plaintext file
+// is not current once encryption is enabled, so it gets rewritten sealed.
+func Plaintext(existing []byte) (plain []byte, current bool) {
+	plain, err := OpenJSON(existing)
+	if err != nil {
+		return existing, false
+	}
+	if !Enabled() {
+		return plain, true
+	}
+	var doc map[string]any
+	if decode(existing, &doc) != nil {
+		return plain, false
+	}
+	current = true
+	_ = walk(doc, func(_, value string) (string, error) {
+		if !strings.HasPrefix(value, Prefix) {
+			current = false
+		}
+		return value, nil
+	})
+	return plain, current
+}
+
+// Encrypted reports whether a parsed credential holds encrypted values.
+func Encrypted(metadata map[string]any) bool {
+	found := false
+	_ = walk(metadata, func(_, value string) (string, error) {
+		if strings.HasPrefix(value, Prefix) {
+			found = true
+		}
+		return value, nil
+	})
+	return found
+}
+
+func decode(data []byte, doc *map[string]any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(doc); err != nil {
+		return fmt.Errorf("credcrypt: decode credential: %w", err)
+	}
+	return nil
+}
+
+// walk applies fn to every string secret field of doc, recursing into objects and arrays.
+func walk(doc map[string]any, fn func(field, value string) (string, error)) error {
+	for key, value := range doc {
+		switch v := value.(type) {
+		case string:
+			if !secretFields[strings.ToLower(key)] || v == "" {
+				continue
+			}
+			out, err := fn(key, v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			doc[key] = out
+		case map[string]any:
+			if err := walk(v, fn); err != nil {
+				return fmt.Errorf("%s.%w", key, err)
+			}
+		case []any:
+			for _, item := range v {
+				if nested, ok := item.(map[string]any); ok {
+					if err := walk(nested, fn); err != nil {
+						return fmt.Errorf("%s.%w", key, err)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sealValue encrypts value, binding it to its field name.
+func (st *state) sealValue(field, value string) (string, error) {
+	if strings.HasPrefix(value, Prefix) {
+		return value, nil
+	}
+	nonce := make([]byte, st.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := st.aead.Seal(nonce, nonce, []byte(value), []byte(strings.ToLower(field)))
+	return Prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func openValue(field, value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return value, nil
+	}
+	st := current.Load()
+	if st == nil {
+		return "", ErrNoKey
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < st.aead.NonceSize() {
+		return "", errors.New("credcrypt: malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:st.aead.NonceSize()], sealed[st.aead.NonceSize():]
+	plain, err := st.aead.Open(nil, nonce, ciphertext, []byte(strings.ToLower(field)))
+	if err != nil {
+		return "", errors.New("credcrypt: decryption failed, wrong key or tampered value")
+	}
+	return string(plain), nil
+}
+
+// Encoder writes credentials as JSON with their secret fields sealed, mirroring json.Encoder.
+type Encoder struct {
+	w      io.Writer
+	prefix string
+	indent string
+}
+
+// NewEncoder returns an encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SetIndent formats the output like json.Encoder.SetIndent.
+func (e *Encoder) SetIndent(prefix, indent string) {
+	e.prefix, e.indent = prefix, indent
+}
+
+// Encode writes the JSON encoding of v followed by a newline.
+func (e *Encoder) Encode(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if data, err = SealJSON(data); err != nil {
+		return err
+	}
+	if e.prefix != "" || e.indent != "" {
+		var buf bytes.Buffer
+		if err = json.Indent(&buf, data, e.prefix, e.indent); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	_, err = e.w.Write(append(data, '\n'))
+	return err
+}
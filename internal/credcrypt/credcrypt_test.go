@@ -0,0 +1,159 @@
+package credcrypt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const testKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // base64 of 32 bytes
+
+func configure(t *testing.T, key string, enable bool) {
+	t.Helper()
+	t.Setenv(DefaultKeyEnv, key)
+	if err := Configure(config.CredentialEncryptionConfig{Enable: enable}); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	t.Cleanup(func() { current.Store(nil) })
+}
+
+func TestSealAndOpenJSON(t *testing.T) {
+	configure(t, testKey, true)
+	plain := []byte(`{"type":"gemini","email":"a@example.com","refresh_token":"rt-secret","token":{"access_token":"at-secret","expiry":"2030-01-01T00:00:00Z"},"expires_in":3600}`)
+
+	sealed, err := SealJSON(plain)
+	if err != nil {
+		t.Fatalf("SealJSON() error = %v", err)
+	}
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Fatalf("sealed credential still contains a secret: %s", sealed)
+	}
+	for _, visible := range []string{`"email":"a@example.com"`, `"expiry":"2030-01-01T00:00:00Z"`, `"expires_in":3600`} {
+		if !bytes.Contains(sealed, []byte(visible)) {
+			t.Fatalf("sealed credential lost %s: %s", visible, sealed)
+		}
+	}
+	if again, _ := SealJSON(sealed); !bytes.Equal(again, sealed) {
+		t.Fatal("sealing twice re-encrypted existing values")
+	}
+
+	var doc map[string]any
+	if err = json.Unmarshal(sealed, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !Encrypted(doc) {
+		t.Fatal("Encrypted() = false for a sealed credential")
+	}
+	opened, err := Open(doc)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if opened["refresh_token"] != "rt-secret" || opened["token"].(map[string]any)["access_token"] != "at-secret" {
+		t.Fatalf("Open() = %v", opened)
+	}
+	if !strings.HasPrefix(doc["refresh_token"].(string), Prefix) {
+		t.Fatal("Open() modified the caller's metadata")
+	}
+}
+
+func TestOpenRejectsWrongKeyAndMovedValues(t *testing.T) {
+	configure(t, testKey, true)
+	sealed, err := SealJSON([]byte(`{"access_token":"at","refresh_token":"rt"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	_ = json.Unmarshal(sealed, &doc)
+
+	// A value copied into another field does not decrypt.
+	doc["access_token"] = doc["refresh_token"]
+	if _, err = Open(doc); err == nil {
+		t.Fatal("Open() accepted a value moved between fields")
+	}
+
+	configure(t, "another passphrase", true)
+	if _, err = OpenJSON(sealed); err == nil {
+		t.Fatal("OpenJSON() accepted the wrong key")
+	}
+
+	current.Store(nil)
+	if _, err = OpenJSON(sealed); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("OpenJSON() without key error = %v, want ErrNoKey", err)
+	}
+}
+
+func TestPlaintextReportsCurrentForm(t *testing.T) {
+	configure(t, testKey, false)
+	plain := []byte(`{"type":"claude","access_token":"at"}`)
+	if _, current := Plaintext(plain); !current {
+		t.Fatal("plaintext file is not current with encryption disabled")
+	}
+
+	configure(t, testKey, true)
+	if _, current := Plaintext(plain); current {
+		t.Fatal("plaintext file is current with encryption enabled")
+	}
+	sealed, _ := SealJSON(plain)
+	opened, current := Plaintext(sealed)
+	if !current || !bytes.Contains(opened, []byte(`"access_token":"at"`)) {
+		t.Fatalf("Plaintext(sealed) = %s, %v", opened, current)
+	}
+}
+
+func TestEncoderSealsWhenEnabled(t *testing.T) {
+	token := struct {
+		Type        string `json:"type"`
+		AccessToken string `json:"access_token"`
+	}{Type: "codex", AccessToken: "at"}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(token); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "{\"type\":\"codex\",\"access_token\":\"at\"}\n" {
+		t.Fatalf("Encode() without encryption = %q", buf.String())
+	}
+
+	configure(t, testKey, true)
+	buf.Reset()
+	enc := NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(token); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"access_token": "`+Prefix) || !strings.Contains(buf.String(), "\n  \"type\": \"codex\"") {
+		t.Fatalf("Encode() with encryption = %q", buf.String())
+	}
+}
+
+func TestConfigureRequiresKeyWhenEnabled(t *testing.T) {
+	t.Setenv("CUSTOM_KEY", "")
+	if err := Configure(config.CredentialEncryptionConfig{Enable: true, KeyEnv: "CUSTOM_KEY"}); err == nil {
+		t.Fatal("Configure() without key succeeded")
+	}
+	if err := Configure(config.CredentialEncryptionConfig{KeyEnv: "CUSTOM_KEY"}); err != nil || Enabled() {
+		t.Fatalf("Configure() disabled = %v, enabled %v", err, Enabled())
+	}
+}
+
+func TestConfigureReloadKeepsKeyOnError(t *testing.T) {
+	configure(t, testKey, true)
+	t.Setenv("CUSTOM_KEY", "")
+	if err := Configure(config.CredentialEncryptionConfig{Enable: true, KeyEnv: "CUSTOM_KEY"}); err == nil {
+		t.Fatal("Configure() without key succeeded")
+	}
+	if !Enabled() {
+		t.Fatal("failed Configure() dropped the active key")
+	}
+	if err := Configure(config.CredentialEncryptionConfig{}); err != nil || Enabled() {
+		t.Fatalf("Configure() disabled = %v, enabled %v", err, Enabled())
+	}
+	sealed, err := SealJSON([]byte(`{"access_token":"secret"}`))
+	if err != nil || bytes.Contains(sealed, []byte(Prefix)) {
+		t.Fatalf("SealJSON() after disabling = %s, %v", sealed, err)
+	}
+}
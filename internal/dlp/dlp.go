@@ -0,0 +1,199 @@
+// Package dlp redacts or blocks configured patterns in model responses, including streamed
+// responses whose matches may be split across chunks.
+package dlp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pii"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultHoldback    = 128
+	defaultReplacement = "[FILTERED]"
+)
+
+type rule struct {
+	name        string
+	re          *regexp.Regexp
+	block       bool
+	replacement string
+}
+
+// Filter holds the compiled rules of a configuration.
+type Filter struct {
+	rules    []rule
+	holdback int
+}
+
+// New compiles the rules described by cfg. Block rules are evaluated before redact rules so a
+// redaction never hides content that should end the response.
+func New(cfg config.ResponseFilterConfig) (*Filter, error) {
+	f := &Filter{holdback: cfg.HoldbackChars}
+	if f.holdback <= 0 {
+		f.holdback = defaultHoldback
+	}
+	for i, r := range cfg.Rules {
+		name := strings.TrimSpace(r.Name)
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i+1)
+		}
+		var alternatives []string
+		if strings.TrimSpace(r.Pattern) != "" {
+			alternatives = append(alternatives, "(?:"+r.Pattern+")")
+		}
+		var keywords []string
+		for _, kw := range r.Keywords {
+			if kw != "" {
+				keywords = append(keywords, regexp.QuoteMeta(kw))
+			}
+		}
+		if len(keywords) > 0 {
+			alternatives = append(alternatives, "(?i:"+strings.Join(keywords, "|")+")")
+		}
+		if len(alternatives) == 0 {
+			return nil, fmt.Errorf("dlp: rule %q has no pattern or keywords", name)
+		}
+		re, err := regexp.Compile(strings.Join(alternatives, "|"))
+		if err != nil {
+			return nil, fmt.Errorf("dlp: rule %q: %w", name, err)
+		}
+		compiled := rule{name: name, re: re, replacement: r.Replacement}
+		switch strings.ToLower(strings.TrimSpace(r.Action)) {
+		case "", "redact":
+		case "block":
+			compiled.block = true
+		default:
+			return nil, fmt.Errorf("dlp: rule %q: unknown action %q", name, r.Action)
+		}
+		if compiled.replacement == "" {
+			compiled.replacement = defaultReplacement
+		}
+		f.rules = append(f.rules, compiled)
+	}
+	sort.SliceStable(f.rules, func(i, j int) bool { return f.rules[i].block && !f.rules[j].block })
+	return f, nil
+}
+
+// filterText applies the rules to text. It returns the filtered text, whether anything was
+// redacted, and the name of the block rule that matched, if any.
+func (f *Filter) filterText(text string) (string, bool, string) {
+	changed := false
+	for _, r := range f.rules {
+		if !r.re.MatchString(text) {
+			continue
+		}
+		if r.block {
+			return text, changed, r.name
+		}
+		text = r.re.ReplaceAllLiteralString(text, r.replacement)
+		changed = true
+	}
+	return text, changed, ""
+}
+
+// Apply filters every string value of a complete JSON response. When a block rule matches it
+// returns a nil body and the rule name.
+func (f *Filter) Apply(body []byte) ([]byte, string) {
+	if f == nil || len(f.rules) == 0 {
+		return body, ""
+	}
+	blocked := ""
+	out := pii.RewriteStrings(body, func(value string) (string, bool) {
+		if blocked != "" {
+			return value, false
+		}
+		filtered, changed, rule := f.filterText(value)
+		if rule != "" {
+			blocked = rule
+		}
+		return filtered, changed
+	})
+	if blocked != "" {
+		return nil, blocked
+	}
+	return out, ""
+}
+
+// Stream filters the text deltas of one streamed response. The last holdback characters of text
+// are withheld until more text arrives or the content ends, then emitted by rewriting the next
+// text event or by synthesising one from the previous text event.
+type Stream struct {
+	f        *Filter
+	pending  string
+	template *sse.Block
+}
+
+// NewStream starts filtering a streamed response. It returns nil when there is nothing to filter.
+func (f *Filter) NewStream() *Stream {
+	if f == nil || len(f.rules) == 0 {
+		return nil
+	}
+	return &Stream{f: f}
+}
+
+// Process filters one streamed chunk. It returns the chunks to forward in its place, one per
+// event, which may include a synthesised text event carrying withheld text, or the name of the
+// block rule that matched.
+func (s *Stream) Process(chunk []byte) ([][]byte, string) {
+	if s == nil {
+		return [][]byte{chunk}, ""
+	}
+	blocks := sse.SplitBlocks(chunk)
+	var out [][]byte
+	for _, b := range blocks {
+		path := sse.TextPath(b.Payload)
+		if path == "" {
+			if s.pending != "" && s.template != nil && !passive(b.Payload) {
+				out = append(out, s.template.WithText(sse.TextPath(s.template.Payload), s.pending))
+				s.pending = ""
+			}
+			out = append(out, b.Bytes())
+			continue
+		}
+		filtered, _, blocked := s.f.filterText(s.pending + gjson.GetBytes(b.Payload, path).String())
+		if blocked != "" {
+			return nil, blocked
+		}
+		cut := len(filtered)
+		if !sse.EndsContent(b.Payload) {
+			cut = holdbackCut(filtered, s.f.holdback)
+		}
+		s.pending = filtered[cut:]
+		s.template = b
+		out = append(out, b.WithText(path, filtered[:cut]))
+	}
+	return out, ""
+}
+
+// Flush returns a synthesised text event carrying any withheld text at the end of the stream.
+func (s *Stream) Flush() []byte {
+	if s == nil || s.pending == "" || s.template == nil {
+		return nil
+	}
+	out := s.template.WithText(sse.TextPath(s.template.Payload), s.pending)
+	s.pending = ""
+	return out
+}
+
+// holdbackCut returns the byte offset that leaves the last n runes of text withheld.
+func holdbackCut(text string, n int) int {
+	cut := len(text)
+	for i := 0; i < n && cut > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(text[:cut])
+		cut -= size
+	}
+	return cut
+}
+
+// passive reports whether an event can pass ahead of withheld text without reordering output.
+func passive(payload []byte) bool {
+	return len(payload) == 0 || gjson.GetBytes(payload, "type").String() == "ping"
+}
@@ -0,0 +1,152 @@
+// Package events is the in-process bus for live proxy events. Subsystems publish events such as
+// started streams, routing decisions and upstream errors; the admin event stream and webhooks
+// subscribe to them.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// Event types published by the proxy.
+const (
+	TypeRequestCompleted  = "request.completed"
+	TypeRoutingDecision   = "routing.decision"
+	TypeUpstreamError     = "upstream.error"
+	TypeUpstreamFailover  = "upstream.failover"
+	TypeCredentialExpired = "credential.expired"
+	TypeBudgetThreshold   = "budget.threshold_crossed"
+	TypeStreamStarted     = "stream.started"
+	TypeStreamAborted     = "stream.aborted"
+)
+
+// Event is one live proxy event. KeyID and APIKey identify the client key of the request that
+// caused it, as a stable hash and a masked value; the key itself is never published.
+type Event struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Summary  string    `json:"summary"`
+	KeyID    string    `json:"key_id,omitempty"`
+	APIKey   string    `json:"api_key,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Upstream string    `json:"upstream,omitempty"`
+	AuthID   string    `json:"auth_id,omitempty"`
+	Data     any       `json:"data,omitempty"`
+}
+
+// Subscriber receives published events. Handle is called on the publishing goroutine and must
+// not block.
+type Subscriber interface {
+	// Wants reports whether the subscriber receives events of eventType.
+	Wants(eventType string) bool
+	// Handle delivers one event.
+	Handle(Event)
+}
+
+// Bus fans published events out to subscribers.
+type Bus struct {
+	mu   sync.RWMutex
+	seq  int
+	subs map[int]Subscriber
+}
+
+var defaultBus = NewBus()
+
+// NewBus returns a bus without subscribers.
+func NewBus() *Bus { return &Bus{subs: make(map[int]Subscriber)} }
+
+// Default returns the process-wide bus.
+func Default() *Bus { return defaultBus }
+
+// Subscribe adds s to the process-wide bus and returns a function that removes it.
+func Subscribe(s Subscriber) func() { return defaultBus.Subscribe(s) }
+
+// Wanted reports whether any subscriber of the process-wide bus receives events of eventType.
+func Wanted(eventType string) bool { return defaultBus.Wanted(eventType) }
+
+// Publish sends an event to the subscribers of the process-wide bus.
+func Publish(e Event) { defaultBus.Publish(e) }
+
+// Subscribe adds s and returns a function that removes it.
+func (b *Bus) Subscribe(s Subscriber) func() {
+	b.mu.Lock()
+	b.seq++
+	id := b.seq
+	b.subs[id] = s
+	b.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Wanted reports whether any subscriber receives events of eventType. Publishers use it to skip
+// building events nobody listens to.
+func (b *Bus) Wanted(eventType string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subs {
+		if s.Wants(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish assigns the event an ID and timestamp when missing and hands it to every subscriber
+// that wants its type.
+func (b *Bus) Publish(e Event) {
+	if e.ID == "" {
+		e.ID = newEventID()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subs {
+		if s.Wants(e.Type) {
+			s.Handle(e)
+		}
+	}
+}
+
+// WithClient fills the event's client key fields from the request carried by ctx.
+func (e Event) WithClient(ctx context.Context) Event {
+	if ctx == nil || e.KeyID != "" {
+		return e
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return e
+	}
+	if key := ginCtx.GetString("apiKey"); key != "" {
+		e.KeyID = KeyID(key)
+		e.APIKey = util.HideAPIKey(key)
+	}
+	return e
+}
+
+// KeyID returns the stable identifier published for a client key.
+func KeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func newEventID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "evt_" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,75 @@
+package events
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestBusDeliversToSubscribersWantingTheType(t *testing.T) {
+	bus := NewBus()
+	if bus.Wanted(TypeStreamStarted) {
+		t.Fatal("empty bus should want nothing")
+	}
+	streams := NewChannelSubscriber(Filter{Types: map[string]struct{}{TypeStreamStarted: {}}}, 4)
+	all := NewChannelSubscriber(Filter{}, 4)
+	unsubscribe := bus.Subscribe(streams)
+	bus.Subscribe(all)
+
+	bus.Publish(Event{Type: TypeStreamStarted})
+	bus.Publish(Event{Type: TypeUpstreamError})
+	if len(streams.Events()) != 1 || len(all.Events()) != 2 {
+		t.Fatalf("deliveries = %d, %d; want 1, 2", len(streams.Events()), len(all.Events()))
+	}
+	if e := <-streams.Events(); e.ID == "" || e.Time.IsZero() {
+		t.Fatalf("published event missing id or time: %+v", e)
+	}
+
+	unsubscribe()
+	bus.Publish(Event{Type: TypeStreamStarted})
+	if len(streams.Events()) != 0 {
+		t.Fatal("unsubscribed subscriber still receives events")
+	}
+}
+
+func TestFilterMatchesKeyModelAndUpstream(t *testing.T) {
+	f := ParseFilter(url.Values{"key": {"sk-client"}, "model": {"claude-*"}, "upstream": {"Claude"}, "types": {"a,b"}})
+	event := Event{Type: "a", KeyID: KeyID("sk-client"), Model: "claude-sonnet-4", Upstream: "claude"}
+	if !f.Match(event) {
+		t.Fatalf("filter %+v should match %+v", f, event)
+	}
+	for name, e := range map[string]Event{
+		"type":     {Type: "c", KeyID: event.KeyID, Model: event.Model, Upstream: event.Upstream},
+		"key":      {Type: "a", KeyID: KeyID("other"), Model: event.Model, Upstream: event.Upstream},
+		"model":    {Type: "a", KeyID: event.KeyID, Model: "gpt-5", Upstream: event.Upstream},
+		"upstream": {Type: "a", KeyID: event.KeyID, Model: event.Model, Upstream: "codex"},
+	} {
+		if f.Match(e) {
+			t.Errorf("filter should reject a different %s: %+v", name, e)
+		}
+	}
+}
+
+func TestFilterModelWildcards(t *testing.T) {
+	for pattern, want := range map[string]bool{
+		"*":          true,
+		"GPT-*-mini": true,
+		"*-4.1-*":    true,
+		"gpt-*":      true,
+		"*-mini":     true,
+		"gpt-*-nano": false,
+	} {
+		f := ParseFilter(url.Values{"model": {pattern}})
+		if got := f.Match(Event{Type: "a", Model: "gpt-4.1-mini"}); got != want {
+			t.Errorf("model filter %q matched gpt-4.1-mini = %v; want %v", pattern, got, want)
+		}
+	}
+}
+
+func TestChannelSubscriberDropsWhenFull(t *testing.T) {
+	sub := NewChannelSubscriber(Filter{}, 1)
+	sub.Handle(Event{Type: "a"})
+	sub.Handle(Event{Type: "a"})
+	if sub.Dropped() != 1 {
+		t.Fatalf("Dropped() = %d; want 1", sub.Dropped())
+	}
+}
@@ -0,0 +1,101 @@
+package events
+
+import (
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// Filter selects events by type, client key, model and upstream. Empty fields match everything.
+type Filter struct {
+	Types    map[string]struct{}
+	KeyID    string
+	Model    string
+	Upstream string
+}
+
+// ParseFilter reads a filter from query parameters: types (comma separated), key (a client key,
+// matched by its hash), key_id, model (* matches any characters) and upstream.
+func ParseFilter(query url.Values) Filter {
+	f := Filter{
+		KeyID:    strings.TrimSpace(query.Get("key_id")),
+		Model:    strings.TrimSpace(query.Get("model")),
+		Upstream: strings.TrimSpace(query.Get("upstream")),
+	}
+	if key := strings.TrimSpace(query.Get("key")); key != "" {
+		f.KeyID = KeyID(key)
+	}
+	for _, raw := range query["types"] {
+		for _, t := range strings.Split(raw, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				if f.Types == nil {
+					f.Types = make(map[string]struct{})
+				}
+				f.Types[t] = struct{}{}
+			}
+		}
+	}
+	return f
+}
+
+// WantsType reports whether the filter accepts events of eventType.
+func (f Filter) WantsType(eventType string) bool {
+	if len(f.Types) == 0 {
+		return true
+	}
+	_, ok := f.Types[eventType]
+	return ok
+}
+
+// Match reports whether the filter accepts e.
+func (f Filter) Match(e Event) bool {
+	if !f.WantsType(e.Type) {
+		return false
+	}
+	if f.KeyID != "" && e.KeyID != f.KeyID {
+		return false
+	}
+	if f.Upstream != "" && !strings.EqualFold(e.Upstream, f.Upstream) {
+		return false
+	}
+	if f.Model != "" && !util.MatchModelWildcard(f.Model, e.Model) {
+		return false
+	}
+	return true
+}
+
+// ChannelSubscriber buffers the events matching a filter on a channel. Events that arrive while
+// the buffer is full are dropped and counted.
+type ChannelSubscriber struct {
+	filter  Filter
+	ch      chan Event
+	dropped atomic.Int64
+}
+
+// NewChannelSubscriber returns a subscriber buffering up to size events.
+func NewChannelSubscriber(filter Filter, size int) *ChannelSubscriber {
+	return &ChannelSubscriber{filter: filter, ch: make(chan Event, size)}
+}
+
+// Events returns the channel events are delivered on.
+func (s *ChannelSubscriber) Events() <-chan Event { return s.ch }
+
+// Dropped returns the number of events dropped because the buffer was full.
+func (s *ChannelSubscriber) Dropped() int64 { return s.dropped.Load() }
+
+// Wants implements Subscriber.
+func (s *ChannelSubscriber) Wants(eventType string) bool { return s.filter.WantsType(eventType) }
+
+// Handle implements Subscriber.
+func (s *ChannelSubscriber) Handle(e Event) {
+	if !s.filter.Match(e) {
+		return
+	}
+	select {
+	case s.ch <- e:
+	default:
+		s.dropped.Add(1)
+	}
+}
@@ -0,0 +1,264 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// maxResponseBytes caps the size of a single MCP response.
+const maxResponseBytes = 8 << 20
+
+// Tool describes a tool offered by an MCP server.
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema json.RawMessage
+}
+
+// ToolResult is the text rendering of a tools/call result.
+type ToolResult struct {
+	Text    string
+	IsError bool
+}
+
+// Client talks to one MCP server over the streamable HTTP transport. The session is
+// initialized on first use and re-initialized when the server forgets it. Requests to one
+// server are serialized.
+type Client struct {
+	URL        string
+	Headers    map[string]string
+	HTTPClient *http.Client
+
+	mu          sync.Mutex
+	initialized bool
+	sessionID   string
+	version     string
+	nextID      int64
+}
+
+// ListTools returns every tool the server offers, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		result, err := c.call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, err
+		}
+		result.Get("tools").ForEach(func(_, tool gjson.Result) bool {
+			schema := json.RawMessage(`{"type":"object","properties":{}}`)
+			if raw := tool.Get("inputSchema"); raw.IsObject() {
+				schema = json.RawMessage(raw.Raw)
+			}
+			tools = append(tools, Tool{Name: tool.Get("name").String(), Description: tool.Get("description").String(), InputSchema: schema})
+			return true
+		})
+		cursor = result.Get("nextCursor").String()
+		if cursor == "" {
+			return tools, nil
+		}
+	}
+}
+
+// CallTool invokes a tool and renders its content as text.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (ToolResult, error) {
+	if len(arguments) == 0 || !gjson.ValidBytes(arguments) {
+		arguments = json.RawMessage(`{}`)
+	}
+	result, err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": arguments})
+	if err != nil {
+		return ToolResult{}, err
+	}
+	var parts []string
+	result.Get("content").ForEach(func(_, item gjson.Result) bool {
+		switch item.Get("type").String() {
+		case "text":
+			parts = append(parts, item.Get("text").String())
+		case "resource":
+			if text := item.Get("resource.text").String(); text != "" {
+				parts = append(parts, text)
+			} else {
+				parts = append(parts, fmt.Sprintf("[resource %s]", item.Get("resource.uri").String()))
+			}
+		case "resource_link":
+			parts = append(parts, fmt.Sprintf("[resource %s]", item.Get("uri").String()))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content omitted]", item.Get("type").String()))
+		}
+		return true
+	})
+	if len(parts) == 0 {
+		if structured := result.Get("structuredContent"); structured.Exists() {
+			parts = append(parts, structured.Raw)
+		}
+	}
+	return ToolResult{Text: strings.Join(parts, "\n"), IsError: result.Get("isError").Bool()}, nil
+}
+
+func (c *Client) call(ctx context.Context, method string, params any) (gjson.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.initialized {
+		if err := c.initialize(ctx); err != nil {
+			return gjson.Result{}, err
+		}
+	}
+	result, status, err := c.request(ctx, method, params)
+	if status == http.StatusNotFound && c.sessionID != "" {
+		// The server dropped the session; start a new one and retry once.
+		c.initialized, c.sessionID = false, ""
+		if err = c.initialize(ctx); err != nil {
+			return gjson.Result{}, err
+		}
+		result, _, err = c.request(ctx, method, params)
+	}
+	return result, err
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "cliproxy", "version": "1.0"},
+	}
+	result, _, err := c.request(ctx, "initialize", params)
+	if err != nil {
+		return fmt.Errorf("mcp initialize %s: %w", c.URL, err)
+	}
+	c.version = result.Get("protocolVersion").String()
+	if err = c.notify(ctx, "notifications/initialized"); err != nil {
+		return fmt.Errorf("mcp initialize %s: %w", c.URL, err)
+	}
+	c.initialized = true
+	return nil
+}
+
+func (c *Client) notify(ctx context.Context, method string) error {
+	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": method})
+	resp, err := c.post(ctx, body)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: status %d", method, resp.StatusCode)
+	}
+	return nil
+}
+
+// request sends one JSON-RPC request and returns its result. The status code is returned so
+// callers can detect expired sessions.
+func (c *Client) request(ctx context.Context, method string, params any) (gjson.Result, int, error) {
+	c.nextID++
+	id := c.nextID
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	if err != nil {
+		return gjson.Result{}, 0, err
+	}
+	resp, err := c.post(ctx, body)
+	if err != nil {
+		return gjson.Result{}, 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return gjson.Result{}, resp.StatusCode, fmt.Errorf("%s: status %d: %s", method, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if sessionID := resp.Header.Get("Mcp-Session-Id"); sessionID != "" {
+		c.sessionID = sessionID
+	}
+
+	var message gjson.Result
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		message, err = readEventStreamResponse(resp.Body, id)
+	} else {
+		var data []byte
+		data, err = io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+		message = gjson.ParseBytes(data)
+	}
+	if err != nil {
+		return gjson.Result{}, resp.StatusCode, fmt.Errorf("%s: %w", method, err)
+	}
+	if rpcErr := message.Get("error"); rpcErr.Exists() {
+		return gjson.Result{}, resp.StatusCode, fmt.Errorf("%s: %s (code %d)", method, rpcErr.Get("message").String(), rpcErr.Get("code").Int())
+	}
+	return message.Get("result"), resp.StatusCode, nil
+}
+
+func (c *Client) post(ctx context.Context, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for key, value := range c.Headers {
+		req.Header.Set(key, value)
+	}
+	if c.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", c.sessionID)
+	}
+	if c.version != "" {
+		req.Header.Set("MCP-Protocol-Version", c.version)
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// readEventStreamResponse returns the JSON-RPC response with the given id from an SSE body,
+// skipping server requests and notifications sent ahead of it.
+func readEventStreamResponse(body io.Reader, id int64) (gjson.Result, error) {
+	scanner := bufio.NewScanner(io.LimitReader(body, maxResponseBytes))
+	scanner.Buffer(make([]byte, 0, 64<<10), maxResponseBytes)
+	var data strings.Builder
+	flush := func() (gjson.Result, bool) {
+		defer data.Reset()
+		if data.Len() == 0 {
+			return gjson.Result{}, false
+		}
+		message := gjson.Parse(data.String())
+		if message.Get("id").Int() == id && (message.Get("result").Exists() || message.Get("error").Exists()) {
+			return message, true
+		}
+		return gjson.Result{}, false
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if message, ok := flush(); ok {
+				return message, nil
+			}
+			continue
+		}
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+		}
+	}
+	if message, ok := flush(); ok {
+		return message, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return gjson.Result{}, err
+	}
+	return gjson.Result{}, fmt.Errorf("event stream ended without a response")
+}
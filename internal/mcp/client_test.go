@@ -0,0 +1,73 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestToolboxAgainstServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := &Server{Name: "upstream", Models: func() []string { return []string{"m1", "m2"} }}
+	engine := gin.New()
+	engine.POST("/mcp", server.Handle)
+	upstream := httptest.NewServer(engine)
+	defer upstream.Close()
+
+	toolbox := NewToolbox([]config.MCPClientServer{{Name: "peer", URL: upstream.URL + "/mcp", Tools: []string{"list_models"}}}, time.Second)
+	tools := toolbox.Tools(context.Background())
+	if len(tools) != 1 || tools[0].Name != "peer__list_models" || !toolbox.Has("peer__list_models") {
+		t.Fatalf("unexpected tools: %+v", tools)
+	}
+	result := toolbox.Call(context.Background(), "peer__list_models", nil)
+	if result.IsError || result.Text != "m1\nm2" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result = toolbox.Call(context.Background(), "peer__missing", nil); !result.IsError {
+		t.Fatal("expected an error for an unknown tool")
+	}
+}
+
+func TestClientEventStreamResponses(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		msg := gjson.ParseBytes(body)
+		if !msg.Get("id").Exists() {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var result string
+		switch msg.Get("method").String() {
+		case "initialize":
+			w.Header().Set("Mcp-Session-Id", "s1")
+			result = `{"protocolVersion":"2025-06-18","capabilities":{},"serverInfo":{"name":"sse"}}`
+		case "tools/call":
+			if r.Header.Get("Mcp-Session-Id") != "s1" {
+				t.Errorf("session id not sent")
+			}
+			result = `{"content":[{"type":"text","text":"pong"}],"isError":true}`
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		response, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": msg.Get("id").Value(), "result": json.RawMessage(result)})
+		_, _ = fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\"}\n\ndata: %s\n\n", response)
+	}))
+	defer upstream.Close()
+
+	client := &Client{URL: upstream.URL}
+	result, err := client.CallTool(context.Background(), "ping", nil)
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if result.Text != "pong" || !result.IsError {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
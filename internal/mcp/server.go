@@ -0,0 +1,243 @@
+// Package mcp implements the Model Context Protocol pieces used by the proxy: a server that
+// exposes routed models as tools to MCP clients, and a client that aggregates the tools of
+// external MCP servers for upstream models.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ProtocolVersion is the newest MCP revision the server speaks. Clients asking for one of the
+// supportedProtocolVersions get their own version back.
+const ProtocolVersion = "2025-06-18"
+
+var supportedProtocolVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+const (
+	askModelTool   = "ask_model"
+	listModelsTool = "list_models"
+)
+
+// AskFunc sends an OpenAI chat completion request to model through the proxy's routing and
+// returns the OpenAI chat completion response.
+type AskFunc func(ctx context.Context, model string, request []byte) ([]byte, error)
+
+// Server answers MCP requests over the streamable HTTP transport. Every request is answered
+// with a single JSON response; the server never opens an SSE stream.
+type Server struct {
+	// Name and Version are reported to clients during initialization.
+	Name    string
+	Version string
+	// Ask executes model requests.
+	Ask AskFunc
+	// Models lists the model IDs the proxy can currently route.
+	Models func() []string
+	// Allowed reports whether tools may query model. Nil allows every model.
+	Allowed func(model string) bool
+	// DefaultModel is used when ask_model is called without a model.
+	DefaultModel string
+}
+
+// Handle is the gin handler for the MCP endpoint. POST carries JSON-RPC messages; GET is
+// answered with 405 as the transport allows for servers without a server-initiated stream.
+func (s *Server) Handle(c *gin.Context) {
+	if c.Request.Method != http.MethodPost {
+		c.Header("Allow", http.MethodPost)
+		c.Status(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, rpcError(nil, codeParseError, err.Error()))
+		return
+	}
+	resp, ok := s.HandleMessage(c.Request.Context(), body)
+	if !ok {
+		c.Status(http.StatusAccepted)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", resp)
+}
+
+// HandleMessage processes one JSON-RPC message. ok is false for notifications and responses,
+// which get no reply.
+func (s *Server) HandleMessage(ctx context.Context, body []byte) (resp []byte, ok bool) {
+	if !gjson.ValidBytes(body) {
+		return mustJSON(rpcError(nil, codeParseError, "invalid JSON")), true
+	}
+	msg := gjson.ParseBytes(body)
+	if !msg.IsObject() {
+		return mustJSON(rpcError(nil, codeInvalidRequest, "expected a single JSON-RPC message")), true
+	}
+	id := msg.Get("id")
+	method := msg.Get("method").String()
+	if !id.Exists() || method == "" {
+		// Notifications (notifications/initialized, notifications/cancelled) and responses.
+		return nil, false
+	}
+	idRaw := json.RawMessage(id.Raw)
+
+	switch method {
+	case "initialize":
+		version := msg.Get("params.protocolVersion").String()
+		if !supportedProtocolVersions[version] {
+			version = ProtocolVersion
+		}
+		result := `{"protocolVersion":"","capabilities":{"tools":{"listChanged":false}},"serverInfo":{"name":"","version":""}}`
+		result, _ = sjson.Set(result, "protocolVersion", version)
+		result, _ = sjson.Set(result, "serverInfo.name", s.Name)
+		result, _ = sjson.Set(result, "serverInfo.version", s.Version)
+		return rpcResult(idRaw, result), true
+	case "ping":
+		return rpcResult(idRaw, `{}`), true
+	case "tools/list":
+		return rpcResult(idRaw, s.toolsList()), true
+	case "tools/call":
+		name := msg.Get("params.name").String()
+		args := msg.Get("params.arguments")
+		switch name {
+		case askModelTool:
+			return rpcResult(idRaw, s.askModel(ctx, args)), true
+		case listModelsTool:
+			return rpcResult(idRaw, toolText(strings.Join(s.allowedModels(), "\n"), false)), true
+		}
+		return mustJSON(rpcError(idRaw, codeInvalidParams, fmt.Sprintf("unknown tool: %s", name))), true
+	}
+	return mustJSON(rpcError(idRaw, codeMethodNotFound, fmt.Sprintf("method not found: %s", method))), true
+}
+
+func (s *Server) toolsList() string {
+	ask := `{"name":"ask_model","description":"","inputSchema":{"type":"object","properties":{"model":{"type":"string","description":"Model ID to ask; see list_models."},"prompt":{"type":"string","description":"The question or task for the model."},"system":{"type":"string","description":"Optional system instructions."},"max_tokens":{"type":"integer","description":"Optional cap on the answer length in tokens."}},"required":["prompt"]}}`
+	description := "Send a prompt to another model through the proxy and return its answer. Useful for second opinions or for capabilities the current model lacks."
+	if s.DefaultModel != "" {
+		description += " Defaults to " + s.DefaultModel + "."
+	} else {
+		ask, _ = sjson.Set(ask, "inputSchema.required", []string{"model", "prompt"})
+	}
+	ask, _ = sjson.Set(ask, "description", description)
+	list := `{"name":"list_models","description":"List the model IDs that ask_model accepts.","inputSchema":{"type":"object","properties":{}}}`
+
+	result := `{"tools":[]}`
+	result, _ = sjson.SetRaw(result, "tools.-1", ask)
+	result, _ = sjson.SetRaw(result, "tools.-1", list)
+	return result
+}
+
+// askModel runs the ask_model tool. Failures are reported as tool errors so the calling model
+// can see them, rather than as protocol errors.
+func (s *Server) askModel(ctx context.Context, args gjson.Result) string {
+	prompt := args.Get("prompt").String()
+	if strings.TrimSpace(prompt) == "" {
+		return toolText("prompt is required", true)
+	}
+	model := strings.TrimSpace(args.Get("model").String())
+	if model == "" {
+		model = s.DefaultModel
+	}
+	if model == "" {
+		return toolText("model is required", true)
+	}
+	if s.Allowed != nil && !s.Allowed(model) {
+		return toolText(fmt.Sprintf("model %s is not available through this MCP server", model), true)
+	}
+	if s.Ask == nil {
+		return toolText("model execution is not configured", true)
+	}
+
+	request := `{"model":"","messages":[]}`
+	request, _ = sjson.Set(request, "model", model)
+	if system := args.Get("system").String(); system != "" {
+		request, _ = sjson.SetRaw(request, "messages.-1", mustJSONString(map[string]string{"role": "system", "content": system}))
+	}
+	request, _ = sjson.SetRaw(request, "messages.-1", mustJSONString(map[string]string{"role": "user", "content": prompt}))
+	if maxTokens := args.Get("max_tokens").Int(); maxTokens > 0 {
+		request, _ = sjson.Set(request, "max_tokens", maxTokens)
+	}
+
+	resp, err := s.Ask(ctx, model, []byte(request))
+	if err != nil {
+		return toolText(fmt.Sprintf("model request failed: %v", err), true)
+	}
+	message := gjson.GetBytes(resp, "choices.0.message")
+	answer := message.Get("content").String()
+	if answer == "" {
+		if refusal := message.Get("refusal").String(); refusal != "" {
+			return toolText(refusal, true)
+		}
+	}
+	return toolText(answer, false)
+}
+
+func (s *Server) allowedModels() []string {
+	if s.Models == nil {
+		return nil
+	}
+	models := s.Models()
+	out := make([]string, 0, len(models))
+	for _, model := range models {
+		if s.Allowed == nil || s.Allowed(model) {
+			out = append(out, model)
+		}
+	}
+	return out
+}
+
+func toolText(text string, isError bool) string {
+	result := `{"content":[{"type":"text","text":""}],"isError":false}`
+	result, _ = sjson.Set(result, "content.0.text", text)
+	result, _ = sjson.Set(result, "isError", isError)
+	return result
+}
+
+type rpcErrorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcErrorBody   `json:"error,omitempty"`
+}
+
+func rpcResult(id json.RawMessage, result string) []byte {
+	return mustJSON(rpcMessage{JSONRPC: "2.0", ID: id, Result: json.RawMessage(result)})
+}
+
+func rpcError(id json.RawMessage, code int, message string) rpcMessage {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return rpcMessage{JSONRPC: "2.0", ID: id, Error: &rpcErrorBody{Code: code, Message: message}}
+}
+
+func mustJSON(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func mustJSONString(v any) string {
+	return string(mustJSON(v))
+}
@@ -0,0 +1,62 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestHandleMessageInitializeAndNotifications(t *testing.T) {
+	s := &Server{Name: "cliproxy", Version: "test"}
+	resp, ok := s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`))
+	if !ok {
+		t.Fatal("expected a response to initialize")
+	}
+	if v := gjson.GetBytes(resp, "result.protocolVersion").String(); v != "2025-03-26" {
+		t.Fatalf("protocolVersion = %q", v)
+	}
+	if gjson.GetBytes(resp, "result.serverInfo.name").String() != "cliproxy" || !gjson.GetBytes(resp, "result.capabilities.tools").Exists() {
+		t.Fatalf("unexpected initialize result: %s", resp)
+	}
+	if _, ok = s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); ok {
+		t.Fatal("notifications must not be answered")
+	}
+	resp, _ = s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":"x","method":"resources/list"}`))
+	if gjson.GetBytes(resp, "error.code").Int() != codeMethodNotFound || gjson.GetBytes(resp, "id").String() != "x" {
+		t.Fatalf("unexpected error response: %s", resp)
+	}
+}
+
+func TestHandleMessageAskModel(t *testing.T) {
+	var gotModel string
+	var gotRequest []byte
+	s := &Server{
+		DefaultModel: "gemini-2.5-pro",
+		Ask: func(_ context.Context, model string, request []byte) ([]byte, error) {
+			gotModel, gotRequest = model, request
+			return []byte(`{"choices":[{"message":{"role":"assistant","content":"42"}}]}`), nil
+		},
+		Allowed: func(model string) bool { return model != "blocked" },
+	}
+
+	resp, _ := s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"ask_model","arguments":{"prompt":"answer?","system":"be brief","max_tokens":10}}}`))
+	if gjson.GetBytes(resp, "result.content.0.text").String() != "42" || gjson.GetBytes(resp, "result.isError").Bool() {
+		t.Fatalf("unexpected tool result: %s", resp)
+	}
+	if gotModel != "gemini-2.5-pro" || gjson.GetBytes(gotRequest, "messages.#").Int() != 2 || gjson.GetBytes(gotRequest, "max_tokens").Int() != 10 {
+		t.Fatalf("unexpected upstream request for %s: %s", gotModel, gotRequest)
+	}
+
+	resp, _ = s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"ask_model","arguments":{"model":"blocked","prompt":"hi"}}}`))
+	if !gjson.GetBytes(resp, "result.isError").Bool() {
+		t.Fatalf("expected a tool error for a disallowed model: %s", resp)
+	}
+
+	s.Ask = func(context.Context, string, []byte) ([]byte, error) { return nil, errors.New("no credentials") }
+	resp, _ = s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"ask_model","arguments":{"prompt":"hi"}}}`))
+	if !gjson.GetBytes(resp, "result.isError").Bool() {
+		t.Fatalf("expected a tool error for a failed request: %s", resp)
+	}
+}
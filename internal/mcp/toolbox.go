@@ -0,0 +1,184 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ToolNameSeparator joins a server name and a tool name in advertised tool names.
+	ToolNameSeparator = "__"
+
+	defaultToolTimeout = 30 * time.Second
+	toolListTTL        = 5 * time.Minute
+	maxToolNameLength  = 64
+)
+
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// toolRoute maps an advertised tool name back to its server and original name.
+type toolRoute struct {
+	client *Client
+	name   string
+}
+
+// Toolbox merges the tools of several MCP servers under "<server>__<tool>" names and routes
+// calls back to the owning server. Tool lists are cached for a few minutes.
+type Toolbox struct {
+	servers []config.MCPClientServer
+	clients []*Client
+	timeout time.Duration
+
+	mu      sync.Mutex
+	tools   []Tool
+	routes  map[string]toolRoute
+	fetched time.Time
+}
+
+// NewToolbox creates a toolbox for the configured servers. timeout bounds each MCP request.
+func NewToolbox(servers []config.MCPClientServer, timeout time.Duration) *Toolbox {
+	if timeout <= 0 {
+		timeout = defaultToolTimeout
+	}
+	httpClient := &http.Client{Timeout: timeout}
+	t := &Toolbox{timeout: timeout}
+	for _, server := range servers {
+		if strings.TrimSpace(server.URL) == "" {
+			continue
+		}
+		t.servers = append(t.servers, server)
+		t.clients = append(t.clients, &Client{URL: strings.TrimSpace(server.URL), Headers: server.Headers, HTTPClient: httpClient})
+	}
+	return t
+}
+
+var (
+	sharedMu        sync.Mutex
+	sharedToolboxes = map[string]*Toolbox{}
+)
+
+// SharedToolbox returns the toolbox for cfg, reusing sessions and cached tool lists across
+// requests until the server configuration changes.
+func SharedToolbox(cfg config.MCPClientConfig) *Toolbox {
+	key, _ := json.Marshal(struct {
+		Servers []config.MCPClientServer
+		Timeout int
+	}{cfg.Servers, cfg.TimeoutSeconds})
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if t, ok := sharedToolboxes[string(key)]; ok {
+		return t
+	}
+	t := NewToolbox(cfg.Servers, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	// Only the current configuration is kept.
+	sharedToolboxes = map[string]*Toolbox{string(key): t}
+	return t
+}
+
+// Tools returns the merged tool list. Servers that cannot be reached are skipped and logged.
+func (t *Toolbox) Tools(ctx context.Context) []Tool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.routes != nil && time.Since(t.fetched) < toolListTTL {
+		return t.tools
+	}
+	tools := make([]Tool, 0)
+	routes := make(map[string]toolRoute)
+	for i, client := range t.clients {
+		server := t.servers[i]
+		listCtx, cancel := context.WithTimeout(ctx, t.timeout)
+		serverTools, err := client.ListTools(listCtx)
+		cancel()
+		if err != nil {
+			log.Warnf("mcp client: list tools of %s: %v", server.Name, err)
+			continue
+		}
+		for _, tool := range serverTools {
+			if !toolAllowed(server.Tools, tool.Name) {
+				continue
+			}
+			name := advertisedToolName(server.Name, tool.Name)
+			if _, exists := routes[name]; exists {
+				continue
+			}
+			routes[name] = toolRoute{client: client, name: tool.Name}
+			tools = append(tools, Tool{Name: name, Description: tool.Description, InputSchema: tool.InputSchema})
+		}
+	}
+	t.tools, t.routes, t.fetched = tools, routes, time.Now()
+	return tools
+}
+
+// Has reports whether name is one of the advertised tools.
+func (t *Toolbox) Has(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.routes[name]
+	return ok
+}
+
+// Call executes an advertised tool. Failures are returned as error results so the model can
+// see them.
+func (t *Toolbox) Call(ctx context.Context, name string, arguments json.RawMessage) ToolResult {
+	t.mu.Lock()
+	route, ok := t.routes[name]
+	t.mu.Unlock()
+	if !ok {
+		return ToolResult{Text: fmt.Sprintf("unknown tool: %s", name), IsError: true}
+	}
+	callCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	result, err := route.client.CallTool(callCtx, route.name, arguments)
+	if err != nil {
+		log.Warnf("mcp client: call %s: %v", name, err)
+		return ToolResult{Text: fmt.Sprintf("tool call failed: %v", err), IsError: true}
+	}
+	return result
+}
+
+// AppliesTo reports whether cfg advertises MCP tools to requests for model.
+func AppliesTo(cfg config.MCPClientConfig, model string) bool {
+	if len(cfg.Servers) == 0 {
+		return false
+	}
+	if len(cfg.Models) == 0 {
+		return true
+	}
+	for _, pattern := range cfg.Models {
+		if util.MatchModelWildcard(strings.TrimSpace(pattern), model) {
+			return true
+		}
+	}
+	return false
+}
+
+func toolAllowed(allowed []string, name string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, candidate := range allowed {
+		if strings.TrimSpace(candidate) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// advertisedToolName builds a tool name that satisfies the Claude and OpenAI name rules.
+func advertisedToolName(server, tool string) string {
+	name := invalidToolNameChars.ReplaceAllString(server+ToolNameSeparator+tool, "_")
+	if len(name) > maxToolNameLength {
+		name = name[:maxToolNameLength]
+	}
+	return name
+}
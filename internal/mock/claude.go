@@ -0,0 +1,137 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func (m *server) serveClaude(w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r, writeClaudeError)
+	if !ok {
+		return
+	}
+	model := gjson.GetBytes(body, "model").String()
+	if model == "" {
+		model = m.opts.Models[0]
+	}
+	ex := m.newExchange(r, model, body, gjson.GetBytes(body, "stream").Bool())
+	if e := ex.resp.Error; e != nil && (e.AfterChunks == 0 || !ex.stream) {
+		writeClaudeError(w, errorStatus(e), errorMessage(e))
+		return
+	}
+	if !sleepContext(r, ex.firstDelay) {
+		return
+	}
+
+	id := fmt.Sprintf("msg_mock_%d", time.Now().UnixNano())
+	stopReason := "end_turn"
+	if len(ex.resp.ToolCalls) > 0 {
+		stopReason = "tool_use"
+	}
+
+	if !ex.stream {
+		content := make([]any, 0, 1+len(ex.resp.ToolCalls))
+		if ex.resp.Text != "" {
+			content = append(content, map[string]any{"type": "text", "text": ex.resp.Text})
+		}
+		for i, call := range ex.resp.ToolCalls {
+			content = append(content, map[string]any{
+				"type":  "tool_use",
+				"id":    fmt.Sprintf("toolu_mock_%d", i),
+				"name":  call.Name,
+				"input": json.RawMessage(call.argumentsJSON()),
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":            id,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         model,
+			"content":       content,
+			"stop_reason":   stopReason,
+			"stop_sequence": nil,
+			"usage":         map[string]any{"input_tokens": ex.promptTokens, "output_tokens": ex.completionTokens()},
+		})
+		return
+	}
+
+	s := newStreamWriter(w, r, "text/event-stream")
+	s.event("message_start", map[string]any{"type": "message_start", "message": map[string]any{
+		"id":            id,
+		"type":          "message",
+		"role":          "assistant",
+		"model":         model,
+		"content":       []any{},
+		"stop_reason":   nil,
+		"stop_sequence": nil,
+		"usage":         map[string]any{"input_tokens": ex.promptTokens, "output_tokens": 0},
+	}})
+
+	index := 0
+	if len(ex.deltas) > 0 {
+		s.event("content_block_start", map[string]any{"type": "content_block_start", "index": index, "content_block": map[string]any{"type": "text", "text": ""}})
+	}
+	completed := ex.streamDeltas(s,
+		func(delta string) {
+			s.event("content_block_delta", map[string]any{"type": "content_block_delta", "index": index, "delta": map[string]any{"type": "text_delta", "text": delta}})
+		},
+		func() {
+			s.raw("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_del\n\n")
+		},
+		func(e *Error) {
+			s.event("error", map[string]any{"type": "error", "error": map[string]any{"type": claudeErrorType(errorStatus(e)), "message": errorMessage(e)}})
+		},
+	)
+	if !completed {
+		return
+	}
+	if len(ex.deltas) > 0 {
+		s.event("content_block_stop", map[string]any{"type": "content_block_stop", "index": index})
+		index++
+	}
+	for i, call := range ex.resp.ToolCalls {
+		s.event("content_block_start", map[string]any{"type": "content_block_start", "index": index, "content_block": map[string]any{
+			"type":  "tool_use",
+			"id":    fmt.Sprintf("toolu_mock_%d", i),
+			"name":  call.Name,
+			"input": map[string]any{},
+		}})
+		for _, piece := range splitArguments(call.argumentsJSON()) {
+			s.event("content_block_delta", map[string]any{"type": "content_block_delta", "index": index, "delta": map[string]any{"type": "input_json_delta", "partial_json": piece}})
+		}
+		s.event("content_block_stop", map[string]any{"type": "content_block_stop", "index": index})
+		index++
+	}
+	s.event("message_delta", map[string]any{
+		"type":  "message_delta",
+		"delta": map[string]any{"stop_reason": stopReason, "stop_sequence": nil},
+		"usage": map[string]any{"output_tokens": ex.completionTokens()},
+	})
+	s.event("message_stop", map[string]any{"type": "message_stop"})
+}
+
+func claudeErrorType(status int) string {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case status == http.StatusServiceUnavailable || status == 529:
+		return "overloaded_error"
+	case status == http.StatusUnauthorized:
+		return "authentication_error"
+	case status < http.StatusInternalServerError:
+		return "invalid_request_error"
+	default:
+		return "api_error"
+	}
+}
+
+func writeClaudeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{
+		"type":  "error",
+		"error": map[string]any{"type": claudeErrorType(status), "message": message},
+	})
+}
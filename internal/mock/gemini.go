@@ -0,0 +1,146 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+func (m *server) serveGemini(w http.ResponseWriter, r *http.Request) {
+	model, action, found := strings.Cut(r.PathValue("target"), ":")
+	if !found || (action != "generateContent" && action != "streamGenerateContent") {
+		writeGeminiError(w, http.StatusNotFound, "unsupported method "+r.PathValue("target"))
+		return
+	}
+	body, ok := readBody(w, r, writeGeminiError)
+	if !ok {
+		return
+	}
+	ex := m.newExchange(r, model, body, action == "streamGenerateContent")
+	if e := ex.resp.Error; e != nil && (e.AfterChunks == 0 || !ex.stream) {
+		writeGeminiError(w, errorStatus(e), errorMessage(e))
+		return
+	}
+	if !sleepContext(r, ex.firstDelay) {
+		return
+	}
+
+	usage := map[string]any{
+		"promptTokenCount":     ex.promptTokens,
+		"candidatesTokenCount": ex.completionTokens(),
+		"totalTokenCount":      ex.promptTokens + ex.completionTokens(),
+	}
+	response := func(parts []any, final bool) map[string]any {
+		candidate := map[string]any{"content": map[string]any{"role": "model", "parts": parts}, "index": 0}
+		out := map[string]any{"candidates": []any{candidate}, "modelVersion": model}
+		if final {
+			candidate["finishReason"] = "STOP"
+			out["usageMetadata"] = usage
+		}
+		return out
+	}
+	toolParts := func() []any {
+		parts := make([]any, 0, len(ex.resp.ToolCalls))
+		for _, call := range ex.resp.ToolCalls {
+			parts = append(parts, map[string]any{"functionCall": map[string]any{
+				"name": call.Name,
+				"args": json.RawMessage(call.argumentsJSON()),
+			}})
+		}
+		return parts
+	}
+
+	if !ex.stream {
+		parts := toolParts()
+		if ex.resp.Text != "" {
+			parts = append([]any{map[string]any{"text": ex.resp.Text}}, parts...)
+		}
+		writeJSON(w, http.StatusOK, response(parts, true))
+		return
+	}
+
+	// Without alt=sse Gemini streams a JSON array whose elements arrive incrementally.
+	sse := r.URL.Query().Get("alt") == "sse"
+	contentType := "application/json"
+	if sse {
+		contentType = "text/event-stream"
+	}
+	s := newStreamWriter(w, r, contentType)
+	first := true
+	emit := func(v any) {
+		if sse {
+			s.event("", v)
+			return
+		}
+		data, _ := json.Marshal(v)
+		prefix := ",\r\n"
+		if first {
+			prefix = "["
+		}
+		first = false
+		s.raw(prefix + string(data))
+	}
+	malformed := func() {
+		if sse {
+			s.raw("data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"trunc\n\n")
+			return
+		}
+		prefix := ",\r\n"
+		if first {
+			prefix = "["
+		}
+		first = false
+		s.raw(prefix + "{\"candidates\":[{\"content\":")
+	}
+
+	completed := ex.streamDeltas(s,
+		func(delta string) { emit(response([]any{map[string]any{"text": delta}}, false)) },
+		malformed,
+		func(e *Error) {
+			emit(map[string]any{"error": geminiErrorBody(errorStatus(e), errorMessage(e))})
+			if !sse {
+				s.raw("]")
+			}
+		},
+	)
+	if !completed {
+		return
+	}
+	emit(response(toolParts(), true))
+	if !sse {
+		s.raw("]")
+	}
+}
+
+func (m *server) serveGeminiModels(w http.ResponseWriter, _ *http.Request) {
+	models := make([]any, 0, len(m.opts.Models))
+	for _, model := range m.opts.Models {
+		models = append(models, map[string]any{
+			"name":                       "models/" + model,
+			"displayName":                model,
+			"supportedGenerationMethods": []string{"generateContent", "streamGenerateContent"},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"models": models})
+}
+
+func geminiErrorBody(status int, message string) map[string]any {
+	code := "INTERNAL"
+	switch {
+	case status == http.StatusTooManyRequests:
+		code = "RESOURCE_EXHAUSTED"
+	case status == http.StatusServiceUnavailable:
+		code = "UNAVAILABLE"
+	case status == http.StatusNotFound:
+		code = "NOT_FOUND"
+	case status == http.StatusUnauthorized:
+		code = "UNAUTHENTICATED"
+	case status < http.StatusInternalServerError:
+		code = "INVALID_ARGUMENT"
+	}
+	return map[string]any{"code": status, "message": message, "status": code}
+}
+
+func writeGeminiError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{"error": geminiErrorBody(status, message)})
+}
@@ -0,0 +1,361 @@
+// Package mock implements a scripted upstream that emulates the OpenAI, Claude and Gemini
+// generation endpoints, including tool calls, errors and malformed or truncated streams. It backs
+// the bench and mock subcommands and lets clients be exercised without provider credentials.
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultText is streamed back when neither Options.Text nor the script provides a reply.
+const DefaultText = "The quick brown fox jumps over the lazy dog. " +
+	"Pack my box with five dozen liquor jugs. " +
+	"How vexingly quick daft zebras jump."
+
+// ScenarioHeader selects a script rule or built-in scenario by name for a single request.
+const ScenarioHeader = "X-Mock-Scenario"
+
+// Options shapes the mock responses.
+type Options struct {
+	// Text is the default assistant reply. It is streamed one word per chunk.
+	Text string
+	// FirstTokenDelay is waited before the first chunk (or the whole non-streaming reply).
+	FirstTokenDelay time.Duration
+	// TokenDelay is waited between streamed chunks.
+	TokenDelay time.Duration
+	// Script optionally maps requests to scripted responses.
+	Script *Script
+	// Models is returned by the model listing endpoints. Defaults to a single "mock-model".
+	Models []string
+}
+
+// Script is a list of rules evaluated in order; the first match decides the response.
+type Script struct {
+	Rules []Rule `yaml:"rules" json:"rules"`
+	// Default is used when no rule matches. An empty default falls back to Options.Text.
+	Default *Response `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// Rule matches requests to a scripted response. All non-empty conditions must hold.
+type Rule struct {
+	// Name lets clients pick the rule explicitly through the X-Mock-Scenario header.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Model is a model name pattern; "*" wildcards are supported.
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+	// Contains matches a substring anywhere in the request body.
+	Contains string   `yaml:"contains,omitempty" json:"contains,omitempty"`
+	Response Response `yaml:"response" json:"response"`
+}
+
+// Response describes one scripted reply. Streaming specific fields are ignored for
+// non-streaming requests.
+type Response struct {
+	// Text is the reply. When both Text and ToolCalls are empty the server default text is used.
+	Text      string     `yaml:"text,omitempty" json:"text,omitempty"`
+	ToolCalls []ToolCall `yaml:"tool-calls,omitempty" json:"tool-calls,omitempty"`
+	Error     *Error     `yaml:"error,omitempty" json:"error,omitempty"`
+	// Malformed emits a chunk that is not valid JSON halfway through the stream.
+	Malformed bool `yaml:"malformed,omitempty" json:"malformed,omitempty"`
+	// DisconnectAfter aborts the connection after this many text chunks. 0 disables it.
+	DisconnectAfter int `yaml:"disconnect-after,omitempty" json:"disconnect-after,omitempty"`
+	// FirstTokenDelayMs and TokenDelayMs override the server-wide delays when positive.
+	FirstTokenDelayMs int `yaml:"first-token-delay-ms,omitempty" json:"first-token-delay-ms,omitempty"`
+	TokenDelayMs      int `yaml:"token-delay-ms,omitempty" json:"token-delay-ms,omitempty"`
+}
+
+// ToolCall is a function call the mock asks the client to run.
+type ToolCall struct {
+	Name      string         `yaml:"name" json:"name"`
+	Arguments map[string]any `yaml:"arguments,omitempty" json:"arguments,omitempty"`
+}
+
+// Error makes the mock fail the request.
+type Error struct {
+	// Status is the HTTP status for errors returned before streaming starts. Default is 500.
+	Status  int    `yaml:"status,omitempty" json:"status,omitempty"`
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+	// AfterChunks streams this many text chunks before emitting the error as a stream event.
+	// 0 fails the request with an HTTP error instead.
+	AfterChunks int `yaml:"after-chunks,omitempty" json:"after-chunks,omitempty"`
+}
+
+// builtinScenarios are available through X-Mock-Scenario without a script.
+var builtinScenarios = map[string]Response{
+	"tool_call": {ToolCalls: []ToolCall{{Name: "get_weather", Arguments: map[string]any{"location": "Paris", "unit": "celsius"}}}},
+	"text_and_tool_call": {
+		Text:      "Let me check the weather.",
+		ToolCalls: []ToolCall{{Name: "get_weather", Arguments: map[string]any{"location": "Paris", "unit": "celsius"}}},
+	},
+	"error":           {Error: &Error{Status: http.StatusInternalServerError, Message: "mock upstream error"}},
+	"rate_limit":      {Error: &Error{Status: http.StatusTooManyRequests, Message: "mock rate limit exceeded"}},
+	"overloaded":      {Error: &Error{Status: http.StatusServiceUnavailable, Message: "mock upstream overloaded"}},
+	"midstream_error": {Error: &Error{Status: http.StatusInternalServerError, Message: "mock stream interrupted", AfterChunks: 3}},
+	"malformed":       {Malformed: true},
+	"disconnect":      {DisconnectAfter: 3},
+}
+
+// LoadScript reads a YAML (or JSON) script file.
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var script Script
+	if err = yaml.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("parse mock script %s: %w", path, err)
+	}
+	return &script, nil
+}
+
+// NewHandler returns an http.Handler serving the mock endpoints:
+//
+//	POST /v1/chat/completions                          OpenAI chat completions
+//	POST /v1/messages                                  Claude messages
+//	POST /v1beta/models/{model}:generateContent        Gemini
+//	POST /v1beta/models/{model}:streamGenerateContent  Gemini (alt=sse or JSON array)
+//	GET  /v1/models, /v1beta/models                    model listings
+func NewHandler(opts Options) http.Handler {
+	if opts.Text == "" {
+		opts.Text = DefaultText
+	}
+	if len(opts.Models) == 0 {
+		opts.Models = []string{"mock-model"}
+	}
+	m := &server{opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", m.serveOpenAI)
+	mux.HandleFunc("POST /chat/completions", m.serveOpenAI)
+	mux.HandleFunc("POST /v1/messages", m.serveClaude)
+	mux.HandleFunc("POST /v1beta/models/{target}", m.serveGemini)
+	mux.HandleFunc("GET /v1/models", m.serveOpenAIModels)
+	mux.HandleFunc("GET /v1beta/models", m.serveGeminiModels)
+	return mux
+}
+
+type server struct {
+	opts Options
+}
+
+// exchange is a parsed request with its resolved script response.
+type exchange struct {
+	model        string
+	body         []byte
+	stream       bool
+	resp         Response
+	deltas       []string
+	promptTokens int
+	firstDelay   time.Duration
+	tokenDelay   time.Duration
+}
+
+func (m *server) newExchange(r *http.Request, model string, body []byte, stream bool) *exchange {
+	resp := m.resolve(r, model, body)
+	if resp.Text == "" && len(resp.ToolCalls) == 0 {
+		resp.Text = m.opts.Text
+	}
+	ex := &exchange{
+		model:        model,
+		body:         body,
+		stream:       stream,
+		resp:         resp,
+		deltas:       words(resp.Text),
+		promptTokens: len(strings.Fields(string(body))),
+		firstDelay:   m.opts.FirstTokenDelay,
+		tokenDelay:   m.opts.TokenDelay,
+	}
+	if resp.FirstTokenDelayMs > 0 {
+		ex.firstDelay = time.Duration(resp.FirstTokenDelayMs) * time.Millisecond
+	}
+	if resp.TokenDelayMs > 0 {
+		ex.tokenDelay = time.Duration(resp.TokenDelayMs) * time.Millisecond
+	}
+	return ex
+}
+
+// completionTokens approximates the reply size: one token per text chunk plus one per tool call.
+func (ex *exchange) completionTokens() int {
+	return len(ex.deltas) + len(ex.resp.ToolCalls)
+}
+
+func (m *server) resolve(r *http.Request, model string, body []byte) Response {
+	name := strings.TrimSpace(r.Header.Get(ScenarioHeader))
+	if script := m.opts.Script; script != nil {
+		for _, rule := range script.Rules {
+			if name != "" {
+				if rule.Name == name {
+					return rule.Response
+				}
+				continue
+			}
+			if rule.Model != "" && !util.MatchModelWildcard(rule.Model, model) {
+				continue
+			}
+			if rule.Contains != "" && !strings.Contains(string(body), rule.Contains) {
+				continue
+			}
+			return rule.Response
+		}
+	}
+	if resp, ok := builtinScenarios[name]; ok {
+		return resp
+	}
+	if script := m.opts.Script; script != nil && script.Default != nil {
+		return *script.Default
+	}
+	return Response{}
+}
+
+// readBody reads a JSON request body, answering 400 in the given dialect when it is invalid.
+func readBody(w http.ResponseWriter, r *http.Request, fail func(http.ResponseWriter, int, string)) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || !gjson.ValidBytes(body) {
+		fail(w, http.StatusBadRequest, "invalid JSON body")
+		return nil, false
+	}
+	return body, true
+}
+
+// words splits text into stream deltas that keep their trailing space, so concatenating the
+// deltas reproduces the text exactly.
+func words(text string) []string {
+	fields := strings.SplitAfter(text, " ")
+	out := fields[:0]
+	for _, field := range fields {
+		if field != "" {
+			out = append(out, field)
+		}
+	}
+	return out
+}
+
+// argumentsJSON encodes tool call arguments, defaulting to an empty object.
+func (c ToolCall) argumentsJSON() string {
+	if len(c.Arguments) == 0 {
+		return "{}"
+	}
+	data, err := json.Marshal(c.Arguments)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// splitArguments cuts encoded arguments into pieces to emulate incremental tool call streaming.
+func splitArguments(args string) []string {
+	if len(args) < 8 {
+		return []string{args}
+	}
+	mid := len(args) / 2
+	return []string{args[:mid], args[mid:]}
+}
+
+func errorStatus(e *Error) int {
+	if e.Status > 0 {
+		return e.Status
+	}
+	return http.StatusInternalServerError
+}
+
+func errorMessage(e *Error) string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return http.StatusText(errorStatus(e))
+}
+
+// streamWriter writes flushed server-sent events.
+type streamWriter struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	flusher http.Flusher
+}
+
+func newStreamWriter(w http.ResponseWriter, r *http.Request, contentType string) *streamWriter {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	return &streamWriter{w: w, r: r, flusher: flusher}
+}
+
+// event writes one SSE event; an empty name omits the event field.
+func (s *streamWriter) event(name string, v any) {
+	data, _ := json.Marshal(v)
+	if name != "" {
+		_, _ = fmt.Fprintf(s.w, "event: %s\n", name)
+	}
+	_, _ = fmt.Fprintf(s.w, "data: %s\n\n", data)
+	s.flush()
+}
+
+func (s *streamWriter) raw(text string) {
+	_, _ = io.WriteString(s.w, text)
+	s.flush()
+}
+
+func (s *streamWriter) flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+// streamDeltas emits the text deltas with the configured pacing and the scripted faults.
+// It returns false once the stream has been terminated by an error event or a disconnect.
+func (ex *exchange) streamDeltas(s *streamWriter, emit func(delta string), malformed func(), emitError func(*Error)) bool {
+	for i, delta := range ex.deltas {
+		if i > 0 && !sleepContext(s.r, ex.tokenDelay) {
+			return false
+		}
+		if e := ex.resp.Error; e != nil && e.AfterChunks > 0 && i == e.AfterChunks {
+			emitError(e)
+			return false
+		}
+		if ex.resp.DisconnectAfter > 0 && i == ex.resp.DisconnectAfter {
+			// Abort the connection without a terminal chunk, like a dropped upstream.
+			panic(http.ErrAbortHandler)
+		}
+		if ex.resp.Malformed && i == len(ex.deltas)/2 {
+			malformed()
+		}
+		emit(delta)
+	}
+	if e := ex.resp.Error; e != nil && e.AfterChunks > 0 {
+		emitError(e)
+		return false
+	}
+	if ex.resp.Malformed && len(ex.deltas) == 0 {
+		malformed()
+	}
+	return true
+}
+
+// sleepContext waits for d unless the client goes away first.
+func sleepContext(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,186 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func post(t *testing.T, url, scenario, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scenario != "" {
+		req.Header.Set(ScenarioHeader, scenario)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func sseData(stream string) []string {
+	var out []string
+	for _, line := range strings.Split(stream, "\n") {
+		if payload, ok := strings.CutPrefix(line, "data: "); ok {
+			out = append(out, payload)
+		}
+	}
+	return out
+}
+
+func TestOpenAIStreamingText(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(Options{Text: "hello mock world"}))
+	defer srv.Close()
+
+	_, body := post(t, srv.URL+"/v1/chat/completions", "", `{"model":"m","stream":true,"stream_options":{"include_usage":true}}`)
+	events := sseData(body)
+	if events[len(events)-1] != "[DONE]" {
+		t.Fatalf("missing [DONE]: %q", body)
+	}
+	var text strings.Builder
+	for _, event := range events[:len(events)-1] {
+		text.WriteString(gjson.Get(event, "choices.0.delta.content").String())
+	}
+	if text.String() != "hello mock world" {
+		t.Fatalf("text = %q", text.String())
+	}
+	final := events[len(events)-2]
+	if gjson.Get(final, "choices.0.finish_reason").String() != "stop" || gjson.Get(final, "usage.completion_tokens").Int() != 3 {
+		t.Fatalf("final chunk = %s", final)
+	}
+}
+
+func TestToolCallsInEveryDialect(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(Options{}))
+	defer srv.Close()
+
+	_, body := post(t, srv.URL+"/v1/chat/completions", "tool_call", `{"model":"m","stream":true}`)
+	var args strings.Builder
+	for _, event := range sseData(body) {
+		args.WriteString(gjson.Get(event, "choices.0.delta.tool_calls.0.function.arguments").String())
+	}
+	if !json.Valid([]byte(args.String())) || !strings.Contains(body, `"finish_reason":"tool_calls"`) {
+		t.Fatalf("openai tool call stream: %s", body)
+	}
+
+	_, body = post(t, srv.URL+"/v1/messages", "text_and_tool_call", `{"model":"m","stream":true}`)
+	if !strings.Contains(body, `"type":"tool_use"`) || !strings.Contains(body, "input_json_delta") || !strings.Contains(body, `"stop_reason":"tool_use"`) {
+		t.Fatalf("claude tool call stream: %s", body)
+	}
+
+	_, body = post(t, srv.URL+"/v1beta/models/gemini-mock:generateContent", "tool_call", `{"contents":[]}`)
+	if gjson.Get(body, "candidates.0.content.parts.0.functionCall.args.location").String() != "Paris" {
+		t.Fatalf("gemini tool call: %s", body)
+	}
+}
+
+func TestGeminiStreamingFormats(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(Options{Text: "a b"}))
+	defer srv.Close()
+
+	_, body := post(t, srv.URL+"/v1beta/models/gemini-mock:streamGenerateContent?alt=sse", "", `{}`)
+	events := sseData(body)
+	if len(events) != 3 || gjson.Get(events[2], "candidates.0.finishReason").String() != "STOP" {
+		t.Fatalf("sse stream: %q", body)
+	}
+
+	_, body = post(t, srv.URL+"/v1beta/models/gemini-mock:streamGenerateContent", "", `{}`)
+	if !gjson.Valid(body) || len(gjson.Parse(body).Array()) != 3 {
+		t.Fatalf("json array stream: %q", body)
+	}
+}
+
+func TestErrorsAndFaults(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(Options{Text: "one two three four five six"}))
+	defer srv.Close()
+
+	resp, body := post(t, srv.URL+"/v1/messages", "rate_limit", `{"model":"m"}`)
+	if resp.StatusCode != http.StatusTooManyRequests || gjson.Get(body, "error.type").String() != "rate_limit_error" {
+		t.Fatalf("rate limit: %d %s", resp.StatusCode, body)
+	}
+
+	resp, body = post(t, srv.URL+"/v1/chat/completions", "midstream_error", `{"model":"m","stream":true}`)
+	events := sseData(body)
+	if resp.StatusCode != http.StatusOK || !gjson.Get(events[len(events)-1], "error").Exists() || strings.Contains(body, "[DONE]") {
+		t.Fatalf("midstream error: %d %q", resp.StatusCode, body)
+	}
+
+	_, body = post(t, srv.URL+"/v1/chat/completions", "malformed", `{"model":"m","stream":true}`)
+	invalid := 0
+	for _, event := range sseData(body) {
+		if event != "[DONE]" && !gjson.Valid(event) {
+			invalid++
+		}
+	}
+	if invalid != 1 {
+		t.Fatalf("malformed chunks = %d: %q", invalid, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/chat/completions", strings.NewReader(`{"model":"m","stream":true}`))
+	req.Header.Set(ScenarioHeader, "disconnect")
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		_, errRead := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if errRead == nil {
+			t.Fatal("expected the disconnect scenario to abort the response body")
+		}
+	}
+}
+
+func TestScriptRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	script := `
+rules:
+  - name: weather
+    contains: "weather"
+    response:
+      tool-calls:
+        - name: lookup
+          arguments: {city: Oslo}
+  - model: "claude-*"
+    response:
+      text: "scripted claude"
+default:
+  text: "fallback"
+`
+	if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadScript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewHandler(Options{Script: loaded}))
+	defer srv.Close()
+
+	_, body := post(t, srv.URL+"/v1/chat/completions", "", `{"model":"gpt","messages":[{"role":"user","content":"what is the weather"}]}`)
+	if gjson.Get(body, "choices.0.message.tool_calls.0.function.name").String() != "lookup" {
+		t.Fatalf("contains rule: %s", body)
+	}
+	_, body = post(t, srv.URL+"/v1/messages", "", `{"model":"claude-test"}`)
+	if gjson.Get(body, "content.0.text").String() != "scripted claude" {
+		t.Fatalf("model rule: %s", body)
+	}
+	_, body = post(t, srv.URL+"/v1/chat/completions", "", `{"model":"gpt"}`)
+	if gjson.Get(body, "choices.0.message.content").String() != "fallback" {
+		t.Fatalf("default: %s", body)
+	}
+	_, body = post(t, srv.URL+"/v1/chat/completions", "weather", `{"model":"gpt"}`)
+	if !bytes.Contains([]byte(body), []byte(`"lookup"`)) {
+		t.Fatalf("named rule: %s", body)
+	}
+}
@@ -0,0 +1,134 @@
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func (m *server) serveOpenAI(w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r, writeOpenAIError)
+	if !ok {
+		return
+	}
+	model := gjson.GetBytes(body, "model").String()
+	if model == "" {
+		model = m.opts.Models[0]
+	}
+	ex := m.newExchange(r, model, body, gjson.GetBytes(body, "stream").Bool())
+	if e := ex.resp.Error; e != nil && (e.AfterChunks == 0 || !ex.stream) {
+		writeOpenAIError(w, errorStatus(e), errorMessage(e))
+		return
+	}
+	if !sleepContext(r, ex.firstDelay) {
+		return
+	}
+
+	id := fmt.Sprintf("chatcmpl-mock-%d", time.Now().UnixNano())
+	created := time.Now().Unix()
+	finish := "stop"
+	if len(ex.resp.ToolCalls) > 0 {
+		finish = "tool_calls"
+	}
+
+	if !ex.stream {
+		message := map[string]any{"role": "assistant", "content": ex.resp.Text}
+		if len(ex.resp.ToolCalls) > 0 {
+			calls := make([]any, 0, len(ex.resp.ToolCalls))
+			for i, call := range ex.resp.ToolCalls {
+				calls = append(calls, map[string]any{
+					"id":       fmt.Sprintf("call_mock_%d", i),
+					"type":     "function",
+					"function": map[string]any{"name": call.Name, "arguments": call.argumentsJSON()},
+				})
+			}
+			message["tool_calls"] = calls
+			if ex.resp.Text == "" {
+				message["content"] = nil
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      id,
+			"object":  "chat.completion",
+			"created": created,
+			"model":   model,
+			"choices": []any{map[string]any{"index": 0, "message": message, "finish_reason": finish}},
+			"usage":   openAIUsage(ex.promptTokens, ex.completionTokens()),
+		})
+		return
+	}
+
+	s := newStreamWriter(w, r, "text/event-stream")
+	chunk := func(delta map[string]any, finishReason any) map[string]any {
+		return map[string]any{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   model,
+			"choices": []any{map[string]any{"index": 0, "delta": delta, "finish_reason": finishReason}},
+		}
+	}
+
+	s.event("", chunk(map[string]any{"role": "assistant", "content": ""}, nil))
+	completed := ex.streamDeltas(s,
+		func(delta string) { s.event("", chunk(map[string]any{"content": delta}, nil)) },
+		func() { s.raw("data: {\"id\":\"" + id + "\",\"choices\":[{\"delta\":{\"content\":\"trunc\n\n") },
+		func(e *Error) {
+			s.event("", map[string]any{"error": map[string]any{"message": errorMessage(e), "type": "server_error", "code": errorStatus(e)}})
+		},
+	)
+	if !completed {
+		return
+	}
+	for i, call := range ex.resp.ToolCalls {
+		s.event("", chunk(map[string]any{"tool_calls": []any{map[string]any{
+			"index":    i,
+			"id":       fmt.Sprintf("call_mock_%d", i),
+			"type":     "function",
+			"function": map[string]any{"name": call.Name, "arguments": ""},
+		}}}, nil))
+		for _, piece := range splitArguments(call.argumentsJSON()) {
+			s.event("", chunk(map[string]any{"tool_calls": []any{map[string]any{
+				"index":    i,
+				"function": map[string]any{"arguments": piece},
+			}}}, nil))
+		}
+	}
+	final := chunk(map[string]any{}, finish)
+	if gjson.GetBytes(body, "stream_options.include_usage").Bool() {
+		final["usage"] = openAIUsage(ex.promptTokens, ex.completionTokens())
+	}
+	s.event("", final)
+	s.raw("data: [DONE]\n\n")
+}
+
+func (m *server) serveOpenAIModels(w http.ResponseWriter, _ *http.Request) {
+	data := make([]any, 0, len(m.opts.Models))
+	for _, model := range m.opts.Models {
+		data = append(data, map[string]any{"id": model, "object": "model", "created": 0, "owned_by": "mock"})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+func openAIUsage(prompt, completion int) map[string]any {
+	return map[string]any{
+		"prompt_tokens":     prompt,
+		"completion_tokens": completion,
+		"total_tokens":      prompt + completion,
+	}
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, message string) {
+	errType := "server_error"
+	if status < http.StatusInternalServerError {
+		errType = "invalid_request_error"
+	}
+	if status == http.StatusTooManyRequests {
+		errType = "rate_limit_error"
+	}
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{"message": message, "type": errType, "code": status},
+	})
+}
@@ -0,0 +1,242 @@
+// Package pii detects personal data and secrets in JSON payloads and replaces them with
+// reversible placeholders.
+package pii
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// builtinDetectors are the detectors enabled when none are listed explicitly.
+var builtinDetectors = map[string]string{
+	"email":        `[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`,
+	"api-key":      `\b(?:sk-(?:ant-|proj-)?[A-Za-z0-9_-]{20,}|AIza[0-9A-Za-z_-]{35}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abprs]-[A-Za-z0-9-]{10,})`,
+	"jwt":          `\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`,
+	"bearer-token": `(?i)\bbearer\s+([A-Za-z0-9._~+/-]{20,}=*)`,
+}
+
+type detector struct {
+	label string
+	re    *regexp.Regexp
+}
+
+// Redactor holds the compiled detectors of a configuration.
+type Redactor struct {
+	detectors []detector
+	providers map[string]struct{}
+	restore   bool
+}
+
+// New compiles the detectors described by cfg.
+func New(cfg config.PIIRedactionConfig) (*Redactor, error) {
+	r := &Redactor{restore: !cfg.KeepPlaceholders}
+	names := cfg.Detectors
+	if len(names) == 0 {
+		for name := range builtinDetectors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		expr, ok := builtinDetectors[name]
+		if !ok {
+			return nil, fmt.Errorf("pii: unknown detector %q", name)
+		}
+		r.detectors = append(r.detectors, detector{label: placeholderLabel(name), re: regexp.MustCompile(expr)})
+	}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pii: pattern %q: %w", p.Name, err)
+		}
+		name := p.Name
+		if strings.TrimSpace(name) == "" {
+			name = "custom"
+		}
+		r.detectors = append(r.detectors, detector{label: placeholderLabel(name), re: re})
+	}
+	if len(cfg.Providers) > 0 {
+		r.providers = make(map[string]struct{}, len(cfg.Providers))
+		for _, p := range cfg.Providers {
+			r.providers[strings.ToLower(strings.TrimSpace(p))] = struct{}{}
+		}
+	}
+	return r, nil
+}
+
+func placeholderLabel(name string) string {
+	label := strings.ToUpper(strings.TrimSpace(name))
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, label)
+}
+
+// AppliesTo reports whether requests routed to any of providers must be redacted.
+func (r *Redactor) AppliesTo(providers []string) bool {
+	if r == nil || len(r.detectors) == 0 {
+		return false
+	}
+	if r.providers == nil {
+		return true
+	}
+	for _, p := range providers {
+		if _, ok := r.providers[strings.ToLower(p)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Session redacts one request and restores its placeholders in the matching response. The same
+// value always maps to the same placeholder within a session.
+type Session struct {
+	r        *Redactor
+	byValue  map[string]string
+	byHolder map[string]string
+	counts   map[string]int
+}
+
+// NewSession starts a redaction session.
+func (r *Redactor) NewSession() *Session {
+	return &Session{
+		r:        r,
+		byValue:  make(map[string]string),
+		byHolder: make(map[string]string),
+		counts:   make(map[string]int),
+	}
+}
+
+// Len returns the number of distinct values redacted so far.
+func (s *Session) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.byHolder)
+}
+
+// RedactJSON replaces detected values inside the string values of a JSON document. Object keys
+// are left untouched.
+func (s *Session) RedactJSON(body []byte) []byte {
+	if s == nil {
+		return body
+	}
+	return RewriteStrings(body, s.redactString)
+}
+
+func (s *Session) redactString(text string) (string, bool) {
+	changed := false
+	for _, d := range s.r.detectors {
+		if !d.re.MatchString(text) {
+			continue
+		}
+		var out strings.Builder
+		last := 0
+		for _, loc := range d.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			if len(loc) >= 4 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			if start < last {
+				continue
+			}
+			out.WriteString(text[last:start])
+			out.WriteString(s.placeholder(d.label, text[start:end]))
+			last = end
+		}
+		out.WriteString(text[last:])
+		text = out.String()
+		changed = true
+	}
+	return text, changed
+}
+
+func (s *Session) placeholder(label, value string) string {
+	if holder, ok := s.byValue[value]; ok {
+		return holder
+	}
+	s.counts[label]++
+	holder := fmt.Sprintf("[REDACTED_%s_%d]", label, s.counts[label])
+	s.byValue[value] = holder
+	s.byHolder[holder] = value
+	return holder
+}
+
+// Restore replaces placeholders in a JSON payload, or in SSE lines carrying JSON, with the
+// original values. Placeholders split across streaming chunks are not restored.
+func (s *Session) Restore(payload []byte) []byte {
+	if s == nil || !s.r.restore || len(s.byHolder) == 0 || !bytes.Contains(payload, []byte("[REDACTED_")) {
+		return payload
+	}
+	for holder, value := range s.byHolder {
+		if !bytes.Contains(payload, []byte(holder)) {
+			continue
+		}
+		payload = bytes.ReplaceAll(payload, []byte(holder), jsonEscape(value))
+	}
+	return payload
+}
+
+// jsonEscape returns value encoded for inclusion inside a JSON string literal.
+func jsonEscape(value string) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(value)
+	out := bytes.TrimSpace(buf.Bytes())
+	return out[1 : len(out)-1]
+}
+
+// RewriteStrings calls fn for every string value of a JSON document and splices in the
+// replacements, preserving the rest of the document byte for byte.
+func RewriteStrings(body []byte, fn func(string) (string, bool)) []byte {
+	var out []byte
+	last := 0
+	for i := 0; i < len(body); i++ {
+		if body[i] != '"' {
+			continue
+		}
+		end := i + 1
+		for end < len(body) && body[end] != '"' {
+			if body[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(body) {
+			break
+		}
+		literal := body[i : end+1]
+		next := end + 1
+		for next < len(body) && (body[next] == ' ' || body[next] == '\t' || body[next] == '\n' || body[next] == '\r') {
+			next++
+		}
+		isKey := next < len(body) && body[next] == ':'
+		if !isKey {
+			var value string
+			if err := json.Unmarshal(literal, &value); err == nil {
+				if replaced, changed := fn(value); changed {
+					out = append(out, body[last:i]...)
+					out = append(out, '"')
+					out = append(out, jsonEscape(replaced)...)
+					out = append(out, '"')
+					last = end + 1
+				}
+			}
+		}
+		i = end
+	}
+	if out == nil {
+		return body
+	}
+	return append(out, body[last:]...)
+}
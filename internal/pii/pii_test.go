@@ -0,0 +1,62 @@
+package pii
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestRedactAndRestore(t *testing.T) {
+	r, err := New(config.PIIRedactionConfig{
+		Enable:   true,
+		Patterns: []config.PIIPattern{{Name: "employee-id", Pattern: `EMP-\d{6}`}},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	body := []byte(`{"email":"keep-key","messages":[{"role":"user","content":"Mail jane.doe@example.com or jane.doe@example.com about EMP-123456, token Bearer abcdefghijklmnopqrstuvwxyz0123"}]}`)
+	s := r.NewSession()
+	out := s.RedactJSON(body)
+
+	content := gjson.GetBytes(out, "messages.0.content").String()
+	for _, leaked := range []string{"jane.doe@example.com", "EMP-123456", "abcdefghijklmnopqrstuvwxyz0123"} {
+		if strings.Contains(content, leaked) {
+			t.Fatalf("value %q was not redacted: %s", leaked, content)
+		}
+	}
+	if strings.Count(content, "[REDACTED_EMAIL_1]") != 2 || !strings.Contains(content, "Bearer [REDACTED_BEARER_TOKEN_1]") {
+		t.Fatalf("unexpected placeholders: %s", content)
+	}
+	if !gjson.GetBytes(out, "email").Exists() {
+		t.Fatalf("object keys must not be rewritten: %s", out)
+	}
+	if s.Len() != 3 {
+		t.Fatalf("expected 3 redacted values, got %d", s.Len())
+	}
+
+	resp := []byte(`data: {"choices":[{"delta":{"content":"Wrote to [REDACTED_EMAIL_1] re [REDACTED_EMPLOYEE_ID_1]"}}]}`)
+	restored := string(s.Restore(resp))
+	if !strings.Contains(restored, "Wrote to jane.doe@example.com re EMP-123456") {
+		t.Fatalf("placeholders not restored: %s", restored)
+	}
+}
+
+func TestProvidersAndKeepPlaceholders(t *testing.T) {
+	r, err := New(config.PIIRedactionConfig{Enable: true, Detectors: []string{"email"}, Providers: []string{"Gemini"}, KeepPlaceholders: true})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if r.AppliesTo([]string{"claude"}) || !r.AppliesTo([]string{"claude", "gemini"}) {
+		t.Fatalf("provider scoping is wrong")
+	}
+	s := r.NewSession()
+	s.RedactJSON([]byte(`{"input":"a@b.io"}`))
+	if got := string(s.Restore([]byte(`{"output":"[REDACTED_EMAIL_1]"}`))); got != `{"output":"[REDACTED_EMAIL_1]"}` {
+		t.Fatalf("placeholders should be kept: %s", got)
+	}
+	if _, err = New(config.PIIRedactionConfig{Detectors: []string{"ssn"}}); err == nil {
+		t.Fatalf("expected error for unknown detector")
+	}
+}
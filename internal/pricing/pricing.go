@@ -0,0 +1,127 @@
+// Package pricing estimates the cost of upstream requests from per-model token prices.
+//
+// A built-in table covers common models; the pricing section of the config overrides or extends
+// it. Prices are in US dollars per million tokens.
+package pricing
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// Price holds the token prices of a model in USD per million tokens.
+type Price struct {
+	Input       float64 `json:"input"`
+	Output      float64 `json:"output"`
+	CachedInput float64 `json:"cached_input,omitempty"`
+}
+
+// builtin maps model name prefixes to list prices. The longest matching prefix wins, so dated
+// snapshots and suffixed variants resolve to their family.
+var builtin = map[string]Price{
+	"gpt-4o":                {Input: 2.5, Output: 10, CachedInput: 1.25},
+	"gpt-4o-mini":           {Input: 0.15, Output: 0.6, CachedInput: 0.075},
+	"gpt-4.1":               {Input: 2, Output: 8, CachedInput: 0.5},
+	"gpt-4.1-mini":          {Input: 0.4, Output: 1.6, CachedInput: 0.1},
+	"gpt-4.1-nano":          {Input: 0.1, Output: 0.4, CachedInput: 0.025},
+	"gpt-5":                 {Input: 1.25, Output: 10, CachedInput: 0.125},
+	"gpt-5-mini":            {Input: 0.25, Output: 2, CachedInput: 0.025},
+	"gpt-5-nano":            {Input: 0.05, Output: 0.4, CachedInput: 0.005},
+	"o3":                    {Input: 2, Output: 8, CachedInput: 0.5},
+	"o4-mini":               {Input: 1.1, Output: 4.4, CachedInput: 0.275},
+	"claude-opus-4":         {Input: 15, Output: 75, CachedInput: 1.5},
+	"claude-sonnet-4":       {Input: 3, Output: 15, CachedInput: 0.3},
+	"claude-3-7-sonnet":     {Input: 3, Output: 15, CachedInput: 0.3},
+	"claude-3-5-sonnet":     {Input: 3, Output: 15, CachedInput: 0.3},
+	"claude-haiku-4-5":      {Input: 1, Output: 5, CachedInput: 0.1},
+	"claude-3-5-haiku":      {Input: 0.8, Output: 4, CachedInput: 0.08},
+	"gemini-2.5-pro":        {Input: 1.25, Output: 10, CachedInput: 0.31},
+	"gemini-2.5-flash":      {Input: 0.3, Output: 2.5, CachedInput: 0.075},
+	"gemini-2.5-flash-lite": {Input: 0.1, Output: 0.4, CachedInput: 0.025},
+	"gemini-2.0-flash":      {Input: 0.1, Output: 0.4, CachedInput: 0.025},
+	"qwen3-coder-plus":      {Input: 1, Output: 5},
+}
+
+var overrides atomic.Pointer[[]config.ModelPrice]
+
+// Configure replaces the configured price overrides.
+func Configure(prices []config.ModelPrice) {
+	list := make([]config.ModelPrice, 0, len(prices))
+	for _, p := range prices {
+		p.Model = strings.ToLower(strings.TrimSpace(p.Model))
+		if p.Model != "" {
+			list = append(list, p)
+		}
+	}
+	overrides.Store(&list)
+}
+
+// Lookup returns the price of model and whether one is known. Configured prices take precedence
+// over the built-in table and match either the full model name, provider prefix included, or the
+// bare model name.
+func Lookup(model string) (Price, bool) {
+	name := normalizeModel(model)
+	if name == "" {
+		return Price{}, false
+	}
+	full := strings.ToLower(strings.TrimSpace(model))
+	if list := overrides.Load(); list != nil {
+		for _, p := range *list {
+			if p.Model == full || p.Model == name {
+				return Price{Input: p.Input, Output: p.Output, CachedInput: p.CachedInput}, true
+			}
+		}
+		for _, p := range *list {
+			if strings.Contains(p.Model, "*") && (util.MatchModelWildcard(p.Model, full) || util.MatchModelWildcard(p.Model, name)) {
+				return Price{Input: p.Input, Output: p.Output, CachedInput: p.CachedInput}, true
+			}
+		}
+	}
+	best := ""
+	for prefix := range builtin {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return builtin[best], true
+}
+
+// Cost estimates the cost in USD of the tokens in detail. Cached input tokens are assumed to be
+// included in the input count. Reasoning tokens are billed as output when the provider reports
+// them separately from the output count.
+func Cost(model string, detail coreusage.Detail) (float64, bool) {
+	price, ok := Lookup(model)
+	if !ok {
+		return 0, false
+	}
+	cached := detail.CachedTokens
+	if cached > detail.InputTokens {
+		cached = detail.InputTokens
+	}
+	cachedPrice := price.CachedInput
+	if cachedPrice <= 0 {
+		cachedPrice = price.Input
+	}
+	output := detail.OutputTokens
+	if detail.ReasoningTokens > 0 && detail.TotalTokens >= detail.InputTokens+detail.OutputTokens+detail.ReasoningTokens {
+		output += detail.ReasoningTokens
+	}
+	cost := float64(detail.InputTokens-cached)*price.Input + float64(cached)*cachedPrice + float64(output)*price.Output
+	return cost / 1e6, true
+}
+
+// normalizeModel lowercases model and drops any provider or "models/" path prefix.
+func normalizeModel(model string) string {
+	name := strings.ToLower(strings.TrimSpace(model))
+	if idx := strings.LastIndexByte(name, '/'); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return name
+}
@@ -0,0 +1,63 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestLookupPrefersLongestBuiltinPrefix(t *testing.T) {
+	Configure(nil)
+	price, ok := Lookup("gpt-4o-mini-2024-07-18")
+	if !ok || price.Input != 0.15 {
+		t.Fatalf("gpt-4o-mini price = %+v, %v", price, ok)
+	}
+	if price, ok = Lookup("models/gemini-2.5-pro"); !ok || price.Output != 10 {
+		t.Fatalf("gemini-2.5-pro price = %+v, %v", price, ok)
+	}
+	if _, ok = Lookup("unknown-model"); ok {
+		t.Fatal("unknown model should have no price")
+	}
+}
+
+func TestConfiguredPricesOverrideBuiltin(t *testing.T) {
+	Configure([]config.ModelPrice{{Model: "gpt-4o*", Input: 1, Output: 2}, {Model: "my-model", Input: 3, Output: 4}})
+	defer Configure(nil)
+	if price, _ := Lookup("gpt-4o-mini"); price.Input != 1 {
+		t.Fatalf("wildcard override not applied: %+v", price)
+	}
+	if price, ok := Lookup("My-Model"); !ok || price.Output != 4 {
+		t.Fatalf("exact override not applied: %+v, %v", price, ok)
+	}
+}
+
+func TestWildcardPricesMatchProviderPrefixedModels(t *testing.T) {
+	Configure([]config.ModelPrice{{Model: "openrouter/*", Input: 5, Output: 6}, {Model: "gpt-*-mini", Input: 7, Output: 8}})
+	defer Configure(nil)
+	if price, ok := Lookup("openrouter/anthropic/claude-x"); !ok || price.Input != 5 {
+		t.Fatalf("openrouter/anthropic/claude-x price = %+v, %v; want input 5", price, ok)
+	}
+	if price, ok := Lookup("GPT-4.1-mini"); !ok || price.Output != 8 {
+		t.Fatalf("gpt-4.1-mini price = %+v, %v; want output 8", price, ok)
+	}
+	if _, ok := Lookup("anthropic/claude-x"); ok {
+		t.Fatal("anthropic/claude-x should have no price")
+	}
+}
+
+func TestCost(t *testing.T) {
+	Configure([]config.ModelPrice{{Model: "m", Input: 2, Output: 10, CachedInput: 1}})
+	defer Configure(nil)
+	cost, ok := Cost("m", coreusage.Detail{InputTokens: 1_000_000, CachedTokens: 500_000, OutputTokens: 100_000, TotalTokens: 1_100_000})
+	// 0.5M uncached * $2 + 0.5M cached * $1 + 0.1M output * $10
+	if !ok || math.Abs(cost-2.5) > 1e-9 {
+		t.Fatalf("cost = %v, %v; want 2.5", cost, ok)
+	}
+	// Reasoning reported separately from output is billed as output.
+	cost, _ = Cost("m", coreusage.Detail{OutputTokens: 100_000, ReasoningTokens: 100_000, TotalTokens: 200_000})
+	if math.Abs(cost-2) > 1e-9 {
+		t.Fatalf("cost with separate reasoning = %v; want 2", cost)
+	}
+}
@@ -0,0 +1,204 @@
+// Package ratelimit synthesizes the rate-limit response headers client SDKs use for backoff.
+//
+// A State travels in the request context. The proxy's own limiters report their windows into it,
+// and the upstream transport records the windows reported by the provider that served the
+// request. When the response is written, the most restrictive window of each kind is emitted in
+// the dialect of the client API: anthropic-ratelimit-* for Claude and x-ratelimit-* for OpenAI.
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Window kinds.
+const (
+	Requests = "requests"
+	Tokens   = "tokens"
+)
+
+// Dialect selects the header names and formats written to the client.
+type Dialect int
+
+const (
+	// Anthropic writes anthropic-ratelimit-<kind>-limit/-remaining/-reset with RFC 3339 resets.
+	Anthropic Dialect = iota + 1
+	// OpenAI writes x-ratelimit-limit-<kind>, x-ratelimit-remaining-<kind> and
+	// x-ratelimit-reset-<kind> with duration resets such as "6m0s".
+	OpenAI
+)
+
+// Window is the state of one limit.
+type Window struct {
+	Limit     int64
+	Remaining int64
+	Reset     time.Time
+}
+
+// tighter reports whether w is more restrictive than other.
+func (w Window) tighter(other Window) bool {
+	if w.Remaining != other.Remaining {
+		return w.Remaining < other.Remaining
+	}
+	return w.Reset.After(other.Reset)
+}
+
+// State collects the windows that apply to one request.
+type State struct {
+	mu       sync.Mutex
+	proxy    map[string]Window
+	upstream map[string]Window
+}
+
+// NewState returns an empty state.
+func NewState() *State {
+	return &State{proxy: make(map[string]Window), upstream: make(map[string]Window)}
+}
+
+type contextKey struct{}
+
+// WithState returns a context carrying s.
+func WithState(ctx context.Context, s *State) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// FromContext returns the state carried by ctx, or nil.
+func FromContext(ctx context.Context) *State {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(contextKey{}).(*State)
+	return s
+}
+
+// Limit reports a window of one of the proxy's own limiters. The most restrictive window of each
+// kind is kept. It is a no-op on a nil state.
+func (s *State) Limit(kind string, w Window) {
+	if s == nil || w.Limit <= 0 {
+		return
+	}
+	w.Remaining = max(w.Remaining, 0)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.proxy[kind]; !ok || w.tighter(current) {
+		s.proxy[kind] = w
+	}
+}
+
+// ObserveUpstream records the windows reported in an upstream response. Each response replaces
+// the windows of earlier attempts, so the credential that finally served the request wins. It is
+// a no-op on a nil state or when the headers report no window.
+func (s *State) ObserveUpstream(header http.Header, now time.Time) {
+	if s == nil {
+		return
+	}
+	windows := ParseHeaders(header, now)
+	if len(windows) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.upstream = windows
+}
+
+// Effective returns the most restrictive window of kind.
+func (s *State) Effective(kind string) (Window, bool) {
+	if s == nil {
+		return Window{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	proxy, okProxy := s.proxy[kind]
+	upstream, okUpstream := s.upstream[kind]
+	switch {
+	case okProxy && okUpstream:
+		if upstream.tighter(proxy) {
+			return upstream, true
+		}
+		return proxy, true
+	case okProxy:
+		return proxy, true
+	default:
+		return upstream, okUpstream
+	}
+}
+
+// WriteHeaders sets the rate-limit headers of every known window in the given dialect.
+func (s *State) WriteHeaders(header http.Header, dialect Dialect, now time.Time) {
+	for _, kind := range []string{Requests, Tokens} {
+		w, ok := s.Effective(kind)
+		if !ok {
+			continue
+		}
+		switch dialect {
+		case Anthropic:
+			prefix := "anthropic-ratelimit-" + kind + "-"
+			header.Set(prefix+"limit", strconv.FormatInt(w.Limit, 10))
+			header.Set(prefix+"remaining", strconv.FormatInt(w.Remaining, 10))
+			if !w.Reset.IsZero() {
+				header.Set(prefix+"reset", w.Reset.UTC().Format(time.RFC3339))
+			}
+		case OpenAI:
+			header.Set("x-ratelimit-limit-"+kind, strconv.FormatInt(w.Limit, 10))
+			header.Set("x-ratelimit-remaining-"+kind, strconv.FormatInt(w.Remaining, 10))
+			if !w.Reset.IsZero() {
+				header.Set("x-ratelimit-reset-"+kind, max(w.Reset.Sub(now), 0).Round(time.Millisecond).String())
+			}
+		}
+	}
+}
+
+// DialectForPath returns the header dialect of a client API route.
+func DialectForPath(path string) (Dialect, bool) {
+	switch {
+	case strings.HasPrefix(path, "/v1/messages"):
+		return Anthropic, true
+	case path == "/v1/chat/completions", path == "/v1/completions", path == "/v1/responses", strings.HasPrefix(path, "/v1/responses/"):
+		return OpenAI, true
+	}
+	return 0, false
+}
+
+// ParseHeaders extracts the request and token windows reported by Anthropic or OpenAI style
+// upstream headers.
+func ParseHeaders(header http.Header, now time.Time) map[string]Window {
+	windows := make(map[string]Window)
+	for _, kind := range []string{Requests, Tokens} {
+		if w, ok := parseWindow(header, "Anthropic-Ratelimit-"+kind+"-Limit", "Anthropic-Ratelimit-"+kind+"-Remaining", "Anthropic-Ratelimit-"+kind+"-Reset", now); ok {
+			windows[kind] = w
+			continue
+		}
+		if w, ok := parseWindow(header, "X-Ratelimit-Limit-"+kind, "X-Ratelimit-Remaining-"+kind, "X-Ratelimit-Reset-"+kind, now); ok {
+			windows[kind] = w
+		}
+	}
+	return windows
+}
+
+func parseWindow(header http.Header, limitKey, remainingKey, resetKey string, now time.Time) (Window, bool) {
+	limit, errLimit := strconv.ParseInt(strings.TrimSpace(header.Get(limitKey)), 10, 64)
+	remaining, errRemaining := strconv.ParseInt(strings.TrimSpace(header.Get(remainingKey)), 10, 64)
+	if errLimit != nil || errRemaining != nil || limit <= 0 {
+		return Window{}, false
+	}
+	return Window{Limit: limit, Remaining: max(remaining, 0), Reset: parseReset(header.Get(resetKey), now)}, true
+}
+
+// parseReset accepts RFC 3339 timestamps (Anthropic) and durations such as "1s" or "6m0s" (OpenAI).
+func parseReset(value string, now time.Time) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d)
+	}
+	return time.Time{}
+}
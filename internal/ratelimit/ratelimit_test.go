@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseHeaders(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	anthropic := http.Header{}
+	anthropic.Set("anthropic-ratelimit-requests-limit", "50")
+	anthropic.Set("anthropic-ratelimit-requests-remaining", "49")
+	anthropic.Set("anthropic-ratelimit-requests-reset", "2026-01-01T00:00:30Z")
+	openai := http.Header{}
+	openai.Set("x-ratelimit-limit-tokens", "10000")
+	openai.Set("x-ratelimit-remaining-tokens", "9000")
+	openai.Set("x-ratelimit-reset-tokens", "6m0s")
+
+	if w := ParseHeaders(anthropic, now)[Requests]; w.Limit != 50 || w.Remaining != 49 || !w.Reset.Equal(now.Add(30*time.Second)) {
+		t.Fatalf("anthropic window = %+v", w)
+	}
+	if w := ParseHeaders(openai, now)[Tokens]; w.Limit != 10000 || w.Remaining != 9000 || !w.Reset.Equal(now.Add(6*time.Minute)) {
+		t.Fatalf("openai window = %+v", w)
+	}
+}
+
+func TestStateKeepsMostRestrictiveWindow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewState()
+	s.Limit(Requests, Window{Limit: 60, Remaining: 10, Reset: now.Add(time.Minute)})
+	s.Limit(Requests, Window{Limit: 1000, Remaining: 500, Reset: now.Add(time.Hour)})
+
+	upstream := http.Header{}
+	upstream.Set("x-ratelimit-limit-requests", "100")
+	upstream.Set("x-ratelimit-remaining-requests", "3")
+	upstream.Set("x-ratelimit-reset-requests", "1.5s")
+	upstream.Set("x-ratelimit-limit-tokens", "5000")
+	upstream.Set("x-ratelimit-remaining-tokens", "4000")
+	s.ObserveUpstream(upstream, now)
+
+	out := http.Header{}
+	s.WriteHeaders(out, OpenAI, now)
+	if out.Get("x-ratelimit-remaining-requests") != "3" || out.Get("x-ratelimit-reset-requests") != "1.5s" || out.Get("x-ratelimit-limit-tokens") != "5000" {
+		t.Fatalf("openai headers = %v", out)
+	}
+
+	// A later attempt on another credential replaces the upstream windows.
+	retry := http.Header{}
+	retry.Set("anthropic-ratelimit-requests-limit", "100")
+	retry.Set("anthropic-ratelimit-requests-remaining", "90")
+	s.ObserveUpstream(retry, now)
+	out = http.Header{}
+	s.WriteHeaders(out, Anthropic, now)
+	if out.Get("anthropic-ratelimit-requests-remaining") != "10" || out.Get("anthropic-ratelimit-requests-reset") != "2026-01-01T00:01:00Z" {
+		t.Fatalf("anthropic headers = %v", out)
+	}
+	if out.Get("anthropic-ratelimit-tokens-limit") != "" {
+		t.Fatalf("stale token window kept: %v", out)
+	}
+}
+
+func TestNilStateIsNoop(t *testing.T) {
+	var s *State
+	s.Limit(Requests, Window{Limit: 1})
+	s.ObserveUpstream(http.Header{}, time.Now())
+	if _, ok := s.Effective(Requests); ok {
+		t.Fatal("nil state reported a window")
+	}
+}
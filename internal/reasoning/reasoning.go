@@ -0,0 +1,206 @@
+// Package reasoning applies the configured reasoning-rules to translated upstream requests: a
+// default reasoning depth for requests that set none and limits on thinking budgets.
+package reasoning
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// effortRank orders the effort levels shared by the supported protocols.
+var effortRank = map[string]int{"none": 0, "minimal": 1, "low": 2, "medium": 3, "high": 4, "xhigh": 5}
+
+// claudeDynamicBudget stands in for a dynamic ("auto") budget, which Claude cannot express.
+const claudeDynamicBudget = 8192
+
+// Apply runs the first rule matching model on a payload in the given upstream format. It returns
+// the payload and the name of the applied rule, or "" when no rule matched or the format is
+// unknown.
+func Apply(format string, payload []byte, rules []config.ReasoningRule, model string) ([]byte, string) {
+	for i := range rules {
+		rule := &rules[i]
+		if !Matches(rule, model) {
+			continue
+		}
+		var ok bool
+		switch format {
+		case "claude":
+			payload, ok = applyClaude(payload, rule, model)
+		case "openai":
+			payload, ok = applyEffort(payload, "reasoning_effort", rule, model)
+		case "codex", "openai-response":
+			payload, ok = applyEffort(payload, "reasoning.effort", rule, model)
+		case "gemini", "gemini-cli", "antigravity":
+			root := ""
+			if gjson.GetBytes(payload, "request").IsObject() {
+				root = "request."
+			}
+			payload, ok = applyGemini(payload, root, rule, model)
+		}
+		if !ok {
+			return payload, ""
+		}
+		return payload, ruleName(rule, i)
+	}
+	return payload, ""
+}
+
+// Matches reports whether rule applies to the upstream model.
+func Matches(rule *config.ReasoningRule, model string) bool {
+	if len(rule.Models) == 0 {
+		return true
+	}
+	for _, pattern := range rule.Models {
+		if util.MatchModelWildcard(strings.TrimSpace(pattern), model) {
+			return true
+		}
+	}
+	return false
+}
+
+func ruleName(rule *config.ReasoningRule, index int) string {
+	if name := strings.TrimSpace(rule.Name); name != "" {
+		return name
+	}
+	return "rule-" + strconv.Itoa(index)
+}
+
+// clampBudget bounds an enabled budget by the rule and then by the model's registered range.
+func clampBudget(rule *config.ReasoningRule, model string, budget int) int {
+	if budget <= 0 {
+		return budget
+	}
+	if rule.MinBudget > 0 && budget < rule.MinBudget {
+		budget = rule.MinBudget
+	}
+	if rule.MaxBudget > 0 && budget > rule.MaxBudget {
+		budget = rule.MaxBudget
+	}
+	return util.NormalizeThinkingBudget(model, budget)
+}
+
+// defaultBudget converts the rule's default effort to a budget. ok is false without a default.
+func defaultBudget(rule *config.ReasoningRule, model string) (int, bool) {
+	effort := strings.ToLower(strings.TrimSpace(rule.DefaultEffort))
+	if effort == "" {
+		return 0, false
+	}
+	return util.ThinkingEffortToBudget(model, effort)
+}
+
+func applyClaude(payload []byte, rule *config.ReasoningRule, model string) ([]byte, bool) {
+	thinking := gjson.GetBytes(payload, "thinking")
+	if !thinking.Exists() {
+		budget, ok := defaultBudget(rule, model)
+		if !ok {
+			return payload, false
+		}
+		if budget == 0 {
+			updated, _ := sjson.SetBytes(payload, "thinking.type", "disabled")
+			return updated, true
+		}
+		if budget < 0 {
+			budget = claudeDynamicBudget
+		}
+		updated, _ := sjson.SetBytes(payload, "thinking.type", "enabled")
+		updated, _ = sjson.SetBytes(updated, "thinking.budget_tokens", clampBudget(rule, model, budget))
+		return updated, true
+	}
+	if thinking.Get("type").String() != "enabled" || !thinking.Get("budget_tokens").Exists() {
+		return payload, false
+	}
+	budget := int(thinking.Get("budget_tokens").Int())
+	clamped := clampBudget(rule, model, budget)
+	if clamped == budget {
+		return payload, false
+	}
+	updated, _ := sjson.SetBytes(payload, "thinking.budget_tokens", clamped)
+	return updated, true
+}
+
+func applyGemini(payload []byte, root string, rule *config.ReasoningRule, model string) ([]byte, bool) {
+	configPath := root + "generationConfig.thinkingConfig"
+	thinkingConfig := gjson.GetBytes(payload, configPath)
+	if !thinkingConfig.Exists() {
+		budget, ok := defaultBudget(rule, model)
+		if !ok {
+			return payload, false
+		}
+		if util.IsGemini3Model(model) {
+			level, valid := util.ValidateGemini3ThinkingLevel(model, rule.DefaultEffort)
+			if !valid {
+				level, valid = util.ThinkingBudgetToGemini3Level(model, budget)
+			}
+			if !valid {
+				return payload, false
+			}
+			updated, _ := sjson.SetBytes(payload, configPath+".thinkingLevel", level)
+			updated, _ = sjson.SetBytes(updated, configPath+".includeThoughts", true)
+			return updated, true
+		}
+		updated, _ := sjson.SetBytes(payload, configPath+".thinkingBudget", clampBudget(rule, model, budget))
+		if budget != 0 {
+			updated, _ = sjson.SetBytes(updated, configPath+".include_thoughts", true)
+		}
+		return updated, true
+	}
+	value := thinkingConfig.Get("thinkingBudget")
+	if !value.Exists() {
+		return payload, false
+	}
+	budget := int(value.Int())
+	clamped := clampBudget(rule, model, budget)
+	if clamped == budget {
+		return payload, false
+	}
+	updated, _ := sjson.SetBytes(payload, configPath+".thinkingBudget", clamped)
+	return updated, true
+}
+
+func applyEffort(payload []byte, path string, rule *config.ReasoningRule, model string) ([]byte, bool) {
+	value := gjson.GetBytes(payload, path)
+	if !value.Exists() {
+		effort := strings.ToLower(strings.TrimSpace(rule.DefaultEffort))
+		if _, known := effortRank[effort]; !known {
+			return payload, false
+		}
+		updated, _ := sjson.SetBytes(payload, path, clampEffort(rule, model, effort))
+		return updated, true
+	}
+	effort := strings.ToLower(strings.TrimSpace(value.String()))
+	clamped := clampEffort(rule, model, effort)
+	if clamped == effort {
+		return payload, false
+	}
+	updated, _ := sjson.SetBytes(payload, path, clamped)
+	return updated, true
+}
+
+// clampEffort bounds an effort level by the levels the rule's budget limits map to. "none" and
+// unknown levels are left alone.
+func clampEffort(rule *config.ReasoningRule, model, effort string) string {
+	rank, known := effortRank[effort]
+	if !known || effort == "none" {
+		return effort
+	}
+	if rule.MinBudget > 0 {
+		if floor, ok := util.ThinkingBudgetToEffort(model, rule.MinBudget); ok {
+			if floorRank, known := effortRank[floor]; known && floorRank > rank {
+				effort, rank = floor, floorRank
+			}
+		}
+	}
+	if rule.MaxBudget > 0 {
+		if ceiling, ok := util.ThinkingBudgetToEffort(model, rule.MaxBudget); ok {
+			if ceilingRank, known := effortRank[ceiling]; known && ceilingRank < rank {
+				effort = ceiling
+			}
+		}
+	}
+	return effort
+}
@@ -0,0 +1,74 @@
+package reasoning
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyDefaultsPerFormat(t *testing.T) {
+	rules := []config.ReasoningRule{
+		{Name: "other", Models: []string{"gpt-*"}, DefaultEffort: "high"},
+		{Name: "test", Models: []string{"test-*"}, DefaultEffort: "low"},
+	}
+	cases := []struct {
+		format string
+		body   string
+		path   string
+		want   string
+	}{
+		{"claude", `{"model":"test-model"}`, "thinking.budget_tokens", "1024"},
+		{"claude", `{"model":"test-model"}`, "thinking.type", "enabled"},
+		{"gemini", `{"contents":[]}`, "generationConfig.thinkingConfig.thinkingBudget", "1024"},
+		{"gemini-cli", `{"request":{"contents":[]}}`, "request.generationConfig.thinkingConfig.thinkingBudget", "1024"},
+		{"openai", `{"messages":[]}`, "reasoning_effort", "low"},
+		{"codex", `{"input":[]}`, "reasoning.effort", "low"},
+	}
+	for _, tc := range cases {
+		out, applied := Apply(tc.format, []byte(tc.body), rules, "test-model")
+		if applied != "test" {
+			t.Fatalf("%s: applied = %q", tc.format, applied)
+		}
+		if got := gjson.GetBytes(out, tc.path).String(); got != tc.want {
+			t.Errorf("%s: %s = %q, want %q", tc.format, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestApplyKeepsClientSettingsAndClamps(t *testing.T) {
+	rules := []config.ReasoningRule{{DefaultEffort: "high", MinBudget: 2048, MaxBudget: 8000}}
+
+	out, applied := Apply("claude", []byte(`{"thinking":{"type":"enabled","budget_tokens":20000}}`), rules, "test-model")
+	if applied == "" || gjson.GetBytes(out, "thinking.budget_tokens").Int() != 8000 {
+		t.Fatalf("claude budget not clamped: %s", out)
+	}
+	out, _ = Apply("gemini", []byte(`{"generationConfig":{"thinkingConfig":{"thinkingBudget":100}}}`), rules, "test-model")
+	if gjson.GetBytes(out, "generationConfig.thinkingConfig.thinkingBudget").Int() != 2048 {
+		t.Fatalf("gemini budget not raised: %s", out)
+	}
+	out, _ = Apply("gemini", []byte(`{"generationConfig":{"thinkingConfig":{"thinkingBudget":0}}}`), rules, "test-model")
+	if gjson.GetBytes(out, "generationConfig.thinkingConfig.thinkingBudget").Int() != 0 {
+		t.Fatalf("disabled thinking was changed: %s", out)
+	}
+	out, _ = Apply("openai", []byte(`{"reasoning_effort":"xhigh"}`), rules, "test-model")
+	if got := gjson.GetBytes(out, "reasoning_effort").String(); got != "medium" {
+		t.Fatalf("effort = %q, want medium", got)
+	}
+	out, applied = Apply("openai", []byte(`{"reasoning_effort":"minimal"}`), rules, "test-model")
+	if got := gjson.GetBytes(out, "reasoning_effort").String(); got != "medium" || applied == "" {
+		t.Fatalf("effort = %q, want medium", got)
+	}
+	if _, applied = Apply("openai", []byte(`{"reasoning_effort":"none"}`), rules, "test-model"); applied != "" {
+		t.Fatal("none should be left alone")
+	}
+}
+
+func TestApplySkipsUnmatchedModels(t *testing.T) {
+	rules := []config.ReasoningRule{{Models: []string{"claude-*"}, DefaultEffort: "low"}}
+	body := []byte(`{"messages":[]}`)
+	out, applied := Apply("openai", body, rules, "gpt-5")
+	if applied != "" || string(out) != string(body) {
+		t.Fatalf("unexpected change: %s", out)
+	}
+}
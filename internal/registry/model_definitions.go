@@ -192,6 +192,58 @@ func GetGeminiModels() []*ModelInfo {
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true, Levels: []string{"low", "high"}},
 		},
+		{
+			ID:                         "imagen-4.0-generate-001",
+			Object:                     "model",
+			Created:                    1747094400,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/imagen-4.0-generate-001",
+			Version:                    "4.0",
+			DisplayName:                "Imagen 4",
+			Description:                "Imagen 4 text-to-image model, served through /v1/images/generations.",
+			SupportedGenerationMethods: []string{"predict"},
+		},
+		{
+			ID:                         "imagen-4.0-ultra-generate-001",
+			Object:                     "model",
+			Created:                    1747094400,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/imagen-4.0-ultra-generate-001",
+			Version:                    "4.0",
+			DisplayName:                "Imagen 4 Ultra",
+			Description:                "Imagen 4 Ultra text-to-image model, served through /v1/images/generations.",
+			SupportedGenerationMethods: []string{"predict"},
+		},
+		{
+			ID:                         "gemini-2.5-flash-preview-tts",
+			Object:                     "model",
+			Created:                    1747094400,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-2.5-flash-preview-tts",
+			Version:                    "2.5",
+			DisplayName:                "Gemini 2.5 Flash Preview TTS",
+			Description:                "Gemini 2.5 Flash text-to-speech model, served through /v1/audio/speech.",
+			InputTokenLimit:            8192,
+			OutputTokenLimit:           16384,
+			SupportedGenerationMethods: []string{"generateContent"},
+		},
+		{
+			ID:                         "gemini-2.5-pro-preview-tts",
+			Object:                     "model",
+			Created:                    1747094400,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-2.5-pro-preview-tts",
+			Version:                    "2.5",
+			DisplayName:                "Gemini 2.5 Pro Preview TTS",
+			Description:                "Gemini 2.5 Pro text-to-speech model, served through /v1/audio/speech.",
+			InputTokenLimit:            8192,
+			OutputTokenLimit:           16384,
+			SupportedGenerationMethods: []string{"generateContent"},
+		},
 	}
 }
 
@@ -287,6 +339,58 @@ func GetGeminiVertexModels() []*ModelInfo {
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true, Levels: []string{"low", "high"}},
 		},
+		{
+			ID:                         "imagen-4.0-generate-001",
+			Object:                     "model",
+			Created:                    1747094400,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/imagen-4.0-generate-001",
+			Version:                    "4.0",
+			DisplayName:                "Imagen 4",
+			Description:                "Imagen 4 text-to-image model, served through /v1/images/generations.",
+			SupportedGenerationMethods: []string{"predict"},
+		},
+		{
+			ID:                         "imagen-4.0-ultra-generate-001",
+			Object:                     "model",
+			Created:                    1747094400,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/imagen-4.0-ultra-generate-001",
+			Version:                    "4.0",
+			DisplayName:                "Imagen 4 Ultra",
+			Description:                "Imagen 4 Ultra text-to-image model, served through /v1/images/generations.",
+			SupportedGenerationMethods: []string{"predict"},
+		},
+		{
+			ID:                         "gemini-2.5-flash-preview-tts",
+			Object:                     "model",
+			Created:                    1747094400,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-2.5-flash-preview-tts",
+			Version:                    "2.5",
+			DisplayName:                "Gemini 2.5 Flash Preview TTS",
+			Description:                "Gemini 2.5 Flash text-to-speech model, served through /v1/audio/speech.",
+			InputTokenLimit:            8192,
+			OutputTokenLimit:           16384,
+			SupportedGenerationMethods: []string{"generateContent"},
+		},
+		{
+			ID:                         "gemini-2.5-pro-preview-tts",
+			Object:                     "model",
+			Created:                    1747094400,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-2.5-pro-preview-tts",
+			Version:                    "2.5",
+			DisplayName:                "Gemini 2.5 Pro Preview TTS",
+			Description:                "Gemini 2.5 Pro text-to-speech model, served through /v1/audio/speech.",
+			InputTokenLimit:            8192,
+			OutputTokenLimit:           16384,
+			SupportedGenerationMethods: []string{"generateContent"},
+		},
 	}
 }
 
@@ -0,0 +1,363 @@
+// Package remoteconfig mirrors a centrally managed configuration file from an HTTP(S) URL or an
+// S3/GCS object into the local config path. Changes are detected with ETags, optionally verified
+// against a detached Ed25519 signature, and written in place so the regular config watcher
+// reloads them.
+package remoteconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultInterval is the polling interval used when none is configured.
+	DefaultInterval = 30 * time.Second
+	// maxConfigSize bounds the size of a fetched config or signature.
+	maxConfigSize = 8 << 20
+
+	gcsEndpoint = "storage.googleapis.com"
+	s3Endpoint  = "s3.amazonaws.com"
+)
+
+// errNotModified reports that the remote object still matches the last seen ETag.
+var errNotModified = errors.New("remote config not modified")
+
+// Options describes the remote configuration source.
+type Options struct {
+	// URL locates the config: http(s)://host/path, s3://bucket/key or gs://bucket/key.
+	URL string
+	// SignatureURL locates the detached signature. Defaults to URL + ".sig" when PublicKey is set.
+	SignatureURL string
+	// PublicKey enables signature verification. Unsigned or mis-signed configs are rejected.
+	PublicKey ed25519.PublicKey
+	// Interval controls how often the source is polled. Zero uses DefaultInterval.
+	Interval time.Duration
+	// Header holds extra headers (e.g. Authorization) sent with HTTP(S) requests.
+	Header http.Header
+	// HTTPClient overrides the client used for HTTP(S) sources.
+	HTTPClient *http.Client
+
+	// Endpoint overrides the S3-compatible endpoint for s3:// URLs (default s3.amazonaws.com).
+	// gs:// URLs use the GCS interoperability endpoint with HMAC keys.
+	Endpoint string
+	// AccessKey and SecretKey authenticate object store requests. When empty, credentials are
+	// read from the standard AWS environment variables and shared files.
+	AccessKey string
+	SecretKey string
+	Region    string
+}
+
+type fetcher interface {
+	// fetch returns the object body and its ETag, or errNotModified when etag still matches.
+	fetch(ctx context.Context, etag string) ([]byte, string, error)
+}
+
+// Syncer keeps a local config file in sync with a remote source.
+type Syncer struct {
+	opts      Options
+	localPath string
+	config    fetcher
+	signature fetcher
+	etag      string
+	digest    [32]byte
+}
+
+// New builds a syncer writing the remote config to localPath.
+func New(opts Options, localPath string) (*Syncer, error) {
+	if strings.TrimSpace(localPath) == "" {
+		return nil, fmt.Errorf("remote config: local path is required")
+	}
+	cfgFetcher, err := newFetcher(opts, opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	s := &Syncer{opts: opts, localPath: localPath, config: cfgFetcher}
+	if len(opts.PublicKey) > 0 {
+		if len(opts.PublicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("remote config: invalid ed25519 public key size %d", len(opts.PublicKey))
+		}
+		sigURL := strings.TrimSpace(opts.SignatureURL)
+		if sigURL == "" {
+			sigURL = strings.TrimSpace(opts.URL) + ".sig"
+		}
+		if s.signature, err = newFetcher(opts, sigURL); err != nil {
+			return nil, err
+		}
+	}
+	if existing, errRead := os.ReadFile(localPath); errRead == nil {
+		s.digest = sha256.Sum256(existing)
+	}
+	return s, nil
+}
+
+// Sync fetches the remote config once. It reports whether the local file was updated.
+func (s *Syncer) Sync(ctx context.Context) (bool, error) {
+	data, etag, err := s.config.fetch(ctx, s.etag)
+	if errors.Is(err, errNotModified) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if s.signature != nil {
+		sig, _, errSig := s.signature.fetch(ctx, "")
+		if errSig != nil {
+			return false, fmt.Errorf("remote config: fetch signature: %w", errSig)
+		}
+		if errVerify := verify(s.opts.PublicKey, data, sig); errVerify != nil {
+			return false, errVerify
+		}
+	}
+	var probe config.Config
+	if err = yaml.Unmarshal(data, &probe); err != nil {
+		return false, fmt.Errorf("remote config: invalid YAML: %w", err)
+	}
+	s.etag = etag
+	digest := sha256.Sum256(data)
+	if digest == s.digest {
+		return false, nil
+	}
+	if err = writeFile(s.localPath, data); err != nil {
+		return false, fmt.Errorf("remote config: write %s: %w", s.localPath, err)
+	}
+	s.digest = digest
+	return true, nil
+}
+
+// Run polls the source until ctx is canceled. Failures keep the last good local copy.
+func (s *Syncer) Run(ctx context.Context) {
+	interval := s.opts.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.Sync(ctx)
+			if err != nil {
+				log.Warnf("remote config sync failed, keeping current config: %v", err)
+				continue
+			}
+			if changed {
+				log.Infof("remote config updated from %s", redactURL(s.opts.URL))
+			}
+		}
+	}
+}
+
+// ParsePublicKey decodes an Ed25519 public key given as base64, hex or a PEM "PUBLIC KEY" block.
+func ParsePublicKey(value string) (ed25519.PublicKey, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if block, _ := pem.Decode([]byte(value)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("remote config: parse public key: %w", err)
+		}
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("remote config: public key is not ed25519")
+		}
+		return edKey, nil
+	}
+	if raw, err := hex.DecodeString(value); err == nil && len(raw) == ed25519.PublicKeySize {
+		return raw, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("remote config: public key must be a 32-byte ed25519 key in base64, hex or PEM")
+	}
+	return raw, nil
+}
+
+// verify checks a detached signature given as raw bytes or base64 text.
+func verify(key ed25519.PublicKey, data, sig []byte) error {
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+		if err != nil {
+			return fmt.Errorf("remote config: malformed signature")
+		}
+		sig = decoded
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("remote config: signature verification failed")
+	}
+	return nil
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write in place rather than renaming so the config watcher keeps its file watch.
+	return os.WriteFile(path, data, 0o644)
+}
+
+func newFetcher(opts Options, rawURL string) (fetcher, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("remote config: invalid URL: %w", err)
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https":
+		client := opts.HTTPClient
+		if client == nil {
+			client = &http.Client{Timeout: 30 * time.Second}
+		}
+		return &httpFetcher{client: client, url: parsed.String(), header: opts.Header}, nil
+	case "s3", "gs":
+		return newObjectFetcher(opts, parsed)
+	}
+	return nil, fmt.Errorf("remote config: unsupported URL scheme %q (use http, https, s3 or gs)", parsed.Scheme)
+}
+
+type httpFetcher struct {
+	client *http.Client
+	url    string
+	header http.Header
+}
+
+func (f *httpFetcher) fetch(ctx context.Context, etag string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for key, values := range f.header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Debugf("remote config: close response body: %v", errClose)
+		}
+	}()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, errNotModified
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("remote config: GET %s: status %d", redactURL(f.url), resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxConfigSize {
+		return nil, "", fmt.Errorf("remote config: %s exceeds %d bytes", redactURL(f.url), maxConfigSize)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+type objectFetcher struct {
+	client *minio.Client
+	bucket string
+	key    string
+}
+
+func newObjectFetcher(opts Options, parsed *url.URL) (*objectFetcher, error) {
+	bucket := parsed.Host
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("remote config: object URL must look like %s://bucket/key", parsed.Scheme)
+	}
+	endpoint := strings.TrimSpace(opts.Endpoint)
+	if endpoint == "" {
+		endpoint = s3Endpoint
+		if parsed.Scheme == "gs" {
+			endpoint = gcsEndpoint
+		}
+	}
+	useSSL := true
+	if strings.Contains(endpoint, "://") {
+		ep, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("remote config: invalid endpoint: %w", err)
+		}
+		useSSL = !strings.EqualFold(ep.Scheme, "http")
+		endpoint = ep.Host
+	}
+	creds := credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, "")
+	if opts.AccessKey == "" && opts.SecretKey == "" {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+		})
+	}
+	client, err := minio.New(endpoint, &minio.Options{Creds: creds, Secure: useSSL, Region: opts.Region})
+	if err != nil {
+		return nil, fmt.Errorf("remote config: create object store client: %w", err)
+	}
+	return &objectFetcher{client: client, bucket: bucket, key: key}, nil
+}
+
+func (f *objectFetcher) fetch(ctx context.Context, etag string) ([]byte, string, error) {
+	info, err := f.client.StatObject(ctx, f.bucket, f.key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("remote config: stat %s/%s: %w", f.bucket, f.key, err)
+	}
+	if etag != "" && info.ETag == etag {
+		return nil, etag, errNotModified
+	}
+	if info.Size > maxConfigSize {
+		return nil, "", fmt.Errorf("remote config: %s/%s exceeds %d bytes", f.bucket, f.key, maxConfigSize)
+	}
+	obj, err := f.client.GetObject(ctx, f.bucket, f.key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("remote config: get %s/%s: %w", f.bucket, f.key, err)
+	}
+	defer func() {
+		if errClose := obj.Close(); errClose != nil {
+			log.Debugf("remote config: close object: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(io.LimitReader(obj, maxConfigSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("remote config: read %s/%s: %w", f.bucket, f.key, err)
+	}
+	return data, info.ETag, nil
+}
+
+// redactURL strips credentials and query strings (e.g. presigned tokens) for logging.
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid url>"
+	}
+	parsed.User = nil
+	parsed.RawQuery = ""
+	return parsed.String()
+}
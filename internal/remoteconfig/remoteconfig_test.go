@@ -0,0 +1,100 @@
+package remoteconfig
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSyncerFetchesVerifiesAndHonoursETag(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu      sync.Mutex
+		body    = []byte("port: 8317\n")
+		etag    = `"v1"`
+		badSig  bool
+		fetches int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/config.yaml":
+			fetches++
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			_, _ = w.Write(body)
+		case "/config.yaml.sig":
+			sig := ed25519.Sign(priv, body)
+			if badSig {
+				sig = ed25519.Sign(priv, []byte("tampered"))
+			}
+			_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString(sig)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	local := filepath.Join(t.TempDir(), "config.yaml")
+	s, err := New(Options{URL: srv.URL + "/config.yaml", PublicKey: pub}, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	changed, err := s.Sync(ctx)
+	if err != nil || !changed {
+		t.Fatalf("first sync: changed=%v err=%v", changed, err)
+	}
+	if data, _ := os.ReadFile(local); string(data) != "port: 8317\n" {
+		t.Fatalf("local copy = %q", data)
+	}
+
+	if changed, err = s.Sync(ctx); err != nil || changed {
+		t.Fatalf("unchanged ETag must not rewrite: changed=%v err=%v", changed, err)
+	}
+
+	mu.Lock()
+	body, etag, badSig = []byte("port: 9000\n"), `"v2"`, true
+	mu.Unlock()
+	if _, err = s.Sync(ctx); err == nil {
+		t.Fatal("expected tampered config to be rejected")
+	}
+	if data, _ := os.ReadFile(local); string(data) != "port: 8317\n" {
+		t.Fatalf("rejected config must not replace the local copy, got %q", data)
+	}
+
+	mu.Lock()
+	badSig = false
+	mu.Unlock()
+	if changed, err = s.Sync(ctx); err != nil || !changed {
+		t.Fatalf("signed update: changed=%v err=%v", changed, err)
+	}
+	if data, _ := os.ReadFile(local); string(data) != "port: 9000\n" {
+		t.Fatalf("local copy after update = %q", data)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	key, err := ParsePublicKey(base64.StdEncoding.EncodeToString(pub))
+	if err != nil || !key.Equal(pub) {
+		t.Fatalf("base64 key: %v", err)
+	}
+	if _, err = ParsePublicKey("not-a-key"); err == nil {
+		t.Fatal("expected invalid key error")
+	}
+}
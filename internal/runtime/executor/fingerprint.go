@@ -0,0 +1,213 @@
+package executor
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// fingerprintTransports caches TLS-shaped clones of the pooled transports, keyed by base
+// transport and profile settings.
+var fingerprintTransports sync.Map // map[fingerprintTransportKey]*http.Transport
+
+type fingerprintTransportKey struct {
+	base *http.Transport
+	tls  string
+}
+
+// fingerprintCurves are the key exchange groups a profile may select.
+var fingerprintCurves = []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// fingerprintRoundTripper applies a fingerprint profile to every request of a provider before
+// handing it to the base round tripper.
+type fingerprintRoundTripper struct {
+	base    http.RoundTripper
+	profile *config.FingerprintConfig
+	// pinned lists the headers set on the credential, which the profile leaves alone.
+	pinned map[string]bool
+}
+
+// withFingerprint applies the fingerprint profile matching the credential's provider to every
+// request of httpClient.
+func withFingerprint(cfg *config.Config, auth *cliproxyauth.Auth, httpClient *http.Client) *http.Client {
+	if auth == nil {
+		return httpClient
+	}
+	profile := matchFingerprint(cfg, auth.Provider)
+	if profile == nil {
+		return httpClient
+	}
+	pinned := make(map[string]bool)
+	for key, value := range auth.Attributes {
+		if name, ok := strings.CutPrefix(key, "header:"); ok && strings.TrimSpace(value) != "" {
+			pinned[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+	httpClient.Transport = &fingerprintRoundTripper{base: httpClient.Transport, profile: profile, pinned: pinned}
+	return httpClient
+}
+
+// matchFingerprint returns the first profile listing provider.
+func matchFingerprint(cfg *config.Config, provider string) *config.FingerprintConfig {
+	if cfg == nil || len(cfg.Fingerprints) == 0 {
+		return nil
+	}
+	provider = strings.TrimSpace(provider)
+	for i := range cfg.Fingerprints {
+		for _, p := range cfg.Fingerprints[i].Providers {
+			if strings.EqualFold(strings.TrimSpace(p), provider) {
+				return &cfg.Fingerprints[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (t *fingerprintRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	t.applyHeaders(req.Header)
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.URL != nil && req.URL.Scheme == "https" && fingerprintShapesTLS(&t.profile.TLS) {
+		if transport := t.tlsTransport(base, strings.ToLower(req.URL.Hostname())); transport != nil {
+			return transport.RoundTrip(req)
+		}
+	}
+	return base.RoundTrip(req)
+}
+
+func (t *fingerprintRoundTripper) applyHeaders(header http.Header) {
+	if userAgent := strings.TrimSpace(t.profile.UserAgent); userAgent != "" && !t.pinned["User-Agent"] {
+		header.Set("User-Agent", userAgent)
+	}
+	for name, value := range t.profile.Headers {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name == "" || t.pinned[name] {
+			continue
+		}
+		if value = strings.TrimSpace(value); value == "" {
+			header.Del(name)
+			continue
+		}
+		header.Set(name, value)
+	}
+}
+
+// tlsTransport returns the TLS-shaped clone of the pooled transport for host, or nil when the
+// base is not a pooled transport or host presents an upstream-tls client certificate, whose
+// settings take precedence.
+func (t *fingerprintRoundTripper) tlsTransport(base http.RoundTripper, host string) *http.Transport {
+	if hostProxies, ok := base.(*hostProxyRoundTripper); ok {
+		base = hostProxies.roundTripperFor(host)
+	}
+	if mutual, ok := base.(*upstreamTLSRoundTripper); ok {
+		for i := range mutual.entries {
+			if upstreamTLSHostMatches(mutual.entries[i].Hosts, host) {
+				return nil
+			}
+		}
+		base = mutual.base
+	}
+	var pooled *http.Transport
+	switch b := base.(type) {
+	case *upstreamPoolRoundTripper:
+		pooled = b.transportForHost(host)
+	case *http.Transport:
+		pooled = b
+	default:
+		log.Debugf("fingerprint %q: TLS settings skipped for a custom round tripper", t.profile.Name)
+		return nil
+	}
+
+	settings := &t.profile.TLS
+	key := fingerprintTransportKey{base: pooled, tls: fmt.Sprintf("%s/%s/%v/%v/%t", settings.MinVersion, settings.MaxVersion, settings.CipherSuites, settings.Curves, settings.DisableHTTP2)}
+	if cached, ok := fingerprintTransports.Load(key); ok {
+		return cached.(*http.Transport)
+	}
+	transport := pooled.Clone()
+	tlsConfig := transport.TLSClientConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	applyFingerprintTLS(t.profile, tlsConfig)
+	transport.TLSClientConfig = tlsConfig
+	if settings.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		tlsConfig.NextProtos = []string{"http/1.1"}
+	}
+	actual, _ := fingerprintTransports.LoadOrStore(key, transport)
+	return actual.(*http.Transport)
+}
+
+func fingerprintShapesTLS(settings *config.FingerprintTLSConfig) bool {
+	return settings.MinVersion != "" || settings.MaxVersion != "" || len(settings.CipherSuites) > 0 || len(settings.Curves) > 0 || settings.DisableHTTP2
+}
+
+// applyFingerprintTLS copies the profile's TLS settings into tlsConfig. Unknown names are
+// logged and skipped.
+func applyFingerprintTLS(profile *config.FingerprintConfig, tlsConfig *tls.Config) {
+	settings := &profile.TLS
+	if version, ok := parseTLSVersion(settings.MinVersion); ok {
+		tlsConfig.MinVersion = version
+	} else if settings.MinVersion != "" {
+		log.Warnf("fingerprint %q: unknown min-version %q", profile.Name, settings.MinVersion)
+	}
+	if version, ok := parseTLSVersion(settings.MaxVersion); ok {
+		tlsConfig.MaxVersion = version
+	} else if settings.MaxVersion != "" {
+		log.Warnf("fingerprint %q: unknown max-version %q", profile.Name, settings.MaxVersion)
+	}
+
+	if len(settings.CipherSuites) > 0 {
+		suites := make(map[string]uint16)
+		for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			suites[suite.Name] = suite.ID
+		}
+		tlsConfig.CipherSuites = nil
+		for _, name := range settings.CipherSuites {
+			if id, ok := suites[strings.ToUpper(strings.TrimSpace(name))]; ok {
+				tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+				continue
+			}
+			log.Warnf("fingerprint %q: unknown cipher suite %q", profile.Name, name)
+		}
+	}
+
+	if len(settings.Curves) > 0 {
+		tlsConfig.CurvePreferences = nil
+		for _, name := range settings.Curves {
+			name = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "CURVE")
+			found := false
+			for _, curve := range fingerprintCurves {
+				if strings.TrimPrefix(strings.ToUpper(curve.String()), "CURVE") == name {
+					tlsConfig.CurvePreferences = append(tlsConfig.CurvePreferences, curve)
+					found = true
+					break
+				}
+			}
+			if !found {
+				log.Warnf("fingerprint %q: unknown curve %q", profile.Name, name)
+			}
+		}
+	}
+}
+
+func parseTLSVersion(version string) (uint16, bool) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "tls") {
+	case "1.2":
+		return tls.VersionTLS12, true
+	case "1.3":
+		return tls.VersionTLS13, true
+	}
+	return 0, false
+}
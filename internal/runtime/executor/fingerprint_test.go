@@ -0,0 +1,137 @@
+package executor
+
+import (
+	"crypto/tls"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+type captureRoundTripper struct {
+	header http.Header
+}
+
+func (c *captureRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.header = req.Header.Clone()
+	return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: req}, nil
+}
+
+func TestMatchFingerprint(t *testing.T) {
+	cfg := &config.Config{Fingerprints: []config.FingerprintConfig{
+		{Name: "cli", Providers: []string{"Claude", "codex"}},
+		{Name: "other", Providers: []string{"claude"}},
+	}}
+	if profile := matchFingerprint(cfg, "claude"); profile == nil || profile.Name != "cli" {
+		t.Fatalf("expected the first listing profile, got %+v", profile)
+	}
+	if matchFingerprint(cfg, "gemini") != nil || matchFingerprint(nil, "claude") != nil {
+		t.Fatal("expected no profile")
+	}
+}
+
+func TestFingerprintHeadersRespectCredentialOverrides(t *testing.T) {
+	cfg := &config.Config{Fingerprints: []config.FingerprintConfig{{
+		Name:      "cli",
+		Providers: []string{"claude"},
+		UserAgent: "claude-cli/2.0.0 (external, cli)",
+		Headers:   map[string]string{"x-app": "cli", "X-Stainless-Os": "", "anthropic-beta": "profile-beta"},
+	}}}
+	auth := &cliproxyauth.Auth{Provider: "claude", Attributes: map[string]string{"header:Anthropic-Beta": "credential-beta"}}
+	capture := &captureRoundTripper{}
+	client := withFingerprint(cfg, auth, &http.Client{Transport: capture})
+
+	req, _ := http.NewRequest(http.MethodPost, "http://api.example.com/v1/messages", nil)
+	req.Header.Set("User-Agent", "Go-http-client/1.1")
+	req.Header.Set("X-Stainless-Os", "Linux")
+	req.Header.Set("Anthropic-Beta", "credential-beta")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if got := capture.header.Get("User-Agent"); got != "claude-cli/2.0.0 (external, cli)" {
+		t.Fatalf("User-Agent = %q", got)
+	}
+	if got := capture.header.Get("X-App"); got != "cli" {
+		t.Fatalf("X-App = %q", got)
+	}
+	if _, ok := capture.header["X-Stainless-Os"]; ok {
+		t.Fatal("an empty profile value must remove the header")
+	}
+	if got := capture.header.Get("Anthropic-Beta"); got != "credential-beta" {
+		t.Fatalf("credential headers must win, got %q", got)
+	}
+	if req.Header.Get("User-Agent") != "Go-http-client/1.1" {
+		t.Fatal("the caller's request must not be modified")
+	}
+
+	if withFingerprint(cfg, &cliproxyauth.Auth{Provider: "codex"}, &http.Client{Transport: capture}).Transport != capture {
+		t.Fatal("providers without a profile must keep their transport")
+	}
+}
+
+func TestApplyFingerprintTLS(t *testing.T) {
+	profile := &config.FingerprintConfig{Name: "chrome", TLS: config.FingerprintTLSConfig{
+		MinVersion:   "1.2",
+		MaxVersion:   "TLS1.3",
+		CipherSuites: []string{"tls_ecdhe_ecdsa_with_aes_128_gcm_sha256", "TLS_NOT_A_SUITE", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"},
+		Curves:       []string{"X25519MLKEM768", "x25519", "CurveP256", "P999"},
+	}}
+	tlsConfig := &tls.Config{}
+	applyFingerprintTLS(profile, tlsConfig)
+	if tlsConfig.MinVersion != tls.VersionTLS12 || tlsConfig.MaxVersion != tls.VersionTLS13 {
+		t.Fatalf("versions = %x-%x", tlsConfig.MinVersion, tlsConfig.MaxVersion)
+	}
+	wantSuites := []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}
+	if !reflect.DeepEqual(tlsConfig.CipherSuites, wantSuites) {
+		t.Fatalf("cipher suites = %v, want %v", tlsConfig.CipherSuites, wantSuites)
+	}
+	wantCurves := []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256}
+	if !reflect.DeepEqual(tlsConfig.CurvePreferences, wantCurves) {
+		t.Fatalf("curves = %v, want %v", tlsConfig.CurvePreferences, wantCurves)
+	}
+
+	invalid := &config.FingerprintConfig{Name: "bad", TLS: config.FingerprintTLSConfig{
+		MinVersion:   "1.0",
+		MaxVersion:   "ssl3",
+		CipherSuites: []string{"NOPE"},
+		Curves:       []string{"P999"},
+	}}
+	tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	applyFingerprintTLS(invalid, tlsConfig)
+	if tlsConfig.MinVersion != tls.VersionTLS12 || tlsConfig.MaxVersion != 0 {
+		t.Fatalf("invalid versions must be rejected, got %x-%x", tlsConfig.MinVersion, tlsConfig.MaxVersion)
+	}
+	if len(tlsConfig.CipherSuites) != 0 || len(tlsConfig.CurvePreferences) != 0 {
+		t.Fatalf("invalid names must be rejected, got %v %v", tlsConfig.CipherSuites, tlsConfig.CurvePreferences)
+	}
+}
+
+func TestFingerprintTLSTransportIsCachedClone(t *testing.T) {
+	t.Cleanup(drainSharedTransports)
+	base := sharedUpstreamTransport(&config.Config{}, "", nil)
+	rt := &fingerprintRoundTripper{profile: &config.FingerprintConfig{Name: "h1", TLS: config.FingerprintTLSConfig{MinVersion: "1.3", DisableHTTP2: true}}}
+	if !fingerprintShapesTLS(&rt.profile.TLS) || fingerprintShapesTLS(&config.FingerprintTLSConfig{}) {
+		t.Fatal("unexpected fingerprintShapesTLS result")
+	}
+	shaped := rt.tlsTransport(base, "api.example.com")
+	if shaped == nil || shaped == base {
+		t.Fatal("expected a shaped clone of the pooled transport")
+	}
+	if shaped.TLSClientConfig.MinVersion != tls.VersionTLS13 || !reflect.DeepEqual(shaped.TLSClientConfig.NextProtos, []string{"http/1.1"}) || shaped.ForceAttemptHTTP2 {
+		t.Fatalf("unexpected TLS config: %+v", shaped.TLSClientConfig)
+	}
+	if base.TLSClientConfig != nil && base.TLSClientConfig.MinVersion == tls.VersionTLS13 {
+		t.Fatal("the pooled transport must not be modified")
+	}
+	if rt.tlsTransport(base, "api.example.com") != shaped {
+		t.Fatal("expected the shaped transport to be reused")
+	}
+	if rt.tlsTransport(&captureRoundTripper{}, "api.example.com") != nil {
+		t.Fatal("custom round trippers must be left alone")
+	}
+}
@@ -1,12 +1,20 @@
 package executor
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/reasoning"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sysprompt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/toolhistory"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/transform"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -104,6 +112,20 @@ func ApplyReasoningEffortMetadata(payload []byte, metadata map[string]any, model
 	return payload
 }
 
+// translateOriginalForPayload translates the client's original request (or the payload when
+// there is none) for payload rules that compare against it. Without payload rules the result
+// is never read, so large requests skip the extra clone and translation.
+func translateOriginalForPayload(cfg *config.Config, from, to sdktranslator.Format, model string, payload, original []byte, stream bool) []byte {
+	if cfg == nil || (len(cfg.Payload.Default) == 0 && len(cfg.Payload.Override) == 0) {
+		return nil
+	}
+	source := payload
+	if len(original) > 0 {
+		source = original
+	}
+	return sdktranslator.TranslateRequest(from, to, model, bytes.Clone(source), stream)
+}
+
 // applyPayloadConfigWithRoot behaves like applyPayloadConfig but treats all parameter
 // paths as relative to the provided root path (for example, "request" for Gemini CLI)
 // and restricts matches to the given protocol when supplied. Defaults are checked
@@ -355,3 +377,70 @@ func ValidateThinkingConfig(payload []byte, model string) error {
 	}
 	return nil
 }
+
+// schemaDowngradeHeader reports which JSON Schema keywords were rewritten or stripped from tool
+// declarations before the request reached a Gemini upstream.
+const schemaDowngradeHeader = "X-CLIProxy-Schema-Downgraded"
+
+// downgradeGeminiToolSchemas rewrites tool schemas into the subset Gemini accepts and records the
+// affected keywords in a debug response header when the response has not started yet.
+func downgradeGeminiToolSchemas(ctx context.Context, payload []byte, root string) []byte {
+	payload, keywords := util.DowngradeGeminiToolSchemas(payload, root)
+	if len(keywords) == 0 {
+		return payload
+	}
+	log.Debugf("downgraded tool schema keywords for gemini: %s", strings.Join(keywords, ", "))
+	if ctx == nil {
+		return payload
+	}
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil && ginCtx.Writer != nil && !ginCtx.Writer.Written() {
+		ginCtx.Header(schemaDowngradeHeader, strings.Join(keywords, ","))
+	}
+	return payload
+}
+
+// applyRequestTransforms prepares a translated upstream payload: it repairs the tool call history,
+// applies the system prompt and reasoning rules, then runs the request transformers configured for
+// the inbound route. format is the upstream translator format of payload.
+func applyRequestTransforms(ctx context.Context, cfg *config.Config, model, format string, payload []byte) []byte {
+	if cfg == nil || !cfg.DisableHistoryRepair {
+		var report toolhistory.Report
+		if payload, report = toolhistory.Repair(format, payload); report.Changed() {
+			log.Debugf("repaired %s tool history for %s: dropped %d orphaned results, stubbed %d unanswered calls", format, model, report.DroppedResults, report.StubbedCalls)
+		}
+	}
+	if cfg != nil && len(cfg.SystemPromptRules) > 0 {
+		apiKey := ""
+		if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+			if v, ok := ginCtx.Get("apiKey"); ok {
+				apiKey, _ = v.(string)
+			}
+		}
+		var applied []string
+		if payload, applied = sysprompt.Apply(format, payload, cfg.SystemPromptRules, model, apiKey); len(applied) > 0 {
+			log.Debugf("applied system prompt rules %s to %s request for %s", strings.Join(applied, ","), format, model)
+		}
+	}
+	if cfg != nil && len(cfg.ReasoningRules) > 0 {
+		var applied string
+		if payload, applied = reasoning.Apply(format, payload, cfg.ReasoningRules, model); applied != "" {
+			log.Debugf("applied reasoning rule %s to %s request for %s", applied, format, model)
+		}
+	}
+	if cfg == nil || !transform.HasStage(cfg.Transforms, transform.StageRequest) {
+		return payload
+	}
+	p := &transform.Payload{
+		Stage:  transform.StageRequest,
+		Model:  model,
+		Format: format,
+		Body:   payload,
+	}
+	if ctx != nil {
+		if ginCtx := ginContextFrom(ctx); ginCtx != nil && ginCtx.Request != nil && ginCtx.Request.URL != nil {
+			p.Route = ginCtx.Request.URL.Path
+		}
+	}
+	transform.Apply(ctx, cfg.Transforms, p)
+	return p.Body
+}
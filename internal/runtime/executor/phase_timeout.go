@@ -0,0 +1,257 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Upstream request phases bounded by upstream-transport.timeouts.
+const (
+	phaseConnect   = "connect"
+	phaseFirstByte = "first_byte"
+	phaseIdle      = "idle"
+	phaseTotal     = "total"
+)
+
+// phaseTimeoutError reports an upstream request that exceeded one of its phase timeouts. It
+// renders as an OpenAI-style error body so clients see which phase expired.
+type phaseTimeoutError struct {
+	phase string
+	limit time.Duration
+}
+
+func (e *phaseTimeoutError) code() string {
+	switch e.phase {
+	case phaseConnect:
+		return "upstream_connect_timeout"
+	case phaseFirstByte:
+		return "upstream_first_byte_timeout"
+	case phaseIdle:
+		return "upstream_idle_timeout"
+	default:
+		return "upstream_duration_exceeded"
+	}
+}
+
+func (e *phaseTimeoutError) message() string {
+	switch e.phase {
+	case phaseConnect:
+		return fmt.Sprintf("upstream connection not established within %s", e.limit)
+	case phaseFirstByte:
+		return fmt.Sprintf("upstream sent no response within %s", e.limit)
+	case phaseIdle:
+		return fmt.Sprintf("upstream sent no data for %s", e.limit)
+	default:
+		return fmt.Sprintf("upstream request exceeded %s", e.limit)
+	}
+}
+
+func (e *phaseTimeoutError) Error() string {
+	payload, _ := json.Marshal(map[string]any{"error": map[string]string{
+		"message": e.message(),
+		"type":    "timeout_error",
+		"code":    e.code(),
+	}})
+	return string(payload)
+}
+
+func (e *phaseTimeoutError) StatusCode() int { return http.StatusGatewayTimeout }
+
+// phaseTimeouts is the effective timeout set for one upstream host.
+type phaseTimeouts struct {
+	connect, firstByte, idle, total time.Duration
+}
+
+func (t phaseTimeouts) enabled() bool {
+	return t.connect > 0 || t.firstByte > 0 || t.idle > 0 || t.total > 0
+}
+
+// resolvePhaseTimeouts merges the upstream-transport timeouts with the entry matching host.
+func resolvePhaseTimeouts(cfg *config.Config, host string) phaseTimeouts {
+	if cfg == nil {
+		return phaseTimeouts{}
+	}
+	base := cfg.UpstreamTransport.Timeouts
+	t := phaseTimeouts{
+		connect:   secondsOr(base.ConnectSeconds, 0),
+		firstByte: secondsOr(base.FirstByteSeconds, 0),
+		idle:      secondsOr(base.IdleSeconds, 0),
+		total:     secondsOr(base.TotalSeconds, 0),
+	}
+	if entry := matchUpstreamPool(cfg, host); entry != nil {
+		t.connect = secondsOr(entry.Timeouts.ConnectSeconds, t.connect)
+		t.firstByte = secondsOr(entry.Timeouts.FirstByteSeconds, t.firstByte)
+		t.idle = secondsOr(entry.Timeouts.IdleSeconds, t.idle)
+		t.total = secondsOr(entry.Timeouts.TotalSeconds, t.total)
+	}
+	return t
+}
+
+func hasPhaseTimeouts(cfg *config.Config) bool {
+	if cfg == nil {
+		return false
+	}
+	if cfg.UpstreamTransport.Timeouts != (config.UpstreamTimeoutConfig{}) {
+		return true
+	}
+	for _, entry := range cfg.UpstreamTransport.Upstreams {
+		if entry.Timeouts != (config.UpstreamTimeoutConfig{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// withPhaseTimeouts enforces upstream-transport.timeouts on the client's requests.
+func withPhaseTimeouts(cfg *config.Config, httpClient *http.Client) *http.Client {
+	if hasPhaseTimeouts(cfg) {
+		httpClient.Transport = &phaseTimeoutRoundTripper{cfg: cfg, base: httpClient.Transport}
+	}
+	return httpClient
+}
+
+type phaseTimeoutRoundTripper struct {
+	cfg  *config.Config
+	base http.RoundTripper
+}
+
+func (rt *phaseTimeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := rt.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	limits := resolvePhaseTimeouts(rt.cfg, strings.ToLower(req.URL.Hostname()))
+	if !limits.enabled() {
+		return base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timers := &phaseTimers{cancel: cancel}
+	if limits.total > 0 {
+		timers.start(phaseTotal, limits.total)
+	}
+	if limits.connect > 0 {
+		timers.start(phaseConnect, limits.connect)
+	}
+	connected := func() {
+		timers.stop(phaseConnect)
+		if limits.firstByte > 0 {
+			timers.start(phaseFirstByte, limits.firstByte)
+		}
+	}
+	if limits.connect <= 0 && limits.firstByte > 0 {
+		// Without a connect bound the first-byte wait covers the whole exchange.
+		timers.start(phaseFirstByte, limits.firstByte)
+	}
+	clientTrace := &httptrace.ClientTrace{GotConn: func(httptrace.GotConnInfo) { connected() }}
+
+	resp, err := base.RoundTrip(req.WithContext(httptrace.WithClientTrace(ctx, clientTrace)))
+	timers.stop(phaseConnect)
+	timers.stop(phaseFirstByte)
+	if err != nil {
+		if cause := phaseTimeoutCause(ctx); cause != nil {
+			err = cause
+		}
+		timers.stopAll()
+		cancel(nil)
+		return resp, err
+	}
+	if limits.idle > 0 {
+		timers.start(phaseIdle, limits.idle)
+	}
+	resp.Body = &phaseTimeoutBody{ReadCloser: resp.Body, ctx: ctx, cancel: cancel, timers: timers, idle: limits.idle}
+	return resp, nil
+}
+
+func phaseTimeoutCause(ctx context.Context) error {
+	var timeoutErr *phaseTimeoutError
+	if errors.As(context.Cause(ctx), &timeoutErr) {
+		return timeoutErr
+	}
+	return nil
+}
+
+// phaseTimers cancels the request context with a phaseTimeoutError when a phase expires.
+type phaseTimers struct {
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc
+	timers map[string]*time.Timer
+	closed bool
+}
+
+func (p *phaseTimers) start(phase string, limit time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	if p.timers == nil {
+		p.timers = make(map[string]*time.Timer)
+	}
+	if t := p.timers[phase]; t != nil {
+		t.Reset(limit)
+		return
+	}
+	p.timers[phase] = time.AfterFunc(limit, func() {
+		p.cancel(&phaseTimeoutError{phase: phase, limit: limit})
+	})
+}
+
+func (p *phaseTimers) stop(phase string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t := p.timers[phase]; t != nil {
+		t.Stop()
+		delete(p.timers, phase)
+	}
+}
+
+func (p *phaseTimers) stopAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for phase, t := range p.timers {
+		t.Stop()
+		delete(p.timers, phase)
+	}
+}
+
+// phaseTimeoutBody restarts the idle timer on every read and reports expired phases as the
+// read error.
+type phaseTimeoutBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	timers *phaseTimers
+	idle   time.Duration
+}
+
+func (b *phaseTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		if cause := phaseTimeoutCause(b.ctx); cause != nil {
+			err = cause
+		}
+	}
+	if n > 0 && b.idle > 0 && err == nil {
+		b.timers.start(phaseIdle, b.idle)
+	}
+	return n, err
+}
+
+func (b *phaseTimeoutBody) Close() error {
+	b.timers.stopAll()
+	err := b.ReadCloser.Close()
+	b.cancel(nil)
+	return err
+}
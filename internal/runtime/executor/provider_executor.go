@@ -0,0 +1,235 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/provider"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// ProviderExecutor serves a provider registered through the provider package. It owns the
+// parts shared by every upstream (translation, payload rules, proxies, request logging and
+// usage) and delegates the upstream specifics to the provider.
+type ProviderExecutor struct {
+	provider provider.Provider
+	cfg      *config.Config
+}
+
+// NewProviderExecutor wraps p in an executor using cfg.
+func NewProviderExecutor(p provider.Provider, cfg *config.Config) *ProviderExecutor {
+	return &ProviderExecutor{provider: p, cfg: cfg}
+}
+
+func (e *ProviderExecutor) Identifier() string { return e.provider.Identifier() }
+
+func (e *ProviderExecutor) PrepareRequest(req *http.Request, auth *cliproxyauth.Auth) error {
+	return e.provider.Authorize(req, auth)
+}
+
+func (e *ProviderExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := e.provider.Format()
+	body := e.translateRequest(ctx, from, to, req, opts, false)
+	httpResp, err := e.do(ctx, auth, req.Model, body, false)
+	if err != nil {
+		return resp, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("%s executor: close response body error: %v", e.Identifier(), errClose)
+		}
+	}()
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	reporter.publish(ctx, e.parseUsage(data))
+	var param any
+	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
+	resp = cliproxyexecutor.Response{Payload: []byte(out)}
+	return resp, nil
+}
+
+func (e *ProviderExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (stream <-chan cliproxyexecutor.StreamChunk, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := e.provider.Format()
+	body := e.translateRequest(ctx, from, to, req, opts, true)
+	httpResp, err := e.do(ctx, auth, req.Model, body, true)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	stream = out
+	go func() {
+		defer close(out)
+		defer func() {
+			if errClose := httpResp.Body.Close(); errClose != nil {
+				log.Errorf("%s executor: close response body error: %v", e.Identifier(), errClose)
+			}
+		}()
+		var param any
+		errDecode := e.provider.DecodeStream(httpResp.Body, func(frame []byte) error {
+			appendAPIResponseChunk(ctx, e.cfg, frame)
+			if detail, ok := e.parseStreamUsage(frame); ok {
+				reporter.publish(ctx, detail)
+			}
+			chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, opts.OriginalRequest, body, bytes.Clone(frame), &param)
+			for i := range chunks {
+				select {
+				case out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+		if errDecode != nil {
+			if !errors.Is(errDecode, context.Canceled) {
+				recordAPIResponseError(ctx, e.cfg, errDecode)
+				reporter.publishFailure(ctx)
+				out <- cliproxyexecutor.StreamChunk{Err: errDecode}
+			}
+			return
+		}
+		doneChunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, opts.OriginalRequest, body, []byte("[DONE]"), &param)
+		for i := range doneChunks {
+			out <- cliproxyexecutor.StreamChunk{Payload: []byte(doneChunks[i])}
+		}
+	}()
+	return stream, nil
+}
+
+// CountTokens estimates the prompt size locally from the request translated to the OpenAI schema.
+func (e *ProviderExecutor) CountTokens(ctx context.Context, _ *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	body := sdktranslator.TranslateRequestContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
+
+	enc, err := tokenizerForModel(req.Model)
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("%s executor: tokenizer init failed: %w", e.Identifier(), err)
+	}
+	count, err := countOpenAIChatTokens(enc, body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("%s executor: token counting failed: %w", e.Identifier(), err)
+	}
+	usageJSON := buildOpenAIUsageJSON(count)
+	translated := sdktranslator.TranslateTokenCount(ctx, to, from, count, usageJSON)
+	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
+}
+
+func (e *ProviderExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	if refresher, ok := e.provider.(provider.Refresher); ok {
+		return refresher.Refresh(ctx, auth)
+	}
+	return auth, nil
+}
+
+func (e *ProviderExecutor) translateRequest(ctx context.Context, from, to sdktranslator.Format, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, stream bool) []byte {
+	originalTranslated := translateOriginalForPayload(e.cfg, from, to, req.Model, req.Payload, opts.OriginalRequest, stream)
+	body := sdktranslator.TranslateRequestContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), stream)
+	body = applyPayloadConfigWithRoot(e.cfg, req.Model, to.String(), "", body, originalTranslated)
+	return applyRequestTransforms(ctx, e.cfg, req.Model, to.String(), body)
+}
+
+// do sends body upstream and returns the response when its status is 2xx.
+func (e *ProviderExecutor) do(ctx context.Context, auth *cliproxyauth.Auth, model string, body []byte, stream bool) (*http.Response, error) {
+	httpReq, err := e.provider.BuildRequest(ctx, auth, model, body, stream)
+	if err != nil {
+		return nil, err
+	}
+	if err = e.provider.Authorize(httpReq, auth); err != nil {
+		return nil, err
+	}
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       httpReq.URL.String(),
+		Method:    httpReq.Method,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return nil, err
+	}
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode >= 200 && httpResp.StatusCode < 300 {
+		return httpResp, nil
+	}
+	b, _ := io.ReadAll(httpResp.Body)
+	appendAPIResponseChunk(ctx, e.cfg, b)
+	if errClose := httpResp.Body.Close(); errClose != nil {
+		log.Errorf("%s executor: close response body error: %v", e.Identifier(), errClose)
+	}
+	log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, summarizeErrorBody(httpResp.Header.Get("Content-Type"), b))
+	if errMapped := e.provider.MapError(httpResp.StatusCode, httpResp.Header, b); errMapped != nil {
+		return nil, errMapped
+	}
+	return nil, statusErr{code: httpResp.StatusCode, msg: string(b)}
+}
+
+// parseUsage reads usage from a buffered response using the provider's parser when it has one,
+// otherwise the parser for its format.
+func (e *ProviderExecutor) parseUsage(data []byte) usage.Detail {
+	if parser, ok := e.provider.(provider.UsageParser); ok {
+		return parser.ParseUsage(data)
+	}
+	switch e.provider.Format() {
+	case sdktranslator.FormatClaude:
+		return parseClaudeUsage(data)
+	case sdktranslator.FormatGemini:
+		return parseGeminiUsage(data)
+	case sdktranslator.FormatCodex:
+		detail, _ := parseCodexUsage(data)
+		return detail
+	default:
+		return parseOpenAIUsage(data)
+	}
+}
+
+func (e *ProviderExecutor) parseStreamUsage(frame []byte) (usage.Detail, bool) {
+	if parser, ok := e.provider.(provider.UsageParser); ok {
+		return parser.ParseStreamUsage(frame)
+	}
+	switch e.provider.Format() {
+	case sdktranslator.FormatClaude:
+		return parseClaudeStreamUsage(frame)
+	case sdktranslator.FormatGemini:
+		return parseGeminiStreamUsage(frame)
+	case sdktranslator.FormatCodex:
+		return parseCodexUsage(jsonPayload(frame))
+	default:
+		return parseOpenAIStreamUsage(frame)
+	}
+}
@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+const (
+	// claudeUnifiedPrefix starts the Claude subscription window headers, for example
+	// anthropic-ratelimit-unified-5h-utilization and anthropic-ratelimit-unified-5h-reset.
+	claudeUnifiedPrefix = "Anthropic-Ratelimit-Unified-"
+	// codexWindowPrefix starts the Codex plan window headers, for example
+	// x-codex-primary-used-percent and x-codex-secondary-reset-after-seconds.
+	codexWindowPrefix = "X-Codex-"
+)
+
+// quotaWindowRoundTripper reports the usage windows found in upstream response headers to the
+// auth package, which rotates away from credentials close to their limits, and records the
+// upstream request and token limits for the rate-limit headers sent to the client.
+type quotaWindowRoundTripper struct {
+	base     http.RoundTripper
+	authID   string
+	provider string
+}
+
+// withQuotaWindows records the usage windows reported in the responses of httpClient against
+// the credential.
+func withQuotaWindows(auth *cliproxyauth.Auth, httpClient *http.Client) *http.Client {
+	if auth == nil || auth.ID == "" {
+		return httpClient
+	}
+	httpClient.Transport = &quotaWindowRoundTripper{base: httpClient.Transport, authID: auth.ID, provider: auth.Provider}
+	return httpClient
+}
+
+func (rt *quotaWindowRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := rt.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	if windows := parseQuotaWindows(resp.Header, time.Now()); len(windows) > 0 {
+		cliproxyauth.RecordQuotaWindows(rt.authID, rt.provider, windows)
+	}
+	ratelimit.FromContext(req.Context()).ObserveUpstream(resp.Header, time.Now())
+	return resp, nil
+}
+
+// parseQuotaWindows extracts the Claude and Codex usage window headers.
+func parseQuotaWindows(header http.Header, now time.Time) []cliproxyauth.QuotaWindow {
+	var windows []cliproxyauth.QuotaWindow
+	for key := range header {
+		if name, ok := strings.CutPrefix(key, claudeUnifiedPrefix); ok {
+			name, ok = strings.CutSuffix(name, "-Utilization")
+			if !ok {
+				continue
+			}
+			used, errParse := strconv.ParseFloat(strings.TrimSpace(header.Get(key)), 64)
+			if errParse != nil {
+				continue
+			}
+			window := cliproxyauth.QuotaWindow{Name: strings.ToLower(name), Used: used}
+			if reset, errReset := strconv.ParseInt(strings.TrimSpace(header.Get(claudeUnifiedPrefix+name+"-Reset")), 10, 64); errReset == nil && reset > 0 {
+				window.ResetsAt = time.Unix(reset, 0)
+			}
+			windows = append(windows, window)
+			continue
+		}
+		if name, ok := strings.CutPrefix(key, codexWindowPrefix); ok {
+			name, ok = strings.CutSuffix(name, "-Used-Percent")
+			if !ok {
+				continue
+			}
+			percent, errParse := strconv.ParseFloat(strings.TrimSpace(header.Get(key)), 64)
+			if errParse != nil {
+				continue
+			}
+			window := cliproxyauth.QuotaWindow{Name: strings.ToLower(name), Used: percent / 100}
+			if minutes, errMinutes := strconv.Atoi(strings.TrimSpace(header.Get(codexWindowPrefix + name + "-Window-Minutes"))); errMinutes == nil {
+				window.WindowMinutes = minutes
+			}
+			if seconds, errReset := strconv.ParseInt(strings.TrimSpace(header.Get(codexWindowPrefix+name+"-Reset-After-Seconds")), 10, 64); errReset == nil && seconds >= 0 {
+				window.ResetsAt = now.Add(time.Duration(seconds) * time.Second).Truncate(time.Second)
+			} else if reset, errAt := strconv.ParseInt(strings.TrimSpace(header.Get(codexWindowPrefix+name+"-Reset-At")), 10, 64); errAt == nil && reset > 0 {
+				window.ResetsAt = time.Unix(reset, 0)
+			}
+			windows = append(windows, window)
+		}
+	}
+	return windows
+}
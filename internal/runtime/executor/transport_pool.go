@@ -0,0 +1,322 @@
+package executor
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultUpstreamMaxIdleConns        = 256
+	defaultUpstreamMaxIdleConnsPerHost = 64
+	defaultUpstreamIdleConnTimeout     = 90 * time.Second
+	defaultUpstreamKeepAlive           = 30 * time.Second
+	upstreamMaintenanceTick            = 5 * time.Second
+)
+
+// pooledTransport is a shared upstream transport together with its DNS refresh schedule.
+type pooledTransport struct {
+	transport   *http.Transport
+	dnsRefresh  time.Duration
+	lastRefresh atomic.Int64 // unix nanoseconds
+}
+
+// sharedTransports caches upstream transports by proxy URL, pool settings and protocol so
+// streaming requests reuse pooled HTTP/2 connections instead of paying a TLS handshake per request.
+var sharedTransports sync.Map // map[string]*pooledTransport
+
+// upstreamPoolSettings is the effective pool configuration for one transport.
+type upstreamPoolSettings struct {
+	http2               bool
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	keepAlive           time.Duration
+	dnsRefresh          time.Duration
+}
+
+func (s upstreamPoolSettings) key(proxyURL string) string {
+	return fmt.Sprintf("%s\x00%t/%d/%d/%s/%s/%s", proxyURL, s.http2, s.maxIdleConns, s.maxIdleConnsPerHost, s.idleConnTimeout, s.keepAlive, s.dnsRefresh)
+}
+
+func secondsOr(seconds int, fallback time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
+}
+
+// resolvePoolSettings merges the upstream-transport defaults with an optional per-host override.
+func resolvePoolSettings(cfg *config.Config, entry *config.UpstreamPoolConfig) upstreamPoolSettings {
+	s := upstreamPoolSettings{
+		http2:               true,
+		maxIdleConns:        defaultUpstreamMaxIdleConns,
+		maxIdleConnsPerHost: defaultUpstreamMaxIdleConnsPerHost,
+		idleConnTimeout:     defaultUpstreamIdleConnTimeout,
+		keepAlive:           defaultUpstreamKeepAlive,
+	}
+	if cfg == nil {
+		return s
+	}
+	tc := cfg.UpstreamTransport
+	s.http2 = !tc.DisableHTTP2
+	if tc.MaxIdleConns > 0 {
+		s.maxIdleConns = tc.MaxIdleConns
+	}
+	if tc.MaxIdleConnsPerHost > 0 {
+		s.maxIdleConnsPerHost = tc.MaxIdleConnsPerHost
+	}
+	s.idleConnTimeout = secondsOr(tc.IdleConnTimeoutSeconds, s.idleConnTimeout)
+	s.keepAlive = secondsOr(tc.KeepAliveSeconds, s.keepAlive)
+	s.dnsRefresh = secondsOr(tc.DNSRefreshSeconds, 0)
+	if entry != nil {
+		if entry.MaxIdleConnsPerHost > 0 {
+			s.maxIdleConnsPerHost = entry.MaxIdleConnsPerHost
+		}
+		s.idleConnTimeout = secondsOr(entry.IdleConnTimeoutSeconds, s.idleConnTimeout)
+		s.dnsRefresh = secondsOr(entry.DNSRefreshSeconds, s.dnsRefresh)
+	}
+	return s
+}
+
+// matchUpstreamPool returns the first upstreams entry whose hosts match host.
+func matchUpstreamPool(cfg *config.Config, host string) *config.UpstreamPoolConfig {
+	if cfg == nil {
+		return nil
+	}
+	host = strings.ToLower(host)
+	for i := range cfg.UpstreamTransport.Upstreams {
+		entry := &cfg.UpstreamTransport.Upstreams[i]
+		if upstreamTLSHostMatches(entry.Hosts, host) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// sharedUpstreamTransport returns the pooled transport for proxyURL ("" for a direct connection)
+// using the pool settings of entry (nil for the defaults). It returns nil when the proxy URL
+// cannot be used.
+func sharedUpstreamTransport(cfg *config.Config, proxyURL string, entry *config.UpstreamPoolConfig) *http.Transport {
+	settings := resolvePoolSettings(cfg, entry)
+	key := settings.key(proxyURL)
+	if cached, ok := sharedTransports.Load(key); ok {
+		return cached.(*pooledTransport).transport
+	}
+	var transport *http.Transport
+	if proxyURL == "" {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: settings.keepAlive}).DialContext
+	} else if transport = buildProxyTransport(proxyURL); transport == nil {
+		return nil
+	}
+	tuneUpstreamTransport(transport, settings)
+	pooled := &pooledTransport{transport: transport, dnsRefresh: settings.dnsRefresh}
+	pooled.lastRefresh.Store(time.Now().UnixNano())
+	actual, _ := sharedTransports.LoadOrStore(key, pooled)
+	return actual.(*pooledTransport).transport
+}
+
+// tuneUpstreamTransport applies pool sizing and enables or disables HTTP/2 negotiation.
+func tuneUpstreamTransport(transport *http.Transport, settings upstreamPoolSettings) {
+	transport.MaxIdleConns = settings.maxIdleConns
+	transport.MaxIdleConnsPerHost = settings.maxIdleConnsPerHost
+	transport.IdleConnTimeout = settings.idleConnTimeout
+	if transport.TLSHandshakeTimeout == 0 {
+		transport.TLSHandshakeTimeout = 10 * time.Second
+	}
+	if settings.http2 {
+		transport.ForceAttemptHTTP2 = true
+		return
+	}
+	transport.ForceAttemptHTTP2 = false
+	transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+}
+
+// upstreamRoundTripper returns the pooled round tripper for proxyURL. When per-host overrides are
+// configured, requests are dispatched to the transport of the matching upstreams entry.
+func upstreamRoundTripper(cfg *config.Config, proxyURL string) http.RoundTripper {
+	base := sharedUpstreamTransport(cfg, proxyURL, nil)
+	if base == nil {
+		return nil
+	}
+	if cfg == nil || len(cfg.UpstreamTransport.Upstreams) == 0 {
+		return base
+	}
+	return &upstreamPoolRoundTripper{cfg: cfg, proxyURL: proxyURL, base: base}
+}
+
+// upstreamPoolRoundTripper routes requests to per-host pooled transports.
+type upstreamPoolRoundTripper struct {
+	cfg      *config.Config
+	proxyURL string
+	base     *http.Transport
+}
+
+func (t *upstreamPoolRoundTripper) transportForHost(host string) *http.Transport {
+	if entry := matchUpstreamPool(t.cfg, host); entry != nil {
+		if transport := sharedUpstreamTransport(t.cfg, t.proxyURL, entry); transport != nil {
+			return transport
+		}
+	}
+	return t.base
+}
+
+func (t *upstreamPoolRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL == nil {
+		return t.base.RoundTrip(req)
+	}
+	return t.transportForHost(req.URL.Hostname()).RoundTrip(req)
+}
+
+var (
+	upstreamMaintenanceMu     sync.Mutex
+	upstreamMaintenanceCancel context.CancelFunc
+	upstreamPoolSignature     string
+)
+
+// ConfigureUpstreamTransport applies upstream-transport settings. Pools built with outdated
+// settings are drained, and a background loop refreshes DNS, keeps warm-up connections alive and
+// runs the upstream proxy health checks.
+// It is safe to call on every configuration reload.
+func ConfigureUpstreamTransport(cfg *config.Config) {
+	upstreamMaintenanceMu.Lock()
+	defer upstreamMaintenanceMu.Unlock()
+	if upstreamMaintenanceCancel != nil {
+		upstreamMaintenanceCancel()
+		upstreamMaintenanceCancel = nil
+	}
+	var tc config.UpstreamTransportConfig
+	if cfg != nil {
+		tc = cfg.UpstreamTransport
+	}
+	signature := fmt.Sprintf("%t/%d/%d/%d/%d/%d/%v", tc.DisableHTTP2, tc.MaxIdleConns, tc.MaxIdleConnsPerHost, tc.IdleConnTimeoutSeconds, tc.KeepAliveSeconds, tc.DNSRefreshSeconds, tc.Upstreams)
+	if upstreamPoolSignature != "" && signature != upstreamPoolSignature {
+		drainSharedTransports()
+	}
+	upstreamPoolSignature = signature
+
+	ctx, cancel := context.WithCancel(context.Background())
+	upstreamMaintenanceCancel = cancel
+	go maintainUpstreamTransports(ctx, cfg)
+}
+
+// StopUpstreamTransport stops background connection maintenance and closes idle connections.
+func StopUpstreamTransport() {
+	upstreamMaintenanceMu.Lock()
+	defer upstreamMaintenanceMu.Unlock()
+	if upstreamMaintenanceCancel != nil {
+		upstreamMaintenanceCancel()
+		upstreamMaintenanceCancel = nil
+	}
+	sharedTransports.Range(func(_, value any) bool {
+		value.(*pooledTransport).transport.CloseIdleConnections()
+		return true
+	})
+}
+
+func drainSharedTransports() {
+	sharedTransports.Range(func(key, value any) bool {
+		sharedTransports.Delete(key)
+		value.(*pooledTransport).transport.CloseIdleConnections()
+		return true
+	})
+	fingerprintTransports.Range(func(key, value any) bool {
+		fingerprintTransports.Delete(key)
+		value.(*http.Transport).CloseIdleConnections()
+		return true
+	})
+}
+
+func maintainUpstreamTransports(ctx context.Context, cfg *config.Config) {
+	var warmUp []string
+	warmInterval := time.Duration(0)
+	if cfg != nil && len(cfg.UpstreamTransport.WarmUp) > 0 {
+		warmUp = cfg.UpstreamTransport.WarmUp
+		warmInterval = secondsOr(cfg.UpstreamTransport.WarmUpIntervalSeconds, resolvePoolSettings(cfg, nil).idleConnTimeout*2/3)
+		warmUpstreams(ctx, cfg, warmUp)
+	}
+	healthInterval := time.Duration(0)
+	if cfg != nil && cfg.ProxyHealthCheck.Enable && len(cfg.UpstreamProxies) > 0 {
+		healthInterval = secondsOr(cfg.ProxyHealthCheck.IntervalSeconds, defaultProxyHealthInterval)
+		checkUpstreamProxies(ctx, cfg)
+	} else {
+		proxyHealth.Range(func(key, _ any) bool {
+			proxyHealth.Delete(key)
+			return true
+		})
+	}
+	ticker := time.NewTicker(upstreamMaintenanceTick)
+	defer ticker.Stop()
+	lastWarm, lastHealth := time.Now(), time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			refreshUpstreamDNS(now)
+			if healthInterval > 0 && now.Sub(lastHealth) >= healthInterval {
+				lastHealth = now
+				checkUpstreamProxies(ctx, cfg)
+			}
+			if len(warmUp) > 0 && now.Sub(lastWarm) >= warmInterval {
+				lastWarm = now
+				warmUpstreams(ctx, cfg, warmUp)
+			}
+		}
+	}
+}
+
+// refreshUpstreamDNS closes idle connections of transports whose DNS refresh interval elapsed,
+// so the next request dials, and therefore resolves, the upstream host again.
+func refreshUpstreamDNS(now time.Time) {
+	sharedTransports.Range(func(_, value any) bool {
+		pooled := value.(*pooledTransport)
+		if pooled.dnsRefresh > 0 && now.Sub(time.Unix(0, pooled.lastRefresh.Load())) >= pooled.dnsRefresh {
+			pooled.lastRefresh.Store(now.UnixNano())
+			pooled.transport.CloseIdleConnections()
+		}
+		return true
+	})
+}
+
+// warmUpstreams pre-establishes pooled connections by sending a HEAD request to each URL. The
+// response status is irrelevant; only the connection is kept.
+func warmUpstreams(ctx context.Context, cfg *config.Config, urls []string) {
+	var wg sync.WaitGroup
+	for _, rawURL := range urls {
+		rawURL = strings.TrimSpace(rawURL)
+		if rawURL == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			reqCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+			defer cancel()
+			req, errReq := http.NewRequestWithContext(reqCtx, http.MethodHead, target, nil)
+			if errReq != nil {
+				log.Debugf("upstream warm-up %s: %v", target, errReq)
+				return
+			}
+			resp, errDo := newProxyAwareHTTPClient(reqCtx, cfg, nil, 0).Do(req)
+			if errDo != nil {
+				log.Debugf("upstream warm-up %s: %v", target, errDo)
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}(rawURL)
+	}
+	wg.Wait()
+}
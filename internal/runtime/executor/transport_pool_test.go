@@ -0,0 +1,189 @@
+package executor
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestResolvePoolSettings(t *testing.T) {
+	defaults := upstreamPoolSettings{
+		http2:               true,
+		maxIdleConns:        defaultUpstreamMaxIdleConns,
+		maxIdleConnsPerHost: defaultUpstreamMaxIdleConnsPerHost,
+		idleConnTimeout:     defaultUpstreamIdleConnTimeout,
+		keepAlive:           defaultUpstreamKeepAlive,
+	}
+	global := config.UpstreamTransportConfig{
+		DisableHTTP2:           true,
+		MaxIdleConns:           10,
+		MaxIdleConnsPerHost:    5,
+		IdleConnTimeoutSeconds: 30,
+		KeepAliveSeconds:       15,
+		DNSRefreshSeconds:      60,
+	}
+	cases := []struct {
+		name      string
+		transport *config.UpstreamTransportConfig
+		entry     *config.UpstreamPoolConfig
+		want      upstreamPoolSettings
+	}{
+		{name: "nil config", want: defaults},
+		{name: "empty config", transport: &config.UpstreamTransportConfig{}, want: defaults},
+		{
+			name:      "global settings",
+			transport: &global,
+			want:      upstreamPoolSettings{maxIdleConns: 10, maxIdleConnsPerHost: 5, idleConnTimeout: 30 * time.Second, keepAlive: 15 * time.Second, dnsRefresh: time.Minute},
+		},
+		{
+			name:      "per-host override",
+			transport: &global,
+			entry:     &config.UpstreamPoolConfig{MaxIdleConnsPerHost: 2, IdleConnTimeoutSeconds: 5, DNSRefreshSeconds: 10},
+			want:      upstreamPoolSettings{maxIdleConns: 10, maxIdleConnsPerHost: 2, idleConnTimeout: 5 * time.Second, keepAlive: 15 * time.Second, dnsRefresh: 10 * time.Second},
+		},
+		{
+			name:      "per-host zero values inherit",
+			transport: &global,
+			entry:     &config.UpstreamPoolConfig{},
+			want:      upstreamPoolSettings{maxIdleConns: 10, maxIdleConnsPerHost: 5, idleConnTimeout: 30 * time.Second, keepAlive: 15 * time.Second, dnsRefresh: time.Minute},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg *config.Config
+			if tc.transport != nil {
+				cfg = &config.Config{}
+				cfg.UpstreamTransport = *tc.transport
+			}
+			if got := resolvePoolSettings(cfg, tc.entry); got != tc.want {
+				t.Fatalf("resolvePoolSettings() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatchUpstreamPool(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.UpstreamTransport.Upstreams = []config.UpstreamPoolConfig{
+		{Hosts: []string{"*.googleapis.com"}, MaxIdleConnsPerHost: 8},
+		{Hosts: []string{"api.anthropic.com"}, MaxIdleConnsPerHost: 4},
+	}
+	if entry := matchUpstreamPool(cfg, "Generativelanguage.googleapis.com"); entry == nil || entry.MaxIdleConnsPerHost != 8 {
+		t.Fatalf("expected the wildcard entry, got %+v", entry)
+	}
+	if entry := matchUpstreamPool(cfg, "api.anthropic.com"); entry == nil || entry.MaxIdleConnsPerHost != 4 {
+		t.Fatalf("expected the exact entry, got %+v", entry)
+	}
+	if entry := matchUpstreamPool(cfg, "api.openai.com"); entry != nil {
+		t.Fatalf("expected no entry, got %+v", entry)
+	}
+}
+
+// connCounter serves 204s and counts connections the server saw closed.
+func connCounter(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var closed atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv, &closed
+}
+
+func idleRequest(t *testing.T, transport http.RoundTripper, url string) {
+	t.Helper()
+	resp, err := (&http.Client{Transport: transport}).Get(url)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConfigureUpstreamTransportDrainsPoolOnReload(t *testing.T) {
+	t.Cleanup(func() {
+		StopUpstreamTransport()
+		drainSharedTransports()
+		upstreamPoolSignature = ""
+	})
+	srv, closed := connCounter(t)
+
+	first := &config.Config{}
+	ConfigureUpstreamTransport(first)
+	old := sharedUpstreamTransport(first, "", nil)
+	idleRequest(t, old, srv.URL)
+
+	// Reapplying identical settings keeps the pool and its idle connections.
+	ConfigureUpstreamTransport(first)
+	if sharedUpstreamTransport(first, "", nil) != old || closed.Load() != 0 {
+		t.Fatal("unchanged settings must keep the existing pool")
+	}
+
+	second := &config.Config{}
+	second.UpstreamTransport.MaxIdleConnsPerHost = 3
+	ConfigureUpstreamTransport(second)
+	waitFor(t, "the old pool to close its idle connection", func() bool { return closed.Load() == 1 })
+	replacement := sharedUpstreamTransport(second, "", nil)
+	if replacement == old || replacement.MaxIdleConnsPerHost != 3 {
+		t.Fatal("expected a new pool built with the reloaded settings")
+	}
+	if sharedUpstreamTransport(first, "", nil) == old {
+		t.Fatal("the drained pool must not be handed out again")
+	}
+}
+
+func TestRefreshUpstreamDNSClosesIdleConnections(t *testing.T) {
+	t.Cleanup(drainSharedTransports)
+	srv, closed := connCounter(t)
+	cfg := &config.Config{}
+	cfg.UpstreamTransport.DNSRefreshSeconds = 30
+	transport := sharedUpstreamTransport(cfg, "", nil)
+	idleRequest(t, transport, srv.URL)
+
+	refreshUpstreamDNS(time.Now())
+	time.Sleep(20 * time.Millisecond)
+	if closed.Load() != 0 {
+		t.Fatal("connections must survive until the refresh interval elapses")
+	}
+	refreshUpstreamDNS(time.Now().Add(31 * time.Second))
+	waitFor(t, "the idle connection to close", func() bool { return closed.Load() == 1 })
+}
+
+func TestWarmUpstreamsOpensPooledConnections(t *testing.T) {
+	t.Cleanup(drainSharedTransports)
+	var heads atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	warmUpstreams(context.Background(), &config.Config{}, []string{srv.URL, " ", srv.URL + "/v1"})
+	if heads.Load() != 2 {
+		t.Fatalf("expected 2 warm-up requests, got %d", heads.Load())
+	}
+}
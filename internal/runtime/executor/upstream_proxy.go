@@ -0,0 +1,234 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultProxyHealthInterval = 60 * time.Second
+	defaultProxyHealthTimeout  = 10 * time.Second
+	defaultProxyHealthURL      = "https://www.gstatic.com/generate_204"
+)
+
+// ProxyHealth reports the last health check result of an upstream proxy.
+type ProxyHealth struct {
+	Name      string    `json:"name,omitempty"`
+	Proxy     string    `json:"proxy"`
+	Healthy   bool      `json:"healthy"`
+	LatencyMs int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// proxyHealth holds health results keyed by proxy URL.
+var proxyHealth sync.Map // map[string]ProxyHealth
+
+// ProxyHealthSnapshot returns the latest health check results ordered by entry name and proxy.
+func ProxyHealthSnapshot() []ProxyHealth {
+	out := make([]ProxyHealth, 0)
+	proxyHealth.Range(func(_, value any) bool {
+		status := value.(ProxyHealth)
+		status.Proxy = redactProxyURL(status.Proxy)
+		out = append(out, status)
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Proxy < out[j].Proxy
+	})
+	return out
+}
+
+// redactProxyURL hides proxy credentials for display.
+func redactProxyURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.User == nil {
+		return raw
+	}
+	parsed.User = url.User(parsed.User.Username())
+	return parsed.String()
+}
+
+// matchUpstreamProxy returns the first upstream-proxies entry listing provider, or, when host is
+// non-empty, the first entry whose hosts match host.
+func matchUpstreamProxy(cfg *config.Config, provider, host string) *config.UpstreamProxyConfig {
+	if cfg == nil {
+		return nil
+	}
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	host = strings.ToLower(host)
+	for i := range cfg.UpstreamProxies {
+		entry := &cfg.UpstreamProxies[i]
+		if len(entry.ProxyURLs) == 0 {
+			continue
+		}
+		if provider != "" {
+			for _, p := range entry.Providers {
+				if strings.EqualFold(strings.TrimSpace(p), provider) {
+					return entry
+				}
+			}
+		}
+		if host != "" && upstreamTLSHostMatches(entry.Hosts, host) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// selectUpstreamProxy returns the first proxy of entry not known to be unhealthy. When every
+// proxy failed its last check the first one is used anyway.
+func selectUpstreamProxy(entry *config.UpstreamProxyConfig) string {
+	first := ""
+	for _, raw := range entry.ProxyURLs {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if first == "" {
+			first = raw
+		}
+		if status, ok := proxyHealth.Load(raw); !ok || status.(ProxyHealth).Healthy {
+			return raw
+		}
+	}
+	return first
+}
+
+// hasHostProxies reports whether any upstream-proxies entry matches by host.
+func hasHostProxies(cfg *config.Config) bool {
+	if cfg == nil {
+		return false
+	}
+	for i := range cfg.UpstreamProxies {
+		if len(cfg.UpstreamProxies[i].Hosts) > 0 && len(cfg.UpstreamProxies[i].ProxyURLs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hostProxyRoundTripper sends requests for hosts listed in upstream-proxies through their proxy
+// and everything else through fallback.
+type hostProxyRoundTripper struct {
+	cfg      *config.Config
+	fallback http.RoundTripper
+}
+
+func (t *hostProxyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := ""
+	if req.URL != nil {
+		host = req.URL.Hostname()
+	}
+	return t.roundTripperFor(host).RoundTrip(req)
+}
+
+// roundTripperFor returns the proxied transport for host, or the fallback when no entry lists it.
+func (t *hostProxyRoundTripper) roundTripperFor(host string) http.RoundTripper {
+	if host != "" {
+		if entry := matchUpstreamProxy(t.cfg, "", host); entry != nil {
+			proxyURL := selectUpstreamProxy(entry)
+			if transport := upstreamRoundTripper(t.cfg, proxyURL); transport != nil {
+				return withUpstreamTLS(t.cfg, proxyURL, transport)
+			}
+		}
+	}
+	if t.fallback == nil {
+		return http.DefaultTransport
+	}
+	return t.fallback
+}
+
+// checkUpstreamProxies probes every configured proxy once and records the results.
+func checkUpstreamProxies(ctx context.Context, cfg *config.Config) {
+	hc := cfg.ProxyHealthCheck
+	target := strings.TrimSpace(hc.URL)
+	if target == "" {
+		target = defaultProxyHealthURL
+	}
+	timeout := secondsOr(hc.TimeoutSeconds, defaultProxyHealthTimeout)
+	seen := make(map[string]struct{})
+	var wg sync.WaitGroup
+	for i := range cfg.UpstreamProxies {
+		entry := cfg.UpstreamProxies[i]
+		for _, raw := range entry.ProxyURLs {
+			raw = strings.TrimSpace(raw)
+			if raw == "" {
+				continue
+			}
+			if _, dup := seen[raw]; dup {
+				continue
+			}
+			seen[raw] = struct{}{}
+			wg.Add(1)
+			go func(name, proxyURL string) {
+				defer wg.Done()
+				status := probeUpstreamProxy(ctx, cfg, proxyURL, target, timeout)
+				status.Name = name
+				previous, known := proxyHealth.Load(proxyURL)
+				if known && previous.(ProxyHealth).Healthy != status.Healthy {
+					if status.Healthy {
+						log.Infof("upstream proxy %s is healthy again", redactProxyURL(proxyURL))
+					} else {
+						log.Warnf("upstream proxy %s is unhealthy: %s", redactProxyURL(proxyURL), status.Error)
+					}
+				}
+				proxyHealth.Store(proxyURL, status)
+			}(entry.Name, raw)
+		}
+	}
+	wg.Wait()
+	// Forget proxies removed from the configuration.
+	proxyHealth.Range(func(key, _ any) bool {
+		if _, ok := seen[key.(string)]; !ok {
+			proxyHealth.Delete(key)
+		}
+		return true
+	})
+}
+
+// probeUpstreamProxy fetches target through proxyURL. Any response from the target counts as
+// healthy; transport errors and proxy authentication or gateway failures do not.
+func probeUpstreamProxy(ctx context.Context, cfg *config.Config, proxyURL, target string, timeout time.Duration) ProxyHealth {
+	status := ProxyHealth{Proxy: proxyURL, CheckedAt: time.Now()}
+	transport := upstreamRoundTripper(cfg, proxyURL)
+	if transport == nil {
+		status.Error = "invalid proxy url"
+		return status
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, errReq := http.NewRequestWithContext(reqCtx, http.MethodGet, target, nil)
+	if errReq != nil {
+		status.Error = errReq.Error()
+		return status
+	}
+	start := time.Now()
+	resp, errDo := (&http.Client{Transport: transport}).Do(req)
+	status.LatencyMs = time.Since(start).Milliseconds()
+	if errDo != nil {
+		status.Error = errDo.Error()
+		return status
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusProxyAuthRequired, http.StatusBadGateway, http.StatusGatewayTimeout:
+		status.Error = resp.Status
+	default:
+		status.Healthy = true
+	}
+	return status
+}
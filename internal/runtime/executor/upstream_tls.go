@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// upstreamTLSTransports caches mutual-TLS transports by certificate and proxy so connections are
+// reused across requests.
+var upstreamTLSTransports sync.Map // map[string]*http.Transport
+
+// upstreamTLSRoundTripper presents client certificates to the upstream hosts configured in
+// upstream-tls and sends every other request through the base round tripper.
+type upstreamTLSRoundTripper struct {
+	base     http.RoundTripper
+	entries  []config.UpstreamTLSConfig
+	proxyURL string
+}
+
+// withUpstreamTLS wraps base with client certificate support when upstream-tls is configured.
+func withUpstreamTLS(cfg *config.Config, proxyURL string, base http.RoundTripper) http.RoundTripper {
+	if cfg == nil || len(cfg.UpstreamTLS) == 0 {
+		return base
+	}
+	return &upstreamTLSRoundTripper{base: base, entries: cfg.UpstreamTLS, proxyURL: proxyURL}
+}
+
+func (t *upstreamTLSRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.URL == nil || req.URL.Scheme != "https" {
+		return base.RoundTrip(req)
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	for i := range t.entries {
+		entry := &t.entries[i]
+		if !upstreamTLSHostMatches(entry.Hosts, host) {
+			continue
+		}
+		if pool, ok := base.(*upstreamPoolRoundTripper); ok {
+			base = pool.transportForHost(host)
+		}
+		transport, err := t.transportFor(entry, base)
+		if err != nil {
+			return nil, fmt.Errorf("upstream-tls for %s: %w", host, err)
+		}
+		return transport.RoundTrip(req)
+	}
+	return base.RoundTrip(req)
+}
+
+func (t *upstreamTLSRoundTripper) transportFor(entry *config.UpstreamTLSConfig, base http.RoundTripper) (*http.Transport, error) {
+	key := entry.Cert + "\x00" + entry.Key + "\x00" + entry.CA + "\x00" + t.proxyURL
+	if cached, ok := upstreamTLSTransports.Load(key); ok {
+		return cached.(*http.Transport), nil
+	}
+	cert, err := tls.LoadX509KeyPair(entry.Cert, entry.Key)
+	if err != nil {
+		return nil, fmt.Errorf("load client certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	if ca := strings.TrimSpace(entry.CA); ca != "" {
+		pem, errRead := os.ReadFile(ca)
+		if errRead != nil {
+			return nil, fmt.Errorf("read ca: %w", errRead)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca %s contains no PEM certificates", ca)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	var transport *http.Transport
+	if baseTransport, ok := base.(*http.Transport); ok {
+		transport = baseTransport.Clone()
+	} else {
+		if base != http.DefaultTransport {
+			log.Debugf("upstream-tls: custom round tripper replaced by a mutual-TLS transport")
+		}
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.TLSClientConfig = tlsConfig
+	actual, _ := upstreamTLSTransports.LoadOrStore(key, transport)
+	return actual.(*http.Transport), nil
+}
+
+func upstreamTLSHostMatches(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		switch {
+		case pattern == "":
+		case pattern == host:
+			return true
+		case strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]):
+			return true
+		}
+	}
+	return false
+}
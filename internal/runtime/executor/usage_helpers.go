@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/tidwall/gjson"
@@ -21,6 +22,7 @@ type usageReporter struct {
 	authID      string
 	authIndex   string
 	apiKey      string
+	tenant      string
 	source      string
 	requestedAt time.Time
 	once        sync.Once
@@ -33,6 +35,7 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 		model:       model,
 		requestedAt: time.Now(),
 		apiKey:      apiKey,
+		tenant:      cliproxyauth.TenantFromContext(ctx),
 		source:      resolveUsageSource(auth, apiKey),
 	}
 	if auth != nil {
@@ -78,6 +81,7 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 			Model:       r.model,
 			Source:      r.source,
 			APIKey:      r.apiKey,
+			Tenant:      r.tenant,
 			AuthID:      r.authID,
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
@@ -101,6 +105,7 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 			Model:       r.model,
 			Source:      r.source,
 			APIKey:      r.apiKey,
+			Tenant:      r.tenant,
 			AuthID:      r.authID,
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
@@ -532,14 +537,14 @@ func jsonPayload(line []byte) []byte {
 	if len(trimmed) == 0 {
 		return nil
 	}
-	if bytes.Equal(trimmed, []byte("[DONE]")) {
+	if sse.IsDone(trimmed) {
 		return nil
 	}
-	if bytes.HasPrefix(trimmed, []byte("event:")) {
+	if _, ok := sse.Event(trimmed); ok {
 		return nil
 	}
-	if bytes.HasPrefix(trimmed, []byte("data:")) {
-		trimmed = bytes.TrimSpace(trimmed[len("data:"):])
+	if payload, ok := sse.Data(trimmed); ok {
+		trimmed = payload
 	}
 	if len(trimmed) == 0 || trimmed[0] != '{' {
 		return nil
@@ -0,0 +1,241 @@
+package executor
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/sjson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const vertexGRPCMethod = "/google.cloud.aiplatform.v1.PredictionService/StreamGenerateContent"
+
+// vertexGRPCConns caches one multiplexed gRPC connection per regional endpoint.
+var vertexGRPCConns sync.Map // map[string]*grpc.ClientConn
+
+var vertexGRPCDial sync.Mutex
+
+// useVertexGRPC reports whether a service-account stream should use the native gRPC API.
+// Requests routed through an outbound proxy, mutual TLS or a non-SSE alt stay on REST.
+func useVertexGRPC(cfg *config.Config, auth *cliproxyauth.Auth, opts cliproxyexecutor.Options) bool {
+	if cfg == nil || !cfg.UpstreamTransport.VertexGRPC || opts.Alt != "" || len(cfg.UpstreamTLS) > 0 {
+		return false
+	}
+	if auth != nil && strings.TrimSpace(auth.ProxyURL) != "" {
+		return false
+	}
+	return strings.TrimSpace(cfg.ProxyURL) == ""
+}
+
+// vertexGRPCTarget returns the regional gRPC endpoint for location.
+func vertexGRPCTarget(location string) string {
+	return strings.TrimPrefix(vertexBaseURL(location), "https://") + ":443"
+}
+
+func vertexGRPCConn(target string) (*grpc.ClientConn, error) {
+	if cached, ok := vertexGRPCConns.Load(target); ok {
+		return cached.(*grpc.ClientConn), nil
+	}
+	vertexGRPCDial.Lock()
+	defer vertexGRPCDial.Unlock()
+	if cached, ok := vertexGRPCConns.Load(target); ok {
+		return cached.(*grpc.ClientConn), nil
+	}
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})))
+	if err != nil {
+		return nil, fmt.Errorf("vertex executor: dial %s: %w", target, err)
+	}
+	vertexGRPCConns.Store(target, conn)
+	return conn, nil
+}
+
+// vertexGRPCRequest converts a translated Gemini JSON body into a GenerateContentRequest.
+func vertexGRPCRequest(body []byte, modelPath string) (*aiplatformpb.GenerateContentRequest, error) {
+	body, _ = sjson.DeleteBytes(body, "model")
+	var req aiplatformpb.GenerateContentRequest
+	if err := protojson.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	req.Model = modelPath
+	return &req, nil
+}
+
+// vertexGRPCStatusErr maps a gRPC status to the HTTP status the REST endpoint would return.
+func vertexGRPCStatusErr(err error) statusErr {
+	st, _ := status.FromError(err)
+	code := http.StatusInternalServerError
+	switch st.Code() {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		code = http.StatusBadRequest
+	case codes.Unauthenticated:
+		code = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		code = http.StatusForbidden
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.ResourceExhausted:
+		code = http.StatusTooManyRequests
+	case codes.Canceled:
+		code = 499
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		code = http.StatusGatewayTimeout
+	case codes.Unimplemented:
+		code = http.StatusNotImplemented
+	}
+	msg, _ := sjson.Set(`{"error":{}}`, "error.code", code)
+	msg, _ = sjson.Set(msg, "error.message", st.Message())
+	msg, _ = sjson.Set(msg, "error.status", vertexGRPCStatusName(st.Code()))
+	return statusErr{code: code, msg: msg}
+}
+
+// vertexGRPCStatusName returns the google.rpc status name the REST API reports for c.
+func vertexGRPCStatusName(c codes.Code) string {
+	switch c {
+	case codes.InvalidArgument:
+		return "INVALID_ARGUMENT"
+	case codes.FailedPrecondition:
+		return "FAILED_PRECONDITION"
+	case codes.ResourceExhausted:
+		return "RESOURCE_EXHAUSTED"
+	case codes.PermissionDenied:
+		return "PERMISSION_DENIED"
+	case codes.Unauthenticated:
+		return "UNAUTHENTICATED"
+	case codes.NotFound:
+		return "NOT_FOUND"
+	case codes.Unavailable:
+		return "UNAVAILABLE"
+	case codes.DeadlineExceeded:
+		return "DEADLINE_EXCEEDED"
+	case codes.Canceled:
+		return "CANCELLED"
+	default:
+		return "INTERNAL"
+	}
+}
+
+// executeStreamWithGRPC streams a service-account request over PredictionService.StreamGenerateContent.
+// Each response is re-encoded as the JSON the REST SSE endpoint emits, so translation is unchanged.
+// ok is false when the body cannot be expressed as a gRPC request and the caller should use REST.
+func (e *GeminiVertexExecutor) executeStreamWithGRPC(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, reporter *usageReporter, body []byte, projectID, location string, saJSON []byte) (stream <-chan cliproxyexecutor.StreamChunk, ok bool, err error) {
+	modelPath := fmt.Sprintf("projects/%s/locations/%s/publishers/google/models/%s", projectID, location, req.Model)
+	grpcReq, errReq := vertexGRPCRequest(body, modelPath)
+	if errReq != nil {
+		log.Debugf("vertex executor: request not representable over gRPC, using REST: %v", errReq)
+		return nil, false, nil
+	}
+	target := vertexGRPCTarget(location)
+	conn, errConn := vertexGRPCConn(target)
+	if errConn != nil {
+		log.Warnf("%v, using REST", errConn)
+		return nil, false, nil
+	}
+	token, errTok := vertexAccessToken(ctx, e.cfg, auth, saJSON)
+	if errTok != nil {
+		log.Errorf("vertex executor: access token error: %v", errTok)
+		return nil, true, statusErr{code: 500, msg: "internal server error"}
+	}
+
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer "+token)
+	headers.Set("X-Goog-Request-Params", "model="+modelPath)
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       "grpc://" + target + vertexGRPCMethod,
+		Method:    http.MethodPost,
+		Headers:   headers,
+		Body:      body,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	streamCtx = metadata.AppendToOutgoingContext(streamCtx,
+		"authorization", "Bearer "+token,
+		"x-goog-request-params", "model="+modelPath)
+	client := aiplatformpb.NewPredictionServiceClient(conn)
+	upstream, errStream := client.StreamGenerateContent(streamCtx, grpcReq)
+	if errStream != nil {
+		cancel()
+		recordAPIResponseError(ctx, e.cfg, errStream)
+		return nil, true, vertexGRPCStatusErr(errStream)
+	}
+	// Receive the first message synchronously so upstream errors surface as status errors
+	// the auth manager can retry on another credential.
+	first, errRecv := upstream.Recv()
+	if errRecv != nil && !errors.Is(errRecv, io.EOF) {
+		cancel()
+		recordAPIResponseError(ctx, e.cfg, errRecv)
+		sErr := vertexGRPCStatusErr(errRecv)
+		recordAPIResponseMetadata(ctx, e.cfg, sErr.code, nil)
+		appendAPIResponseChunk(ctx, e.cfg, []byte(sErr.msg))
+		return nil, true, sErr
+	}
+	recordAPIResponseMetadata(ctx, e.cfg, http.StatusOK, nil)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("gemini")
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer cancel()
+		var param any
+		emit := func(line []byte) {
+			appendAPIResponseChunk(ctx, e.cfg, line)
+			if detail, okUsage := parseGeminiStreamUsage(line); okUsage {
+				reporter.publish(ctx, detail)
+			}
+			lines := sdktranslator.TranslateStream(ctx, to, from, req.Model, opts.OriginalRequest, body, line, &param)
+			for i := range lines {
+				out <- cliproxyexecutor.StreamChunk{Payload: []byte(lines[i])}
+			}
+		}
+		resp, errNext := first, errRecv
+		for errNext == nil {
+			payload, errMarshal := protojson.Marshal(resp)
+			if errMarshal != nil {
+				errNext = errMarshal
+				break
+			}
+			emit(append([]byte("data: "), payload...))
+			resp, errNext = upstream.Recv()
+		}
+		lines := sdktranslator.TranslateStream(ctx, to, from, req.Model, opts.OriginalRequest, body, []byte("[DONE]"), &param)
+		for i := range lines {
+			out <- cliproxyexecutor.StreamChunk{Payload: []byte(lines[i])}
+		}
+		if !errors.Is(errNext, io.EOF) {
+			recordAPIResponseError(ctx, e.cfg, errNext)
+			reporter.publishFailure(ctx)
+			out <- cliproxyexecutor.StreamChunk{Err: vertexGRPCStatusErr(errNext)}
+		}
+	}()
+	return out, true, nil
+}
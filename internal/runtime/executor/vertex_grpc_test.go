@@ -0,0 +1,143 @@
+package executor
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestVertexGRPCRequest(t *testing.T) {
+	body := []byte(`{"model":"gemini-2.5-pro","contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"temperature":0.5,"maxOutputTokens":64}}`)
+	modelPath := "projects/p/locations/us-central1/publishers/google/models/gemini-2.5-pro"
+	req, err := vertexGRPCRequest(body, modelPath)
+	if err != nil {
+		t.Fatalf("vertexGRPCRequest() error = %v", err)
+	}
+	if req.GetModel() != modelPath {
+		t.Fatalf("model = %q, want %q", req.GetModel(), modelPath)
+	}
+	if len(req.GetContents()) != 1 || req.GetContents()[0].GetParts()[0].GetText() != "hi" {
+		t.Fatalf("unexpected contents: %v", req.GetContents())
+	}
+	if req.GetGenerationConfig().GetMaxOutputTokens() != 64 || req.GetGenerationConfig().GetTemperature() != 0.5 {
+		t.Fatalf("unexpected generation config: %v", req.GetGenerationConfig())
+	}
+
+	if _, err = vertexGRPCRequest([]byte(`{"contents":[],"unknownField":true}`), modelPath); err == nil {
+		t.Fatal("expected fields without a gRPC equivalent to fall back to REST")
+	}
+}
+
+func TestVertexGRPCStatusErr(t *testing.T) {
+	cases := []struct {
+		code       codes.Code
+		wantStatus int
+		wantName   string
+	}{
+		{codes.InvalidArgument, http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{codes.Unauthenticated, http.StatusUnauthorized, "UNAUTHENTICATED"},
+		{codes.PermissionDenied, http.StatusForbidden, "PERMISSION_DENIED"},
+		{codes.NotFound, http.StatusNotFound, "NOT_FOUND"},
+		{codes.ResourceExhausted, http.StatusTooManyRequests, "RESOURCE_EXHAUSTED"},
+		{codes.Canceled, 499, "CANCELLED"},
+		{codes.Unavailable, http.StatusServiceUnavailable, "UNAVAILABLE"},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout, "DEADLINE_EXCEEDED"},
+		{codes.Unimplemented, http.StatusNotImplemented, "INTERNAL"},
+		{codes.Internal, http.StatusInternalServerError, "INTERNAL"},
+	}
+	for _, tc := range cases {
+		err := vertexGRPCStatusErr(status.Error(tc.code, "boom"))
+		if err.code != tc.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tc.code, err.code, tc.wantStatus)
+		}
+		msg := gjson.Parse(err.msg)
+		if msg.Get("error.code").Int() != int64(tc.wantStatus) || msg.Get("error.message").String() != "boom" || msg.Get("error.status").String() != tc.wantName {
+			t.Errorf("%s: unexpected body %s", tc.code, err.msg)
+		}
+	}
+}
+
+func TestUseVertexGRPC(t *testing.T) {
+	enabled := func() *config.Config {
+		cfg := &config.Config{}
+		cfg.UpstreamTransport.VertexGRPC = true
+		return cfg
+	}
+	if !useVertexGRPC(enabled(), &cliproxyauth.Auth{}, cliproxyexecutor.Options{}) {
+		t.Fatal("expected gRPC when enabled without proxies or mutual TLS")
+	}
+	if useVertexGRPC(nil, nil, cliproxyexecutor.Options{}) || useVertexGRPC(&config.Config{}, nil, cliproxyexecutor.Options{}) {
+		t.Fatal("gRPC must be opt-in")
+	}
+	if useVertexGRPC(enabled(), nil, cliproxyexecutor.Options{Alt: "json"}) {
+		t.Fatal("non-SSE alt must stay on REST")
+	}
+	if useVertexGRPC(enabled(), &cliproxyauth.Auth{ProxyURL: "http://proxy:8080"}, cliproxyexecutor.Options{}) {
+		t.Fatal("credential proxies must stay on REST")
+	}
+	cfg := enabled()
+	cfg.ProxyURL = "socks5://proxy:1080"
+	if useVertexGRPC(cfg, nil, cliproxyexecutor.Options{}) {
+		t.Fatal("a global proxy must stay on REST")
+	}
+	cfg = enabled()
+	cfg.UpstreamTLS = []config.UpstreamTLSConfig{{}}
+	if useVertexGRPC(cfg, nil, cliproxyexecutor.Options{}) {
+		t.Fatal("mutual TLS must stay on REST")
+	}
+}
+
+func TestVertexGRPCConnReusedPerTarget(t *testing.T) {
+	target := vertexGRPCTarget("europe-west4")
+	if target != "europe-west4-aiplatform.googleapis.com:443" {
+		t.Fatalf("target = %q", target)
+	}
+	first, err := vertexGRPCConn(target)
+	if err != nil {
+		t.Fatalf("vertexGRPCConn() error = %v", err)
+	}
+	second, _ := vertexGRPCConn(target)
+	other, _ := vertexGRPCConn(vertexGRPCTarget("us-east5"))
+	t.Cleanup(func() {
+		for _, target := range []string{target, vertexGRPCTarget("us-east5")} {
+			if conn, ok := vertexGRPCConns.LoadAndDelete(target); ok {
+				_ = conn.(interface{ Close() error }).Close()
+			}
+		}
+	})
+	if first != second {
+		t.Fatal("expected the connection to be reused for the same endpoint")
+	}
+	if first == other {
+		t.Fatal("expected separate connections per regional endpoint")
+	}
+}
+
+func TestSharedUpstreamTransportKeying(t *testing.T) {
+	t.Cleanup(drainSharedTransports)
+	cfg := &config.Config{}
+	direct := sharedUpstreamTransport(cfg, "", nil)
+	if direct == nil || sharedUpstreamTransport(cfg, "", nil) != direct {
+		t.Fatal("expected the direct transport to be reused")
+	}
+	proxied := sharedUpstreamTransport(cfg, "http://proxy.internal:3128", nil)
+	if proxied == nil || proxied == direct {
+		t.Fatal("expected a separate transport per proxy URL")
+	}
+	if sharedUpstreamTransport(cfg, "http://proxy.internal:3128", nil) != proxied {
+		t.Fatal("expected the proxied transport to be reused")
+	}
+	tuned := sharedUpstreamTransport(cfg, "", &config.UpstreamPoolConfig{MaxIdleConnsPerHost: 4})
+	if tuned == direct || tuned.MaxIdleConnsPerHost != 4 {
+		t.Fatal("expected per-host pool settings to get their own transport")
+	}
+	if sharedUpstreamTransport(cfg, "ftp://proxy.internal", nil) != nil {
+		t.Fatal("expected an unsupported proxy scheme to be rejected")
+	}
+}
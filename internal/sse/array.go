@@ -0,0 +1,119 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+)
+
+// FrameScanner yields the frames of a streamed response body one at a time: the lines of an
+// SSE body or the elements of a JSON array body.
+type FrameScanner interface {
+	Scan() bool
+	Bytes() []byte
+	Err() error
+	Release()
+}
+
+// NewFrameScanner picks the framing from the response Content-Type. JSON bodies, which Gemini
+// streams when a request does not ask for alt=sse, are read as an incrementally streamed array;
+// anything else is read line by line.
+func NewFrameScanner(r io.Reader, contentType string) FrameScanner {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && strings.HasSuffix(mediaType, "json") {
+		return NewArrayScanner(r)
+	}
+	return NewScanner(r)
+}
+
+// ArrayScanner yields the elements of a JSON array as soon as each one is complete, so a
+// streamed array does not have to be buffered whole. Each element is returned compacted onto a
+// single line. A body holding a single JSON value or a sequence of values is also accepted.
+type ArrayScanner struct {
+	reader  *bufio.Reader
+	dec     *json.Decoder
+	started bool
+	array   bool
+	frame   bytes.Buffer
+	err     error
+}
+
+// NewArrayScanner returns a scanner over the JSON array streamed by r.
+func NewArrayScanner(r io.Reader) *ArrayScanner {
+	reader := bufio.NewReader(r)
+	return &ArrayScanner{reader: reader, dec: json.NewDecoder(reader)}
+}
+
+// Scan advances to the next element, returning false at the end of the array or on error.
+func (s *ArrayScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	if !s.started {
+		s.started = true
+		if s.peek() == '[' {
+			s.array = true
+			if _, err := s.dec.Token(); err != nil {
+				s.fail(err)
+				return false
+			}
+		}
+	}
+	if !s.dec.More() {
+		// Consume the closing bracket so truncated arrays surface as errors.
+		if _, err := s.dec.Token(); err != nil && (s.array || !errors.Is(err, io.EOF)) {
+			s.fail(err)
+		}
+		return false
+	}
+	var raw json.RawMessage
+	if err := s.dec.Decode(&raw); err != nil {
+		s.fail(err)
+		return false
+	}
+	s.frame.Reset()
+	if err := json.Compact(&s.frame, raw); err != nil {
+		s.fail(err)
+		return false
+	}
+	return true
+}
+
+// peek returns the first non-whitespace byte of the body without consuming it.
+func (s *ArrayScanner) peek() byte {
+	for {
+		b, err := s.reader.Peek(1)
+		if err != nil || len(b) == 0 {
+			return 0
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = s.reader.ReadByte()
+		default:
+			return b[0]
+		}
+	}
+}
+
+func (s *ArrayScanner) fail(err error) {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	s.err = err
+}
+
+// Bytes returns the current element. It is only valid until the next call to Scan.
+func (s *ArrayScanner) Bytes() []byte {
+	return s.frame.Bytes()
+}
+
+// Err returns the first error encountered, or nil at a clean end of the array.
+func (s *ArrayScanner) Err() error {
+	return s.err
+}
+
+// Release is a no-op; it lets ArrayScanner stand in for a Scanner.
+func (s *ArrayScanner) Release() {}
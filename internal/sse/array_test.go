@@ -0,0 +1,62 @@
+package sse
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func scanAll(t *testing.T, s FrameScanner) ([]string, error) {
+	t.Helper()
+	var frames []string
+	for s.Scan() {
+		frames = append(frames, string(s.Bytes()))
+	}
+	s.Release()
+	return frames, s.Err()
+}
+
+func TestArrayScanner_StreamsElements(t *testing.T) {
+	body := "[{\n  \"candidates\": [{\"content\": {\"parts\": [{\"text\": \"Hel\"}]}}]\n}\n,\r\n{\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"lo\"}]}}]}\n]"
+	frames, err := scanAll(t, NewFrameScanner(strings.NewReader(body), "application/json; charset=UTF-8"))
+	if err != nil {
+		t.Fatalf("Err = %v", err)
+	}
+	want := []string{
+		`{"candidates":[{"content":{"parts":[{"text":"Hel"}]}}]}`,
+		`{"candidates":[{"content":{"parts":[{"text":"lo"}]}}]}`,
+	}
+	if len(frames) != len(want) || frames[0] != want[0] || frames[1] != want[1] {
+		t.Fatalf("frames = %q", frames)
+	}
+}
+
+func TestArrayScanner_YieldsElementsBeforeArrayEnds(t *testing.T) {
+	r, w := io.Pipe()
+	s := NewArrayScanner(r)
+	go func() { _, _ = w.Write([]byte(`[{"n":1},`)) }()
+	if !s.Scan() || string(s.Bytes()) != `{"n":1}` {
+		t.Fatalf("first element not available before the array was complete: %q, %v", s.Bytes(), s.Err())
+	}
+	_ = w.CloseWithError(io.EOF)
+	if s.Scan() {
+		t.Fatalf("unexpected element %q", s.Bytes())
+	}
+	if s.Err() == nil {
+		t.Fatal("expected an error for the truncated array")
+	}
+}
+
+func TestNewFrameScanner_SSE(t *testing.T) {
+	frames, err := scanAll(t, NewFrameScanner(strings.NewReader("data: {\"a\":1}\n\n"), "text/event-stream"))
+	if err != nil || len(frames) != 2 || frames[0] != `data: {"a":1}` {
+		t.Fatalf("frames = %q, err = %v", frames, err)
+	}
+}
+
+func TestArrayScanner_SingleObject(t *testing.T) {
+	frames, err := scanAll(t, NewArrayScanner(strings.NewReader(` {"error":{"code":400}}`)))
+	if err != nil || len(frames) != 1 || frames[0] != `{"error":{"code":400}}` {
+		t.Fatalf("frames = %q, err = %v", frames, err)
+	}
+}
@@ -0,0 +1,83 @@
+// Package sse holds the server-sent events helpers shared by executors and translators: a line
+// scanner with pooled buffers, a scanner for streamed JSON arrays and allocation-free extraction
+// of event fields.
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+const (
+	// MaxLineBytes is the longest line a Scanner accepts. Single SSE events carrying inline
+	// images or large tool arguments can be tens of megabytes.
+	MaxLineBytes = 52_428_800 // 50MB
+
+	// initialBufferBytes sizes the pooled buffers. Longer lines make bufio.Scanner allocate a
+	// larger buffer of its own, which is left to the garbage collector rather than pooled so one
+	// huge event does not pin its memory for the life of the process.
+	initialBufferBytes = 64 << 10
+)
+
+var (
+	dataPrefix  = []byte("data:")
+	eventPrefix = []byte("event:")
+	doneMarker  = []byte("[DONE]")
+)
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, initialBufferBytes)
+		return &buf
+	},
+}
+
+// Scanner splits a stream into lines like bufio.Scanner, reusing its buffer across streams.
+// Bytes returns a view into the buffer that is only valid until the next call to Scan.
+type Scanner struct {
+	*bufio.Scanner
+	buf *[]byte
+}
+
+// NewScanner returns a line scanner over r that accepts lines up to MaxLineBytes. Call Release
+// once the stream is done.
+func NewScanner(r io.Reader) *Scanner {
+	buf := bufferPool.Get().(*[]byte)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer((*buf)[:0:cap(*buf)], MaxLineBytes)
+	return &Scanner{Scanner: scanner, buf: buf}
+}
+
+// Release returns the scanner's initial buffer to the pool. The scanner must not be used
+// afterwards.
+func (s *Scanner) Release() {
+	if s == nil || s.buf == nil {
+		return
+	}
+	bufferPool.Put(s.buf)
+	s.buf = nil
+}
+
+// Data returns the payload of a "data:" line with surrounding whitespace removed. The result
+// aliases line.
+func Data(line []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(line, dataPrefix) {
+		return nil, false
+	}
+	return bytes.TrimSpace(line[len(dataPrefix):]), true
+}
+
+// Event returns the name of an "event:" line. The result aliases line.
+func Event(line []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(line, eventPrefix) {
+		return nil, false
+	}
+	return bytes.TrimSpace(line[len(eventPrefix):]), true
+}
+
+// IsDone reports whether payload is the OpenAI end-of-stream marker.
+func IsDone(payload []byte) bool {
+	return bytes.Equal(payload, doneMarker)
+}
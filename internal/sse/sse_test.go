@@ -0,0 +1,98 @@
+package sse
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestData(t *testing.T) {
+	cases := []struct {
+		line string
+		want string
+		ok   bool
+	}{
+		{`data: {"a":1}`, `{"a":1}`, true},
+		{`data:{"a":1}  `, `{"a":1}`, true},
+		{`data: [DONE]`, `[DONE]`, true},
+		{`event: message_start`, ``, false},
+		{`{"a":1}`, ``, false},
+		{``, ``, false},
+	}
+	for _, tc := range cases {
+		got, ok := Data([]byte(tc.line))
+		if ok != tc.ok || string(got) != tc.want {
+			t.Fatalf("Data(%q) = %q, %v; want %q, %v", tc.line, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestEventAndDone(t *testing.T) {
+	name, ok := Event([]byte("event: content_block_delta"))
+	if !ok || string(name) != "content_block_delta" {
+		t.Fatalf("Event = %q, %v", name, ok)
+	}
+	if _, ok = Event([]byte("data: {}")); ok {
+		t.Fatal("data line reported as event")
+	}
+	if !IsDone([]byte("[DONE]")) || IsDone([]byte("[DONE] ")) {
+		t.Fatal("IsDone mismatch")
+	}
+}
+
+func TestScannerLongLinesAndReuse(t *testing.T) {
+	long := "data: " + strings.Repeat("x", 3*initialBufferBytes)
+	input := "data: first\n\n" + long + "\ndata: last\n"
+
+	for i := 0; i < 2; i++ {
+		scanner := NewScanner(strings.NewReader(input))
+		var lines []string
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("scan error: %v", err)
+		}
+		scanner.Release()
+		scanner.Release()
+
+		if len(lines) != 4 || lines[0] != "data: first" || lines[2] != long || lines[3] != "data: last" {
+			t.Fatalf("unexpected lines on pass %d: %d lines", i, len(lines))
+		}
+	}
+}
+
+func benchmarkStream() []byte {
+	var buf bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		buf.WriteString(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"hello"}}]}`)
+		buf.WriteString("\n\n")
+	}
+	buf.WriteString("data: [DONE]\n")
+	return buf.Bytes()
+}
+
+func BenchmarkScanner(b *testing.B) {
+	stream := benchmarkStream()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(stream)))
+	for i := 0; i < b.N; i++ {
+		scanner := NewScanner(bytes.NewReader(stream))
+		for scanner.Scan() {
+			if payload, ok := Data(scanner.Bytes()); ok && IsDone(payload) {
+				break
+			}
+		}
+		scanner.Release()
+	}
+}
+
+func BenchmarkData(b *testing.B) {
+	line := []byte(`data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"hello"}}]}`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, ok := Data(line); !ok {
+			b.Fatal("expected data line")
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package sse
+
+import (
+	"bytes"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Block is one event of a chunk forwarded to clients: a raw JSON document, as in OpenAI chat
+// streams, or an SSE event whose data line holds the JSON payload.
+type Block struct {
+	prefix  []byte
+	Payload []byte
+	suffix  []byte
+}
+
+// Bytes returns the event as it appears on the wire.
+func (b *Block) Bytes() []byte {
+	out := make([]byte, 0, len(b.prefix)+len(b.Payload)+len(b.suffix))
+	out = append(out, b.prefix...)
+	out = append(out, b.Payload...)
+	return append(out, b.suffix...)
+}
+
+// WithText returns a copy of the event with the string at path replaced by text.
+func (b *Block) WithText(path, text string) []byte {
+	payload, err := sjson.SetBytes(b.Payload, path, text)
+	if err != nil {
+		payload = b.Payload
+	}
+	return b.WithPayload(payload)
+}
+
+// WithPayload returns a copy of the event carrying payload instead.
+func (b *Block) WithPayload(payload []byte) []byte {
+	return (&Block{prefix: b.prefix, Payload: payload, suffix: b.suffix}).Bytes()
+}
+
+// SplitBlocks splits a chunk into its raw JSON document or SSE events. Events without a data
+// line have an empty Payload.
+func SplitBlocks(chunk []byte) []*Block {
+	trimmed := bytes.TrimSpace(chunk)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		start := bytes.Index(chunk, trimmed)
+		return []*Block{{prefix: chunk[:start], Payload: trimmed, suffix: chunk[start+len(trimmed):]}}
+	}
+	var blocks []*Block
+	for _, event := range bytes.SplitAfter(chunk, []byte("\n\n")) {
+		if len(event) == 0 {
+			continue
+		}
+		b := &Block{prefix: event}
+		offset := 0
+		for _, line := range bytes.SplitAfter(event, []byte("\n")) {
+			if bytes.HasPrefix(line, dataPrefix) {
+				data := line[len(dataPrefix):]
+				start := offset + len(dataPrefix)
+				if len(data) > 0 && data[0] == ' ' {
+					start++
+				}
+				end := offset + len(bytes.TrimRight(line, "\r\n"))
+				if start <= end {
+					b.prefix, b.Payload, b.suffix = event[:start], event[start:end], event[end:]
+				}
+				break
+			}
+			offset += len(line)
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+// TextPath returns the JSON path of the generated text in a streamed event of any supported
+// format, or "" when the event carries no text. Tool call and reasoning deltas carry no text.
+func TextPath(payload []byte) string {
+	if len(payload) == 0 || payload[0] != '{' {
+		return ""
+	}
+	root := gjson.ParseBytes(payload)
+	switch root.Get("type").String() {
+	case "content_block_delta":
+		if root.Get("delta.type").String() == "text_delta" {
+			return "delta.text"
+		}
+		return ""
+	case "response.output_text.delta":
+		return "delta"
+	}
+	for _, path := range []string{
+		"choices.0.delta.content",
+		"choices.0.text",
+		"candidates.0.content.parts.0.text",
+		"response.candidates.0.content.parts.0.text",
+	} {
+		if v := root.Get(path); v.Type == gjson.String {
+			return path
+		}
+	}
+	return ""
+}
+
+// EndsContent reports whether a streamed event carries the finish reason of the generated content.
+func EndsContent(payload []byte) bool {
+	root := gjson.ParseBytes(payload)
+	return root.Get("choices.0.finish_reason").Type == gjson.String ||
+		root.Get("candidates.0.finishReason").Exists() ||
+		root.Get("response.candidates.0.finishReason").Exists()
+}
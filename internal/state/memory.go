@@ -0,0 +1,65 @@
+package state
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryStore implements Store in process memory. Subsystems that need a Store even when no
+// durable backend is configured use it as a fallback; its contents are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func memoryKey(namespace, key string) string { return namespace + "\x00" + key }
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, namespace, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := memoryKey(namespace, key)
+	entry, ok := s.entries[k]
+	if !ok {
+		return nil, false, nil
+	}
+	if !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+		delete(s.entries, k)
+		return nil, false, nil
+	}
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(_ context.Context, namespace, key string, value []byte, ttl time.Duration) error {
+	entry := memoryEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	s.mu.Lock()
+	s.entries[memoryKey(namespace, key)] = entry
+	s.mu.Unlock()
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, namespace, key string) error {
+	s.mu.Lock()
+	delete(s.entries, memoryKey(namespace, key))
+	s.mu.Unlock()
+	return nil
+}
+
+// Close implements Store.
+func (s *MemoryStore) Close() error { return nil }
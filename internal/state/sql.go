@@ -0,0 +1,220 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	// Register the pure-Go SQLite and pgx database/sql drivers.
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// DriverSQLite selects the embedded SQLite backend.
+	DriverSQLite = "sqlite"
+	// DriverPostgres selects the Postgres backend.
+	DriverPostgres = "postgres"
+
+	purgeInterval = 5 * time.Minute
+)
+
+type migration struct {
+	version    int
+	statements []string
+}
+
+// migrations are applied in order; {{blob}} expands to the dialect's binary column type.
+var migrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS state_entries (
+				namespace  TEXT NOT NULL,
+				key        TEXT NOT NULL,
+				value      {{blob}} NOT NULL,
+				expires_at BIGINT NOT NULL DEFAULT 0,
+				updated_at BIGINT NOT NULL,
+				PRIMARY KEY (namespace, key)
+			)`,
+			`CREATE INDEX IF NOT EXISTS state_entries_expires_at ON state_entries (expires_at)`,
+		},
+	},
+}
+
+// SQLStore implements Store on top of database/sql for SQLite and Postgres.
+type SQLStore struct {
+	db        *sql.DB
+	driver    string
+	lastPurge atomic.Int64
+}
+
+// Open connects to the backend and applies pending migrations. For SQLite, dsn is a file path;
+// for Postgres it is a connection string.
+func Open(ctx context.Context, driver, dsn string) (*SQLStore, error) {
+	driver = strings.ToLower(strings.TrimSpace(driver))
+	if driver == "" {
+		driver = DriverSQLite
+	}
+	var (
+		db  *sql.DB
+		err error
+	)
+	switch driver {
+	case DriverSQLite:
+		if strings.TrimSpace(dsn) == "" {
+			return nil, fmt.Errorf("state store: sqlite path is required")
+		}
+		if errMkdir := os.MkdirAll(filepath.Dir(dsn), 0o755); errMkdir != nil {
+			return nil, fmt.Errorf("state store: create directory: %w", errMkdir)
+		}
+		db, err = sql.Open("sqlite", dsn+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+		if err == nil {
+			// SQLite serialises writers; a single connection avoids SQLITE_BUSY churn.
+			db.SetMaxOpenConns(1)
+		}
+	case DriverPostgres:
+		if strings.TrimSpace(dsn) == "" {
+			return nil, fmt.Errorf("state store: postgres dsn is required")
+		}
+		db, err = sql.Open("pgx", dsn)
+	default:
+		return nil, fmt.Errorf("state store: unsupported driver %q", driver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("state store: open %s: %w", driver, err)
+	}
+	s := &SQLStore{db: db, driver: driver}
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("state store: connect %s: %w", driver, err)
+	}
+	if err = s.migrate(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLStore) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS state_schema_migrations (version INTEGER PRIMARY KEY, applied_at BIGINT NOT NULL)`); err != nil {
+		return fmt.Errorf("state store: create migrations table: %w", err)
+	}
+	var current int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM state_schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("state store: read schema version: %w", err)
+	}
+	blobType := "BLOB"
+	if s.driver == DriverPostgres {
+		blobType = "BYTEA"
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("state store: begin migration %d: %w", m.version, err)
+		}
+		for _, stmt := range m.statements {
+			if _, err = tx.ExecContext(ctx, strings.ReplaceAll(stmt, "{{blob}}", blobType)); err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("state store: migration %d: %w", m.version, err)
+			}
+		}
+		if _, err = tx.ExecContext(ctx, s.rebind(`INSERT INTO state_schema_migrations (version, applied_at) VALUES (?, ?)`), m.version, time.Now().Unix()); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("state store: record migration %d: %w", m.version, err)
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("state store: commit migration %d: %w", m.version, err)
+		}
+	}
+	return nil
+}
+
+// rebind converts "?" placeholders to the driver's syntax.
+func (s *SQLStore) rebind(query string) string {
+	if s.driver != DriverPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Get implements Store.
+func (s *SQLStore) Get(ctx context.Context, namespace, key string) ([]byte, bool, error) {
+	var (
+		value     []byte
+		expiresAt int64
+	)
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT value, expires_at FROM state_entries WHERE namespace = ? AND key = ?`), namespace, key).Scan(&value, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if expiresAt > 0 && time.Now().UnixMilli() >= expiresAt {
+		return nil, false, nil
+	}
+	return value, true, nil
+}
+
+// Put implements Store.
+func (s *SQLStore) Put(ctx context.Context, namespace, key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = now.Add(ttl).UnixMilli()
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO state_entries (namespace, key, value, expires_at, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (namespace, key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at, updated_at = excluded.updated_at`),
+		namespace, key, value, expiresAt, now.UnixMilli())
+	if err != nil {
+		return err
+	}
+	s.maybePurge(ctx, now)
+	return nil
+}
+
+// Delete implements Store.
+func (s *SQLStore) Delete(ctx context.Context, namespace, key string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM state_entries WHERE namespace = ? AND key = ?`), namespace, key)
+	return err
+}
+
+// Driver returns the backend driver name.
+func (s *SQLStore) Driver() string { return s.driver }
+
+// Close implements Store.
+func (s *SQLStore) Close() error { return s.db.Close() }
+
+// maybePurge removes expired entries at most once per purgeInterval.
+func (s *SQLStore) maybePurge(ctx context.Context, now time.Time) {
+	last := s.lastPurge.Load()
+	if now.UnixMilli()-last < purgeInterval.Milliseconds() || !s.lastPurge.CompareAndSwap(last, now.UnixMilli()) {
+		return
+	}
+	_, _ = s.db.ExecContext(ctx, s.rebind(`DELETE FROM state_entries WHERE expires_at > 0 AND expires_at <= ?`), now.UnixMilli())
+}
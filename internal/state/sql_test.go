@@ -0,0 +1,62 @@
+package state
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLStoreSQLite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.db")
+	store, err := Open(ctx, DriverSQLite, path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	if err = store.Put(ctx, NamespaceToolIDMap, "k", []byte("v1"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err = store.Put(ctx, NamespaceToolIDMap, "k", []byte("v2"), 0); err != nil {
+		t.Fatalf("Put overwrite: %v", err)
+	}
+	if got, ok, errGet := store.Get(ctx, NamespaceToolIDMap, "k"); errGet != nil || !ok || string(got) != "v2" {
+		t.Fatalf("Get = %q, %v, %v; want v2", got, ok, errGet)
+	}
+	if _, ok, _ := store.Get(ctx, NamespaceUsage, "k"); ok {
+		t.Fatal("namespaces must be isolated")
+	}
+
+	if err = store.Put(ctx, NamespaceResponseCache, "short", []byte("x"), time.Nanosecond); err != nil {
+		t.Fatalf("Put ttl: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if _, ok, _ := store.Get(ctx, NamespaceResponseCache, "short"); ok {
+		t.Fatal("expired entry must not be returned")
+	}
+
+	if err = store.Delete(ctx, NamespaceToolIDMap, "k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := store.Get(ctx, NamespaceToolIDMap, "k"); ok {
+		t.Fatal("deleted entry must not be returned")
+	}
+
+	if err = store.Put(ctx, NamespaceUsage, "snapshot", []byte("{}"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err = store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Reopening re-runs migrations without touching existing data.
+	store, err = Open(ctx, DriverSQLite, path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	if got, ok, _ := store.Get(ctx, NamespaceUsage, "snapshot"); !ok || string(got) != "{}" {
+		t.Fatalf("entry lost across reopen: %q %v", got, ok)
+	}
+}
@@ -0,0 +1,53 @@
+// Package state provides the durable key/value backend shared by subsystems that previously
+// kept their state in ad-hoc in-memory maps (tool_use ID mapping, response cache, usage
+// accounting). Entries are grouped by namespace and may carry an expiry.
+package state
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Namespaces used by built-in subsystems.
+const (
+	NamespaceToolIDMap     = "tool-id-map"
+	NamespaceResponseCache = "response-cache"
+	NamespaceUsage         = "usage"
+	NamespaceOpenAIFiles   = "openai-files"
+	NamespaceOpenAIBatches = "openai-batches"
+	NamespaceThoughtSigs   = "thought-signatures"
+	NamespaceKeyUsage      = "key-usage"
+	NamespaceSpendBudgets  = "spend-budgets"
+)
+
+// Store is a namespaced key/value store with optional per-entry expiry.
+type Store interface {
+	// Get returns the value stored under namespace/key and whether it exists and is unexpired.
+	Get(ctx context.Context, namespace, key string) ([]byte, bool, error)
+	// Put stores value under namespace/key. A ttl <= 0 keeps the entry until it is deleted.
+	Put(ctx context.Context, namespace, key string, value []byte, ttl time.Duration) error
+	// Delete removes namespace/key. Deleting a missing entry is not an error.
+	Delete(ctx context.Context, namespace, key string) error
+	// Close releases backend resources.
+	Close() error
+}
+
+var (
+	defaultMu    sync.RWMutex
+	defaultStore Store
+)
+
+// SetDefault installs the process-wide store. Passing nil disables durable state.
+func SetDefault(store Store) {
+	defaultMu.Lock()
+	defaultStore = store
+	defaultMu.Unlock()
+}
+
+// Default returns the process-wide store, or nil when no durable backend is configured.
+func Default() Store {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultStore
+}
@@ -0,0 +1,152 @@
+// Package streams tracks in-flight streaming responses so operators can inspect them through
+// the management API.
+package streams
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Info is a point-in-time snapshot of an active stream.
+type Info struct {
+	ID        string    `json:"id"`
+	Handler   string    `json:"handler"`
+	Model     string    `json:"model"`
+	Providers []string  `json:"providers,omitempty"`
+	APIKey    string    `json:"api_key,omitempty"`
+	Route     string    `json:"route,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Chunks    int64     `json:"chunks"`
+	Bytes     int64     `json:"bytes"`
+}
+
+// Stream is a handle for one tracked stream. A nil *Stream is valid and ignores all calls.
+type Stream struct {
+	registry *Registry
+	info     Info
+	cancel   context.CancelFunc
+	chunks   atomic.Int64
+	bytes    atomic.Int64
+	once     sync.Once
+}
+
+// Registry holds the active streams of the process.
+type Registry struct {
+	mu      sync.RWMutex
+	seq     atomic.Uint64
+	streams map[string]*Stream
+}
+
+var defaultRegistry = NewRegistry()
+
+// GetRegistry returns the process-wide stream registry.
+func GetRegistry() *Registry { return defaultRegistry }
+
+// NewRegistry constructs an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{streams: make(map[string]*Stream)}
+}
+
+// Start registers a stream. cancel aborts the stream's upstream request and is invoked when
+// the stream finishes.
+func (r *Registry) Start(info Info, cancel context.CancelFunc) *Stream {
+	if r == nil {
+		return nil
+	}
+	info.ID = "stream-" + strconv.FormatUint(r.seq.Add(1), 10)
+	if info.StartedAt.IsZero() {
+		info.StartedAt = time.Now()
+	}
+	s := &Stream{registry: r, info: info, cancel: cancel}
+	r.mu.Lock()
+	r.streams[info.ID] = s
+	r.mu.Unlock()
+	return s
+}
+
+// List returns the active streams ordered by start time.
+func (r *Registry) List() []Info {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	out := make([]Info, 0, len(r.streams))
+	for _, s := range r.streams {
+		out = append(out, s.snapshot())
+	}
+	r.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].StartedAt.Equal(out[j].StartedAt) {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].StartedAt.Before(out[j].StartedAt)
+	})
+	return out
+}
+
+// Cancel aborts the stream with the given ID. It reports false when no such stream is active.
+func (r *Registry) Cancel(id string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	s, ok := r.streams[id]
+	r.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	s.Finish()
+	return true
+}
+
+// ID returns the stream identifier.
+func (s *Stream) ID() string {
+	if s == nil {
+		return ""
+	}
+	return s.info.ID
+}
+
+// Info returns a snapshot of the stream.
+func (s *Stream) Info() Info {
+	if s == nil {
+		return Info{}
+	}
+	return s.snapshot()
+}
+
+// Observe records a chunk of n bytes delivered to the client.
+func (s *Stream) Observe(n int) {
+	if s == nil {
+		return
+	}
+	s.chunks.Add(1)
+	s.bytes.Add(int64(n))
+}
+
+// Finish removes the stream from its registry and releases its context.
+func (s *Stream) Finish() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() {
+		s.registry.mu.Lock()
+		delete(s.registry.streams, s.info.ID)
+		s.registry.mu.Unlock()
+		if s.cancel != nil {
+			s.cancel()
+		}
+	})
+}
+
+func (s *Stream) snapshot() Info {
+	info := s.info
+	info.Providers = append([]string(nil), s.info.Providers...)
+	info.Chunks = s.chunks.Load()
+	info.Bytes = s.bytes.Load()
+	return info
+}
@@ -0,0 +1,32 @@
+package streams
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRegistryTracksStreams(t *testing.T) {
+	r := NewRegistry()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := r.Start(Info{Handler: "openai", Model: "m"}, cancel)
+	s.Observe(10)
+	s.Observe(5)
+
+	list := r.List()
+	if len(list) != 1 || list[0].ID != s.ID() || list[0].Chunks != 2 || list[0].Bytes != 15 {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+
+	s.Finish()
+	s.Finish()
+	if len(r.List()) != 0 {
+		t.Fatal("finished stream still listed")
+	}
+	if ctx.Err() == nil {
+		t.Fatal("finish must release the stream context")
+	}
+
+	var nilStream *Stream
+	nilStream.Observe(1)
+	nilStream.Finish()
+}
@@ -0,0 +1,263 @@
+// Package sysprompt rewrites the system prompt of translated upstream requests according to the
+// configured system-prompt-rules.
+package sysprompt
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Rule actions.
+const (
+	ActionPrepend = "prepend"
+	ActionAppend  = "append"
+	ActionReplace = "replace"
+)
+
+// Apply runs every rule matching model and apiKey, in order, on the system prompt of a payload in
+// the given upstream format. Payloads in unknown formats are returned unchanged.
+func Apply(format string, payload []byte, rules []config.SystemPromptRule, model, apiKey string) ([]byte, []string) {
+	var applied []string
+	for i := range rules {
+		rule := &rules[i]
+		if !Matches(rule, model, apiKey) {
+			continue
+		}
+		updated, ok := applyRule(format, payload, strings.ToLower(strings.TrimSpace(rule.Action)), rule.Text)
+		if !ok {
+			continue
+		}
+		payload = updated
+		applied = append(applied, ruleName(rule, i))
+	}
+	return payload, applied
+}
+
+// Matches reports whether rule applies to the upstream model and client API key.
+func Matches(rule *config.SystemPromptRule, model, apiKey string) bool {
+	switch strings.ToLower(strings.TrimSpace(rule.Action)) {
+	case ActionPrepend, ActionAppend, ActionReplace:
+	default:
+		return false
+	}
+	if len(rule.Models) > 0 {
+		matched := false
+		for _, pattern := range rule.Models {
+			if util.MatchWildcard(strings.TrimSpace(pattern), model) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rule.APIKeys) > 0 {
+		for _, key := range rule.APIKeys {
+			if key != "" && key == apiKey {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func ruleName(rule *config.SystemPromptRule, index int) string {
+	if name := strings.TrimSpace(rule.Name); name != "" {
+		return name
+	}
+	return "rule-" + strconv.Itoa(index)
+}
+
+func applyRule(format string, payload []byte, action, text string) ([]byte, bool) {
+	switch format {
+	case "claude":
+		return applyClaude(payload, action, text)
+	case "openai":
+		return applyOpenAI(payload, action, text)
+	case "codex", "openai-response":
+		return applyString(payload, "instructions", action, text)
+	case "gemini", "gemini-cli", "antigravity":
+		root := ""
+		if gjson.GetBytes(payload, "request").IsObject() {
+			root = "request."
+		}
+		return applyGemini(payload, root, action, text)
+	}
+	return payload, false
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// joinPrompt combines an existing prompt with text according to action.
+func joinPrompt(existing, action, text string) string {
+	switch {
+	case action == ActionReplace:
+		return text
+	case existing == "":
+		return text
+	case text == "":
+		return existing
+	case action == ActionPrepend:
+		return text + "\n\n" + existing
+	default:
+		return existing + "\n\n" + text
+	}
+}
+
+func applyString(payload []byte, path, action, text string) ([]byte, bool) {
+	next := joinPrompt(gjson.GetBytes(payload, path).String(), action, text)
+	var (
+		updated []byte
+		err     error
+	)
+	if next == "" {
+		updated, err = sjson.DeleteBytes(payload, path)
+	} else {
+		updated, err = sjson.SetBytes(payload, path, next)
+	}
+	if err != nil {
+		return payload, false
+	}
+	return updated, true
+}
+
+// applyClaude edits the top-level system field, keeping block arrays (and their cache_control
+// markers) intact when prepending or appending.
+func applyClaude(payload []byte, action, text string) ([]byte, bool) {
+	system := gjson.GetBytes(payload, "system")
+	if !system.IsArray() || action == ActionReplace {
+		return applyString(payload, "system", action, text)
+	}
+	if text == "" {
+		return payload, false
+	}
+	block := `{"type":"text","text":` + quote(text) + `}`
+	blocks := make([]string, 0, len(system.Array())+1)
+	if action == ActionPrepend {
+		blocks = append(blocks, block)
+	}
+	for _, item := range system.Array() {
+		blocks = append(blocks, item.Raw)
+	}
+	if action == ActionAppend {
+		blocks = append(blocks, block)
+	}
+	updated, err := sjson.SetRawBytes(payload, "system", []byte("["+strings.Join(blocks, ",")+"]"))
+	if err != nil {
+		return payload, false
+	}
+	return updated, true
+}
+
+// applyOpenAI edits the leading system and developer messages of a chat completion request.
+func applyOpenAI(payload []byte, action, text string) ([]byte, bool) {
+	messages := gjson.GetBytes(payload, "messages")
+	if !messages.IsArray() {
+		return payload, false
+	}
+	items := messages.Array()
+	leading := 0
+	for leading < len(items) {
+		role := items[leading].Get("role").String()
+		if role != "system" && role != "developer" {
+			break
+		}
+		leading++
+	}
+	message := `{"role":"system","content":` + quote(text) + `}`
+	out := make([]string, 0, len(items)+1)
+	switch action {
+	case ActionPrepend:
+		if text == "" {
+			return payload, false
+		}
+		out = append(out, message)
+		for _, item := range items {
+			out = append(out, item.Raw)
+		}
+	case ActionAppend:
+		if text == "" {
+			return payload, false
+		}
+		for i, item := range items {
+			if i == leading {
+				out = append(out, message)
+			}
+			out = append(out, item.Raw)
+		}
+		if leading == len(items) {
+			out = append(out, message)
+		}
+	default:
+		if text != "" {
+			out = append(out, message)
+		}
+		for _, item := range items {
+			if role := item.Get("role").String(); role == "system" || role == "developer" {
+				continue
+			}
+			out = append(out, item.Raw)
+		}
+	}
+	updated, err := sjson.SetRawBytes(payload, "messages", []byte("["+strings.Join(out, ",")+"]"))
+	if err != nil {
+		return payload, false
+	}
+	return updated, true
+}
+
+// applyGemini edits systemInstruction.parts of a Gemini request rooted at root.
+func applyGemini(payload []byte, root, action, text string) ([]byte, bool) {
+	path := root + "systemInstruction"
+	if !gjson.GetBytes(payload, path).Exists() && gjson.GetBytes(payload, root+"system_instruction").Exists() {
+		path = root + "system_instruction"
+	}
+	part := `{"text":` + quote(text) + `}`
+	if action == ActionReplace {
+		if text == "" {
+			updated, err := sjson.DeleteBytes(payload, path)
+			if err != nil {
+				return payload, false
+			}
+			return updated, true
+		}
+		updated, err := sjson.SetRawBytes(payload, path, []byte(`{"role":"user","parts":[`+part+`]}`))
+		if err != nil {
+			return payload, false
+		}
+		return updated, true
+	}
+	if text == "" {
+		return payload, false
+	}
+	parts := gjson.GetBytes(payload, path+".parts").Array()
+	out := make([]string, 0, len(parts)+1)
+	if action == ActionPrepend {
+		out = append(out, part)
+	}
+	for _, p := range parts {
+		out = append(out, p.Raw)
+	}
+	if action == ActionAppend {
+		out = append(out, part)
+	}
+	updated, err := sjson.SetRawBytes(payload, path+".parts", []byte("["+strings.Join(out, ",")+"]"))
+	if err != nil {
+		return payload, false
+	}
+	if !gjson.GetBytes(updated, path+".role").Exists() {
+		updated, _ = sjson.SetBytes(updated, path+".role", "user")
+	}
+	return updated, true
+}
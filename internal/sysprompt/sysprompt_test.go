@@ -0,0 +1,44 @@
+package sysprompt
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyClaudeKeepsBlocks(t *testing.T) {
+	rules := []config.SystemPromptRule{
+		{Name: "preamble", Action: "prepend", Text: "policy"},
+		{Name: "other-model", Models: []string{"gpt-*"}, Action: "replace", Text: ""},
+	}
+	payload := []byte(`{"system":[{"type":"text","text":"base","cache_control":{"type":"ephemeral"}}],"messages":[]}`)
+	out, applied := Apply("claude", payload, rules, "claude-sonnet-4", "")
+	if len(applied) != 1 || applied[0] != "preamble" {
+		t.Fatalf("applied = %v, want [preamble]", applied)
+	}
+	if got := gjson.GetBytes(out, "system.0.text").String(); got != "policy" {
+		t.Fatalf("system[0] = %q, want policy", got)
+	}
+	if !gjson.GetBytes(out, "system.1.cache_control").Exists() {
+		t.Fatalf("expected the original block to keep cache_control: %s", out)
+	}
+}
+
+func TestApplyReplaceByAPIKey(t *testing.T) {
+	rules := []config.SystemPromptRule{{APIKeys: []string{"team-a"}, Action: "replace"}}
+	payload := []byte(`{"messages":[{"role":"system","content":"long"},{"role":"user","content":"hi"}]}`)
+	if out, _ := Apply("openai", payload, rules, "m", "team-b"); string(out) != string(payload) {
+		t.Fatalf("expected other keys to be unaffected, got %s", out)
+	}
+	out, _ := Apply("openai", payload, rules, "m", "team-a")
+	if roles := gjson.GetBytes(out, "messages.#.role").Array(); len(roles) != 1 || roles[0].String() != "user" {
+		t.Fatalf("expected the system prompt to be removed, got %s", out)
+	}
+
+	gemini := []byte(`{"request":{"systemInstruction":{"role":"user","parts":[{"text":"base"}]},"contents":[]}}`)
+	out, _ = Apply("gemini-cli", gemini, []config.SystemPromptRule{{Action: "append", Text: "tail"}}, "m", "")
+	if got := gjson.GetBytes(out, "request.systemInstruction.parts.1.text").String(); got != "tail" {
+		t.Fatalf("expected appended part, got %s", out)
+	}
+}
@@ -0,0 +1,382 @@
+// Package toolhistory repairs conversation histories whose tool calls and tool results no longer
+// pair up, which happens when clients retry or edit a conversation mid-tool-call. Upstreams reject
+// such requests, so orphaned results are dropped and unanswered calls receive a stub result.
+package toolhistory
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// StubResult is the content of a synthesized result for a tool call that never returned.
+const StubResult = "Tool call was interrupted before it returned a result."
+
+// Report counts the repairs made to a payload.
+type Report struct {
+	// DroppedResults is the number of tool results removed because no call matched them.
+	DroppedResults int
+	// StubbedCalls is the number of tool calls that received a synthesized result.
+	StubbedCalls int
+}
+
+// Changed reports whether any repair was made.
+func (r Report) Changed() bool {
+	return r.DroppedResults > 0 || r.StubbedCalls > 0
+}
+
+// Repair fixes the tool call history of a payload in the given upstream format ("claude",
+// "openai", "codex", "openai-response", "gemini", "gemini-cli" or "antigravity"). Payloads in
+// other formats, or without repairs to make, are returned unchanged.
+func Repair(format string, payload []byte) ([]byte, Report) {
+	switch format {
+	case "claude":
+		return repairArray(payload, "messages", repairClaude)
+	case "openai":
+		return repairArray(payload, "messages", repairOpenAI)
+	case "codex", "openai-response":
+		return repairArray(payload, "input", repairResponses)
+	case "gemini", "gemini-cli", "antigravity":
+		for _, path := range []string{"contents", "request.contents"} {
+			if gjson.GetBytes(payload, path).IsArray() {
+				return repairArray(payload, path, repairGemini)
+			}
+		}
+	}
+	return payload, Report{}
+}
+
+func repairArray(payload []byte, path string, repair func([]gjson.Result) ([]string, Report)) ([]byte, Report) {
+	items := gjson.GetBytes(payload, path)
+	if !items.IsArray() {
+		return payload, Report{}
+	}
+	out, report := repair(items.Array())
+	if !report.Changed() {
+		return payload, report
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	buf.WriteString(strings.Join(out, ","))
+	buf.WriteByte(']')
+	updated, err := sjson.SetRawBytes(payload, path, buf.Bytes())
+	if err != nil {
+		return payload, Report{}
+	}
+	return updated, report
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// repairClaude pairs tool_use blocks of an assistant message with the tool_result blocks of the
+// user message that follows it.
+func repairClaude(messages []gjson.Result) ([]string, Report) {
+	var report Report
+	out := make([]string, 0, len(messages))
+	var pending []string
+	for i, msg := range messages {
+		role := msg.Get("role").String()
+		if role != "user" {
+			if len(pending) > 0 && i > 0 {
+				// The previous assistant turn is followed by another assistant turn.
+				out = append(out, `{"role":"user","content":[`+claudeStubs(pending)+`]}`)
+				report.StubbedCalls += len(pending)
+			}
+			pending = nil
+			if role == "assistant" {
+				pending = claudeToolUseIDs(msg)
+			}
+			out = append(out, msg.Raw)
+			continue
+		}
+
+		content := msg.Get("content")
+		calls := make(map[string]bool, len(pending))
+		for _, id := range pending {
+			calls[id] = false
+		}
+		var kept []string
+		dropped := 0
+		if content.IsArray() {
+			for _, block := range content.Array() {
+				if block.Get("type").String() == "tool_result" {
+					id := block.Get("tool_use_id").String()
+					if answered, ok := calls[id]; !ok || answered {
+						dropped++
+						continue
+					}
+					calls[id] = true
+				}
+				kept = append(kept, block.Raw)
+			}
+		} else if content.Exists() {
+			kept = append(kept, `{"type":"text","text":`+quote(content.String())+`}`)
+		}
+		var missing []string
+		for _, id := range pending {
+			if !calls[id] {
+				missing = append(missing, id)
+			}
+		}
+		pending = nil
+		report.DroppedResults += dropped
+		if dropped == 0 && len(missing) == 0 {
+			out = append(out, msg.Raw)
+			continue
+		}
+		blocks := kept
+		if len(missing) > 0 {
+			report.StubbedCalls += len(missing)
+			blocks = append([]string{claudeStubs(missing)}, kept...)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		updated, err := sjson.SetRaw(msg.Raw, "content", "["+strings.Join(blocks, ",")+"]")
+		if err != nil {
+			updated = msg.Raw
+		}
+		out = append(out, updated)
+	}
+	return out, report
+}
+
+func claudeToolUseIDs(msg gjson.Result) []string {
+	var ids []string
+	msg.Get("content").ForEach(func(_, block gjson.Result) bool {
+		if block.Get("type").String() == "tool_use" {
+			if id := block.Get("id").String(); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		return true
+	})
+	return ids
+}
+
+func claudeStubs(ids []string) string {
+	stubs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		stubs = append(stubs, `{"type":"tool_result","tool_use_id":`+quote(id)+`,"content":`+quote(StubResult)+`,"is_error":true}`)
+	}
+	return strings.Join(stubs, ",")
+}
+
+// repairOpenAI pairs the tool_calls of an assistant message with the tool messages that follow it.
+func repairOpenAI(messages []gjson.Result) ([]string, Report) {
+	var report Report
+	out := make([]string, 0, len(messages))
+	for i := 0; i < len(messages); i++ {
+		msg := messages[i]
+		role := msg.Get("role").String()
+		if role == "tool" {
+			report.DroppedResults++
+			continue
+		}
+		out = append(out, msg.Raw)
+		if role != "assistant" {
+			continue
+		}
+		var ids []string
+		msg.Get("tool_calls").ForEach(func(_, call gjson.Result) bool {
+			if id := call.Get("id").String(); id != "" {
+				ids = append(ids, id)
+			}
+			return true
+		})
+		if len(ids) == 0 {
+			continue
+		}
+		answered := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			answered[id] = false
+		}
+		j := i + 1
+		for ; j < len(messages) && messages[j].Get("role").String() == "tool"; j++ {
+			id := messages[j].Get("tool_call_id").String()
+			if done, ok := answered[id]; !ok || done {
+				report.DroppedResults++
+				continue
+			}
+			answered[id] = true
+			out = append(out, messages[j].Raw)
+		}
+		if j < len(messages) || j > i+1 {
+			for _, id := range ids {
+				if !answered[id] {
+					out = append(out, `{"role":"tool","tool_call_id":`+quote(id)+`,"content":`+quote(StubResult)+`}`)
+					report.StubbedCalls++
+				}
+			}
+		}
+		i = j - 1
+	}
+	return out, report
+}
+
+// repairResponses pairs function_call items of a Responses API input with their
+// function_call_output items, matched by call_id.
+func repairResponses(items []gjson.Result) ([]string, Report) {
+	var report Report
+	called := make(map[string]bool)
+	answered := make(map[string]bool)
+	for _, item := range items {
+		typ := item.Get("type").String()
+		id := item.Get("call_id").String()
+		if id == "" {
+			continue
+		}
+		if strings.HasSuffix(typ, "_call_output") {
+			if called[id] {
+				answered[id] = true
+			}
+		} else if strings.HasSuffix(typ, "_call") {
+			called[id] = true
+		}
+	}
+	out := make([]string, 0, len(items))
+	seen := make(map[string]bool)
+	emitted := make(map[string]bool)
+	var unanswered []gjson.Result
+	flush := func() {
+		for _, call := range unanswered {
+			outputType := call.Get("type").String() + "_output"
+			out = append(out, `{"type":`+quote(outputType)+`,"call_id":`+quote(call.Get("call_id").String())+`,"output":`+quote(StubResult)+`}`)
+			report.StubbedCalls++
+		}
+		unanswered = nil
+	}
+	for _, item := range items {
+		typ := item.Get("type").String()
+		id := item.Get("call_id").String()
+		isCall := id != "" && strings.HasSuffix(typ, "_call")
+		isOutput := id != "" && strings.HasSuffix(typ, "_call_output")
+		if !isCall {
+			flush()
+		}
+		switch {
+		case isOutput:
+			if !seen[id] || emitted[id] {
+				report.DroppedResults++
+				continue
+			}
+			emitted[id] = true
+		case isCall:
+			seen[id] = true
+			if !answered[id] {
+				unanswered = append(unanswered, item)
+			}
+		}
+		out = append(out, item.Raw)
+	}
+	// Calls at the very end of the input are the turn being continued; leave them as they are.
+	return out, report
+}
+
+// repairGemini pairs the functionCall parts of a model turn with the functionResponse parts of
+// the user turn that follows it, matched by id when present and by name otherwise.
+func repairGemini(contents []gjson.Result) ([]string, Report) {
+	var report Report
+	out := make([]string, 0, len(contents))
+	var pending []gjson.Result
+	for i, turn := range contents {
+		role := turn.Get("role").String()
+		if role != "user" && role != "function" {
+			if len(pending) > 0 && i > 0 {
+				out = append(out, `{"role":"user","parts":[`+geminiStubs(pending)+`]}`)
+				report.StubbedCalls += len(pending)
+			}
+			pending = nil
+			turn.Get("parts").ForEach(func(_, part gjson.Result) bool {
+				if part.Get("functionCall").Exists() {
+					pending = append(pending, part.Get("functionCall"))
+				}
+				return true
+			})
+			out = append(out, turn.Raw)
+			continue
+		}
+
+		used := make([]bool, len(pending))
+		var kept []string
+		dropped := 0
+		turn.Get("parts").ForEach(func(_, part gjson.Result) bool {
+			response := part.Get("functionResponse")
+			if !response.Exists() {
+				kept = append(kept, part.Raw)
+				return true
+			}
+			if idx := matchGeminiCall(pending, used, response); idx >= 0 {
+				used[idx] = true
+				kept = append(kept, part.Raw)
+			} else {
+				dropped++
+			}
+			return true
+		})
+		var missing []gjson.Result
+		for idx, call := range pending {
+			if !used[idx] {
+				missing = append(missing, call)
+			}
+		}
+		pending = nil
+		report.DroppedResults += dropped
+		if dropped == 0 && len(missing) == 0 {
+			out = append(out, turn.Raw)
+			continue
+		}
+		parts := kept
+		if len(missing) > 0 {
+			report.StubbedCalls += len(missing)
+			parts = append([]string{geminiStubs(missing)}, kept...)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		updated, err := sjson.SetRaw(turn.Raw, "parts", "["+strings.Join(parts, ",")+"]")
+		if err != nil {
+			updated = turn.Raw
+		}
+		out = append(out, updated)
+	}
+	return out, report
+}
+
+func matchGeminiCall(calls []gjson.Result, used []bool, response gjson.Result) int {
+	id := response.Get("id").String()
+	name := response.Get("name").String()
+	for idx, call := range calls {
+		if used[idx] {
+			continue
+		}
+		if id != "" && call.Get("id").String() != "" {
+			if call.Get("id").String() == id {
+				return idx
+			}
+			continue
+		}
+		if call.Get("name").String() == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+func geminiStubs(calls []gjson.Result) string {
+	stubs := make([]string, 0, len(calls))
+	for _, call := range calls {
+		stub := `{"functionResponse":{"name":` + quote(call.Get("name").String()) + `,"response":{"error":` + quote(StubResult) + `}}}`
+		if id := call.Get("id").String(); id != "" {
+			stub, _ = sjson.Set(stub, "functionResponse.id", id)
+		}
+		stubs = append(stubs, stub)
+	}
+	return strings.Join(stubs, ",")
+}
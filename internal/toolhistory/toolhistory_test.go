@@ -0,0 +1,65 @@
+package toolhistory
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestRepairClaude(t *testing.T) {
+	payload := []byte(`{"messages":[` +
+		`{"role":"user","content":"list files"},` +
+		`{"role":"assistant","content":[{"type":"tool_use","id":"a","name":"ls","input":{}},{"type":"tool_use","id":"b","name":"pwd","input":{}}]},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"a","content":"x"},{"type":"tool_result","tool_use_id":"zzz","content":"stale"}]},` +
+		`{"role":"assistant","content":"done"},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"a","content":"retry"}]},` +
+		`{"role":"user","content":"next"}]}`)
+	out, report := Repair("claude", payload)
+	if report.DroppedResults != 2 || report.StubbedCalls != 1 {
+		t.Fatalf("report = %+v, want 2 dropped and 1 stubbed", report)
+	}
+	messages := gjson.GetBytes(out, "messages").Array()
+	if len(messages) != 5 {
+		t.Fatalf("expected the emptied message to be removed, got %s", gjson.GetBytes(out, "messages").Raw)
+	}
+	results := messages[2].Get("content.#.tool_use_id").Array()
+	if len(results) != 2 || results[0].String() != "b" || results[1].String() != "a" {
+		t.Fatalf("unexpected tool results: %s", messages[2].Raw)
+	}
+	if !messages[2].Get("content.0.is_error").Bool() {
+		t.Fatal("expected the stub result to be flagged as an error")
+	}
+}
+
+func TestRepairOpenAI(t *testing.T) {
+	payload := []byte(`{"messages":[` +
+		`{"role":"tool","tool_call_id":"orphan","content":"x"},` +
+		`{"role":"user","content":"hi"},` +
+		`{"role":"assistant","tool_calls":[{"id":"c1","type":"function","function":{"name":"f","arguments":"{}"}},{"id":"c2","type":"function","function":{"name":"g","arguments":"{}"}}]},` +
+		`{"role":"tool","tool_call_id":"c2","content":"ok"},` +
+		`{"role":"user","content":"again"}]}`)
+	out, report := Repair("openai", payload)
+	if report.DroppedResults != 1 || report.StubbedCalls != 1 {
+		t.Fatalf("report = %+v, want 1 dropped and 1 stubbed", report)
+	}
+	roles := gjson.GetBytes(out, "messages.#.role").Array()
+	if len(roles) != 5 || roles[0].String() != "user" || roles[4].String() != "user" {
+		t.Fatalf("unexpected messages: %s", gjson.GetBytes(out, "messages").Raw)
+	}
+	if id := gjson.GetBytes(out, "messages.3.tool_call_id").String(); id != "c1" {
+		t.Fatalf("expected stub for c1 after the real result, got %q", id)
+	}
+}
+
+func TestRepairLeavesValidHistoryUntouched(t *testing.T) {
+	payload := []byte(`{"input":[{"type":"message","role":"user","content":"hi"},{"type":"function_call","call_id":"x","name":"f","arguments":"{}"},{"type":"function_call_output","call_id":"x","output":"ok"},{"type":"function_call","call_id":"y","name":"f","arguments":"{}"}]}`)
+	out, report := Repair("codex", payload)
+	if report.Changed() || string(out) != string(payload) {
+		t.Fatalf("expected no change, got %+v: %s", report, out)
+	}
+	payload = []byte(`{"contents":[{"role":"model","parts":[{"functionCall":{"name":"f","args":{}}}]},{"role":"user","parts":[{"text":"never mind"}]}]}`)
+	out, report = Repair("gemini", payload)
+	if report.StubbedCalls != 1 || gjson.GetBytes(out, "contents.1.parts.0.functionResponse.name").String() != "f" {
+		t.Fatalf("expected a stub function response, got %+v: %s", report, out)
+	}
+}
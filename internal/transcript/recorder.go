@@ -0,0 +1,168 @@
+package transcript
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/analytics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pii"
+	log "github.com/sirupsen/logrus"
+)
+
+// queueSize bounds the transcripts waiting to be stored; overflow is dropped and logged so a
+// slow store never stalls request handling.
+const queueSize = 1024
+
+const (
+	pruneInterval = time.Hour
+	writeTimeout  = time.Minute
+)
+
+// Transcript is one retained conversation.
+type Transcript struct {
+	analytics.Record
+	// KeyID identifies the client key without revealing it.
+	KeyID string `json:"key_id,omitempty"`
+}
+
+// Key returns the storage key of the transcript.
+func (t Transcript) Key() string {
+	return t.Timestamp.UTC().Format("2006/01/02/") + t.ID + ".json"
+}
+
+// Recorder stores transcripts on a background goroutine and enforces the retention period.
+type Recorder struct {
+	store     Store
+	redactor  *pii.Redactor
+	retention time.Duration
+
+	queue   chan Transcript
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+// NewRecorder builds the store described by cfg and starts recording. piiCfg supplies the
+// detectors used when cfg.Redact is set.
+func NewRecorder(cfg config.TranscriptsConfig, piiCfg config.PIIRedactionConfig) (*Recorder, error) {
+	var redactor *pii.Redactor
+	if cfg.Redact {
+		var err error
+		if redactor, err = pii.New(piiCfg); err != nil {
+			return nil, err
+		}
+	}
+	store, err := NewStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newRecorder(store, redactor, time.Duration(cfg.RetentionDays)*24*time.Hour), nil
+}
+
+func newRecorder(store Store, redactor *pii.Redactor, retention time.Duration) *Recorder {
+	r := &Recorder{
+		store:     store,
+		redactor:  redactor,
+		retention: retention,
+		queue:     make(chan Transcript, queueSize),
+		done:      make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record queues a transcript without blocking.
+func (r *Recorder) Record(t Transcript) {
+	if r == nil {
+		return
+	}
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+	}
+	if t.Timestamp.IsZero() {
+		t.Timestamp = time.Now().UTC()
+	}
+	select {
+	case r.queue <- t:
+	default:
+		if dropped := r.dropped.Add(1); dropped == 1 || dropped%1000 == 0 {
+			log.Warnf("transcript queue full, %d transcripts dropped", dropped)
+		}
+	}
+}
+
+// Close stores the queued transcripts and closes the store.
+func (r *Recorder) Close() {
+	if r == nil {
+		return
+	}
+	r.once.Do(func() {
+		close(r.queue)
+		select {
+		case <-r.done:
+		case <-time.After(writeTimeout):
+			log.Warn("transcripts: timed out storing queued transcripts")
+		}
+		if err := r.store.Close(); err != nil {
+			log.Warnf("transcripts: close store: %v", err)
+		}
+	})
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+	var prune <-chan time.Time
+	if r.retention > 0 {
+		r.prune()
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+		prune = ticker.C
+	}
+	for {
+		select {
+		case t, ok := <-r.queue:
+			if !ok {
+				return
+			}
+			r.write(t)
+		case <-prune:
+			r.prune()
+		}
+	}
+}
+
+func (r *Recorder) write(t Transcript) {
+	if len(t.Request) == 0 || !json.Valid(t.Request) {
+		t.Request = json.RawMessage("null")
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		log.Warnf("transcripts: encode %s: %v", t.ID, err)
+		return
+	}
+	if r.redactor != nil {
+		data = r.redactor.NewSession().RedactJSON(data)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	if err = r.store.Put(ctx, t.Key(), data); err != nil {
+		log.Warnf("transcripts: store %s: %v", t.ID, err)
+	}
+}
+
+func (r *Recorder) prune() {
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	removed, err := r.store.Prune(ctx, time.Now().Add(-r.retention))
+	if err != nil {
+		log.Warnf("transcripts: prune: %v", err)
+	}
+	if removed > 0 {
+		log.Infof("transcripts: pruned %d transcripts past retention", removed)
+	}
+}
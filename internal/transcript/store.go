@@ -0,0 +1,174 @@
+// Package transcript retains complete conversations for compliance.
+//
+// Transcripts are JSON documents stored under date-partitioned keys (YYYY/MM/DD/<id>.json) in a
+// pluggable Store. A Recorder writes them asynchronously and periodically prunes the ones past
+// the retention period.
+package transcript
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// gcsEndpoint is the S3-compatible endpoint of Google Cloud Storage.
+const gcsEndpoint = "storage.googleapis.com"
+
+// Store persists transcripts by key.
+type Store interface {
+	// Put stores data under key, replacing any existing transcript.
+	Put(ctx context.Context, key string, data []byte) error
+	// Prune deletes the transcripts stored before cutoff and returns how many were removed.
+	Prune(ctx context.Context, cutoff time.Time) (int, error)
+	// Close releases the store.
+	Close() error
+}
+
+// NewStore builds the backend selected by cfg.
+func NewStore(cfg config.TranscriptsConfig) (Store, error) {
+	switch backend := strings.ToLower(strings.TrimSpace(cfg.Backend)); backend {
+	case "", "local":
+		dir := strings.TrimSpace(cfg.Directory)
+		if dir == "" {
+			dir = "transcripts"
+			if base := util.WritablePath(); base != "" {
+				dir = filepath.Join(base, dir)
+			}
+		}
+		return NewLocalStore(dir)
+	case "s3", "gcs":
+		bucket := cfg.Bucket
+		if backend == "gcs" && strings.TrimSpace(bucket.Endpoint) == "" {
+			bucket.Endpoint, bucket.UseSSL = gcsEndpoint, true
+		}
+		return NewObjectStore(bucket)
+	default:
+		return nil, fmt.Errorf("transcript: unknown backend %q", cfg.Backend)
+	}
+}
+
+// LocalStore keeps transcripts as files below a directory.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore creates dir when needed and returns a store rooted there.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("transcript: create directory: %w", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// Put writes the transcript atomically.
+func (s *LocalStore) Put(_ context.Context, key string, data []byte) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Prune removes transcripts last modified before cutoff, and the directories left empty.
+func (s *LocalStore) Prune(_ context.Context, cutoff time.Time) (int, error) {
+	removed := 0
+	var dirs []string
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != s.dir {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		info, errInfo := d.Info()
+		if errInfo != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if errRemove := os.Remove(path); errRemove != nil && !errors.Is(errRemove, fs.ErrNotExist) {
+			return errRemove
+		}
+		removed++
+		return nil
+	})
+	// Deepest directories first, so emptied parents are removed too; non-empty ones fail harmlessly.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+	return removed, err
+}
+
+// Close is a no-op for the local store.
+func (s *LocalStore) Close() error { return nil }
+
+// ObjectStore keeps transcripts as objects in an S3-compatible bucket.
+type ObjectStore struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewObjectStore connects to the bucket described by cfg.
+func NewObjectStore(cfg config.ObjectStorageConfig) (*ObjectStore, error) {
+	if strings.TrimSpace(cfg.Endpoint) == "" || strings.TrimSpace(cfg.Bucket) == "" {
+		return nil, fmt.Errorf("transcript: object storage requires endpoint and bucket")
+	}
+	options := &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	}
+	if cfg.PathStyle {
+		options.BucketLookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(cfg.Endpoint, options)
+	if err != nil {
+		return nil, fmt.Errorf("transcript: create object storage client: %w", err)
+	}
+	return &ObjectStore{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+// Put uploads the transcript.
+func (s *ObjectStore) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.prefix+key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: "application/json"})
+	return err
+}
+
+// Prune deletes objects under the prefix last modified before cutoff. Buckets with lifecycle
+// rules can leave retention-days unset and let the service expire objects instead.
+func (s *ObjectStore) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	removed := 0
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix, Recursive: true}) {
+		if object.Err != nil {
+			return removed, object.Err
+		}
+		if !object.LastModified.Before(cutoff) {
+			continue
+		}
+		if err := s.client.RemoveObject(ctx, s.bucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Close is a no-op for the object store.
+func (s *ObjectStore) Close() error { return nil }
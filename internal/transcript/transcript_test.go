@@ -0,0 +1,77 @@
+package transcript
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/analytics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pii"
+)
+
+func TestRecorderStoresRedactedTranscripts(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	redactor, err := pii.New(config.PIIRedactionConfig{Detectors: []string{"email"}})
+	if err != nil {
+		t.Fatalf("pii.New: %v", err)
+	}
+	recorder := newRecorder(store, redactor, 0)
+	at := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	recorder.Record(Transcript{
+		Record: analytics.Record{ID: "abc", Timestamp: at, Model: "m", Request: []byte(`{"prompt":"ann@example.com"}`)},
+		KeyID:  "k1",
+	})
+	recorder.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, "2026", "03", "04", "abc.json"))
+	if err != nil {
+		t.Fatalf("read transcript: %v", err)
+	}
+	if strings.Contains(string(data), "ann@example.com") || !strings.Contains(string(data), `"key_id":"k1"`) {
+		t.Fatalf("unexpected transcript: %s", data)
+	}
+}
+
+func TestLocalStorePrune(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewLocalStore(dir)
+	ctx := context.Background()
+	_ = store.Put(ctx, "2020/01/01/old.json", []byte("{}"))
+	_ = store.Put(ctx, "2026/01/01/new.json", []byte("{}"))
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "2020", "01", "01", "old.json"), old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	removed, err := store.Prune(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil || removed != 1 {
+		t.Fatalf("Prune = %d, %v; want 1, nil", removed, err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "2020")); !os.IsNotExist(err) {
+		t.Fatalf("emptied directories not removed: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "2026", "01", "01", "new.json")); err != nil {
+		t.Fatalf("recent transcript removed: %v", err)
+	}
+}
+
+func TestNewStoreBackends(t *testing.T) {
+	store, err := NewStore(config.TranscriptsConfig{Backend: "gcs", Bucket: config.ObjectStorageConfig{Bucket: "b"}})
+	if err != nil {
+		t.Fatalf("gcs backend: %v", err)
+	}
+	if got := store.(*ObjectStore).client.EndpointURL().Host; got != gcsEndpoint {
+		t.Fatalf("gcs endpoint = %q", got)
+	}
+	if _, err = NewStore(config.TranscriptsConfig{Backend: "ftp"}); err == nil {
+		t.Fatal("unknown backend accepted")
+	}
+}
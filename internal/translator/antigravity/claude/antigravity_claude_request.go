@@ -210,6 +210,8 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 							const skipSentinel = "skip_thought_signature_validator"
 							if cache.HasValidSignature(currentMessageThinkingSignature) {
 								partJSON, _ = sjson.Set(partJSON, "thoughtSignature", currentMessageThinkingSignature)
+							} else if signature := cache.GetToolCallThoughtSignature(functionID); signature != "" {
+								partJSON, _ = sjson.Set(partJSON, "thoughtSignature", signature)
 							} else {
 								// No valid signature - use skip sentinel to bypass validation
 								partJSON, _ = sjson.Set(partJSON, "thoughtSignature", skipSentinel)
@@ -324,13 +326,24 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 	// tools
 	toolsJSON := ""
 	toolDeclCount := 0
+	webSearch := false
+	codeExecution := false
 	allowedToolKeys := []string{"name", "description", "behavior", "parameters", "parametersJsonSchema", "response", "responseJsonSchema"}
 	toolsResult := gjson.GetBytes(rawJSON, "tools")
 	if toolsResult.IsArray() {
 		toolsJSON = `[{"functionDeclarations":[]}]`
 		toolsResults := toolsResult.Array()
 		for i := 0; i < len(toolsResults); i++ {
-			toolResult := toolsResults[i]
+			// Computer use, bash and text editor tools degrade to function declarations.
+			toolResult := util.ClaudeClientToolAsFunction(toolsResults[i])
+			if common.IsWebSearchTool(toolResult) {
+				webSearch = true
+				continue
+			}
+			if common.IsCodeExecutionTool(toolResult) {
+				codeExecution = true
+				continue
+			}
 			inputSchemaResult := toolResult.Get("input_schema")
 			if inputSchemaResult.Exists() && inputSchemaResult.IsObject() {
 				// Sanitize the input schema for Antigravity API compatibility
@@ -386,17 +399,35 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 	if toolDeclCount > 0 {
 		out, _ = sjson.SetRaw(out, "request.tools", toolsJSON)
 	}
+	// Claude's web search and code execution tools map onto googleSearch grounding and the
+	// codeExecution interpreter, which only Gemini models support.
+	if !strings.Contains(strings.ToLower(modelName), "claude") {
+		if webSearch {
+			out = common.AppendGoogleSearchTool(out, "request.tools")
+		}
+		if codeExecution {
+			out = common.AppendCodeExecutionTool(out, "request.tools")
+		}
+	}
+
+	// Map Anthropic tool_choice -> Gemini functionCallingConfig
+	if choice, ok := util.ParseClaudeToolChoice(gjson.GetBytes(rawJSON, "tool_choice")); ok && gjson.Get(out, "request.tools").Exists() {
+		out, _ = sjson.SetRaw(out, "request.toolConfig", choice.GeminiToolConfig())
+	}
 
 	// Map Anthropic thinking -> Gemini thinkingBudget/include_thoughts when type==enabled
 	if t := gjson.GetBytes(rawJSON, "thinking"); t.Exists() && t.IsObject() && util.ModelSupportsThinking(modelName) {
 		if t.Get("type").String() == "enabled" {
 			if b := t.Get("budget_tokens"); b.Exists() && b.Type == gjson.Number {
-				budget := int(b.Int())
+				budget := util.NormalizeThinkingBudget(modelName, int(b.Int()))
 				out, _ = sjson.Set(out, "request.generationConfig.thinkingConfig.thinkingBudget", budget)
 				out, _ = sjson.Set(out, "request.generationConfig.thinkingConfig.include_thoughts", true)
 			}
 		}
 	}
+	if stops, _ := util.LimitStopSequences(util.ParseStopSequences(gjson.GetBytes(rawJSON, "stop_sequences")), util.GeminiMaxStopSequences); len(stops) > 0 {
+		out, _ = sjson.Set(out, "request.generationConfig.stopSequences", stops)
+	}
 	if v := gjson.GetBytes(rawJSON, "temperature"); v.Exists() && v.Type == gjson.Number {
 		out, _ = sjson.Set(out, "request.generationConfig.temperature", v.Num)
 	}
@@ -407,7 +438,7 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 		out, _ = sjson.Set(out, "request.generationConfig.topK", v.Num)
 	}
 	if v := gjson.GetBytes(rawJSON, "max_tokens"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.Set(out, "request.generationConfig.maxOutputTokens", v.Num)
+		out, _ = sjson.Set(out, "request.generationConfig.maxOutputTokens", util.BoundMaxTokens(modelName, v.Int()))
 	}
 
 	outBytes := []byte(out)
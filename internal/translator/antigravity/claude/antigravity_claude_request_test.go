@@ -656,3 +656,29 @@ func TestConvertClaudeRequestToAntigravity_ToolAndThinking_NoExistingSystem(t *t
 		t.Errorf("Interleaved thinking hint should be in created systemInstruction, got: %v", sysInstruction.Raw)
 	}
 }
+
+func TestConvertClaudeRequestToAntigravity_WebSearchTool(t *testing.T) {
+	inputJSON := []byte(`{
+		"model": "claude-3-5-sonnet-20240620",
+		"messages": [{"role": "user", "content": "What's new?"}],
+		"tools": [
+			{"type": "web_search_20250305", "name": "web_search", "max_uses": 3},
+			{"name": "lookup", "input_schema": {"type": "object", "properties": {}}}
+		]
+	}`)
+
+	output := string(ConvertClaudeRequestToAntigravity("gemini-2.5-pro", inputJSON, false))
+
+	decls := gjson.Get(output, "request.tools.0.functionDeclarations").Array()
+	if len(decls) != 1 || decls[0].Get("name").String() != "lookup" {
+		t.Errorf("Expected only the lookup function declaration, got %v", decls)
+	}
+	if !gjson.Get(output, `request.tools.#(googleSearch)`).Exists() {
+		t.Errorf("Expected a googleSearch tool, got %s", gjson.Get(output, "request.tools").Raw)
+	}
+
+	output = string(ConvertClaudeRequestToAntigravity("claude-sonnet-4-5", inputJSON, false))
+	if gjson.Get(output, `request.tools.#(googleSearch)`).Exists() {
+		t.Error("googleSearch should not be sent to Claude models")
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/tidwall/gjson"
@@ -39,6 +41,10 @@ type Params struct {
 	HasSentFinalEvents   bool   // Indicates if final content/message events have been sent
 	HasToolUse           bool   // Indicates if tool use was observed in the stream
 	HasContent           bool   // Tracks whether any content (text, thinking, or tool use) has been output
+	ResponseID           string // Upstream response ID, used for synthesized web search blocks
+
+	// Grounding keeps the latest search grounding, emitted as web search blocks at the end
+	Grounding common.GroundingTracker
 
 	// Signature caching support
 	SessionID           string          // Session ID derived from request for signature caching
@@ -113,6 +119,7 @@ func ConvertAntigravityResponseToClaude(_ context.Context, _ string, originalReq
 		}
 		if responseIDResult := gjson.GetBytes(rawJSON, "response.responseId"); responseIDResult.Exists() {
 			messageStartTemplate, _ = sjson.Set(messageStartTemplate, "message.id", responseIDResult.String())
+			params.ResponseID = responseIDResult.String()
 		}
 		output = output + fmt.Sprintf("data: %s\n\n\n", messageStartTemplate)
 
@@ -257,7 +264,9 @@ func ConvertAntigravityResponseToClaude(_ context.Context, _ string, originalReq
 
 				// Create the tool use block with unique ID and function details
 				data := fmt.Sprintf(`{"type":"content_block_start","index":%d,"content_block":{"type":"tool_use","id":"","name":"","input":{}}}`, params.ResponseIndex)
-				data, _ = sjson.Set(data, "content_block.id", fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&toolUseIDCounter, 1)))
+				toolUseID := fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&toolUseIDCounter, 1))
+				common.CacheFunctionCallThoughtSignature(toolUseID, partResult)
+				data, _ = sjson.Set(data, "content_block.id", toolUseID)
 				data, _ = sjson.Set(data, "content_block.name", fcName)
 				output = output + fmt.Sprintf("data: %s\n\n\n", data)
 
@@ -272,11 +281,30 @@ func ConvertAntigravityResponseToClaude(_ context.Context, _ string, originalReq
 		}
 	}
 
+	params.Grounding.Observe(gjson.GetBytes(rawJSON, "response.candidates.0.groundingMetadata"))
+
 	if finishReasonResult := gjson.GetBytes(rawJSON, "response.candidates.0.finishReason"); finishReasonResult.Exists() {
 		params.HasFinishReason = true
 		params.FinishReason = finishReasonResult.String()
 	}
 
+	// A safety block before anything was streamed would otherwise end as an empty message;
+	// surface the explanation as text so clients can tell a policy block from an empty answer.
+	if blockReason, blockMessage, blocked := common.SafetyBlock(gjson.GetBytes(rawJSON, "response")); blocked {
+		params.HasFinishReason = true
+		params.FinishReason = blockReason
+		if !params.HasContent {
+			output = output + "event: content_block_start\n"
+			output = output + fmt.Sprintf(`data: {"type":"content_block_start","index":%d,"content_block":{"type":"text","text":""}}`, params.ResponseIndex)
+			output = output + "\n\n\n"
+			output = output + "event: content_block_delta\n"
+			data, _ := sjson.Set(fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":""}}`, params.ResponseIndex), "delta.text", blockMessage)
+			output = output + fmt.Sprintf("data: %s\n\n\n", data)
+			params.ResponseType = 1
+			params.HasContent = true
+		}
+	}
+
 	if usageResult := gjson.GetBytes(rawJSON, "response.usageMetadata"); usageResult.Exists() {
 		params.HasUsageMetadata = true
 		params.CachedTokenCount = usageResult.Get("cachedContentTokenCount").Int()
@@ -318,8 +346,14 @@ func appendFinalEvents(params *Params, output *string, force bool) {
 		*output = *output + fmt.Sprintf(`data: {"type":"content_block_stop","index":%d}`, params.ResponseIndex)
 		*output = *output + "\n\n\n"
 		params.ResponseType = 0
+		params.ResponseIndex++
 	}
 
+	// Search grounding closes the content as Claude web search blocks
+	events, blocks := common.ClaudeWebSearchEvents(params.Grounding.Metadata(), params.ResponseID, params.ResponseIndex)
+	*output = *output + events
+	params.ResponseIndex += blocks
+
 	stopReason := resolveStopReason(params)
 	usageOutputTokens := params.CandidatesTokenCount + params.ThoughtsTokenCount
 	if usageOutputTokens == 0 && params.TotalTokenCount > 0 {
@@ -350,14 +384,7 @@ func resolveStopReason(params *Params) string {
 		return "tool_use"
 	}
 
-	switch params.FinishReason {
-	case "MAX_TOKENS":
-		return "max_tokens"
-	case "STOP", "FINISH_REASON_UNSPECIFIED", "UNKNOWN":
-		return "end_turn"
-	}
-
-	return "end_turn"
+	return util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonClaude, params.FinishReason)
 }
 
 // ConvertAntigravityResponseToClaudeNonStream converts a non-streaming Gemini CLI response to a non-streaming Claude response.
@@ -415,8 +442,8 @@ func ConvertAntigravityResponseToClaudeNonStream(_ context.Context, _ string, or
 	textBuilder := strings.Builder{}
 	thinkingBuilder := strings.Builder{}
 	thinkingSignature := ""
-	toolIDCounter := 0
 	hasToolCall := false
+	codeExecution := common.NewCodeExecutionConverter(root.Get("response.responseId").String())
 
 	flushText := func() {
 		if textBuilder.Len() == 0 {
@@ -468,15 +495,24 @@ func ConvertAntigravityResponseToClaudeNonStream(_ context.Context, _ string, or
 				continue
 			}
 
+			if block, ok := codeExecution.Block(part); ok {
+				flushThinking()
+				flushText()
+				ensureContentArray()
+				responseJSON, _ = sjson.SetRaw(responseJSON, "content.-1", block)
+				continue
+			}
+
 			if functionCall := part.Get("functionCall"); functionCall.Exists() {
 				flushThinking()
 				flushText()
 				hasToolCall = true
 
 				name := functionCall.Get("name").String()
-				toolIDCounter++
 				toolBlock := `{"type":"tool_use","id":"","name":"","input":{}}`
-				toolBlock, _ = sjson.Set(toolBlock, "id", fmt.Sprintf("tool_%d", toolIDCounter))
+				toolUseID := fmt.Sprintf("%s-%d-%d", name, time.Now().UnixNano(), atomic.AddUint64(&toolUseIDCounter, 1))
+				common.CacheFunctionCallThoughtSignature(toolUseID, part)
+				toolBlock, _ = sjson.Set(toolBlock, "id", toolUseID)
 				toolBlock, _ = sjson.Set(toolBlock, "name", name)
 
 				if args := functionCall.Get("args"); args.Exists() && args.Raw != "" && gjson.Valid(args.Raw) && args.IsObject() {
@@ -498,14 +534,16 @@ func ConvertAntigravityResponseToClaudeNonStream(_ context.Context, _ string, or
 		stopReason = "tool_use"
 	} else {
 		if finish := root.Get("response.candidates.0.finishReason"); finish.Exists() {
-			switch finish.String() {
-			case "MAX_TOKENS":
-				stopReason = "max_tokens"
-			case "STOP", "FINISH_REASON_UNSPECIFIED", "UNKNOWN":
-				stopReason = "end_turn"
-			default:
-				stopReason = "end_turn"
-			}
+			stopReason = util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonClaude, finish.String())
+		}
+	}
+	// Policy blocks become a refusal; when nothing was generated the block explanation is the content.
+	if blockReason, blockMessage, blocked := common.SafetyBlock(root.Get("response")); blocked && !hasToolCall {
+		stopReason = util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonClaude, blockReason)
+		if !gjson.Get(responseJSON, "content.0").Exists() {
+			block, _ := sjson.Set(`{"type":"text","text":""}`, "text", blockMessage)
+			ensureContentArray()
+			responseJSON, _ = sjson.SetRaw(responseJSON, "content.-1", block)
 		}
 	}
 	responseJSON, _ = sjson.Set(responseJSON, "stop_reason", stopReason)
@@ -516,7 +554,12 @@ func ConvertAntigravityResponseToClaudeNonStream(_ context.Context, _ string, or
 		}
 	}
 
-	return responseJSON
+	// Surface googleSearch grounding as the web search blocks Claude clients expect.
+	responseJSON = common.PrependClaudeContent(responseJSON, common.ClaudeWebSearchBlocks(root.Get("response.candidates.0.groundingMetadata"), root.Get("response.responseId").String()))
+
+	// Stop sequences beyond Gemini's limit were not forwarded; enforce them here.
+	_, overflow := util.LimitStopSequences(util.ParseStopSequences(gjson.GetBytes(originalRequestRawJSON, "stop_sequences")), util.GeminiMaxStopSequences)
+	return util.ApplyClaudeStopSequences(responseJSON, overflow)
 }
 
 func ClaudeTokenCount(ctx context.Context, count int64) string {
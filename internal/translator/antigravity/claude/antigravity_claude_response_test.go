@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/tidwall/gjson"
 )
 
 // ============================================================================
@@ -314,3 +315,73 @@ func TestDeriveSessionIDFromRequest_DifferentMessages(t *testing.T) {
 		t.Error("Different messages should produce different session IDs")
 	}
 }
+
+func TestConvertAntigravityResponseToClaudeNonStream_WebSearchGrounding(t *testing.T) {
+	responseJSON := []byte(`{
+		"response": {
+			"responseId": "abc",
+			"candidates": [{
+				"content": {"parts": [{"text": "Go 1.24 is out."}]},
+				"finishReason": "STOP",
+				"groundingMetadata": {
+					"webSearchQueries": ["latest go release"],
+					"groundingChunks": [{"web": {"uri": "https://go.dev/doc/go1.24", "title": "go.dev"}}]
+				}
+			}]
+		}
+	}`)
+
+	out := ConvertAntigravityResponseToClaudeNonStream(context.Background(), "gemini-2.5-pro", nil, nil, responseJSON, nil)
+
+	content := gjson.Get(out, "content").Array()
+	if len(content) != 3 {
+		t.Fatalf("Expected 3 content blocks, got %d: %s", len(content), out)
+	}
+	if content[0].Get("type").String() != "server_tool_use" || content[0].Get("input.query").String() != "latest go release" {
+		t.Errorf("Unexpected server_tool_use block: %s", content[0].Raw)
+	}
+	if content[1].Get("type").String() != "web_search_tool_result" || content[1].Get("tool_use_id").String() != content[0].Get("id").String() {
+		t.Errorf("Unexpected web_search_tool_result block: %s", content[1].Raw)
+	}
+	if content[1].Get("content.0.url").String() != "https://go.dev/doc/go1.24" {
+		t.Errorf("Unexpected search result: %s", content[1].Raw)
+	}
+	if content[2].Get("text").String() != "Go 1.24 is out." {
+		t.Errorf("Unexpected text block: %s", content[2].Raw)
+	}
+}
+
+func TestConvertAntigravityResponseToClaudeNonStream_CodeExecution(t *testing.T) {
+	responseJSON := []byte(`{
+		"response": {
+			"responseId": "r1",
+			"candidates": [{
+				"content": {"parts": [
+					{"executableCode": {"language": "PYTHON", "code": "print(6*7)"}},
+					{"codeExecutionResult": {"outcome": "OUTCOME_OK", "output": "42\n"}},
+					{"text": "The answer is 42."}
+				]},
+				"finishReason": "STOP"
+			}]
+		}
+	}`)
+
+	out := ConvertAntigravityResponseToClaudeNonStream(context.Background(), "gemini-2.5-pro", nil, nil, responseJSON, nil)
+
+	content := gjson.Get(out, "content").Array()
+	if len(content) != 3 {
+		t.Fatalf("Expected 3 content blocks, got %d: %s", len(content), out)
+	}
+	if content[0].Get("type").String() != "server_tool_use" || content[0].Get("input.code").String() != "print(6*7)" {
+		t.Errorf("Unexpected server_tool_use block: %s", content[0].Raw)
+	}
+	if content[1].Get("type").String() != "code_execution_tool_result" || content[1].Get("tool_use_id").String() != content[0].Get("id").String() {
+		t.Errorf("Unexpected code_execution_tool_result block: %s", content[1].Raw)
+	}
+	if content[1].Get("content.stdout").String() != "42\n" || content[1].Get("content.return_code").Int() != 0 {
+		t.Errorf("Unexpected execution result: %s", content[1].Raw)
+	}
+	if gjson.Get(out, "stop_reason").String() != "end_turn" {
+		t.Errorf("Expected end_turn, got %s", gjson.Get(out, "stop_reason").String())
+	}
+}
@@ -11,10 +11,10 @@ func init() {
 		Claude,
 		Antigravity,
 		ConvertClaudeRequestToAntigravity,
-		interfaces.TranslateResponse{
+		translator.WithClaudePrefill(interfaces.TranslateResponse{
 			Stream:     ConvertAntigravityResponseToClaude,
 			NonStream:  ConvertAntigravityResponseToClaudeNonStream,
 			TokenCount: ClaudeTokenCount,
-		},
+		}),
 	)
 }
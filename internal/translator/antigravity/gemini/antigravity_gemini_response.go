@@ -6,10 +6,10 @@
 package gemini
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -31,8 +31,8 @@ import (
 // Returns:
 //   - []string: The transformed request data in Gemini API format
 func ConvertAntigravityResponseToGemini(ctx context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) []string {
-	if bytes.HasPrefix(rawJSON, []byte("data:")) {
-		rawJSON = bytes.TrimSpace(rawJSON[5:])
+	if payload, ok := sse.Data(rawJSON); ok {
+		rawJSON = payload
 	}
 
 	if alt, ok := ctx.Value("alt").(string); ok {
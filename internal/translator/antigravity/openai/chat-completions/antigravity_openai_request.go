@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
@@ -99,6 +100,19 @@ func ConvertOpenAIRequestToAntigravity(modelName string, inputRawJSON []byte, _
 		}
 	}
 
+	// logprobs/top_logprobs -> request.generationConfig.responseLogprobs/logprobs
+	if lp := gjson.GetBytes(rawJSON, "logprobs"); lp.Exists() && lp.Bool() {
+		out, _ = sjson.SetBytes(out, "request.generationConfig.responseLogprobs", true)
+		if top := gjson.GetBytes(rawJSON, "top_logprobs"); top.Exists() && top.Type == gjson.Number {
+			out, _ = sjson.SetBytes(out, "request.generationConfig.logprobs", top.Int())
+		}
+	}
+
+	// stop -> request.generationConfig.stopSequences
+	if stops, _ := util.LimitStopSequences(util.ParseStopSequences(gjson.GetBytes(rawJSON, "stop")), util.GeminiMaxStopSequences); len(stops) > 0 {
+		out, _ = sjson.SetBytes(out, "request.generationConfig.stopSequences", stops)
+	}
+
 	// Temperature/top_p/top_k/max_tokens
 	if tr := gjson.GetBytes(rawJSON, "temperature"); tr.Exists() && tr.Type == gjson.Number {
 		out, _ = sjson.SetBytes(out, "request.generationConfig.temperature", tr.Num)
@@ -110,7 +124,7 @@ func ConvertOpenAIRequestToAntigravity(modelName string, inputRawJSON []byte, _
 		out, _ = sjson.SetBytes(out, "request.generationConfig.topK", tkr.Num)
 	}
 	if maxTok := gjson.GetBytes(rawJSON, "max_tokens"); maxTok.Exists() && maxTok.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.maxOutputTokens", maxTok.Num)
+		out, _ = sjson.SetBytes(out, "request.generationConfig.maxOutputTokens", util.BoundMaxTokens(modelName, maxTok.Int()))
 	}
 
 	// Map OpenAI modalities -> Gemini CLI request.generationConfig.responseModalities
@@ -179,29 +193,29 @@ func ConvertOpenAIRequestToAntigravity(modelName string, inputRawJSON []byte, _
 			}
 		}
 
+		// Instructions are only sent as systemInstruction when the request also has turns, since
+		// Gemini requires contents.
+		hasTurns := false
+		for _, m := range arr {
+			if !util.IsInstructionRole(m.Get("role").String()) {
+				hasTurns = true
+				break
+			}
+		}
+
 		for i := 0; i < len(arr); i++ {
 			m := arr[i]
 			role := m.Get("role").String()
 			content := m.Get("content")
 
-			if (role == "system" || role == "developer") && len(arr) > 1 {
-				// system -> request.systemInstruction as a user message style
-				if content.Type == gjson.String {
-					out, _ = sjson.SetBytes(out, "request.systemInstruction.role", "user")
-					out, _ = sjson.SetBytes(out, "request.systemInstruction.parts.0.text", content.String())
-				} else if content.IsObject() && content.Get("type").String() == "text" {
+			if util.IsInstructionRole(role) && hasTurns {
+				// Gemini has a single systemInstruction; every instruction message adds its parts in order.
+				for _, text := range util.InstructionTexts(content) {
+					part, _ := sjson.SetBytes([]byte(`{"text":""}`), "text", text)
 					out, _ = sjson.SetBytes(out, "request.systemInstruction.role", "user")
-					out, _ = sjson.SetBytes(out, "request.systemInstruction.parts.0.text", content.Get("text").String())
-				} else if content.IsArray() {
-					contents := content.Array()
-					if len(contents) > 0 {
-						out, _ = sjson.SetBytes(out, "request.systemInstruction.role", "user")
-						for j := 0; j < len(contents); j++ {
-							out, _ = sjson.SetBytes(out, fmt.Sprintf("request.systemInstruction.parts.%d.text", j), contents[j].Get("text").String())
-						}
-					}
+					out, _ = sjson.SetRawBytes(out, "request.systemInstruction.parts.-1", part)
 				}
-			} else if role == "user" || ((role == "system" || role == "developer") && len(arr) == 1) {
+			} else if role == "user" || (util.IsInstructionRole(role) && !hasTurns) {
 				// Build single user content node to avoid splitting into multiple contents
 				node := []byte(`{"role":"user","parts":[]}`)
 				if content.Type == gjson.String {
@@ -293,7 +307,7 @@ func ConvertOpenAIRequestToAntigravity(modelName string, inputRawJSON []byte, _
 						} else {
 							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".functionCall.args.params", []byte(fargs))
 						}
-						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".thoughtSignature", geminiCLIFunctionThoughtSignature)
+						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".thoughtSignature", cache.ToolCallThoughtSignature(fid, geminiCLIFunctionThoughtSignature))
 						p++
 						if fid != "" {
 							fIDs = append(fIDs, fid)
@@ -399,12 +413,30 @@ func ConvertOpenAIRequestToAntigravity(modelName string, inputRawJSON []byte, _
 				}
 				hasTool = true
 			}
+			// OpenAI's built-in web search tools map onto googleSearch grounding.
+			if common.IsWebSearchTool(t) && !gjson.GetBytes(toolNode, "googleSearch").Exists() {
+				toolNode, _ = sjson.SetRawBytes(toolNode, "googleSearch", []byte(`{}`))
+				hasTool = true
+			}
+			// The code_interpreter tool maps onto Gemini's codeExecution interpreter.
+			if common.IsCodeExecutionTool(t) {
+				toolNode, _ = sjson.SetRawBytes(toolNode, "codeExecution", []byte(`{}`))
+				hasTool = true
+			}
 		}
 		if hasTool {
 			out, _ = sjson.SetRawBytes(out, "request.tools", []byte("[]"))
 			out, _ = sjson.SetRawBytes(out, "request.tools.0", toolNode)
 		}
 	}
+	if gjson.GetBytes(rawJSON, "web_search_options").IsObject() {
+		out = []byte(common.AppendGoogleSearchTool(string(out), "request.tools"))
+	}
+
+	// tool_choice -> request.toolConfig.functionCallingConfig
+	if choice, ok := util.ParseOpenAIToolChoice(gjson.GetBytes(rawJSON, "tool_choice")); ok && gjson.GetBytes(out, "request.tools").Exists() {
+		out, _ = sjson.SetRawBytes(out, "request.toolConfig", []byte(choice.GeminiToolConfig()))
+	}
 
 	return common.AttachDefaultSafetySettings(out, "request.safetySettings")
 }
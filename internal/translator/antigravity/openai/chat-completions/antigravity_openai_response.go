@@ -15,7 +15,9 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -24,6 +26,8 @@ import (
 type convertCliResponseToOpenAIChatParams struct {
 	UnixTimestamp int64
 	FunctionIndex int
+	// Grounding follows the answer text and groundingMetadata for url_citation annotations.
+	Grounding common.GroundingTracker
 }
 
 // functionCallIDCounter provides a process-wide unique counter for function call identifiers.
@@ -81,10 +85,22 @@ func ConvertAntigravityResponseToOpenAI(_ context.Context, _ string, originalReq
 
 	// Extract and set the finish reason.
 	if finishReasonResult := gjson.GetBytes(rawJSON, "response.candidates.0.finishReason"); finishReasonResult.Exists() {
-		template, _ = sjson.Set(template, "choices.0.finish_reason", strings.ToLower(finishReasonResult.String()))
+		template, _ = sjson.Set(template, "choices.0.finish_reason", util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonOpenAI, finishReasonResult.String()))
 		template, _ = sjson.Set(template, "choices.0.native_finish_reason", strings.ToLower(finishReasonResult.String()))
 	}
 
+	// Safety blocks are reported as a refusal rather than an empty message.
+	if blockReason, blockMessage, blocked := common.SafetyBlock(gjson.GetBytes(rawJSON, "response")); blocked {
+		template, _ = sjson.Set(template, "choices.0.finish_reason", util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonOpenAI, blockReason))
+		template, _ = sjson.Set(template, "choices.0.native_finish_reason", strings.ToLower(blockReason))
+		template, _ = sjson.Set(template, "choices.0.delta.refusal", blockMessage)
+	}
+
+	// Pass through token log probabilities when the upstream returned them.
+	if logprobs, ok := util.GeminiLogprobsToOpenAI(gjson.GetBytes(rawJSON, "response.candidates.0.logprobsResult")); ok {
+		template, _ = sjson.SetRaw(template, "choices.0.logprobs", logprobs)
+	}
+
 	// Extract and set usage metadata (token counts).
 	if usageResult := gjson.GetBytes(rawJSON, "response.usageMetadata"); usageResult.Exists() {
 		cachedTokenCount := usageResult.Get("cachedContentTokenCount").Int()
@@ -144,6 +160,7 @@ func ConvertAntigravityResponseToOpenAI(_ context.Context, _ string, originalReq
 					template, _ = sjson.Set(template, "choices.0.delta.reasoning_content", textContent)
 				} else {
 					template, _ = sjson.Set(template, "choices.0.delta.content", textContent)
+					(*param).(*convertCliResponseToOpenAIChatParams).Grounding.AddText(textContent)
 				}
 				template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
 			} else if functionCallResult.Exists() {
@@ -160,7 +177,9 @@ func ConvertAntigravityResponseToOpenAI(_ context.Context, _ string, originalReq
 
 				functionCallTemplate := `{"id": "","index": 0,"type": "function","function": {"name": "","arguments": ""}}`
 				fcName := functionCallResult.Get("name").String()
-				functionCallTemplate, _ = sjson.Set(functionCallTemplate, "id", fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&functionCallIDCounter, 1)))
+				functionCallID := fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&functionCallIDCounter, 1))
+				common.CacheFunctionCallThoughtSignature(functionCallID, partResult)
+				functionCallTemplate, _ = sjson.Set(functionCallTemplate, "id", functionCallID)
 				functionCallTemplate, _ = sjson.Set(functionCallTemplate, "index", functionCallIndex)
 				functionCallTemplate, _ = sjson.Set(functionCallTemplate, "function.name", fcName)
 				if fcArgsResult := functionCallResult.Get("args"); fcArgsResult.Exists() {
@@ -195,6 +214,15 @@ func ConvertAntigravityResponseToOpenAI(_ context.Context, _ string, originalReq
 		}
 	}
 
+	// Search grounding arrives with the final chunks; cite it once the candidate finishes.
+	grounding := &(*param).(*convertCliResponseToOpenAIChatParams).Grounding
+	grounding.Observe(gjson.GetBytes(rawJSON, "response.candidates.0.groundingMetadata"))
+	if gjson.GetBytes(rawJSON, "response.candidates.0.finishReason").Exists() {
+		if annotations := common.OpenAIURLCitations(grounding.Metadata(), grounding.Text(), true); annotations != "" {
+			template, _ = sjson.SetRaw(template, "choices.0.delta.annotations", annotations)
+		}
+	}
+
 	if hasFunctionCall {
 		template, _ = sjson.Set(template, "choices.0.finish_reason", "tool_calls")
 		template, _ = sjson.Set(template, "choices.0.native_finish_reason", "tool_calls")
@@ -51,6 +51,10 @@ func ConvertClaudeResponseToGeminiCLI(ctx context.Context, modelName string, ori
 func ConvertClaudeResponseToGeminiCLINonStream(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
 	strJSON := ConvertClaudeResponseToGeminiNonStream(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param)
 	// Wrap the converted response in a "response" object to match Gemini CLI API structure
+	if strJSON == "" {
+		// Nothing to wrap; an empty response object would not be valid JSON.
+		return ""
+	}
 	json := `{"response": {}}`
 	strJSON, _ = sjson.SetRaw(json, "response", strJSON)
 	return strJSON
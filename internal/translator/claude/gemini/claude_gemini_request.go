@@ -62,7 +62,7 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 	userID := fmt.Sprintf("user_%s_account_%s_session_%s", user, account, session)
 
 	// Base Claude message payload
-	out := fmt.Sprintf(`{"model":"","max_tokens":32000,"messages":[],"metadata":{"user_id":"%s"}}`, userID)
+	out := fmt.Sprintf(`{"model":"","max_tokens":0,"messages":[],"metadata":{"user_id":"%s"}}`, userID)
 
 	root := gjson.ParseBytes(rawJSON)
 
@@ -88,12 +88,11 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 	// Model mapping to specify which Claude Code model to use
 	out, _ = sjson.Set(out, "model", modelName)
 
+	// Max tokens bounded by the model's limit, with a per-model default when omitted
+	out, _ = sjson.Set(out, "max_tokens", util.ResolveMaxTokens(modelName, root.Get("generationConfig.maxOutputTokens").Int()))
+
 	// Generation config extraction from Gemini format
 	if genConfig := root.Get("generationConfig"); genConfig.Exists() {
-		// Max output tokens configuration
-		if maxTokens := genConfig.Get("maxOutputTokens"); maxTokens.Exists() {
-			out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
-		}
 		// Temperature setting for controlling response randomness
 		if temp := genConfig.Get("temperature"); temp.Exists() {
 			out, _ = sjson.Set(out, "temperature", temp.Float())
@@ -121,6 +120,12 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 				out, _ = sjson.Set(out, "thinking.type", "enabled")
 				normalizedBudget := util.NormalizeThinkingBudget(modelName, int(thinkingBudget.Int()))
 				out, _ = sjson.Set(out, "thinking.budget_tokens", normalizedBudget)
+			} else if level := thinkingConfig.Get("thinkingLevel"); level.Exists() {
+				// Gemini 3 clients express depth as a level rather than a budget.
+				if budget, ok := util.ThinkingLevelToBudget(level.String()); ok {
+					out, _ = sjson.Set(out, "thinking.type", "enabled")
+					out, _ = sjson.Set(out, "thinking.budget_tokens", util.NormalizeThinkingBudget(modelName, budget))
+				}
 			} else if includeThoughts := thinkingConfig.Get("include_thoughts"); includeThoughts.Exists() && includeThoughts.Type == gjson.True {
 				// Fallback to include_thoughts if no budget specified
 				out, _ = sjson.Set(out, "thinking.type", "enabled")
@@ -271,7 +276,19 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 	if tools := root.Get("tools"); tools.Exists() && tools.IsArray() {
 		var anthropicTools []interface{}
 
+		webSearch := false
+		codeExecution := false
 		tools.ForEach(func(_, tool gjson.Result) bool {
+			// Gemini's googleSearch grounding maps onto Claude's server-side web search tool.
+			if (tool.Get("googleSearch").Exists() || tool.Get("google_search").Exists()) && !webSearch {
+				webSearch = true
+				anthropicTools = append(anthropicTools, map[string]interface{}{"type": "web_search_20250305", "name": "web_search"})
+			}
+			// Gemini's codeExecution interpreter maps onto Claude's code execution tool.
+			if (tool.Get("codeExecution").Exists() || tool.Get("code_execution").Exists()) && !codeExecution {
+				codeExecution = true
+				anthropicTools = append(anthropicTools, map[string]interface{}{"type": "code_execution_20250522", "name": "code_execution"})
+			}
 			if funcDecls := tool.Get("functionDeclarations"); funcDecls.Exists() && funcDecls.IsArray() {
 				funcDecls.ForEach(func(_, funcDecl gjson.Result) bool {
 					anthropicTool := `{"name":"","description":"","input_schema":{}}`
@@ -306,22 +323,19 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 		if len(anthropicTools) > 0 {
 			out, _ = sjson.Set(out, "tools", anthropicTools)
 		}
+		if codeExecution {
+			// The executor moves body betas into the Anthropic-Beta header.
+			out, _ = sjson.Set(out, "betas.-1", "code-execution-2025-05-22")
+		}
 	}
 
 	// Tool config mapping from Gemini format to Claude Code format
-	if toolConfig := root.Get("tool_config"); toolConfig.Exists() {
-		if funcCalling := toolConfig.Get("function_calling_config"); funcCalling.Exists() {
-			if mode := funcCalling.Get("mode"); mode.Exists() {
-				switch mode.String() {
-				case "AUTO":
-					out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"auto"}`)
-				case "NONE":
-					out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"none"}`)
-				case "ANY":
-					out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"any"}`)
-				}
-			}
-		}
+	toolConfig := root.Get("toolConfig")
+	if !toolConfig.Exists() {
+		toolConfig = root.Get("tool_config")
+	}
+	if choice, ok := util.ParseGeminiToolConfig(toolConfig); ok {
+		out, _ = sjson.SetRaw(out, "tool_choice", choice.Claude())
 	}
 
 	// Stream setting configuration
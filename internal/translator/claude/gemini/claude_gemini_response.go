@@ -13,6 +13,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -37,6 +39,17 @@ type ConvertAnthropicResponseToGeminiParams struct {
 	// Keyed by content_block index from Claude SSE events
 	ToolUseNames map[int]string           // function/tool name per block index
 	ToolUseArgs  map[int]*strings.Builder // accumulates partial_json across deltas
+
+	// ServerToolUse records the names of Claude's server-side tool calls by block index. Web
+	// searches surface as groundingMetadata and code execution as executableCode parts instead of
+	// functionCall parts.
+	ServerToolUse map[int]string
+
+	// Citation tracking: bytes of response text so far, the open text block and its web
+	// citations, which surface as groundingSupports when the block ends.
+	TextLength int
+	TextBlock  strings.Builder
+	Citations  []util.Citation
 }
 
 // ConvertClaudeResponseToGemini converts Claude Code streaming response format to Gemini format.
@@ -62,10 +75,11 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 		}
 	}
 
-	if !bytes.HasPrefix(rawJSON, dataTag) {
+	payload, ok := sse.Data(rawJSON)
+	if !ok {
 		return []string{}
 	}
-	rawJSON = bytes.TrimSpace(rawJSON[5:])
+	rawJSON = payload
 
 	root := gjson.ParseBytes(rawJSON)
 	eventType := root.Get("type").String()
@@ -102,6 +116,28 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 	case "content_block_start":
 		// Start of a content block - record tool_use name by index for functionCall assembly
 		if cb := root.Get("content_block"); cb.Exists() {
+			switch cb.Get("type").String() {
+			case "server_tool_use":
+				params := (*param).(*ConvertAnthropicResponseToGeminiParams)
+				if params.ServerToolUse == nil {
+					params.ServerToolUse = map[int]string{}
+				}
+				params.ServerToolUse[int(root.Get("index").Int())] = cb.Get("name").String()
+				return []string{}
+			case "web_search_tool_result":
+				if chunks := webSearchGroundingChunks(cb); chunks != "" {
+					template, _ = sjson.SetRaw(template, "candidates.0.groundingMetadata.groundingChunks", chunks)
+					return []string{template}
+				}
+				return []string{}
+			case "code_execution_tool_result", "bash_code_execution_tool_result":
+				template, _ = sjson.SetRaw(template, "candidates.0.content.parts.-1", codeExecutionResultPart(cb))
+				return []string{template}
+			case "text":
+				params := (*param).(*ConvertAnthropicResponseToGeminiParams)
+				params.TextBlock.Reset()
+				params.Citations = nil
+			}
 			if cb.Get("type").String() == "tool_use" {
 				idx := int(root.Get("index").Int())
 				if (*param).(*ConvertAnthropicResponseToGeminiParams).ToolUseNames == nil {
@@ -126,7 +162,14 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 					textPart := `{"text":""}`
 					textPart, _ = sjson.Set(textPart, "text", text.String())
 					template, _ = sjson.SetRaw(template, "candidates.0.content.parts.-1", textPart)
+					(*param).(*ConvertAnthropicResponseToGeminiParams).TextBlock.WriteString(text.String())
 				}
+			case "citations_delta":
+				if citation, ok := util.ParseClaudeCitation(delta.Get("citation")); ok {
+					params := (*param).(*ConvertAnthropicResponseToGeminiParams)
+					params.Citations = append(params.Citations, citation)
+				}
+				return []string{}
 			case "thinking_delta":
 				// Thinking/reasoning content delta for models with reasoning capabilities
 				if text := delta.Get("thinking"); text.Exists() && text.String() != "" {
@@ -169,6 +212,31 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 				argsTrim = strings.TrimSpace(b.String())
 			}
 		}
+		if params := (*param).(*ConvertAnthropicResponseToGeminiParams); params.ServerToolUse[idx] != "" {
+			serverTool := params.ServerToolUse[idx]
+			delete(params.ServerToolUse, idx)
+			delete(params.ToolUseArgs, idx)
+			if part, ok := executableCodePart(serverTool, argsTrim); ok {
+				template, _ = sjson.SetRaw(template, "candidates.0.content.parts.-1", part)
+				return []string{template}
+			}
+			if query := gjson.Get(argsTrim, "query").String(); query != "" {
+				template, _ = sjson.Set(template, "candidates.0.groundingMetadata.webSearchQueries", []string{query})
+				return []string{template}
+			}
+			return []string{}
+		}
+		if params := (*param).(*ConvertAnthropicResponseToGeminiParams); name == "" && argsTrim == "" {
+			// End of a text block: its web citations become grounding supports
+			var chunks []string
+			support := params.citationGroundingSupport(&chunks)
+			if support == "" {
+				return []string{}
+			}
+			template, _ = sjson.SetRaw(template, "candidates.0.groundingMetadata.groundingChunks", "["+strings.Join(chunks, ",")+"]")
+			template, _ = sjson.SetRaw(template, "candidates.0.groundingMetadata.groundingSupports", "["+support+"]")
+			return []string{template}
+		}
 		if name != "" || argsTrim != "" {
 			functionCall := `{"functionCall":{"name":"","args":{}}}`
 			if name != "" {
@@ -195,17 +263,9 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 		// Handle message-level changes (like stop reason and usage information)
 		if delta := root.Get("delta"); delta.Exists() {
 			if stopReason := delta.Get("stop_reason"); stopReason.Exists() {
-				switch stopReason.String() {
-				case "end_turn":
-					template, _ = sjson.Set(template, "candidates.0.finishReason", "STOP")
-				case "tool_use":
-					template, _ = sjson.Set(template, "candidates.0.finishReason", "STOP")
-				case "max_tokens":
-					template, _ = sjson.Set(template, "candidates.0.finishReason", "MAX_TOKENS")
-				case "stop_sequence":
-					template, _ = sjson.Set(template, "candidates.0.finishReason", "STOP")
-				default:
-					template, _ = sjson.Set(template, "candidates.0.finishReason", "STOP")
+				template, _ = sjson.Set(template, "candidates.0.finishReason", util.MapFinishReason(util.FinishReasonClaude, util.FinishReasonGemini, stopReason.String()))
+				if stopReason.String() == "refusal" {
+					template, _ = sjson.Set(template, "candidates.0.finishMessage", util.DefaultRefusalMessage)
 				}
 			}
 		}
@@ -239,7 +299,9 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 			// Set traffic type (required by Gemini API)
 			template, _ = sjson.Set(template, "usageMetadata.trafficType", "PROVISIONED_THROUGHPUT")
 		}
-		template, _ = sjson.Set(template, "candidates.0.finishReason", "STOP")
+		if !gjson.Get(template, "candidates.0.finishReason").Exists() {
+			template, _ = sjson.Set(template, "candidates.0.finishReason", "STOP")
+		}
 
 		return []string{template}
 	case "message_stop":
@@ -312,6 +374,9 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 
 	// Process each streaming event and collect parts
 	var allParts []string
+	var searchQueries []string
+	var searchChunks []string
+	var searchSupports []string
 	var finalUsageJSON string
 	var responseID string
 	var createdAt int64
@@ -341,6 +406,23 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 			// Prepare for content block; record tool_use name by index for later functionCall assembly
 			idx := int(root.Get("index").Int())
 			if cb := root.Get("content_block"); cb.Exists() {
+				switch cb.Get("type").String() {
+				case "server_tool_use":
+					if newParam.ServerToolUse == nil {
+						newParam.ServerToolUse = map[int]string{}
+					}
+					newParam.ServerToolUse[idx] = cb.Get("name").String()
+				case "web_search_tool_result":
+					gjson.Parse(webSearchGroundingChunks(cb)).ForEach(func(_, chunk gjson.Result) bool {
+						searchChunks = append(searchChunks, chunk.Raw)
+						return true
+					})
+				case "code_execution_tool_result", "bash_code_execution_tool_result":
+					allParts = append(allParts, codeExecutionResultPart(cb))
+				case "text":
+					newParam.TextBlock.Reset()
+					newParam.Citations = nil
+				}
 				if cb.Get("type").String() == "tool_use" {
 					if newParam.ToolUseNames == nil {
 						newParam.ToolUseNames = map[int]string{}
@@ -363,6 +445,11 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 						partJSON := `{"text":""}`
 						partJSON, _ = sjson.Set(partJSON, "text", text.String())
 						allParts = append(allParts, partJSON)
+						newParam.TextBlock.WriteString(text.String())
+					}
+				case "citations_delta":
+					if citation, ok := util.ParseClaudeCitation(delta.Get("citation")); ok {
+						newParam.Citations = append(newParam.Citations, citation)
 					}
 				case "thinking_delta":
 					// Process reasoning/thinking content
@@ -401,6 +488,25 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 					argsTrim = strings.TrimSpace(b.String())
 				}
 			}
+			if serverTool := newParam.ServerToolUse[idx]; serverTool != "" {
+				delete(newParam.ServerToolUse, idx)
+				delete(newParam.ToolUseArgs, idx)
+				if part, ok := executableCodePart(serverTool, argsTrim); ok {
+					allParts = append(allParts, part)
+					continue
+				}
+				if query := gjson.Get(argsTrim, "query").String(); query != "" {
+					searchQueries = append(searchQueries, query)
+				}
+				continue
+			}
+			if name == "" && argsTrim == "" {
+				// End of a text block: its web citations become grounding supports
+				if support := newParam.citationGroundingSupport(&searchChunks); support != "" {
+					searchSupports = append(searchSupports, support)
+				}
+				continue
+			}
 			if name != "" || argsTrim != "" {
 				functionCallJSON := `{"functionCall":{"name":"","args":{}}}`
 				if name != "" {
@@ -420,6 +526,13 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 			}
 
 		case "message_delta":
+			if stopReason := root.Get("delta.stop_reason"); stopReason.Exists() && stopReason.String() != "" {
+				template, _ = sjson.Set(template, "candidates.0.finishReason", util.MapFinishReason(util.FinishReasonClaude, util.FinishReasonGemini, stopReason.String()))
+				if stopReason.String() == "refusal" {
+					template, _ = sjson.Set(template, "candidates.0.finishMessage", util.DefaultRefusalMessage)
+				}
+			}
+
 			// Extract final usage information using sjson for token counts and metadata
 			if usage := root.Get("usage"); usage.Exists() {
 				usageJSON := `{}`
@@ -477,6 +590,17 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 		template, _ = sjson.SetRaw(template, "candidates.0.content.parts", partsJSON)
 	}
 
+	// Surface Claude's web search results as googleSearch grounding metadata
+	if len(searchQueries) > 0 {
+		template, _ = sjson.Set(template, "candidates.0.groundingMetadata.webSearchQueries", searchQueries)
+	}
+	if len(searchChunks) > 0 {
+		template, _ = sjson.SetRaw(template, "candidates.0.groundingMetadata.groundingChunks", "["+strings.Join(searchChunks, ",")+"]")
+	}
+	if len(searchSupports) > 0 {
+		template, _ = sjson.SetRaw(template, "candidates.0.groundingMetadata.groundingSupports", "["+strings.Join(searchSupports, ",")+"]")
+	}
+
 	// Set usage metadata
 	if finalUsageJSON != "" {
 		template, _ = sjson.SetRaw(template, "usageMetadata", finalUsageJSON)
@@ -485,6 +609,65 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 	return template
 }
 
+// citationGroundingSupport closes the open text block and returns a groundingSupports entry tying
+// its web citations to the block's byte span, or "" when it has none. Cited sources are looked up
+// in chunks by URI and appended when missing.
+func (p *ConvertAnthropicResponseToGeminiParams) citationGroundingSupport(chunks *[]string) string {
+	text := p.TextBlock.String()
+	start := p.TextLength
+	p.TextLength += len(text)
+	p.TextBlock.Reset()
+	citations := p.Citations
+	p.Citations = nil
+	if len(citations) == 0 || text == "" {
+		return ""
+	}
+	var indices []int
+	for _, citation := range citations {
+		index := -1
+		for i, chunk := range *chunks {
+			if gjson.Get(chunk, "web.uri").String() == citation.URL {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			chunk := `{"web":{"uri":"","title":""}}`
+			chunk, _ = sjson.Set(chunk, "web.uri", citation.URL)
+			chunk, _ = sjson.Set(chunk, "web.title", citation.Title)
+			*chunks = append(*chunks, chunk)
+			index = len(*chunks) - 1
+		}
+		indices = append(indices, index)
+	}
+	support := `{"segment":{"startIndex":0,"endIndex":0,"text":""},"groundingChunkIndices":[]}`
+	support, _ = sjson.Set(support, "segment.startIndex", start)
+	support, _ = sjson.Set(support, "segment.endIndex", start+len(text))
+	support, _ = sjson.Set(support, "segment.text", text)
+	support, _ = sjson.Set(support, "groundingChunkIndices", indices)
+	return support
+}
+
+// webSearchGroundingChunks converts the results of a Claude web_search_tool_result block into
+// Gemini groundingChunks, or returns "" when the block carries none.
+func webSearchGroundingChunks(block gjson.Result) string {
+	chunks := "[]"
+	block.Get("content").ForEach(func(_, item gjson.Result) bool {
+		if item.Get("type").String() != "web_search_result" || item.Get("url").String() == "" {
+			return true
+		}
+		chunk := `{"web":{"uri":"","title":""}}`
+		chunk, _ = sjson.Set(chunk, "web.uri", item.Get("url").String())
+		chunk, _ = sjson.Set(chunk, "web.title", item.Get("title").String())
+		chunks, _ = sjson.SetRaw(chunks, "-1", chunk)
+		return true
+	})
+	if len(gjson.Parse(chunks).Array()) == 0 {
+		return ""
+	}
+	return chunks
+}
+
 func GeminiTokenCount(ctx context.Context, count int64) string {
 	return fmt.Sprintf(`{"totalTokens":%d,"promptTokensDetails":[{"modality":"TEXT","tokenCount":%d}]}`, count, count)
 }
@@ -564,3 +747,41 @@ func consolidateParts(parts []string) []string {
 
 	return consolidated
 }
+
+// executableCodePart converts the input of a Claude code execution call into a Gemini
+// executableCode part, reporting false for other server tools.
+func executableCodePart(name, input string) (string, bool) {
+	var code, language string
+	switch name {
+	case "code_execution":
+		code, language = gjson.Get(input, "code").String(), "PYTHON"
+	case "bash_code_execution":
+		code, language = gjson.Get(input, "command").String(), "LANGUAGE_UNSPECIFIED"
+	default:
+		return "", false
+	}
+	part := `{"executableCode":{"language":"","code":""}}`
+	part, _ = sjson.Set(part, "executableCode.language", language)
+	part, _ = sjson.Set(part, "executableCode.code", code)
+	return part, true
+}
+
+// codeExecutionResultPart converts a Claude code execution result block into a Gemini
+// codeExecutionResult part.
+func codeExecutionResultPart(block gjson.Result) string {
+	content := block.Get("content")
+	output := content.Get("stdout").String()
+	outcome := "OUTCOME_OK"
+	if content.Get("return_code").Int() != 0 || strings.HasSuffix(content.Get("type").String(), "_error") {
+		outcome = "OUTCOME_FAILED"
+		if stderr := content.Get("stderr").String(); stderr != "" {
+			output = strings.TrimSpace(output + "\n" + stderr)
+		} else if code := content.Get("error_code").String(); code != "" {
+			output = code
+		}
+	}
+	part := `{"codeExecutionResult":{"outcome":"","output":""}}`
+	part, _ = sjson.Set(part, "codeExecutionResult.outcome", outcome)
+	part, _ = sjson.Set(part, "codeExecutionResult.output", output)
+	return part
+}
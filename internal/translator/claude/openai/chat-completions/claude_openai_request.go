@@ -60,8 +60,8 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	}
 	userID := fmt.Sprintf("user_%s_account_%s_session_%s", user, account, session)
 
-	// Base Claude Code API template with default max_tokens value
-	out := fmt.Sprintf(`{"model":"","max_tokens":32000,"messages":[],"metadata":{"user_id":"%s"}}`, userID)
+	// Base Claude Code API template; max_tokens is always filled in since Claude requires it
+	out := fmt.Sprintf(`{"model":"","max_tokens":0,"messages":[],"metadata":{"user_id":"%s"}}`, userID)
 
 	root := gjson.ParseBytes(rawJSON)
 
@@ -101,10 +101,12 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	// Model mapping to specify which Claude Code model to use
 	out, _ = sjson.Set(out, "model", modelName)
 
-	// Max tokens configuration with fallback to default value
-	if maxTokens := root.Get("max_tokens"); maxTokens.Exists() {
-		out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
+	// Max tokens bounded by the model's limit, with a per-model default when omitted
+	maxTokens := root.Get("max_completion_tokens")
+	if !maxTokens.Exists() {
+		maxTokens = root.Get("max_tokens")
 	}
+	out, _ = sjson.Set(out, "max_tokens", util.ResolveMaxTokens(modelName, maxTokens.Int()))
 
 	// Temperature setting for controlling response randomness
 	if temp := root.Get("temperature"); temp.Exists() {
@@ -117,19 +119,8 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	}
 
 	// Stop sequences configuration for custom termination conditions
-	if stop := root.Get("stop"); stop.Exists() {
-		if stop.IsArray() {
-			var stopSequences []string
-			stop.ForEach(func(_, value gjson.Result) bool {
-				stopSequences = append(stopSequences, value.String())
-				return true
-			})
-			if len(stopSequences) > 0 {
-				out, _ = sjson.Set(out, "stop_sequences", stopSequences)
-			}
-		} else {
-			out, _ = sjson.Set(out, "stop_sequences", []string{stop.String()})
-		}
+	if stops := util.ParseStopSequences(root.Get("stop")); len(stops) > 0 {
+		out, _ = sjson.Set(out, "stop_sequences", stops)
 	}
 
 	// Stream configuration to enable or disable streaming responses
@@ -142,12 +133,15 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			contentResult := message.Get("content")
 
 			switch role {
-			case "system", "user", "assistant":
-				// Create Claude Code message with appropriate role mapping
-				if role == "system" {
-					role = "user"
+			case "system", "developer":
+				// Claude has a single system prompt; instructions are hoisted into it in order.
+				for _, text := range util.InstructionTexts(contentResult) {
+					block := `{"type":"text","text":""}`
+					block, _ = sjson.Set(block, "text", text)
+					out, _ = sjson.SetRaw(out, "system.-1", block)
 				}
 
+			case "user", "assistant":
 				msg := `{"role":"","content":[]}`
 				msg, _ = sjson.Set(msg, "role", role)
 
@@ -245,6 +239,19 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	if tools := root.Get("tools"); tools.Exists() && tools.IsArray() && len(tools.Array()) > 0 {
 		hasAnthropicTools := false
 		tools.ForEach(func(_, tool gjson.Result) bool {
+			if strings.HasPrefix(tool.Get("type").String(), "web_search") {
+				// OpenAI's built-in web search tools map onto Claude's server-side web search tool.
+				out, _ = sjson.SetRaw(out, "tools.-1", `{"type":"web_search_20250305","name":"web_search"}`)
+				hasAnthropicTools = true
+				return true
+			}
+			if tool.Get("type").String() == "code_interpreter" {
+				// OpenAI's code interpreter maps onto Claude's code execution tool.
+				out, _ = sjson.SetRaw(out, "tools.-1", `{"type":"code_execution_20250522","name":"code_execution"}`)
+				out, _ = sjson.Set(out, "betas.-1", "code-execution-2025-05-22")
+				hasAnthropicTools = true
+				return true
+			}
 			if tool.Get("type").String() == "function" {
 				function := tool.Get("function")
 				anthropicTool := `{"name":"","description":""}`
@@ -268,30 +275,13 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			out, _ = sjson.Delete(out, "tools")
 		}
 	}
+	if root.Get("web_search_options").IsObject() && !gjson.Get(out, `tools.#(name=="web_search")`).Exists() {
+		out, _ = sjson.SetRaw(out, "tools.-1", `{"type":"web_search_20250305","name":"web_search"}`)
+	}
 
 	// Tool choice mapping from OpenAI format to Claude Code format
-	if toolChoice := root.Get("tool_choice"); toolChoice.Exists() {
-		switch toolChoice.Type {
-		case gjson.String:
-			choice := toolChoice.String()
-			switch choice {
-			case "none":
-				// Don't set tool_choice, Claude Code will not use tools
-			case "auto":
-				out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"auto"}`)
-			case "required":
-				out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"any"}`)
-			}
-		case gjson.JSON:
-			// Specific tool choice mapping
-			if toolChoice.Get("type").String() == "function" {
-				functionName := toolChoice.Get("function.name").String()
-				toolChoiceJSON := `{"type":"tool","name":""}`
-				toolChoiceJSON, _ = sjson.Set(toolChoiceJSON, "name", functionName)
-				out, _ = sjson.SetRaw(out, "tool_choice", toolChoiceJSON)
-			}
-		default:
-		}
+	if choice, ok := util.ParseOpenAIToolChoice(root.Get("tool_choice")); ok {
+		out, _ = sjson.SetRaw(out, "tool_choice", choice.Claude())
 	}
 
 	return []byte(out)
@@ -11,7 +11,10 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -27,6 +30,10 @@ type ConvertAnthropicResponseToOpenAIParams struct {
 	FinishReason string
 	// Tool calls accumulator for streaming
 	ToolCallsAccumulator map[int]*ToolCallAccumulator
+	// Citation tracking: text length so far and the citations of the open text block
+	TextLength     int
+	TextBlockStart int
+	Citations      []util.Citation
 }
 
 // ToolCallAccumulator holds the state for accumulating tool call data
@@ -58,10 +65,11 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		}
 	}
 
-	if !bytes.HasPrefix(rawJSON, dataTag) {
+	payload, ok := sse.Data(rawJSON)
+	if !ok {
 		return []string{}
 	}
-	rawJSON = bytes.TrimSpace(rawJSON[5:])
+	rawJSON = payload
 
 	root := gjson.ParseBytes(rawJSON)
 	eventType := root.Get("type").String()
@@ -126,6 +134,12 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 				// Don't output anything yet - wait for complete tool call
 				return []string{}
 			}
+			if blockType == "text" {
+				// Citations of a text block cover the whole block
+				p := (*param).(*ConvertAnthropicResponseToOpenAIParams)
+				p.TextBlockStart = p.TextLength
+				p.Citations = nil
+			}
 		}
 		return []string{}
 
@@ -140,8 +154,16 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 				// Text content delta - send incremental text updates
 				if text := delta.Get("text"); text.Exists() {
 					template, _ = sjson.Set(template, "choices.0.delta.content", text.String())
+					(*param).(*ConvertAnthropicResponseToOpenAIParams).TextLength += utf8.RuneCountInString(text.String())
 					hasContent = true
 				}
+			case "citations_delta":
+				// Collect web citations; they are emitted as annotations when the block ends
+				if citation, ok := util.ParseClaudeCitation(delta.Get("citation")); ok {
+					p := (*param).(*ConvertAnthropicResponseToOpenAIParams)
+					p.Citations = append(p.Citations, citation)
+				}
+				return []string{}
 			case "thinking_delta":
 				// Accumulate reasoning/thinking content
 				if thinking := delta.Get("thinking"); thinking.Exists() {
@@ -190,6 +212,14 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 				return []string{template}
 			}
 		}
+		if p := (*param).(*ConvertAnthropicResponseToOpenAIParams); len(p.Citations) > 0 {
+			for i, citation := range p.Citations {
+				citation.Start, citation.End = p.TextBlockStart, p.TextLength
+				template, _ = sjson.SetRaw(template, fmt.Sprintf("choices.0.delta.annotations.%d", i), citation.OpenAIAnnotation(true))
+			}
+			p.Citations = nil
+			return []string{template}
+		}
 		return []string{}
 
 	case "message_delta":
@@ -198,6 +228,9 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 			if stopReason := delta.Get("stop_reason"); stopReason.Exists() {
 				(*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason = mapAnthropicStopReasonToOpenAI(stopReason.String())
 				template, _ = sjson.Set(template, "choices.0.finish_reason", (*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason)
+				if stopReason.String() == "refusal" {
+					template, _ = sjson.Set(template, "choices.0.delta.refusal", util.DefaultRefusalMessage)
+				}
 			}
 		}
 
@@ -240,18 +273,7 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 
 // mapAnthropicStopReasonToOpenAI maps Anthropic stop reasons to OpenAI stop reasons
 func mapAnthropicStopReasonToOpenAI(anthropicReason string) string {
-	switch anthropicReason {
-	case "end_turn":
-		return "stop"
-	case "tool_use":
-		return "tool_calls"
-	case "max_tokens":
-		return "length"
-	case "stop_sequence":
-		return "stop"
-	default:
-		return "stop"
-	}
+	return util.MapFinishReason(util.FinishReasonClaude, util.FinishReasonOpenAI, anthropicReason)
 }
 
 // ConvertClaudeResponseToOpenAINonStream converts a non-streaming Claude Code response to a non-streaming OpenAI response.
@@ -287,6 +309,9 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 	var stopReason string
 	var contentParts []string
 	var reasoningParts []string
+	var annotations []string
+	var textLength, textBlockStart int
+	var citations []util.Citation
 	toolCallsAccumulator := make(map[int]*ToolCallAccumulator)
 
 	for _, chunk := range chunks {
@@ -316,6 +341,9 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 						ID:   contentBlock.Get("id").String(),
 						Name: contentBlock.Get("name").String(),
 					}
+				} else if blockType == "text" {
+					textBlockStart = textLength
+					citations = nil
 				}
 			}
 
@@ -328,6 +356,12 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 					// Accumulate text content
 					if text := delta.Get("text"); text.Exists() {
 						contentParts = append(contentParts, text.String())
+						textLength += utf8.RuneCountInString(text.String())
+					}
+				case "citations_delta":
+					// Collect web citations of the current text block
+					if citation, ok := util.ParseClaudeCitation(delta.Get("citation")); ok {
+						citations = append(citations, citation)
 					}
 				case "thinking_delta":
 					// Accumulate reasoning/thinking content
@@ -353,6 +387,11 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 					accumulator.Arguments.WriteString("{}")
 				}
 			}
+			for _, citation := range citations {
+				citation.Start, citation.End = textBlockStart, textLength
+				annotations = append(annotations, citation.OpenAIAnnotation(true))
+			}
+			citations = nil
 
 		case "message_delta":
 			// Extract stop reason and output token count when message ends
@@ -382,6 +421,9 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 	// Set message content by combining all text parts
 	messageContent := strings.Join(contentParts, "")
 	out, _ = sjson.Set(out, "choices.0.message.content", messageContent)
+	if len(annotations) > 0 {
+		out, _ = sjson.SetRaw(out, "choices.0.message.annotations", "["+strings.Join(annotations, ",")+"]")
+	}
 
 	// Add reasoning content if available (following OpenAI reasoning format)
 	if len(reasoningParts) > 0 {
@@ -426,6 +468,9 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 		}
 	} else {
 		out, _ = sjson.Set(out, "choices.0.finish_reason", mapAnthropicStopReasonToOpenAI(stopReason))
+		if stopReason == "refusal" {
+			out, _ = sjson.Set(out, "choices.0.message.refusal", util.DefaultRefusalMessage)
+		}
 	}
 
 	return out
@@ -49,7 +49,7 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 	userID := fmt.Sprintf("user_%s_account_%s_session_%s", user, account, session)
 
 	// Base Claude message payload
-	out := fmt.Sprintf(`{"model":"","max_tokens":32000,"messages":[],"metadata":{"user_id":"%s"}}`, userID)
+	out := fmt.Sprintf(`{"model":"","max_tokens":0,"messages":[],"metadata":{"user_id":"%s"}}`, userID)
 
 	root := gjson.ParseBytes(rawJSON)
 
@@ -87,61 +87,37 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 	// Model
 	out, _ = sjson.Set(out, "model", modelName)
 
-	// Max tokens
-	if mot := root.Get("max_output_tokens"); mot.Exists() {
-		out, _ = sjson.Set(out, "max_tokens", mot.Int())
-	}
+	// Max tokens bounded by the model's limit, with a per-model default when omitted
+	out, _ = sjson.Set(out, "max_tokens", util.ResolveMaxTokens(modelName, root.Get("max_output_tokens").Int()))
 
 	// Stream
 	out, _ = sjson.Set(out, "stream", stream)
 
-	// instructions -> as a leading message (use role user for Claude API compatibility)
-	instructionsText := ""
-	extractedFromSystem := false
-	if instr := root.Get("instructions"); instr.Exists() && instr.Type == gjson.String {
-		instructionsText = instr.String()
-		if instructionsText != "" {
-			sysMsg := `{"role":"user","content":""}`
-			sysMsg, _ = sjson.Set(sysMsg, "content", instructionsText)
-			out, _ = sjson.SetRaw(out, "messages.-1", sysMsg)
+	// instructions and system/developer input items -> top-level system blocks, in order
+	addSystem := func(text string) {
+		if text == "" {
+			return
 		}
+		block := `{"type":"text","text":""}`
+		block, _ = sjson.Set(block, "text", text)
+		out, _ = sjson.SetRaw(out, "system.-1", block)
 	}
-
-	if instructionsText == "" {
-		if input := root.Get("input"); input.Exists() && input.IsArray() {
-			input.ForEach(func(_, item gjson.Result) bool {
-				if strings.EqualFold(item.Get("role").String(), "system") {
-					var builder strings.Builder
-					if parts := item.Get("content"); parts.Exists() && parts.IsArray() {
-						parts.ForEach(func(_, part gjson.Result) bool {
-							textResult := part.Get("text")
-							text := textResult.String()
-							if builder.Len() > 0 && text != "" {
-								builder.WriteByte('\n')
-							}
-							builder.WriteString(text)
-							return true
-						})
-					} else if parts.Type == gjson.String {
-						builder.WriteString(parts.String())
-					}
-					instructionsText = builder.String()
-					if instructionsText != "" {
-						sysMsg := `{"role":"user","content":""}`
-						sysMsg, _ = sjson.Set(sysMsg, "content", instructionsText)
-						out, _ = sjson.SetRaw(out, "messages.-1", sysMsg)
-						extractedFromSystem = true
-					}
-				}
-				return instructionsText == ""
-			})
-		}
+	if instr := root.Get("instructions"); instr.Exists() && instr.Type == gjson.String {
+		addSystem(instr.String())
+	}
+	if input := root.Get("input"); input.Exists() && input.IsArray() {
+		input.ForEach(func(_, item gjson.Result) bool {
+			if util.IsInstructionRole(item.Get("role").String()) {
+				addSystem(strings.Join(util.InstructionTexts(item.Get("content")), "\n"))
+			}
+			return true
+		})
 	}
 
 	// input array processing
 	if input := root.Get("input"); input.Exists() && input.IsArray() {
 		input.ForEach(func(_, item gjson.Result) bool {
-			if extractedFromSystem && strings.EqualFold(item.Get("role").String(), "system") {
+			if util.IsInstructionRole(item.Get("role").String()) {
 				return true
 			}
 			typ := item.Get("type").String()
@@ -218,7 +194,7 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 				if role == "" {
 					r := item.Get("role").String()
 					switch r {
-					case "user", "assistant", "system":
+					case "user", "assistant":
 						role = r
 					default:
 						role = "user"
@@ -239,7 +215,7 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 						}
 					}
 					out, _ = sjson.SetRaw(out, "messages.-1", msg)
-				} else if textAggregate.Len() > 0 || role == "system" {
+				} else if textAggregate.Len() > 0 {
 					msg := `{"role":"","content":""}`
 					msg, _ = sjson.Set(msg, "role", role)
 					msg, _ = sjson.Set(msg, "content", textAggregate.String())
@@ -277,6 +253,43 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 				toolResult, _ = sjson.Set(toolResult, "tool_use_id", callID)
 				toolResult, _ = sjson.Set(toolResult, "content", outputStr)
 
+				usr := `{"role":"user","content":[]}`
+				usr, _ = sjson.SetRaw(usr, "content.-1", toolResult)
+				out, _ = sjson.SetRaw(out, "messages.-1", usr)
+
+			case "computer_call":
+				// Map to an assistant tool_use of Claude's computer tool
+				callID := item.Get("call_id").String()
+				if callID == "" {
+					callID = genToolCallID()
+				}
+				toolUse := `{"type":"tool_use","id":"","name":"computer","input":{}}`
+				toolUse, _ = sjson.Set(toolUse, "id", callID)
+				toolUse, _ = sjson.SetRaw(toolUse, "input", util.OpenAIComputerActionToClaude(item.Get("action")))
+
+				asst := `{"role":"assistant","content":[]}`
+				asst, _ = sjson.SetRaw(asst, "content.-1", toolUse)
+				out, _ = sjson.SetRaw(out, "messages.-1", asst)
+
+			case "computer_call_output":
+				// Map the screenshot to a user tool_result with image content
+				toolResult := `{"type":"tool_result","tool_use_id":"","content":[]}`
+				toolResult, _ = sjson.Set(toolResult, "tool_use_id", item.Get("call_id").String())
+				url := item.Get("output.image_url").String()
+				if strings.HasPrefix(url, "data:") {
+					mediaAndData := strings.SplitN(strings.TrimPrefix(url, "data:"), ";base64,", 2)
+					if len(mediaAndData) == 2 && mediaAndData[1] != "" {
+						image := `{"type":"image","source":{"type":"base64","media_type":"","data":""}}`
+						image, _ = sjson.Set(image, "source.media_type", mediaAndData[0])
+						image, _ = sjson.Set(image, "source.data", mediaAndData[1])
+						toolResult, _ = sjson.SetRaw(toolResult, "content.-1", image)
+					}
+				} else if url != "" {
+					image := `{"type":"image","source":{"type":"url","url":""}}`
+					image, _ = sjson.Set(image, "source.url", url)
+					toolResult, _ = sjson.SetRaw(toolResult, "content.-1", image)
+				}
+
 				usr := `{"role":"user","content":[]}`
 				usr, _ = sjson.SetRaw(usr, "content.-1", toolResult)
 				out, _ = sjson.SetRaw(out, "messages.-1", usr)
@@ -289,6 +302,23 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 	if tools := root.Get("tools"); tools.Exists() && tools.IsArray() {
 		toolsJSON := "[]"
 		tools.ForEach(func(_, tool gjson.Result) bool {
+			if strings.HasPrefix(tool.Get("type").String(), "web_search") {
+				// OpenAI's built-in web search tools map onto Claude's server-side web search tool.
+				toolsJSON, _ = sjson.SetRaw(toolsJSON, "-1", `{"type":"web_search_20250305","name":"web_search"}`)
+				return true
+			}
+			if tool.Get("type").String() == "code_interpreter" {
+				// OpenAI's code interpreter maps onto Claude's code execution tool.
+				toolsJSON, _ = sjson.SetRaw(toolsJSON, "-1", `{"type":"code_execution_20250522","name":"code_execution"}`)
+				out, _ = sjson.Set(out, "betas.-1", "code-execution-2025-05-22")
+				return true
+			}
+			if util.IsOpenAIComputerTool(tool) {
+				// OpenAI's computer use tool maps onto Claude's computer tool.
+				toolsJSON, _ = sjson.SetRaw(toolsJSON, "-1", util.OpenAIComputerToolToClaude(tool))
+				out, _ = sjson.Set(out, "betas.-1", util.ClaudeComputerUseBeta)
+				return true
+			}
 			tJSON := `{"name":"","description":"","input_schema":{}}`
 			if n := tool.Get("name"); n.Exists() {
 				tJSON, _ = sjson.Set(tJSON, "name", n.String())
@@ -311,28 +341,9 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 		}
 	}
 
-	// Map tool_choice similar to Chat Completions translator (optional in docs, safe to handle)
-	if toolChoice := root.Get("tool_choice"); toolChoice.Exists() {
-		switch toolChoice.Type {
-		case gjson.String:
-			switch toolChoice.String() {
-			case "auto":
-				out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"auto"}`)
-			case "none":
-				// Leave unset; implies no tools
-			case "required":
-				out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"any"}`)
-			}
-		case gjson.JSON:
-			if toolChoice.Get("type").String() == "function" {
-				fn := toolChoice.Get("function.name").String()
-				toolChoiceJSON := `{"name":"","type":"tool"}`
-				toolChoiceJSON, _ = sjson.Set(toolChoiceJSON, "name", fn)
-				out, _ = sjson.SetRaw(out, "tool_choice", toolChoiceJSON)
-			}
-		default:
-
-		}
+	// Map tool_choice similar to Chat Completions translator
+	if choice, ok := util.ParseOpenAIToolChoice(root.Get("tool_choice")); ok {
+		out, _ = sjson.SetRaw(out, "tool_choice", choice.Claude())
 	}
 
 	return []byte(out)
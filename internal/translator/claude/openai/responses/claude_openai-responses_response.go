@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -24,8 +27,16 @@ type claudeToResponsesState struct {
 	// function call bookkeeping for output aggregation
 	FuncNames   map[int]string // index -> function name
 	FuncCallIDs map[int]string // index -> call id
+	// ComputerCalls marks tool_use blocks of the computer tool, emitted as computer_call items
+	ComputerCalls map[int]bool
 	// message text aggregation
 	TextBuf strings.Builder
+	// citation tracking: text length so far, start of the open text block, its pending
+	// citations and the annotations emitted so far
+	TextLength     int
+	TextBlockStart int
+	Citations      []util.Citation
+	Annotations    []string
 	// reasoning state
 	ReasoningActive    bool
 	ReasoningItemID    string
@@ -36,6 +47,8 @@ type claudeToResponsesState struct {
 	InputTokens  int64
 	OutputTokens int64
 	UsageSeen    bool
+	// StopReason is the Claude stop_reason, which decides whether the response is incomplete
+	StopReason string
 }
 
 var dataTag = []byte("data:")
@@ -47,15 +60,16 @@ func emitEvent(event string, payload string) string {
 // ConvertClaudeResponseToOpenAIResponses converts Claude SSE to OpenAI Responses SSE events.
 func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
-		*param = &claudeToResponsesState{FuncArgsBuf: make(map[int]*strings.Builder), FuncNames: make(map[int]string), FuncCallIDs: make(map[int]string)}
+		*param = &claudeToResponsesState{FuncArgsBuf: make(map[int]*strings.Builder), FuncNames: make(map[int]string), FuncCallIDs: make(map[int]string), ComputerCalls: make(map[int]bool)}
 	}
 	st := (*param).(*claudeToResponsesState)
 
 	// Expect `data: {..}` from Claude clients
-	if !bytes.HasPrefix(rawJSON, dataTag) {
+	payload, ok := sse.Data(rawJSON)
+	if !ok {
 		return []string{}
 	}
-	rawJSON = bytes.TrimSpace(rawJSON[5:])
+	rawJSON = payload
 	root := gjson.ParseBytes(rawJSON)
 	ev := root.Get("type").String()
 	var out []string
@@ -69,6 +83,9 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			st.CreatedAt = time.Now().Unix()
 			// Reset per-message aggregation state
 			st.TextBuf.Reset()
+			st.TextLength = 0
+			st.Citations = nil
+			st.Annotations = nil
 			st.ReasoningBuf.Reset()
 			st.ReasoningActive = false
 			st.InTextBlock = false
@@ -117,6 +134,8 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 		if typ == "text" {
 			// open message item + content part
 			st.InTextBlock = true
+			st.TextBlockStart = st.TextLength
+			st.Citations = nil
 			st.CurrentMsgID = fmt.Sprintf("msg_%s_0", st.ResponseID)
 			item := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"in_progress","content":[],"role":"assistant"}}`
 			item, _ = sjson.Set(item, "sequence_number", nextSeq())
@@ -131,13 +150,22 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			st.InFuncBlock = true
 			st.CurrentFCID = cb.Get("id").String()
 			name := cb.Get("name").String()
-			item := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"in_progress","arguments":"","call_id":"","name":""}}`
-			item, _ = sjson.Set(item, "sequence_number", nextSeq())
-			item, _ = sjson.Set(item, "output_index", idx)
-			item, _ = sjson.Set(item, "item.id", fmt.Sprintf("fc_%s", st.CurrentFCID))
-			item, _ = sjson.Set(item, "item.call_id", st.CurrentFCID)
-			item, _ = sjson.Set(item, "item.name", name)
-			out = append(out, emitEvent("response.output_item.added", item))
+			if isComputerCall(originalRequestRawJSON, name) {
+				st.ComputerCalls[idx] = true
+				item := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{}}`
+				item, _ = sjson.Set(item, "sequence_number", nextSeq())
+				item, _ = sjson.Set(item, "output_index", idx)
+				item, _ = sjson.SetRaw(item, "item", computerCallItem(st.CurrentFCID, "", "in_progress"))
+				out = append(out, emitEvent("response.output_item.added", item))
+			} else {
+				item := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"in_progress","arguments":"","call_id":"","name":""}}`
+				item, _ = sjson.Set(item, "sequence_number", nextSeq())
+				item, _ = sjson.Set(item, "output_index", idx)
+				item, _ = sjson.Set(item, "item.id", fmt.Sprintf("fc_%s", st.CurrentFCID))
+				item, _ = sjson.Set(item, "item.call_id", st.CurrentFCID)
+				item, _ = sjson.Set(item, "item.name", name)
+				out = append(out, emitEvent("response.output_item.added", item))
+			}
 			if st.FuncArgsBuf[idx] == nil {
 				st.FuncArgsBuf[idx] = &strings.Builder{}
 			}
@@ -178,6 +206,11 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 				out = append(out, emitEvent("response.output_text.delta", msg))
 				// aggregate text for response.output
 				st.TextBuf.WriteString(t.String())
+				st.TextLength += utf8.RuneCountInString(t.String())
+			}
+		} else if dt == "citations_delta" {
+			if citation, ok := util.ParseClaudeCitation(d.Get("citation")); ok {
+				st.Citations = append(st.Citations, citation)
 			}
 		} else if dt == "input_json_delta" {
 			idx := int(root.Get("index").Int())
@@ -186,6 +219,10 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 					st.FuncArgsBuf[idx] = &strings.Builder{}
 				}
 				st.FuncArgsBuf[idx].WriteString(pj.String())
+				if st.ComputerCalls[idx] {
+					// computer_call actions have no argument deltas; the action is sent when the block ends
+					return out
+				}
 				msg := `{"type":"response.function_call_arguments.delta","sequence_number":0,"item_id":"","output_index":0,"delta":""}`
 				msg, _ = sjson.Set(msg, "sequence_number", nextSeq())
 				msg, _ = sjson.Set(msg, "item_id", fmt.Sprintf("fc_%s", st.CurrentFCID))
@@ -209,6 +246,19 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 	case "content_block_stop":
 		idx := int(root.Get("index").Int())
 		if st.InTextBlock {
+			// web citations of the block become url_citation annotations spanning its text
+			for _, citation := range st.Citations {
+				citation.Start, citation.End = st.TextBlockStart, st.TextLength
+				annotation := citation.OpenAIAnnotation(false)
+				added := `{"type":"response.output_text.annotation.added","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"annotation_index":0,"annotation":{}}`
+				added, _ = sjson.Set(added, "sequence_number", nextSeq())
+				added, _ = sjson.Set(added, "item_id", st.CurrentMsgID)
+				added, _ = sjson.Set(added, "annotation_index", len(st.Annotations))
+				added, _ = sjson.SetRaw(added, "annotation", annotation)
+				out = append(out, emitEvent("response.output_text.annotation.added", added))
+				st.Annotations = append(st.Annotations, annotation)
+			}
+			st.Citations = nil
 			done := `{"type":"response.output_text.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"text":"","logprobs":[]}`
 			done, _ = sjson.Set(done, "sequence_number", nextSeq())
 			done, _ = sjson.Set(done, "item_id", st.CurrentMsgID)
@@ -222,6 +272,17 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			final, _ = sjson.Set(final, "item.id", st.CurrentMsgID)
 			out = append(out, emitEvent("response.output_item.done", final))
 			st.InTextBlock = false
+		} else if st.InFuncBlock && st.ComputerCalls[idx] {
+			args := ""
+			if buf := st.FuncArgsBuf[idx]; buf != nil {
+				args = buf.String()
+			}
+			itemDone := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{}}`
+			itemDone, _ = sjson.Set(itemDone, "sequence_number", nextSeq())
+			itemDone, _ = sjson.Set(itemDone, "output_index", idx)
+			itemDone, _ = sjson.SetRaw(itemDone, "item", computerCallItem(st.CurrentFCID, args, "completed"))
+			out = append(out, emitEvent("response.output_item.done", itemDone))
+			st.InFuncBlock = false
 		} else if st.InFuncBlock {
 			args := "{}"
 			if buf := st.FuncArgsBuf[idx]; buf != nil {
@@ -261,6 +322,9 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			st.ReasoningPartAdded = false
 		}
 	case "message_delta":
+		if v := root.Get("delta.stop_reason"); v.Exists() && v.String() != "" {
+			st.StopReason = v.String()
+		}
 		if usage := root.Get("usage"); usage.Exists() {
 			if v := usage.Get("output_tokens"); v.Exists() {
 				st.OutputTokens = v.Int()
@@ -357,6 +421,9 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			item := `{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`
 			item, _ = sjson.Set(item, "id", st.CurrentMsgID)
 			item, _ = sjson.Set(item, "content.0.text", st.TextBuf.String())
+			if len(st.Annotations) > 0 {
+				item, _ = sjson.SetRaw(item, "content.0.annotations", "["+strings.Join(st.Annotations, ",")+"]")
+			}
 			outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
 		}
 		// function_call items (in ascending index order for determinism)
@@ -384,6 +451,10 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 				if callID == "" && st.CurrentFCID != "" {
 					callID = st.CurrentFCID
 				}
+				if st.ComputerCalls[idx] {
+					outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", computerCallItem(callID, args, "completed"))
+					continue
+				}
 				item := `{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}`
 				item, _ = sjson.Set(item, "id", fmt.Sprintf("fc_%s", callID))
 				item, _ = sjson.Set(item, "arguments", args)
@@ -413,7 +484,14 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 				completed, _ = sjson.Set(completed, "response.usage.total_tokens", total)
 			}
 		}
-		out = append(out, emitEvent("response.completed", completed))
+		if reason := util.MapFinishReason(util.FinishReasonClaude, util.FinishReasonResponses, st.StopReason); reason != "" {
+			completed, _ = sjson.Set(completed, "type", "response.incomplete")
+			completed, _ = sjson.Set(completed, "response.status", "incomplete")
+			completed, _ = sjson.Set(completed, "response.incomplete_details.reason", reason)
+			out = append(out, emitEvent("response.incomplete", completed))
+		} else {
+			out = append(out, emitEvent("response.completed", completed))
+		}
 	}
 
 	return out
@@ -455,15 +533,21 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		reasoningBuf    strings.Builder
 		reasoningActive bool
 		reasoningItemID string
+		textLength      int
+		textBlockStart  int
+		citations       []util.Citation
+		annotations     []string
 		inputTokens     int64
 		outputTokens    int64
+		stopReason      string
 	)
 
 	// Per-index tool call aggregation
 	type toolState struct {
-		id   string
-		name string
-		args strings.Builder
+		id       string
+		name     string
+		args     strings.Builder
+		computer bool
 	}
 	toolCalls := make(map[int]*toolState)
 
@@ -492,6 +576,8 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 			switch typ {
 			case "text":
 				currentMsgID = "msg_" + responseID + "_0"
+				textBlockStart = textLength
+				citations = nil
 			case "tool_use":
 				currentFCID = cb.Get("id").String()
 				name := cb.Get("name").String()
@@ -501,6 +587,7 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 					toolCalls[idx].id = currentFCID
 					toolCalls[idx].name = name
 				}
+				toolCalls[idx].computer = isComputerCall(originalRequestRawJSON, name)
 			case "thinking":
 				reasoningActive = true
 				reasoningItemID = fmt.Sprintf("rs_%s_%d", responseID, idx)
@@ -516,6 +603,11 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 			case "text_delta":
 				if t := d.Get("text"); t.Exists() {
 					textBuf.WriteString(t.String())
+					textLength += utf8.RuneCountInString(t.String())
+				}
+			case "citations_delta":
+				if citation, ok := util.ParseClaudeCitation(d.Get("citation")); ok {
+					citations = append(citations, citation)
 				}
 			case "input_json_delta":
 				if pj := d.Get("partial_json"); pj.Exists() {
@@ -534,10 +626,17 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 			}
 
 		case "content_block_stop":
-			// Nothing special to finalize for non-stream aggregation
-			_ = root
+			// Web citations of a text block become url_citation annotations spanning its text
+			for _, citation := range citations {
+				citation.Start, citation.End = textBlockStart, textLength
+				annotations = append(annotations, citation.OpenAIAnnotation(false))
+			}
+			citations = nil
 
 		case "message_delta":
+			if v := root.Get("delta.stop_reason"); v.Exists() && v.String() != "" {
+				stopReason = v.String()
+			}
 			if usage := root.Get("usage"); usage.Exists() {
 				outputTokens = usage.Get("output_tokens").Int()
 			}
@@ -625,6 +724,9 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		item := `{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`
 		item, _ = sjson.Set(item, "id", currentMsgID)
 		item, _ = sjson.Set(item, "content.0.text", textBuf.String())
+		if len(annotations) > 0 {
+			item, _ = sjson.SetRaw(item, "content.0.annotations", "["+strings.Join(annotations, ",")+"]")
+		}
 		outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
 	}
 	if len(toolCalls) > 0 {
@@ -643,6 +745,10 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		for _, i := range idxs {
 			st := toolCalls[i]
 			args := st.args.String()
+			if st.computer {
+				outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", computerCallItem(st.id, args, "completed"))
+				continue
+			}
 			if args == "" {
 				args = "{}"
 			}
@@ -671,5 +777,36 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		}
 	}
 
+	if reason := util.MapFinishReason(util.FinishReasonClaude, util.FinishReasonResponses, stopReason); reason != "" {
+		out, _ = sjson.Set(out, "status", "incomplete")
+		out, _ = sjson.Set(out, "incomplete_details.reason", reason)
+	}
+
 	return out
 }
+
+// isComputerCall reports whether a Claude tool_use named name answers the computer_use_preview
+// tool of the original Responses request, and so must be returned as a computer_call item.
+func isComputerCall(originalRequestRawJSON []byte, name string) bool {
+	if name != "computer" {
+		return false
+	}
+	declared := false
+	gjson.GetBytes(originalRequestRawJSON, "tools").ForEach(func(_, tool gjson.Result) bool {
+		declared = util.IsOpenAIComputerTool(tool)
+		return !declared
+	})
+	return declared
+}
+
+// computerCallItem builds a computer_call output item from the JSON input of a Claude computer tool_use.
+func computerCallItem(callID, input, status string) string {
+	item := `{"id":"","type":"computer_call","call_id":"","action":{},"pending_safety_checks":[],"status":""}`
+	item, _ = sjson.Set(item, "id", fmt.Sprintf("cu_%s", callID))
+	item, _ = sjson.Set(item, "call_id", callID)
+	item, _ = sjson.Set(item, "status", status)
+	if input != "" {
+		item, _ = sjson.SetRaw(item, "action", util.ClaudeComputerActionToOpenAI(gjson.Parse(input)))
+	}
+	return item
+}
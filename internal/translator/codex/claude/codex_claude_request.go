@@ -46,18 +46,13 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	rootResult := gjson.ParseBytes(rawJSON)
 	template, _ = sjson.Set(template, "model", modelName)
 
-	// Process system messages and convert them to input content format.
-	systemsResult := rootResult.Get("system")
-	if systemsResult.IsArray() {
-		systemResults := systemsResult.Array()
-		message := `{"type":"message","role":"user","content":[]}`
-		for i := 0; i < len(systemResults); i++ {
-			systemResult := systemResults[i]
-			systemTypeResult := systemResult.Get("type")
-			if systemTypeResult.String() == "text" {
-				message, _ = sjson.Set(message, fmt.Sprintf("content.%d.type", i), "input_text")
-				message, _ = sjson.Set(message, fmt.Sprintf("content.%d.text", i), systemResult.Get("text").String())
-			}
+	// Process system messages and convert them to a developer message.
+	if texts := util.InstructionTexts(rootResult.Get("system")); len(texts) > 0 {
+		message := `{"type":"message","role":"developer","content":[]}`
+		for _, text := range texts {
+			part := `{"type":"input_text","text":""}`
+			part, _ = sjson.Set(part, "text", text)
+			message, _ = sjson.SetRaw(message, "content.-1", part)
 		}
 		template, _ = sjson.SetRaw(template, "input.-1", message)
 	}
@@ -174,8 +169,15 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	toolsResult := rootResult.Get("tools")
 	if toolsResult.IsArray() {
 		template, _ = sjson.SetRaw(template, "tools", `[]`)
-		template, _ = sjson.Set(template, "tool_choice", `auto`)
+		choice, ok := util.ParseClaudeToolChoice(rootResult.Get("tool_choice"))
+		if !ok {
+			choice = util.ToolChoice{Mode: util.ToolChoiceAuto}
+		}
 		toolResults := toolsResult.Array()
+		// Computer use, bash and text editor tools degrade to functions with explicit schemas.
+		for i := range toolResults {
+			toolResults[i] = util.ClaudeClientToolAsFunction(toolResults[i])
+		}
 		// Build short name map from declared tools
 		var names []string
 		for i := 0; i < len(toolResults); i++ {
@@ -211,6 +213,12 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 			tool, _ = sjson.Set(tool, "strict", false)
 			template, _ = sjson.SetRaw(template, "tools.-1", tool)
 		}
+		if short, ok := shortMap[choice.Name]; ok {
+			choice.Name = short
+		} else if choice.Name != "" {
+			choice.Name = shortenNameIfNeeded(choice.Name)
+		}
+		template, _ = sjson.SetRaw(template, "tool_choice", choice.OpenAIResponses())
 	}
 
 	// Add additional configuration parameters for the Codex API.
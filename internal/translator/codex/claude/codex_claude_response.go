@@ -7,23 +7,24 @@
 package claude
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
-var (
-	dataTag = []byte("data:")
-)
-
 // ConvertCodexResponseToClaudeParams holds parameters for response conversion.
 type ConvertCodexResponseToClaudeParams struct {
 	HasToolCall bool
 	BlockIndex  int
+
+	// PartText accumulates the current output_text part so annotation offsets can be resolved
+	// to the cited text.
+	PartText strings.Builder
 }
 
 // ConvertCodexResponseToClaude performs sophisticated streaming response format conversion.
@@ -51,10 +52,11 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 	}
 
 	// log.Debugf("rawJSON: %s", string(rawJSON))
-	if !bytes.HasPrefix(rawJSON, dataTag) {
+	payload, ok := sse.Data(rawJSON)
+	if !ok {
 		return []string{}
 	}
-	rawJSON = bytes.TrimSpace(rawJSON[5:])
+	rawJSON = payload
 
 	output := ""
 	rootResult := gjson.ParseBytes(rawJSON)
@@ -90,6 +92,7 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 		output += fmt.Sprintf("data: %s\n\n", template)
 
 	} else if typeStr == "response.content_part.added" {
+		(*param).(*ConvertCodexResponseToClaudeParams).PartText.Reset()
 		template = `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`
 		template, _ = sjson.Set(template, "index", (*param).(*ConvertCodexResponseToClaudeParams).BlockIndex)
 
@@ -99,9 +102,20 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 		template = `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":""}}`
 		template, _ = sjson.Set(template, "index", (*param).(*ConvertCodexResponseToClaudeParams).BlockIndex)
 		template, _ = sjson.Set(template, "delta.text", rootResult.Get("delta").String())
+		(*param).(*ConvertCodexResponseToClaudeParams).PartText.WriteString(rootResult.Get("delta").String())
 
 		output = "event: content_block_delta\n"
 		output += fmt.Sprintf("data: %s\n\n", template)
+	} else if typeStr == "response.output_text.annotation.added" {
+		if citation, ok := util.ParseOpenAIAnnotation(rootResult.Get("annotation")); ok {
+			text := []rune((*param).(*ConvertCodexResponseToClaudeParams).PartText.String())
+			template = `{"type":"content_block_delta","index":0,"delta":{"type":"citations_delta","citation":{}}}`
+			template, _ = sjson.Set(template, "index", (*param).(*ConvertCodexResponseToClaudeParams).BlockIndex)
+			template, _ = sjson.SetRaw(template, "delta.citation", citation.ClaudeCitation(util.CitationSpan(text, citation)))
+
+			output = "event: content_block_delta\n"
+			output += fmt.Sprintf("data: %s\n\n", template)
+		}
 	} else if typeStr == "response.content_part.done" {
 		template = `{"type":"content_block_stop","index":0}`
 		template, _ = sjson.Set(template, "index", (*param).(*ConvertCodexResponseToClaudeParams).BlockIndex)
@@ -109,13 +123,13 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 
 		output = "event: content_block_stop\n"
 		output += fmt.Sprintf("data: %s\n\n", template)
-	} else if typeStr == "response.completed" {
+	} else if typeStr == "response.completed" || typeStr == "response.incomplete" {
 		template = `{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"input_tokens":0,"output_tokens":0}}`
 		p := (*param).(*ConvertCodexResponseToClaudeParams).HasToolCall
 		if p {
 			template, _ = sjson.Set(template, "delta.stop_reason", "tool_use")
 		} else {
-			template, _ = sjson.Set(template, "delta.stop_reason", "end_turn")
+			template, _ = sjson.Set(template, "delta.stop_reason", util.MapFinishReason(util.FinishReasonResponses, util.FinishReasonClaude, rootResult.Get("response.incomplete_details.reason").String()))
 		}
 		template, _ = sjson.Set(template, "usage.input_tokens", rootResult.Get("response.usage.input_tokens").Int())
 		template, _ = sjson.Set(template, "usage.output_tokens", rootResult.Get("response.usage.output_tokens").Int())
@@ -192,7 +206,7 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 	revNames := buildReverseMapFromClaudeOriginalShortToOriginal(originalRequestRawJSON)
 
 	rootResult := gjson.ParseBytes(rawJSON)
-	if rootResult.Get("type").String() != "response.completed" {
+	if typ := rootResult.Get("type").String(); typ != "response.completed" && typ != "response.incomplete" {
 		return ""
 	}
 
@@ -256,9 +270,9 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 							if part.Get("type").String() == "output_text" {
 								text := part.Get("text").String()
 								if text != "" {
-									block := `{"type":"text","text":""}`
-									block, _ = sjson.Set(block, "text", text)
-									out, _ = sjson.SetRaw(out, "content.-1", block)
+									for _, block := range util.ClaudeTextBlocks(text, util.ParseOpenAIAnnotations(part.Get("annotations"))) {
+										out, _ = sjson.SetRaw(out, "content.-1", block)
+									}
 								}
 							}
 							return true
@@ -301,7 +315,7 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 	} else if hasToolCall {
 		out, _ = sjson.Set(out, "stop_reason", "tool_use")
 	} else {
-		out, _ = sjson.Set(out, "stop_reason", "end_turn")
+		out, _ = sjson.Set(out, "stop_reason", util.MapFinishReason(util.FinishReasonResponses, util.FinishReasonClaude, responseData.Get("incomplete_details.reason").String()))
 	}
 
 	if stopSequence := responseData.Get("stop_sequence"); stopSequence.Exists() && stopSequence.String() != "" {
@@ -11,10 +11,10 @@ func init() {
 		Claude,
 		Codex,
 		ConvertClaudeRequestToCodex,
-		interfaces.TranslateResponse{
+		translator.WithClaudePrefill(interfaces.TranslateResponse{
 			Stream:     ConvertCodexResponseToClaude,
 			NonStream:  ConvertCodexResponseToClaudeNonStream,
 			TokenCount: ClaudeTokenCount,
-		},
+		}),
 	)
 }
@@ -51,6 +51,10 @@ func ConvertCodexResponseToGeminiCLI(ctx context.Context, modelName string, orig
 func ConvertCodexResponseToGeminiCLINonStream(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
 	// log.Debug(string(rawJSON))
 	strJSON := ConvertCodexResponseToGeminiNonStream(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+	if strJSON == "" {
+		// Nothing to wrap; an empty response object would not be valid JSON.
+		return ""
+	}
 	json := `{"response": {}}`
 	strJSON, _ = sjson.SetRaw(json, "response", strJSON)
 	return strJSON
@@ -89,10 +89,10 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	// Model
 	out, _ = sjson.Set(out, "model", modelName)
 
-	// System instruction -> as a user message with input_text parts
+	// System instruction -> as a developer message with input_text parts
 	sysParts := root.Get("system_instruction.parts")
 	if sysParts.IsArray() {
-		msg := `{"type":"message","role":"user","content":[]}`
+		msg := `{"type":"message","role":"developer","content":[]}`
 		arr := sysParts.Array()
 		for i := 0; i < len(arr); i++ {
 			p := arr[i]
@@ -199,10 +199,21 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	tools := root.Get("tools")
 	if tools.IsArray() {
 		out, _ = sjson.SetRaw(out, "tools", `[]`)
-		out, _ = sjson.Set(out, "tool_choice", "auto")
+		toolConfig := root.Get("toolConfig")
+		if !toolConfig.Exists() {
+			toolConfig = root.Get("tool_config")
+		}
+		choice, ok := util.ParseGeminiToolConfig(toolConfig)
+		if !ok {
+			choice = util.ToolChoice{Mode: util.ToolChoiceAuto}
+		}
 		tarr := tools.Array()
 		for i := 0; i < len(tarr); i++ {
 			td := tarr[i]
+			// Gemini's googleSearch grounding maps onto the Responses web_search tool.
+			if td.Get("googleSearch").Exists() || td.Get("google_search").Exists() {
+				out, _ = sjson.SetRaw(out, "tools.-1", `{"type":"web_search"}`)
+			}
 			fns := td.Get("functionDeclarations")
 			if !fns.IsArray() {
 				continue
@@ -241,6 +252,12 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 				out, _ = sjson.SetRaw(out, "tools.-1", tool)
 			}
 		}
+		if short, ok := shortMap[choice.Name]; ok {
+			choice.Name = short
+		} else if choice.Name != "" {
+			choice.Name = shortenNameIfNeeded(choice.Name)
+		}
+		out, _ = sjson.SetRaw(out, "tool_choice", choice.OpenAIResponses())
 	}
 
 	// Fixed flags aligning with Codex expectations
@@ -256,6 +273,8 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 					if effort, ok := util.ThinkingBudgetToEffort(modelName, budget); ok && effort != "" {
 						reasoningEffort = effort
 					}
+				} else if level := thinkingConfig.Get("thinkingLevel"); level.Exists() && level.String() != "" {
+					reasoningEffort = strings.ToLower(strings.TrimSpace(level.String()))
 				}
 			}
 		}
@@ -5,25 +5,30 @@
 package gemini
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
-var (
-	dataTag = []byte("data:")
-)
-
 // ConvertCodexResponseToGeminiParams holds parameters for response conversion.
 type ConvertCodexResponseToGeminiParams struct {
 	Model             string
 	CreatedAt         int64
 	ResponseID        string
 	LastStorageOutput string
+
+	// Text, PartStart and Citations collect output_text annotations, which are reported as
+	// groundingMetadata on the final chunk.
+	Text      strings.Builder
+	PartStart int
+	Citations []util.Citation
 }
 
 // ConvertCodexResponseToGemini converts Codex streaming response format to Gemini format.
@@ -49,10 +54,11 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 		}
 	}
 
-	if !bytes.HasPrefix(rawJSON, dataTag) {
+	payload, ok := sse.Data(rawJSON)
+	if !ok {
 		return []string{}
 	}
-	rawJSON = bytes.TrimSpace(rawJSON[5:])
+	rawJSON = payload
 
 	rootResult := gjson.ParseBytes(rawJSON)
 	typeResult := rootResult.Get("type")
@@ -120,7 +126,25 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 		part := `{"text":""}`
 		part, _ = sjson.Set(part, "text", rootResult.Get("delta").String())
 		template, _ = sjson.SetRaw(template, "candidates.0.content.parts.-1", part)
-	} else if typeStr == "response.completed" { // Handle response completion with usage metadata
+		(*param).(*ConvertCodexResponseToGeminiParams).Text.WriteString(rootResult.Get("delta").String())
+	} else if typeStr == "response.content_part.added" { // Annotation offsets are relative to the part
+		p := (*param).(*ConvertCodexResponseToGeminiParams)
+		p.PartStart = utf8.RuneCountInString(p.Text.String())
+		return []string{}
+	} else if typeStr == "response.output_text.annotation.added" {
+		p := (*param).(*ConvertCodexResponseToGeminiParams)
+		if citation, ok := util.ParseOpenAIAnnotation(rootResult.Get("annotation")); ok {
+			citation.Start += p.PartStart
+			citation.End += p.PartStart
+			p.Citations = append(p.Citations, citation)
+		}
+		return []string{}
+	} else if typeStr == "response.completed" || typeStr == "response.incomplete" { // Handle response completion with usage metadata
+		template, _ = sjson.Set(template, "candidates.0.finishReason", util.MapFinishReason(util.FinishReasonResponses, util.FinishReasonGemini, rootResult.Get("response.incomplete_details.reason").String()))
+		p := (*param).(*ConvertCodexResponseToGeminiParams)
+		if metadata := util.GeminiGroundingMetadata(p.Citations, p.Text.String()); metadata != "" {
+			template, _ = sjson.SetRaw(template, "candidates.0.groundingMetadata", metadata)
+		}
 		template, _ = sjson.Set(template, "usageMetadata.promptTokenCount", rootResult.Get("response.usage.input_tokens").Int())
 		template, _ = sjson.Set(template, "usageMetadata.candidatesTokenCount", rootResult.Get("response.usage.output_tokens").Int())
 		totalTokens := rootResult.Get("response.usage.input_tokens").Int() + rootResult.Get("response.usage.output_tokens").Int()
@@ -153,8 +177,8 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
 	rootResult := gjson.ParseBytes(rawJSON)
 
-	// Verify this is a response.completed event
-	if rootResult.Get("type").String() != "response.completed" {
+	// Verify this is a response.completed or response.incomplete event
+	if typ := rootResult.Get("type").String(); typ != "response.completed" && typ != "response.incomplete" {
 		return ""
 	}
 
@@ -191,6 +215,8 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 		// Process output content to build parts array
 		hasToolCall := false
 		var pendingFunctionCalls []string
+		var text strings.Builder
+		var citations []util.Citation
 
 		flushPendingFunctionCalls := func() {
 			if len(pendingFunctionCalls) == 0 {
@@ -228,10 +254,18 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 					if content := value.Get("content"); content.Exists() && content.IsArray() {
 						content.ForEach(func(_, contentItem gjson.Result) bool {
 							if contentItem.Get("type").String() == "output_text" {
-								if text := contentItem.Get("text"); text.Exists() {
+								if textResult := contentItem.Get("text"); textResult.Exists() {
 									part := `{"text":""}`
-									part, _ = sjson.Set(part, "text", text.String())
+									part, _ = sjson.Set(part, "text", textResult.String())
 									template, _ = sjson.SetRaw(template, "candidates.0.content.parts.-1", part)
+
+									partStart := utf8.RuneCountInString(text.String())
+									for _, citation := range util.ParseOpenAIAnnotations(contentItem.Get("annotations")) {
+										citation.Start += partStart
+										citation.End += partStart
+										citations = append(citations, citation)
+									}
+									text.WriteString(textResult.String())
 								}
 							}
 							return true
@@ -268,11 +302,15 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 			flushPendingFunctionCalls()
 		}
 
+		if metadata := util.GeminiGroundingMetadata(citations, text.String()); metadata != "" {
+			template, _ = sjson.SetRaw(template, "candidates.0.groundingMetadata", metadata)
+		}
+
 		// Set finish reason based on whether there were tool calls
 		if hasToolCall {
 			template, _ = sjson.Set(template, "candidates.0.finishReason", "STOP")
 		} else {
-			template, _ = sjson.Set(template, "candidates.0.finishReason", "STOP")
+			template, _ = sjson.Set(template, "candidates.0.finishReason", util.MapFinishReason(util.FinishReasonResponses, util.FinishReasonGemini, responseData.Get("incomplete_details.reason").String()))
 		}
 	}
 	return template
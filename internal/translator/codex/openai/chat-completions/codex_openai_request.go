@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -139,8 +140,9 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 				// Handle regular messages
 				msg := `{}`
 				msg, _ = sjson.Set(msg, "type", "message")
-				if role == "system" {
-					msg, _ = sjson.Set(msg, "role", "user")
+				if util.IsInstructionRole(role) {
+					// Codex reserves instructions for its own prompt; client instructions stay in place.
+					msg, _ = sjson.Set(msg, "role", "developer")
 				} else {
 					msg, _ = sjson.Set(msg, "role", role)
 				}
@@ -312,6 +314,11 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 		}
 	}
 
+	// Chat Completions enables search through web_search_options; the Responses API uses a tool.
+	if gjson.GetBytes(rawJSON, "web_search_options").IsObject() && !gjson.Get(out, `tools.#(type%"web_search*")`).Exists() {
+		out, _ = sjson.SetRaw(out, "tools.-1", `{"type":"web_search"}`)
+	}
+
 	// Map tool_choice when present.
 	// Chat Completions: "tool_choice" can be a string ("auto"/"none") or an object (e.g. {"type":"function","function":{"name":"..."}}).
 	// Responses API: keep built-in tool choices as-is; flatten function choice to {"type":"function","name":"..."}.
@@ -6,18 +6,15 @@
 package chat_completions
 
 import (
-	"bytes"
 	"context"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
-var (
-	dataTag = []byte("data:")
-)
-
 // ConvertCliToOpenAIParams holds parameters for response conversion.
 type ConvertCliToOpenAIParams struct {
 	ResponseID        string
@@ -50,10 +47,11 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 		}
 	}
 
-	if !bytes.HasPrefix(rawJSON, dataTag) {
+	payload, ok := sse.Data(rawJSON)
+	if !ok {
 		return []string{}
 	}
-	rawJSON = bytes.TrimSpace(rawJSON[5:])
+	rawJSON = payload
 
 	// Initialize the OpenAI SSE template.
 	template := `{"id":"","object":"chat.completion.chunk","created":12345,"model":"model","choices":[{"index":0,"delta":{"role":null,"content":null,"reasoning_content":null,"tool_calls":null},"finish_reason":null,"native_finish_reason":null}]}`
@@ -108,8 +106,16 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 			template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
 			template, _ = sjson.Set(template, "choices.0.delta.content", deltaResult.String())
 		}
-	} else if dataType == "response.completed" {
-		finishReason := "stop"
+	} else if dataType == "response.output_text.annotation.added" {
+		citation, ok := util.ParseOpenAIAnnotation(rootResult.Get("annotation"))
+		if !ok {
+			return []string{}
+		}
+		template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
+		template, _ = sjson.SetRaw(template, "choices.0.delta.annotations", "[]")
+		template, _ = sjson.SetRaw(template, "choices.0.delta.annotations.-1", citation.OpenAIAnnotation(true))
+	} else if dataType == "response.completed" || dataType == "response.incomplete" {
+		finishReason := util.MapFinishReason(util.FinishReasonResponses, util.FinishReasonOpenAI, rootResult.Get("response.incomplete_details.reason").String())
 		if (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex != -1 {
 			finishReason = "tool_calls"
 		}
@@ -166,8 +172,8 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 //   - string: An OpenAI-compatible JSON response containing all message content and metadata
 func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
 	rootResult := gjson.ParseBytes(rawJSON)
-	// Verify this is a response.completed event
-	if rootResult.Get("type").String() != "response.completed" {
+	// Verify this is a response.completed or response.incomplete event
+	if typ := rootResult.Get("type").String(); typ != "response.completed" && typ != "response.incomplete" {
 		return ""
 	}
 
@@ -215,6 +221,7 @@ func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, original
 	if outputResult.IsArray() {
 		outputArray := outputResult.Array()
 		var contentText string
+		var annotations []util.Citation
 		var reasoningText string
 		var toolCalls []string
 
@@ -240,6 +247,7 @@ func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, original
 					for _, contentItem := range contentArray {
 						if contentItem.Get("type").String() == "output_text" {
 							contentText = contentItem.Get("text").String()
+							annotations = util.ParseOpenAIAnnotations(contentItem.Get("annotations"))
 							break
 						}
 					}
@@ -273,6 +281,9 @@ func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, original
 		if contentText != "" {
 			template, _ = sjson.Set(template, "choices.0.message.content", contentText)
 			template, _ = sjson.Set(template, "choices.0.message.role", "assistant")
+			for _, citation := range annotations {
+				template, _ = sjson.SetRaw(template, "choices.0.message.annotations.-1", citation.OpenAIAnnotation(true))
+			}
 		}
 
 		if reasoningText != "" {
@@ -292,10 +303,14 @@ func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, original
 
 	// Extract and set the finish reason based on status
 	if statusResult := responseResult.Get("status"); statusResult.Exists() {
-		status := statusResult.String()
-		if status == "completed" {
-			template, _ = sjson.Set(template, "choices.0.finish_reason", "stop")
-			template, _ = sjson.Set(template, "choices.0.native_finish_reason", "stop")
+		switch status := statusResult.String(); status {
+		case "completed", "incomplete":
+			finishReason := util.MapFinishReason(util.FinishReasonResponses, util.FinishReasonOpenAI, responseResult.Get("incomplete_details.reason").String())
+			if gjson.Get(template, "choices.0.message.tool_calls").IsArray() {
+				finishReason = "tool_calls"
+			}
+			template, _ = sjson.Set(template, "choices.0.finish_reason", finishReason)
+			template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReason)
 		}
 	}
 
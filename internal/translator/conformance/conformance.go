@@ -0,0 +1,258 @@
+// Package conformance replays upstream responses through the registered translators and compares
+// the client-facing output with golden fixtures.
+//
+// Each testdata file covers one registered pair and is named <client>_<provider>.json. A case
+// holds the client request, the exact inputs the provider's executor hands to the translator
+// (one element per stream call, or the single body for non-streaming requests) and the expected
+// output chunks. Identifiers and timestamps are normalized before comparison and in saved
+// goldens. New providers add their fixtures with the upstream inputs and an empty "expected",
+// then run the "conformance -update" subcommand to fill in the goldens for review.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// DefaultDir is the fixture directory relative to the repository root.
+const DefaultDir = "internal/translator/conformance/testdata"
+
+// volatileKeys hold generated identifiers and timestamps that differ between runs.
+var volatileKeys = map[string]bool{
+	"id": true, "created": true, "created_at": true, "createdAt": true, "createTime": true, "responseId": true,
+	"call_id": true, "item_id": true, "tool_call_id": true, "tool_use_id": true, "system_fingerprint": true,
+	"user_id": true,
+}
+
+const volatileValue = "<volatile>"
+
+// Fixture holds the cases for one client/provider pair.
+type Fixture struct {
+	Description string `json:"description,omitempty"`
+	// Client is the client-facing format, the first argument of translator.Register.
+	Client string `json:"client"`
+	// Provider is the upstream format, the second argument of translator.Register.
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Cases    []Case `json:"cases"`
+
+	path string
+}
+
+// Case is one request/response exchange.
+type Case struct {
+	Name    string          `json:"name"`
+	Stream  bool            `json:"stream"`
+	Request json.RawMessage `json:"request"`
+	// Upstream lists the translator inputs exactly as the provider executor passes them.
+	Upstream []string `json:"upstream"`
+	// Expected lists the normalized translator outputs.
+	Expected []string `json:"expected"`
+}
+
+// Result is the outcome of one case.
+type Result struct {
+	Fixture string
+	Case    string
+	Diff    string
+	Updated bool
+}
+
+// Failed reports whether the case output differs from its golden.
+func (r Result) Failed() bool { return r.Diff != "" && !r.Updated }
+
+// LoadDir reads every fixture in dir.
+func LoadDir(dir string) ([]*Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	fixtures := make([]*Fixture, 0, len(paths))
+	for _, path := range paths {
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			return nil, errRead
+		}
+		fixture := &Fixture{path: path}
+		if errParse := json.Unmarshal(data, fixture); errParse != nil {
+			return nil, fmt.Errorf("conformance: parse %s: %w", path, errParse)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+// Name is the fixture file name without extension.
+func (f *Fixture) Name() string {
+	return strings.TrimSuffix(filepath.Base(f.path), filepath.Ext(f.path))
+}
+
+// Save writes the fixture back to its file.
+func (f *Fixture) Save() error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(f); err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, buf.Bytes(), 0o644)
+}
+
+// FileName returns the fixture file name for a registered pair.
+func FileName(client, provider string) string {
+	return client + "_" + provider + ".json"
+}
+
+// Run translates the case's upstream inputs and returns the normalized outputs.
+func (f *Fixture) Run(c Case) []string {
+	// Executors pass the client's alt query value; SSE streaming, the common case, is empty.
+	ctx := context.WithValue(context.Background(), "alt", "")
+	client := sdktranslator.FromString(f.Client)
+	provider := sdktranslator.FromString(f.Provider)
+	original := []byte(c.Request)
+	translated := sdktranslator.TranslateRequest(client, provider, f.Model, original, c.Stream)
+
+	var outputs []string
+	var param any
+	for _, input := range c.Upstream {
+		if c.Stream {
+			for _, chunk := range sdktranslator.TranslateStream(ctx, provider, client, f.Model, original, translated, []byte(input), &param) {
+				outputs = append(outputs, Normalize(chunk))
+			}
+			continue
+		}
+		outputs = append(outputs, Normalize(sdktranslator.TranslateNonStream(ctx, provider, client, f.Model, original, translated, []byte(input), &param)))
+	}
+	return outputs
+}
+
+// Check runs every case in dir. With update set, differing goldens are rewritten instead of
+// reported as failures. filter, when non-empty, limits the run to fixtures or cases whose name
+// contains it.
+func Check(dir string, update bool, filter string) ([]Result, error) {
+	fixtures, err := LoadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, fixture := range fixtures {
+		changed := false
+		for i, c := range fixture.Cases {
+			if filter != "" && !strings.Contains(fixture.Name(), filter) && !strings.Contains(c.Name, filter) {
+				continue
+			}
+			actual := fixture.Run(c)
+			result := Result{Fixture: fixture.Name(), Case: c.Name, Diff: Diff(c.Expected, actual)}
+			if result.Diff != "" && update {
+				fixture.Cases[i].Expected = actual
+				result.Updated = true
+				changed = true
+			}
+			results = append(results, result)
+		}
+		if changed {
+			if errSave := fixture.Save(); errSave != nil {
+				return results, errSave
+			}
+		}
+	}
+	return results, nil
+}
+
+// Normalize replaces volatile values in a translator output. Outputs may be a JSON document, SSE
+// text whose data lines carry JSON, or binary data, which is base64 encoded.
+func Normalize(output string) string {
+	if !utf8.ValidString(output) {
+		// Binary bodies such as synthesized audio are compared by their encoding.
+		return "base64:" + base64.StdEncoding.EncodeToString([]byte(output))
+	}
+	if gjson.Valid(output) {
+		return normalizeJSON(output)
+	}
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		payload, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		trimmed := strings.TrimSpace(payload)
+		if gjson.Valid(trimmed) {
+			lines[i] = "data: " + normalizeJSON(trimmed)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func normalizeJSON(doc string) string {
+	var paths []string
+	collectVolatile(gjson.Parse(doc), "", &paths)
+	for _, path := range paths {
+		if updated, err := sjson.Set(doc, path, volatileValue); err == nil {
+			doc = updated
+		}
+	}
+	return doc
+}
+
+func collectVolatile(value gjson.Result, prefix string, paths *[]string) {
+	if !value.IsObject() && !value.IsArray() {
+		return
+	}
+	index := 0
+	value.ForEach(func(key, child gjson.Result) bool {
+		var path string
+		if value.IsArray() {
+			path = joinPath(prefix, fmt.Sprint(index))
+			index++
+		} else {
+			path = joinPath(prefix, escapeKey(key.String()))
+			if volatileKeys[key.String()] && (child.Type == gjson.String || child.Type == gjson.Number) && child.String() != "" {
+				*paths = append(*paths, path)
+				return true
+			}
+		}
+		collectVolatile(child, path, paths)
+		return true
+	})
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func escapeKey(key string) string {
+	return strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`, "|", `\|`, "#", `\#`, "@", `\@`).Replace(key)
+}
+
+// Diff describes the first difference between expected and actual outputs, or returns "" when
+// they match.
+func Diff(expected, actual []string) string {
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		switch {
+		case i >= len(expected):
+			return fmt.Sprintf("unexpected extra chunk %d:\n  %s", i, actual[i])
+		case i >= len(actual):
+			return fmt.Sprintf("missing chunk %d:\n  %s", i, expected[i])
+		case expected[i] != actual[i]:
+			return fmt.Sprintf("chunk %d differs:\n  want %s\n  got  %s", i, expected[i], actual[i])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package conformance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func TestGoldenFixtures(t *testing.T) {
+	results, err := Check("testdata", false, "")
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(results) == 0 {
+		t.Fatal("no conformance cases found")
+	}
+	for _, result := range results {
+		if result.Failed() {
+			t.Errorf("%s/%s differs from golden (regenerate with \"conformance -update\" after review):\n%s", result.Fixture, result.Case, result.Diff)
+		}
+	}
+}
+
+func TestEveryPairHasFixture(t *testing.T) {
+	for _, pair := range sdktranslator.Pairs() {
+		name := FileName(pair.From.String(), pair.To.String())
+		if _, err := os.Stat(filepath.Join("testdata", name)); err != nil {
+			t.Errorf("registered pair %s -> %s has no fixture %s", pair.From, pair.To, name)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		`{"id":"chatcmpl-9","created":17,"choices":[{"delta":{"tool_calls":[{"id":"call_1"}]}}]}`: `{"id":"<volatile>","created":"<volatile>","choices":[{"delta":{"tool_calls":[{"id":"<volatile>"}]}}]}`,
+		"event: message_start\ndata: {\"message\":{\"id\":\"msg_1\"}}\n\n":                        "event: message_start\ndata: {\"message\":{\"id\":\"<volatile>\"}}\n\n",
+		`{"id":""}`:    `{"id":""}`,
+		"data: [DONE]": "data: [DONE]",
+		"\xff\x00":     "base64:/wA=",
+	}
+	for input, want := range cases {
+		if got := Normalize(input); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
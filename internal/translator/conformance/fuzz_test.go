@@ -0,0 +1,128 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// The fuzz targets feed arbitrary upstream bytes through every translator pair that has a
+// fixture and require that translation neither panics nor emits malformed JSON. The fixtures'
+// upstream inputs seed the corpus. Run one with, for example:
+//
+//	go test ./internal/translator/conformance -run '^$' -fuzz FuzzStreamTranslators -fuzztime 1m
+
+func FuzzStreamTranslators(f *testing.F) {
+	// Only pairs with stream cases: the media pairs have no stream translator.
+	var fixtures []*Fixture
+	for _, fixture := range loadFuzzFixtures(f) {
+		streams := false
+		for _, c := range fixture.Cases {
+			if c.Stream {
+				f.Add(uint8(len(fixtures)), []byte(strings.Join(c.Upstream, "\n")))
+				streams = true
+			}
+		}
+		if streams {
+			fixtures = append(fixtures, fixture)
+		}
+	}
+	f.Fuzz(func(t *testing.T, index uint8, data []byte) {
+		fixture := fixtures[int(index)%len(fixtures)]
+		client, provider, request := fuzzPair(fixture, true)
+		translated := sdktranslator.TranslateRequest(client, provider, fixture.Model, request, true)
+
+		ctx := context.WithValue(context.Background(), "alt", "")
+		var param any
+		for _, input := range bytes.Split(data, []byte("\n")) {
+			for _, chunk := range sdktranslator.TranslateStream(ctx, provider, client, fixture.Model, request, translated, input, &param) {
+				if err := validOutput(chunk); err != nil {
+					t.Fatalf("%s: input %q produced invalid chunk %q: %v", fixture.Name(), input, chunk, err)
+				}
+			}
+		}
+	})
+}
+
+func FuzzNonStreamTranslators(f *testing.F) {
+	fixtures := loadFuzzFixtures(f)
+	for i, fixture := range fixtures {
+		for _, c := range fixture.Cases {
+			if !c.Stream && len(c.Upstream) > 0 {
+				f.Add(uint8(i), []byte(c.Upstream[0]))
+			}
+		}
+	}
+	f.Fuzz(func(t *testing.T, index uint8, data []byte) {
+		fixture := fixtures[int(index)%len(fixtures)]
+		client, provider, request := fuzzPair(fixture, false)
+		translated := sdktranslator.TranslateRequest(client, provider, fixture.Model, request, false)
+
+		ctx := context.WithValue(context.Background(), "alt", "")
+		var param any
+		out := sdktranslator.TranslateNonStream(ctx, provider, client, fixture.Model, request, translated, data, &param)
+		if fixture.Client == constant.OpenAISpeech {
+			// Speech responses are audio bytes or a JSON error.
+			return
+		}
+		if out == string(data) && !gjson.ValidBytes(data) {
+			// Non-JSON upstream bodies may be returned untouched.
+			return
+		}
+		if err := validOutput(out); err != nil {
+			t.Fatalf("%s: input %q produced invalid output %q: %v", fixture.Name(), data, out, err)
+		}
+	})
+}
+
+func loadFuzzFixtures(f *testing.F) []*Fixture {
+	fixtures, err := LoadDir("testdata")
+	if err != nil {
+		f.Fatalf("LoadDir: %v", err)
+	}
+	if len(fixtures) == 0 {
+		f.Fatal("no fixtures found")
+	}
+	return fixtures
+}
+
+// fuzzPair returns the fixture's formats and the client request of its first case matching
+// stream, so request-dependent translator paths are exercised.
+func fuzzPair(fixture *Fixture, stream bool) (sdktranslator.Format, sdktranslator.Format, []byte) {
+	request := fixture.Cases[0].Request
+	for _, c := range fixture.Cases {
+		if c.Stream == stream {
+			request = c.Request
+			break
+		}
+	}
+	return sdktranslator.FromString(fixture.Client), sdktranslator.FromString(fixture.Provider), []byte(request)
+}
+
+// validOutput accepts an empty chunk, a JSON document, or SSE text whose data lines carry JSON
+// or the [DONE] marker.
+func validOutput(chunk string) error {
+	if strings.TrimSpace(chunk) == "" || gjson.Valid(chunk) {
+		return nil
+	}
+	for _, line := range strings.Split(chunk, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", strings.HasPrefix(line, ":"), strings.HasPrefix(line, "event:"):
+		case strings.HasPrefix(line, "data:"):
+			payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if payload != "[DONE]" && !gjson.Valid(payload) {
+				return fmt.Errorf("data line is not JSON: %q", payload)
+			}
+		default:
+			return fmt.Errorf("unexpected line %q", line)
+		}
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
@@ -96,7 +97,7 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 						argsResult := gjson.Parse(functionArgs)
 						if argsResult.IsObject() && gjson.Valid(functionArgs) {
 							part := `{"thoughtSignature":"","functionCall":{"name":"","args":{}}}`
-							part, _ = sjson.Set(part, "thoughtSignature", geminiCLIClaudeThoughtSignature)
+							part, _ = sjson.Set(part, "thoughtSignature", cache.ToolCallThoughtSignature(contentResult.Get("id").String(), geminiCLIClaudeThoughtSignature))
 							part, _ = sjson.Set(part, "functionCall.name", functionName)
 							part, _ = sjson.SetRaw(part, "functionCall.args", functionArgs)
 							contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", part)
@@ -134,7 +135,19 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 	// tools
 	if toolsResult := gjson.GetBytes(rawJSON, "tools"); toolsResult.IsArray() {
 		hasTools := false
+		webSearch := false
+		codeExecution := false
 		toolsResult.ForEach(func(_, toolResult gjson.Result) bool {
+			// Computer use, bash and text editor tools degrade to function declarations.
+			toolResult = util.ClaudeClientToolAsFunction(toolResult)
+			if common.IsWebSearchTool(toolResult) {
+				webSearch = true
+				return true
+			}
+			if common.IsCodeExecutionTool(toolResult) {
+				codeExecution = true
+				return true
+			}
 			inputSchemaResult := toolResult.Get("input_schema")
 			if inputSchemaResult.Exists() && inputSchemaResult.IsObject() {
 				inputSchema := inputSchemaResult.Raw
@@ -157,18 +170,34 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 		if !hasTools {
 			out, _ = sjson.Delete(out, "request.tools")
 		}
+		// Claude's web search tool maps onto Gemini's googleSearch grounding.
+		if webSearch {
+			out = common.AppendGoogleSearchTool(out, "request.tools")
+		}
+		// Claude's code execution tool maps onto Gemini's codeExecution interpreter.
+		if codeExecution {
+			out = common.AppendCodeExecutionTool(out, "request.tools")
+		}
+	}
+
+	// Map Anthropic tool_choice -> Gemini functionCallingConfig
+	if choice, ok := util.ParseClaudeToolChoice(gjson.GetBytes(rawJSON, "tool_choice")); ok && gjson.Get(out, "request.tools").Exists() {
+		out, _ = sjson.SetRaw(out, "request.toolConfig", choice.GeminiToolConfig())
 	}
 
 	// Map Anthropic thinking -> Gemini thinkingBudget/include_thoughts when type==enabled
 	if t := gjson.GetBytes(rawJSON, "thinking"); t.Exists() && t.IsObject() && util.ModelSupportsThinking(modelName) {
 		if t.Get("type").String() == "enabled" {
 			if b := t.Get("budget_tokens"); b.Exists() && b.Type == gjson.Number {
-				budget := int(b.Int())
+				budget := util.NormalizeThinkingBudget(modelName, int(b.Int()))
 				out, _ = sjson.Set(out, "request.generationConfig.thinkingConfig.thinkingBudget", budget)
 				out, _ = sjson.Set(out, "request.generationConfig.thinkingConfig.include_thoughts", true)
 			}
 		}
 	}
+	if stops, _ := util.LimitStopSequences(util.ParseStopSequences(gjson.GetBytes(rawJSON, "stop_sequences")), util.GeminiMaxStopSequences); len(stops) > 0 {
+		out, _ = sjson.Set(out, "request.generationConfig.stopSequences", stops)
+	}
 	if v := gjson.GetBytes(rawJSON, "temperature"); v.Exists() && v.Type == gjson.Number {
 		out, _ = sjson.Set(out, "request.generationConfig.temperature", v.Num)
 	}
@@ -14,6 +14,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -26,6 +28,8 @@ type Params struct {
 	ResponseType     int  // Current response type: 0=none, 1=content, 2=thinking, 3=function
 	ResponseIndex    int  // Index counter for content blocks in the streaming response
 	HasContent       bool // Tracks whether any content (text, thinking, or tool use) has been output
+
+	Grounding common.GroundingTracker // Latest search grounding, emitted as web search blocks at the end
 }
 
 // toolUseIDCounter provides a process-wide unique counter for tool use identifiers.
@@ -209,7 +213,9 @@ func ConvertGeminiCLIResponseToClaude(_ context.Context, _ string, originalReque
 
 				// Create the tool use block with unique ID and function details
 				data := fmt.Sprintf(`{"type":"content_block_start","index":%d,"content_block":{"type":"tool_use","id":"","name":"","input":{}}}`, (*param).(*Params).ResponseIndex)
-				data, _ = sjson.Set(data, "content_block.id", fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&toolUseIDCounter, 1)))
+				toolUseID := fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&toolUseIDCounter, 1))
+				common.CacheFunctionCallThoughtSignature(toolUseID, partResult)
+				data, _ = sjson.Set(data, "content_block.id", toolUseID)
 				data, _ = sjson.Set(data, "content_block.name", fcName)
 				output = output + fmt.Sprintf("data: %s\n\n\n", data)
 
@@ -224,10 +230,26 @@ func ConvertGeminiCLIResponseToClaude(_ context.Context, _ string, originalReque
 		}
 	}
 
+	// A safety block before anything was streamed would otherwise end as an empty message;
+	// surface the explanation as text so clients can tell a policy block from an empty answer.
+	blockReason, blockMessage, blocked := common.SafetyBlock(gjson.GetBytes(rawJSON, "response"))
+	if blocked && !(*param).(*Params).HasContent {
+		output = output + "event: content_block_start\n"
+		output = output + fmt.Sprintf(`data: {"type":"content_block_start","index":%d,"content_block":{"type":"text","text":""}}`, (*param).(*Params).ResponseIndex)
+		output = output + "\n\n\n"
+		output = output + "event: content_block_delta\n"
+		data, _ := sjson.Set(fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":""}}`, (*param).(*Params).ResponseIndex), "delta.text", blockMessage)
+		output = output + fmt.Sprintf("data: %s\n\n\n", data)
+		(*param).(*Params).ResponseType = 1
+		(*param).(*Params).HasContent = true
+	}
+
+	(*param).(*Params).Grounding.Observe(gjson.GetBytes(rawJSON, "response.candidates.0.groundingMetadata"))
+
 	usageResult := gjson.GetBytes(rawJSON, "response.usageMetadata")
 	// Process usage metadata and finish reason when present in the response
-	if usageResult.Exists() && bytes.Contains(rawJSON, []byte(`"finishReason"`)) {
-		if candidatesTokenCountResult := usageResult.Get("candidatesTokenCount"); candidatesTokenCountResult.Exists() {
+	if (usageResult.Exists() && bytes.Contains(rawJSON, []byte(`"finishReason"`))) || blocked {
+		if candidatesTokenCountResult := usageResult.Get("candidatesTokenCount"); candidatesTokenCountResult.Exists() || blocked {
 			// Only send final events if we have actually output content
 			if (*param).(*Params).HasContent {
 				// Close the final content block
@@ -235,6 +257,10 @@ func ConvertGeminiCLIResponseToClaude(_ context.Context, _ string, originalReque
 				output = output + fmt.Sprintf(`data: {"type":"content_block_stop","index":%d}`, (*param).(*Params).ResponseIndex)
 				output = output + "\n\n\n"
 
+				// Search grounding closes the content as Claude web search blocks
+				events, _ := common.ClaudeWebSearchEvents((*param).(*Params).Grounding.Metadata(), gjson.GetBytes(rawJSON, "response.responseId").String(), (*param).(*Params).ResponseIndex+1)
+				output = output + events
+
 				// Send the final message delta with usage information and stop reason
 				output = output + "event: message_delta\n"
 				output = output + `data: `
@@ -244,6 +270,10 @@ func ConvertGeminiCLIResponseToClaude(_ context.Context, _ string, originalReque
 				// Set tool_use stop reason if tools were used in this response
 				if usedTool {
 					template = `{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"input_tokens":0,"output_tokens":0}}`
+				} else if blocked {
+					template, _ = sjson.Set(template, "delta.stop_reason", util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonClaude, blockReason))
+				} else if finishReason := gjson.GetBytes(rawJSON, "response.candidates.0.finishReason"); finishReason.Exists() {
+					template, _ = sjson.Set(template, "delta.stop_reason", util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonClaude, finishReason.String()))
 				}
 
 				// Include thinking tokens in output token count if present
@@ -287,8 +317,8 @@ func ConvertGeminiCLIResponseToClaudeNonStream(_ context.Context, _ string, orig
 	parts := root.Get("response.candidates.0.content.parts")
 	textBuilder := strings.Builder{}
 	thinkingBuilder := strings.Builder{}
-	toolIDCounter := 0
 	hasToolCall := false
+	codeExecution := common.NewCodeExecutionConverter(root.Get("response.responseId").String())
 
 	flushText := func() {
 		if textBuilder.Len() == 0 {
@@ -323,15 +353,23 @@ func ConvertGeminiCLIResponseToClaudeNonStream(_ context.Context, _ string, orig
 				continue
 			}
 
+			if block, ok := codeExecution.Block(part); ok {
+				flushThinking()
+				flushText()
+				out, _ = sjson.SetRaw(out, "content.-1", block)
+				continue
+			}
+
 			if functionCall := part.Get("functionCall"); functionCall.Exists() {
 				flushThinking()
 				flushText()
 				hasToolCall = true
 
 				name := functionCall.Get("name").String()
-				toolIDCounter++
 				toolBlock := `{"type":"tool_use","id":"","name":"","input":{}}`
-				toolBlock, _ = sjson.Set(toolBlock, "id", fmt.Sprintf("tool_%d", toolIDCounter))
+				toolUseID := fmt.Sprintf("%s-%d-%d", name, time.Now().UnixNano(), atomic.AddUint64(&toolUseIDCounter, 1))
+				common.CacheFunctionCallThoughtSignature(toolUseID, part)
+				toolBlock, _ = sjson.Set(toolBlock, "id", toolUseID)
 				toolBlock, _ = sjson.Set(toolBlock, "name", name)
 				inputRaw := "{}"
 				if args := functionCall.Get("args"); args.Exists() && gjson.Valid(args.Raw) && args.IsObject() {
@@ -352,14 +390,15 @@ func ConvertGeminiCLIResponseToClaudeNonStream(_ context.Context, _ string, orig
 		stopReason = "tool_use"
 	} else {
 		if finish := root.Get("response.candidates.0.finishReason"); finish.Exists() {
-			switch finish.String() {
-			case "MAX_TOKENS":
-				stopReason = "max_tokens"
-			case "STOP", "FINISH_REASON_UNSPECIFIED", "UNKNOWN":
-				stopReason = "end_turn"
-			default:
-				stopReason = "end_turn"
-			}
+			stopReason = util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonClaude, finish.String())
+		}
+	}
+	// Policy blocks become a refusal; when nothing was generated the block explanation is the content.
+	if blockReason, blockMessage, blocked := common.SafetyBlock(root.Get("response")); blocked && !hasToolCall {
+		stopReason = util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonClaude, blockReason)
+		if !gjson.Get(out, "content.0").Exists() {
+			block, _ := sjson.Set(`{"type":"text","text":""}`, "text", blockMessage)
+			out, _ = sjson.SetRaw(out, "content.-1", block)
 		}
 	}
 	out, _ = sjson.Set(out, "stop_reason", stopReason)
@@ -368,7 +407,12 @@ func ConvertGeminiCLIResponseToClaudeNonStream(_ context.Context, _ string, orig
 		out, _ = sjson.Delete(out, "usage")
 	}
 
-	return out
+	// Surface googleSearch grounding as the web search blocks Claude clients expect.
+	out = common.PrependClaudeContent(out, common.ClaudeWebSearchBlocks(root.Get("response.candidates.0.groundingMetadata"), root.Get("response.responseId").String()))
+
+	// Stop sequences beyond Gemini's limit were not forwarded; enforce them here.
+	_, overflow := util.LimitStopSequences(util.ParseStopSequences(gjson.GetBytes(originalRequestRawJSON, "stop_sequences")), util.GeminiMaxStopSequences)
+	return util.ApplyClaudeStopSequences(out, overflow)
 }
 
 func ClaudeTokenCount(ctx context.Context, count int64) string {
@@ -11,10 +11,10 @@ func init() {
 		Claude,
 		GeminiCLI,
 		ConvertClaudeRequestToCLI,
-		interfaces.TranslateResponse{
+		translator.WithClaudePrefill(interfaces.TranslateResponse{
 			Stream:     ConvertGeminiCLIResponseToClaude,
 			NonStream:  ConvertGeminiCLIResponseToClaudeNonStream,
 			TokenCount: ClaudeTokenCount,
-		},
+		}),
 	)
 }
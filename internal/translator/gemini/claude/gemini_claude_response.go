@@ -14,6 +14,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -25,6 +27,8 @@ type Params struct {
 	ResponseType     int
 	ResponseIndex    int
 	HasContent       bool // Tracks whether any content (text, thinking, or tool use) has been output
+
+	Grounding common.GroundingTracker // Latest search grounding, emitted as web search blocks at the end
 }
 
 // toolUseIDCounter provides a process-wide unique counter for tool use identifiers.
@@ -221,7 +225,9 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 
 				// Create the tool use block with unique ID and function details
 				data := fmt.Sprintf(`{"type":"content_block_start","index":%d,"content_block":{"type":"tool_use","id":"","name":"","input":{}}}`, (*param).(*Params).ResponseIndex)
-				data, _ = sjson.Set(data, "content_block.id", fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&toolUseIDCounter, 1)))
+				toolUseID := fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&toolUseIDCounter, 1))
+				common.CacheFunctionCallThoughtSignature(toolUseID, partResult)
+				data, _ = sjson.Set(data, "content_block.id", toolUseID)
 				data, _ = sjson.Set(data, "content_block.name", fcName)
 				output = output + fmt.Sprintf("data: %s\n\n\n", data)
 
@@ -236,21 +242,45 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 		}
 	}
 
+	// A safety block before anything was streamed would otherwise end as an empty message;
+	// surface the explanation as text so clients can tell a policy block from an empty answer.
+	blockReason, blockMessage, blocked := common.SafetyBlock(gjson.ParseBytes(rawJSON))
+	if blocked && !(*param).(*Params).HasContent {
+		output = output + "event: content_block_start\n"
+		output = output + fmt.Sprintf(`data: {"type":"content_block_start","index":%d,"content_block":{"type":"text","text":""}}`, (*param).(*Params).ResponseIndex)
+		output = output + "\n\n\n"
+		output = output + "event: content_block_delta\n"
+		data, _ := sjson.Set(fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":""}}`, (*param).(*Params).ResponseIndex), "delta.text", blockMessage)
+		output = output + fmt.Sprintf("data: %s\n\n\n", data)
+		(*param).(*Params).ResponseType = 1
+		(*param).(*Params).HasContent = true
+	}
+
+	(*param).(*Params).Grounding.Observe(gjson.GetBytes(rawJSON, "candidates.0.groundingMetadata"))
+
 	usageResult := gjson.GetBytes(rawJSON, "usageMetadata")
-	if usageResult.Exists() && bytes.Contains(rawJSON, []byte(`"finishReason"`)) {
-		if candidatesTokenCountResult := usageResult.Get("candidatesTokenCount"); candidatesTokenCountResult.Exists() {
+	if (usageResult.Exists() && bytes.Contains(rawJSON, []byte(`"finishReason"`))) || blocked {
+		if candidatesTokenCountResult := usageResult.Get("candidatesTokenCount"); candidatesTokenCountResult.Exists() || blocked {
 			// Only send final events if we have actually output content
 			if (*param).(*Params).HasContent {
 				output = output + "event: content_block_stop\n"
 				output = output + fmt.Sprintf(`data: {"type":"content_block_stop","index":%d}`, (*param).(*Params).ResponseIndex)
 				output = output + "\n\n\n"
 
+				// Search grounding closes the content as Claude web search blocks
+				events, _ := common.ClaudeWebSearchEvents((*param).(*Params).Grounding.Metadata(), gjson.GetBytes(rawJSON, "responseId").String(), (*param).(*Params).ResponseIndex+1)
+				output = output + events
+
 				output = output + "event: message_delta\n"
 				output = output + `data: `
 
 				template := `{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"input_tokens":0,"output_tokens":0}}`
 				if usedTool {
 					template = `{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"input_tokens":0,"output_tokens":0}}`
+				} else if blocked {
+					template, _ = sjson.Set(template, "delta.stop_reason", util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonClaude, blockReason))
+				} else if finishReason := gjson.GetBytes(rawJSON, "candidates.0.finishReason"); finishReason.Exists() {
+					template, _ = sjson.Set(template, "delta.stop_reason", util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonClaude, finishReason.String()))
 				}
 
 				thoughtsTokenCount := usageResult.Get("thoughtsTokenCount").Int()
@@ -293,8 +323,8 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 	parts := root.Get("candidates.0.content.parts")
 	textBuilder := strings.Builder{}
 	thinkingBuilder := strings.Builder{}
-	toolIDCounter := 0
 	hasToolCall := false
+	codeExecution := common.NewCodeExecutionConverter(root.Get("responseId").String())
 
 	flushText := func() {
 		if textBuilder.Len() == 0 {
@@ -329,15 +359,23 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 				continue
 			}
 
+			if block, ok := codeExecution.Block(part); ok {
+				flushThinking()
+				flushText()
+				out, _ = sjson.SetRaw(out, "content.-1", block)
+				continue
+			}
+
 			if functionCall := part.Get("functionCall"); functionCall.Exists() {
 				flushThinking()
 				flushText()
 				hasToolCall = true
 
 				name := functionCall.Get("name").String()
-				toolIDCounter++
 				toolBlock := `{"type":"tool_use","id":"","name":"","input":{}}`
-				toolBlock, _ = sjson.Set(toolBlock, "id", fmt.Sprintf("tool_%d", toolIDCounter))
+				toolUseID := fmt.Sprintf("%s-%d-%d", name, time.Now().UnixNano(), atomic.AddUint64(&toolUseIDCounter, 1))
+				common.CacheFunctionCallThoughtSignature(toolUseID, part)
+				toolBlock, _ = sjson.Set(toolBlock, "id", toolUseID)
 				toolBlock, _ = sjson.Set(toolBlock, "name", name)
 				inputRaw := "{}"
 				if args := functionCall.Get("args"); args.Exists() && gjson.Valid(args.Raw) && args.IsObject() {
@@ -358,14 +396,15 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 		stopReason = "tool_use"
 	} else {
 		if finish := root.Get("candidates.0.finishReason"); finish.Exists() {
-			switch finish.String() {
-			case "MAX_TOKENS":
-				stopReason = "max_tokens"
-			case "STOP", "FINISH_REASON_UNSPECIFIED", "UNKNOWN":
-				stopReason = "end_turn"
-			default:
-				stopReason = "end_turn"
-			}
+			stopReason = util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonClaude, finish.String())
+		}
+	}
+	// Policy blocks become a refusal; when nothing was generated the block explanation is the content.
+	if blockReason, blockMessage, blocked := common.SafetyBlock(root); blocked && !hasToolCall {
+		stopReason = util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonClaude, blockReason)
+		if !gjson.Get(out, "content.0").Exists() {
+			block, _ := sjson.Set(`{"type":"text","text":""}`, "text", blockMessage)
+			out, _ = sjson.SetRaw(out, "content.-1", block)
 		}
 	}
 	out, _ = sjson.Set(out, "stop_reason", stopReason)
@@ -374,7 +413,12 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 		out, _ = sjson.Delete(out, "usage")
 	}
 
-	return out
+	// Surface googleSearch grounding as the web search blocks Claude clients expect.
+	out = common.PrependClaudeContent(out, common.ClaudeWebSearchBlocks(root.Get("candidates.0.groundingMetadata"), root.Get("responseId").String()))
+
+	// Stop sequences beyond Gemini's limit were not forwarded; enforce them here.
+	_, overflow := util.LimitStopSequences(util.ParseStopSequences(gjson.GetBytes(originalRequestRawJSON, "stop_sequences")), util.GeminiMaxStopSequences)
+	return util.ApplyClaudeStopSequences(out, overflow)
 }
 
 func ClaudeTokenCount(ctx context.Context, count int64) string {
@@ -11,10 +11,10 @@ func init() {
 		Claude,
 		Gemini,
 		ConvertClaudeRequestToGemini,
-		interfaces.TranslateResponse{
+		translator.WithClaudePrefill(interfaces.TranslateResponse{
 			Stream:     ConvertGeminiResponseToClaude,
 			NonStream:  ConvertGeminiResponseToClaudeNonStream,
 			TokenCount: ClaudeTokenCount,
-		},
+		}),
 	)
 }
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// IsCodeExecutionTool reports whether tool is a built-in code interpreter of the Claude
+// (code_execution_*) or OpenAI (code_interpreter) APIs.
+func IsCodeExecutionTool(tool gjson.Result) bool {
+	typ := tool.Get("type").String()
+	return strings.HasPrefix(typ, "code_execution") || typ == "code_interpreter"
+}
+
+// AppendCodeExecutionTool adds a codeExecution tool to the tools array at path unless one is
+// already declared there.
+func AppendCodeExecutionTool(out, path string) string {
+	return appendBuiltinTool(out, path, "codeExecution")
+}
+
+// CodeExecutionConverter turns Gemini executableCode and codeExecutionResult parts into the
+// server_tool_use and code_execution_tool_result blocks of Claude's code execution tool. Each
+// result is paired with the call that precedes it.
+type CodeExecutionConverter struct {
+	prefix string
+	count  int
+	last   string
+}
+
+// NewCodeExecutionConverter returns a converter whose tool IDs derive from responseID.
+func NewCodeExecutionConverter(responseID string) *CodeExecutionConverter {
+	return &CodeExecutionConverter{prefix: "srvtoolu_" + responseID}
+}
+
+// Block converts part, reporting false when it is neither executable code nor its result.
+func (c *CodeExecutionConverter) Block(part gjson.Result) (string, bool) {
+	if code := part.Get("executableCode"); code.Exists() {
+		c.count++
+		c.last = fmt.Sprintf("%s_%d", c.prefix, c.count)
+		block := `{"type":"server_tool_use","id":"","name":"code_execution","input":{"code":""}}`
+		block, _ = sjson.Set(block, "id", c.last)
+		block, _ = sjson.Set(block, "input.code", code.Get("code").String())
+		return block, true
+	}
+	result := part.Get("codeExecutionResult")
+	if !result.Exists() {
+		return "", false
+	}
+	if c.last == "" {
+		c.count++
+		c.last = fmt.Sprintf("%s_%d", c.prefix, c.count)
+	}
+	block := `{"type":"code_execution_tool_result","tool_use_id":"","content":{"type":"code_execution_result","stdout":"","stderr":"","return_code":0}}`
+	block, _ = sjson.Set(block, "tool_use_id", c.last)
+	output := result.Get("output").String()
+	if outcome := result.Get("outcome").String(); outcome == "" || outcome == "OUTCOME_OK" {
+		block, _ = sjson.Set(block, "content.stdout", output)
+	} else {
+		block, _ = sjson.Set(block, "content.stderr", output)
+		block, _ = sjson.Set(block, "content.return_code", 1)
+	}
+	c.last = ""
+	return block, true
+}
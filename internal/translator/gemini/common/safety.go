@@ -45,3 +45,40 @@ func AttachDefaultSafetySettings(rawJSON []byte, path string) []byte {
 
 	return out
 }
+
+// SafetyBlock reports whether a Gemini response was stopped by a safety or policy filter, either
+// before generation (promptFeedback.blockReason) or on the candidate itself. It returns a Gemini
+// finishReason suitable for util.MapFinishReason and a human readable explanation that can be
+// surfaced to clients as refusal text.
+func SafetyBlock(response gjson.Result) (finishReason, message string, blocked bool) {
+	if blockReason := response.Get("promptFeedback.blockReason").String(); blockReason != "" && !response.Get("candidates.0").Exists() {
+		finishReason = blockReason
+		if !isSafetyFinishReason(finishReason) {
+			// OTHER and BLOCK_REASON_UNSPECIFIED still mean the prompt was rejected.
+			finishReason = "SAFETY"
+		}
+		message = response.Get("promptFeedback.blockReasonMessage").String()
+		if message == "" {
+			message = "The prompt was blocked by the upstream safety filters (" + blockReason + ")."
+		}
+		return finishReason, message, true
+	}
+
+	finishReason = response.Get("candidates.0.finishReason").String()
+	if !isSafetyFinishReason(finishReason) {
+		return "", "", false
+	}
+	message = response.Get("candidates.0.finishMessage").String()
+	if message == "" {
+		message = "The response was blocked by the upstream safety filters (" + finishReason + ")."
+	}
+	return finishReason, message, true
+}
+
+func isSafetyFinishReason(reason string) bool {
+	switch reason {
+	case "SAFETY", "IMAGE_SAFETY", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "RECITATION":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestSafetyBlock(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     string
+		reason  string
+		message string
+		blocked bool
+	}{
+		{
+			name:    "prompt blocked",
+			raw:     `{"promptFeedback":{"blockReason":"PROHIBITED_CONTENT"},"usageMetadata":{"promptTokenCount":5}}`,
+			reason:  "PROHIBITED_CONTENT",
+			message: "The prompt was blocked by the upstream safety filters (PROHIBITED_CONTENT).",
+			blocked: true,
+		},
+		{
+			name:    "prompt blocked for other reason",
+			raw:     `{"promptFeedback":{"blockReason":"OTHER","blockReasonMessage":"not allowed"}}`,
+			reason:  "SAFETY",
+			message: "not allowed",
+			blocked: true,
+		},
+		{
+			name:    "candidate blocked",
+			raw:     `{"candidates":[{"finishReason":"SAFETY"}]}`,
+			reason:  "SAFETY",
+			message: "The response was blocked by the upstream safety filters (SAFETY).",
+			blocked: true,
+		},
+		{
+			name: "normal stop",
+			raw:  `{"candidates":[{"content":{"parts":[{"text":"hi"}]},"finishReason":"STOP"}]}`,
+		},
+	}
+	for _, tc := range cases {
+		reason, message, blocked := SafetyBlock(gjson.Parse(tc.raw))
+		if reason != tc.reason || message != tc.message || blocked != tc.blocked {
+			t.Errorf("%s: SafetyBlock() = (%q, %q, %v), want (%q, %q, %v)", tc.name, reason, message, blocked, tc.reason, tc.message, tc.blocked)
+		}
+	}
+}
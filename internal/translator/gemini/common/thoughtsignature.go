@@ -0,0 +1,17 @@
+package common
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/tidwall/gjson"
+)
+
+// CacheFunctionCallThoughtSignature remembers the thoughtSignature of a Gemini functionCall part
+// under the tool call ID it was surfaced to the client as, so the signature can be restored when
+// the client replays the call in a later turn.
+func CacheFunctionCallThoughtSignature(toolCallID string, part gjson.Result) {
+	signature := part.Get("thoughtSignature")
+	if !signature.Exists() {
+		signature = part.Get("thought_signature")
+	}
+	cache.CacheToolCallThoughtSignature(toolCallID, signature.String())
+}
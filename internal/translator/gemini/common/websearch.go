@@ -0,0 +1,224 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// IsWebSearchTool reports whether tool is a built-in web search tool of the Claude
+// (web_search_20250305) or OpenAI (web_search, web_search_preview) APIs.
+func IsWebSearchTool(tool gjson.Result) bool {
+	typ := tool.Get("type").String()
+	return strings.HasPrefix(typ, "web_search")
+}
+
+// AppendGoogleSearchTool adds a googleSearch grounding tool to the tools array at path unless
+// one is already declared there.
+func AppendGoogleSearchTool(out, path string) string {
+	return appendBuiltinTool(out, path, "googleSearch")
+}
+
+// appendBuiltinTool adds a {key:{}} tool to the tools array at path unless one is already declared.
+func appendBuiltinTool(out, path, key string) string {
+	exists := false
+	gjson.Get(out, path).ForEach(func(_, tool gjson.Result) bool {
+		if tool.Get(key).Exists() {
+			exists = true
+			return false
+		}
+		return true
+	})
+	if exists {
+		return out
+	}
+	if !gjson.Get(out, path).IsArray() {
+		out, _ = sjson.SetRaw(out, path, "[]")
+	}
+	out, _ = sjson.SetRaw(out, path+".-1", `{"`+key+`":{}}`)
+	return out
+}
+
+// GroundingSource is a web page Gemini grounded a response on.
+type GroundingSource struct {
+	URL   string
+	Title string
+}
+
+// GroundingSources returns the web sources of a candidate's groundingMetadata in chunk order.
+func GroundingSources(metadata gjson.Result) []GroundingSource {
+	var sources []GroundingSource
+	metadata.Get("groundingChunks").ForEach(func(_, chunk gjson.Result) bool {
+		web := chunk.Get("web")
+		if url := web.Get("uri").String(); url != "" {
+			sources = append(sources, GroundingSource{URL: url, Title: web.Get("title").String()})
+		}
+		return true
+	})
+	return sources
+}
+
+// GroundingQuery returns the search queries Gemini ran, joined with "; ".
+func GroundingQuery(metadata gjson.Result) string {
+	var queries []string
+	metadata.Get("webSearchQueries").ForEach(func(_, q gjson.Result) bool {
+		if s := strings.TrimSpace(q.String()); s != "" {
+			queries = append(queries, s)
+		}
+		return true
+	})
+	return strings.Join(queries, "; ")
+}
+
+// ClaudeWebSearchBlocks renders groundingMetadata as the server_tool_use and
+// web_search_tool_result content blocks Claude returns for its web search tool. It returns nil
+// when the response was not grounded on a search.
+func ClaudeWebSearchBlocks(metadata gjson.Result, id string) []string {
+	sources := GroundingSources(metadata)
+	query := GroundingQuery(metadata)
+	if len(sources) == 0 && query == "" {
+		return nil
+	}
+	toolID := "srvtoolu_" + id
+	use := `{"type":"server_tool_use","id":"","name":"web_search","input":{"query":""}}`
+	use, _ = sjson.Set(use, "id", toolID)
+	use, _ = sjson.Set(use, "input.query", query)
+	result := `{"type":"web_search_tool_result","tool_use_id":"","content":[]}`
+	result, _ = sjson.Set(result, "tool_use_id", toolID)
+	for _, source := range sources {
+		item := `{"type":"web_search_result","url":"","title":""}`
+		item, _ = sjson.Set(item, "url", source.URL)
+		item, _ = sjson.Set(item, "title", source.Title)
+		result, _ = sjson.SetRaw(result, "content.-1", item)
+	}
+	return []string{use, result}
+}
+
+// PrependClaudeContent inserts blocks at the start of the content array of a Claude message.
+func PrependClaudeContent(message string, blocks []string) string {
+	if len(blocks) == 0 {
+		return message
+	}
+	existing := gjson.Get(message, "content")
+	items := append([]string{}, blocks...)
+	existing.ForEach(func(_, block gjson.Result) bool {
+		items = append(items, block.Raw)
+		return true
+	})
+	message, _ = sjson.SetRaw(message, "content", "["+strings.Join(items, ",")+"]")
+	return message
+}
+
+// OpenAIURLCitations renders groundingMetadata as OpenAI url_citation annotations on text.
+// Grounding supports carry UTF-8 byte offsets, which are converted to the character offsets
+// OpenAI clients expect. Sources without a support span cover the whole text.
+func OpenAIURLCitations(metadata gjson.Result, text string, nested bool) string {
+	sources := GroundingSources(metadata)
+	if len(sources) == 0 {
+		return ""
+	}
+	annotation := func(source GroundingSource, start, end int) string {
+		item := `{"type":"url_citation"}`
+		prefix := ""
+		if nested {
+			prefix = "url_citation."
+		}
+		item, _ = sjson.Set(item, prefix+"url", source.URL)
+		item, _ = sjson.Set(item, prefix+"title", source.Title)
+		item, _ = sjson.Set(item, prefix+"start_index", start)
+		item, _ = sjson.Set(item, prefix+"end_index", end)
+		return item
+	}
+	var annotations []string
+	cited := make([]bool, len(sources))
+	metadata.Get("groundingSupports").ForEach(func(_, support gjson.Result) bool {
+		segment := support.Get("segment")
+		start := charOffset(text, int(segment.Get("startIndex").Int()))
+		end := charOffset(text, int(segment.Get("endIndex").Int()))
+		support.Get("groundingChunkIndices").ForEach(func(_, idx gjson.Result) bool {
+			i := int(idx.Int())
+			if i >= 0 && i < len(sources) {
+				cited[i] = true
+				annotations = append(annotations, annotation(sources[i], start, end))
+			}
+			return true
+		})
+		return true
+	})
+	length := utf8.RuneCountInString(text)
+	for i, source := range sources {
+		if !cited[i] {
+			annotations = append(annotations, annotation(source, 0, length))
+		}
+	}
+	return "[" + strings.Join(annotations, ",") + "]"
+}
+
+// ResponsesWebSearchCall renders groundingMetadata as a Responses API web_search_call output item.
+func ResponsesWebSearchCall(metadata gjson.Result, id string) string {
+	query := GroundingQuery(metadata)
+	if query == "" && len(GroundingSources(metadata)) == 0 {
+		return ""
+	}
+	item := `{"id":"","type":"web_search_call","status":"completed","action":{"type":"search","query":""}}`
+	item, _ = sjson.Set(item, "id", fmt.Sprintf("ws_%s", id))
+	item, _ = sjson.Set(item, "action.query", query)
+	return item
+}
+
+// charOffset converts a UTF-8 byte offset within text to a character offset.
+func charOffset(text string, byteOffset int) int {
+	if byteOffset <= 0 {
+		return 0
+	}
+	if byteOffset > len(text) {
+		byteOffset = len(text)
+	}
+	return utf8.RuneCountInString(text[:byteOffset])
+}
+
+// GroundingTracker follows a streamed Gemini response so its citations can be emitted once the
+// stream finishes: it accumulates the answer text and keeps the latest groundingMetadata.
+type GroundingTracker struct {
+	text     strings.Builder
+	metadata string
+}
+
+// AddText appends non-thought response text.
+func (g *GroundingTracker) AddText(text string) {
+	g.text.WriteString(text)
+}
+
+// Observe records the groundingMetadata of a chunk, if it carries one.
+func (g *GroundingTracker) Observe(metadata gjson.Result) {
+	if metadata.IsObject() {
+		g.metadata = metadata.Raw
+	}
+}
+
+// Text returns the response text seen so far.
+func (g *GroundingTracker) Text() string {
+	return g.text.String()
+}
+
+// Metadata returns the latest groundingMetadata seen, or an empty result.
+func (g *GroundingTracker) Metadata() gjson.Result {
+	return gjson.Parse(g.metadata)
+}
+
+// ClaudeWebSearchEvents renders groundingMetadata as Claude SSE content blocks numbered from
+// index. It returns the events and the number of blocks written.
+func ClaudeWebSearchEvents(metadata gjson.Result, id string, index int) (string, int) {
+	blocks := ClaudeWebSearchBlocks(metadata, id)
+	var events strings.Builder
+	for i, block := range blocks {
+		start := fmt.Sprintf(`{"type":"content_block_start","index":%d,"content_block":{}}`, index+i)
+		start, _ = sjson.SetRaw(start, "content_block", block)
+		events.WriteString("event: content_block_start\ndata: " + start + "\n\n\n")
+		events.WriteString(fmt.Sprintf("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":%d}\n\n\n", index+i))
+	}
+	return events.String(), len(blocks)
+}
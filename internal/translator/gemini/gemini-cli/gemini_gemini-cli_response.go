@@ -9,11 +9,11 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
-var dataTag = []byte("data:")
-
 // ConvertGeminiResponseToGeminiCLI converts Gemini streaming response format to Gemini CLI single-line JSON format.
 // This function processes various Gemini event types and transforms them into Gemini CLI-compatible JSON responses.
 // It handles thinking content, regular text content, and function calls, outputting single-line JSON
@@ -28,12 +28,13 @@ var dataTag = []byte("data:")
 // Returns:
 //   - []string: A slice of strings, each containing a Gemini CLI-compatible JSON response.
 func ConvertGeminiResponseToGeminiCLI(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) []string {
-	if !bytes.HasPrefix(rawJSON, dataTag) {
-		return []string{}
+	// The Gemini executor passes bare JSON payloads; SSE data lines are accepted as well.
+	if payload, ok := sse.Data(rawJSON); ok {
+		rawJSON = payload
 	}
-	rawJSON = bytes.TrimSpace(rawJSON[5:])
+	rawJSON = bytes.TrimSpace(rawJSON)
 
-	if bytes.Equal(rawJSON, []byte("[DONE]")) {
+	if len(rawJSON) == 0 || sse.IsDone(rawJSON) {
 		return []string{}
 	}
 	json := `{"response": {}}`
@@ -52,6 +53,9 @@ func ConvertGeminiResponseToGeminiCLI(_ context.Context, _ string, originalReque
 // Returns:
 //   - string: A Gemini CLI-compatible JSON response.
 func ConvertGeminiResponseToGeminiCLINonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+	if !gjson.ValidBytes(rawJSON) {
+		return string(rawJSON)
+	}
 	json := `{"response": {}}`
 	rawJSON, _ = sjson.SetRawBytes([]byte(json), "response", rawJSON)
 	return string(rawJSON)
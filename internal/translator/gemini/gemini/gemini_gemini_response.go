@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
 )
 
 // PassthroughGeminiResponseStream forwards Gemini responses unchanged.
 func PassthroughGeminiResponseStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) []string {
-	if bytes.HasPrefix(rawJSON, []byte("data:")) {
-		rawJSON = bytes.TrimSpace(rawJSON[5:])
+	if payload, ok := sse.Data(rawJSON); ok {
+		rawJSON = payload
 	}
 
 	if bytes.Equal(rawJSON, []byte("[DONE]")) {
@@ -0,0 +1,115 @@
+// Package audio translates OpenAI /v1/audio/transcriptions and /v1/audio/speech requests to
+// Gemini generateContent calls: transcription sends the upload as inline audio, speech asks a
+// TTS model for audio output with the matching prebuilt voice.
+package audio
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// transcriptionInstruction asks the model for a plain verbatim transcript.
+const transcriptionInstruction = "Generate a verbatim transcript of the speech in this audio. Respond with the transcript text only, without timestamps, speaker labels or commentary."
+
+// openAIVoices maps OpenAI voice names to Gemini prebuilt voices of a similar character.
+// Gemini voice names are passed through unchanged.
+var openAIVoices = map[string]string{
+	"alloy":   "Kore",
+	"ash":     "Charon",
+	"ballad":  "Sulafat",
+	"coral":   "Aoede",
+	"echo":    "Puck",
+	"fable":   "Fenrir",
+	"nova":    "Leda",
+	"onyx":    "Orus",
+	"sage":    "Achird",
+	"shimmer": "Zephyr",
+	"verse":   "Iapetus",
+}
+
+// ConvertOpenAITranscriptionRequestToGemini converts a transcription request, with the upload
+// carried as file.content_type and base64 file.data, into a generateContent request that asks
+// for a transcript. language and prompt are passed to the model as hints.
+func ConvertOpenAITranscriptionRequestToGemini(_ string, inputRawJSON []byte, _ bool) []byte {
+	root := gjson.ParseBytes(inputRawJSON)
+
+	mimeType := root.Get("file.content_type").String()
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = audioMimeTypeFromFilename(root.Get("file.filename").String())
+	}
+	instruction := transcriptionInstruction
+	if language := strings.TrimSpace(root.Get("language").String()); language != "" {
+		instruction += " The audio is in language \"" + language + "\"."
+	}
+	if prompt := strings.TrimSpace(root.Get("prompt").String()); prompt != "" {
+		instruction += " Context for spelling and vocabulary: " + prompt
+	}
+
+	out := `{"contents":[{"role":"user","parts":[{"inlineData":{"mimeType":"","data":""}},{"text":""}]}]}`
+	out, _ = sjson.Set(out, "contents.0.parts.0.inlineData.mimeType", mimeType)
+	out, _ = sjson.Set(out, "contents.0.parts.0.inlineData.data", root.Get("file.data").String())
+	out, _ = sjson.Set(out, "contents.0.parts.1.text", instruction)
+	if temperature := root.Get("temperature"); temperature.Exists() {
+		out, _ = sjson.Set(out, "generationConfig.temperature", temperature.Float())
+	}
+	return []byte(out)
+}
+
+// ConvertOpenAISpeechRequestToGemini converts a text-to-speech request into a generateContent
+// request with audio output. instructions are prefixed to the input as a style direction, the
+// form Gemini TTS models expect.
+func ConvertOpenAISpeechRequestToGemini(_ string, inputRawJSON []byte, _ bool) []byte {
+	root := gjson.ParseBytes(inputRawJSON)
+
+	text := root.Get("input").String()
+	if instructions := strings.TrimSpace(root.Get("instructions").String()); instructions != "" {
+		text = strings.TrimSuffix(instructions, ":") + ": " + text
+	}
+
+	out := `{"contents":[{"role":"user","parts":[{"text":""}]}],"generationConfig":{"responseModalities":["AUDIO"]}}`
+	out, _ = sjson.Set(out, "contents.0.parts.0.text", text)
+	if voice := geminiVoice(root.Get("voice")); voice != "" {
+		out, _ = sjson.Set(out, "generationConfig.speechConfig.voiceConfig.prebuiltVoiceConfig.voiceName", voice)
+	}
+	return []byte(out)
+}
+
+// geminiVoice resolves the voice field, which OpenAI accepts either as a name or as an
+// object with an id.
+func geminiVoice(voice gjson.Result) string {
+	name := voice.String()
+	if voice.IsObject() {
+		name = voice.Get("id").String()
+	}
+	name = strings.TrimSpace(name)
+	if mapped, ok := openAIVoices[strings.ToLower(name)]; ok {
+		return mapped
+	}
+	return name
+}
+
+func audioMimeTypeFromFilename(filename string) string {
+	ext := strings.ToLower(filename)
+	if idx := strings.LastIndex(ext, "."); idx >= 0 {
+		ext = ext[idx+1:]
+	}
+	switch ext {
+	case "wav":
+		return "audio/wav"
+	case "ogg", "oga", "opus":
+		return "audio/ogg"
+	case "flac":
+		return "audio/flac"
+	case "aac":
+		return "audio/aac"
+	case "m4a", "mp4":
+		return "audio/mp4"
+	case "webm":
+		return "audio/webm"
+	case "aiff", "aif":
+		return "audio/aiff"
+	}
+	return "audio/mpeg"
+}
@@ -0,0 +1,96 @@
+package audio
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ConvertGeminiResponseToOpenAITranscriptionNonStream converts a generateContent response into
+// the OpenAI transcription JSON. verbose_json requests also receive the task and language
+// fields; text, srt and vtt formatting is left to the handler.
+func ConvertGeminiResponseToOpenAITranscriptionNonStream(_ context.Context, _ string, originalRequestRawJSON, _, rawJSON []byte, _ *any) string {
+	root := gjson.ParseBytes(rawJSON)
+
+	var text strings.Builder
+	root.Get("candidates.0.content.parts").ForEach(func(_, part gjson.Result) bool {
+		if !part.Get("thought").Bool() {
+			text.WriteString(part.Get("text").String())
+		}
+		return true
+	})
+	if text.Len() == 0 {
+		if _, message, blocked := common.SafetyBlock(root); blocked {
+			return contentPolicyError(message)
+		}
+	}
+
+	out := `{"text":""}`
+	out, _ = sjson.Set(out, "text", strings.TrimSpace(text.String()))
+	if gjson.GetBytes(originalRequestRawJSON, "response_format").String() == "verbose_json" {
+		out, _ = sjson.Set(out, "task", "transcribe")
+		if language := gjson.GetBytes(originalRequestRawJSON, "language").String(); language != "" {
+			out, _ = sjson.Set(out, "language", language)
+		}
+	}
+	if usage := root.Get("usageMetadata"); usage.Exists() {
+		out, _ = sjson.Set(out, "usage.type", "tokens")
+		out, _ = sjson.Set(out, "usage.input_tokens", usage.Get("promptTokenCount").Int())
+		out, _ = sjson.Set(out, "usage.output_tokens", usage.Get("candidatesTokenCount").Int())
+		out, _ = sjson.Set(out, "usage.total_tokens", usage.Get("totalTokenCount").Int())
+	}
+	return out
+}
+
+// ConvertGeminiResponseToOpenAISpeechNonStream extracts the audio from a generateContent
+// response. Gemini returns 16-bit PCM, which is passed through for response_format "pcm" and
+// wrapped in a WAV container otherwise. The result is the raw audio, not JSON.
+func ConvertGeminiResponseToOpenAISpeechNonStream(_ context.Context, _ string, originalRequestRawJSON, _, rawJSON []byte, _ *any) string {
+	root := gjson.ParseBytes(rawJSON)
+
+	var pcm []byte
+	mimeType := ""
+	root.Get("candidates.0.content.parts").ForEach(func(_, part gjson.Result) bool {
+		inline := part.Get("inlineData")
+		if !inline.Exists() {
+			inline = part.Get("inline_data")
+		}
+		if !inline.Exists() {
+			return true
+		}
+		data, err := base64.StdEncoding.DecodeString(inline.Get("data").String())
+		if err != nil {
+			return true
+		}
+		if mimeType == "" {
+			mimeType = inline.Get("mimeType").String()
+			if mimeType == "" {
+				mimeType = inline.Get("mime_type").String()
+			}
+		}
+		pcm = append(pcm, data...)
+		return true
+	})
+	if len(pcm) == 0 {
+		if _, message, blocked := common.SafetyBlock(root); blocked {
+			return contentPolicyError(message)
+		}
+		return ""
+	}
+
+	if strings.EqualFold(gjson.GetBytes(originalRequestRawJSON, "response_format").String(), "pcm") {
+		return string(pcm)
+	}
+	return string(util.PCMToWAV(pcm, util.PCMSampleRate(mimeType)))
+}
+
+func contentPolicyError(message string) string {
+	out := `{"error":{"message":"","type":"invalid_request_error","code":"content_policy_violation"}}`
+	out, _ = sjson.Set(out, "error.message", message)
+	return out
+}
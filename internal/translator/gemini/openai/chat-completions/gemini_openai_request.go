@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
@@ -88,6 +89,19 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 		}
 	}
 
+	// logprobs/top_logprobs -> generationConfig.responseLogprobs/logprobs
+	if lp := gjson.GetBytes(rawJSON, "logprobs"); lp.Exists() && lp.Bool() {
+		out, _ = sjson.SetBytes(out, "generationConfig.responseLogprobs", true)
+		if top := gjson.GetBytes(rawJSON, "top_logprobs"); top.Exists() && top.Type == gjson.Number {
+			out, _ = sjson.SetBytes(out, "generationConfig.logprobs", top.Int())
+		}
+	}
+
+	// stop -> generationConfig.stopSequences
+	if stops, _ := util.LimitStopSequences(util.ParseStopSequences(gjson.GetBytes(rawJSON, "stop")), util.GeminiMaxStopSequences); len(stops) > 0 {
+		out, _ = sjson.SetBytes(out, "generationConfig.stopSequences", stops)
+	}
+
 	// Temperature/top_p/top_k
 	if tr := gjson.GetBytes(rawJSON, "temperature"); tr.Exists() && tr.Type == gjson.Number {
 		out, _ = sjson.SetBytes(out, "generationConfig.temperature", tr.Num)
@@ -165,29 +179,29 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			}
 		}
 
+		// Instructions are only sent as systemInstruction when the request also has turns, since
+		// Gemini requires contents.
+		hasTurns := false
+		for _, m := range arr {
+			if !util.IsInstructionRole(m.Get("role").String()) {
+				hasTurns = true
+				break
+			}
+		}
+
 		for i := 0; i < len(arr); i++ {
 			m := arr[i]
 			role := m.Get("role").String()
 			content := m.Get("content")
 
-			if (role == "system" || role == "developer") && len(arr) > 1 {
-				// system -> system_instruction as a user message style
-				if content.Type == gjson.String {
-					out, _ = sjson.SetBytes(out, "system_instruction.role", "user")
-					out, _ = sjson.SetBytes(out, "system_instruction.parts.0.text", content.String())
-				} else if content.IsObject() && content.Get("type").String() == "text" {
+			if util.IsInstructionRole(role) && hasTurns {
+				// Gemini has a single systemInstruction; every instruction message adds its parts in order.
+				for _, text := range util.InstructionTexts(content) {
+					part, _ := sjson.SetBytes([]byte(`{"text":""}`), "text", text)
 					out, _ = sjson.SetBytes(out, "system_instruction.role", "user")
-					out, _ = sjson.SetBytes(out, "system_instruction.parts.0.text", content.Get("text").String())
-				} else if content.IsArray() {
-					contents := content.Array()
-					if len(contents) > 0 {
-						out, _ = sjson.SetBytes(out, "request.systemInstruction.role", "user")
-						for j := 0; j < len(contents); j++ {
-							out, _ = sjson.SetBytes(out, fmt.Sprintf("request.systemInstruction.parts.%d.text", j), contents[j].Get("text").String())
-						}
-					}
+					out, _ = sjson.SetRawBytes(out, "system_instruction.parts.-1", part)
 				}
-			} else if role == "user" || ((role == "system" || role == "developer") && len(arr) == 1) {
+			} else if role == "user" || (util.IsInstructionRole(role) && !hasTurns) {
 				// Build single user content node to avoid splitting into multiple contents
 				node := []byte(`{"role":"user","parts":[]}`)
 				if content.Type == gjson.String {
@@ -275,7 +289,7 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						fargs := tc.Get("function.arguments").String()
 						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".functionCall.name", fname)
 						node, _ = sjson.SetRawBytes(node, "parts."+itoa(p)+".functionCall.args", []byte(fargs))
-						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".thoughtSignature", geminiFunctionThoughtSignature)
+						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".thoughtSignature", cache.ToolCallThoughtSignature(fid, geminiFunctionThoughtSignature))
 						p++
 						if fid != "" {
 							fIDs = append(fIDs, fid)
@@ -372,12 +386,30 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 				}
 				hasTool = true
 			}
+			// OpenAI's built-in web search tools map onto googleSearch grounding.
+			if common.IsWebSearchTool(t) && !gjson.GetBytes(toolNode, "googleSearch").Exists() {
+				toolNode, _ = sjson.SetRawBytes(toolNode, "googleSearch", []byte(`{}`))
+				hasTool = true
+			}
+			// The code_interpreter tool maps onto Gemini's codeExecution interpreter.
+			if common.IsCodeExecutionTool(t) {
+				toolNode, _ = sjson.SetRawBytes(toolNode, "codeExecution", []byte(`{}`))
+				hasTool = true
+			}
 		}
 		if hasTool {
 			out, _ = sjson.SetRawBytes(out, "tools", []byte("[]"))
 			out, _ = sjson.SetRawBytes(out, "tools.0", toolNode)
 		}
 	}
+	if gjson.GetBytes(rawJSON, "web_search_options").IsObject() {
+		out = []byte(common.AppendGoogleSearchTool(string(out), "tools"))
+	}
+
+	// tool_choice -> toolConfig.functionCallingConfig
+	if choice, ok := util.ParseOpenAIToolChoice(gjson.GetBytes(rawJSON, "tool_choice")); ok && gjson.GetBytes(out, "tools").Exists() {
+		out, _ = sjson.SetRawBytes(out, "toolConfig", []byte(choice.GeminiToolConfig()))
+	}
 
 	out = common.AttachDefaultSafetySettings(out, "safetySettings")
 
@@ -13,6 +13,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -22,6 +25,8 @@ import (
 type convertGeminiResponseToOpenAIChatParams struct {
 	UnixTimestamp int64
 	FunctionIndex int
+	// Grounding follows the answer text and groundingMetadata for url_citation annotations.
+	Grounding common.GroundingTracker
 }
 
 // functionCallIDCounter provides a process-wide unique counter for function call identifiers.
@@ -49,8 +54,8 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 		}
 	}
 
-	if bytes.HasPrefix(rawJSON, []byte("data:")) {
-		rawJSON = bytes.TrimSpace(rawJSON[5:])
+	if payload, ok := sse.Data(rawJSON); ok {
+		rawJSON = payload
 	}
 
 	if bytes.Equal(rawJSON, []byte("[DONE]")) {
@@ -83,10 +88,22 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 
 	// Extract and set the finish reason.
 	if finishReasonResult := gjson.GetBytes(rawJSON, "candidates.0.finishReason"); finishReasonResult.Exists() {
-		template, _ = sjson.Set(template, "choices.0.finish_reason", strings.ToLower(finishReasonResult.String()))
+		template, _ = sjson.Set(template, "choices.0.finish_reason", util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonOpenAI, finishReasonResult.String()))
 		template, _ = sjson.Set(template, "choices.0.native_finish_reason", strings.ToLower(finishReasonResult.String()))
 	}
 
+	// Safety blocks are reported as a refusal rather than an empty message.
+	if blockReason, blockMessage, blocked := common.SafetyBlock(gjson.ParseBytes(rawJSON)); blocked {
+		template, _ = sjson.Set(template, "choices.0.finish_reason", util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonOpenAI, blockReason))
+		template, _ = sjson.Set(template, "choices.0.native_finish_reason", strings.ToLower(blockReason))
+		template, _ = sjson.Set(template, "choices.0.delta.refusal", blockMessage)
+	}
+
+	// Pass through token log probabilities when the upstream returned them.
+	if logprobs, ok := util.GeminiLogprobsToOpenAI(gjson.GetBytes(rawJSON, "candidates.0.logprobsResult")); ok {
+		template, _ = sjson.SetRaw(template, "choices.0.logprobs", logprobs)
+	}
+
 	// Extract and set usage metadata (token counts).
 	if usageResult := gjson.GetBytes(rawJSON, "usageMetadata"); usageResult.Exists() {
 		cachedTokenCount := usageResult.Get("cachedContentTokenCount").Int()
@@ -145,6 +162,7 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 					template, _ = sjson.Set(template, "choices.0.delta.reasoning_content", text)
 				} else {
 					template, _ = sjson.Set(template, "choices.0.delta.content", text)
+					(*param).(*convertGeminiResponseToOpenAIChatParams).Grounding.AddText(text)
 				}
 				template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
 			} else if functionCallResult.Exists() {
@@ -161,7 +179,9 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 
 				functionCallTemplate := `{"id": "","index": 0,"type": "function","function": {"name": "","arguments": ""}}`
 				fcName := functionCallResult.Get("name").String()
-				functionCallTemplate, _ = sjson.Set(functionCallTemplate, "id", fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&functionCallIDCounter, 1)))
+				functionCallID := fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&functionCallIDCounter, 1))
+				common.CacheFunctionCallThoughtSignature(functionCallID, partResult)
+				functionCallTemplate, _ = sjson.Set(functionCallTemplate, "id", functionCallID)
 				functionCallTemplate, _ = sjson.Set(functionCallTemplate, "index", functionCallIndex)
 				functionCallTemplate, _ = sjson.Set(functionCallTemplate, "function.name", fcName)
 				if fcArgsResult := functionCallResult.Get("args"); fcArgsResult.Exists() {
@@ -196,6 +216,15 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 		}
 	}
 
+	// Search grounding arrives with the final chunks; cite it once the candidate finishes.
+	grounding := &(*param).(*convertGeminiResponseToOpenAIChatParams).Grounding
+	grounding.Observe(gjson.GetBytes(rawJSON, "candidates.0.groundingMetadata"))
+	if gjson.GetBytes(rawJSON, "candidates.0.finishReason").Exists() {
+		if annotations := common.OpenAIURLCitations(grounding.Metadata(), grounding.Text(), true); annotations != "" {
+			template, _ = sjson.SetRaw(template, "choices.0.delta.annotations", annotations)
+		}
+	}
+
 	if hasFunctionCall {
 		template, _ = sjson.Set(template, "choices.0.finish_reason", "tool_calls")
 		template, _ = sjson.Set(template, "choices.0.native_finish_reason", "tool_calls")
@@ -239,10 +268,21 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 	}
 
 	if finishReasonResult := gjson.GetBytes(rawJSON, "candidates.0.finishReason"); finishReasonResult.Exists() {
-		template, _ = sjson.Set(template, "choices.0.finish_reason", strings.ToLower(finishReasonResult.String()))
+		template, _ = sjson.Set(template, "choices.0.finish_reason", util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonOpenAI, finishReasonResult.String()))
 		template, _ = sjson.Set(template, "choices.0.native_finish_reason", strings.ToLower(finishReasonResult.String()))
 	}
 
+	// Safety blocks are reported as a refusal rather than an empty message.
+	if blockReason, blockMessage, blocked := common.SafetyBlock(gjson.ParseBytes(rawJSON)); blocked {
+		template, _ = sjson.Set(template, "choices.0.finish_reason", util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonOpenAI, blockReason))
+		template, _ = sjson.Set(template, "choices.0.native_finish_reason", strings.ToLower(blockReason))
+		template, _ = sjson.Set(template, "choices.0.message.refusal", blockMessage)
+	}
+
+	if logprobs, ok := util.GeminiLogprobsToOpenAI(gjson.GetBytes(rawJSON, "candidates.0.logprobsResult")); ok {
+		template, _ = sjson.SetRaw(template, "choices.0.logprobs", logprobs)
+	}
+
 	if usageResult := gjson.GetBytes(rawJSON, "usageMetadata"); usageResult.Exists() {
 		if candidatesTokenCountResult := usageResult.Get("candidatesTokenCount"); candidatesTokenCountResult.Exists() {
 			template, _ = sjson.Set(template, "usage.completion_tokens", candidatesTokenCountResult.Int())
@@ -298,7 +338,9 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 				}
 				functionCallItemTemplate := `{"id": "","type": "function","function": {"name": "","arguments": ""}}`
 				fcName := functionCallResult.Get("name").String()
-				functionCallItemTemplate, _ = sjson.Set(functionCallItemTemplate, "id", fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&functionCallIDCounter, 1)))
+				functionCallID := fmt.Sprintf("%s-%d-%d", fcName, time.Now().UnixNano(), atomic.AddUint64(&functionCallIDCounter, 1))
+				common.CacheFunctionCallThoughtSignature(functionCallID, partResult)
+				functionCallItemTemplate, _ = sjson.Set(functionCallItemTemplate, "id", functionCallID)
 				functionCallItemTemplate, _ = sjson.Set(functionCallItemTemplate, "function.name", fcName)
 				if fcArgsResult := functionCallResult.Get("args"); fcArgsResult.Exists() {
 					functionCallItemTemplate, _ = sjson.Set(functionCallItemTemplate, "function.arguments", fcArgsResult.Raw)
@@ -337,5 +379,11 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 		template, _ = sjson.Set(template, "choices.0.native_finish_reason", "tool_calls")
 	}
 
+	// Surface googleSearch grounding as url_citation annotations.
+	grounding := gjson.GetBytes(rawJSON, "candidates.0.groundingMetadata")
+	if annotations := common.OpenAIURLCitations(grounding, gjson.Get(template, "choices.0.message.content").String(), true); annotations != "" {
+		template, _ = sjson.SetRaw(template, "choices.0.message.annotations", annotations)
+	}
+
 	return template
 }
@@ -0,0 +1,75 @@
+// Package images translates OpenAI /v1/images/generations requests to Gemini. Imagen models are
+// called through their :predict method; Gemini image models through generateContent with image
+// output enabled.
+package images
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ConvertOpenAIImagesRequestToGemini converts an OpenAI image generation request into the Imagen
+// :predict body or a generateContent request, depending on the target model. size becomes the
+// closest supported aspect ratio, high/hd quality requests 2K output, and output_format and
+// output_compression map to Imagen's output options.
+func ConvertOpenAIImagesRequestToGemini(modelName string, inputRawJSON []byte, _ bool) []byte {
+	root := gjson.ParseBytes(inputRawJSON)
+	prompt := root.Get("prompt").String()
+	imageSize := imageSizeForQuality(root.Get("quality").String())
+
+	if util.IsImagenModel(modelName) {
+		out := `{"instances":[{"prompt":""}],"parameters":{"sampleCount":1}}`
+		out, _ = sjson.Set(out, "instances.0.prompt", prompt)
+		if n := root.Get("n").Int(); n > 0 {
+			out, _ = sjson.Set(out, "parameters.sampleCount", n)
+		}
+		if ratio := util.ImageSizeToAspectRatio(root.Get("size").String(), util.ImagenAspectRatios); ratio != "" {
+			out, _ = sjson.Set(out, "parameters.aspectRatio", ratio)
+		}
+		if imageSize != "" {
+			out, _ = sjson.Set(out, "parameters.sampleImageSize", imageSize)
+		}
+		if mimeType := imageMimeType(root.Get("output_format").String()); mimeType != "" {
+			out, _ = sjson.Set(out, "parameters.outputOptions.mimeType", mimeType)
+			if compression := root.Get("output_compression"); compression.Exists() && mimeType == "image/jpeg" {
+				out, _ = sjson.Set(out, "parameters.outputOptions.compressionQuality", compression.Int())
+			}
+		}
+		return []byte(out)
+	}
+
+	out := `{"contents":[{"role":"user","parts":[{"text":""}]}],"generationConfig":{"responseModalities":["IMAGE"]}}`
+	out, _ = sjson.Set(out, "contents.0.parts.0.text", prompt)
+	if ratio := util.ImageSizeToAspectRatio(root.Get("size").String(), util.GeminiImageAspectRatios); ratio != "" {
+		out, _ = sjson.Set(out, "generationConfig.imageConfig.aspectRatio", ratio)
+	}
+	if imageSize != "" {
+		out, _ = sjson.Set(out, "generationConfig.imageConfig.imageSize", imageSize)
+	}
+	return []byte(out)
+}
+
+// imageSizeForQuality maps OpenAI quality values to Gemini output resolutions; lower qualities
+// keep the upstream default.
+func imageSizeForQuality(quality string) string {
+	switch strings.ToLower(strings.TrimSpace(quality)) {
+	case "high", "hd":
+		return "2K"
+	}
+	return ""
+}
+
+func imageMimeType(format string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "png":
+		return "image/png"
+	case "jpeg", "jpg":
+		return "image/jpeg"
+	case "webp":
+		return "image/webp"
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package images
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ConvertGeminiResponseToOpenAIImagesNonStream converts an Imagen :predict response or a
+// generateContent response with inline images into the OpenAI images response. Images are
+// returned as b64_json, or as data URLs when the client asked for response_format "url". A
+// prompt blocked by the safety filters is reported as a content_policy_violation error.
+func ConvertGeminiResponseToOpenAIImagesNonStream(_ context.Context, _ string, originalRequestRawJSON, _, rawJSON []byte, _ *any) string {
+	root := gjson.ParseBytes(rawJSON)
+	asURL := gjson.GetBytes(originalRequestRawJSON, "response_format").String() == "url"
+
+	out := `{"created":0,"data":[]}`
+	out, _ = sjson.Set(out, "created", time.Now().Unix())
+	outputFormat := ""
+	addImage := func(data, mimeType, revisedPrompt string) {
+		if data == "" {
+			return
+		}
+		if mimeType == "" {
+			mimeType = "image/png"
+		}
+		if outputFormat == "" {
+			outputFormat = strings.TrimPrefix(mimeType, "image/")
+		}
+		item := `{}`
+		if asURL {
+			item, _ = sjson.Set(item, "url", "data:"+mimeType+";base64,"+data)
+		} else {
+			item, _ = sjson.Set(item, "b64_json", data)
+		}
+		if revisedPrompt != "" {
+			item, _ = sjson.Set(item, "revised_prompt", revisedPrompt)
+		}
+		out, _ = sjson.SetRaw(out, "data.-1", item)
+	}
+
+	// Imagen :predict
+	root.Get("predictions").ForEach(func(_, prediction gjson.Result) bool {
+		addImage(prediction.Get("bytesBase64Encoded").String(), prediction.Get("mimeType").String(), prediction.Get("prompt").String())
+		return true
+	})
+
+	// generateContent inline image parts
+	root.Get("candidates").ForEach(func(_, candidate gjson.Result) bool {
+		candidate.Get("content.parts").ForEach(func(_, part gjson.Result) bool {
+			inline := part.Get("inlineData")
+			if !inline.Exists() {
+				inline = part.Get("inline_data")
+			}
+			mimeType := inline.Get("mimeType").String()
+			if mimeType == "" {
+				mimeType = inline.Get("mime_type").String()
+			}
+			addImage(inline.Get("data").String(), mimeType, "")
+			return true
+		})
+		return true
+	})
+
+	if outputFormat != "" {
+		out, _ = sjson.Set(out, "output_format", outputFormat)
+	} else if _, message, blocked := common.SafetyBlock(root); blocked {
+		errJSON := `{"error":{"message":"","type":"image_generation_user_error","code":"content_policy_violation"}}`
+		errJSON, _ = sjson.Set(errJSON, "error.message", message)
+		return errJSON
+	}
+
+	if usage := root.Get("usageMetadata"); usage.Exists() {
+		out, _ = sjson.Set(out, "usage.input_tokens", usage.Get("promptTokenCount").Int())
+		out, _ = sjson.Set(out, "usage.output_tokens", usage.Get("candidatesTokenCount").Int())
+		out, _ = sjson.Set(out, "usage.total_tokens", usage.Get("totalTokenCount").Int())
+	}
+	return out
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
@@ -24,11 +25,17 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 
 	root := gjson.ParseBytes(rawJSON)
 
-	// Extract system instruction from OpenAI "instructions" field
+	// Instructions and every system/developer input item become system_instruction parts, in order.
+	addSystemPart := func(text string) {
+		if text == "" {
+			return
+		}
+		part := `{"text":""}`
+		part, _ = sjson.Set(part, "text", text)
+		out, _ = sjson.SetRaw(out, "system_instruction.parts.-1", part)
+	}
 	if instructions := root.Get("instructions"); instructions.Exists() {
-		systemInstr := `{"parts":[{"text":""}]}`
-		systemInstr, _ = sjson.Set(systemInstr, "parts.0.text", instructions.String())
-		out, _ = sjson.SetRaw(out, "system_instruction", systemInstr)
+		addSystemPart(instructions.String())
 	}
 
 	// Convert input messages to Gemini contents format
@@ -118,23 +125,8 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 
 			switch itemType {
 			case "message":
-				if strings.EqualFold(itemRole, "system") {
-					if contentArray := item.Get("content"); contentArray.Exists() && contentArray.IsArray() {
-						var builder strings.Builder
-						contentArray.ForEach(func(_, contentItem gjson.Result) bool {
-							text := contentItem.Get("text").String()
-							if builder.Len() > 0 && text != "" {
-								builder.WriteByte('\n')
-							}
-							builder.WriteString(text)
-							return true
-						})
-						if !gjson.Get(out, "system_instruction").Exists() {
-							systemInstr := `{"parts":[{"text":""}]}`
-							systemInstr, _ = sjson.Set(systemInstr, "parts.0.text", builder.String())
-							out, _ = sjson.SetRaw(out, "system_instruction", systemInstr)
-						}
-					}
+				if util.IsInstructionRole(itemRole) {
+					addSystemPart(strings.Join(util.InstructionTexts(item.Get("content")), "\n"))
 					continue
 				}
 
@@ -248,7 +240,7 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 				modelContent := `{"role":"model","parts":[]}`
 				functionCall := `{"functionCall":{"name":"","args":{}}}`
 				functionCall, _ = sjson.Set(functionCall, "functionCall.name", name)
-				functionCall, _ = sjson.Set(functionCall, "thoughtSignature", geminiResponsesThoughtSignature)
+				functionCall, _ = sjson.Set(functionCall, "thoughtSignature", cache.ToolCallThoughtSignature(item.Get("call_id").String(), geminiResponsesThoughtSignature))
 				functionCall, _ = sjson.Set(functionCall, "functionCall.id", item.Get("call_id").String())
 
 				// Parse arguments JSON string and set as args object
@@ -311,8 +303,22 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 	// Convert tools to Gemini functionDeclarations format
 	if tools := root.Get("tools"); tools.Exists() && tools.IsArray() {
 		geminiTools := `[{"functionDeclarations":[]}]`
+		webSearch := false
+		codeExecution := false
 
 		tools.ForEach(func(_, tool gjson.Result) bool {
+			if common.IsWebSearchTool(tool) {
+				webSearch = true
+				return true
+			}
+			if common.IsCodeExecutionTool(tool) {
+				codeExecution = true
+				return true
+			}
+			// computer_use_preview degrades to a "computer" function taking the action object.
+			if util.IsOpenAIComputerTool(tool) {
+				tool = util.OpenAIComputerToolAsFunction(tool)
+			}
 			if tool.Get("type").String() == "function" {
 				funcDecl := `{"name":"","description":"","parametersJsonSchema":{}}`
 
@@ -350,12 +356,24 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 		if funcDecls := gjson.Get(geminiTools, "0.functionDeclarations"); funcDecls.Exists() && len(funcDecls.Array()) > 0 {
 			out, _ = sjson.SetRaw(out, "tools", geminiTools)
 		}
+		// OpenAI's built-in web search tools map onto googleSearch grounding.
+		if webSearch {
+			out = common.AppendGoogleSearchTool(out, "tools")
+		}
+		if codeExecution {
+			out = common.AppendCodeExecutionTool(out, "tools")
+		}
+	}
+
+	// Map tool_choice -> toolConfig.functionCallingConfig
+	if choice, ok := util.ParseOpenAIToolChoice(root.Get("tool_choice")); ok && gjson.Get(out, "tools").Exists() {
+		out, _ = sjson.SetRaw(out, "toolConfig", choice.GeminiToolConfig())
 	}
 
 	// Handle generation config from OpenAI format
 	if maxOutputTokens := root.Get("max_output_tokens"); maxOutputTokens.Exists() {
 		genConfig := `{"maxOutputTokens":0}`
-		genConfig, _ = sjson.Set(genConfig, "maxOutputTokens", maxOutputTokens.Int())
+		genConfig, _ = sjson.Set(genConfig, "maxOutputTokens", util.BoundMaxTokens(modelName, maxOutputTokens.Int()))
 		out, _ = sjson.SetRaw(out, "generationConfig", genConfig)
 	}
 
@@ -376,16 +394,12 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 	}
 
 	// Handle stop sequences
-	if stopSequences := root.Get("stop_sequences"); stopSequences.Exists() && stopSequences.IsArray() {
-		if !gjson.Get(out, "generationConfig").Exists() {
-			out, _ = sjson.SetRaw(out, "generationConfig", `{}`)
-		}
-		var sequences []string
-		stopSequences.ForEach(func(_, seq gjson.Result) bool {
-			sequences = append(sequences, seq.String())
-			return true
-		})
-		out, _ = sjson.Set(out, "generationConfig.stopSequences", sequences)
+	stopSequences := root.Get("stop_sequences")
+	if !stopSequences.Exists() {
+		stopSequences = root.Get("stop")
+	}
+	if stops, _ := util.LimitStopSequences(util.ParseStopSequences(stopSequences), util.GeminiMaxStopSequences); len(stops) > 0 {
+		out, _ = sjson.Set(out, "generationConfig.stopSequences", stops)
 	}
 
 	// OpenAI official reasoning fields take precedence
@@ -1,13 +1,15 @@
 package responses
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -37,6 +39,14 @@ type geminiToResponsesState struct {
 	FuncArgsBuf map[int]*strings.Builder
 	FuncNames   map[int]string
 	FuncCallIDs map[int]string
+
+	// safety block explanation, surfaced as a refusal message when nothing else was produced
+	RefusalIndex int
+	RefusalMsgID string
+	Refusal      string
+
+	// search grounding, cited when the response finishes
+	Grounding common.GroundingTracker
 }
 
 // responseIDCounter provides a process-wide unique counter for synthesized response identifiers.
@@ -60,8 +70,8 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 	}
 	st := (*param).(*geminiToResponsesState)
 
-	if bytes.HasPrefix(rawJSON, []byte("data:")) {
-		rawJSON = bytes.TrimSpace(rawJSON[5:])
+	if payload, ok := sse.Data(rawJSON); ok {
+		rawJSON = payload
 	}
 
 	root := gjson.ParseBytes(rawJSON)
@@ -194,6 +204,7 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 					st.ItemTextBuf.WriteString(t.String())
 				}
 				st.TextBuf.WriteString(t.String())
+				st.Grounding.AddText(t.String())
 				msg := `{"type":"response.output_text.delta","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"delta":"","logprobs":[]}`
 				msg, _ = sjson.Set(msg, "sequence_number", nextSeq())
 				msg, _ = sjson.Set(msg, "item_id", st.CurrentMsgID)
@@ -217,6 +228,7 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 				if st.FuncCallIDs[idx] == "" {
 					st.FuncCallIDs[idx] = fmt.Sprintf("call_%d_%d", time.Now().UnixNano(), atomic.AddUint64(&funcCallIDCounter, 1))
 				}
+				common.CacheFunctionCallThoughtSignature(st.FuncCallIDs[idx], part)
 				st.FuncNames[idx] = name
 
 				// Emit item.added for function call
@@ -247,13 +259,89 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 		})
 	}
 
-	// Finalization on finishReason
-	if fr := root.Get("candidates.0.finishReason"); fr.Exists() && fr.String() != "" {
+	st.Grounding.Observe(root.Get("candidates.0.groundingMetadata"))
+
+	// Finalization on finishReason; a blocked prompt carries no candidate but finishes the response too.
+	finishReason := root.Get("candidates.0.finishReason").String()
+	blockReason, blockMessage, blocked := common.SafetyBlock(root)
+	if blocked {
+		finishReason = blockReason
+	}
+	if finishReason != "" {
 		// Finalize reasoning first to keep ordering tight with last delta
 		finalizeReasoning()
+		// googleSearch grounding surfaces as a web_search_call item and url_citation annotations
+		webSearchCall := common.ResponsesWebSearchCall(st.Grounding.Metadata(), st.ResponseID)
+		annotations := common.OpenAIURLCitations(st.Grounding.Metadata(), st.Grounding.Text(), false)
+		if webSearchCall != "" {
+			webSearchIndex := st.NextIndex
+			st.NextIndex++
+			added := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{}}`
+			added, _ = sjson.Set(added, "sequence_number", nextSeq())
+			added, _ = sjson.Set(added, "output_index", webSearchIndex)
+			added, _ = sjson.SetRaw(added, "item", webSearchCall)
+			out = append(out, emitEvent("response.output_item.added", added))
+			done := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{}}`
+			done, _ = sjson.Set(done, "sequence_number", nextSeq())
+			done, _ = sjson.Set(done, "output_index", webSearchIndex)
+			done, _ = sjson.SetRaw(done, "item", webSearchCall)
+			out = append(out, emitEvent("response.output_item.done", done))
+		}
+		if blocked && !st.MsgOpened && len(st.FuncArgsBuf) == 0 {
+			st.Refusal = blockMessage
+			st.RefusalIndex = st.NextIndex
+			st.NextIndex++
+			st.RefusalMsgID = fmt.Sprintf("msg_%s_0", st.ResponseID)
+			item := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"in_progress","content":[],"role":"assistant"}}`
+			item, _ = sjson.Set(item, "sequence_number", nextSeq())
+			item, _ = sjson.Set(item, "output_index", st.RefusalIndex)
+			item, _ = sjson.Set(item, "item.id", st.RefusalMsgID)
+			out = append(out, emitEvent("response.output_item.added", item))
+			partAdded := `{"type":"response.content_part.added","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"refusal","refusal":""}}`
+			partAdded, _ = sjson.Set(partAdded, "sequence_number", nextSeq())
+			partAdded, _ = sjson.Set(partAdded, "item_id", st.RefusalMsgID)
+			partAdded, _ = sjson.Set(partAdded, "output_index", st.RefusalIndex)
+			out = append(out, emitEvent("response.content_part.added", partAdded))
+			delta := `{"type":"response.refusal.delta","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"delta":""}`
+			delta, _ = sjson.Set(delta, "sequence_number", nextSeq())
+			delta, _ = sjson.Set(delta, "item_id", st.RefusalMsgID)
+			delta, _ = sjson.Set(delta, "output_index", st.RefusalIndex)
+			delta, _ = sjson.Set(delta, "delta", st.Refusal)
+			out = append(out, emitEvent("response.refusal.delta", delta))
+			done := `{"type":"response.refusal.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"refusal":""}`
+			done, _ = sjson.Set(done, "sequence_number", nextSeq())
+			done, _ = sjson.Set(done, "item_id", st.RefusalMsgID)
+			done, _ = sjson.Set(done, "output_index", st.RefusalIndex)
+			done, _ = sjson.Set(done, "refusal", st.Refusal)
+			out = append(out, emitEvent("response.refusal.done", done))
+			partDone := `{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"refusal","refusal":""}}`
+			partDone, _ = sjson.Set(partDone, "sequence_number", nextSeq())
+			partDone, _ = sjson.Set(partDone, "item_id", st.RefusalMsgID)
+			partDone, _ = sjson.Set(partDone, "output_index", st.RefusalIndex)
+			partDone, _ = sjson.Set(partDone, "part.refusal", st.Refusal)
+			out = append(out, emitEvent("response.content_part.done", partDone))
+			final := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"refusal","refusal":""}],"role":"assistant"}}`
+			final, _ = sjson.Set(final, "sequence_number", nextSeq())
+			final, _ = sjson.Set(final, "output_index", st.RefusalIndex)
+			final, _ = sjson.Set(final, "item.id", st.RefusalMsgID)
+			final, _ = sjson.Set(final, "item.content.0.refusal", st.Refusal)
+			out = append(out, emitEvent("response.output_item.done", final))
+		}
 		// Close message output if opened
 		if st.MsgOpened {
 			fullText := st.ItemTextBuf.String()
+			if annotations != "" {
+				gjson.Parse(annotations).ForEach(func(i, annotation gjson.Result) bool {
+					added := `{"type":"response.output_text.annotation.added","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"annotation_index":0,"annotation":{}}`
+					added, _ = sjson.Set(added, "sequence_number", nextSeq())
+					added, _ = sjson.Set(added, "item_id", st.CurrentMsgID)
+					added, _ = sjson.Set(added, "output_index", st.MsgIndex)
+					added, _ = sjson.Set(added, "annotation_index", i.Int())
+					added, _ = sjson.SetRaw(added, "annotation", annotation.Raw)
+					out = append(out, emitEvent("response.output_text.annotation.added", added))
+					return true
+				})
+			}
 			done := `{"type":"response.output_text.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"text":"","logprobs":[]}`
 			done, _ = sjson.Set(done, "sequence_number", nextSeq())
 			done, _ = sjson.Set(done, "item_id", st.CurrentMsgID)
@@ -265,12 +353,18 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 			partDone, _ = sjson.Set(partDone, "item_id", st.CurrentMsgID)
 			partDone, _ = sjson.Set(partDone, "output_index", st.MsgIndex)
 			partDone, _ = sjson.Set(partDone, "part.text", fullText)
+			if annotations != "" {
+				partDone, _ = sjson.SetRaw(partDone, "part.annotations", annotations)
+			}
 			out = append(out, emitEvent("response.content_part.done", partDone))
 			final := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","text":""}],"role":"assistant"}}`
 			final, _ = sjson.Set(final, "sequence_number", nextSeq())
 			final, _ = sjson.Set(final, "output_index", st.MsgIndex)
 			final, _ = sjson.Set(final, "item.id", st.CurrentMsgID)
 			final, _ = sjson.Set(final, "item.content.0.text", fullText)
+			if annotations != "" {
+				final, _ = sjson.SetRaw(final, "item.content.0.annotations", annotations)
+			}
 			out = append(out, emitEvent("response.output_item.done", final))
 		}
 
@@ -391,10 +485,22 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 			item, _ = sjson.Set(item, "summary.0.text", st.ReasoningBuf.String())
 			outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
 		}
+		if webSearchCall != "" {
+			outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", webSearchCall)
+		}
 		if st.MsgOpened {
 			item := `{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`
 			item, _ = sjson.Set(item, "id", st.CurrentMsgID)
 			item, _ = sjson.Set(item, "content.0.text", st.TextBuf.String())
+			if annotations != "" {
+				item, _ = sjson.SetRaw(item, "content.0.annotations", annotations)
+			}
+			outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
+		}
+		if st.Refusal != "" {
+			item := `{"id":"","type":"message","status":"completed","content":[{"type":"refusal","refusal":""}],"role":"assistant"}`
+			item, _ = sjson.Set(item, "id", st.RefusalMsgID)
+			item, _ = sjson.Set(item, "content.0.refusal", st.Refusal)
 			outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
 		}
 		if len(st.FuncArgsBuf) > 0 {
@@ -451,6 +557,13 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 			}
 		}
 
+		if reason := util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonResponses, finishReason); reason != "" {
+			completed, _ = sjson.Set(completed, "type", "response.incomplete")
+			completed, _ = sjson.Set(completed, "response.status", "incomplete")
+			completed, _ = sjson.Set(completed, "response.incomplete_details.reason", reason)
+			out = append(out, emitEvent("response.incomplete", completed))
+			return out
+		}
 		out = append(out, emitEvent("response.completed", completed))
 	}
 
@@ -592,6 +705,7 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 				name := fc.Get("name").String()
 				args := fc.Get("args")
 				callID := fmt.Sprintf("call_%x_%d", time.Now().UnixNano(), atomic.AddUint64(&funcCallIDCounter, 1))
+				common.CacheFunctionCallThoughtSignature(callID, p)
 				itemJSON := `{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}`
 				itemJSON, _ = sjson.Set(itemJSON, "id", fmt.Sprintf("fc_%s", callID))
 				itemJSON, _ = sjson.Set(itemJSON, "call_id", callID)
@@ -623,14 +737,35 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		appendOutput(itemJSON)
 	}
 
+	// googleSearch grounding output items
+	grounding := root.Get("candidates.0.groundingMetadata")
+	if item := common.ResponsesWebSearchCall(grounding, strings.TrimPrefix(id, "resp_")); item != "" {
+		appendOutput(item)
+	}
+
 	// Assistant message output item
 	if haveMessage {
 		itemJSON := `{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`
 		itemJSON, _ = sjson.Set(itemJSON, "id", fmt.Sprintf("msg_%s_0", strings.TrimPrefix(id, "resp_")))
 		itemJSON, _ = sjson.Set(itemJSON, "content.0.text", messageText.String())
+		if annotations := common.OpenAIURLCitations(grounding, messageText.String(), false); annotations != "" {
+			itemJSON, _ = sjson.SetRaw(itemJSON, "content.0.annotations", annotations)
+		}
 		appendOutput(itemJSON)
 	}
 
+	// A safety block with no visible output is surfaced as a refusal message.
+	finishReason := root.Get("candidates.0.finishReason").String()
+	if blockReason, blockMessage, blocked := common.SafetyBlock(root); blocked {
+		finishReason = blockReason
+		if !haveMessage && !gjson.Get(resp, `output.#(type=="function_call")`).Exists() {
+			itemJSON := `{"id":"","type":"message","status":"completed","content":[{"type":"refusal","refusal":""}],"role":"assistant"}`
+			itemJSON, _ = sjson.Set(itemJSON, "id", fmt.Sprintf("msg_%s_0", strings.TrimPrefix(id, "resp_")))
+			itemJSON, _ = sjson.Set(itemJSON, "content.0.refusal", blockMessage)
+			appendOutput(itemJSON)
+		}
+	}
+
 	// usage mapping
 	if um := root.Get("usageMetadata"); um.Exists() {
 		// input tokens = prompt + thoughts
@@ -650,5 +785,10 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		}
 	}
 
+	if reason := util.MapFinishReason(util.FinishReasonGemini, util.FinishReasonResponses, finishReason); reason != "" {
+		resp, _ = sjson.Set(resp, "status", "incomplete")
+		resp, _ = sjson.Set(resp, "incomplete_details.reason", reason)
+	}
+
 	return resp
 }
@@ -20,7 +20,9 @@ import (
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/claude"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/gemini"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/gemini-cli"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/audio"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/images"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/responses"
 
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/claude"
@@ -0,0 +1,138 @@
+package claude
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
+)
+
+// streamDeduper merges streamed text frames according to the upstream's dedupe mode and
+// counts how often deduplication changed a frame.
+type streamDeduper struct {
+	upstream string
+	mode     string
+}
+
+// StreamDedupeStats reports how often streamed frames of one upstream were deduplicated.
+type StreamDedupeStats struct {
+	Upstream string `json:"upstream"`
+	Mode     string `json:"mode"`
+	// Frames counts text and tool argument frames received after the first.
+	Frames uint64 `json:"frames"`
+	// Trimmed counts frames of which only a new suffix was emitted.
+	Trimmed uint64 `json:"trimmed"`
+	// Dropped counts frames that were discarded as repeats.
+	Dropped uint64 `json:"dropped"`
+}
+
+var (
+	streamDedupeMu    sync.Mutex
+	streamDedupeStats = make(map[[2]string]*StreamDedupeStats)
+)
+
+// GetStreamDedupeStats returns the dedupe counters of every upstream seen so far.
+func GetStreamDedupeStats() []StreamDedupeStats {
+	streamDedupeMu.Lock()
+	out := make([]StreamDedupeStats, 0, len(streamDedupeStats))
+	for _, s := range streamDedupeStats {
+		out = append(out, *s)
+	}
+	streamDedupeMu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Upstream != out[j].Upstream {
+			return out[i].Upstream < out[j].Upstream
+		}
+		return out[i].Mode < out[j].Mode
+	})
+	return out
+}
+
+func newStreamDeduper(ctx context.Context) streamDeduper {
+	upstream, mode := translator.StreamDedupeFromContext(ctx)
+	return streamDeduper{upstream: upstream, mode: mode}
+}
+
+func (d streamDeduper) record(trimmed, dropped bool) {
+	streamDedupeMu.Lock()
+	defer streamDedupeMu.Unlock()
+	upstream, mode := d.upstream, d.mode
+	if upstream == "" {
+		upstream = "default"
+	}
+	if mode == "" {
+		mode = translator.StreamDedupeAuto
+	}
+	key := [2]string{upstream, mode}
+	s := streamDedupeStats[key]
+	if s == nil {
+		s = &StreamDedupeStats{Upstream: upstream, Mode: mode}
+		streamDedupeStats[key] = s
+	}
+	s.Frames++
+	if trimmed {
+		s.Trimmed++
+	}
+	if dropped {
+		s.Dropped++
+	}
+}
+
+// text merges a text frame into the text streamed so far and returns the part to emit.
+func (d streamDeduper) text(soFar, incoming string) (delta, next string) {
+	if incoming == "" {
+		return "", soFar
+	}
+	if soFar == "" {
+		return incoming, incoming
+	}
+	switch d.mode {
+	case translator.StreamDedupeDelta:
+		delta, next = incoming, soFar+incoming
+	case translator.StreamDedupeSnapshot:
+		// Text already emitted cannot be revised, so only what extends past it is new.
+		next = soFar
+		if len(incoming) > len(soFar) {
+			delta, next = incoming[len(soFar):], incoming
+		}
+	default:
+		delta, next = computeStreamDelta(soFar, incoming)
+	}
+	d.record(delta != "" && delta != incoming, delta == "")
+	return delta, next
+}
+
+// arguments merges a tool call arguments frame into the arguments accumulated so far.
+func (d streamDeduper) arguments(current *strings.Builder, incoming string) {
+	if incoming == "" {
+		return
+	}
+	soFar := current.String()
+	if soFar == "" {
+		current.WriteString(incoming)
+		return
+	}
+	switch {
+	case d.mode == translator.StreamDedupeDelta:
+		current.WriteString(incoming)
+		d.record(false, false)
+	case incoming == soFar:
+		d.record(false, true)
+	case strings.HasPrefix(incoming, soFar):
+		current.WriteString(incoming[len(soFar):])
+		d.record(true, false)
+	case d.mode == translator.StreamDedupeSnapshot:
+		// A revised snapshot replaces the arguments, which are only emitted once complete.
+		current.Reset()
+		current.WriteString(incoming)
+		d.record(false, false)
+	case strings.HasSuffix(soFar, incoming):
+		// Ignore exact duplicate fragments.
+		d.record(false, true)
+	default:
+		current.WriteString(incoming)
+		d.record(false, false)
+	}
+}
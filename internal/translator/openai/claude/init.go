@@ -11,10 +11,11 @@ func init() {
 		Claude,
 		OpenAI,
 		ConvertClaudeRequestToOpenAI,
-		interfaces.TranslateResponse{
+		translator.WithClaudePrefill(interfaces.TranslateResponse{
 			Stream:     ConvertOpenAIResponseToClaude,
 			NonStream:  ConvertOpenAIResponseToClaudeNonStream,
 			TokenCount: ClaudeTokenCount,
-		},
+		}),
 	)
+	translator.RegisterRequestContext(Claude, OpenAI, ConvertClaudeRequestToOpenAIContext)
 }
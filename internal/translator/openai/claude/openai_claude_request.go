@@ -7,6 +7,7 @@ package claude
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"strings"
 
@@ -19,6 +20,12 @@ import (
 // It extracts the model name, system instruction, message contents, and tool declarations
 // from the raw JSON request and returns them in the format expected by the OpenAI API.
 func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream bool) []byte {
+	return ConvertClaudeRequestToOpenAIContext(context.Background(), modelName, inputRawJSON, stream)
+}
+
+// ConvertClaudeRequestToOpenAIContext is ConvertClaudeRequestToOpenAI for a request context. The
+// calling client in ctx scopes the tool_use IDs resolved back to upstream tool call IDs.
+func ConvertClaudeRequestToOpenAIContext(ctx context.Context, modelName string, inputRawJSON []byte, stream bool) []byte {
 	rawJSON := bytes.Clone(inputRawJSON)
 	// Base OpenAI Chat Completions API template
 	out := `{"model":"","messages":[]}`
@@ -30,7 +37,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 
 	// Max tokens
 	if maxTokens := root.Get("max_tokens"); maxTokens.Exists() {
-		out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
+		out, _ = sjson.Set(out, "max_tokens", util.BoundMaxTokens(modelName, maxTokens.Int()))
 	}
 
 	// Temperature
@@ -40,21 +47,12 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 		out, _ = sjson.Set(out, "top_p", topP.Float())
 	}
 
-	// Stop sequences -> stop
-	if stopSequences := root.Get("stop_sequences"); stopSequences.Exists() {
-		if stopSequences.IsArray() {
-			var stops []string
-			stopSequences.ForEach(func(_, value gjson.Result) bool {
-				stops = append(stops, value.String())
-				return true
-			})
-			if len(stops) > 0 {
-				if len(stops) == 1 {
-					out, _ = sjson.Set(out, "stop", stops[0])
-				} else {
-					out, _ = sjson.Set(out, "stop", stops)
-				}
-			}
+	// Stop sequences -> stop; sequences beyond OpenAI's limit are enforced on the response
+	if stops, _ := util.LimitStopSequences(util.ParseStopSequences(root.Get("stop_sequences")), util.OpenAIMaxStopSequences); len(stops) > 0 {
+		if len(stops) == 1 {
+			out, _ = sjson.Set(out, "stop", stops[0])
+		} else {
+			out, _ = sjson.Set(out, "stop", stops)
 		}
 	}
 
@@ -111,6 +109,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	messagesJSON, _ = sjson.SetRaw(messagesJSON, "-1", systemMsgJSON)
 
 	// Process Anthropic messages
+	toolSessionKey := toolIDSessionKey(ctx, rawJSON)
 	if messages := root.Get("messages"); messages.Exists() && messages.IsArray() {
 		messages.ForEach(func(_, message gjson.Result) bool {
 			role := message.Get("role").String()
@@ -151,7 +150,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						if role == "assistant" {
 							toolCallJSON := `{"id":"","type":"function","function":{"name":"","arguments":""}}`
 							toolUseID := part.Get("id").String()
-							if mapped, ok := resolveToolUseIDMapping(toolUseID); ok {
+							if mapped, ok := resolveToolUseIDMapping(toolSessionKey, toolUseID); ok {
 								toolUseID = mapped
 							}
 							toolCallJSON, _ = sjson.Set(toolCallJSON, "id", toolUseID)
@@ -171,7 +170,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						// Collect tool_result to emit after the main message (ensures tool results follow tool_calls)
 						toolResultJSON := `{"role":"tool","tool_call_id":"","content":""}`
 						toolUseID := part.Get("tool_use_id").String()
-						if mapped, ok := resolveToolUseIDMapping(toolUseID); ok {
+						if mapped, ok := resolveToolUseIDMapping(toolSessionKey, toolUseID); ok {
 							toolUseID = mapped
 						}
 						toolResultJSON, _ = sjson.Set(toolResultJSON, "tool_call_id", toolUseID)
@@ -270,6 +269,8 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 		var toolsJSON = "[]"
 
 		tools.ForEach(func(_, tool gjson.Result) bool {
+			// Computer use, bash and text editor tools degrade to functions with explicit schemas.
+			tool = util.ClaudeClientToolAsFunction(tool)
 			openAIToolJSON := `{"type":"function","function":{"name":"","description":""}}`
 			openAIToolJSON, _ = sjson.Set(openAIToolJSON, "function.name", tool.Get("name").String())
 			openAIToolJSON, _ = sjson.Set(openAIToolJSON, "function.description", sanitizeToolDescription(tool.Get("description").String()))
@@ -289,22 +290,8 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	}
 
 	// Tool choice mapping - convert Anthropic tool_choice to OpenAI format
-	if toolChoice := root.Get("tool_choice"); toolChoice.Exists() {
-		switch toolChoice.Get("type").String() {
-		case "auto":
-			out, _ = sjson.Set(out, "tool_choice", "auto")
-		case "any":
-			out, _ = sjson.Set(out, "tool_choice", "required")
-		case "tool":
-			// Specific tool choice
-			toolName := toolChoice.Get("name").String()
-			toolChoiceJSON := `{"type":"function","function":{"name":""}}`
-			toolChoiceJSON, _ = sjson.Set(toolChoiceJSON, "function.name", toolName)
-			out, _ = sjson.SetRaw(out, "tool_choice", toolChoiceJSON)
-		default:
-			// Default to auto if not specified
-			out, _ = sjson.Set(out, "tool_choice", "auto")
-		}
+	if choice, ok := util.ParseClaudeToolChoice(root.Get("tool_choice")); ok {
+		out, _ = sjson.SetRaw(out, "tool_choice", choice.OpenAIChat())
 	}
 
 	// Handle user parameter (for tracking)
@@ -6,30 +6,30 @@
 package claude
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
-var (
-	dataTag = []byte("data:")
-)
-
 // ConvertOpenAIResponseToAnthropicParams holds parameters for response conversion
 type ConvertOpenAIResponseToAnthropicParams struct {
 	MessageID   string
 	Model       string
 	CreatedAt   int64
 	RequestSeed string
+	// SessionKey scopes tool_use ID mappings to the originating conversation.
+	SessionKey string
 	// Track running text/thinking content to support upstreams that stream full snapshots
 	// instead of incremental deltas.
 	TextSoFar     string
 	ThinkingSoFar string
+	// Dedupe merges snapshot frames according to the upstream's configured stream mode.
+	Dedupe streamDeduper
 	// Tool calls accumulator for streaming
 	ToolCallsAccumulator map[int]*ToolCallAccumulator
 	// Track if text content block has been started
@@ -38,6 +38,8 @@ type ConvertOpenAIResponseToAnthropicParams struct {
 	ThinkingContentBlockStarted bool
 	// Track finish reason for later use
 	FinishReason string
+	// Track if the upstream refused; the refusal streams as text and ends with a "refusal" stop reason
+	Refused bool
 	// Track if content blocks have been stopped
 	ContentBlocksStopped bool
 	// Track if message_delta has been sent
@@ -77,15 +79,17 @@ type ToolCallAccumulator struct {
 //
 // Returns:
 //   - []string: A slice of strings, each containing an Anthropic-compatible JSON response.
-func ConvertOpenAIResponseToClaude(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+func ConvertOpenAIResponseToClaude(ctx context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
 		*param = &ConvertOpenAIResponseToAnthropicParams{
 			MessageID:                   "",
 			Model:                       "",
 			CreatedAt:                   0,
-			RequestSeed:                 requestSeedFromPayload(originalRequestRawJSON),
+			RequestSeed:                 requestSeedFromContext(ctx, originalRequestRawJSON),
+			SessionKey:                  toolIDSessionKey(ctx, originalRequestRawJSON),
 			TextSoFar:                   "",
 			ThinkingSoFar:               "",
+			Dedupe:                      newStreamDeduper(ctx),
 			ToolCallsAccumulator:        nil,
 			TextContentBlockStarted:     false,
 			ThinkingContentBlockStarted: false,
@@ -99,10 +103,11 @@ func ConvertOpenAIResponseToClaude(_ context.Context, _ string, originalRequestR
 		}
 	}
 
-	if !bytes.HasPrefix(rawJSON, dataTag) {
+	payload, ok := sse.Data(rawJSON)
+	if !ok {
 		return []string{}
 	}
-	rawJSON = bytes.TrimSpace(rawJSON[5:])
+	rawJSON = payload
 
 	// Check if this is the [DONE] marker
 	rawStr := strings.TrimSpace(string(rawJSON))
@@ -153,7 +158,7 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 		if reasoning := delta.Get("reasoning_content"); reasoning.Exists() {
 			combined := strings.Join(collectOpenAIReasoningTexts(reasoning), "")
 			if combined != "" {
-				thinkingDelta, nextThinking := computeStreamDelta(param.ThinkingSoFar, combined)
+				thinkingDelta, nextThinking := param.Dedupe.text(param.ThinkingSoFar, combined)
 				param.ThinkingSoFar = nextThinking
 				if thinkingDelta != "" {
 					stopTextContentBlock(param, &results)
@@ -178,8 +183,13 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 
 		// Handle content delta.
 		// Some upstreams send the full content snapshot on every frame; emit only the new suffix.
-		if content := delta.Get("content"); content.Exists() && content.String() != "" {
-			textDelta, nextText := computeStreamDelta(param.TextSoFar, content.String())
+		content := delta.Get("content")
+		if refusal := delta.Get("refusal"); refusal.Type == gjson.String && refusal.String() != "" {
+			content = refusal
+			param.Refused = true
+		}
+		if content.Exists() && content.String() != "" {
+			textDelta, nextText := param.Dedupe.text(param.TextSoFar, content.String())
 			param.TextSoFar = nextText
 			if textDelta != "" {
 				// Send content_block_start for text if not already sent
@@ -202,6 +212,20 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 			}
 		}
 
+		// url_citation annotations become citations on the open text block.
+		if annotations := delta.Get("annotations"); annotations.IsArray() && param.TextContentBlockStarted {
+			text := []rune(param.TextSoFar)
+			annotations.ForEach(func(_, annotation gjson.Result) bool {
+				if citation, ok := util.ParseOpenAIAnnotation(annotation); ok {
+					citationDeltaJSON := `{"type":"content_block_delta","index":0,"delta":{"type":"citations_delta","citation":{}}}`
+					citationDeltaJSON, _ = sjson.Set(citationDeltaJSON, "index", param.TextContentBlockIndex)
+					citationDeltaJSON, _ = sjson.SetRaw(citationDeltaJSON, "delta.citation", citation.ClaudeCitation(util.CitationSpan(text, citation)))
+					results = append(results, "event: content_block_delta\ndata: "+citationDeltaJSON+"\n\n")
+				}
+				return true
+			})
+		}
+
 		// Handle tool calls
 		if toolCalls := delta.Get("tool_calls"); toolCalls.Exists() && toolCalls.IsArray() {
 			if param.ToolCallsAccumulator == nil {
@@ -235,22 +259,8 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 
 					// Handle function arguments
 					if args := function.Get("arguments"); args.Exists() {
-						argsText := args.String()
-						if argsText != "" {
-							current := accumulator.Arguments.String()
-							// Some upstreams send full argument snapshots instead of incremental deltas.
-							// Prefer appending only the new suffix when possible to avoid duplicated JSON fragments.
-							switch {
-							case current == "":
-								accumulator.Arguments.WriteString(argsText)
-							case strings.HasPrefix(argsText, current):
-								accumulator.Arguments.WriteString(argsText[len(current):])
-							case strings.HasSuffix(current, argsText):
-								// Ignore exact duplicate fragments.
-							default:
-								accumulator.Arguments.WriteString(argsText)
-							}
-						}
+						// Some upstreams send full argument snapshots instead of incremental deltas.
+						param.Dedupe.arguments(&accumulator.Arguments, args.String())
 					}
 				}
 
@@ -264,7 +274,7 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 					if strings.TrimSpace(accumulator.StableID) == "" {
 						accumulator.StableID = stableToolUseID(param.RequestSeed, index)
 					}
-					registerToolUseIDMapping(accumulator.StableID, accumulator.ID)
+					registerToolUseIDMapping(param.SessionKey, accumulator.StableID, accumulator.ID)
 					blockIndex := param.toolContentBlockIndex(index)
 
 					stopThinkingContentBlock(param, &results)
@@ -315,7 +325,7 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 					if strings.TrimSpace(accumulator.StableID) == "" {
 						accumulator.StableID = stableToolUseID(param.RequestSeed, index)
 					}
-					registerToolUseIDMapping(accumulator.StableID, accumulator.ID)
+					registerToolUseIDMapping(param.SessionKey, accumulator.StableID, accumulator.ID)
 					blockIndex := param.toolContentBlockIndex(index)
 					contentBlockStartJSON := `{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"","name":"","input":{}}}`
 					contentBlockStartJSON, _ = sjson.Set(contentBlockStartJSON, "index", blockIndex)
@@ -362,7 +372,7 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 			}
 			// Send message_delta with usage
 			messageDeltaJSON := `{"type":"message_delta","delta":{"stop_reason":"","stop_sequence":null},"usage":{"input_tokens":0,"output_tokens":0}}`
-			messageDeltaJSON, _ = sjson.Set(messageDeltaJSON, "delta.stop_reason", mapOpenAIFinishReasonToAnthropic(param.FinishReason))
+			messageDeltaJSON, _ = sjson.Set(messageDeltaJSON, "delta.stop_reason", param.stopReason())
 			messageDeltaJSON, _ = sjson.Set(messageDeltaJSON, "usage.input_tokens", inputTokens)
 			messageDeltaJSON, _ = sjson.Set(messageDeltaJSON, "usage.output_tokens", outputTokens)
 			results = append(results, "event: message_delta\ndata: "+messageDeltaJSON+"\n\n")
@@ -404,7 +414,7 @@ func convertOpenAIDoneToAnthropic(param *ConvertOpenAIResponseToAnthropicParams)
 				if strings.TrimSpace(accumulator.StableID) == "" {
 					accumulator.StableID = stableToolUseID(param.RequestSeed, index)
 				}
-				registerToolUseIDMapping(accumulator.StableID, accumulator.ID)
+				registerToolUseIDMapping(param.SessionKey, accumulator.StableID, accumulator.ID)
 				blockIndex := param.toolContentBlockIndex(index)
 				contentBlockStartJSON := `{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"","name":"","input":{}}}`
 				contentBlockStartJSON, _ = sjson.Set(contentBlockStartJSON, "index", blockIndex)
@@ -433,12 +443,8 @@ func convertOpenAIDoneToAnthropic(param *ConvertOpenAIResponseToAnthropicParams)
 
 	// Ensure message_delta is emitted before message_stop, even if upstream omitted finish_reason/usage.
 	if !param.MessageDeltaSent {
-		stopReason := "end_turn"
-		if param.FinishReason != "" {
-			stopReason = mapOpenAIFinishReasonToAnthropic(param.FinishReason)
-		}
 		messageDeltaJSON := `{"type":"message_delta","delta":{"stop_reason":"","stop_sequence":null}}`
-		messageDeltaJSON, _ = sjson.Set(messageDeltaJSON, "delta.stop_reason", stopReason)
+		messageDeltaJSON, _ = sjson.Set(messageDeltaJSON, "delta.stop_reason", param.stopReason())
 		results = append(results, "event: message_delta\ndata: "+messageDeltaJSON+"\n\n")
 		param.MessageDeltaSent = true
 	}
@@ -472,9 +478,9 @@ func convertOpenAINonStreamingToAnthropic(rawJSON []byte) []string {
 
 		// Handle text content
 		if content := choice.Get("message.content"); content.Exists() && content.String() != "" {
-			block := `{"type":"text","text":""}`
-			block, _ = sjson.Set(block, "text", content.String())
-			out, _ = sjson.SetRaw(out, "content.-1", block)
+			for _, block := range citedTextBlocks(content.String(), choice.Get("message.annotations")) {
+				out, _ = sjson.SetRaw(out, "content.-1", block)
+			}
 		}
 
 		// Handle tool calls
@@ -502,8 +508,12 @@ func convertOpenAINonStreamingToAnthropic(rawJSON []byte) []string {
 		}
 
 		// Set stop reason
+		hasToolCalls := len(choice.Get("message.tool_calls").Array()) > 0
 		if finishReason := choice.Get("finish_reason"); finishReason.Exists() {
-			out, _ = sjson.Set(out, "stop_reason", mapOpenAIFinishReasonToAnthropic(finishReason.String()))
+			out, _ = sjson.Set(out, "stop_reason", anthropicStopReason(finishReason.String(), hasToolCalls))
+		}
+		if refusal := choice.Get("message.refusal").String(); refusal != "" && !hasToolCalls {
+			out = appendRefusal(out, refusal)
 		}
 	}
 
@@ -523,20 +533,29 @@ func convertOpenAINonStreamingToAnthropic(rawJSON []byte) []string {
 
 // mapOpenAIFinishReasonToAnthropic maps OpenAI finish reasons to Anthropic equivalents
 func mapOpenAIFinishReasonToAnthropic(openAIReason string) string {
-	switch openAIReason {
-	case "stop":
-		return "end_turn"
-	case "length":
-		return "max_tokens"
-	case "tool_calls":
-		return "tool_use"
-	case "content_filter":
-		return "end_turn" // Anthropic doesn't have direct equivalent
-	case "function_call": // Legacy OpenAI
+	return util.MapFinishReason(util.FinishReasonOpenAI, util.FinishReasonClaude, openAIReason)
+}
+
+// anthropicStopReason maps a finish reason while accounting for forced tool calls: OpenAI reports
+// "stop" when tool_choice names a specific function, but the turn still ended with tool use.
+func anthropicStopReason(openAIReason string, hasToolCalls bool) string {
+	if hasToolCalls && openAIReason == "stop" {
 		return "tool_use"
-	default:
+	}
+	return mapOpenAIFinishReasonToAnthropic(openAIReason)
+}
+
+// stopReason resolves the stop reason of a streamed message, defaulting to end_turn when the
+// upstream omitted finish_reason.
+func (p *ConvertOpenAIResponseToAnthropicParams) stopReason() string {
+	hasToolCalls := len(p.ToolCallsAccumulator) > 0
+	if p.Refused && !hasToolCalls {
+		return "refusal"
+	}
+	if p.FinishReason == "" {
 		return "end_turn"
 	}
+	return anthropicStopReason(p.FinishReason, hasToolCalls)
 }
 
 func (p *ConvertOpenAIResponseToAnthropicParams) toolContentBlockIndex(openAIToolIndex int) int {
@@ -639,7 +658,6 @@ func computeStreamDelta(soFar, incoming string) (delta, next string) {
 // Returns:
 //   - string: An Anthropic-compatible JSON response.
 func ConvertOpenAIResponseToClaudeNonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
-	_ = originalRequestRawJSON
 	_ = requestRawJSON
 
 	root := gjson.ParseBytes(rawJSON)
@@ -654,7 +672,8 @@ func ConvertOpenAIResponseToClaudeNonStream(_ context.Context, _ string, origina
 		choice := choices.Array()[0]
 
 		if finishReason := choice.Get("finish_reason"); finishReason.Exists() {
-			out, _ = sjson.Set(out, "stop_reason", mapOpenAIFinishReasonToAnthropic(finishReason.String()))
+			hasToolCalls := len(choice.Get("message.tool_calls").Array()) > 0
+			out, _ = sjson.Set(out, "stop_reason", anthropicStopReason(finishReason.String(), hasToolCalls))
 			stopReasonSet = true
 		}
 
@@ -732,9 +751,9 @@ func ConvertOpenAIResponseToClaudeNonStream(_ context.Context, _ string, origina
 				} else if contentResult.Type == gjson.String {
 					textContent := contentResult.String()
 					if textContent != "" {
-						block := `{"type":"text","text":""}`
-						block, _ = sjson.Set(block, "text", textContent)
-						out, _ = sjson.SetRaw(out, "content.-1", block)
+						for _, block := range citedTextBlocks(textContent, message.Get("annotations")) {
+							out, _ = sjson.SetRaw(out, "content.-1", block)
+						}
 					}
 				}
 			}
@@ -789,9 +808,30 @@ func ConvertOpenAIResponseToClaudeNonStream(_ context.Context, _ string, origina
 		}
 	}
 
+	if refusal := root.Get("choices.0.message.refusal").String(); refusal != "" && !hasToolCall {
+		out = appendRefusal(out, refusal)
+	}
+
+	// Stop sequences beyond OpenAI's limit were not forwarded; enforce them here.
+	_, overflow := util.LimitStopSequences(util.ParseStopSequences(gjson.GetBytes(originalRequestRawJSON, "stop_sequences")), util.OpenAIMaxStopSequences)
+	return util.ApplyClaudeStopSequences(out, overflow)
+}
+
+// appendRefusal surfaces an OpenAI refusal as Claude text ending the message with a "refusal"
+// stop reason, so clients can tell a policy block from an empty answer.
+func appendRefusal(out, refusal string) string {
+	block, _ := sjson.Set(`{"type":"text","text":""}`, "text", refusal)
+	out, _ = sjson.SetRaw(out, "content.-1", block)
+	out, _ = sjson.Set(out, "stop_reason", "refusal")
 	return out
 }
 
+// citedTextBlocks renders text as Claude text blocks, splitting out the spans cited by OpenAI
+// url_citation annotations so each carries its citations.
+func citedTextBlocks(text string, annotations gjson.Result) []string {
+	return util.ClaudeTextBlocks(text, util.ParseOpenAIAnnotations(annotations))
+}
+
 func ClaudeTokenCount(ctx context.Context, count int64) string {
 	return fmt.Sprintf(`{"input_tokens":%d}`, count)
 }
@@ -3,8 +3,14 @@ package claude
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
+	"github.com/tidwall/gjson"
 )
 
 func TestConvertOpenAIResponseToClaude_DedupesToolUseStart(t *testing.T) {
@@ -137,6 +143,28 @@ func TestConvertOpenAIResponseToClaude_EmitsMessageDeltaOnDoneWithoutFinishReaso
 	}
 }
 
+func TestConvertOpenAIResponseToClaude_RefusalStopsWithRefusal(t *testing.T) {
+	originalRequest := []byte(`{"stream":true}`)
+	var param any
+
+	part := `{"id":"chat","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"index":0,"delta":{"refusal":"I can't help with that."}}]}`
+	out1 := ConvertOpenAIResponseToClaude(context.Background(), "", originalRequest, nil, []byte("data: "+part+"\n"), &param)
+	out2 := ConvertOpenAIResponseToClaude(context.Background(), "", originalRequest, nil, []byte("data: [DONE]\n"), &param)
+
+	joined := strings.Join(append(out1, out2...), "")
+	if !strings.Contains(joined, `"text":"I can't help with that."`) {
+		t.Fatalf("expected refusal text delta, got: %q", joined)
+	}
+	if !strings.Contains(joined, `"stop_reason":"refusal"`) {
+		t.Fatalf("expected refusal stop_reason, got: %q", joined)
+	}
+
+	nonStream := ConvertOpenAIResponseToClaudeNonStream(context.Background(), "", nil, nil, []byte(`{"id":"chat","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":null,"refusal":"No."},"finish_reason":"stop"}]}`), nil)
+	if !strings.Contains(nonStream, `"stop_reason":"refusal"`) || !strings.Contains(nonStream, `"text":"No."`) {
+		t.Fatalf("unexpected non-stream refusal translation: %s", nonStream)
+	}
+}
+
 func TestConvertOpenAIResponseToClaude_DedupesTextSnapshots(t *testing.T) {
 	originalRequest := []byte(`{"stream":true}`)
 	var param any
@@ -179,7 +207,7 @@ func TestConvertOpenAIResponseToClaude_DedupesTextSnapshots(t *testing.T) {
 }
 
 func TestToolUseIDMapping_RewritesToolResultToUpstreamID(t *testing.T) {
-	originalRequest := []byte(`{"stream":true,"_seed":"TestToolUseIDMapping_RewritesToolResultToUpstreamID"}`)
+	originalRequest := []byte(`{"stream":true,"_seed":"TestToolUseIDMapping_RewritesToolResultToUpstreamID","messages":[{"role":"user","content":"fix .gitignore"}]}`)
 	var param any
 
 	upstreamID := "call_upstream_1"
@@ -213,6 +241,7 @@ func TestToolUseIDMapping_RewritesToolResultToUpstreamID(t *testing.T) {
 	}
 
 	claudeReq := `{"model":"claude-sonnet-latest","stream":false,"messages":[` +
+		`{"role":"user","content":[{"type":"text","text":"fix .gitignore","cache_control":{"type":"ephemeral"}}]},` +
 		`{"role":"assistant","content":[{"type":"tool_use","id":"` + stableID + `","name":"Update","input":{"path":".gitignore","patch":"noop"}}]},` +
 		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"` + stableID + `","content":"Error editing file"}]}` +
 		`]}`
@@ -260,3 +289,104 @@ func TestToolUseIDMapping_RewritesToolResultToUpstreamID(t *testing.T) {
 		t.Fatalf("expected tool_result tool_call_id %q, got %q (body=%q)", upstreamID, foundToolResultID, string(openAIReqBytes))
 	}
 }
+
+func TestToolUseIDMapping_ScopedPerSession(t *testing.T) {
+	sessionA := toolIDSessionKey(context.Background(), []byte(`{"metadata":{"user_id":"user-a"},"messages":[{"role":"user","content":"hello"}]}`))
+	sessionB := toolIDSessionKey(context.Background(), []byte(`{"metadata":{"user_id":"user-b"},"messages":[{"role":"user","content":"hello"}]}`))
+	if sessionA == sessionB {
+		t.Fatal("expected different clients to get different session keys")
+	}
+
+	registerToolUseIDMapping(sessionA, "toolu_scoped", "call_a")
+	if got, ok := resolveToolUseIDMapping(sessionA, "toolu_scoped"); !ok || got != "call_a" {
+		t.Fatalf("expected session A to resolve its mapping, got %q ok=%v", got, ok)
+	}
+	if got, ok := resolveToolUseIDMapping(sessionB, "toolu_scoped"); ok {
+		t.Fatalf("expected session B not to see session A mapping, got %q", got)
+	}
+
+	stats := GetToolIDMappingStats()
+	if stats.Entries == 0 || stats.Hits == 0 || stats.Misses == 0 {
+		t.Fatalf("expected populated stats, got %+v", stats)
+	}
+}
+
+func TestToolUseIDMapping_ScopedPerClientKey(t *testing.T) {
+	clientContext := func(key string) context.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+key)
+		return context.WithValue(context.Background(), "gin", c)
+	}
+	ctxA, ctxB := clientContext("key-a"), clientContext("key-b")
+	payload := []byte(`{"system":"shared prompt","messages":[{"role":"user","content":"TestToolUseIDMapping_ScopedPerClientKey"}]}`)
+	if toolIDSessionKey(ctxA, payload) == toolIDSessionKey(ctxB, payload) {
+		t.Fatal("expected different API keys to get different session keys for the same payload")
+	}
+
+	registerToolUseIDMapping(toolIDSessionKey(ctxA, payload), "toolu_client_scoped", "call_client_a")
+	request := []byte(`{"system":"shared prompt","messages":[` +
+		`{"role":"user","content":"TestToolUseIDMapping_ScopedPerClientKey"},` +
+		`{"role":"assistant","content":[{"type":"tool_use","id":"toolu_client_scoped","name":"Read","input":{}}]},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_client_scoped","content":"ok"}]}]}`)
+	toolCallID := func(ctx context.Context) string {
+		out := ConvertClaudeRequestToOpenAIContext(ctx, "glm-4.7", request, false)
+		return gjson.GetBytes(out, "messages.#(role==\"assistant\").tool_calls.0.id").String()
+	}
+	if got := toolCallID(ctxA); got != "call_client_a" {
+		t.Fatalf("key-a tool call id = %q, want call_client_a", got)
+	}
+	if got := toolCallID(ctxB); got != "toolu_client_scoped" {
+		t.Fatalf("key-b resolved another client's mapping: tool call id = %q", got)
+	}
+}
+
+func TestConvertOpenAIResponseToClaude_StreamDedupeModes(t *testing.T) {
+	originalRequest := []byte(`{"stream":true}`)
+	frame := func(text string) []byte {
+		return []byte(`data: {"id":"chat","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"` + text + `"}}]}` + "\n")
+	}
+	streamText := func(ctx context.Context, frames ...string) string {
+		var param any
+		var text strings.Builder
+		for _, f := range frames {
+			for _, event := range ConvertOpenAIResponseToClaude(ctx, "", originalRequest, nil, frame(f), &param) {
+				for _, line := range strings.Split(event, "\n") {
+					var payload struct {
+						Delta struct {
+							Type string `json:"type"`
+							Text string `json:"text"`
+						} `json:"delta"`
+					}
+					if json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &payload) == nil && payload.Delta.Type == "text_delta" {
+						text.WriteString(payload.Delta.Text)
+					}
+				}
+			}
+		}
+		return text.String()
+	}
+
+	deltaCtx := translator.WithStreamDedupe(context.Background(), "dedupe-delta-test", translator.StreamDedupeDelta)
+	if got := streamText(deltaCtx, "ha", "ha", "ha"); got != "hahaha" {
+		t.Fatalf("delta mode text = %q, want %q", got, "hahaha")
+	}
+	if got := streamText(context.Background(), "ha", "ha"); got != "ha" {
+		t.Fatalf("auto mode text = %q, want the repeated frame dropped", got)
+	}
+	snapshotCtx := translator.WithStreamDedupe(context.Background(), "dedupe-snapshot-test", translator.StreamDedupeSnapshot)
+	if got := streamText(snapshotCtx, "Hel", "Hello", "Help", "Hello world"); got != "Hello world" {
+		t.Fatalf("snapshot mode text = %q, want %q", got, "Hello world")
+	}
+
+	stats := make(map[string]StreamDedupeStats)
+	for _, s := range GetStreamDedupeStats() {
+		stats[s.Upstream] = s
+	}
+	if s := stats["dedupe-delta-test"]; s.Mode != "delta" || s.Frames != 2 || s.Trimmed != 0 || s.Dropped != 0 {
+		t.Fatalf("delta stats = %+v", s)
+	}
+	if s := stats["dedupe-snapshot-test"]; s.Frames != 3 || s.Trimmed != 2 || s.Dropped != 1 {
+		t.Fatalf("snapshot stats = %+v", s)
+	}
+}
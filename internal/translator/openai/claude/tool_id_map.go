@@ -2,26 +2,58 @@ package claude
 
 import (
 	"bytes"
+	"container/list"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/state"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
 )
 
 type toolIDMappingEntry struct {
+	key        string
 	upstreamID string
 	expiresAt  time.Time
 }
 
 var (
-	toolIDMappingTTL = 30 * time.Minute
+	toolIDMappingTTL        = 30 * time.Minute
+	toolIDMappingMaxEntries = 10000
 
-	toolIDMappingMu sync.Mutex
-	toolIDMapping   = make(map[string]toolIDMappingEntry)
+	toolIDMappingMu     sync.Mutex
+	toolIDMapping       = make(map[string]*list.Element)
+	toolIDMappingOrder  = list.New()
+	toolIDMappingHits   uint64
+	toolIDMappingMisses uint64
 )
 
+// ToolIDMappingStats describes the tool_use ID mapping table for monitoring.
+type ToolIDMappingStats struct {
+	Entries    int    `json:"entries"`
+	MaxEntries int    `json:"max_entries"`
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+}
+
+// GetToolIDMappingStats returns the current size and hit/miss counters of the tool_use ID mapping.
+func GetToolIDMappingStats() ToolIDMappingStats {
+	toolIDMappingMu.Lock()
+	defer toolIDMappingMu.Unlock()
+	return ToolIDMappingStats{
+		Entries:    toolIDMappingOrder.Len(),
+		MaxEntries: toolIDMappingMaxEntries,
+		Hits:       toolIDMappingHits,
+		Misses:     toolIDMappingMisses,
+	}
+}
+
 func stableToolUseID(seed string, toolIndex int) string {
 	sum := sha256.Sum256([]byte(seed + ":" + strconv.Itoa(toolIndex)))
 	// 24 hex chars keeps IDs short while staying collision-resistant for our usage.
@@ -37,46 +69,170 @@ func requestSeedFromPayload(payload []byte) string {
 	return hex.EncodeToString(sum[:])[:16]
 }
 
-func registerToolUseIDMapping(toolUseID, upstreamID string) {
+// requestSeedFromContext salts the payload seed with the caller's credentials so identical
+// payloads sent by different clients never produce the same stable tool_use IDs.
+func requestSeedFromContext(ctx context.Context, payload []byte) string {
+	seed := requestSeedFromPayload(payload)
+	credential := clientScopeFromContext(ctx)
+	if credential == "" {
+		return seed
+	}
+	sum := sha256.Sum256([]byte(credential + "\x00" + seed))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// clientScopeFromContext identifies the calling client by its tenant and credential header, or
+// returns "" when the request carries neither.
+func clientScopeFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return ""
+	}
+	credential := ginCtx.GetHeader("Authorization")
+	if credential == "" {
+		credential = ginCtx.GetHeader("X-Api-Key")
+	}
+	tenant := ginCtx.GetString("tenant")
+	if credential == "" && tenant == "" {
+		return ""
+	}
+	return tenant + "\x00" + credential
+}
+
+// toolIDSessionKey scopes tool_use ID mappings to one conversation of one client. It combines
+// the client credential and tenant from ctx and the identity carried in metadata.user_id with a
+// fingerprint of the system prompt and the first message, both of which stay stable across the
+// turns of a conversation. Only text is hashed so moving cache_control markers or string/array
+// content forms do not change the key.
+func toolIDSessionKey(ctx context.Context, payload []byte) string {
+	root := gjson.ParseBytes(payload)
+	h := sha256.New()
+	_, _ = h.Write([]byte(clientScopeFromContext(ctx)))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(root.Get("metadata.user_id").String()))
+	_, _ = h.Write([]byte{0})
+	writeToolIDFingerprintText(h, root.Get("system"))
+	_, _ = h.Write([]byte{0})
+	if first := root.Get("messages.0"); first.Exists() {
+		_, _ = h.Write([]byte(first.Get("role").String()))
+		_, _ = h.Write([]byte{0})
+		writeToolIDFingerprintText(h, first.Get("content"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+func writeToolIDFingerprintText(h interface{ Write([]byte) (int, error) }, value gjson.Result) {
+	if value.Type == gjson.String {
+		_, _ = h.Write([]byte(value.String()))
+		_, _ = h.Write([]byte{0})
+		return
+	}
+	value.ForEach(func(_, part gjson.Result) bool {
+		if text := part.Get("text"); text.Exists() {
+			_, _ = h.Write([]byte(text.String()))
+			_, _ = h.Write([]byte{0})
+		}
+		return true
+	})
+}
+
+func toolIDMappingKey(sessionKey, toolUseID string) string {
+	return sessionKey + "\x00" + toolUseID
+}
+
+// durableToolIDMappingKey is the state store key for a mapping; NUL bytes are not portable
+// across SQL backends.
+func durableToolIDMappingKey(sessionKey, toolUseID string) string {
+	return sessionKey + ":" + toolUseID
+}
+
+func registerToolUseIDMapping(sessionKey, toolUseID, upstreamID string) {
 	toolUseID = strings.TrimSpace(toolUseID)
 	upstreamID = strings.TrimSpace(upstreamID)
-	if toolUseID == "" || upstreamID == "" {
+	if toolUseID == "" || upstreamID == "" || toolUseID == upstreamID {
 		return
 	}
 
-	now := time.Now()
-	expiresAt := now.Add(toolIDMappingTTL)
+	if store := state.Default(); store != nil {
+		if err := store.Put(context.Background(), state.NamespaceToolIDMap, durableToolIDMappingKey(sessionKey, toolUseID), []byte(upstreamID), toolIDMappingTTL); err != nil {
+			log.Debugf("failed to persist tool_use ID mapping: %v", err)
+		}
+	}
+	storeToolUseIDMapping(toolIDMappingKey(sessionKey, toolUseID), upstreamID, time.Now())
+}
 
+func storeToolUseIDMapping(key, upstreamID string, now time.Time) {
 	toolIDMappingMu.Lock()
 	defer toolIDMappingMu.Unlock()
 
-	for k, v := range toolIDMapping {
-		if now.After(v.expiresAt) {
-			delete(toolIDMapping, k)
-		}
+	if elem, ok := toolIDMapping[key]; ok {
+		entry := elem.Value.(*toolIDMappingEntry)
+		entry.upstreamID = upstreamID
+		entry.expiresAt = now.Add(toolIDMappingTTL)
+		toolIDMappingOrder.MoveToFront(elem)
+		return
 	}
+	toolIDMapping[key] = toolIDMappingOrder.PushFront(&toolIDMappingEntry{
+		key:        key,
+		upstreamID: upstreamID,
+		expiresAt:  now.Add(toolIDMappingTTL),
+	})
 
-	toolIDMapping[toolUseID] = toolIDMappingEntry{upstreamID: upstreamID, expiresAt: expiresAt}
+	// Evict expired entries from the tail, then enforce the LRU bound.
+	for elem := toolIDMappingOrder.Back(); elem != nil; elem = toolIDMappingOrder.Back() {
+		entry := elem.Value.(*toolIDMappingEntry)
+		if toolIDMappingOrder.Len() <= toolIDMappingMaxEntries && !now.After(entry.expiresAt) {
+			break
+		}
+		toolIDMappingOrder.Remove(elem)
+		delete(toolIDMapping, entry.key)
+	}
 }
 
-func resolveToolUseIDMapping(toolUseID string) (string, bool) {
+func resolveToolUseIDMapping(sessionKey, toolUseID string) (string, bool) {
 	toolUseID = strings.TrimSpace(toolUseID)
 	if toolUseID == "" {
 		return "", false
 	}
 
 	now := time.Now()
+	key := toolIDMappingKey(sessionKey, toolUseID)
 
 	toolIDMappingMu.Lock()
-	defer toolIDMappingMu.Unlock()
-
-	entry, ok := toolIDMapping[toolUseID]
-	if !ok {
-		return "", false
+	elem, ok := toolIDMapping[key]
+	if ok {
+		entry := elem.Value.(*toolIDMappingEntry)
+		if !now.After(entry.expiresAt) {
+			toolIDMappingOrder.MoveToFront(elem)
+			toolIDMappingHits++
+			toolIDMappingMu.Unlock()
+			return entry.upstreamID, true
+		}
+		toolIDMappingOrder.Remove(elem)
+		delete(toolIDMapping, key)
 	}
-	if now.After(entry.expiresAt) {
-		delete(toolIDMapping, toolUseID)
-		return "", false
+	toolIDMappingMu.Unlock()
+
+	// Fall back to the durable store so mappings survive restarts and are shared between instances.
+	if store := state.Default(); store != nil {
+		value, found, err := store.Get(context.Background(), state.NamespaceToolIDMap, durableToolIDMappingKey(sessionKey, toolUseID))
+		if err != nil {
+			log.Debugf("failed to read tool_use ID mapping: %v", err)
+		}
+		if found && len(value) > 0 {
+			storeToolUseIDMapping(key, string(value), now)
+			toolIDMappingMu.Lock()
+			toolIDMappingHits++
+			toolIDMappingMu.Unlock()
+			return string(value), true
+		}
 	}
-	return entry.upstreamID, true
+
+	toolIDMappingMu.Lock()
+	toolIDMappingMisses++
+	toolIDMappingMu.Unlock()
+	return "", false
 }
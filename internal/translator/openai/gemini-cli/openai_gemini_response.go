@@ -48,6 +48,10 @@ func ConvertOpenAIResponseToGeminiCLI(ctx context.Context, modelName string, ori
 //   - string: A Gemini-compatible JSON response.
 func ConvertOpenAIResponseToGeminiCLINonStream(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
 	strJSON := ConvertOpenAIResponseToGeminiNonStream(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+	if strJSON == "" {
+		// Nothing to wrap; an empty response object would not be valid JSON.
+		return ""
+	}
 	json := `{"response": {}}`
 	strJSON, _ = sjson.SetRaw(json, "response", strJSON)
 	return strJSON
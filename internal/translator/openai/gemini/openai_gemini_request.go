@@ -51,7 +51,7 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 
 		// Max tokens
 		if maxTokens := genConfig.Get("maxOutputTokens"); maxTokens.Exists() {
-			out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
+			out, _ = sjson.Set(out, "max_tokens", util.BoundMaxTokens(modelName, maxTokens.Int()))
 		}
 
 		// Top P
@@ -66,15 +66,8 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 		}
 
 		// Stop sequences
-		if stopSequences := genConfig.Get("stopSequences"); stopSequences.Exists() && stopSequences.IsArray() {
-			var stops []string
-			stopSequences.ForEach(func(_, value gjson.Result) bool {
-				stops = append(stops, value.String())
-				return true
-			})
-			if len(stops) > 0 {
-				out, _ = sjson.Set(out, "stop", stops)
-			}
+		if stops, _ := util.LimitStopSequences(util.ParseStopSequences(genConfig.Get("stopSequences")), util.OpenAIMaxStopSequences); len(stops) > 0 {
+			out, _ = sjson.Set(out, "stop", stops)
 		}
 
 		// Convert thinkingBudget to reasoning_effort
@@ -85,6 +78,8 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				if effort, ok := util.ThinkingBudgetToEffort(modelName, budget); ok && effort != "" {
 					out, _ = sjson.Set(out, "reasoning_effort", effort)
 				}
+			} else if level := thinkingConfig.Get("thinkingLevel"); level.Exists() && level.String() != "" {
+				out, _ = sjson.Set(out, "reasoning_effort", strings.ToLower(strings.TrimSpace(level.String())))
 			}
 		}
 	}
@@ -283,19 +278,13 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 		})
 	}
 
-	// Tool choice mapping (Gemini doesn't have direct equivalent, but we can handle it)
-	if toolConfig := root.Get("toolConfig"); toolConfig.Exists() {
-		if functionCallingConfig := toolConfig.Get("functionCallingConfig"); functionCallingConfig.Exists() {
-			mode := functionCallingConfig.Get("mode").String()
-			switch mode {
-			case "NONE":
-				out, _ = sjson.Set(out, "tool_choice", "none")
-			case "AUTO":
-				out, _ = sjson.Set(out, "tool_choice", "auto")
-			case "ANY":
-				out, _ = sjson.Set(out, "tool_choice", "required")
-			}
-		}
+	// Tool choice mapping, including a single allowed function name as named forcing
+	toolConfig := root.Get("toolConfig")
+	if !toolConfig.Exists() {
+		toolConfig = root.Get("tool_config")
+	}
+	if choice, ok := util.ParseGeminiToolConfig(toolConfig); ok {
+		out, _ = sjson.SetRaw(out, "tool_choice", choice.OpenAIChat())
 	}
 
 	return []byte(out)
@@ -6,12 +6,13 @@
 package gemini
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -24,6 +25,8 @@ type ConvertOpenAIResponseToGeminiParams struct {
 	ContentAccumulator strings.Builder
 	// Track if this is the first chunk
 	IsFirstChunk bool
+	// Track if the upstream refused; the response then finishes with SAFETY
+	Refused bool
 }
 
 // ToolCallAccumulator holds the state for accumulating tool call data
@@ -59,8 +62,8 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 		return []string{}
 	}
 
-	if bytes.HasPrefix(rawJSON, []byte("data:")) {
-		rawJSON = bytes.TrimSpace(rawJSON[5:])
+	if payload, ok := sse.Data(rawJSON); ok {
+		rawJSON = payload
 	}
 
 	root := gjson.ParseBytes(rawJSON)
@@ -146,6 +149,19 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 				chunkOutputs = append(chunkOutputs, contentTemplate)
 			}
 
+			// A refusal streams as visible text so the client sees why the answer was withheld
+			if refusal := delta.Get("refusal"); refusal.Type == gjson.String && refusal.String() != "" {
+				(*param).(*ConvertOpenAIResponseToGeminiParams).Refused = true
+				refusalTemplate, _ := sjson.Set(baseTemplate, "candidates.0.content.parts.0.text", refusal.String())
+				chunkOutputs = append(chunkOutputs, refusalTemplate)
+			}
+
+			// url_citation annotations become grounding metadata over the text so far
+			if metadata := util.GeminiGroundingMetadata(util.ParseOpenAIAnnotations(delta.Get("annotations")), (*param).(*ConvertOpenAIResponseToGeminiParams).ContentAccumulator.String()); metadata != "" {
+				groundingTemplate, _ := sjson.SetRaw(baseTemplate, "candidates.0.groundingMetadata", metadata)
+				chunkOutputs = append(chunkOutputs, groundingTemplate)
+			}
+
 			if len(chunkOutputs) > 0 {
 				results = append(results, chunkOutputs...)
 				return true
@@ -207,6 +223,9 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 			// Handle finish reason
 			if finishReason := choice.Get("finish_reason"); finishReason.Exists() {
 				geminiFinishReason := mapOpenAIFinishReasonToGemini(finishReason.String())
+				if (*param).(*ConvertOpenAIResponseToGeminiParams).Refused && len((*param).(*ConvertOpenAIResponseToGeminiParams).ToolCallsAccumulator) == 0 {
+					geminiFinishReason = "SAFETY"
+				}
 				template, _ = sjson.Set(template, "candidates.0.finishReason", geminiFinishReason)
 
 				// If we have accumulated tool calls, output them now
@@ -249,18 +268,7 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 
 // mapOpenAIFinishReasonToGemini maps OpenAI finish reasons to Gemini finish reasons
 func mapOpenAIFinishReasonToGemini(openAIReason string) string {
-	switch openAIReason {
-	case "stop":
-		return "STOP"
-	case "length":
-		return "MAX_TOKENS"
-	case "tool_calls":
-		return "STOP" // Gemini doesn't have a specific tool_calls finish reason
-	case "content_filter":
-		return "SAFETY"
-	default:
-		return "STOP"
-	}
+	return util.MapFinishReason(util.FinishReasonOpenAI, util.FinishReasonGemini, openAIReason)
 }
 
 // parseArgsToObjectRaw safely parses a JSON string of function arguments into an object JSON string.
@@ -574,6 +582,9 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 			if content := message.Get("content"); content.Exists() && content.String() != "" {
 				out, _ = sjson.Set(out, fmt.Sprintf("candidates.0.content.parts.%d.text", partIndex), content.String())
 				partIndex++
+				if metadata := util.GeminiGroundingMetadata(util.ParseOpenAIAnnotations(message.Get("annotations")), content.String()); metadata != "" {
+					out, _ = sjson.SetRaw(out, "candidates.0.groundingMetadata", metadata)
+				}
 			}
 
 			// Handle tool calls
@@ -600,6 +611,13 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 				out, _ = sjson.Set(out, "candidates.0.finishReason", geminiFinishReason)
 			}
 
+			// A refusal becomes visible text on a SAFETY-finished candidate
+			if refusal := message.Get("refusal").String(); refusal != "" && !message.Get("tool_calls.0").Exists() {
+				out, _ = sjson.Set(out, fmt.Sprintf("candidates.0.content.parts.%d.text", partIndex), refusal)
+				out, _ = sjson.Set(out, "candidates.0.finishReason", "SAFETY")
+				out, _ = sjson.Set(out, "candidates.0.finishMessage", refusal)
+			}
+
 			// Set index
 			out, _ = sjson.Set(out, "candidates.0.index", choiceIdx)
 
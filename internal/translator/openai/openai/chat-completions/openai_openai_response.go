@@ -8,6 +8,8 @@ package chat_completions
 import (
 	"bytes"
 	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
 )
 
 // ConvertOpenAIResponseToOpenAI translates a single chunk of a streaming response from the
@@ -25,8 +27,8 @@ import (
 // Returns:
 //   - []string: A slice of strings, each containing an OpenAI-compatible JSON response
 func ConvertOpenAIResponseToOpenAI(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
-	if bytes.HasPrefix(rawJSON, []byte("data:")) {
-		rawJSON = bytes.TrimSpace(rawJSON[5:])
+	if payload, ok := sse.Data(rawJSON); ok {
+		rawJSON = payload
 	}
 	if bytes.Equal(rawJSON, []byte("[DONE]")) {
 		return []string{}
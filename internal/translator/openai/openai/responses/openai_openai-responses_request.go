@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -42,13 +43,27 @@ func ConvertOpenAIResponsesRequestToOpenAIChatCompletions(modelName string, inpu
 
 	// Map generation parameters from responses format to chat completions format
 	if maxTokens := root.Get("max_output_tokens"); maxTokens.Exists() {
-		out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
+		out, _ = sjson.Set(out, "max_tokens", util.BoundMaxTokens(modelName, maxTokens.Int()))
 	}
 
 	if parallelToolCalls := root.Get("parallel_tool_calls"); parallelToolCalls.Exists() {
 		out, _ = sjson.Set(out, "parallel_tool_calls", parallelToolCalls.Bool())
 	}
 
+	// Output text logprobs are requested through include/top_logprobs in the Responses API
+	wantLogprobs := root.Get("top_logprobs").Int() > 0
+	for _, include := range root.Get("include").Array() {
+		if include.String() == "message.output_text.logprobs" {
+			wantLogprobs = true
+		}
+	}
+	if wantLogprobs {
+		out, _ = sjson.Set(out, "logprobs", true)
+		if topLogprobs := root.Get("top_logprobs"); topLogprobs.Exists() {
+			out, _ = sjson.Set(out, "top_logprobs", topLogprobs.Int())
+		}
+	}
+
 	// Convert instructions to system message
 	if instructions := root.Get("instructions"); instructions.Exists() {
 		systemMessage := `{"role":"system","content":""}`
@@ -8,6 +8,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -21,11 +23,13 @@ type oaiToResponsesState struct {
 	ReasoningIndex int
 	// aggregation buffers for response.output
 	// Per-output message text buffers by index
-	MsgTextBuf   map[int]*strings.Builder
-	ReasoningBuf strings.Builder
-	FuncArgsBuf  map[int]*strings.Builder // index -> args
-	FuncNames    map[int]string           // index -> name
-	FuncCallIDs  map[int]string           // index -> call_id
+	MsgTextBuf     map[int]*strings.Builder
+	MsgLogprobs    map[int]string // index -> accumulated output_text logprobs array
+	MsgAnnotations map[int]string // index -> accumulated output_text annotations array
+	ReasoningBuf   strings.Builder
+	FuncArgsBuf    map[int]*strings.Builder // index -> args
+	FuncNames      map[int]string           // index -> name
+	FuncCallIDs    map[int]string           // index -> call_id
 	// message item state per output index
 	MsgItemAdded    map[int]bool // whether response.output_item.added emitted for message
 	MsgContentAdded map[int]bool // whether response.content_part.added emitted for message
@@ -45,6 +49,43 @@ type oaiToResponsesState struct {
 // responseIDCounter provides a process-wide unique counter for synthesized response identifiers.
 var responseIDCounter uint64
 
+// appendLogprobs accumulates Chat Completions logprobs entries for the message at idx.
+func (st *oaiToResponsesState) appendLogprobs(idx int, entries gjson.Result) {
+	acc := st.logprobsFor(idx)
+	for _, entry := range entries.Array() {
+		acc, _ = sjson.SetRaw(acc, "-1", entry.Raw)
+	}
+	st.MsgLogprobs[idx] = acc
+}
+
+// appendAnnotations accumulates Chat Completions url_citation annotations for the message at idx
+// and returns them in the flat Responses shape.
+func (st *oaiToResponsesState) appendAnnotations(idx int, annotations gjson.Result) []string {
+	acc := st.annotationsFor(idx)
+	var added []string
+	for _, citation := range util.ParseOpenAIAnnotations(annotations) {
+		annotation := citation.OpenAIAnnotation(false)
+		acc, _ = sjson.SetRaw(acc, "-1", annotation)
+		added = append(added, annotation)
+	}
+	st.MsgAnnotations[idx] = acc
+	return added
+}
+
+func (st *oaiToResponsesState) annotationsFor(idx int) string {
+	if acc, ok := st.MsgAnnotations[idx]; ok {
+		return acc
+	}
+	return "[]"
+}
+
+func (st *oaiToResponsesState) logprobsFor(idx int) string {
+	if acc, ok := st.MsgLogprobs[idx]; ok {
+		return acc
+	}
+	return "[]"
+}
+
 func emitRespEvent(event string, payload string) string {
 	return fmt.Sprintf("event: %s\ndata: %s", event, payload)
 }
@@ -58,6 +99,8 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 			FuncNames:       make(map[int]string),
 			FuncCallIDs:     make(map[int]string),
 			MsgTextBuf:      make(map[int]*strings.Builder),
+			MsgLogprobs:     make(map[int]string),
+			MsgAnnotations:  make(map[int]string),
 			MsgItemAdded:    make(map[int]bool),
 			MsgContentAdded: make(map[int]bool),
 			MsgItemDone:     make(map[int]bool),
@@ -67,8 +110,8 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 	}
 	st := (*param).(*oaiToResponsesState)
 
-	if bytes.HasPrefix(rawJSON, []byte("data:")) {
-		rawJSON = bytes.TrimSpace(rawJSON[5:])
+	if payload, ok := sse.Data(rawJSON); ok {
+		rawJSON = payload
 	}
 
 	rawJSON = bytes.TrimSpace(rawJSON)
@@ -125,6 +168,8 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 		st.Created = root.Get("created").Int()
 		// reset aggregation state for a new streaming response
 		st.MsgTextBuf = make(map[int]*strings.Builder)
+		st.MsgLogprobs = make(map[int]string)
+		st.MsgAnnotations = make(map[int]string)
 		st.ReasoningBuf.Reset()
 		st.ReasoningID = ""
 		st.ReasoningIndex = 0
@@ -189,6 +234,10 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 					msg, _ = sjson.Set(msg, "output_index", idx)
 					msg, _ = sjson.Set(msg, "content_index", 0)
 					msg, _ = sjson.Set(msg, "delta", c.String())
+					if lp := choice.Get("logprobs.content"); lp.IsArray() {
+						msg, _ = sjson.SetRaw(msg, "logprobs", lp.Raw)
+						st.appendLogprobs(idx, lp)
+					}
 					out = append(out, emitRespEvent("response.output_text.delta", msg))
 					// aggregate for response.output
 					if st.MsgTextBuf[idx] == nil {
@@ -197,6 +246,20 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 					st.MsgTextBuf[idx].WriteString(c.String())
 				}
 
+				// url_citation annotations on the message text
+				if anns := delta.Get("annotations"); anns.IsArray() && st.MsgContentAdded[idx] && !st.MsgItemDone[idx] {
+					start := len(gjson.Parse(st.annotationsFor(idx)).Array())
+					for n, annotation := range st.appendAnnotations(idx, anns) {
+						added := `{"type":"response.output_text.annotation.added","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"annotation_index":0,"annotation":{}}`
+						added, _ = sjson.Set(added, "sequence_number", nextSeq())
+						added, _ = sjson.Set(added, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
+						added, _ = sjson.Set(added, "output_index", idx)
+						added, _ = sjson.Set(added, "annotation_index", start+n)
+						added, _ = sjson.SetRaw(added, "annotation", annotation)
+						out = append(out, emitRespEvent("response.output_text.annotation.added", added))
+					}
+				}
+
 				// reasoning_content (OpenAI reasoning incremental text)
 				if rc := delta.Get("reasoning_content"); rc.Exists() && rc.String() != "" {
 					// On first appearance, add reasoning item and part
@@ -239,6 +302,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 						done, _ = sjson.Set(done, "output_index", idx)
 						done, _ = sjson.Set(done, "content_index", 0)
 						done, _ = sjson.Set(done, "text", fullText)
+						done, _ = sjson.SetRaw(done, "logprobs", st.logprobsFor(idx))
 						out = append(out, emitRespEvent("response.output_text.done", done))
 
 						partDone := `{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`
@@ -247,6 +311,8 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 						partDone, _ = sjson.Set(partDone, "output_index", idx)
 						partDone, _ = sjson.Set(partDone, "content_index", 0)
 						partDone, _ = sjson.Set(partDone, "part.text", fullText)
+						partDone, _ = sjson.SetRaw(partDone, "part.logprobs", st.logprobsFor(idx))
+						partDone, _ = sjson.SetRaw(partDone, "part.annotations", st.annotationsFor(idx))
 						out = append(out, emitRespEvent("response.content_part.done", partDone))
 
 						itemDone := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}}`
@@ -254,6 +320,8 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 						itemDone, _ = sjson.Set(itemDone, "output_index", idx)
 						itemDone, _ = sjson.Set(itemDone, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
 						itemDone, _ = sjson.Set(itemDone, "item.content.0.text", fullText)
+						itemDone, _ = sjson.SetRaw(itemDone, "item.content.0.logprobs", st.logprobsFor(idx))
+						itemDone, _ = sjson.SetRaw(itemDone, "item.content.0.annotations", st.annotationsFor(idx))
 						out = append(out, emitRespEvent("response.output_item.done", itemDone))
 						st.MsgItemDone[idx] = true
 					}
@@ -339,6 +407,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 							done, _ = sjson.Set(done, "output_index", i)
 							done, _ = sjson.Set(done, "content_index", 0)
 							done, _ = sjson.Set(done, "text", fullText)
+							done, _ = sjson.SetRaw(done, "logprobs", st.logprobsFor(i))
 							out = append(out, emitRespEvent("response.output_text.done", done))
 
 							partDone := `{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`
@@ -347,6 +416,8 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 							partDone, _ = sjson.Set(partDone, "output_index", i)
 							partDone, _ = sjson.Set(partDone, "content_index", 0)
 							partDone, _ = sjson.Set(partDone, "part.text", fullText)
+							partDone, _ = sjson.SetRaw(partDone, "part.logprobs", st.logprobsFor(i))
+							partDone, _ = sjson.SetRaw(partDone, "part.annotations", st.annotationsFor(i))
 							out = append(out, emitRespEvent("response.content_part.done", partDone))
 
 							itemDone := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}}`
@@ -354,6 +425,8 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 							itemDone, _ = sjson.Set(itemDone, "output_index", i)
 							itemDone, _ = sjson.Set(itemDone, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
 							itemDone, _ = sjson.Set(itemDone, "item.content.0.text", fullText)
+							itemDone, _ = sjson.SetRaw(itemDone, "item.content.0.logprobs", st.logprobsFor(i))
+							itemDone, _ = sjson.SetRaw(itemDone, "item.content.0.annotations", st.annotationsFor(i))
 							out = append(out, emitRespEvent("response.output_item.done", itemDone))
 							st.MsgItemDone[i] = true
 						}
@@ -512,6 +585,8 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 						item := `{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`
 						item, _ = sjson.Set(item, "id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
 						item, _ = sjson.Set(item, "content.0.text", txt)
+						item, _ = sjson.SetRaw(item, "content.0.logprobs", st.logprobsFor(i))
+						item, _ = sjson.SetRaw(item, "content.0.annotations", st.annotationsFor(i))
 						outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
 					}
 				}
@@ -559,7 +634,14 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 					}
 					completed, _ = sjson.Set(completed, "response.usage.total_tokens", total)
 				}
-				out = append(out, emitRespEvent("response.completed", completed))
+				if reason := util.MapFinishReason(util.FinishReasonOpenAI, util.FinishReasonResponses, fr.String()); reason != "" {
+					completed, _ = sjson.Set(completed, "type", "response.incomplete")
+					completed, _ = sjson.Set(completed, "response.status", "incomplete")
+					completed, _ = sjson.Set(completed, "response.incomplete_details.reason", reason)
+					out = append(out, emitRespEvent("response.incomplete", completed))
+				} else {
+					out = append(out, emitRespEvent("response.completed", completed))
+				}
 			}
 
 			return true
@@ -698,6 +780,12 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Co
 					item := `{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`
 					item, _ = sjson.Set(item, "id", fmt.Sprintf("msg_%s_%d", id, int(choice.Get("index").Int())))
 					item, _ = sjson.Set(item, "content.0.text", c.String())
+					if lp := choice.Get("logprobs.content"); lp.IsArray() {
+						item, _ = sjson.SetRaw(item, "content.0.logprobs", lp.Raw)
+					}
+					for _, citation := range util.ParseOpenAIAnnotations(msg.Get("annotations")) {
+						item, _ = sjson.SetRaw(item, "content.0.annotations.-1", citation.OpenAIAnnotation(false))
+					}
 					outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
 				}
 
@@ -744,5 +832,10 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Co
 		}
 	}
 
+	if reason := util.MapFinishReason(util.FinishReasonOpenAI, util.FinishReasonResponses, root.Get("choices.0.finish_reason").String()); reason != "" {
+		resp, _ = sjson.Set(resp, "status", "incomplete")
+		resp, _ = sjson.Set(resp, "incomplete_details.reason", reason)
+	}
+
 	return resp
 }
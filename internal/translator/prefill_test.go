@@ -0,0 +1,77 @@
+package translator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const prefillRequest = `{"model":"test-model","stream":true,"messages":[
+	{"role":"user","content":"list items as JSON"},
+	{"role":"assistant","content":[{"type":"text","text":"{\"items\": ["}]}
+]}`
+
+func streamClaudeText(t *testing.T, chunks ...string) string {
+	t.Helper()
+	var param any
+	var text strings.Builder
+	for _, chunk := range append(chunks, "[DONE]") {
+		out := sdktranslator.TranslateStream(context.Background(), sdktranslator.FormatOpenAI, sdktranslator.FormatClaude, "test-model", []byte(prefillRequest), nil, []byte("data: "+chunk), &param)
+		for _, event := range out {
+			for _, line := range strings.Split(event, "\n") {
+				data, ok := strings.CutPrefix(line, "data: ")
+				if ok && gjson.Get(data, "delta.type").String() == "text_delta" {
+					text.WriteString(gjson.Get(data, "delta.text").String())
+				}
+			}
+		}
+	}
+	return text.String()
+}
+
+func openAIDelta(text string) string {
+	out, _ := sjson.Set(`{"id":"c1","model":"test-model","choices":[{"index":0,"delta":{}}]}`, "choices.0.delta.content", text)
+	return out
+}
+
+func TestClaudePrefillTranslation(t *testing.T) {
+	if got := translate(t, "claude", "openai", prefillRequest).Get("messages.@reverse.0"); got.Get("role").String() != "assistant" || !strings.Contains(got.Raw, "items") {
+		t.Fatalf("openai request lost the prefill: %s", got.Raw)
+	}
+	if got := translate(t, "claude", "gemini", prefillRequest).Get("contents.@reverse.0.role").String(); got != "model" {
+		t.Fatalf("gemini request last role = %q, want model", got)
+	}
+}
+
+func TestClaudePrefillStreamRemovesEcho(t *testing.T) {
+	cases := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"echoed", []string{openAIDelta(`{"items"`), openAIDelta(`: [1, 2]}`)}, `1, 2]}`},
+		{"continuation", []string{openAIDelta(`1, 2`), openAIDelta(`]}`)}, `1, 2]}`},
+		{"diverges inside prefill", []string{openAIDelta(`{"it`), openAIDelta(`em": 1}`)}, `{"item": 1}`},
+		{"ends inside prefill", []string{openAIDelta(`{"ite`)}, `{"ite`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := streamClaudeText(t, tc.chunks...); got != tc.want {
+				t.Fatalf("text = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClaudePrefillNonStreamRemovesEcho(t *testing.T) {
+	upstream := `{"id":"c1","model":"test-model","choices":[{"index":0,"message":{"role":"assistant","content":"{\"items\": [1]}"},"finish_reason":"stop"}]}`
+	var param any
+	out := sdktranslator.TranslateNonStream(context.Background(), sdktranslator.FormatOpenAI, sdktranslator.FormatClaude, "test-model", []byte(prefillRequest), nil, []byte(upstream), &param)
+	if got := gjson.Get(out, "content.0.text").String(); got != "1]}" {
+		t.Fatalf("text = %q, want %q", got, "1]}")
+	}
+}
@@ -0,0 +1,86 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+const mixedRoleChat = `{"model":"test-model","messages":[
+	{"role":"system","content":"A"},
+	{"role":"user","content":"hi"},
+	{"role":"developer","content":"B"},
+	{"role":"assistant","content":"ok"},
+	{"role":"system","content":[{"type":"text","text":"C"}]},
+	{"role":"user","content":"go"}
+]}`
+
+const mixedRoleResponses = `{"model":"test-model","instructions":"A","input":[
+	{"type":"message","role":"user","content":[{"type":"input_text","text":"hi"}]},
+	{"type":"message","role":"developer","content":[{"type":"input_text","text":"B"}]},
+	{"type":"message","role":"system","content":"C"},
+	{"type":"message","role":"user","content":[{"type":"input_text","text":"go"}]}
+]}`
+
+func translate(t *testing.T, from, to, body string) gjson.Result {
+	t.Helper()
+	out := sdktranslator.TranslateRequest(sdktranslator.FromString(from), sdktranslator.FromString(to), "test-model", []byte(body), false)
+	return gjson.ParseBytes(out)
+}
+
+func texts(result gjson.Result, path string) string {
+	var parts []string
+	result.Get(path).ForEach(func(_, v gjson.Result) bool {
+		parts = append(parts, v.String())
+		return true
+	})
+	return strings.Join(parts, ",")
+}
+
+func TestInstructionRolesHoistedForClaude(t *testing.T) {
+	for _, tc := range []struct{ from, body string }{
+		{"openai", mixedRoleChat},
+		{"openai-response", mixedRoleResponses},
+	} {
+		out := translate(t, tc.from, "claude", tc.body)
+		if got := texts(out, "system.#.text"); got != "A,B,C" {
+			t.Errorf("%s: system = %q, want A,B,C", tc.from, got)
+		}
+		if got := texts(out, "messages.#.role"); strings.Contains(got, "system") || strings.Contains(got, "developer") {
+			t.Errorf("%s: instruction roles left in messages: %s", tc.from, got)
+		}
+	}
+}
+
+func TestInstructionRolesMergedForGemini(t *testing.T) {
+	for _, tc := range []struct{ from, to, path, body string }{
+		{"openai", "gemini", "system_instruction.parts.#.text", mixedRoleChat},
+		{"openai", "gemini-cli", "request.systemInstruction.parts.#.text", mixedRoleChat},
+		{"openai", "antigravity", "request.systemInstruction.parts.#.text", mixedRoleChat},
+		{"openai-response", "gemini", "system_instruction.parts.#.text", mixedRoleResponses},
+	} {
+		out := translate(t, tc.from, tc.to, tc.body)
+		if got := texts(out, tc.path); got != "A,B,C" {
+			t.Errorf("%s -> %s: system parts = %q, want A,B,C", tc.from, tc.to, got)
+		}
+	}
+
+	out := translate(t, "openai", "gemini", `{"messages":[{"role":"developer","content":"only"}]}`)
+	if out.Get("system_instruction").Exists() || out.Get("contents.0.parts.0.text").String() != "only" {
+		t.Errorf("instruction-only request should become a user turn: %s", out.Raw)
+	}
+}
+
+func TestInstructionRolesKeptInPlaceForCodex(t *testing.T) {
+	out := translate(t, "openai", "codex", mixedRoleChat)
+	if got := texts(out, "input.#.role"); got != "developer,user,developer,assistant,developer,user" {
+		t.Errorf("openai -> codex roles = %q", got)
+	}
+	out = translate(t, "claude", "codex", `{"system":"A","messages":[{"role":"user","content":"hi"}]}`)
+	developer := out.Get(`input.#(role=="developer")`)
+	if developer.Get("content.0.text").String() != "A" {
+		t.Errorf("claude -> codex input = %s", out.Get("input").Raw)
+	}
+}
@@ -0,0 +1,54 @@
+package translator
+
+import (
+	"context"
+	"strings"
+)
+
+// Stream dedupe modes describe how an upstream streams generated text.
+const (
+	// StreamDedupeAuto detects full snapshots by prefix and emits only the new suffix.
+	StreamDedupeAuto = "auto"
+	// StreamDedupeDelta treats every frame as new text, even when it repeats earlier text.
+	StreamDedupeDelta = "delta"
+	// StreamDedupeSnapshot treats every frame as the full text generated so far.
+	StreamDedupeSnapshot = "snapshot"
+)
+
+type streamDedupeKey struct{}
+
+type streamDedupe struct {
+	upstream string
+	mode     string
+}
+
+// NormalizeStreamDedupe returns the canonical dedupe mode, defaulting to StreamDedupeAuto.
+func NormalizeStreamDedupe(mode string) string {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case StreamDedupeDelta:
+		return StreamDedupeDelta
+	case StreamDedupeSnapshot:
+		return StreamDedupeSnapshot
+	default:
+		return StreamDedupeAuto
+	}
+}
+
+// WithStreamDedupe records the upstream and its dedupe mode for response translators.
+func WithStreamDedupe(ctx context.Context, upstream, mode string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, streamDedupeKey{}, streamDedupe{upstream: upstream, mode: NormalizeStreamDedupe(mode)})
+}
+
+// StreamDedupeFromContext returns the upstream and dedupe mode recorded by WithStreamDedupe.
+// Without one, the upstream is empty and the mode is StreamDedupeAuto.
+func StreamDedupeFromContext(ctx context.Context) (upstream, mode string) {
+	if ctx != nil {
+		if d, ok := ctx.Value(streamDedupeKey{}).(streamDedupe); ok {
+			return d.upstream, d.mode
+		}
+	}
+	return "", StreamDedupeAuto
+}
@@ -0,0 +1,176 @@
+package translator
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// WithClaudePrefill wraps the response translators of a Claude-client pair so that an assistant
+// prefill (a request whose last message has role assistant) behaves as it does on Anthropic's
+// API: the response carries only the continuation. Upstreams that restate the prefill at the
+// start of their output have that copy removed; text that diverges from it is released unchanged.
+func WithClaudePrefill(response interfaces.TranslateResponse) interfaces.TranslateResponse {
+	stream, nonStream := response.Stream, response.NonStream
+	if stream != nil {
+		response.Stream = func(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+			state, ok := (*param).(*prefillStreamState)
+			if !ok {
+				state = &prefillStreamState{trimmer: newPrefillTrimmer(ClaudePrefill(originalRequestRawJSON))}
+				*param = state
+			}
+			results := stream(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, &state.inner)
+			return state.process(results)
+		}
+	}
+	if nonStream != nil {
+		response.NonStream = func(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
+			out := nonStream(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+			return trimClaudePrefillMessage(out, ClaudePrefill(originalRequestRawJSON))
+		}
+	}
+	return response
+}
+
+// ClaudePrefill returns the text of the trailing assistant message of a Claude Messages request,
+// or "" when the request does not end with one.
+func ClaudePrefill(rawJSON []byte) string {
+	messages := gjson.GetBytes(rawJSON, "messages").Array()
+	if len(messages) == 0 {
+		return ""
+	}
+	last := messages[len(messages)-1]
+	if last.Get("role").String() != "assistant" {
+		return ""
+	}
+	content := last.Get("content")
+	if content.Type == gjson.String {
+		return content.String()
+	}
+	var text strings.Builder
+	for _, block := range content.Array() {
+		if block.Get("type").String() == "text" {
+			text.WriteString(block.Get("text").String())
+		}
+	}
+	return text.String()
+}
+
+// prefillTrimmer removes a restated prefill from the start of generated text. Text is held back
+// only while everything generated so far is still a prefix of the prefill.
+type prefillTrimmer struct {
+	prefill string
+	held    string
+	done    bool
+}
+
+func newPrefillTrimmer(prefill string) *prefillTrimmer {
+	// Anthropic rejects prefills with trailing whitespace, so clients strip it; upstreams may not.
+	prefill = strings.TrimRight(prefill, " \t\r\n")
+	if prefill == "" {
+		return nil
+	}
+	return &prefillTrimmer{prefill: prefill}
+}
+
+// active reports whether the trimmer still inspects generated text.
+func (t *prefillTrimmer) active() bool {
+	return t != nil && !t.done
+}
+
+// trim consumes the next piece of generated text and returns what may be sent to the client.
+func (t *prefillTrimmer) trim(text string) string {
+	if !t.active() {
+		return text
+	}
+	candidate := t.held + text
+	switch {
+	case strings.HasPrefix(candidate, t.prefill):
+		t.done, t.held = true, ""
+		return candidate[len(t.prefill):]
+	case strings.HasPrefix(t.prefill, candidate):
+		t.held = candidate
+		return ""
+	default:
+		t.done, t.held = true, ""
+		return candidate
+	}
+}
+
+// flush releases any held text; an output that ended inside the prefill is not dropped.
+func (t *prefillTrimmer) flush() string {
+	if !t.active() {
+		return ""
+	}
+	held := t.held
+	t.done, t.held = true, ""
+	return held
+}
+
+// prefillStreamState wraps the state of the underlying stream translator.
+type prefillStreamState struct {
+	inner   any
+	trimmer *prefillTrimmer
+	// pending is the last text delta event withheld while its text matched the prefill.
+	pending *sse.Block
+}
+
+func (s *prefillStreamState) process(results []string) []string {
+	if !s.trimmer.active() {
+		return results
+	}
+	out := make([]string, 0, len(results))
+	for _, result := range results {
+		for _, block := range sse.SplitBlocks([]byte(result)) {
+			if !s.trimmer.active() {
+				out = append(out, string(block.Bytes()))
+				continue
+			}
+			root := gjson.ParseBytes(block.Payload)
+			if root.Get("type").String() == "content_block_delta" && root.Get("delta.type").String() == "text_delta" {
+				if text := s.trimmer.trim(root.Get("delta.text").String()); text != "" {
+					out = append(out, string(block.WithText("delta.text", text)))
+				} else {
+					s.pending = block
+				}
+				continue
+			}
+			if root.Get("type").String() == "ping" {
+				out = append(out, string(block.Bytes()))
+				continue
+			}
+			if s.pending != nil {
+				if held := s.trimmer.flush(); held != "" {
+					out = append(out, string(s.pending.WithText("delta.text", held)))
+				}
+				s.pending = nil
+			}
+			out = append(out, string(block.Bytes()))
+		}
+	}
+	return out
+}
+
+// trimClaudePrefillMessage removes a restated prefill from the first text block of a Claude message.
+func trimClaudePrefillMessage(out, prefill string) string {
+	trimmer := newPrefillTrimmer(prefill)
+	if trimmer == nil {
+		return out
+	}
+	for i, block := range gjson.Get(out, "content").Array() {
+		if block.Get("type").String() != "text" {
+			continue
+		}
+		text := block.Get("text").String()
+		if trimmed := strings.TrimPrefix(text, trimmer.prefill); trimmed != text {
+			out, _ = sjson.Set(out, "content."+strconv.Itoa(i)+".text", trimmed)
+		}
+		break
+	}
+	return out
+}
@@ -25,6 +25,12 @@ func Register(from, to string, request interfaces.TranslateRequestFunc, response
 	registry.Register(sdktranslator.FromString(from), sdktranslator.FromString(to), request, response)
 }
 
+// RegisterRequestContext registers a request translator that receives the request context.
+// It replaces the request function given to Register for callers that pass a context.
+func RegisterRequestContext(from, to string, request sdktranslator.RequestContextTransform) {
+	registry.RegisterRequestContext(sdktranslator.FromString(from), sdktranslator.FromString(to), request)
+}
+
 // Request translates a request from one API format to another.
 //
 // Parameters:
@@ -67,6 +67,8 @@ type RequestStatistics struct {
 
 	apis map[string]*apiStats
 
+	tenants map[string]*tenantStats
+
 	requestsByDay  map[string]int64
 	requestsByHour map[int]int64
 	tokensByDay    map[string]int64
@@ -80,6 +82,13 @@ type apiStats struct {
 	Models        map[string]*modelStats
 }
 
+// tenantStats holds aggregated metrics for a single tenant.
+type tenantStats struct {
+	TotalRequests int64
+	FailureCount  int64
+	TotalTokens   int64
+}
+
 // modelStats holds aggregated metrics for a specific model within an API.
 type modelStats struct {
 	TotalRequests int64
@@ -92,6 +101,7 @@ type RequestDetail struct {
 	Timestamp time.Time  `json:"timestamp"`
 	Source    string     `json:"source"`
 	AuthIndex string     `json:"auth_index"`
+	Tenant    string     `json:"tenant,omitempty"`
 	Tokens    TokenStats `json:"tokens"`
 	Failed    bool       `json:"failed"`
 }
@@ -114,6 +124,9 @@ type StatisticsSnapshot struct {
 
 	APIs map[string]APISnapshot `json:"apis"`
 
+	// Tenants summarises usage per tenant when tenants are configured.
+	Tenants map[string]TenantSnapshot `json:"tenants,omitempty"`
+
 	RequestsByDay  map[string]int64 `json:"requests_by_day"`
 	RequestsByHour map[string]int64 `json:"requests_by_hour"`
 	TokensByDay    map[string]int64 `json:"tokens_by_day"`
@@ -127,6 +140,13 @@ type APISnapshot struct {
 	Models        map[string]ModelSnapshot `json:"models"`
 }
 
+// TenantSnapshot summarises metrics for a single tenant.
+type TenantSnapshot struct {
+	TotalRequests int64 `json:"total_requests"`
+	FailureCount  int64 `json:"failure_count"`
+	TotalTokens   int64 `json:"total_tokens"`
+}
+
 // ModelSnapshot summarises metrics for a specific model.
 type ModelSnapshot struct {
 	TotalRequests int64           `json:"total_requests"`
@@ -143,6 +163,7 @@ func GetRequestStatistics() *RequestStatistics { return defaultRequestStatistics
 func NewRequestStatistics() *RequestStatistics {
 	return &RequestStatistics{
 		apis:           make(map[string]*apiStats),
+		tenants:        make(map[string]*tenantStats),
 		requestsByDay:  make(map[string]int64),
 		requestsByHour: make(map[int]int64),
 		tokensByDay:    make(map[string]int64),
@@ -196,13 +217,16 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		stats = &apiStats{Models: make(map[string]*modelStats)}
 		s.apis[statsKey] = stats
 	}
-	s.updateAPIStats(stats, modelName, RequestDetail{
+	requestDetail := RequestDetail{
 		Timestamp: timestamp,
 		Source:    record.Source,
 		AuthIndex: record.AuthIndex,
+		Tenant:    record.Tenant,
 		Tokens:    detail,
 		Failed:    failed,
-	})
+	}
+	s.updateAPIStats(stats, modelName, requestDetail)
+	s.updateTenantStats(requestDetail)
 
 	s.requestsByDay[dayKey]++
 	s.requestsByHour[hourKey]++
@@ -223,6 +247,25 @@ func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail
 	modelStatsValue.Details = append(modelStatsValue.Details, detail)
 }
 
+func (s *RequestStatistics) updateTenantStats(detail RequestDetail) {
+	if detail.Tenant == "" {
+		return
+	}
+	if s.tenants == nil {
+		s.tenants = make(map[string]*tenantStats)
+	}
+	stats, ok := s.tenants[detail.Tenant]
+	if !ok {
+		stats = &tenantStats{}
+		s.tenants[detail.Tenant] = stats
+	}
+	stats.TotalRequests++
+	if detail.Failed {
+		stats.FailureCount++
+	}
+	stats.TotalTokens += detail.Tokens.TotalTokens
+}
+
 // Snapshot returns a copy of the aggregated metrics for external consumption.
 func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	result := StatisticsSnapshot{}
@@ -257,6 +300,17 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 		result.APIs[apiName] = apiSnapshot
 	}
 
+	if len(s.tenants) > 0 {
+		result.Tenants = make(map[string]TenantSnapshot, len(s.tenants))
+		for name, stats := range s.tenants {
+			result.Tenants[name] = TenantSnapshot{
+				TotalRequests: stats.TotalRequests,
+				FailureCount:  stats.FailureCount,
+				TotalTokens:   stats.TotalTokens,
+			}
+		}
+	}
+
 	result.RequestsByDay = make(map[string]int64, len(s.requestsByDay))
 	for k, v := range s.requestsByDay {
 		result.RequestsByDay[k] = v
@@ -365,6 +419,7 @@ func (s *RequestStatistics) recordImported(apiName, modelName string, stats *api
 	s.totalTokens += totalTokens
 
 	s.updateAPIStats(stats, modelName, detail)
+	s.updateTenantStats(detail)
 
 	dayKey := detail.Timestamp.Format("2006-01-02")
 	hourKey := detail.Timestamp.Hour()
@@ -0,0 +1,69 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/state"
+	log "github.com/sirupsen/logrus"
+)
+
+const persistedSnapshotKey = "snapshot"
+
+// DefaultPersistInterval is used when no usage flush interval is configured.
+const DefaultPersistInterval = time.Minute
+
+// LoadFromStore merges the snapshot persisted in store into the statistics.
+func (s *RequestStatistics) LoadFromStore(ctx context.Context, store state.Store) (MergeResult, error) {
+	if s == nil || store == nil {
+		return MergeResult{}, nil
+	}
+	data, ok, err := store.Get(ctx, state.NamespaceUsage, persistedSnapshotKey)
+	if err != nil || !ok {
+		return MergeResult{}, err
+	}
+	var snapshot StatisticsSnapshot
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return MergeResult{}, fmt.Errorf("usage: decode persisted snapshot: %w", err)
+	}
+	return s.MergeSnapshot(snapshot), nil
+}
+
+// SaveToStore persists the current snapshot into store.
+func (s *RequestStatistics) SaveToStore(ctx context.Context, store state.Store) error {
+	if s == nil || store == nil {
+		return nil
+	}
+	data, err := json.Marshal(s.Snapshot())
+	if err != nil {
+		return fmt.Errorf("usage: encode snapshot: %w", err)
+	}
+	return store.Put(ctx, state.NamespaceUsage, persistedSnapshotKey, data, 0)
+}
+
+// StartPersistence saves the statistics to store every interval until ctx is canceled. Callers
+// flush the final state with SaveToStore during shutdown.
+func (s *RequestStatistics) StartPersistence(ctx context.Context, store state.Store, interval time.Duration) {
+	if s == nil || store == nil {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultPersistInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.SaveToStore(ctx, store); err != nil {
+					log.Warnf("failed to persist usage statistics: %v", err)
+				}
+			}
+		}
+	}()
+}
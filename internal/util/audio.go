@@ -0,0 +1,111 @@
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// DefaultPCMSampleRate is the sample rate of the 16-bit mono PCM audio returned by Gemini
+// speech models when the mime type does not state one.
+const DefaultPCMSampleRate = 24000
+
+// audioTranscriptionFields are the form fields forwarded to Whisper-compatible upstreams.
+var audioTranscriptionFields = []string{"model", "language", "prompt", "response_format", "temperature", "chunking_strategy"}
+
+// AudioTranscriptionMultipart rebuilds the multipart/form-data body expected by
+// Whisper-compatible /audio/transcriptions endpoints from the JSON form the proxy routes
+// internally, where the uploaded file is carried as file.filename, file.content_type and
+// base64 file.data. It returns the body and its Content-Type header.
+func AudioTranscriptionMultipart(payload []byte) ([]byte, string, error) {
+	root := gjson.ParseBytes(payload)
+	data, err := base64.StdEncoding.DecodeString(root.Get("file.data").String())
+	if err != nil {
+		return nil, "", fmt.Errorf("decode audio file: %w", err)
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, field := range audioTranscriptionFields {
+		if value := root.Get(field); value.Exists() && value.String() != "" {
+			if err = writer.WriteField(field, value.String()); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	for _, granularity := range root.Get("timestamp_granularities").Array() {
+		if err = writer.WriteField("timestamp_granularities[]", granularity.String()); err != nil {
+			return nil, "", err
+		}
+	}
+
+	filename := root.Get("file.filename").String()
+	if filename == "" {
+		filename = "audio"
+	}
+	contentType := root.Get("file.content_type").String()
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, strings.ReplaceAll(filename, `"`, "")))
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = part.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+// PCMSampleRate extracts the rate parameter from a mime type such as
+// "audio/L16;codec=pcm;rate=24000", falling back to DefaultPCMSampleRate.
+func PCMSampleRate(mimeType string) int {
+	for _, param := range strings.Split(mimeType, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(key, "rate") {
+			continue
+		}
+		if rate, err := strconv.Atoi(value); err == nil && rate > 0 {
+			return rate
+		}
+	}
+	return DefaultPCMSampleRate
+}
+
+// PCMToWAV wraps raw little-endian 16-bit mono PCM samples in a WAV container.
+func PCMToWAV(pcm []byte, sampleRate int) []byte {
+	const (
+		channels      = 1
+		bitsPerSample = 16
+	)
+	blockAlign := channels * bitsPerSample / 8
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(blockAlign))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample))
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"mime"
+	"mime/multipart"
+	"testing"
+)
+
+func TestPCMToWAVHeader(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4}
+	wav := PCMToWAV(pcm, PCMSampleRate("audio/L16;codec=pcm;rate=16000"))
+	if len(wav) != 44+len(pcm) || string(wav[:4]) != "RIFF" || string(wav[8:12]) != "WAVE" {
+		t.Fatalf("unexpected WAV header: %q", wav[:12])
+	}
+	if rate := binary.LittleEndian.Uint32(wav[24:28]); rate != 16000 {
+		t.Fatalf("sample rate = %d, want 16000", rate)
+	}
+	if !bytes.Equal(wav[44:], pcm) {
+		t.Fatalf("PCM payload not preserved")
+	}
+	if rate := PCMSampleRate("audio/L16;codec=pcm"); rate != DefaultPCMSampleRate {
+		t.Fatalf("default sample rate = %d", rate)
+	}
+}
+
+func TestAudioTranscriptionMultipart(t *testing.T) {
+	payload := []byte(`{"model":"whisper-1","language":"en","timestamp_granularities":["word"],"file":{"filename":"note.mp3","content_type":"audio/mpeg","data":"` + base64.StdEncoding.EncodeToString([]byte("audio")) + `"}}`)
+	body, contentType, err := AudioTranscriptionMultipart(payload)
+	if err != nil {
+		t.Fatalf("AudioTranscriptionMultipart: %v", err)
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("parse content type: %v", err)
+	}
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	fields := map[string]string{}
+	for {
+		part, errPart := reader.NextPart()
+		if errPart == io.EOF {
+			break
+		}
+		if errPart != nil {
+			t.Fatalf("next part: %v", errPart)
+		}
+		data, _ := io.ReadAll(part)
+		if part.FormName() == "file" && part.FileName() != "note.mp3" {
+			t.Fatalf("filename = %q", part.FileName())
+		}
+		fields[part.FormName()] = string(data)
+	}
+	if fields["model"] != "whisper-1" || fields["language"] != "en" || fields["timestamp_granularities[]"] != "word" || fields["file"] != "audio" {
+		t.Fatalf("unexpected form fields: %v", fields)
+	}
+}
@@ -0,0 +1,177 @@
+package util
+
+import (
+	"slices"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Citation is a protocol-neutral web source attached to a span of response text. Start and End
+// are character offsets into the full response text.
+type Citation struct {
+	URL       string
+	Title     string
+	CitedText string
+	Start     int
+	End       int
+}
+
+// ParseClaudeCitation reads a Claude text block citation. Only citations that carry a URL
+// (web_search_result_location and search_result_location) translate to other protocols;
+// document citations are reported as not ok.
+func ParseClaudeCitation(c gjson.Result) (Citation, bool) {
+	url := c.Get("url").String()
+	if url == "" {
+		url = c.Get("source").String()
+	}
+	if url == "" {
+		return Citation{}, false
+	}
+	return Citation{URL: url, Title: c.Get("title").String(), CitedText: c.Get("cited_text").String()}, true
+}
+
+// ParseOpenAIAnnotation reads an OpenAI url_citation annotation. Chat Completions nests the fields
+// under "url_citation"; the Responses API keeps them at the top level.
+func ParseOpenAIAnnotation(a gjson.Result) (Citation, bool) {
+	if a.Get("type").String() != "url_citation" {
+		return Citation{}, false
+	}
+	if nested := a.Get("url_citation"); nested.Exists() {
+		a = nested
+	}
+	url := a.Get("url").String()
+	if url == "" {
+		return Citation{}, false
+	}
+	return Citation{URL: url, Title: a.Get("title").String(), Start: int(a.Get("start_index").Int()), End: int(a.Get("end_index").Int())}, true
+}
+
+// OpenAIAnnotation renders c as a url_citation annotation, nested for Chat Completions and flat
+// for the Responses API.
+func (c Citation) OpenAIAnnotation(nested bool) string {
+	prefix := ""
+	if nested {
+		prefix = "url_citation."
+	}
+	out := `{"type":"url_citation"}`
+	out, _ = sjson.Set(out, prefix+"url", c.URL)
+	out, _ = sjson.Set(out, prefix+"title", c.Title)
+	out, _ = sjson.Set(out, prefix+"start_index", c.Start)
+	out, _ = sjson.Set(out, prefix+"end_index", c.End)
+	return out
+}
+
+// ClaudeCitation renders c as a Claude web_search_result_location citation. cited must be the
+// text the citation covers when c.CitedText is empty.
+func (c Citation) ClaudeCitation(cited string) string {
+	if c.CitedText != "" {
+		cited = c.CitedText
+	}
+	out := `{"type":"web_search_result_location","url":"","title":"","cited_text":"","encrypted_index":""}`
+	out, _ = sjson.Set(out, "url", c.URL)
+	out, _ = sjson.Set(out, "title", c.Title)
+	out, _ = sjson.Set(out, "cited_text", cited)
+	return out
+}
+
+// CitationSpan returns the characters of text between a citation's Start and End offsets,
+// clamped to the text.
+func CitationSpan(text []rune, c Citation) string {
+	start, end := max(c.Start, 0), min(c.End, len(text))
+	if start >= end {
+		return ""
+	}
+	return string(text[start:end])
+}
+
+// ClaudeTextBlocks splits text into Claude text blocks so that each cited span becomes its own
+// block carrying its citations. Citations whose span overlaps an earlier one are attached to the
+// preceding block.
+func ClaudeTextBlocks(text string, citations []Citation) []string {
+	runes := []rune(text)
+	citations = slices.Clone(citations)
+	slices.SortStableFunc(citations, func(a, b Citation) int { return a.Start - b.Start })
+	block := func(from, to int, cited []Citation) string {
+		out := `{"type":"text","text":""}`
+		out, _ = sjson.Set(out, "text", string(runes[from:to]))
+		for _, c := range cited {
+			out, _ = sjson.SetRaw(out, "citations.-1", c.ClaudeCitation(CitationSpan(runes, c)))
+		}
+		return out
+	}
+	var blocks []string
+	pos := 0
+	for i := 0; i < len(citations); {
+		start, end := max(citations[i].Start, pos), min(citations[i].End, len(runes))
+		if start >= end {
+			// Empty or already covered span: attach to the previous block when there is one.
+			if len(blocks) > 0 {
+				blocks[len(blocks)-1], _ = sjson.SetRaw(blocks[len(blocks)-1], "citations.-1", citations[i].ClaudeCitation(CitationSpan(runes, citations[i])))
+			}
+			i++
+			continue
+		}
+		if start > pos {
+			blocks = append(blocks, block(pos, start, nil))
+		}
+		cited := []Citation{citations[i]}
+		i++
+		for i < len(citations) && citations[i].Start == citations[i-1].Start && citations[i].End == citations[i-1].End {
+			cited = append(cited, citations[i])
+			i++
+		}
+		blocks = append(blocks, block(start, end, cited))
+		pos = end
+	}
+	if pos < len(runes) || len(blocks) == 0 {
+		blocks = append(blocks, block(pos, len(runes), nil))
+	}
+	return blocks
+}
+
+// GeminiGroundingMetadata renders citations on text as Gemini groundingMetadata: one web
+// groundingChunk per distinct URL and a groundingSupport per cited span, with the UTF-8 byte
+// offsets Gemini uses. It returns "" when there are no citations.
+func GeminiGroundingMetadata(citations []Citation, text string) string {
+	if len(citations) == 0 {
+		return ""
+	}
+	runes := []rune(text)
+	byteOffset := func(chars int) int {
+		chars = min(max(chars, 0), len(runes))
+		return len(string(runes[:chars]))
+	}
+	out := `{"groundingChunks":[],"groundingSupports":[]}`
+	chunkIndex := map[string]int{}
+	for _, c := range citations {
+		index, ok := chunkIndex[c.URL]
+		if !ok {
+			index = len(chunkIndex)
+			chunkIndex[c.URL] = index
+			chunk := `{"web":{"uri":"","title":""}}`
+			chunk, _ = sjson.Set(chunk, "web.uri", c.URL)
+			chunk, _ = sjson.Set(chunk, "web.title", c.Title)
+			out, _ = sjson.SetRaw(out, "groundingChunks.-1", chunk)
+		}
+		support := `{"segment":{"startIndex":0,"endIndex":0,"text":""},"groundingChunkIndices":[]}`
+		support, _ = sjson.Set(support, "segment.startIndex", byteOffset(c.Start))
+		support, _ = sjson.Set(support, "segment.endIndex", byteOffset(c.End))
+		support, _ = sjson.Set(support, "segment.text", CitationSpan(runes, c))
+		support, _ = sjson.Set(support, "groundingChunkIndices", []int{index})
+		out, _ = sjson.SetRaw(out, "groundingSupports.-1", support)
+	}
+	return out
+}
+
+// ParseOpenAIAnnotations reads the url_citation entries of an OpenAI annotations array.
+func ParseOpenAIAnnotations(annotations gjson.Result) []Citation {
+	var citations []Citation
+	annotations.ForEach(func(_, annotation gjson.Result) bool {
+		if c, ok := ParseOpenAIAnnotation(annotation); ok {
+			citations = append(citations, c)
+		}
+		return true
+	})
+	return citations
+}
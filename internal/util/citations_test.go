@@ -0,0 +1,64 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestClaudeTextBlocks(t *testing.T) {
+	text := "Go is fast. Café opens at 9."
+	citations := []Citation{
+		{URL: "https://cafe.example", Title: "Café", Start: 12, End: 28},
+		{URL: "https://go.dev", Title: "Go", Start: 0, End: 11},
+	}
+	blocks := ClaudeTextBlocks(text, citations)
+	if len(blocks) != 3 {
+		t.Fatalf("blocks = %v", blocks)
+	}
+	first, second, third := gjson.Parse(blocks[0]), gjson.Parse(blocks[1]), gjson.Parse(blocks[2])
+	if first.Get("text").String() != "Go is fast." || first.Get("citations.0.url").String() != "https://go.dev" {
+		t.Fatalf("first block = %s", blocks[0])
+	}
+	if second.Get("text").String() != " " || second.Get("citations").Exists() {
+		t.Fatalf("second block = %s", blocks[1])
+	}
+	if third.Get("text").String() != "Café opens at 9." || third.Get("citations.0.cited_text").String() != "Café opens at 9." {
+		t.Fatalf("third block = %s", blocks[2])
+	}
+
+	if got := ClaudeTextBlocks("plain", nil); len(got) != 1 || gjson.Get(got[0], "text").String() != "plain" {
+		t.Fatalf("uncited text = %v", got)
+	}
+}
+
+func TestGeminiGroundingMetadata(t *testing.T) {
+	text := "Café opens at 9. Café closes at 5."
+	metadata := gjson.Parse(GeminiGroundingMetadata([]Citation{
+		{URL: "https://cafe.example", Title: "Café", Start: 0, End: 16},
+		{URL: "https://cafe.example", Title: "Café", Start: 17, End: 34},
+	}, text))
+	if metadata.Get("groundingChunks.#").Int() != 1 || metadata.Get("groundingSupports.#").Int() != 2 {
+		t.Fatalf("metadata = %s", metadata.Raw)
+	}
+	// Offsets are converted from characters to UTF-8 bytes; "é" is two bytes.
+	second := metadata.Get("groundingSupports.1")
+	if second.Get("segment.startIndex").Int() != 18 || second.Get("segment.endIndex").Int() != 36 || second.Get("groundingChunkIndices.0").Int() != 0 {
+		t.Fatalf("second support = %s", second.Raw)
+	}
+
+	if got := GeminiGroundingMetadata(nil, text); got != "" {
+		t.Fatalf("empty citations = %q", got)
+	}
+}
+
+func TestParseOpenAIAnnotations(t *testing.T) {
+	annotations := gjson.Parse(`[{"type":"url_citation","url_citation":{"url":"https://a.example","title":"A","start_index":1,"end_index":4}},{"type":"file_citation","file_id":"f"},{"type":"url_citation","url":"https://b.example","start_index":5,"end_index":9}]`)
+	citations := ParseOpenAIAnnotations(annotations)
+	if len(citations) != 2 || citations[0].URL != "https://a.example" || citations[0].End != 4 || citations[1].URL != "https://b.example" || citations[1].Start != 5 {
+		t.Fatalf("citations = %+v", citations)
+	}
+	if got := gjson.Get(citations[0].OpenAIAnnotation(true), "url_citation.title").String(); got != "A" {
+		t.Fatalf("nested annotation title = %q", got)
+	}
+}
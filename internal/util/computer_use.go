@@ -0,0 +1,254 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ClaudeComputerToolType is the Anthropic computer use tool version emitted when translating
+// OpenAI computer_use_preview tools, and ClaudeComputerUseBeta the beta that enables it.
+const (
+	ClaudeComputerToolType = "computer_20250124"
+	ClaudeComputerUseBeta  = "computer-use-2025-01-24"
+)
+
+const claudeComputerSchema = `{"type":"object","properties":{` +
+	`"action":{"type":"string","enum":["key","hold_key","type","cursor_position","mouse_move","left_mouse_down","left_mouse_up","left_click","left_click_drag","right_click","middle_click","double_click","triple_click","scroll","wait","screenshot"]},` +
+	`"coordinate":{"type":"array","items":{"type":"integer"},"description":"(x, y) pixel position on the screen."},` +
+	`"start_coordinate":{"type":"array","items":{"type":"integer"},"description":"(x, y) start position for left_click_drag."},` +
+	`"text":{"type":"string","description":"Text to type, or the key combination for key and hold_key (e.g. ctrl+s)."},` +
+	`"scroll_direction":{"type":"string","enum":["up","down","left","right"]},` +
+	`"scroll_amount":{"type":"integer"},` +
+	`"duration":{"type":"number","description":"Seconds to wait or hold a key."},` +
+	`"key":{"type":"string","description":"Modifier key held during a click or scroll."}` +
+	`},"required":["action"]}`
+
+const claudeBashSchema = `{"type":"object","properties":{` +
+	`"command":{"type":"string","description":"The bash command to run."},` +
+	`"restart":{"type":"boolean","description":"Restart the shell session."}` +
+	`}}`
+
+const claudeTextEditorSchema = `{"type":"object","properties":{` +
+	`"command":{"type":"string","enum":["view","create","str_replace","insert","undo_edit"]},` +
+	`"path":{"type":"string","description":"Absolute path to the file or directory."},` +
+	`"file_text":{"type":"string"},` +
+	`"old_str":{"type":"string"},` +
+	`"new_str":{"type":"string"},` +
+	`"insert_line":{"type":"integer"},` +
+	`"view_range":{"type":"array","items":{"type":"integer"}}` +
+	`},"required":["command","path"]}`
+
+const openAIComputerSchema = `{"type":"object","properties":{` +
+	`"type":{"type":"string","enum":["click","double_click","drag","keypress","move","screenshot","scroll","type","wait"]},` +
+	`"x":{"type":"integer"},` +
+	`"y":{"type":"integer"},` +
+	`"button":{"type":"string","enum":["left","right","wheel","back","forward"]},` +
+	`"keys":{"type":"array","items":{"type":"string"}},` +
+	`"path":{"type":"array","items":{"type":"object","properties":{"x":{"type":"integer"},"y":{"type":"integer"}}}},` +
+	`"scroll_x":{"type":"integer"},` +
+	`"scroll_y":{"type":"integer"},` +
+	`"text":{"type":"string"}` +
+	`},"required":["type"]}`
+
+// IsClaudeClientTool reports whether tool is one of Anthropic's schema-less client tools
+// (computer, bash, text editor), which only Claude models understand natively.
+func IsClaudeClientTool(tool gjson.Result) bool {
+	typ := tool.Get("type").String()
+	return strings.HasPrefix(typ, "computer_") || strings.HasPrefix(typ, "bash_") || strings.HasPrefix(typ, "text_editor_")
+}
+
+// IsOpenAIComputerTool reports whether tool is OpenAI's computer_use_preview tool.
+func IsOpenAIComputerTool(tool gjson.Result) bool {
+	return tool.Get("type").String() == "computer_use_preview"
+}
+
+// ClaudeClientToolAsFunction rewrites an Anthropic client tool as a plain custom tool with an
+// explicit input_schema, so backends without native support can still drive it. Other tools
+// are returned unchanged.
+func ClaudeClientToolAsFunction(tool gjson.Result) gjson.Result {
+	if !IsClaudeClientTool(tool) {
+		return tool
+	}
+	typ := tool.Get("type").String()
+	name := tool.Get("name").String()
+	var description, schema string
+	switch {
+	case strings.HasPrefix(typ, "computer_"):
+		description = "Control the computer's mouse and keyboard and take screenshots."
+		if w, h := tool.Get("display_width_px").Int(), tool.Get("display_height_px").Int(); w > 0 && h > 0 {
+			description += fmt.Sprintf(" The display is %dx%d pixels.", w, h)
+		}
+		schema = claudeComputerSchema
+	case strings.HasPrefix(typ, "bash_"):
+		description = "Run commands in a persistent bash shell."
+		schema = claudeBashSchema
+	default:
+		description = "View, create and edit files."
+		schema = claudeTextEditorSchema
+	}
+	out := `{"name":"","description":""}`
+	out, _ = sjson.Set(out, "name", name)
+	out, _ = sjson.Set(out, "description", description)
+	out, _ = sjson.SetRaw(out, "input_schema", schema)
+	return gjson.Parse(out)
+}
+
+// OpenAIComputerToolAsFunction rewrites an OpenAI computer_use_preview tool as a Responses
+// function tool named "computer" whose arguments are a computer_call action.
+func OpenAIComputerToolAsFunction(tool gjson.Result) gjson.Result {
+	description := "Control the computer's mouse and keyboard and take screenshots."
+	if w, h := tool.Get("display_width").Int(), tool.Get("display_height").Int(); w > 0 && h > 0 {
+		description += fmt.Sprintf(" The display is %dx%d pixels.", w, h)
+	}
+	out := `{"type":"function","name":"computer","description":""}`
+	out, _ = sjson.Set(out, "description", description)
+	out, _ = sjson.SetRaw(out, "parameters", openAIComputerSchema)
+	return gjson.Parse(out)
+}
+
+// OpenAIComputerToolToClaude converts an OpenAI computer_use_preview tool to Claude's computer tool.
+func OpenAIComputerToolToClaude(tool gjson.Result) string {
+	out := `{"type":"","name":"computer"}`
+	out, _ = sjson.Set(out, "type", ClaudeComputerToolType)
+	out, _ = sjson.Set(out, "display_width_px", tool.Get("display_width").Int())
+	out, _ = sjson.Set(out, "display_height_px", tool.Get("display_height").Int())
+	return out
+}
+
+// OpenAIComputerActionToClaude converts an OpenAI computer_call action to the input of a Claude
+// computer tool_use block. Actions Claude cannot express fall back to a screenshot.
+func OpenAIComputerActionToClaude(action gjson.Result) string {
+	coordinate := func(out string, path string, x, y gjson.Result) string {
+		out, _ = sjson.Set(out, path, []int64{x.Int(), y.Int()})
+		return out
+	}
+	out := `{"action":"screenshot"}`
+	switch action.Get("type").String() {
+	case "click":
+		switch action.Get("button").String() {
+		case "right":
+			out, _ = sjson.Set(out, "action", "right_click")
+		case "wheel":
+			out, _ = sjson.Set(out, "action", "middle_click")
+		default:
+			out, _ = sjson.Set(out, "action", "left_click")
+		}
+		out = coordinate(out, "coordinate", action.Get("x"), action.Get("y"))
+	case "double_click":
+		out, _ = sjson.Set(out, "action", "double_click")
+		out = coordinate(out, "coordinate", action.Get("x"), action.Get("y"))
+	case "move":
+		out, _ = sjson.Set(out, "action", "mouse_move")
+		out = coordinate(out, "coordinate", action.Get("x"), action.Get("y"))
+	case "drag":
+		path := action.Get("path").Array()
+		if len(path) < 2 {
+			break
+		}
+		out, _ = sjson.Set(out, "action", "left_click_drag")
+		out = coordinate(out, "start_coordinate", path[0].Get("x"), path[0].Get("y"))
+		last := path[len(path)-1]
+		out = coordinate(out, "coordinate", last.Get("x"), last.Get("y"))
+	case "keypress":
+		var keys []string
+		action.Get("keys").ForEach(func(_, k gjson.Result) bool {
+			keys = append(keys, strings.ToLower(k.String()))
+			return true
+		})
+		out, _ = sjson.Set(out, "action", "key")
+		out, _ = sjson.Set(out, "text", strings.Join(keys, "+"))
+	case "type":
+		out, _ = sjson.Set(out, "action", "type")
+		out, _ = sjson.Set(out, "text", action.Get("text").String())
+	case "scroll":
+		out, _ = sjson.Set(out, "action", "scroll")
+		out = coordinate(out, "coordinate", action.Get("x"), action.Get("y"))
+		dx, dy := action.Get("scroll_x").Int(), action.Get("scroll_y").Int()
+		direction, amount := "down", dy
+		switch {
+		case abs64(dx) > abs64(dy) && dx > 0:
+			direction, amount = "right", dx
+		case abs64(dx) > abs64(dy):
+			direction, amount = "left", -dx
+		case dy < 0:
+			direction, amount = "up", -dy
+		}
+		out, _ = sjson.Set(out, "scroll_direction", direction)
+		out, _ = sjson.Set(out, "scroll_amount", max(amount/scrollPixelsPerClick, 1))
+	case "wait":
+		out, _ = sjson.Set(out, "action", "wait")
+		out, _ = sjson.Set(out, "duration", 1)
+	}
+	return out
+}
+
+// ClaudeComputerActionToOpenAI converts the input of a Claude computer tool_use block to an
+// OpenAI computer_call action. Actions OpenAI cannot express fall back to a screenshot.
+func ClaudeComputerActionToOpenAI(input gjson.Result) string {
+	xy := func(out string, c gjson.Result) string {
+		out, _ = sjson.Set(out, "x", c.Get("0").Int())
+		out, _ = sjson.Set(out, "y", c.Get("1").Int())
+		return out
+	}
+	coordinate := input.Get("coordinate")
+	out := `{"type":"screenshot"}`
+	switch action := input.Get("action").String(); action {
+	case "left_click", "right_click", "middle_click":
+		button := map[string]string{"left_click": "left", "right_click": "right", "middle_click": "wheel"}[action]
+		out, _ = sjson.Set(out, "type", "click")
+		out, _ = sjson.Set(out, "button", button)
+		out = xy(out, coordinate)
+	case "double_click":
+		out, _ = sjson.Set(out, "type", "double_click")
+		out = xy(out, coordinate)
+	case "mouse_move":
+		out, _ = sjson.Set(out, "type", "move")
+		out = xy(out, coordinate)
+	case "left_click_drag":
+		start := input.Get("start_coordinate")
+		out, _ = sjson.Set(out, "type", "drag")
+		out, _ = sjson.Set(out, "path", []map[string]int64{
+			{"x": start.Get("0").Int(), "y": start.Get("1").Int()},
+			{"x": coordinate.Get("0").Int(), "y": coordinate.Get("1").Int()},
+		})
+	case "key":
+		out, _ = sjson.Set(out, "type", "keypress")
+		out, _ = sjson.Set(out, "keys", strings.Split(input.Get("text").String(), "+"))
+	case "type":
+		out, _ = sjson.Set(out, "type", "type")
+		out, _ = sjson.Set(out, "text", input.Get("text").String())
+	case "scroll":
+		amount := max(input.Get("scroll_amount").Int(), 1) * scrollPixelsPerClick
+		var dx, dy int64
+		switch input.Get("scroll_direction").String() {
+		case "up":
+			dy = -amount
+		case "left":
+			dx = -amount
+		case "right":
+			dx = amount
+		default:
+			dy = amount
+		}
+		out, _ = sjson.Set(out, "type", "scroll")
+		out = xy(out, coordinate)
+		out, _ = sjson.Set(out, "scroll_x", dx)
+		out, _ = sjson.Set(out, "scroll_y", dy)
+	case "wait":
+		out, _ = sjson.Set(out, "type", "wait")
+	}
+	return out
+}
+
+// scrollPixelsPerClick approximates one Claude scroll_amount unit in OpenAI scroll pixels.
+const scrollPixelsPerClick = 100
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
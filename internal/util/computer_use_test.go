@@ -0,0 +1,50 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestComputerActionRoundTrip(t *testing.T) {
+	cases := []struct {
+		openai string
+		claude string
+	}{
+		{`{"type":"click","button":"right","x":10,"y":20}`, `{"action":"right_click","coordinate":[10,20]}`},
+		{`{"type":"double_click","x":1,"y":2}`, `{"action":"double_click","coordinate":[1,2]}`},
+		{`{"type":"drag","path":[{"x":1,"y":2},{"x":3,"y":4}]}`, `{"action":"left_click_drag","start_coordinate":[1,2],"coordinate":[3,4]}`},
+		{`{"type":"keypress","keys":["ctrl","s"]}`, `{"action":"key","text":"ctrl+s"}`},
+		{`{"type":"type","text":"hello"}`, `{"action":"type","text":"hello"}`},
+		{`{"type":"scroll","x":5,"y":6,"scroll_x":0,"scroll_y":-300}`, `{"action":"scroll","coordinate":[5,6],"scroll_direction":"up","scroll_amount":3}`},
+		{`{"type":"screenshot"}`, `{"action":"screenshot"}`},
+	}
+	for _, tc := range cases {
+		if got := OpenAIComputerActionToClaude(gjson.Parse(tc.openai)); got != tc.claude {
+			t.Errorf("OpenAIComputerActionToClaude(%s) = %s, want %s", tc.openai, got, tc.claude)
+		}
+		back := gjson.Parse(ClaudeComputerActionToOpenAI(gjson.Parse(tc.claude)))
+		want := gjson.Parse(tc.openai)
+		want.ForEach(func(key, value gjson.Result) bool {
+			if back.Get(key.String()).Raw != value.Raw {
+				t.Errorf("ClaudeComputerActionToOpenAI(%s).%s = %s, want %s", tc.claude, key, back.Get(key.String()).Raw, value.Raw)
+			}
+			return true
+		})
+	}
+}
+
+func TestClaudeClientToolAsFunction(t *testing.T) {
+	tool := ClaudeClientToolAsFunction(gjson.Parse(`{"type":"computer_20250124","name":"computer","display_width_px":1024,"display_height_px":768}`))
+	if tool.Get("type").Exists() || tool.Get("name").String() != "computer" || tool.Get("input_schema.properties.action.enum").Raw == "" {
+		t.Fatalf("computer tool not degraded: %s", tool.Raw)
+	}
+	if got := tool.Get("description").String(); got != "Control the computer's mouse and keyboard and take screenshots. The display is 1024x768 pixels." {
+		t.Fatalf("description = %q", got)
+	}
+
+	plain := gjson.Parse(`{"name":"lookup","input_schema":{"type":"object"}}`)
+	if got := ClaudeClientToolAsFunction(plain); got.Raw != plain.Raw {
+		t.Fatalf("custom tool changed: %s", got.Raw)
+	}
+}
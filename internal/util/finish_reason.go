@@ -0,0 +1,182 @@
+package util
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Finish reason dialects understood by MapFinishReason.
+const (
+	// FinishReasonOpenAI is the Chat Completions choices[].finish_reason.
+	FinishReasonOpenAI = "openai"
+	// FinishReasonClaude is the Messages API stop_reason.
+	FinishReasonClaude = "claude"
+	// FinishReasonGemini is the generateContent candidates[].finishReason.
+	FinishReasonGemini = "gemini"
+	// FinishReasonResponses is the Responses API incomplete_details.reason; "" means the
+	// response completed normally.
+	FinishReasonResponses = "responses"
+)
+
+// DefaultRefusalMessage explains a policy stop to clients whose protocol expects refusal text
+// when the upstream reported the stop without an explanation of its own.
+const DefaultRefusalMessage = "The response was blocked by the upstream safety filters."
+
+// Protocol-neutral finish reasons every dialect is mapped through.
+const (
+	finishStop           = "stop"
+	finishStopSequence   = "stop_sequence"
+	finishLength         = "length"
+	finishToolCalls      = "tool_calls"
+	finishContentFilter  = "content_filter"
+	finishRefusal        = "refusal"
+	finishSafety         = "safety"
+	finishRecitation     = "recitation"
+	finishPause          = "pause"
+	finishMalformedCall  = "malformed_function_call"
+	finishContextOverrun = "context_window_exceeded"
+)
+
+// finishReasonsIn maps each dialect's native values (lower-cased) to the neutral reason.
+var finishReasonsIn = map[string]map[string]string{
+	FinishReasonOpenAI: {
+		"stop":           finishStop,
+		"length":         finishLength,
+		"tool_calls":     finishToolCalls,
+		"function_call":  finishToolCalls,
+		"content_filter": finishContentFilter,
+	},
+	FinishReasonClaude: {
+		"end_turn":                      finishStop,
+		"stop_sequence":                 finishStopSequence,
+		"max_tokens":                    finishLength,
+		"tool_use":                      finishToolCalls,
+		"refusal":                       finishRefusal,
+		"pause_turn":                    finishPause,
+		"model_context_window_exceeded": finishContextOverrun,
+	},
+	FinishReasonGemini: {
+		"stop":                      finishStop,
+		"finish_reason_unspecified": finishStop,
+		"other":                     finishStop,
+		"max_tokens":                finishLength,
+		"safety":                    finishSafety,
+		"image_safety":              finishSafety,
+		"recitation":                finishRecitation,
+		"blocklist":                 finishContentFilter,
+		"prohibited_content":        finishContentFilter,
+		"spii":                      finishContentFilter,
+		"language":                  finishContentFilter,
+		"malformed_function_call":   finishMalformedCall,
+		"unexpected_tool_call":      finishMalformedCall,
+		"too_many_tool_calls":       finishMalformedCall,
+	},
+	FinishReasonResponses: {
+		"":                  finishStop,
+		"max_output_tokens": finishLength,
+		"content_filter":    finishContentFilter,
+	},
+}
+
+// finishReasonsOut maps each neutral reason to the closest native value of a dialect.
+var finishReasonsOut = map[string]map[string]string{
+	FinishReasonOpenAI: {
+		finishStop:           "stop",
+		finishStopSequence:   "stop",
+		finishLength:         "length",
+		finishToolCalls:      "tool_calls",
+		finishContentFilter:  "content_filter",
+		finishRefusal:        "content_filter",
+		finishSafety:         "content_filter",
+		finishRecitation:     "content_filter",
+		finishPause:          "stop",
+		finishMalformedCall:  "stop",
+		finishContextOverrun: "length",
+	},
+	FinishReasonClaude: {
+		finishStop:           "end_turn",
+		finishStopSequence:   "stop_sequence",
+		finishLength:         "max_tokens",
+		finishToolCalls:      "tool_use",
+		finishContentFilter:  "refusal",
+		finishRefusal:        "refusal",
+		finishSafety:         "refusal",
+		finishRecitation:     "refusal",
+		finishPause:          "pause_turn",
+		finishMalformedCall:  "end_turn",
+		finishContextOverrun: "model_context_window_exceeded",
+	},
+	FinishReasonGemini: {
+		finishStop:           "STOP",
+		finishStopSequence:   "STOP",
+		finishLength:         "MAX_TOKENS",
+		finishToolCalls:      "STOP",
+		finishContentFilter:  "SAFETY",
+		finishRefusal:        "SAFETY",
+		finishSafety:         "SAFETY",
+		finishRecitation:     "RECITATION",
+		finishPause:          "STOP",
+		finishMalformedCall:  "MALFORMED_FUNCTION_CALL",
+		finishContextOverrun: "MAX_TOKENS",
+	},
+	FinishReasonResponses: {
+		finishStop:           "",
+		finishStopSequence:   "",
+		finishLength:         "max_output_tokens",
+		finishToolCalls:      "",
+		finishContentFilter:  "content_filter",
+		finishRefusal:        "content_filter",
+		finishSafety:         "content_filter",
+		finishRecitation:     "content_filter",
+		finishPause:          "",
+		finishMalformedCall:  "",
+		finishContextOverrun: "max_output_tokens",
+	},
+}
+
+var (
+	finishReasonOverridesMu sync.RWMutex
+	finishReasonOverrides   map[string]string
+)
+
+func finishReasonOverrideKey(from, to, reason string) string {
+	return strings.ToLower(from) + "\x00" + strings.ToLower(to) + "\x00" + strings.ToLower(reason)
+}
+
+// SetFinishReasonOverrides installs the configured finish reason overrides, replacing any
+// previously installed ones.
+func SetFinishReasonOverrides(overrides []config.FinishReasonOverride) {
+	table := make(map[string]string, len(overrides))
+	for _, o := range overrides {
+		from, to := strings.TrimSpace(o.From), strings.TrimSpace(o.To)
+		if from == "" || to == "" {
+			continue
+		}
+		table[finishReasonOverrideKey(from, to, strings.TrimSpace(o.Reason))] = strings.TrimSpace(o.Value)
+	}
+	finishReasonOverridesMu.Lock()
+	finishReasonOverrides = table
+	finishReasonOverridesMu.Unlock()
+}
+
+// MapFinishReason translates a finish reason reported in the from dialect into the to dialect.
+// Configured overrides win over the built-in table. Unknown values map to a normal stop, except
+// that a value is passed through unchanged when both dialects are the same.
+func MapFinishReason(from, to, reason string) string {
+	finishReasonOverridesMu.RLock()
+	override, ok := finishReasonOverrides[finishReasonOverrideKey(from, to, reason)]
+	finishReasonOverridesMu.RUnlock()
+	if ok {
+		return override
+	}
+	if from == to {
+		return reason
+	}
+	neutral, ok := finishReasonsIn[from][strings.ToLower(strings.TrimSpace(reason))]
+	if !ok {
+		neutral = finishStop
+	}
+	return finishReasonsOut[to][neutral]
+}
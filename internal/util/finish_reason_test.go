@@ -0,0 +1,44 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestMapFinishReason(t *testing.T) {
+	cases := []struct {
+		from, to, reason, want string
+	}{
+		{FinishReasonGemini, FinishReasonClaude, "MAX_TOKENS", "max_tokens"},
+		{FinishReasonGemini, FinishReasonClaude, "SAFETY", "refusal"},
+		{FinishReasonGemini, FinishReasonOpenAI, "RECITATION", "content_filter"},
+		{FinishReasonGemini, FinishReasonOpenAI, "STOP", "stop"},
+		{FinishReasonClaude, FinishReasonOpenAI, "tool_use", "tool_calls"},
+		{FinishReasonClaude, FinishReasonGemini, "refusal", "SAFETY"},
+		{FinishReasonClaude, FinishReasonResponses, "max_tokens", "max_output_tokens"},
+		{FinishReasonClaude, FinishReasonResponses, "end_turn", ""},
+		{FinishReasonOpenAI, FinishReasonClaude, "function_call", "tool_use"},
+		{FinishReasonOpenAI, FinishReasonClaude, "content_filter", "refusal"},
+		{FinishReasonResponses, FinishReasonClaude, "", "end_turn"},
+		{FinishReasonResponses, FinishReasonGemini, "max_output_tokens", "MAX_TOKENS"},
+		{FinishReasonOpenAI, FinishReasonClaude, "something_new", "end_turn"},
+	}
+	for _, tc := range cases {
+		if got := MapFinishReason(tc.from, tc.to, tc.reason); got != tc.want {
+			t.Errorf("MapFinishReason(%s, %s, %q) = %q, want %q", tc.from, tc.to, tc.reason, got, tc.want)
+		}
+	}
+}
+
+func TestFinishReasonOverrides(t *testing.T) {
+	SetFinishReasonOverrides([]config.FinishReasonOverride{{From: "gemini", To: "claude", Reason: "RECITATION", Value: "end_turn"}})
+	defer SetFinishReasonOverrides(nil)
+
+	if got := MapFinishReason(FinishReasonGemini, FinishReasonClaude, "recitation"); got != "end_turn" {
+		t.Fatalf("override not applied: %q", got)
+	}
+	if got := MapFinishReason(FinishReasonGemini, FinishReasonOpenAI, "RECITATION"); got != "content_filter" {
+		t.Fatalf("override leaked to another dialect: %q", got)
+	}
+}
@@ -6,8 +6,57 @@ import (
 	"image"
 	"image/draw"
 	"image/png"
+	"math"
+	"strconv"
+	"strings"
 )
 
+// Aspect ratios accepted by Gemini image models and the narrower set accepted by Imagen.
+var (
+	GeminiImageAspectRatios = []string{"1:1", "2:3", "3:2", "3:4", "4:3", "4:5", "5:4", "9:16", "16:9", "21:9"}
+	ImagenAspectRatios      = []string{"1:1", "3:4", "4:3", "9:16", "16:9"}
+)
+
+// IsImagenModel reports whether model is a Gemini Imagen model, which generates images through
+// the :predict method rather than generateContent.
+func IsImagenModel(model string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(model)), "imagen-")
+}
+
+// ImageSizeToAspectRatio maps an OpenAI image size such as "1536x1024" to the closest of the
+// given aspect ratios. It returns "" for "auto" and unparsable sizes.
+func ImageSizeToAspectRatio(size string, ratios []string) string {
+	width, height, ok := parseImageDimensions(size, "x")
+	if !ok {
+		return ""
+	}
+	target := math.Log(width / height)
+	best, bestDiff := "", math.Inf(1)
+	for _, ratio := range ratios {
+		w, h, okRatio := parseImageDimensions(ratio, ":")
+		if !okRatio {
+			continue
+		}
+		if diff := math.Abs(math.Log(w/h) - target); diff < bestDiff {
+			best, bestDiff = ratio, diff
+		}
+	}
+	return best
+}
+
+func parseImageDimensions(value, sep string) (float64, float64, bool) {
+	w, h, found := strings.Cut(strings.ToLower(strings.TrimSpace(value)), sep)
+	if !found {
+		return 0, 0, false
+	}
+	width, errW := strconv.ParseFloat(w, 64)
+	height, errH := strconv.ParseFloat(h, 64)
+	if errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
 func CreateWhiteImageBase64(aspectRatio string) (string, error) {
 	width := 1024
 	height := 1024
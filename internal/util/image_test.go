@@ -0,0 +1,24 @@
+package util
+
+import "testing"
+
+func TestImageSizeToAspectRatio(t *testing.T) {
+	cases := []struct {
+		size string
+		want string
+	}{
+		{"1024x1024", "1:1"},
+		{"1536x1024", "4:3"},
+		{"1024x1536", "3:4"},
+		{"1792x1024", "16:9"},
+		{"1024x1792", "9:16"},
+		{"auto", ""},
+		{"", ""},
+		{"bogus", ""},
+	}
+	for _, tc := range cases {
+		if got := ImageSizeToAspectRatio(tc.size, ImagenAspectRatios); got != tc.want {
+			t.Errorf("ImageSizeToAspectRatio(%q) = %q, want %q", tc.size, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// GeminiLogprobsToOpenAI converts a Gemini candidate logprobsResult into an OpenAI Chat
+// Completions choice logprobs object ({"content":[...]}). It returns false when the upstream
+// supplied no token log probabilities.
+func GeminiLogprobsToOpenAI(result gjson.Result) (string, bool) {
+	chosen := result.Get("chosenCandidates").Array()
+	if len(chosen) == 0 {
+		return "", false
+	}
+	top := result.Get("topCandidates").Array()
+	out := `{"content":[]}`
+	for i, candidate := range chosen {
+		entry := `{"token":"","logprob":0,"bytes":null,"top_logprobs":[]}`
+		entry, _ = sjson.Set(entry, "token", candidate.Get("token").String())
+		entry, _ = sjson.Set(entry, "logprob", candidate.Get("logProbability").Float())
+		if token := candidate.Get("token").String(); token != "" {
+			entry, _ = sjson.Set(entry, "bytes", tokenBytes(token))
+		}
+		if i < len(top) {
+			for _, alt := range top[i].Get("candidates").Array() {
+				altEntry := `{"token":"","logprob":0,"bytes":null}`
+				altEntry, _ = sjson.Set(altEntry, "token", alt.Get("token").String())
+				altEntry, _ = sjson.Set(altEntry, "logprob", alt.Get("logProbability").Float())
+				if token := alt.Get("token").String(); token != "" {
+					altEntry, _ = sjson.Set(altEntry, "bytes", tokenBytes(token))
+				}
+				entry, _ = sjson.SetRaw(entry, "top_logprobs.-1", altEntry)
+			}
+		}
+		out, _ = sjson.SetRaw(out, "content.-1", entry)
+	}
+	return out, true
+}
+
+// tokenBytes returns the UTF-8 bytes of token as integers, matching OpenAI's logprobs "bytes" field.
+func tokenBytes(token string) []int {
+	out := make([]int, len(token))
+	for i := 0; i < len(token); i++ {
+		out[i] = int(token[i])
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+// DefaultMaxTokens is the output budget sent to upstreams that require one (Claude) when the
+// client omitted it and neither the configuration nor the model catalog suggests a smaller one.
+const DefaultMaxTokens = 32000
+
+var (
+	maxTokensOverridesMu sync.RWMutex
+	maxTokensOverrides   []config.ModelMaxTokens
+)
+
+// SetMaxTokensOverrides installs the configured per-model output token limits, replacing any
+// previously installed ones.
+func SetMaxTokensOverrides(overrides []config.ModelMaxTokens) {
+	cleaned := make([]config.ModelMaxTokens, 0, len(overrides))
+	for _, o := range overrides {
+		o.Model = strings.TrimSpace(o.Model)
+		if o.Model == "" || (o.Limit <= 0 && o.Default <= 0) {
+			continue
+		}
+		cleaned = append(cleaned, o)
+	}
+	maxTokensOverridesMu.Lock()
+	maxTokensOverrides = cleaned
+	maxTokensOverridesMu.Unlock()
+}
+
+func maxTokensOverride(model string) (config.ModelMaxTokens, bool) {
+	maxTokensOverridesMu.RLock()
+	defer maxTokensOverridesMu.RUnlock()
+	for _, o := range maxTokensOverrides {
+		if MatchModelWildcard(o.Model, model) {
+			return o, true
+		}
+	}
+	return config.ModelMaxTokens{}, false
+}
+
+// ModelOutputTokenLimit returns the largest output token count the model accepts, preferring the
+// configured override over the registry catalog. It returns 0 when the limit is unknown.
+func ModelOutputTokenLimit(model string) int {
+	if model == "" {
+		return 0
+	}
+	if o, ok := maxTokensOverride(model); ok && o.Limit > 0 {
+		return o.Limit
+	}
+	limitOf := func(info *registry.ModelInfo) int {
+		if info.MaxCompletionTokens > 0 {
+			return info.MaxCompletionTokens
+		}
+		return info.OutputTokenLimit
+	}
+	if info := registry.GetGlobalRegistry().GetModelInfo(model); info != nil {
+		if limit := limitOf(info); limit > 0 {
+			return limit
+		}
+	}
+	if info := registry.LookupStaticModelInfo(model); info != nil {
+		if limit := limitOf(info); limit > 0 {
+			return limit
+		}
+	}
+	if cfg := registry.GetAntigravityModelConfig()[model]; cfg != nil {
+		return cfg.MaxCompletionTokens
+	}
+	return 0
+}
+
+// BoundMaxTokens lowers a client-requested output token count to the model's limit so upstreams
+// that reject oversized values accept the request. Non-positive values are returned unchanged.
+func BoundMaxTokens(model string, requested int64) int64 {
+	if requested <= 0 {
+		return requested
+	}
+	if limit := int64(ModelOutputTokenLimit(model)); limit > 0 && requested > limit {
+		return limit
+	}
+	return requested
+}
+
+// ResolveMaxTokens returns the output token count for upstreams that require one: the requested
+// value bounded by the model's limit, or a default when the client omitted it.
+func ResolveMaxTokens(model string, requested int64) int64 {
+	if requested > 0 {
+		return BoundMaxTokens(model, requested)
+	}
+	if o, ok := maxTokensOverride(model); ok && o.Default > 0 {
+		return BoundMaxTokens(model, int64(o.Default))
+	}
+	return BoundMaxTokens(model, DefaultMaxTokens)
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestResolveMaxTokens(t *testing.T) {
+	defer SetMaxTokensOverrides(nil)
+
+	// Catalog limit: claude-3-5-haiku accepts at most 8192 output tokens.
+	if got := ResolveMaxTokens("claude-3-5-haiku-20241022", 0); got != 8192 {
+		t.Fatalf("default for capped model = %d, want 8192", got)
+	}
+	if got := ResolveMaxTokens("claude-3-5-haiku-20241022", 100000); got != 8192 {
+		t.Fatalf("oversized request = %d, want 8192", got)
+	}
+	if got := ResolveMaxTokens("unknown-model", 0); got != DefaultMaxTokens {
+		t.Fatalf("default for unknown model = %d, want %d", got, DefaultMaxTokens)
+	}
+	if got := BoundMaxTokens("unknown-model", 123456); got != 123456 {
+		t.Fatalf("unknown model bound = %d, want unchanged", got)
+	}
+
+	SetMaxTokensOverrides([]config.ModelMaxTokens{
+		{Model: "my-*-alias", Limit: 4000, Default: 1000},
+		{Model: "unknown-model", Default: 50000},
+	})
+	if got := ResolveMaxTokens("my-fast-alias", 0); got != 1000 {
+		t.Fatalf("override default = %d, want 1000", got)
+	}
+	if got := BoundMaxTokens("My-Fast-Alias", 9000); got != 4000 {
+		t.Fatalf("override limit = %d, want 4000", got)
+	}
+	if got := ResolveMaxTokens("unknown-model", 0); got != 50000 {
+		t.Fatalf("override default without limit = %d, want 50000", got)
+	}
+}
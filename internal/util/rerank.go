@@ -0,0 +1,137 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Rerank upstream dialects accepted by openai-compatibility providers.
+const (
+	RerankFormatCohere = "cohere"
+	RerankFormatVoyage = "voyage"
+	RerankFormatJina   = "jina"
+)
+
+// NormalizeRerankFormat returns the canonical dialect name, defaulting to Cohere.
+func NormalizeRerankFormat(format string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case RerankFormatVoyage:
+		return RerankFormatVoyage
+	case RerankFormatJina:
+		return RerankFormatJina
+	}
+	return RerankFormatCohere
+}
+
+// rerankDocuments returns the documents of a rerank request as plain strings. Documents may be
+// given as strings or as objects with a text field.
+func rerankDocuments(root gjson.Result) []string {
+	docs := root.Get("documents").Array()
+	out := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if doc.IsObject() {
+			out = append(out, doc.Get("text").String())
+			continue
+		}
+		out = append(out, doc.String())
+	}
+	return out
+}
+
+// RerankRequestToUpstream converts a request in the common rerank schema (model, query,
+// documents, top_n, return_documents) into the request body of the given dialect.
+func RerankRequestToUpstream(format string, payload []byte) []byte {
+	root := gjson.ParseBytes(payload)
+
+	out := `{"model":"","query":"","documents":[]}`
+	out, _ = sjson.Set(out, "model", root.Get("model").String())
+	out, _ = sjson.Set(out, "query", root.Get("query").String())
+	out, _ = sjson.Set(out, "documents", rerankDocuments(root))
+
+	topN := root.Get("top_n")
+	if !topN.Exists() {
+		topN = root.Get("top_k")
+	}
+	returnDocuments := root.Get("return_documents")
+	switch NormalizeRerankFormat(format) {
+	case RerankFormatVoyage:
+		if topN.Exists() {
+			out, _ = sjson.Set(out, "top_k", topN.Int())
+		}
+		if returnDocuments.Exists() {
+			out, _ = sjson.Set(out, "return_documents", returnDocuments.Bool())
+		}
+		if truncation := root.Get("truncation"); truncation.Exists() {
+			out, _ = sjson.Set(out, "truncation", truncation.Bool())
+		}
+	case RerankFormatJina:
+		if topN.Exists() {
+			out, _ = sjson.Set(out, "top_n", topN.Int())
+		}
+		if returnDocuments.Exists() {
+			out, _ = sjson.Set(out, "return_documents", returnDocuments.Bool())
+		}
+	default:
+		if topN.Exists() {
+			out, _ = sjson.Set(out, "top_n", topN.Int())
+		}
+		if maxTokens := root.Get("max_tokens_per_doc"); maxTokens.Exists() {
+			out, _ = sjson.Set(out, "max_tokens_per_doc", maxTokens.Int())
+		}
+	}
+	return []byte(out)
+}
+
+// RerankResponseFromUpstream converts a dialect response into the common rerank response:
+// results ordered by relevance with index and relevance_score, plus document.text when the
+// original request asked for return_documents. Documents are filled from the request when the
+// upstream does not echo them, as Cohere v2 does not.
+func RerankResponseFromUpstream(format string, body, originalPayload []byte) []byte {
+	root := gjson.ParseBytes(body)
+	request := gjson.ParseBytes(originalPayload)
+	documents := rerankDocuments(request)
+	returnDocuments := request.Get("return_documents").Bool()
+
+	out := `{"results":[]}`
+	if id := root.Get("id"); id.Exists() {
+		out, _ = sjson.Set(out, "id", id.String())
+	}
+	model := root.Get("model").String()
+	if model == "" {
+		model = request.Get("model").String()
+	}
+	out, _ = sjson.Set(out, "model", model)
+
+	results := root.Get("results")
+	if NormalizeRerankFormat(format) == RerankFormatVoyage {
+		results = root.Get("data")
+	}
+	results.ForEach(func(_, result gjson.Result) bool {
+		index := result.Get("index").Int()
+		item := `{"index":0,"relevance_score":0}`
+		item, _ = sjson.Set(item, "index", index)
+		item, _ = sjson.Set(item, "relevance_score", result.Get("relevance_score").Float())
+		if returnDocuments {
+			text := result.Get("document.text").String()
+			if doc := result.Get("document"); doc.Type == gjson.String {
+				text = doc.String()
+			}
+			if text == "" && index >= 0 && int(index) < len(documents) {
+				text = documents[index]
+			}
+			item, _ = sjson.Set(item, "document.text", text)
+		}
+		out, _ = sjson.SetRaw(out, "results.-1", item)
+		return true
+	})
+
+	if total := root.Get("usage.total_tokens"); total.Exists() {
+		out, _ = sjson.Set(out, "usage.total_tokens", total.Int())
+	}
+	if meta := root.Get("meta"); meta.Exists() {
+		out, _ = sjson.SetRaw(out, "meta", meta.Raw)
+	}
+	return []byte(out)
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestRerankRequestToUpstreamVoyage(t *testing.T) {
+	payload := []byte(`{"model":"rerank-2","query":"q","documents":["a",{"text":"b"}],"top_n":1,"return_documents":true}`)
+	out := gjson.ParseBytes(RerankRequestToUpstream(RerankFormatVoyage, payload))
+	if out.Get("top_k").Int() != 1 || out.Get("top_n").Exists() {
+		t.Fatalf("expected top_k for voyage, got %s", out.Raw)
+	}
+	if docs := out.Get("documents").Array(); len(docs) != 2 || docs[1].String() != "b" {
+		t.Fatalf("documents not flattened: %s", out.Get("documents").Raw)
+	}
+}
+
+func TestRerankResponseFromUpstream(t *testing.T) {
+	request := []byte(`{"model":"rerank-v3.5","query":"q","documents":["a","b"],"return_documents":true}`)
+
+	cohere := []byte(`{"id":"r1","results":[{"index":1,"relevance_score":0.9},{"index":0,"relevance_score":0.1}],"meta":{"billed_units":{"search_units":1}}}`)
+	out := gjson.ParseBytes(RerankResponseFromUpstream(RerankFormatCohere, cohere, request))
+	if out.Get("results.0.index").Int() != 1 || out.Get("results.0.document.text").String() != "b" {
+		t.Fatalf("unexpected cohere translation: %s", out.Raw)
+	}
+	if out.Get("meta.billed_units.search_units").Int() != 1 {
+		t.Fatalf("meta not preserved: %s", out.Raw)
+	}
+
+	voyage := []byte(`{"object":"list","data":[{"index":0,"relevance_score":0.7,"document":"a"}],"model":"rerank-2","usage":{"total_tokens":12}}`)
+	out = gjson.ParseBytes(RerankResponseFromUpstream(RerankFormatVoyage, voyage, request))
+	if out.Get("results.0.document.text").String() != "a" || out.Get("usage.total_tokens").Int() != 12 || out.Get("model").String() != "rerank-2" {
+		t.Fatalf("unexpected voyage translation: %s", out.Raw)
+	}
+}
@@ -0,0 +1,303 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// maxSchemaRefDepth bounds $ref inlining so recursive definitions terminate.
+const maxSchemaRefDepth = 8
+
+// geminiDroppedSchemaKeywords lists JSON Schema keywords Gemini function declarations reject.
+var geminiDroppedSchemaKeywords = map[string]struct{}{
+	"$schema":               {},
+	"$id":                   {},
+	"$comment":              {},
+	"$anchor":               {},
+	"$dynamicRef":           {},
+	"$dynamicAnchor":        {},
+	"patternProperties":     {},
+	"unevaluatedProperties": {},
+	"unevaluatedItems":      {},
+	"dependentRequired":     {},
+	"dependentSchemas":      {},
+	"dependencies":          {},
+	"propertyNames":         {},
+	"if":                    {},
+	"then":                  {},
+	"else":                  {},
+	"not":                   {},
+	"contains":              {},
+	"minContains":           {},
+	"maxContains":           {},
+	"exclusiveMinimum":      {},
+	"exclusiveMaximum":      {},
+	"multipleOf":            {},
+	"uniqueItems":           {},
+	"contentEncoding":       {},
+	"contentMediaType":      {},
+	"contentSchema":         {},
+	"examples":              {},
+	"deprecated":            {},
+	"readOnly":              {},
+	"writeOnly":             {},
+}
+
+// geminiOpenAPIOnlyDroppedKeywords are additionally rejected by the OpenAPI-style "parameters"
+// field, while "parametersJsonSchema" accepts them.
+var geminiOpenAPIOnlyDroppedKeywords = map[string]struct{}{
+	"additionalProperties": {},
+}
+
+type schemaDowngrader struct {
+	defs        map[string]any
+	dropExtra   map[string]struct{}
+	transformed map[string]struct{}
+	changed     bool
+}
+
+func (d *schemaDowngrader) record(keyword string) {
+	d.transformed[keyword] = struct{}{}
+	d.changed = true
+}
+
+// DowngradeGeminiToolSchemas rewrites every function declaration schema in a Gemini request so it
+// only uses keywords Gemini accepts: $ref is inlined from $defs/definitions, oneOf becomes anyOf,
+// const becomes a single-value enum, allOf is merged, type arrays become nullable types, and
+// unsupported keywords are removed. root is the JSON path holding the request ("" for Gemini,
+// "request" for Gemini CLI). It returns the rewritten payload and the sorted keywords that were
+// transformed or stripped.
+func DowngradeGeminiToolSchemas(payload []byte, root string) ([]byte, []string) {
+	toolsPath := "tools"
+	if root != "" {
+		toolsPath = root + ".tools"
+	}
+	tools := gjson.GetBytes(payload, toolsPath)
+	if !tools.IsArray() {
+		return payload, nil
+	}
+
+	transformed := make(map[string]struct{})
+	for ti, tool := range tools.Array() {
+		for _, declKey := range []string{"functionDeclarations", "function_declarations"} {
+			for fi, decl := range tool.Get(declKey).Array() {
+				for _, schemaKey := range []string{"parametersJsonSchema", "parameters", "responseJsonSchema", "response"} {
+					schema := decl.Get(schemaKey)
+					if !schema.IsObject() {
+						continue
+					}
+					d := &schemaDowngrader{transformed: transformed}
+					if schemaKey == "parameters" || schemaKey == "response" {
+						d.dropExtra = geminiOpenAPIOnlyDroppedKeywords
+					}
+					rewritten, changed := d.downgradeRaw(schema.Raw)
+					if !changed {
+						continue
+					}
+					path := toolsPath + "." + strconv.Itoa(ti) + "." + declKey + "." + strconv.Itoa(fi) + "." + schemaKey
+					if updated, err := sjson.SetRawBytes(payload, path, rewritten); err == nil {
+						payload = updated
+					}
+				}
+			}
+		}
+	}
+	if len(transformed) == 0 {
+		return payload, nil
+	}
+	keywords := make([]string, 0, len(transformed))
+	for k := range transformed {
+		keywords = append(keywords, k)
+	}
+	sort.Strings(keywords)
+	return payload, keywords
+}
+
+func (d *schemaDowngrader) downgradeRaw(raw string) ([]byte, bool) {
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	var schema map[string]any
+	if err := decoder.Decode(&schema); err != nil {
+		return nil, false
+	}
+	d.defs = collectSchemaDefs(schema)
+	out := d.downgrade(schema, 0)
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(out); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSpace(buf.Bytes()), d.changed
+}
+
+func collectSchemaDefs(schema map[string]any) map[string]any {
+	defs := make(map[string]any)
+	for _, key := range []string{"$defs", "definitions"} {
+		if m, ok := schema[key].(map[string]any); ok {
+			for name, def := range m {
+				defs["#/"+key+"/"+name] = def
+			}
+		}
+	}
+	return defs
+}
+
+func (d *schemaDowngrader) downgrade(node any, depth int) any {
+	switch t := node.(type) {
+	case map[string]any:
+		return d.downgradeObject(t, depth)
+	case []any:
+		out := make([]any, len(t))
+		for i, item := range t {
+			out[i] = d.downgrade(item, depth)
+		}
+		return out
+	default:
+		return node
+	}
+}
+
+func (d *schemaDowngrader) downgradeObject(schema map[string]any, depth int) any {
+	if ref, ok := schema["$ref"].(string); ok {
+		d.record("$ref")
+		resolved, found := d.defs[ref]
+		if !found || depth >= maxSchemaRefDepth {
+			out := map[string]any{"type": "object"}
+			if desc, ok := schema["description"].(string); ok {
+				out["description"] = desc
+			}
+			return out
+		}
+		merged := make(map[string]any)
+		if m, ok := resolved.(map[string]any); ok {
+			for k, v := range m {
+				merged[k] = v
+			}
+		}
+		for k, v := range schema {
+			if k != "$ref" {
+				merged[k] = v
+			}
+		}
+		return d.downgradeObject(merged, depth+1)
+	}
+
+	out := make(map[string]any, len(schema))
+	for key, value := range schema {
+		if _, drop := geminiDroppedSchemaKeywords[key]; drop {
+			d.record(key)
+			continue
+		}
+		if _, drop := d.dropExtra[key]; drop {
+			d.record(key)
+			continue
+		}
+		switch key {
+		case "$defs", "definitions":
+			d.record(key)
+		case "properties":
+			props, ok := value.(map[string]any)
+			if !ok {
+				continue
+			}
+			converted := make(map[string]any, len(props))
+			for name, prop := range props {
+				converted[name] = d.downgrade(prop, depth)
+			}
+			out[key] = converted
+		case "oneOf":
+			d.record("oneOf")
+			if _, hasAnyOf := schema["anyOf"]; !hasAnyOf {
+				out["anyOf"] = d.downgrade(value, depth)
+			}
+		case "const":
+			d.record("const")
+			if _, hasEnum := schema["enum"]; !hasEnum {
+				out["enum"] = []any{value}
+			}
+		case "type":
+			types, ok := value.([]any)
+			if !ok {
+				out[key] = value
+				continue
+			}
+			d.record("type[]")
+			var primary string
+			for _, typ := range types {
+				if s, ok := typ.(string); ok {
+					if s == "null" {
+						out["nullable"] = true
+					} else if primary == "" {
+						primary = s
+					}
+				}
+			}
+			if primary == "" {
+				primary = "string"
+			}
+			out[key] = primary
+		default:
+			out[key] = d.downgrade(value, depth)
+		}
+	}
+
+	if allOf, ok := out["allOf"].([]any); ok {
+		d.record("allOf")
+		delete(out, "allOf")
+		mergeAllOfInto(out, allOf)
+	}
+	return out
+}
+
+// mergeAllOfInto folds allOf branches into dst, unioning properties and required lists.
+func mergeAllOfInto(dst map[string]any, branches []any) {
+	for _, branch := range branches {
+		m, ok := branch.(map[string]any)
+		if !ok {
+			continue
+		}
+		for key, value := range m {
+			switch key {
+			case "properties":
+				props, _ := dst["properties"].(map[string]any)
+				if props == nil {
+					props = make(map[string]any)
+				}
+				if src, ok := value.(map[string]any); ok {
+					for name, prop := range src {
+						if _, exists := props[name]; !exists {
+							props[name] = prop
+						}
+					}
+				}
+				dst["properties"] = props
+			case "required":
+				existing, _ := dst["required"].([]any)
+				seen := make(map[any]struct{}, len(existing))
+				for _, r := range existing {
+					seen[r] = struct{}{}
+				}
+				if src, ok := value.([]any); ok {
+					for _, r := range src {
+						if _, dup := seen[r]; !dup {
+							existing = append(existing, r)
+							seen[r] = struct{}{}
+						}
+					}
+				}
+				dst["required"] = existing
+			default:
+				if _, exists := dst[key]; !exists {
+					dst[key] = value
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestDowngradeGeminiToolSchemas(t *testing.T) {
+	payload := []byte(`{"tools":[{"functionDeclarations":[{"name":"edit","parametersJsonSchema":{
+		"$schema":"http://json-schema.org/draft-07/schema#",
+		"type":"object",
+		"$defs":{"Mode":{"type":"string","enum":["a","b"]}},
+		"properties":{
+			"mode":{"$ref":"#/$defs/Mode"},
+			"kind":{"const":"patch"},
+			"target":{"oneOf":[{"type":"string"},{"type":"integer"}]},
+			"note":{"type":["string","null"]},
+			"if":{"type":"string"}
+		},
+		"required":["mode"]
+	}}]}]}`)
+
+	out, keywords := DowngradeGeminiToolSchemas(payload, "")
+	want := []string{"$defs", "$ref", "$schema", "const", "oneOf", "type[]"}
+	if !reflect.DeepEqual(keywords, want) {
+		t.Fatalf("keywords = %v, want %v", keywords, want)
+	}
+
+	schema := gjson.GetBytes(out, "tools.0.functionDeclarations.0.parametersJsonSchema")
+	if schema.Get("\\$schema").Exists() || schema.Get("\\$defs").Exists() {
+		t.Fatalf("expected $schema and $defs to be removed: %s", schema.Raw)
+	}
+	if got := schema.Get("properties.mode.enum.1").String(); got != "b" {
+		t.Fatalf("expected $ref to be inlined, got %s", schema.Get("properties.mode").Raw)
+	}
+	if got := schema.Get("properties.kind.enum.0").String(); got != "patch" {
+		t.Fatalf("expected const to become enum, got %s", schema.Get("properties.kind").Raw)
+	}
+	if !schema.Get("properties.target.anyOf").IsArray() || schema.Get("properties.target.oneOf").Exists() {
+		t.Fatalf("expected oneOf to become anyOf, got %s", schema.Get("properties.target").Raw)
+	}
+	if schema.Get("properties.note.type").String() != "string" || !schema.Get("properties.note.nullable").Bool() {
+		t.Fatalf("expected nullable string, got %s", schema.Get("properties.note").Raw)
+	}
+	if !schema.Get("properties.if").Exists() {
+		t.Fatal("expected property named like a keyword to be preserved")
+	}
+}
+
+func TestDowngradeGeminiToolSchemas_CLIRootAndNoop(t *testing.T) {
+	payload := []byte(`{"request":{"tools":[{"functionDeclarations":[{"name":"f","parameters":{"type":"object","additionalProperties":false,"properties":{}}}]}]}}`)
+	out, keywords := DowngradeGeminiToolSchemas(payload, "request")
+	if !reflect.DeepEqual(keywords, []string{"additionalProperties"}) {
+		t.Fatalf("keywords = %v", keywords)
+	}
+	if gjson.GetBytes(out, "request.tools.0.functionDeclarations.0.parameters.additionalProperties").Exists() {
+		t.Fatalf("expected additionalProperties to be stripped from OpenAPI parameters: %s", out)
+	}
+
+	clean := []byte(`{"tools":[{"functionDeclarations":[{"name":"f","parametersJsonSchema":{"type":"object","properties":{"a":{"type":"string"}}}}]}]}`)
+	got, keywords := DowngradeGeminiToolSchemas(clean, "")
+	if keywords != nil || string(got) != string(clean) {
+		t.Fatalf("expected clean schema to be untouched, got %s (%v)", got, keywords)
+	}
+}
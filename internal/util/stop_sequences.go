@@ -0,0 +1,100 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Maximum number of stop sequences accepted by each upstream protocol.
+const (
+	OpenAIMaxStopSequences = 4
+	GeminiMaxStopSequences = 5
+)
+
+// ParseStopSequences reads an OpenAI stop, Claude stop_sequences or Gemini stopSequences value,
+// accepting either a single string or an array. Empty and duplicate entries are dropped.
+func ParseStopSequences(v gjson.Result) []string {
+	var raw []string
+	switch {
+	case v.Type == gjson.String:
+		raw = []string{v.String()}
+	case v.IsArray():
+		for _, item := range v.Array() {
+			if item.Type == gjson.String {
+				raw = append(raw, item.String())
+			}
+		}
+	}
+	seen := make(map[string]struct{}, len(raw))
+	out := make([]string, 0, len(raw))
+	for _, seq := range raw {
+		if seq == "" {
+			continue
+		}
+		if _, dup := seen[seq]; dup {
+			continue
+		}
+		seen[seq] = struct{}{}
+		out = append(out, seq)
+	}
+	return out
+}
+
+// LimitStopSequences splits seqs into the sequences forwarded upstream and the overflow the
+// proxy has to enforce itself. maxCount <= 0 means the upstream accepts any number.
+func LimitStopSequences(seqs []string, maxCount int) (kept, overflow []string) {
+	if maxCount <= 0 || len(seqs) <= maxCount {
+		return seqs, nil
+	}
+	return seqs[:maxCount], seqs[maxCount:]
+}
+
+// FindStopSequence returns the earliest offset in text where any of seqs occurs, together with
+// the matched sequence. It returns -1 when none match.
+func FindStopSequence(text string, seqs []string) (int, string) {
+	index, matched := -1, ""
+	for _, seq := range seqs {
+		if seq == "" {
+			continue
+		}
+		if i := strings.Index(text, seq); i >= 0 && (index < 0 || i < index) {
+			index, matched = i, seq
+		}
+	}
+	return index, matched
+}
+
+// ApplyClaudeStopSequences enforces stop sequences on a non-streaming Claude message: text is cut
+// at the first match, later content blocks are dropped and stop_reason/stop_sequence are set the
+// way Anthropic reports a matched custom stop sequence.
+func ApplyClaudeStopSequences(message string, seqs []string) string {
+	if len(seqs) == 0 {
+		return message
+	}
+	blocks := gjson.Get(message, "content").Array()
+	for i, block := range blocks {
+		if block.Get("type").String() != "text" {
+			continue
+		}
+		text := block.Get("text").String()
+		idx, seq := FindStopSequence(text, seqs)
+		if idx < 0 {
+			continue
+		}
+		for j := len(blocks) - 1; j > i; j-- {
+			message, _ = sjson.Delete(message, "content."+strconv.Itoa(j))
+		}
+		if idx == 0 {
+			message, _ = sjson.Delete(message, "content."+strconv.Itoa(i))
+		} else {
+			message, _ = sjson.Set(message, "content."+strconv.Itoa(i)+".text", text[:idx])
+		}
+		message, _ = sjson.Set(message, "stop_reason", "stop_sequence")
+		message, _ = sjson.Set(message, "stop_sequence", seq)
+		return message
+	}
+	return message
+}
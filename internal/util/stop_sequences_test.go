@@ -0,0 +1,42 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestParseAndLimitStopSequences(t *testing.T) {
+	seqs := ParseStopSequences(gjson.Parse(`["a","","b","a","c","d","e"]`))
+	if want := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(seqs, want) {
+		t.Fatalf("ParseStopSequences = %v, want %v", seqs, want)
+	}
+	kept, overflow := LimitStopSequences(seqs, OpenAIMaxStopSequences)
+	if len(kept) != 4 || !reflect.DeepEqual(overflow, []string{"e"}) {
+		t.Fatalf("LimitStopSequences = %v / %v", kept, overflow)
+	}
+	if single := ParseStopSequences(gjson.Parse(`"END"`)); !reflect.DeepEqual(single, []string{"END"}) {
+		t.Fatalf("single string stop = %v", single)
+	}
+}
+
+func TestApplyClaudeStopSequences(t *testing.T) {
+	message := `{"content":[{"type":"text","text":"hello ### world"},{"type":"tool_use","id":"t","name":"x","input":{}}],"stop_reason":"tool_use","stop_sequence":null}`
+	out := ApplyClaudeStopSequences(message, []string{"world", "###"})
+	root := gjson.Parse(out)
+	if got := root.Get("content.0.text").String(); got != "hello " {
+		t.Fatalf("text = %q", got)
+	}
+	if n := len(root.Get("content").Array()); n != 1 {
+		t.Fatalf("content blocks = %d, want 1", n)
+	}
+	if root.Get("stop_reason").String() != "stop_sequence" || root.Get("stop_sequence").String() != "###" {
+		t.Fatalf("stop fields = %s / %s", root.Get("stop_reason").Raw, root.Get("stop_sequence").Raw)
+	}
+
+	unchanged := `{"content":[{"type":"text","text":"plain"}],"stop_reason":"end_turn","stop_sequence":null}`
+	if out := ApplyClaudeStopSequences(unchanged, []string{"###"}); out != unchanged {
+		t.Fatalf("message without match changed: %s", out)
+	}
+}
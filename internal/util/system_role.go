@@ -0,0 +1,52 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Instruction messages are normalized the same way by every request translator:
+//   - Claude has a single top-level system prompt, so every system and developer message is
+//     appended to it as a text block, in order, wherever it appeared in the conversation.
+//   - Gemini has a single systemInstruction, so every system and developer message becomes one
+//     of its parts, in order. A request made of nothing but instructions is sent as a user turn
+//     instead, since Gemini requires contents.
+//   - Codex reserves instructions for its own prompt, so system and developer messages are sent
+//     in place as developer messages.
+//   - OpenAI-compatible upstreams receive the messages unchanged.
+
+// IsInstructionRole reports whether an OpenAI Chat Completions or Responses role carries
+// instructions rather than a conversation turn. "developer" is OpenAI's newer name for "system".
+func IsInstructionRole(role string) bool {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case "system", "developer":
+		return true
+	}
+	return false
+}
+
+// InstructionTexts returns the texts of an OpenAI system or developer message content: a string,
+// a single text part or an array of text, input_text or output_text parts. Empty texts are dropped.
+func InstructionTexts(content gjson.Result) []string {
+	var texts []string
+	add := func(part gjson.Result) {
+		if text := part.Get("text"); text.Type == gjson.String && text.String() != "" {
+			texts = append(texts, text.String())
+		}
+	}
+	switch {
+	case content.Type == gjson.String:
+		if content.String() != "" {
+			texts = append(texts, content.String())
+		}
+	case content.IsArray():
+		content.ForEach(func(_, part gjson.Result) bool {
+			add(part)
+			return true
+		})
+	case content.IsObject():
+		add(content)
+	}
+	return texts
+}
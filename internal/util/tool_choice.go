@@ -0,0 +1,162 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Tool choice modes shared by all protocols.
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
+// ToolChoice is a protocol-neutral representation of tool_choice / function_calling_config.
+// Name is set when a specific tool is forced; Mode is then ToolChoiceRequired.
+type ToolChoice struct {
+	Mode string
+	Name string
+}
+
+// ParseOpenAIToolChoice reads an OpenAI Chat Completions or Responses tool_choice value.
+func ParseOpenAIToolChoice(v gjson.Result) (ToolChoice, bool) {
+	switch v.Type {
+	case gjson.String:
+		switch strings.ToLower(strings.TrimSpace(v.String())) {
+		case "auto":
+			return ToolChoice{Mode: ToolChoiceAuto}, true
+		case "none":
+			return ToolChoice{Mode: ToolChoiceNone}, true
+		case "required", "any":
+			return ToolChoice{Mode: ToolChoiceRequired}, true
+		}
+	case gjson.JSON:
+		if v.Get("type").String() != "function" {
+			return ToolChoice{}, false
+		}
+		// Chat Completions nests the name under "function"; Responses keeps it at the top level.
+		name := v.Get("function.name").String()
+		if name == "" {
+			name = v.Get("name").String()
+		}
+		if name != "" {
+			return ToolChoice{Mode: ToolChoiceRequired, Name: name}, true
+		}
+	}
+	return ToolChoice{}, false
+}
+
+// ParseClaudeToolChoice reads an Anthropic tool_choice object.
+func ParseClaudeToolChoice(v gjson.Result) (ToolChoice, bool) {
+	if !v.IsObject() {
+		return ToolChoice{}, false
+	}
+	switch v.Get("type").String() {
+	case "auto":
+		return ToolChoice{Mode: ToolChoiceAuto}, true
+	case "none":
+		return ToolChoice{Mode: ToolChoiceNone}, true
+	case "any":
+		return ToolChoice{Mode: ToolChoiceRequired}, true
+	case "tool":
+		if name := v.Get("name").String(); name != "" {
+			return ToolChoice{Mode: ToolChoiceRequired, Name: name}, true
+		}
+	}
+	return ToolChoice{}, false
+}
+
+// ParseGeminiToolConfig reads a Gemini toolConfig / tool_config object. ANY restricted to a
+// single allowed function is treated as forcing that function.
+func ParseGeminiToolConfig(v gjson.Result) (ToolChoice, bool) {
+	if !v.IsObject() {
+		return ToolChoice{}, false
+	}
+	cfg := v.Get("functionCallingConfig")
+	if !cfg.Exists() {
+		cfg = v.Get("function_calling_config")
+	}
+	if !cfg.IsObject() {
+		return ToolChoice{}, false
+	}
+	allowed := cfg.Get("allowedFunctionNames")
+	if !allowed.Exists() {
+		allowed = cfg.Get("allowed_function_names")
+	}
+	switch strings.ToUpper(cfg.Get("mode").String()) {
+	case "AUTO", "MODE_UNSPECIFIED":
+		return ToolChoice{Mode: ToolChoiceAuto}, true
+	case "NONE":
+		return ToolChoice{Mode: ToolChoiceNone}, true
+	case "ANY", "VALIDATED":
+		if names := allowed.Array(); len(names) == 1 && names[0].String() != "" {
+			return ToolChoice{Mode: ToolChoiceRequired, Name: names[0].String()}, true
+		}
+		return ToolChoice{Mode: ToolChoiceRequired}, true
+	}
+	return ToolChoice{}, false
+}
+
+// OpenAIChat renders the choice as a Chat Completions tool_choice raw JSON value.
+func (tc ToolChoice) OpenAIChat() string {
+	if tc.Name != "" {
+		out, _ := sjson.Set(`{"type":"function","function":{"name":""}}`, "function.name", tc.Name)
+		return out
+	}
+	return `"` + tc.openAIMode() + `"`
+}
+
+// OpenAIResponses renders the choice as a Responses API tool_choice raw JSON value.
+func (tc ToolChoice) OpenAIResponses() string {
+	if tc.Name != "" {
+		out, _ := sjson.Set(`{"type":"function","name":""}`, "name", tc.Name)
+		return out
+	}
+	return `"` + tc.openAIMode() + `"`
+}
+
+func (tc ToolChoice) openAIMode() string {
+	switch tc.Mode {
+	case ToolChoiceNone:
+		return "none"
+	case ToolChoiceRequired:
+		return "required"
+	default:
+		return "auto"
+	}
+}
+
+// Claude renders the choice as an Anthropic tool_choice raw JSON object.
+func (tc ToolChoice) Claude() string {
+	if tc.Name != "" {
+		out, _ := sjson.Set(`{"type":"tool","name":""}`, "name", tc.Name)
+		return out
+	}
+	switch tc.Mode {
+	case ToolChoiceNone:
+		return `{"type":"none"}`
+	case ToolChoiceRequired:
+		return `{"type":"any"}`
+	default:
+		return `{"type":"auto"}`
+	}
+}
+
+// GeminiToolConfig renders the choice as a Gemini toolConfig raw JSON object.
+func (tc ToolChoice) GeminiToolConfig() string {
+	mode := "AUTO"
+	switch tc.Mode {
+	case ToolChoiceNone:
+		mode = "NONE"
+	case ToolChoiceRequired:
+		mode = "ANY"
+	}
+	out, _ := sjson.Set(`{"functionCallingConfig":{"mode":""}}`, "functionCallingConfig.mode", mode)
+	if tc.Name != "" {
+		out, _ = sjson.Set(out, "functionCallingConfig.allowedFunctionNames", []string{tc.Name})
+	}
+	return out
+}
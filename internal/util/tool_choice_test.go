@@ -0,0 +1,107 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestToolChoiceRoundTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		parse  func(gjson.Result) (ToolChoice, bool)
+		input  string
+		want   ToolChoice
+		claude string
+		openai string
+		gemini string
+	}{
+		{
+			name:   "openai none",
+			parse:  ParseOpenAIToolChoice,
+			input:  `"none"`,
+			want:   ToolChoice{Mode: ToolChoiceNone},
+			claude: `{"type":"none"}`,
+			openai: `"none"`,
+			gemini: `{"functionCallingConfig":{"mode":"NONE"}}`,
+		},
+		{
+			name:   "openai named function",
+			parse:  ParseOpenAIToolChoice,
+			input:  `{"type":"function","function":{"name":"get_weather"}}`,
+			want:   ToolChoice{Mode: ToolChoiceRequired, Name: "get_weather"},
+			claude: `{"type":"tool","name":"get_weather"}`,
+			openai: `{"type":"function","function":{"name":"get_weather"}}`,
+			gemini: `{"functionCallingConfig":{"mode":"ANY","allowedFunctionNames":["get_weather"]}}`,
+		},
+		{
+			name:   "responses named function",
+			parse:  ParseOpenAIToolChoice,
+			input:  `{"type":"function","name":"lookup"}`,
+			want:   ToolChoice{Mode: ToolChoiceRequired, Name: "lookup"},
+			claude: `{"type":"tool","name":"lookup"}`,
+			openai: `{"type":"function","function":{"name":"lookup"}}`,
+			gemini: `{"functionCallingConfig":{"mode":"ANY","allowedFunctionNames":["lookup"]}}`,
+		},
+		{
+			name:   "claude any",
+			parse:  ParseClaudeToolChoice,
+			input:  `{"type":"any"}`,
+			want:   ToolChoice{Mode: ToolChoiceRequired},
+			claude: `{"type":"any"}`,
+			openai: `"required"`,
+			gemini: `{"functionCallingConfig":{"mode":"ANY"}}`,
+		},
+		{
+			name:   "gemini snake case single allowed name",
+			parse:  ParseGeminiToolConfig,
+			input:  `{"function_calling_config":{"mode":"ANY","allowed_function_names":["search"]}}`,
+			want:   ToolChoice{Mode: ToolChoiceRequired, Name: "search"},
+			claude: `{"type":"tool","name":"search"}`,
+			openai: `{"type":"function","function":{"name":"search"}}`,
+			gemini: `{"functionCallingConfig":{"mode":"ANY","allowedFunctionNames":["search"]}}`,
+		},
+		{
+			name:   "gemini auto",
+			parse:  ParseGeminiToolConfig,
+			input:  `{"functionCallingConfig":{"mode":"AUTO"}}`,
+			want:   ToolChoice{Mode: ToolChoiceAuto},
+			claude: `{"type":"auto"}`,
+			openai: `"auto"`,
+			gemini: `{"functionCallingConfig":{"mode":"AUTO"}}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.parse(gjson.Parse(tc.input))
+			if !ok {
+				t.Fatalf("parse(%s) failed", tc.input)
+			}
+			if got != tc.want {
+				t.Fatalf("parse(%s) = %+v, want %+v", tc.input, got, tc.want)
+			}
+			if out := got.Claude(); out != tc.claude {
+				t.Errorf("Claude() = %s, want %s", out, tc.claude)
+			}
+			if out := got.OpenAIChat(); out != tc.openai {
+				t.Errorf("OpenAIChat() = %s, want %s", out, tc.openai)
+			}
+			if out := got.GeminiToolConfig(); out != tc.gemini {
+				t.Errorf("GeminiToolConfig() = %s, want %s", out, tc.gemini)
+			}
+		})
+	}
+}
+
+func TestParseToolChoiceRejectsUnknown(t *testing.T) {
+	if _, ok := ParseOpenAIToolChoice(gjson.Parse(`"sometimes"`)); ok {
+		t.Error("unknown OpenAI tool_choice string should not parse")
+	}
+	if _, ok := ParseClaudeToolChoice(gjson.Parse(`{"type":"tool"}`)); ok {
+		t.Error("Claude tool choice without a name should not parse")
+	}
+	if _, ok := ParseGeminiToolConfig(gjson.Parse(`{}`)); ok {
+		t.Error("empty Gemini toolConfig should not parse")
+	}
+}
@@ -0,0 +1,32 @@
+package util
+
+import "strings"
+
+// MatchWildcard matches value against pattern where "*" matches any sequence of characters,
+// including "/". Matching is case-sensitive.
+func MatchWildcard(pattern, value string) bool {
+	if pattern == "*" {
+		return true
+	}
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(value, part)
+		if idx < 0 {
+			return false
+		}
+		value = value[idx+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
+
+// MatchModelWildcard performs case-insensitive matching where '*' matches any substring.
+func MatchModelWildcard(pattern, model string) bool {
+	return MatchWildcard(strings.ToLower(pattern), strings.ToLower(model))
+}
@@ -0,0 +1,27 @@
+package util
+
+import "testing"
+
+func TestMatchWildcard(t *testing.T) {
+	cases := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"*", "anything", true},
+		{"gpt-5", "gpt-5", true},
+		{"gpt-5", "gpt-5-mini", false},
+		{"claude-*", "claude-sonnet-4", true},
+		{"*-mini", "gpt-5-mini", true},
+		{"/v1/*/completions", "/v1/chat/completions", true},
+		{"gemini-*-pro*", "gemini-2.5-flash", false},
+		{"Claude-*", "claude-sonnet-4", false},
+	}
+	for _, tc := range cases {
+		if got := MatchWildcard(tc.pattern, tc.value); got != tc.want {
+			t.Errorf("MatchWildcard(%q, %q) = %v, want %v", tc.pattern, tc.value, got, tc.want)
+		}
+	}
+	if !MatchModelWildcard("Claude-*", "claude-sonnet-4") {
+		t.Error("MatchModelWildcard must ignore case")
+	}
+}
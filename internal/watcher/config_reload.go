@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"os"
+	"path/filepath"
 	"reflect"
 	"time"
 
@@ -50,12 +51,11 @@ func (w *Watcher) reloadConfigIfChanged() {
 		log.Debugf("ignoring empty config file write event")
 		return
 	}
-	sum := sha256.Sum256(data)
-	newHash := hex.EncodeToString(sum[:])
-
 	w.clientsMutex.RLock()
 	currentHash := w.lastConfigHash
+	secretFiles := w.config.SecretFiles()
 	w.clientsMutex.RUnlock()
+	newHash := configContentHash(data, secretFiles)
 
 	if currentHash != "" && currentHash == newHash {
 		log.Debugf("config file content unchanged (hash match), skipping reload")
@@ -65,8 +65,10 @@ func (w *Watcher) reloadConfigIfChanged() {
 	if w.reloadConfig() {
 		finalHash := newHash
 		if updatedData, errRead := os.ReadFile(w.configPath); errRead == nil && len(updatedData) > 0 {
-			sumUpdated := sha256.Sum256(updatedData)
-			finalHash = hex.EncodeToString(sumUpdated[:])
+			w.clientsMutex.RLock()
+			secretFiles = w.config.SecretFiles()
+			w.clientsMutex.RUnlock()
+			finalHash = configContentHash(updatedData, secretFiles)
 		} else if errRead != nil {
 			log.WithError(errRead).Debug("failed to compute updated config hash after reload")
 		}
@@ -103,6 +105,7 @@ func (w *Watcher) reloadConfig() bool {
 	w.oldConfigYaml, _ = yaml.Marshal(newConfig)
 	w.config = newConfig
 	w.clientsMutex.Unlock()
+	w.watchSecretDirs(newConfig)
 
 	var affectedOAuthProviders []string
 	if oldConfig != nil {
@@ -133,3 +136,67 @@ func (w *Watcher) reloadConfig() bool {
 	w.reloadClients(authDirChanged, affectedOAuthProviders, forceAuthRefresh)
 	return true
 }
+
+// configContentHash hashes the config file together with the secret files it references,
+// so rotating a mounted secret is detected even when the config file itself is unchanged.
+func configContentHash(data []byte, secretFiles []string) string {
+	h := sha256.New()
+	h.Write(data)
+	for _, path := range secretFiles {
+		h.Write([]byte{0})
+		h.Write([]byte(path))
+		h.Write([]byte{0})
+		if content, errRead := os.ReadFile(path); errRead == nil {
+			h.Write(content)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// watchSecretDirs watches the directories holding referenced secret files. Directories are
+// watched instead of files because Kubernetes rotates mounted secrets by swapping a symlink.
+func (w *Watcher) watchSecretDirs(cfg *config.Config) {
+	wanted := make(map[string]struct{})
+	for _, path := range cfg.SecretFiles() {
+		wanted[w.normalizeAuthPath(filepath.Dir(path))] = struct{}{}
+	}
+	normalizedAuthDir := w.normalizeAuthPath(w.authDir)
+
+	w.clientsMutex.Lock()
+	previous := w.secretDirs
+	w.secretDirs = wanted
+	w.clientsMutex.Unlock()
+
+	if w.watcher == nil {
+		return
+	}
+	for dir := range previous {
+		if _, keep := wanted[dir]; keep || dir == normalizedAuthDir {
+			continue
+		}
+		if errRemove := w.watcher.Remove(dir); errRemove != nil {
+			log.Debugf("failed to stop watching secret directory %s: %v", dir, errRemove)
+		}
+	}
+	for dir := range wanted {
+		if _, already := previous[dir]; already || dir == normalizedAuthDir {
+			continue
+		}
+		if errAdd := w.watcher.Add(dir); errAdd != nil {
+			log.Errorf("failed to watch secret directory %s: %v", dir, errAdd)
+			continue
+		}
+		log.Debugf("watching secret directory: %s", dir)
+	}
+}
+
+// isSecretDirEvent reports whether the path lives in a watched secret directory.
+func (w *Watcher) isSecretDirEvent(normalizedName string) bool {
+	w.clientsMutex.RLock()
+	defer w.clientsMutex.RUnlock()
+	if len(w.secretDirs) == 0 {
+		return false
+	}
+	_, ok := w.secretDirs[filepath.Dir(normalizedName)]
+	return ok
+}
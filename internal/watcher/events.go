@@ -39,6 +39,11 @@ func (w *Watcher) start(ctx context.Context) error {
 	}
 	log.Debugf("watching auth directory: %s", w.authDir)
 
+	w.clientsMutex.RLock()
+	cfg := w.config
+	w.clientsMutex.RUnlock()
+	w.watchSecretDirs(cfg)
+
 	go w.processEvents(ctx)
 
 	w.reloadClients(true, nil, false)
@@ -74,6 +79,12 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	authOps := fsnotify.Create | fsnotify.Write | fsnotify.Remove | fsnotify.Rename
 	isAuthJSON := strings.HasPrefix(normalizedName, normalizedAuthDir) && strings.HasSuffix(normalizedName, ".json") && event.Op&authOps != 0
 	if !isConfigEvent && !isAuthJSON {
+		// Secret rotation (including Kubernetes ..data symlink swaps) reloads the config.
+		if event.Op&(configOps|fsnotify.Remove) != 0 && w.isSecretDirEvent(normalizedName) {
+			log.Debugf("secret file change detected: %s %s", event.Op.String(), event.Name)
+			w.scheduleConfigReload()
+			return
+		}
 		// Ignore unrelated files (e.g., cookie snapshots *.cookie) and other noise.
 		return
 	}
@@ -101,6 +101,11 @@ func (s *FileSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth, e
 			CreatedAt: now,
 			UpdatedAt: now,
 		}
+		if rawTenant, ok := metadata["tenant"].(string); ok {
+			if tenant := strings.TrimSpace(rawTenant); tenant != "" {
+				a.Attributes["tenant"] = tenant
+			}
+		}
 		ApplyAuthExcludedModelsMeta(a, cfg, nil, "oauth")
 		if provider == "gemini-cli" {
 			if virtuals := SynthesizeGeminiVirtualAuths(a, metadata, now); len(virtuals) > 0 {
@@ -160,6 +165,9 @@ func SynthesizeGeminiVirtualAuths(primary *coreauth.Auth, metadata map[string]an
 		if authPath != "" {
 			attrs["path"] = authPath
 		}
+		if tenant := primary.Attributes["tenant"]; tenant != "" {
+			attrs["tenant"] = tenant
+		}
 		metadataCopy := map[string]any{
 			"email":             email,
 			"project_id":        projectID,
@@ -51,6 +51,7 @@ type Watcher struct {
 	storePersister    storePersister
 	mirroredAuthDir   string
 	oldConfigYaml     []byte
+	secretDirs        map[string]struct{}
 }
 
 // AuthUpdateAction represents the type of change detected in auth sources.
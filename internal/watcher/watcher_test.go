@@ -800,6 +800,56 @@ func TestHandleEventIgnoresUnrelatedFiles(t *testing.T) {
 	}
 }
 
+func TestSecretRotationTriggersConfigReload(t *testing.T) {
+	tmpDir := t.TempDir()
+	authDir := filepath.Join(tmpDir, "auth")
+	secretDir := filepath.Join(tmpDir, "secrets")
+	for _, dir := range []string{authDir, secretDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+	secretPath := filepath.Join(secretDir, "api-key")
+	if err := os.WriteFile(secretPath, []byte("key-one"), 0o600); err != nil {
+		t.Fatalf("failed to write secret: %v", err)
+	}
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := "auth-dir: " + authDir + "\napi-keys:\n  - ${file:" + secretPath + "}\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	var reloads int32
+	w := &Watcher{
+		authDir:        authDir,
+		configPath:     configPath,
+		lastAuthHashes: make(map[string]string),
+		reloadCallback: func(*config.Config) { atomic.AddInt32(&reloads, 1) },
+	}
+	w.reloadConfigIfChanged()
+	w.reloadConfigIfChanged()
+	if got := atomic.LoadInt32(&reloads); got != 1 {
+		t.Fatalf("expected unchanged secret to be skipped, got %d reloads", got)
+	}
+	if !w.isSecretDirEvent(w.normalizeAuthPath(filepath.Join(secretDir, "..data"))) {
+		t.Fatalf("expected secret directory to be tracked")
+	}
+
+	if err := os.WriteFile(secretPath, []byte("key-two"), 0o600); err != nil {
+		t.Fatalf("failed to rotate secret: %v", err)
+	}
+	w.handleEvent(fsnotify.Event{Name: filepath.Join(secretDir, "..data"), Op: fsnotify.Create})
+	time.Sleep(400 * time.Millisecond)
+	if got := atomic.LoadInt32(&reloads); got != 2 {
+		t.Fatalf("expected secret rotation to trigger reload, got %d reloads", got)
+	}
+	w.clientsMutex.RLock()
+	defer w.clientsMutex.RUnlock()
+	if len(w.config.APIKeys) != 1 || w.config.APIKeys[0] != "key-two" {
+		t.Fatalf("expected rotated key to be loaded, got %v", w.config.APIKeys)
+	}
+}
+
 func TestHandleEventConfigChangeSchedulesReload(t *testing.T) {
 	tmpDir := t.TempDir()
 	authDir := filepath.Join(tmpDir, "auth")
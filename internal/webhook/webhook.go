@@ -0,0 +1,279 @@
+// Package webhook delivers proxy events to operator-configured endpoints.
+//
+// Each delivery is a POST whose body is the JSON event (or a Slack-style text message). When a
+// secret is configured the request carries X-CLIProxy-Signature: sha256=<hex>, the HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the secret, where timestamp is the X-CLIProxy-Timestamp
+// header. Receivers should reject stale timestamps to prevent replays.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/events"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	deliveryQueueSize  = 1024
+	deliveryTimeout    = 10 * time.Second
+	defaultMaxRetries  = 3
+	initialRetryDelay  = time.Second
+	signatureHeader    = "X-CLIProxy-Signature"
+	timestampHeader    = "X-CLIProxy-Timestamp"
+	eventHeader        = "X-CLIProxy-Event"
+	deliveryHeader     = "X-CLIProxy-Delivery"
+	slackFormat        = "slack"
+	wildcardSubscriber = "*"
+)
+
+// Dispatcher fans events out to the configured webhooks. It subscribes to the event bus.
+type Dispatcher struct {
+	mu        sync.RWMutex
+	signature string
+	hooks     []*hook
+	client    *http.Client
+}
+
+type hook struct {
+	cfg     config.WebhookConfig
+	events  map[string]struct{}
+	queue   chan events.Event
+	done    chan struct{}
+	closed  chan struct{}
+	dropped atomic.Int64
+	client  *http.Client
+}
+
+var defaultDispatcher = NewDispatcher(nil)
+
+func init() {
+	events.Subscribe(defaultDispatcher)
+}
+
+// NewDispatcher returns a dispatcher without webhooks. A nil client uses a client with the
+// default delivery timeout.
+func NewDispatcher(client *http.Client) *Dispatcher {
+	if client == nil {
+		client = &http.Client{Timeout: deliveryTimeout}
+	}
+	return &Dispatcher{client: client}
+}
+
+// Configure replaces the process-wide webhooks.
+func Configure(hooks []config.WebhookConfig) { defaultDispatcher.Configure(hooks) }
+
+// Close delivers queued events and stops the process-wide webhooks.
+func Close() { defaultDispatcher.Close() }
+
+// Configure replaces the webhooks. Unchanged configuration keeps the running delivery queues.
+func (d *Dispatcher) Configure(hooks []config.WebhookConfig) {
+	raw, _ := json.Marshal(hooks)
+	d.mu.Lock()
+	if string(raw) == d.signature {
+		d.mu.Unlock()
+		return
+	}
+	old := d.hooks
+	d.signature = string(raw)
+	d.hooks = nil
+	for _, cfg := range hooks {
+		cfg.URL = strings.TrimSpace(cfg.URL)
+		if cfg.URL == "" {
+			continue
+		}
+		h := &hook{
+			cfg:    cfg,
+			events: make(map[string]struct{}, len(cfg.Events)),
+			queue:  make(chan events.Event, deliveryQueueSize),
+			done:   make(chan struct{}),
+			closed: make(chan struct{}),
+			client: d.client,
+		}
+		for _, e := range cfg.Events {
+			if e = strings.TrimSpace(e); e != "" {
+				h.events[e] = struct{}{}
+			}
+		}
+		go h.run()
+		d.hooks = append(d.hooks, h)
+	}
+	d.mu.Unlock()
+	for _, h := range old {
+		h.close()
+	}
+}
+
+// Wants implements events.Subscriber: it reports whether any webhook receives events of eventType.
+func (d *Dispatcher) Wants(eventType string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, h := range d.hooks {
+		if h.wants(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle implements events.Subscriber: it queues the event for every webhook subscribed to its
+// type. It never blocks: events are dropped and logged when a webhook's queue is full.
+func (d *Dispatcher) Handle(event events.Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, h := range d.hooks {
+		if !h.wants(event.Type) {
+			continue
+		}
+		select {
+		case h.queue <- event:
+		default:
+			if dropped := h.dropped.Add(1); dropped == 1 || dropped%1000 == 0 {
+				log.Warnf("webhook %s queue full, %d events dropped", h.name(), dropped)
+			}
+		}
+	}
+}
+
+// Close stops every webhook after delivering queued events.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	old := d.hooks
+	d.hooks, d.signature = nil, ""
+	d.mu.Unlock()
+	for _, h := range old {
+		h.close()
+	}
+}
+
+func (h *hook) wants(eventType string) bool {
+	if len(h.events) == 0 {
+		return true
+	}
+	_, all := h.events[wildcardSubscriber]
+	_, ok := h.events[eventType]
+	return all || ok
+}
+
+func (h *hook) name() string {
+	if h.cfg.Name != "" {
+		return h.cfg.Name
+	}
+	return h.cfg.URL
+}
+
+func (h *hook) run() {
+	defer close(h.done)
+	for {
+		select {
+		case event := <-h.queue:
+			h.deliver(event)
+		case <-h.closed:
+			for {
+				select {
+				case event := <-h.queue:
+					h.deliver(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (h *hook) close() {
+	close(h.closed)
+	select {
+	case <-h.done:
+	case <-time.After(deliveryTimeout):
+	}
+}
+
+// deliver posts the event, retrying with exponential backoff on network errors, 429 and 5xx.
+func (h *hook) deliver(event events.Event) {
+	body, err := h.payload(event)
+	if err != nil {
+		log.Warnf("webhook %s: encode %s event: %v", h.name(), event.Type, err)
+		return
+	}
+	retries := h.cfg.MaxRetries
+	if retries == 0 {
+		retries = defaultMaxRetries
+	}
+	delay := initialRetryDelay
+	for attempt := 0; ; attempt++ {
+		retryable, errSend := h.send(event, body)
+		if errSend == nil {
+			return
+		}
+		if !retryable || attempt >= retries {
+			log.Warnf("webhook %s: deliver %s event %s: %v", h.name(), event.Type, event.ID, errSend)
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-h.closed:
+			log.Warnf("webhook %s: deliver %s event %s: %v", h.name(), event.Type, event.ID, errSend)
+			return
+		}
+		delay *= 2
+	}
+}
+
+func (h *hook) payload(event events.Event) ([]byte, error) {
+	if strings.EqualFold(strings.TrimSpace(h.cfg.Format), slackFormat) {
+		return json.Marshal(map[string]string{"text": "[" + event.Type + "] " + event.Summary})
+	}
+	return json.Marshal(event)
+}
+
+func (h *hook) send(event events.Event, body []byte) (retryable bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range h.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(eventHeader, event.Type)
+	req.Header.Set(deliveryHeader, event.ID)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(timestampHeader, timestamp)
+	if h.cfg.Secret != "" {
+		req.Header.Set(signatureHeader, Sign(h.cfg.Secret, timestamp, body))
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		return retry, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// Sign returns the X-CLIProxy-Signature value for body sent with the given timestamp header.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/events"
+)
+
+type received struct {
+	headers http.Header
+	body    []byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, func() []received) {
+	t.Helper()
+	var mu sync.Mutex
+	var got []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		n := len(got)
+		got = append(got, received{headers: r.Header.Clone(), body: body})
+		mu.Unlock()
+		if n < len(statuses) {
+			w.WriteHeader(statuses[n])
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]received(nil), got...)
+	}
+}
+
+func TestEmitDeliversSignedEventsToSubscribers(t *testing.T) {
+	srv, got := newReceiver(t)
+	d := NewDispatcher(nil)
+	d.Configure([]config.WebhookConfig{
+		{URL: srv.URL, Secret: "s3cret", Events: []string{events.TypeUpstreamFailover}},
+	})
+	if !d.Wants(events.TypeUpstreamFailover) || d.Wants(events.TypeRequestCompleted) {
+		t.Fatal("subscription filter not applied")
+	}
+	d.Handle(events.Event{Type: events.TypeRequestCompleted, Summary: "ignored"})
+	d.Handle(events.Event{Type: events.TypeUpstreamFailover, Summary: "failed over", Data: map[string]string{"from": "a"}})
+	d.Close()
+
+	deliveries := got()
+	if len(deliveries) != 1 {
+		t.Fatalf("deliveries = %d; want 1", len(deliveries))
+	}
+	r := deliveries[0]
+	if r.headers.Get(eventHeader) != events.TypeUpstreamFailover {
+		t.Fatalf("event header = %q", r.headers.Get(eventHeader))
+	}
+	if want := Sign("s3cret", r.headers.Get(timestampHeader), r.body); r.headers.Get(signatureHeader) != want {
+		t.Fatalf("signature = %q; want %q", r.headers.Get(signatureHeader), want)
+	}
+	var event events.Event
+	if err := json.Unmarshal(r.body, &event); err != nil || event.Type != events.TypeUpstreamFailover || event.Summary != "failed over" {
+		t.Fatalf("event = %+v, %v", event, err)
+	}
+}
+
+func TestDeliveryRetriesServerErrorsAndFormatsSlack(t *testing.T) {
+	srv, got := newReceiver(t, http.StatusBadGateway, http.StatusOK)
+	d := NewDispatcher(nil)
+	d.Configure([]config.WebhookConfig{{URL: srv.URL, Format: "slack"}})
+	d.Handle(events.Event{Type: events.TypeStreamAborted, Summary: "stream aborted"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(got()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	d.Close()
+	deliveries := got()
+	if len(deliveries) != 2 {
+		t.Fatalf("deliveries = %d; want a retry after 502", len(deliveries))
+	}
+	if string(deliveries[1].body) != `{"text":"[stream.aborted] stream aborted"}` {
+		t.Fatalf("slack body = %s", deliveries[1].body)
+	}
+}
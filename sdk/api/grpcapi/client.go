@@ -0,0 +1,43 @@
+package grpcapi
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// Client calls the Proxy service. Pass credentials as outgoing metadata, for example with
+// metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+key).
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewClient returns a client over cc.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+// Complete runs a non-streaming request.
+func (c *Client) Complete(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	if err := c.cc.Invoke(ctx, completeMethod, in, out, append([]grpc.CallOption{grpc.ForceCodec(codec{})}, opts...)...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Stream runs a streaming request. Recv returns io.EOF once the stream is complete.
+func (c *Client) Stream(ctx context.Context, in *Request, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Chunk], error) {
+	stream, err := c.cc.NewStream(ctx, &serviceDesc.Streams[0], streamMethod, append([]grpc.CallOption{grpc.ForceCodec(codec{})}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Request, Chunk]{ClientStream: stream}
+	if err = x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err = x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
@@ -0,0 +1,243 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/tidwall/sjson"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// LocalClient runs requests through an HTTP handler in-process, with the semantics of the Proxy
+// service but without a network hop. Errors are gRPC statuses, as a remote client would see.
+type LocalClient struct {
+	handler http.Handler
+	apiKey  string
+}
+
+// NewLocalClient returns a client of handler that authenticates with apiKey, when non-empty.
+func NewLocalClient(handler http.Handler, apiKey string) *LocalClient {
+	return &LocalClient{handler: handler, apiKey: apiKey}
+}
+
+// Complete runs a non-streaming request.
+func (c *LocalClient) Complete(ctx context.Context, in *Request) (*Response, error) {
+	return c.complete(ctx, in, nil)
+}
+
+// Stream runs a streaming request, calling fn for every streamed event. It returns fn's first
+// error, or the request's error.
+func (c *LocalClient) Stream(ctx context.Context, in *Request, fn func(*Chunk) error) error {
+	return c.stream(ctx, in, nil, fn)
+}
+
+func (c *LocalClient) header(header http.Header) http.Header {
+	if header == nil {
+		header = make(http.Header)
+	}
+	if c.apiKey != "" && header.Get("Authorization") == "" && header.Get("X-Api-Key") == "" {
+		header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	return header
+}
+
+func (c *LocalClient) complete(ctx context.Context, in *Request, header http.Header) (*Response, error) {
+	req, err := httpRequest(ctx, in, false, c.header(header))
+	if err != nil {
+		return nil, err
+	}
+	w := newResponseWriter(nil)
+	c.handler.ServeHTTP(w, req)
+	if w.status >= http.StatusBadRequest {
+		return nil, httpStatusError(w.status, w.body.Bytes())
+	}
+	return &Response{Status: int32(w.status), Body: w.body.Bytes(), ContentType: w.header.Get("Content-Type")}, nil
+}
+
+func (c *LocalClient) stream(ctx context.Context, in *Request, header http.Header, send func(*Chunk) error) error {
+	req, err := httpRequest(ctx, in, true, c.header(header))
+	if err != nil {
+		return err
+	}
+	w := newResponseWriter(send)
+	c.handler.ServeHTTP(w, req)
+	if w.status >= http.StatusBadRequest && !w.streaming {
+		return httpStatusError(w.status, w.body.Bytes())
+	}
+	if w.streaming {
+		w.finish()
+	} else if w.body.Len() > 0 {
+		// A handler that answered without streaming, such as a cached response.
+		return send(&Chunk{Data: w.body.Bytes()})
+	}
+	return w.sendErr
+}
+
+// httpRequest builds the HTTP request of the endpoint serving in.Format.
+func httpRequest(ctx context.Context, in *Request, stream bool, header http.Header) (*http.Request, error) {
+	body := in.Body
+	var path string
+	switch in.Format {
+	case constant.OpenAI, "":
+		path = "/v1/chat/completions"
+	case constant.OpenaiResponse:
+		path = "/v1/responses"
+	case constant.Claude:
+		path = "/v1/messages"
+	case constant.Gemini:
+		if strings.TrimSpace(in.Model) == "" {
+			return nil, status.Error(codes.InvalidArgument, "model is required for the gemini format")
+		}
+		path = "/v1beta/models/" + url.PathEscape(in.Model) + ":generateContent"
+		if stream {
+			path = "/v1beta/models/" + url.PathEscape(in.Model) + ":streamGenerateContent?alt=sse"
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported format %q", in.Format)
+	}
+	if in.Format != constant.Gemini {
+		var err error
+		if body, err = sjson.SetBytes(body, "stream", stream); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+		}
+		if in.Model != "" {
+			body, _ = sjson.SetBytes(body, "model", in.Model)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://grpc.local"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	for key, values := range header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "127.0.0.1:0"
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		req.RemoteAddr = p.Addr.String()
+	}
+	return req, nil
+}
+
+// httpStatusError maps an HTTP error response to the gRPC status a caller would expect.
+func httpStatusError(code int, body []byte) error {
+	c := codes.Unknown
+	switch {
+	case code == http.StatusBadRequest, code == http.StatusUnprocessableEntity, code == http.StatusRequestEntityTooLarge:
+		c = codes.InvalidArgument
+	case code == http.StatusUnauthorized:
+		c = codes.Unauthenticated
+	case code == http.StatusForbidden:
+		c = codes.PermissionDenied
+	case code == http.StatusNotFound:
+		c = codes.NotFound
+	case code == http.StatusTooManyRequests, code == http.StatusPaymentRequired:
+		c = codes.ResourceExhausted
+	case code == http.StatusRequestTimeout, code == http.StatusGatewayTimeout:
+		c = codes.DeadlineExceeded
+	case code == http.StatusServiceUnavailable, code == http.StatusBadGateway:
+		c = codes.Unavailable
+	case code == http.StatusNotImplemented:
+		c = codes.Unimplemented
+	case code >= http.StatusInternalServerError:
+		c = codes.Internal
+	}
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(code)
+	}
+	return status.Error(c, fmt.Sprintf("http %d: %s", code, msg))
+}
+
+// responseWriter captures a handler's response. Once the handler starts an event stream, each
+// complete SSE event is passed to send as a Chunk.
+type responseWriter struct {
+	mu        sync.Mutex
+	header    http.Header
+	status    int
+	body      bytes.Buffer
+	send      func(*Chunk) error
+	streaming bool
+	sendErr   error
+}
+
+func newResponseWriter(send func(*Chunk) error) *responseWriter {
+	return &responseWriter{header: make(http.Header), send: send}
+}
+
+func (w *responseWriter) Header() http.Header { return w.header }
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeHeader(code)
+}
+
+func (w *responseWriter) writeHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	w.streaming = w.send != nil && strings.HasPrefix(w.header.Get("Content-Type"), "text/event-stream")
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeHeader(http.StatusOK)
+	w.body.Write(data)
+	if w.streaming {
+		w.sendEvents(false)
+	}
+	if w.sendErr != nil {
+		return 0, w.sendErr
+	}
+	return len(data), nil
+}
+
+// Flush is a no-op: events are sent as soon as they are complete.
+func (w *responseWriter) Flush() {}
+
+func (w *responseWriter) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.sendEvents(true)
+}
+
+// sendEvents sends the complete events buffered in body; with final set, the remainder too.
+func (w *responseWriter) sendEvents(final bool) {
+	for w.sendErr == nil {
+		buf := w.body.Bytes()
+		end := bytes.Index(buf, []byte("\n\n"))
+		if end < 0 {
+			if !final || len(bytes.TrimSpace(buf)) == 0 {
+				return
+			}
+			end = len(buf)
+		}
+		event := buf[:end]
+		w.body.Next(min(end+2, len(buf)))
+		chunk := &Chunk{}
+		for _, line := range bytes.Split(event, []byte("\n")) {
+			line = bytes.TrimRight(line, "\r")
+			if name, ok := sse.Event(line); ok {
+				chunk.Event = string(name)
+			} else if data, ok := sse.Data(line); ok {
+				chunk.Data = append(chunk.Data, data...)
+			}
+		}
+		if len(chunk.Data) == 0 || sse.IsDone(chunk.Data) {
+			continue
+		}
+		w.sendErr = w.send(chunk)
+	}
+}
@@ -0,0 +1,170 @@
+package grpcapi
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Request is the cliproxy.v1.Request message; see proxy.proto.
+type Request struct {
+	Format string
+	Model  string
+	Body   []byte
+}
+
+// Response is the cliproxy.v1.Response message.
+type Response struct {
+	Status      int32
+	Body        []byte
+	ContentType string
+}
+
+// Chunk is the cliproxy.v1.Chunk message.
+type Chunk struct {
+	Event string
+	Data  []byte
+}
+
+// wireMessage is implemented by the messages of this package, which encode themselves in the
+// protobuf wire format described by proxy.proto.
+type wireMessage interface {
+	marshalWire() []byte
+	unmarshalWire(data []byte) error
+}
+
+func (m *Request) marshalWire() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Format)
+	b = appendString(b, 2, m.Model)
+	return appendBytes(b, 3, m.Body)
+}
+
+func (m *Request) unmarshalWire(data []byte) error {
+	*m = Request{}
+	return consumeFields(data, func(num protowire.Number, v []byte, _ uint64) {
+		switch num {
+		case 1:
+			m.Format = string(v)
+		case 2:
+			m.Model = string(v)
+		case 3:
+			m.Body = append([]byte(nil), v...)
+		}
+	})
+}
+
+func (m *Response) marshalWire() []byte {
+	var b []byte
+	if m.Status != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(m.Status))
+	}
+	b = appendBytes(b, 2, m.Body)
+	return appendString(b, 3, m.ContentType)
+}
+
+func (m *Response) unmarshalWire(data []byte) error {
+	*m = Response{}
+	return consumeFields(data, func(num protowire.Number, v []byte, n uint64) {
+		switch num {
+		case 1:
+			m.Status = int32(n)
+		case 2:
+			m.Body = append([]byte(nil), v...)
+		case 3:
+			m.ContentType = string(v)
+		}
+	})
+}
+
+func (m *Chunk) marshalWire() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Event)
+	return appendBytes(b, 2, m.Data)
+}
+
+func (m *Chunk) unmarshalWire(data []byte) error {
+	*m = Chunk{}
+	return consumeFields(data, func(num protowire.Number, v []byte, _ uint64) {
+		switch num {
+		case 1:
+			m.Event = string(v)
+		case 2:
+			m.Data = append([]byte(nil), v...)
+		}
+	})
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// consumeFields walks the fields of a message, passing length-delimited values as v and varints
+// as n. Fields of other types are skipped.
+func consumeFields(data []byte, field func(num protowire.Number, v []byte, n uint64)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, m := protowire.ConsumeBytes(data)
+			if m < 0 {
+				return protowire.ParseError(m)
+			}
+			field(num, v, 0)
+			n = m
+		case protowire.VarintType:
+			v, m := protowire.ConsumeVarint(data)
+			if m < 0 {
+				return protowire.ParseError(m)
+			}
+			field(num, nil, v)
+			n = m
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// codec encodes the messages of this package. It is registered under the "proto" name so that
+// clients generated from proxy.proto interoperate with it.
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("grpcapi: cannot marshal %T", v)
+	}
+	return m.marshalWire(), nil
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return fmt.Errorf("grpcapi: cannot unmarshal into %T", v)
+	}
+	return m.unmarshalWire(data)
+}
+
+func (codec) Name() string { return "proto" }
@@ -0,0 +1,95 @@
+// Package grpcapi serves the proxy's completion endpoints over gRPC. Each call is dispatched to
+// the HTTP handler as an in-process request, so gRPC callers share the routing, authentication,
+// quota and policy layers of the HTTP API without parsing HTTP or SSE themselves.
+package grpcapi
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	serviceName    = "cliproxy.v1.Proxy"
+	completeMethod = "/" + serviceName + "/Complete"
+	streamMethod   = "/" + serviceName + "/Stream"
+)
+
+// forwardedMetadata lists the metadata keys passed on to the HTTP handler as request headers.
+var forwardedMetadata = []string{
+	"authorization", "x-api-key", "x-goog-api-key", "anthropic-version", "anthropic-beta",
+	"idempotency-key", "x-request-id",
+}
+
+// ProxyServer is the server API of the cliproxy.v1.Proxy service.
+type ProxyServer interface {
+	Complete(context.Context, *Request) (*Response, error)
+	Stream(*Request, grpc.ServerStreamingServer[Chunk]) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*ProxyServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Complete",
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			in := new(Request)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return srv.(ProxyServer).Complete(ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: completeMethod}
+			return interceptor(ctx, in, info, func(ctx context.Context, req any) (any, error) {
+				return srv.(ProxyServer).Complete(ctx, req.(*Request))
+			})
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Stream",
+		ServerStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			in := new(Request)
+			if err := stream.RecvMsg(in); err != nil {
+				return err
+			}
+			return srv.(ProxyServer).Stream(in, &grpc.GenericServerStream[Request, Chunk]{ServerStream: stream})
+		},
+	}},
+	Metadata: "proxy.proto",
+}
+
+// NewServer returns a gRPC server whose Proxy service dispatches to handler.
+func NewServer(handler http.Handler, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(append([]grpc.ServerOption{grpc.ForceServerCodec(codec{})}, opts...)...)
+	server.RegisterService(&serviceDesc, &proxyService{local: NewLocalClient(handler, "")})
+	return server
+}
+
+type proxyService struct {
+	local *LocalClient
+}
+
+func (s *proxyService) Complete(ctx context.Context, in *Request) (*Response, error) {
+	return s.local.complete(ctx, in, incomingHeader(ctx))
+}
+
+func (s *proxyService) Stream(in *Request, stream grpc.ServerStreamingServer[Chunk]) error {
+	return s.local.stream(stream.Context(), in, incomingHeader(stream.Context()), stream.Send)
+}
+
+// incomingHeader returns the request headers carried as gRPC metadata.
+func incomingHeader(ctx context.Context) http.Header {
+	header := make(http.Header)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, key := range forwardedMetadata {
+			for _, v := range md.Get(key) {
+				header.Add(key, v)
+			}
+		}
+	}
+	return header
+}
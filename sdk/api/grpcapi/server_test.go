@@ -0,0 +1,152 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/tidwall/gjson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func testClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	server := NewServer(handler)
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewClient(conn)
+}
+
+func TestProxyService(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"missing key"}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !gjson.GetBytes(body, "stream").Bool() {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `","model":"` + gjson.GetBytes(body, "model").String() + `"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\"}\n\n: keep-alive\n\ndata: {\"n\""))
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte(":1}\n\ndata: [DONE]\n\n"))
+	})
+	client := testClient(t, handler)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer key")
+
+	resp, err := client.Complete(ctx, &Request{Format: "claude", Model: "m", Body: []byte(`{"messages":[]}`)})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if resp.Status != http.StatusOK || string(resp.Body) != `{"path":"/v1/messages","model":"m"}` || resp.ContentType != "application/json" {
+		t.Fatalf("Complete = %+v (%s)", resp, resp.Body)
+	}
+
+	stream, err := client.Stream(ctx, &Request{Format: "openai", Body: []byte(`{"model":"m"}`)})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	var chunks []*Chunk
+	for {
+		chunk, errRecv := stream.Recv()
+		if errors.Is(errRecv, io.EOF) {
+			break
+		}
+		if errRecv != nil {
+			t.Fatalf("Recv: %v", errRecv)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 2 || chunks[0].Event != "message_start" || string(chunks[1].Data) != `{"n":1}` {
+		t.Fatalf("chunks = %+v", chunks)
+	}
+
+	_, err = client.Complete(context.Background(), &Request{Format: "openai", Body: []byte(`{}`)})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("unauthenticated Complete error = %v", err)
+	}
+	_, err = client.Complete(ctx, &Request{Format: "gemini", Body: []byte(`{}`)})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("gemini without model error = %v", err)
+	}
+}
+
+func TestMessagesRoundTrip(t *testing.T) {
+	in := &Response{Status: 201, Body: []byte(`{"a":1}`), ContentType: "application/json"}
+	var out Response
+	if err := out.unmarshalWire(in.marshalWire()); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if out.Status != in.Status || string(out.Body) != string(in.Body) || out.ContentType != in.ContentType {
+		t.Fatalf("round trip = %+v", out)
+	}
+}
+
+func TestLocalClient(t *testing.T) {
+	var gotAuth, gotPath string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotPath = r.Header.Get("Authorization"), r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case gjson.GetBytes(body, "model").String() == "denied":
+			http.Error(w, "nope", http.StatusForbidden)
+		case gjson.GetBytes(body, "stream").Bool():
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, "event: delta\ndata: {\"n\":1}\n\ndata: {\"n\"")
+			_, _ = io.WriteString(w, ":2}\n\ndata: [DONE]\n\n")
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		}
+	})
+	client := NewLocalClient(handler, "local-key")
+	ctx := context.Background()
+
+	resp, err := client.Complete(ctx, &Request{Format: "claude", Model: "m", Body: []byte(`{"model":"x"}`)})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if gotPath != "/v1/messages" || gotAuth != "Bearer local-key" || gjson.GetBytes(resp.Body, "model").String() != "m" || resp.Status != http.StatusOK {
+		t.Fatalf("Complete sent path %q auth %q and got %d %s", gotPath, gotAuth, resp.Status, resp.Body)
+	}
+
+	var chunks []string
+	err = client.Stream(ctx, &Request{Format: "openai", Body: []byte(`{}`)}, func(c *Chunk) error {
+		chunks = append(chunks, c.Event+string(c.Data))
+		return nil
+	})
+	if err != nil || len(chunks) != 2 || chunks[0] != `delta{"n":1}` || chunks[1] != `{"n":2}` {
+		t.Fatalf("Stream chunks = %q, err %v", chunks, err)
+	}
+
+	stop := errors.New("stop")
+	if err = client.Stream(ctx, &Request{Body: []byte(`{}`)}, func(*Chunk) error { return stop }); !errors.Is(err, stop) {
+		t.Fatalf("Stream callback error = %v, want stop", err)
+	}
+	if _, err = client.Complete(ctx, &Request{Model: "denied", Body: []byte(`{}`)}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Complete 403 error = %v, want PermissionDenied", err)
+	}
+	if _, err = client.Complete(ctx, &Request{Format: "gemini", Body: []byte(`{}`)}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("gemini without model error = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+)
+
+// systemPromptPaths locate the system prompt of OpenAI Responses, Claude and Gemini requests.
+var systemPromptPaths = []string{"instructions", "system", "systemInstruction", "system_instruction", "request.systemInstruction"}
+
+// withSessionAffinity binds the request to its conversation so follow-up turns reuse the same
+// credential. Requests pinned to a credential by override headers are left alone.
+func (h *BaseAPIHandler) withSessionAffinity(ctx context.Context, rawJSON []byte) context.Context {
+	if h.Cfg == nil || !h.Cfg.SessionAffinity.Enable || coreauth.PinnedAuthFromContext(ctx) != "" {
+		return ctx
+	}
+	ginCtx := ginContextFrom(ctx)
+	fingerprint := sessionFingerprint(ginCtx, rawJSON)
+	if fingerprint == "" {
+		return ctx
+	}
+	if ginCtx != nil {
+		if v, ok := ginCtx.Get("apiKey"); ok {
+			if apiKey, _ := v.(string); apiKey != "" {
+				fingerprint = apiKey + "\x00" + fingerprint
+			}
+		}
+	}
+	sum := sha256.Sum256([]byte(fingerprint))
+	ttl := time.Duration(h.Cfg.SessionAffinity.TTLSeconds) * time.Second
+	return coreauth.WithSessionAffinity(ctx, hex.EncodeToString(sum[:16]), ttl)
+}
+
+// sessionFingerprint identifies the conversation of a request from a client session header,
+// prompt_cache_key or, failing those, the system prompt and first user message, which stay
+// constant across turns.
+func sessionFingerprint(ginCtx *gin.Context, rawJSON []byte) string {
+	if ginCtx != nil && ginCtx.Request != nil {
+		for _, header := range conversationHeaders {
+			if v := strings.TrimSpace(ginCtx.GetHeader(header)); v != "" {
+				return "session:" + v
+			}
+		}
+	}
+	if v := gjson.GetBytes(rawJSON, "prompt_cache_key"); v.Type == gjson.String && strings.TrimSpace(v.Str) != "" {
+		return "cache-key:" + v.Str
+	}
+	first := firstUserTurn(rawJSON)
+	if first == "" {
+		if input := gjson.GetBytes(rawJSON, "input"); input.Type == gjson.String {
+			first = input.Str
+		}
+	}
+	if first == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, path := range systemPromptPaths {
+		if v := gjson.GetBytes(rawJSON, path); v.Exists() {
+			b.WriteString(v.Raw)
+			b.WriteByte(0)
+		}
+	}
+	gjson.GetBytes(rawJSON, "messages").ForEach(func(_, item gjson.Result) bool {
+		switch item.Get("role").String() {
+		case "system", "developer":
+			b.WriteString(item.Get("content").Raw)
+			b.WriteByte(0)
+		}
+		return true
+	})
+	b.WriteString(first)
+	return "prompt:" + b.String()
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import "testing"
+
+func TestSessionFingerprintSeparatesSystemPrompts(t *testing.T) {
+	turn1 := []byte(`{"system":"be brief","messages":[{"role":"user","content":"hello"}]}`)
+	turn2 := []byte(`{"system":"be brief","messages":[{"role":"user","content":"hello"},{"role":"assistant","content":"hi"},{"role":"user","content":"more"}]}`)
+	other := []byte(`{"system":"be verbose","messages":[{"role":"user","content":"hello"}]}`)
+	a, b, c := sessionFingerprint(nil, turn1), sessionFingerprint(nil, turn2), sessionFingerprint(nil, other)
+	if a == "" || a != b {
+		t.Fatalf("expected equal non-empty fingerprints across turns, got %q and %q", a, b)
+	}
+	if a == c {
+		t.Fatal("expected different system prompts to yield different fingerprints")
+	}
+	if got := sessionFingerprint(nil, []byte(`{"model":"m"}`)); got != "" {
+		t.Fatalf("expected no fingerprint without messages, got %q", got)
+	}
+}
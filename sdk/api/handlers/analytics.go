@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/analytics"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+var analyticsState struct {
+	mu        sync.Mutex
+	signature string
+	tee       *analytics.Tee
+}
+
+// analyticsTeeFor returns the conversation tee for the current configuration, rebuilding it
+// when the settings change. The replaced tee drains in the background.
+func analyticsTeeFor(cfg *config.SDKConfig) *analytics.Tee {
+	signature := ""
+	if cfg != nil && cfg.AnalyticsTee.Enable {
+		raw, _ := json.Marshal(cfg.AnalyticsTee)
+		signature = string(raw)
+		if cfg.AnalyticsTee.Redact {
+			rawPII, _ := json.Marshal(cfg.PIIRedaction)
+			signature += string(rawPII)
+		}
+	}
+
+	analyticsState.mu.Lock()
+	defer analyticsState.mu.Unlock()
+	if analyticsState.signature == signature {
+		return analyticsState.tee
+	}
+	if previous := analyticsState.tee; previous != nil {
+		go previous.Close()
+	}
+	analyticsState.signature, analyticsState.tee = signature, nil
+	if signature == "" {
+		return nil
+	}
+	tee, err := analytics.New(cfg.AnalyticsTee, cfg.PIIRedaction)
+	if err != nil {
+		log.Errorf("analytics tee disabled: %v", err)
+		return nil
+	}
+	analyticsState.tee = tee
+	return tee
+}
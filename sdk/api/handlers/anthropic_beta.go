@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// Anthropic beta flags that change what a request may do. Other upstreams must provide the
+// capability natively or the request is rejected.
+const (
+	betaContext1M  = "context-1m-2025-08-07"
+	betaOutput128K = "output-128k-2025-02-19"
+)
+
+// optionalBetas only tune how Claude serves a request, such as prompt caching and token-efficient
+// tool use. Other upstreams serve the request without them, so they are dropped there.
+var optionalBetas = map[string]struct{}{
+	"prompt-caching-2024-07-31":              {},
+	"token-efficient-tools-2025-02-19":       {},
+	"fine-grained-tool-streaming-2025-05-14": {},
+	"interleaved-thinking-2025-05-14":        {},
+	"extended-cache-ttl-2025-04-11":          {},
+	"claude-code-20250219":                   {},
+	"oauth-2025-04-20":                       {},
+}
+
+// requestAnthropicBetas returns the beta flags of the client request, from the anthropic-beta
+// headers (comma separated, possibly repeated) and the "betas" field of a Claude request body.
+func requestAnthropicBetas(ctx context.Context, rawJSON []byte) []string {
+	var values []string
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil && ginCtx.Request != nil {
+		values = append(values, ginCtx.Request.Header.Values("Anthropic-Beta")...)
+	}
+	for _, beta := range gjson.GetBytes(rawJSON, "betas").Array() {
+		values = append(values, beta.String())
+	}
+	seen := make(map[string]struct{})
+	var betas []string
+	for _, value := range values {
+		for _, beta := range strings.Split(value, ",") {
+			beta = strings.ToLower(strings.TrimSpace(beta))
+			if _, dup := seen[beta]; beta != "" && !dup {
+				seen[beta] = struct{}{}
+				betas = append(betas, beta)
+			}
+		}
+	}
+	return betas
+}
+
+// providerHonorsBeta reports whether provider can serve a request that asks for beta. Claude
+// receives every flag unchanged. Elsewhere optional and unknown flags are dropped, and the
+// capability flags are honored only when the model provides them natively.
+func providerHonorsBeta(provider, modelName, beta string) bool {
+	if provider == "claude" {
+		return true
+	}
+	switch beta {
+	case betaContext1M:
+		info := registry.GetGlobalRegistry().GetModelInfo(modelName)
+		return info != nil && max(info.InputTokenLimit, info.ContextLength) >= 1_000_000
+	case betaOutput128K:
+		info := registry.GetGlobalRegistry().GetModelInfo(modelName)
+		return info != nil && max(info.OutputTokenLimit, info.MaxCompletionTokens) >= 128_000
+	}
+	if _, ok := optionalBetas[beta]; !ok {
+		log.Debugf("anthropic-beta %s is not forwarded to provider %s", beta, provider)
+	}
+	return true
+}
+
+// filterBetaProviders restricts providers to those able to honor every beta flag a Claude-format
+// request asked for, and reports which flag cannot be honored when none can. Other request formats
+// carry no beta flags and are left alone.
+func filterBetaProviders(ctx context.Context, handlerType, modelName string, rawJSON []byte, providers []string) ([]string, *interfaces.ErrorMessage) {
+	if handlerType != constant.Claude {
+		return providers, nil
+	}
+	betas := requestAnthropicBetas(ctx, rawJSON)
+	if len(betas) == 0 {
+		return providers, nil
+	}
+	capable := make([]string, 0, len(providers))
+	unsupported := ""
+	for _, provider := range providers {
+		honored := true
+		for _, beta := range betas {
+			if !providerHonorsBeta(provider, modelName, beta) {
+				honored, unsupported = false, beta
+				break
+			}
+		}
+		if honored {
+			capable = append(capable, provider)
+		}
+	}
+	if len(capable) == 0 {
+		return nil, &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("anthropic-beta %s is not supported for model %s: upstream provider %s cannot honor it", unsupported, modelName, strings.Join(providers, ", ")),
+		}
+	}
+	return capable, nil
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func betaContext(header ...string) context.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	for _, h := range header {
+		c.Request.Header.Add("Anthropic-Beta", h)
+	}
+	return context.WithValue(context.Background(), "gin", c)
+}
+
+func TestRequestAnthropicBetas(t *testing.T) {
+	ctx := betaContext("prompt-caching-2024-07-31, Context-1M-2025-08-07", "prompt-caching-2024-07-31")
+	got := requestAnthropicBetas(ctx, []byte(`{"betas":["token-efficient-tools-2025-02-19"]}`))
+	want := []string{"prompt-caching-2024-07-31", "context-1m-2025-08-07", "token-efficient-tools-2025-02-19"}
+	if len(got) != len(want) {
+		t.Fatalf("betas = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("betas = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFilterBetaProviders(t *testing.T) {
+	optional := betaContext("prompt-caching-2024-07-31,token-efficient-tools-2025-02-19")
+	if providers, errMsg := filterBetaProviders(optional, "claude", "unknown-model", nil, []string{"codex", "claude"}); errMsg != nil || len(providers) != 2 {
+		t.Fatalf("optional betas filtered providers: %v, %v", providers, errMsg)
+	}
+
+	required := betaContext("context-1m-2025-08-07")
+	providers, errMsg := filterBetaProviders(required, "claude", "unknown-model", nil, []string{"codex", "claude"})
+	if errMsg != nil || len(providers) != 1 || providers[0] != "claude" {
+		t.Fatalf("providers = %v, %v; want [claude]", providers, errMsg)
+	}
+	if _, errMsg = filterBetaProviders(required, "claude", "unknown-model", nil, []string{"codex"}); errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 when no provider honors the beta, got %v", errMsg)
+	}
+}
+
+func TestFilterBetaProvidersOnlyAppliesToClaudeRequests(t *testing.T) {
+	required := betaContext("context-1m-2025-08-07")
+	for _, handlerType := range []string{"openai", "gemini", "openai-response"} {
+		providers, errMsg := filterBetaProviders(required, handlerType, "unknown-model", nil, []string{"codex"})
+		if errMsg != nil || len(providers) != 1 || providers[0] != "codex" {
+			t.Fatalf("%s: providers = %v, %v; want [codex]", handlerType, providers, errMsg)
+		}
+	}
+}
+
+func TestBetaFilterAppliesToCountTokens(t *testing.T) {
+	executor := &countingExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "beta-count-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "beta-count-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+
+	body := []byte(`{"model":"beta-count-model","messages":[{"role":"user","content":"hi"}]}`)
+	_, errMsg := handler.ExecuteCountWithAuthManager(betaContext("context-1m-2025-08-07"), "claude", "beta-count-model", body, "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("count_tokens with unsupported beta = %v, want 400", errMsg)
+	}
+	if _, errMsg = handler.ExecuteWithAuthManager(betaContext("context-1m-2025-08-07"), "openai", "beta-count-model", body, ""); errMsg != nil {
+		t.Fatalf("openai request with anthropic-beta header rejected: %v", errMsg.Error)
+	}
+	if got := executor.calls.Load(); got != 1 {
+		t.Fatalf("executor calls = %d, want 1", got)
+	}
+}
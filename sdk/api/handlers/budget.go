@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/budget"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/guardrail"
+)
+
+// budgetError is returned when a hard-stop spend budget of the client key or tenant is exhausted.
+type budgetError struct {
+	exceeded *budget.Exceeded
+}
+
+func (e *budgetError) Error() string {
+	payload, _ := json.Marshal(ErrorResponse{Error: ErrorDetail{
+		Message: fmt.Sprintf("Spend budget %s is exhausted: $%.2f of $%.2f used", e.exceeded.Budget, e.exceeded.SpentUSD, e.exceeded.LimitUSD),
+		Type:    "insufficient_quota",
+		Code:    "budget_exceeded",
+	}})
+	return string(payload)
+}
+
+// enforceSpendBudget rejects requests from keys or tenants whose hard-stop budget is used up.
+func enforceSpendBudget(ctx context.Context) *interfaces.ErrorMessage {
+	if guardrail.Skipped(ctx) {
+		return nil
+	}
+	if exceeded := budget.Check(ctx, requestAPIKey(ctx), requestTenant(ctx)); exceeded != nil {
+		return &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: &budgetError{exceeded: exceeded}}
+	}
+	return nil
+}
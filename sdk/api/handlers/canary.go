@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// CanaryHeader names the canary rule that routed a response.
+const CanaryHeader = "X-CLIProxy-Canary"
+
+// conversationHeaders carry client-supplied conversation identifiers, in order of preference.
+var conversationHeaders = []string{"X-CLIProxy-Session", "X-Session-Id", "Session_id", "Conversation_id"}
+
+// conversationFields are request body fields that identify a conversation, in order of preference.
+var conversationFields = []string{"prompt_cache_key", "metadata.user_id", "conversation", "conversation.id", "user"}
+
+// matchCanaryRule returns the first canary rule matching modelName.
+func matchCanaryRule(cfg config.CanaryRoutingConfig, modelName string) *config.CanaryRule {
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if rule.Percent <= 0 || (strings.TrimSpace(rule.TargetModel) == "" && strings.TrimSpace(rule.Provider) == "") {
+			continue
+		}
+		for _, pattern := range rule.Models {
+			if util.MatchWildcard(strings.TrimSpace(pattern), modelName) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// applyCanary routes the request to the canary upstream when its sticky hash falls within the
+// rule's percentage. It returns the model to route and the provider pin.
+func (h *BaseAPIHandler) applyCanary(ctx context.Context, modelName string, rawJSON []byte) (string, requestOverrides) {
+	if h.Cfg == nil || len(h.Cfg.CanaryRouting.Rules) == 0 {
+		return modelName, requestOverrides{}
+	}
+	rule := matchCanaryRule(h.Cfg.CanaryRouting, modelName)
+	if rule == nil {
+		return modelName, requestOverrides{}
+	}
+	key := canaryKey(ctx, rule.StickyBy, rawJSON)
+	if !inCanary(rule.Name, key, rule.Percent) {
+		return modelName, requestOverrides{}
+	}
+	o := requestOverrides{provider: strings.ToLower(strings.TrimSpace(rule.Provider))}
+	target := modelName
+	if m := strings.TrimSpace(rule.TargetModel); m != "" {
+		target = m
+	}
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+		ginCtx.Header(CanaryHeader, rule.Name)
+	}
+	log.Debugf("canary %s: routing %s to %s", rule.Name, modelName, target)
+	trace.Record(ctx, "model.canary", fmt.Sprintf("canary %s routed %s to %s", rule.Name, modelName, target),
+		map[string]any{"rule": rule.Name, "from": modelName, "model": target, "provider": o.provider})
+	return target, o
+}
+
+// inCanary hashes key into one of 10000 buckets and reports whether it falls below percent.
+func inCanary(ruleName, key string, percent float64) bool {
+	if percent >= 100 {
+		return true
+	}
+	hasher := fnv.New64a()
+	_, _ = hasher.Write([]byte(ruleName))
+	_, _ = hasher.Write([]byte{0})
+	_, _ = hasher.Write([]byte(key))
+	return float64(hasher.Sum64()%10000) < percent*100
+}
+
+// canaryKey derives the sticky hashing key of a request.
+func canaryKey(ctx context.Context, stickyBy string, rawJSON []byte) string {
+	ginCtx := ginContextFrom(ctx)
+	apiKey := ""
+	if ginCtx != nil {
+		if v, ok := ginCtx.Get("apiKey"); ok {
+			apiKey, _ = v.(string)
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(stickyBy)) {
+	case "request":
+		return uuid.NewString()
+	case "api-key":
+		return apiKey
+	}
+	if key := conversationKey(ginCtx, rawJSON); key != "" {
+		return apiKey + "\x00" + key
+	}
+	return apiKey
+}
+
+// conversationKey identifies the conversation a request belongs to from session headers, known
+// body fields or, failing those, the first user message, which stays constant across turns.
+func conversationKey(ginCtx *gin.Context, rawJSON []byte) string {
+	if ginCtx != nil && ginCtx.Request != nil {
+		for _, header := range conversationHeaders {
+			if v := strings.TrimSpace(ginCtx.GetHeader(header)); v != "" {
+				return v
+			}
+		}
+	}
+	for _, field := range conversationFields {
+		if v := gjson.GetBytes(rawJSON, field); v.Type == gjson.String && strings.TrimSpace(v.Str) != "" {
+			return v.Str
+		}
+	}
+	if input := gjson.GetBytes(rawJSON, "input"); input.Type == gjson.String && input.Str != "" {
+		return input.Str
+	}
+	return firstUserTurn(rawJSON)
+}
+
+// firstUserTurn returns the raw JSON of the first user message of an OpenAI, Claude, Gemini or
+// Responses request.
+func firstUserTurn(rawJSON []byte) string {
+	for _, path := range []string{"messages", "contents", "input", "request.contents"} {
+		var first string
+		gjson.GetBytes(rawJSON, path).ForEach(func(_, item gjson.Result) bool {
+			if item.Get("role").String() == "user" {
+				first = item.Raw
+				return false
+			}
+			return true
+		})
+		if first != "" {
+			return first
+		}
+	}
+	return ""
+}
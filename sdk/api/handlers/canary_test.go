@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestInCanaryDistribution(t *testing.T) {
+	hits := 0
+	for i := 0; i < 10000; i++ {
+		if inCanary("rule", fmt.Sprintf("conversation-%d", i), 10) {
+			hits++
+		}
+	}
+	if hits < 800 || hits > 1200 {
+		t.Fatalf("expected roughly 10%% canary traffic, got %d/10000", hits)
+	}
+	if inCanary("rule", "x", 0.0001) != inCanary("rule", "x", 0.0001) {
+		t.Fatal("assignment must be deterministic")
+	}
+	if !inCanary("rule", "x", 100) {
+		t.Fatal("100% must always route to the canary")
+	}
+}
+
+func TestConversationKeyStableAcrossTurns(t *testing.T) {
+	turn1 := []byte(`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hello"}]}`)
+	turn2 := []byte(`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hello"},{"role":"assistant","content":"hi"},{"role":"user","content":"more"}]}`)
+	if a, b := conversationKey(nil, turn1), conversationKey(nil, turn2); a == "" || a != b {
+		t.Fatalf("expected equal non-empty keys, got %q and %q", a, b)
+	}
+	if got := conversationKey(nil, []byte(`{"prompt_cache_key":"abc","messages":[{"role":"user","content":"x"}]}`)); got != "abc" {
+		t.Fatalf("expected prompt_cache_key to win, got %q", got)
+	}
+}
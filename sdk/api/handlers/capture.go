@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/analytics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/events"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/transcript"
+)
+
+// captureRun collects the response of one conversation for the analytics tee and the
+// transcript store.
+type captureRun struct {
+	tee         *analytics.Tee
+	transcripts *transcript.Recorder
+	keyID       string
+	record      analytics.Record
+	started     time.Time
+	assembler   *analytics.Assembler
+}
+
+// startCapture decides whether the request is teed or retained as a transcript. It returns nil
+// when neither applies; the methods of a nil run are no-ops.
+func (h *BaseAPIHandler) startCapture(ctx context.Context, handlerType, modelName string, rawJSON []byte, stream bool) *captureRun {
+	if h == nil {
+		return nil
+	}
+	tee := analyticsTeeFor(h.Cfg)
+	if !tee.Sample(modelName) {
+		tee = nil
+	}
+	transcripts := h.transcriptRecorder(ctx)
+	if tee == nil && transcripts == nil {
+		return nil
+	}
+	run := &captureRun{
+		tee:         tee,
+		transcripts: transcripts,
+		record: analytics.Record{
+			ID:        uuid.NewString(),
+			RequestID: logging.GetRequestID(ctx),
+			Format:    handlerType,
+			Model:     modelName,
+			Stream:    stream,
+			Request:   json.RawMessage(cloneBytes(rawJSON)),
+		},
+		started:   time.Now(),
+		assembler: analytics.NewAssembler(),
+	}
+	if key := requestAPIKey(ctx); key != "" {
+		run.keyID = events.KeyID(key)
+	}
+	return run
+}
+
+// observe records a stream chunk as sent to the client.
+func (r *captureRun) observe(chunk []byte) {
+	if r != nil {
+		r.assembler.Add(chunk)
+	}
+}
+
+// finish submits the conversation. body is the buffered response, or nil for streams.
+func (r *captureRun) finish(body []byte, err error) {
+	if r == nil {
+		return
+	}
+	r.record.DurationMs = time.Since(r.started).Milliseconds()
+	if body != nil {
+		r.record.Response = analytics.Assemble(body)
+	} else {
+		r.record.Response = r.assembler.Output()
+	}
+	if err != nil {
+		r.record.Error = err.Error()
+	}
+	r.tee.Submit(r.record)
+	r.transcripts.Record(transcript.Transcript{Record: r.record, KeyID: r.keyID})
+}
@@ -0,0 +1,511 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultBatchConcurrency = 4
+	defaultBatchMaxRequests = 10000
+	defaultBatchRetention   = 24 * time.Hour
+	// batchProcessingWindow matches Anthropic: requests still pending after it expire.
+	batchProcessingWindow = 24 * time.Hour
+
+	batchStatusInProgress = "in_progress"
+	batchStatusCanceling  = "canceling"
+	batchStatusEnded      = "ended"
+
+	batchResultSucceeded = "succeeded"
+	batchResultErrored   = "errored"
+	batchResultCanceled  = "canceled"
+	batchResultExpired   = "expired"
+)
+
+var batchCustomIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+type batchRequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// messageBatch mirrors the Anthropic Message Batch object.
+type messageBatch struct {
+	ID                string             `json:"id"`
+	Type              string             `json:"type"`
+	ProcessingStatus  string             `json:"processing_status"`
+	RequestCounts     batchRequestCounts `json:"request_counts"`
+	EndedAt           *time.Time         `json:"ended_at"`
+	CreatedAt         time.Time          `json:"created_at"`
+	ExpiresAt         time.Time          `json:"expires_at"`
+	ArchivedAt        *time.Time         `json:"archived_at"`
+	CancelInitiatedAt *time.Time         `json:"cancel_initiated_at"`
+	ResultsURL        *string            `json:"results_url"`
+}
+
+type batchItem struct {
+	customID string
+	params   []byte
+}
+
+// batchJob tracks one batch and the results of its requests, indexed by request order.
+type batchJob struct {
+	mu      sync.Mutex
+	batch   messageBatch
+	owner   string
+	ids     []string
+	results [][]byte
+	cancel  context.CancelFunc
+}
+
+func (j *batchJob) snapshot() messageBatch {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.batch
+}
+
+// record stores the result of request i. Results recorded after the batch ended are ignored.
+func (j *batchJob) record(i int, resultType string, result []byte) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.results[i] != nil {
+		return
+	}
+	line, _ := sjson.SetBytes([]byte(`{"custom_id":""}`), "custom_id", j.ids[i])
+	line, _ = sjson.SetRawBytes(line, "result", result)
+	j.results[i] = line
+	j.batch.RequestCounts.Processing--
+	switch resultType {
+	case batchResultSucceeded:
+		j.batch.RequestCounts.Succeeded++
+	case batchResultErrored:
+		j.batch.RequestCounts.Errored++
+	case batchResultCanceled:
+		j.batch.RequestCounts.Canceled++
+	case batchResultExpired:
+		j.batch.RequestCounts.Expired++
+	}
+}
+
+func (j *batchJob) finish() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now().UTC()
+	j.batch.ProcessingStatus = batchStatusEnded
+	j.batch.EndedAt = &now
+	url := "/v1/messages/batches/" + j.batch.ID + "/results"
+	j.batch.ResultsURL = &url
+}
+
+// batchStore keeps batches in memory until their retention period elapses.
+type batchStore struct {
+	mu   sync.Mutex
+	jobs map[string]*batchJob
+}
+
+func newBatchStore() *batchStore {
+	return &batchStore{jobs: make(map[string]*batchJob)}
+}
+
+func (s *batchStore) put(job *batchJob) {
+	s.mu.Lock()
+	s.jobs[job.batch.ID] = job
+	s.mu.Unlock()
+}
+
+// get returns the batch with id owned by owner, purging expired batches first.
+func (s *batchStore) get(id, owner string, retention time.Duration) (*batchJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeLocked(retention)
+	job, ok := s.jobs[id]
+	if !ok || job.owner != owner {
+		return nil, false
+	}
+	return job, true
+}
+
+func (s *batchStore) remove(id string) {
+	s.mu.Lock()
+	delete(s.jobs, id)
+	s.mu.Unlock()
+}
+
+// list returns the batches owned by owner, newest first.
+func (s *batchStore) list(owner string, retention time.Duration) []messageBatch {
+	s.mu.Lock()
+	s.purgeLocked(retention)
+	out := make([]messageBatch, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if job.owner == owner {
+			out = append(out, job.snapshot())
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].CreatedAt.Equal(out[k].CreatedAt) {
+			return out[i].ID > out[k].ID
+		}
+		return out[i].CreatedAt.After(out[k].CreatedAt)
+	})
+	return out
+}
+
+func (s *batchStore) purgeLocked(retention time.Duration) {
+	cutoff := time.Now().Add(-retention)
+	for id, job := range s.jobs {
+		batch := job.snapshot()
+		if batch.EndedAt != nil && batch.EndedAt.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func (h *ClaudeCodeAPIHandler) batchSettings() (concurrency, maxRequests int, retention time.Duration) {
+	concurrency, maxRequests, retention = defaultBatchConcurrency, defaultBatchMaxRequests, defaultBatchRetention
+	if h.Cfg == nil {
+		return
+	}
+	cfg := h.Cfg.MessageBatches
+	if cfg.MaxConcurrency > 0 {
+		concurrency = cfg.MaxConcurrency
+	}
+	if cfg.MaxRequests > 0 {
+		maxRequests = cfg.MaxRequests
+	}
+	if cfg.RetentionHours > 0 {
+		retention = time.Duration(cfg.RetentionHours) * time.Hour
+	}
+	return
+}
+
+func batchOwner(c *gin.Context) string {
+	if v, ok := c.Get("apiKey"); ok {
+		if s, okStr := v.(string); okStr {
+			return s
+		}
+	}
+	return ""
+}
+
+func writeClaudeError(c *gin.Context, status int, errType, message string) {
+	c.JSON(status, claudeErrorResponse{Type: "error", Error: claudeErrorDetail{Type: errType, Message: message}})
+}
+
+// CreateMessageBatch handles POST /v1/messages/batches. The requests are executed in the
+// background through the regular routing and translation pipeline at batch priority.
+func (h *ClaudeCodeAPIHandler) CreateMessageBatch(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		writeClaudeError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	concurrency, maxRequests, _ := h.batchSettings()
+	items, errValidate := parseBatchRequests(rawJSON, maxRequests)
+	if errValidate != nil {
+		writeClaudeError(c, http.StatusBadRequest, "invalid_request_error", errValidate.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	job := &batchJob{
+		batch: messageBatch{
+			ID:               "msgbatch_" + uuid.NewString(),
+			Type:             "message_batch",
+			ProcessingStatus: batchStatusInProgress,
+			RequestCounts:    batchRequestCounts{Processing: len(items)},
+			CreatedAt:        now,
+			ExpiresAt:        now.Add(batchProcessingWindow),
+		},
+		owner:   batchOwner(c),
+		ids:     make([]string, len(items)),
+		results: make([][]byte, len(items)),
+	}
+	for i, item := range items {
+		job.ids[i] = item.customID
+	}
+
+	ctx, cancel := h.DetachedContext(h, c, context.Background())
+	ctx, cancelDeadline := context.WithDeadline(ctx, job.batch.ExpiresAt)
+	ctx = coreauth.WithPriority(ctx, coreauth.PriorityBatch)
+	job.cancel = func() {
+		cancelDeadline()
+		cancel()
+	}
+	h.batches.put(job)
+	go h.runBatch(ctx, job, items, concurrency)
+
+	c.JSON(http.StatusOK, job.snapshot())
+}
+
+func parseBatchRequests(rawJSON []byte, maxRequests int) ([]batchItem, error) {
+	requests := gjson.GetBytes(rawJSON, "requests")
+	if !requests.IsArray() || len(requests.Array()) == 0 {
+		return nil, errors.New("requests: must be a non-empty array")
+	}
+	entries := requests.Array()
+	if len(entries) > maxRequests {
+		return nil, fmt.Errorf("requests: at most %d requests are allowed per batch", maxRequests)
+	}
+	seen := make(map[string]struct{}, len(entries))
+	items := make([]batchItem, 0, len(entries))
+	for i, entry := range entries {
+		customID := entry.Get("custom_id").String()
+		if !batchCustomIDPattern.MatchString(customID) {
+			return nil, fmt.Errorf("requests.%d.custom_id: must be 1-64 characters of letters, digits, '-' or '_'", i)
+		}
+		if _, dup := seen[customID]; dup {
+			return nil, fmt.Errorf("requests.%d.custom_id: duplicate custom_id %q", i, customID)
+		}
+		seen[customID] = struct{}{}
+		params := entry.Get("params")
+		if !params.IsObject() {
+			return nil, fmt.Errorf("requests.%d.params: must be an object", i)
+		}
+		if params.Get("model").String() == "" {
+			return nil, fmt.Errorf("requests.%d.params.model: field required", i)
+		}
+		if params.Get("stream").Bool() {
+			return nil, fmt.Errorf("requests.%d.params.stream: streaming is not supported in batches", i)
+		}
+		items = append(items, batchItem{customID: customID, params: []byte(params.Raw)})
+	}
+	return items, nil
+}
+
+func (h *ClaudeCodeAPIHandler) runBatch(ctx context.Context, job *batchJob, items []batchItem, concurrency int) {
+	defer job.cancel()
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resultType, result := h.executeBatchItem(ctx, items[i])
+			if ctx.Err() != nil && resultType != batchResultSucceeded {
+				resultType, result = batchStopResult(ctx)
+			}
+			job.record(i, resultType, result)
+		}(i)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		resultType, result := batchStopResult(ctx)
+		for i := range items {
+			job.record(i, resultType, result)
+		}
+	}
+	job.finish()
+	batch := job.snapshot()
+	log.Debugf("message batch %s ended: %+v", batch.ID, batch.RequestCounts)
+}
+
+// batchStopResult classifies requests that did not complete because the batch stopped.
+func batchStopResult(ctx context.Context) (string, []byte) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return batchResultExpired, []byte(`{"type":"expired"}`)
+	}
+	return batchResultCanceled, []byte(`{"type":"canceled"}`)
+}
+
+func (h *ClaudeCodeAPIHandler) executeBatchItem(ctx context.Context, item batchItem) (string, []byte) {
+	modelName := gjson.GetBytes(item.params, "model").String()
+	resp, errMsg := h.ExecuteWithAuthManager(ctx, h.HandlerType(), modelName, item.params, "")
+	if errMsg != nil {
+		return batchResultErrored, batchErrorResult(errMsg)
+	}
+	resp = decompressClaudeResponse(resp)
+	if !gjson.ValidBytes(resp) {
+		return batchResultErrored, batchErrorResult(&interfaces.ErrorMessage{
+			StatusCode: http.StatusBadGateway,
+			Error:      errors.New("upstream returned an invalid response"),
+		})
+	}
+	result, _ := sjson.SetRawBytes([]byte(`{"type":"succeeded"}`), "message", resp)
+	return batchResultSucceeded, result
+}
+
+func batchErrorResult(msg *interfaces.ErrorMessage) []byte {
+	message := "unknown error"
+	if msg.Error != nil {
+		message = msg.Error.Error()
+		// Upstream errors are often already Anthropic error bodies; surface their message.
+		if inner := gjson.Get(message, "error.message"); inner.Exists() {
+			message = inner.String()
+		}
+	}
+	result := []byte(`{"type":"errored","error":{"type":"error","error":{"type":"","message":""}}}`)
+	result, _ = sjson.SetBytes(result, "error.error.type", claudeErrorType(msg.StatusCode))
+	result, _ = sjson.SetBytes(result, "error.error.message", message)
+	return result
+}
+
+// claudeErrorType maps an HTTP status to the Anthropic error type.
+func claudeErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	}
+	return "api_error"
+}
+
+// ListMessageBatches handles GET /v1/messages/batches.
+func (h *ClaudeCodeAPIHandler) ListMessageBatches(c *gin.Context) {
+	_, _, retention := h.batchSettings()
+	all := h.batches.list(batchOwner(c), retention)
+	limit := 20
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 1000 {
+			writeClaudeError(c, http.StatusBadRequest, "invalid_request_error", "limit: must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+	start, end := 0, len(all)
+	if afterID := c.Query("after_id"); afterID != "" {
+		start = len(all)
+		for i := range all {
+			if all[i].ID == afterID {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if beforeID := c.Query("before_id"); beforeID != "" {
+		for i := range all {
+			if all[i].ID == beforeID {
+				end = i
+				break
+			}
+		}
+		if end-start > limit {
+			start = end - limit
+		}
+	}
+	if start > end {
+		start = end
+	}
+	hasMore := end-start > limit
+	if hasMore {
+		end = start + limit
+	}
+	page := all[start:end]
+	resp := gin.H{"data": page, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(page) > 0 {
+		resp["first_id"] = page[0].ID
+		resp["last_id"] = page[len(page)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *ClaudeCodeAPIHandler) lookupBatch(c *gin.Context) (*batchJob, bool) {
+	_, _, retention := h.batchSettings()
+	job, ok := h.batches.get(c.Param("id"), batchOwner(c), retention)
+	if !ok {
+		writeClaudeError(c, http.StatusNotFound, "not_found_error", "message batch not found")
+	}
+	return job, ok
+}
+
+// GetMessageBatch handles GET /v1/messages/batches/:id.
+func (h *ClaudeCodeAPIHandler) GetMessageBatch(c *gin.Context) {
+	if job, ok := h.lookupBatch(c); ok {
+		c.JSON(http.StatusOK, job.snapshot())
+	}
+}
+
+// GetMessageBatchResults handles GET /v1/messages/batches/:id/results and streams the results as
+// JSON Lines in request order.
+func (h *ClaudeCodeAPIHandler) GetMessageBatchResults(c *gin.Context) {
+	job, ok := h.lookupBatch(c)
+	if !ok {
+		return
+	}
+	job.mu.Lock()
+	if job.batch.ProcessingStatus != batchStatusEnded {
+		job.mu.Unlock()
+		writeClaudeError(c, http.StatusBadRequest, "invalid_request_error", "message batch is still processing; results are available once it has ended")
+		return
+	}
+	results := job.results
+	job.mu.Unlock()
+
+	c.Header("Content-Type", "application/x-jsonl")
+	c.Status(http.StatusOK)
+	for _, line := range results {
+		_, _ = c.Writer.Write(line)
+		_, _ = c.Writer.Write([]byte("\n"))
+	}
+}
+
+// CancelMessageBatch handles POST /v1/messages/batches/:id/cancel. Requests not yet completed are
+// reported as canceled once the batch ends.
+func (h *ClaudeCodeAPIHandler) CancelMessageBatch(c *gin.Context) {
+	job, ok := h.lookupBatch(c)
+	if !ok {
+		return
+	}
+	job.mu.Lock()
+	if job.batch.ProcessingStatus == batchStatusInProgress {
+		now := time.Now().UTC()
+		job.batch.ProcessingStatus = batchStatusCanceling
+		job.batch.CancelInitiatedAt = &now
+	}
+	job.mu.Unlock()
+	job.cancel()
+	c.JSON(http.StatusOK, job.snapshot())
+}
+
+// DeleteMessageBatch handles DELETE /v1/messages/batches/:id. Only ended batches can be deleted.
+func (h *ClaudeCodeAPIHandler) DeleteMessageBatch(c *gin.Context) {
+	job, ok := h.lookupBatch(c)
+	if !ok {
+		return
+	}
+	batch := job.snapshot()
+	if batch.ProcessingStatus != batchStatusEnded {
+		writeClaudeError(c, http.StatusBadRequest, "invalid_request_error", "message batch must be canceled or ended before it can be deleted")
+		return
+	}
+	h.batches.remove(batch.ID)
+	c.JSON(http.StatusOK, gin.H{"id": batch.ID, "type": "message_batch_deleted"})
+}
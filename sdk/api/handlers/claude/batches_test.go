@@ -0,0 +1,65 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestParseBatchRequests(t *testing.T) {
+	valid := []byte(`{"requests":[{"custom_id":"a-1","params":{"model":"m","max_tokens":8,"messages":[]}},{"custom_id":"b_2","params":{"model":"m"}}]}`)
+	items, err := parseBatchRequests(valid, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 || items[0].customID != "a-1" || gjson.GetBytes(items[0].params, "max_tokens").Int() != 8 {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+
+	cases := []struct {
+		body string
+		max  int
+		want string
+	}{
+		{`{"requests":[]}`, 10, "non-empty"},
+		{`{"requests":[{"custom_id":"a","params":{"model":"m"}},{"custom_id":"a","params":{"model":"m"}}]}`, 10, "duplicate"},
+		{`{"requests":[{"custom_id":"bad id","params":{"model":"m"}}]}`, 10, "custom_id"},
+		{`{"requests":[{"custom_id":"a","params":{}}]}`, 10, "model"},
+		{`{"requests":[{"custom_id":"a","params":{"model":"m","stream":true}}]}`, 10, "stream"},
+		{`{"requests":[{"custom_id":"a","params":{"model":"m"}},{"custom_id":"b","params":{"model":"m"}}]}`, 1, "at most 1"},
+	}
+	for _, tc := range cases {
+		if _, err = parseBatchRequests([]byte(tc.body), tc.max); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want mention of %q", tc.body, err, tc.want)
+		}
+	}
+}
+
+func TestRunBatchCanceledBeforeStart(t *testing.T) {
+	h := NewClaudeCodeAPIHandler(nil)
+	items := []batchItem{{customID: "one", params: []byte(`{"model":"m"}`)}, {customID: "two", params: []byte(`{"model":"m"}`)}}
+	job := &batchJob{
+		batch:   messageBatch{ID: "msgbatch_test", RequestCounts: batchRequestCounts{Processing: len(items)}},
+		ids:     []string{"one", "two"},
+		results: make([][]byte, len(items)),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	job.cancel = cancel
+	cancel()
+	h.runBatch(ctx, job, items, 1)
+
+	batch := job.snapshot()
+	if batch.ProcessingStatus != batchStatusEnded || batch.ResultsURL == nil {
+		t.Fatalf("batch not ended: %+v", batch)
+	}
+	if batch.RequestCounts != (batchRequestCounts{Canceled: 2}) {
+		t.Fatalf("request counts = %+v, want 2 canceled", batch.RequestCounts)
+	}
+	for i, line := range job.results {
+		if gjson.GetBytes(line, "custom_id").String() != items[i].customID || gjson.GetBytes(line, "result.type").String() != "canceled" {
+			t.Fatalf("result %d = %s", i, line)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/codeexec"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	codeExecutionToolName        = "code_execution"
+	defaultCodeExecutionRounds   = 4
+	codeExecutionToolDescription = "Run a Python program in a sandbox. Returns its stdout, stderr and exit code. " +
+		"Print the values you need; state is not kept between calls."
+)
+
+// nativeCodeExecutionProviders serve models with a server-side interpreter.
+var nativeCodeExecutionProviders = map[string]bool{
+	"claude": true, "gemini": true, "gemini-cli": true, "vertex": true, "aistudio": true, "antigravity": true,
+}
+
+// codeExecutionEmulated reports whether rawJSON declares Claude's code execution tool for a
+// model that should run it through the local interpreter.
+func (h *ClaudeCodeAPIHandler) codeExecutionEmulated(rawJSON []byte) bool {
+	if h.Cfg == nil || !h.Cfg.CodeExecution.Emulate || !declaresCodeExecution(rawJSON) {
+		return false
+	}
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	if patterns := h.Cfg.CodeExecution.Models; len(patterns) > 0 {
+		for _, pattern := range patterns {
+			if util.MatchWildcard(strings.TrimSpace(pattern), modelName) {
+				return true
+			}
+		}
+		return false
+	}
+	providers := util.GetProviderName(modelName)
+	for _, provider := range providers {
+		if nativeCodeExecutionProviders[provider] {
+			return false
+		}
+	}
+	return len(providers) > 0
+}
+
+func declaresCodeExecution(rawJSON []byte) bool {
+	found := false
+	gjson.GetBytes(rawJSON, "tools").ForEach(func(_, tool gjson.Result) bool {
+		found = strings.HasPrefix(tool.Get("type").String(), "code_execution")
+		return !found
+	})
+	return found
+}
+
+// handleEmulatedCodeExecution serves a request whose code execution tool is emulated. The
+// upstream is called without streaming; streaming clients receive the final message as events.
+func (h *ClaudeCodeAPIHandler) handleEmulatedCodeExecution(c *gin.Context, rawJSON []byte, stream bool) {
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	cfg := h.Cfg.CodeExecution
+	runner := codeexec.Runner{
+		Command:        cfg.Command,
+		Timeout:        time.Duration(cfg.TimeoutSeconds) * time.Second,
+		MaxOutputBytes: cfg.MaxOutputBytes,
+	}
+	execute := func(body []byte) ([]byte, *interfaces.ErrorMessage) {
+		resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, body, h.GetAlt(c))
+		return decompressClaudeResponse(resp), errMsg
+	}
+	resp, errMsg := runCodeExecutionLoop(cliCtx, rawJSON, cfg.MaxIterations, execute, runner.Run)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	if stream {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.Write(messageToEvents(resp))
+	} else {
+		c.Header("Content-Type", "application/json")
+		_, _ = c.Writer.Write(resp)
+	}
+	cliCancel()
+}
+
+// runCodeExecutionLoop sends the request with the code execution tool replaced by a function
+// tool, runs every call of that tool locally and continues the conversation until the model
+// stops calling it. The returned message carries the calls and their results as
+// server_tool_use and code_execution_tool_result blocks ahead of the final content.
+func runCodeExecutionLoop(ctx context.Context, rawJSON []byte, maxRounds int, execute func([]byte) ([]byte, *interfaces.ErrorMessage), run func(context.Context, string) (codeexec.Result, error)) ([]byte, *interfaces.ErrorMessage) {
+	if maxRounds <= 0 {
+		maxRounds = defaultCodeExecutionRounds
+	}
+	body := emulatedCodeExecutionRequest(rawJSON)
+	var blocks []string
+	var inputTokens, outputTokens int64
+	for round := 0; ; round++ {
+		resp, errMsg := execute(body)
+		if errMsg != nil {
+			return nil, errMsg
+		}
+		inputTokens += gjson.GetBytes(resp, "usage.input_tokens").Int()
+		outputTokens += gjson.GetBytes(resp, "usage.output_tokens").Int()
+
+		content := gjson.GetBytes(resp, "content").Array()
+		var calls []gjson.Result
+		otherCalls := false
+		for _, block := range content {
+			if block.Get("type").String() != "tool_use" {
+				continue
+			}
+			if block.Get("name").String() == codeExecutionToolName {
+				calls = append(calls, block)
+			} else {
+				otherCalls = true
+			}
+		}
+		if len(calls) == 0 || round >= maxRounds {
+			out := finishCodeExecutionMessage(resp, blocks, content, inputTokens, outputTokens)
+			if len(calls) > 0 {
+				out, _ = sjson.SetBytes(out, "stop_reason", "pause_turn")
+			}
+			return out, nil
+		}
+
+		var results []string
+		for _, block := range content {
+			if block.Get("type").String() != "tool_use" || block.Get("name").String() != codeExecutionToolName {
+				blocks = append(blocks, block.Raw)
+				continue
+			}
+			id := block.Get("id").String()
+			result, err := run(ctx, block.Get("input.code").String())
+			if err != nil {
+				log.Warnf("code execution emulation: %v", err)
+				result = codeexec.Result{Stderr: err.Error(), ExitCode: -1}
+			}
+			use := `{"type":"server_tool_use","id":"","name":"code_execution","input":{}}`
+			use, _ = sjson.Set(use, "id", id)
+			use, _ = sjson.SetRaw(use, "input", block.Get("input").Raw)
+			output := `{"type":"code_execution_tool_result","tool_use_id":"","content":{"type":"code_execution_result","stdout":"","stderr":"","return_code":0}}`
+			output, _ = sjson.Set(output, "tool_use_id", id)
+			output, _ = sjson.Set(output, "content.stdout", result.Stdout)
+			output, _ = sjson.Set(output, "content.stderr", result.Stderr)
+			output, _ = sjson.Set(output, "content.return_code", result.ExitCode)
+			blocks = append(blocks, use, output)
+
+			text, _ := json.Marshal(map[string]any{"stdout": result.Stdout, "stderr": result.Stderr, "return_code": result.ExitCode})
+			toolResult := `{"type":"tool_result","tool_use_id":"","content":""}`
+			toolResult, _ = sjson.Set(toolResult, "tool_use_id", id)
+			toolResult, _ = sjson.Set(toolResult, "content", string(text))
+			results = append(results, toolResult)
+		}
+		if otherCalls {
+			// The client must answer its own tools; hand the turn back with the executions done.
+			return finishCodeExecutionMessage(resp, blocks, nil, inputTokens, outputTokens), nil
+		}
+
+		assistant := `{"role":"assistant","content":[]}`
+		assistant, _ = sjson.SetRaw(assistant, "content", gjson.GetBytes(resp, "content").Raw)
+		user := `{"role":"user","content":[` + strings.Join(results, ",") + `]}`
+		body, _ = sjson.SetRawBytes(body, "messages.-1", []byte(assistant))
+		body, _ = sjson.SetRawBytes(body, "messages.-1", []byte(user))
+	}
+}
+
+// emulatedCodeExecutionRequest swaps the code execution tool for an equivalent function tool
+// and rewrites earlier emulated executions in the history into plain text.
+func emulatedCodeExecutionRequest(rawJSON []byte) []byte {
+	body, _ := sjson.SetBytes(rawJSON, "stream", false)
+	var tools []string
+	gjson.GetBytes(body, "tools").ForEach(func(_, tool gjson.Result) bool {
+		if strings.HasPrefix(tool.Get("type").String(), "code_execution") {
+			fn := `{"name":"","description":"","input_schema":{"type":"object","properties":{"code":{"type":"string","description":"The Python program to run."}},"required":["code"]}}`
+			fn, _ = sjson.Set(fn, "name", codeExecutionToolName)
+			fn, _ = sjson.Set(fn, "description", codeExecutionToolDescription)
+			tools = append(tools, fn)
+			return true
+		}
+		tools = append(tools, tool.Raw)
+		return true
+	})
+	body, _ = sjson.SetRawBytes(body, "tools", []byte("["+strings.Join(tools, ",")+"]"))
+
+	messages := gjson.GetBytes(body, "messages")
+	for i, msg := range messages.Array() {
+		content := msg.Get("content")
+		if !content.IsArray() {
+			continue
+		}
+		changed := false
+		var rewritten []string
+		for _, block := range content.Array() {
+			switch block.Get("type").String() {
+			case "server_tool_use":
+				if block.Get("name").String() != codeExecutionToolName {
+					break
+				}
+				changed = true
+				text := "[Ran code]\n```python\n" + block.Get("input.code").String() + "\n```"
+				rewritten = append(rewritten, textBlock(text))
+				continue
+			case "code_execution_tool_result":
+				changed = true
+				result := block.Get("content")
+				text := fmt.Sprintf("[Exit code %d]\n%s%s", result.Get("return_code").Int(), result.Get("stdout").String(), result.Get("stderr").String())
+				rewritten = append(rewritten, textBlock(text))
+				continue
+			}
+			rewritten = append(rewritten, block.Raw)
+		}
+		if changed {
+			body, _ = sjson.SetRawBytes(body, fmt.Sprintf("messages.%d.content", i), []byte("["+strings.Join(rewritten, ",")+"]"))
+		}
+	}
+	return body
+}
+
+func textBlock(text string) string {
+	block, _ := sjson.Set(`{"type":"text","text":""}`, "text", text)
+	return block
+}
+
+// finishCodeExecutionMessage builds the client message from the last upstream response, the
+// blocks produced by earlier rounds and the final content.
+func finishCodeExecutionMessage(resp []byte, blocks []string, content []gjson.Result, inputTokens, outputTokens int64) []byte {
+	all := append([]string{}, blocks...)
+	for _, block := range content {
+		all = append(all, block.Raw)
+	}
+	out, _ := sjson.SetRawBytes(resp, "content", []byte("["+strings.Join(all, ",")+"]"))
+	if gjson.GetBytes(out, "usage").Exists() {
+		out, _ = sjson.SetBytes(out, "usage.input_tokens", inputTokens)
+		out, _ = sjson.SetBytes(out, "usage.output_tokens", outputTokens)
+	}
+	return out
+}
+
+// messageToEvents renders a complete Claude message as the server-sent events of a stream.
+func messageToEvents(message []byte) []byte {
+	var buf bytes.Buffer
+	write := func(event, data string) {
+		buf.WriteString("event: " + event + "\ndata: " + data + "\n\n")
+	}
+	start, _ := sjson.SetRawBytes(message, "content", []byte("[]"))
+	start, _ = sjson.SetBytes(start, "stop_reason", nil)
+	write("message_start", `{"type":"message_start","message":`+string(start)+`}`)
+	gjson.GetBytes(message, "content").ForEach(func(key, block gjson.Result) bool {
+		index := key.Int()
+		blockStart := block.Raw
+		switch block.Get("type").String() {
+		case "text":
+			blockStart = `{"type":"text","text":""}`
+		case "thinking":
+			blockStart, _ = sjson.Set(`{"type":"thinking","thinking":""}`, "signature", block.Get("signature").String())
+		}
+		event, _ := sjson.SetRaw(fmt.Sprintf(`{"type":"content_block_start","index":%d}`, index), "content_block", blockStart)
+		write("content_block_start", event)
+		switch block.Get("type").String() {
+		case "text":
+			delta, _ := sjson.Set(fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":""}}`, index), "delta.text", block.Get("text").String())
+			write("content_block_delta", delta)
+		case "thinking":
+			delta, _ := sjson.Set(fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"thinking_delta","thinking":""}}`, index), "delta.thinking", block.Get("thinking").String())
+			write("content_block_delta", delta)
+		}
+		write("content_block_stop", fmt.Sprintf(`{"type":"content_block_stop","index":%d}`, index))
+		return true
+	})
+	delta := `{"type":"message_delta","delta":{"stop_reason":null,"stop_sequence":null},"usage":{"output_tokens":0}}`
+	delta, _ = sjson.Set(delta, "delta.stop_reason", gjson.GetBytes(message, "stop_reason").Value())
+	delta, _ = sjson.Set(delta, "delta.stop_sequence", gjson.GetBytes(message, "stop_sequence").Value())
+	delta, _ = sjson.Set(delta, "usage.output_tokens", gjson.GetBytes(message, "usage.output_tokens").Int())
+	write("message_delta", delta)
+	write("message_stop", `{"type":"message_stop"}`)
+	return buf.Bytes()
+}
@@ -0,0 +1,79 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/codeexec"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/tidwall/gjson"
+)
+
+func TestRunCodeExecutionLoop(t *testing.T) {
+	request := []byte(`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"What is 6*7?"}],"tools":[{"type":"code_execution_20250522","name":"code_execution"}]}`)
+	responses := []string{
+		`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Computing."},{"type":"tool_use","id":"toolu_1","name":"code_execution","input":{"code":"print(6*7)"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`,
+		`{"id":"msg_2","type":"message","role":"assistant","content":[{"type":"text","text":"It is 42."}],"stop_reason":"end_turn","usage":{"input_tokens":20,"output_tokens":3}}`,
+	}
+	var bodies [][]byte
+	execute := func(body []byte) ([]byte, *interfaces.ErrorMessage) {
+		bodies = append(bodies, body)
+		return []byte(responses[len(bodies)-1]), nil
+	}
+	run := func(_ context.Context, code string) (codeexec.Result, error) {
+		if code != "print(6*7)" {
+			t.Fatalf("unexpected code %q", code)
+		}
+		return codeexec.Result{Stdout: "42\n"}, nil
+	}
+
+	out, errMsg := runCodeExecutionLoop(context.Background(), request, 0, execute, run)
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("upstream calls = %d, want 2", len(bodies))
+	}
+	first := gjson.ParseBytes(bodies[0])
+	if first.Get("stream").Bool() || first.Get("tools.0.name").String() != "code_execution" || !first.Get("tools.0.input_schema").Exists() {
+		t.Fatalf("code execution tool not emulated: %s", bodies[0])
+	}
+	second := gjson.ParseBytes(bodies[1])
+	if second.Get("messages.#").Int() != 3 || second.Get("messages.2.content.0.tool_use_id").String() != "toolu_1" ||
+		!strings.Contains(second.Get("messages.2.content.0.content").String(), `"stdout":"42\n"`) {
+		t.Fatalf("tool result not sent back: %s", bodies[1])
+	}
+
+	msg := gjson.ParseBytes(out)
+	types := []string{}
+	for _, block := range msg.Get("content").Array() {
+		types = append(types, block.Get("type").String())
+	}
+	if strings.Join(types, ",") != "text,server_tool_use,code_execution_tool_result,text" {
+		t.Fatalf("content types = %v", types)
+	}
+	if msg.Get("content.2.content.stdout").String() != "42\n" || msg.Get("stop_reason").String() != "end_turn" {
+		t.Fatalf("unexpected message: %s", out)
+	}
+	if msg.Get("usage.input_tokens").Int() != 30 || msg.Get("usage.output_tokens").Int() != 8 {
+		t.Fatalf("usage not summed: %s", msg.Get("usage").Raw)
+	}
+
+	events := string(messageToEvents(out))
+	if !strings.HasPrefix(events, "event: message_start\n") || !strings.Contains(events, `"stop_reason":"end_turn"`) || !strings.HasSuffix(events, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n") {
+		t.Fatalf("unexpected events:\n%s", events)
+	}
+}
+
+func TestEmulatedCodeExecutionRequestRewritesHistory(t *testing.T) {
+	request := []byte(`{"messages":[{"role":"user","content":"hi"},{"role":"assistant","content":[{"type":"server_tool_use","id":"s1","name":"code_execution","input":{"code":"print(1)"}},{"type":"code_execution_tool_result","tool_use_id":"s1","content":{"type":"code_execution_result","stdout":"1\n","stderr":"","return_code":0}}]}],"tools":[{"type":"code_execution_20250522","name":"code_execution"},{"name":"other","input_schema":{"type":"object"}}]}`)
+	body := gjson.ParseBytes(emulatedCodeExecutionRequest(request))
+	if body.Get("tools.1.name").String() != "other" || body.Get("tools.0.type").Exists() {
+		t.Fatalf("unexpected tools: %s", body.Get("tools").Raw)
+	}
+	content := body.Get("messages.1.content").Array()
+	if len(content) != 2 || content[0].Get("type").String() != "text" || !strings.Contains(content[1].Get("text").String(), "1\n") {
+		t.Fatalf("history not rewritten: %s", body.Get("messages.1").Raw)
+	}
+}
@@ -30,6 +30,9 @@ import (
 // It holds a pool of clients to interact with the backend service.
 type ClaudeCodeAPIHandler struct {
 	*handlers.BaseAPIHandler
+
+	// batches holds the emulated Message Batches and their results.
+	batches *batchStore
 }
 
 // NewClaudeCodeAPIHandler creates a new Claude API handlers instance.
@@ -43,6 +46,7 @@ type ClaudeCodeAPIHandler struct {
 func NewClaudeCodeAPIHandler(apiHandlers *handlers.BaseAPIHandler) *ClaudeCodeAPIHandler {
 	return &ClaudeCodeAPIHandler{
 		BaseAPIHandler: apiHandlers,
+		batches:        newBatchStore(),
 	}
 }
 
@@ -80,6 +84,14 @@ func (h *ClaudeCodeAPIHandler) ClaudeMessages(c *gin.Context) {
 
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
+	if h.codeExecutionEmulated(rawJSON) {
+		h.handleEmulatedCodeExecution(c, rawJSON, streamResult.Type == gjson.True)
+		return
+	}
+	if toolbox, tools := h.mcpToolbox(c.Request.Context(), rawJSON); toolbox != nil {
+		h.handleMCPTools(c, rawJSON, streamResult.Type == gjson.True, toolbox, tools)
+		return
+	}
 	if !streamResult.Exists() || streamResult.Type == gjson.False {
 		h.handleNonStreamingResponse(c, rawJSON)
 	} else {
@@ -124,17 +136,6 @@ func (h *ClaudeCodeAPIHandler) ClaudeCountTokens(c *gin.Context) {
 	cliCancel()
 }
 
-// ClaudeModels handles the Claude models listing endpoint.
-// It returns a JSON response containing available Claude models and their specifications.
-//
-// Parameters:
-//   - c: The Gin context for the request.
-func (h *ClaudeCodeAPIHandler) ClaudeModels(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"data": h.Models(),
-	})
-}
-
 // handleNonStreamingResponse handles non-streaming content generation requests for Claude models.
 // This function processes the request synchronously and returns the complete generated
 // response in a single API call. It supports various generation parameters and
@@ -158,21 +159,7 @@ func (h *ClaudeCodeAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSO
 		return
 	}
 
-	// Decompress gzipped responses - Claude API sometimes returns gzip without Content-Encoding header
-	// This fixes title generation and other non-streaming responses that arrive compressed
-	if len(resp) >= 2 && resp[0] == 0x1f && resp[1] == 0x8b {
-		gzReader, err := gzip.NewReader(bytes.NewReader(resp))
-		if err != nil {
-			log.Warnf("failed to decompress gzipped Claude response: %v", err)
-		} else {
-			defer gzReader.Close()
-			if decompressed, err := io.ReadAll(gzReader); err != nil {
-				log.Warnf("failed to read decompressed Claude response: %v", err)
-			} else {
-				resp = decompressed
-			}
-		}
-	}
+	resp = decompressClaudeResponse(resp)
 
 	_, _ = c.Writer.Write(resp)
 	cliCancel()
@@ -245,7 +232,8 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 	authKey := strings.TrimSpace(c.GetHeader("Authorization"))
 	dedupeKey := ""
 	if idempotencyKey != "" {
-		dedupeKey = claudeStreamDedupeKey(authKey, idempotencyKey)
+		// Scope deduplication to the tenant so identical keys on different hosts never share a stream.
+		dedupeKey = claudeStreamDedupeKey(c.GetString("tenant")+"\x00"+authKey, idempotencyKey)
 	}
 
 	if dedupeKey == "" {
@@ -284,7 +272,7 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 		}
 	}
 
-	stream := globalClaudeStreamHub.getOrCreate(dedupeKey, func(execCtx context.Context) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	stream := globalClaudeStreamHub.getOrCreate(dedupeKey, claudeStreamOrphanGraceFromConfig(h.Cfg, c.Request.URL.Path), func(execCtx context.Context) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
 		return h.ExecuteStreamWithAuthManager(execCtx, h.HandlerType(), modelName, rawJSON, "")
 	}, func(errMsg *interfaces.ErrorMessage) []byte {
 		if errMsg == nil {
@@ -294,15 +282,15 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 		return []byte(fmt.Sprintf("event: error\ndata: %s\n\n", errorBytes))
 	})
 
-	replay, sub, unsubscribe := stream.subscribe()
+	replay, sub, unsubscribe := stream.subscribe(claudeStreamBackpressureFromConfig(h.Cfg))
 	defer unsubscribe()
 
 	for _, chunk := range replay {
-		if len(chunk) == 0 {
-			continue
+		if data := chunk.Bytes(); len(data) > 0 {
+			_, _ = c.Writer.Write(data)
+			flusher.Flush()
 		}
-		_, _ = c.Writer.Write(chunk)
-		flusher.Flush()
+		chunk.release()
 	}
 
 	for {
@@ -312,16 +300,19 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 		case <-keepAlive.C:
 			writeKeepAlive()
 			flusher.Flush()
-		case chunk, ok := <-sub:
+		case chunk, ok := <-sub.C():
 			if !ok {
+				if terminal := sub.terminalChunk(); len(terminal) > 0 {
+					_, _ = c.Writer.Write(terminal)
+				}
 				flusher.Flush()
 				return
 			}
-			if len(chunk) == 0 {
-				continue
+			if data := chunk.Bytes(); len(data) > 0 {
+				_, _ = c.Writer.Write(data)
+				flusher.Flush()
 			}
-			_, _ = c.Writer.Write(chunk)
-			flusher.Flush()
+			chunk.release()
 		}
 	}
 }
@@ -350,6 +341,26 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 	})
 }
 
+// decompressClaudeResponse inflates gzipped responses. The Claude API sometimes returns gzip
+// without a Content-Encoding header, which breaks title generation and other non-streaming calls.
+func decompressClaudeResponse(resp []byte) []byte {
+	if len(resp) < 2 || resp[0] != 0x1f || resp[1] != 0x8b {
+		return resp
+	}
+	gzReader, err := gzip.NewReader(bytes.NewReader(resp))
+	if err != nil {
+		log.Warnf("failed to decompress gzipped Claude response: %v", err)
+		return resp
+	}
+	defer gzReader.Close()
+	decompressed, err := io.ReadAll(gzReader)
+	if err != nil {
+		log.Warnf("failed to read decompressed Claude response: %v", err)
+		return resp
+	}
+	return decompressed
+}
+
 func writeKeepAliveComment(c *gin.Context) {
 	if c == nil {
 		return
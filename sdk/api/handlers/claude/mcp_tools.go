@@ -0,0 +1,134 @@
+package claude
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mcp"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const defaultMCPToolRounds = 8
+
+// mcpToolbox returns the MCP toolbox for rawJSON's model, or nil when no MCP tools apply.
+func (h *ClaudeCodeAPIHandler) mcpToolbox(ctx context.Context, rawJSON []byte) (*mcp.Toolbox, []mcp.Tool) {
+	if h.Cfg == nil || !mcp.AppliesTo(h.Cfg.MCPClient, gjson.GetBytes(rawJSON, "model").String()) {
+		return nil, nil
+	}
+	toolbox := mcp.SharedToolbox(h.Cfg.MCPClient)
+	tools := toolbox.Tools(ctx)
+	if len(tools) == 0 {
+		return nil, nil
+	}
+	return toolbox, tools
+}
+
+// handleMCPTools serves a request with the configured MCP tools merged in. The upstream is
+// called without streaming; streaming clients receive the final message as events.
+func (h *ClaudeCodeAPIHandler) handleMCPTools(c *gin.Context, rawJSON []byte, stream bool, toolbox *mcp.Toolbox, tools []mcp.Tool) {
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	execute := func(body []byte) ([]byte, *interfaces.ErrorMessage) {
+		resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, body, h.GetAlt(c))
+		return decompressClaudeResponse(resp), errMsg
+	}
+	call := func(name string, input []byte) mcp.ToolResult {
+		return toolbox.Call(cliCtx, name, input)
+	}
+	resp, errMsg := runMCPToolLoop(rawJSON, tools, toolbox.Has, h.Cfg.MCPClient.MaxIterations, execute, call)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	if stream {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.Write(messageToEvents(resp))
+	} else {
+		c.Header("Content-Type", "application/json")
+		_, _ = c.Writer.Write(resp)
+	}
+	cliCancel()
+}
+
+// runMCPToolLoop sends the request with the MCP tools added, executes every call of them and
+// continues the conversation until the model answers or calls one of the client's own tools.
+// MCP calls never reach the client: the returned message carries the text of every round and
+// only the client's tool_use blocks.
+func runMCPToolLoop(rawJSON []byte, tools []mcp.Tool, isMCPTool func(string) bool, maxRounds int, execute func([]byte) ([]byte, *interfaces.ErrorMessage), call func(string, []byte) mcp.ToolResult) ([]byte, *interfaces.ErrorMessage) {
+	if maxRounds <= 0 {
+		maxRounds = defaultMCPToolRounds
+	}
+	body, _ := sjson.SetBytes(rawJSON, "stream", false)
+	if !gjson.GetBytes(body, "tools").IsArray() {
+		body, _ = sjson.SetRawBytes(body, "tools", []byte("[]"))
+	}
+	for _, tool := range tools {
+		def := `{"name":"","description":"","input_schema":{}}`
+		def, _ = sjson.Set(def, "name", tool.Name)
+		def, _ = sjson.Set(def, "description", tool.Description)
+		def, _ = sjson.SetRaw(def, "input_schema", string(tool.InputSchema))
+		body, _ = sjson.SetRawBytes(body, "tools.-1", []byte(def))
+	}
+
+	var blocks []string
+	var inputTokens, outputTokens int64
+	for round := 0; ; round++ {
+		resp, errMsg := execute(body)
+		if errMsg != nil {
+			return nil, errMsg
+		}
+		inputTokens += gjson.GetBytes(resp, "usage.input_tokens").Int()
+		outputTokens += gjson.GetBytes(resp, "usage.output_tokens").Int()
+
+		content := gjson.GetBytes(resp, "content").Array()
+		var kept []gjson.Result
+		mcpCalls, clientCalls := 0, 0
+		for _, block := range content {
+			if block.Get("type").String() == "tool_use" && isMCPTool(block.Get("name").String()) {
+				mcpCalls++
+				continue
+			}
+			if block.Get("type").String() == "tool_use" {
+				clientCalls++
+			}
+			kept = append(kept, block)
+		}
+		if mcpCalls == 0 || clientCalls > 0 || round >= maxRounds {
+			out := finishCodeExecutionMessage(resp, blocks, kept, inputTokens, outputTokens)
+			if clientCalls == 0 && gjson.GetBytes(out, "stop_reason").String() == "tool_use" {
+				out, _ = sjson.SetBytes(out, "stop_reason", "end_turn")
+			}
+			return out, nil
+		}
+
+		var results []string
+		for _, block := range content {
+			if block.Get("type").String() != "tool_use" {
+				blocks = append(blocks, block.Raw)
+				continue
+			}
+			result := call(block.Get("name").String(), []byte(block.Get("input").Raw))
+			toolResult := `{"type":"tool_result","tool_use_id":"","content":""}`
+			toolResult, _ = sjson.Set(toolResult, "tool_use_id", block.Get("id").String())
+			toolResult, _ = sjson.Set(toolResult, "content", result.Text)
+			if result.IsError {
+				toolResult, _ = sjson.Set(toolResult, "is_error", true)
+			}
+			results = append(results, toolResult)
+		}
+		assistant := `{"role":"assistant","content":[]}`
+		assistant, _ = sjson.SetRaw(assistant, "content", gjson.GetBytes(resp, "content").Raw)
+		user := `{"role":"user","content":[` + strings.Join(results, ",") + `]}`
+		body, _ = sjson.SetRawBytes(body, "messages.-1", []byte(assistant))
+		body, _ = sjson.SetRawBytes(body, "messages.-1", []byte(user))
+	}
+}
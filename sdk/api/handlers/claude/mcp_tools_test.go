@@ -0,0 +1,67 @@
+package claude
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mcp"
+	"github.com/tidwall/gjson"
+)
+
+func TestRunMCPToolLoop(t *testing.T) {
+	request := []byte(`{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Find the docs."}]}`)
+	tools := []mcp.Tool{{Name: "docs__search", Description: "Search docs", InputSchema: json.RawMessage(`{"type":"object","properties":{"q":{"type":"string"}}}`)}}
+	responses := []string{
+		`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Searching."},{"type":"tool_use","id":"toolu_1","name":"docs__search","input":{"q":"proxy"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`,
+		`{"id":"msg_2","type":"message","role":"assistant","content":[{"type":"text","text":"Found it."}],"stop_reason":"end_turn","usage":{"input_tokens":20,"output_tokens":3}}`,
+	}
+	var bodies [][]byte
+	execute := func(body []byte) ([]byte, *interfaces.ErrorMessage) {
+		bodies = append(bodies, body)
+		return []byte(responses[len(bodies)-1]), nil
+	}
+	call := func(name string, input []byte) mcp.ToolResult {
+		if name != "docs__search" || gjson.GetBytes(input, "q").String() != "proxy" {
+			t.Fatalf("unexpected call %s(%s)", name, input)
+		}
+		return mcp.ToolResult{Text: "result"}
+	}
+	isMCPTool := func(name string) bool { return strings.HasPrefix(name, "docs__") }
+
+	out, errMsg := runMCPToolLoop(request, tools, isMCPTool, 0, execute, call)
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("upstream calls = %d, want 2", len(bodies))
+	}
+	if gjson.GetBytes(bodies[0], "tools.0.name").String() != "docs__search" || !gjson.GetBytes(bodies[0], "tools.0.input_schema.properties.q").Exists() {
+		t.Fatalf("MCP tool not advertised: %s", bodies[0])
+	}
+	if gjson.GetBytes(bodies[1], "messages.2.content.0.content").String() != "result" {
+		t.Fatalf("tool result not sent back: %s", bodies[1])
+	}
+	msg := gjson.ParseBytes(out)
+	if msg.Get("content.#").Int() != 2 || msg.Get("content.0.text").String() != "Searching." || msg.Get("content.1.text").String() != "Found it." {
+		t.Fatalf("unexpected content: %s", msg.Get("content").Raw)
+	}
+	if msg.Get("usage.input_tokens").Int() != 30 {
+		t.Fatalf("usage not summed: %s", msg.Get("usage").Raw)
+	}
+}
+
+func TestRunMCPToolLoopReturnsClientToolCalls(t *testing.T) {
+	request := []byte(`{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}],"tools":[{"name":"local","input_schema":{"type":"object"}}]}`)
+	resp := `{"content":[{"type":"tool_use","id":"a","name":"docs__search","input":{}},{"type":"tool_use","id":"b","name":"local","input":{}}],"stop_reason":"tool_use"}`
+	execute := func([]byte) ([]byte, *interfaces.ErrorMessage) { return []byte(resp), nil }
+	call := func(string, []byte) mcp.ToolResult {
+		t.Fatal("MCP tools must not run when the client has calls to answer")
+		return mcp.ToolResult{}
+	}
+	out, _ := runMCPToolLoop(request, nil, func(name string) bool { return name == "docs__search" }, 0, execute, call)
+	if gjson.GetBytes(out, "content.#").Int() != 1 || gjson.GetBytes(out, "content.0.name").String() != "local" || gjson.GetBytes(out, "stop_reason").String() != "tool_use" {
+		t.Fatalf("unexpected message: %s", out)
+	}
+}
@@ -0,0 +1,164 @@
+package claude
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// anthropicModel renders a registered model in the Anthropic Models API schema. Beyond the
+// documented fields it reports the context window, output limit and capability flags that
+// Claude-family clients probe before choosing a model.
+func anthropicModel(id string) map[string]any {
+	info := registry.GetGlobalRegistry().GetModelInfo(id)
+	if info == nil {
+		info = registry.LookupStaticModelInfo(id)
+	}
+	model := map[string]any{
+		"type":         "model",
+		"id":           id,
+		"display_name": id,
+		"created_at":   time.Unix(0, 0).UTC().Format(time.RFC3339),
+	}
+	if info == nil {
+		return model
+	}
+	if info.DisplayName != "" {
+		model["display_name"] = info.DisplayName
+	}
+	if info.Created > 0 {
+		model["created_at"] = time.Unix(info.Created, 0).UTC().Format(time.RFC3339)
+	}
+	if info.OwnedBy != "" {
+		model["owned_by"] = info.OwnedBy
+	}
+	if contextWindow := firstPositive(info.ContextLength, info.InputTokenLimit); contextWindow > 0 {
+		model["max_input_tokens"] = contextWindow
+	}
+	if maxTokens := firstPositive(info.MaxCompletionTokens, info.OutputTokenLimit); maxTokens > 0 {
+		model["max_tokens"] = maxTokens
+	}
+	model["capabilities"] = map[string]any{
+		"extended_thinking": info.Thinking != nil,
+		"tool_use":          modelSupportsTools(info),
+	}
+	return model
+}
+
+func firstPositive(values ...int) int {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+// modelSupportsTools uses the advertised parameters when a provider lists them and otherwise
+// assumes chat models accept tools, except for image, embedding and speech models.
+func modelSupportsTools(info *registry.ModelInfo) bool {
+	if len(info.SupportedParameters) > 0 {
+		for _, p := range info.SupportedParameters {
+			if p == "tools" || p == "tool_choice" {
+				return true
+			}
+		}
+		return false
+	}
+	id := strings.ToLower(info.ID)
+	for _, marker := range []string{"image", "embedding", "tts", "whisper", "imagen"} {
+		if strings.Contains(id, marker) {
+			return false
+		}
+	}
+	return true
+}
+
+// ClaudeModels handles the Claude models listing endpoint. Models are returned newest first in
+// the Anthropic list schema, limited to those the client key may use. limit, after_id and
+// before_id page through the list; without limit every model is returned.
+//
+// Parameters:
+//   - c: The Gin context for the request.
+func (h *ClaudeCodeAPIHandler) ClaudeModels(c *gin.Context) {
+	models := make([]map[string]any, 0)
+	for _, m := range h.Models() {
+		id, _ := m["id"].(string)
+		if id == "" || !h.KeyAllowsModel(c, id) {
+			continue
+		}
+		models = append(models, anthropicModel(id))
+	}
+	sort.SliceStable(models, func(i, j int) bool {
+		ci, cj := models[i]["created_at"].(string), models[j]["created_at"].(string)
+		if ci != cj {
+			return ci > cj
+		}
+		return models[i]["id"].(string) < models[j]["id"].(string)
+	})
+
+	afterID, beforeID := c.Query("after_id"), c.Query("before_id")
+	start, end := 0, len(models)
+	if afterID != "" {
+		start = len(models)
+		for i, m := range models {
+			if m["id"] == afterID {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if beforeID != "" {
+		for i, m := range models {
+			if m["id"] == beforeID {
+				end = i
+				break
+			}
+		}
+	}
+	if start > end {
+		start = end
+	}
+	// Paging backwards keeps the models closest to before_id.
+	backwards := beforeID != "" && afterID == ""
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && end-start > limit {
+		if backwards {
+			start = end - limit
+		} else {
+			end = start + limit
+		}
+	}
+	page := models[start:end]
+	hasMore := end < len(models)
+	if backwards {
+		hasMore = start > 0
+	}
+
+	body := gin.H{"data": page, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(page) > 0 {
+		body["first_id"] = page[0]["id"]
+		body["last_id"] = page[len(page)-1]["id"]
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// ClaudeModel handles GET /v1/models/{model_id} in the Anthropic schema.
+func (h *ClaudeCodeAPIHandler) ClaudeModel(c *gin.Context) {
+	id := c.Param("id")
+	for _, m := range h.Models() {
+		if m["id"] == id && h.KeyAllowsModel(c, id) {
+			c.JSON(http.StatusOK, anthropicModel(id))
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{
+		"type":  "error",
+		"error": handlers.ErrorDetail{Type: "not_found_error", Message: "model: " + id},
+	})
+}
@@ -0,0 +1,67 @@
+package claude
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestClaudeModelsAnthropicSchema(t *testing.T) {
+	registry.GetGlobalRegistry().RegisterClient("models-test", "claude", []*registry.ModelInfo{
+		{ID: "test-claude-old", Type: "claude", OwnedBy: "anthropic", Created: 1700000000, DisplayName: "Old", ContextLength: 200000, MaxCompletionTokens: 8192},
+		{ID: "test-claude-new", Type: "claude", OwnedBy: "anthropic", Created: 1800000000, ContextLength: 200000, MaxCompletionTokens: 64000, Thinking: &registry.ThinkingSupport{Min: 1024, Max: 32000}},
+		{ID: "test-claude-hidden", Type: "claude", OwnedBy: "anthropic", Created: 1750000000},
+	})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("models-test") })
+
+	h := NewClaudeCodeAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{APIKeyPolicies: []sdkconfig.APIKeyPolicy{
+		{APIKeys: []string{"limited"}, Models: []string{"test-claude-old", "test-claude-new"}},
+	}}, nil))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("apiKey", "limited") })
+	router.GET("/v1/models", h.ClaudeModels)
+	router.GET("/v1/models/:id", h.ClaudeModel)
+
+	get := func(path string) (int, gjson.Result) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code, gjson.ParseBytes(rec.Body.Bytes())
+	}
+
+	code, body := get("/v1/models")
+	if code != http.StatusOK || body.Get("data.#").Int() != 2 {
+		t.Fatalf("unexpected list %d %s", code, body.Raw)
+	}
+	first := body.Get("data.0")
+	if first.Get("id").String() != "test-claude-new" || first.Get("type").String() != "model" ||
+		first.Get("created_at").String() != "2027-01-15T08:00:00Z" || first.Get("max_tokens").Int() != 64000 ||
+		!first.Get("capabilities.extended_thinking").Bool() || !first.Get("capabilities.tool_use").Bool() {
+		t.Fatalf("unexpected model %s", first.Raw)
+	}
+	if body.Get("data.1.capabilities.extended_thinking").Bool() || body.Get("has_more").Bool() {
+		t.Fatalf("unexpected list %s", body.Raw)
+	}
+
+	_, body = get("/v1/models?limit=1")
+	if body.Get("data.#").Int() != 1 || !body.Get("has_more").Bool() || body.Get("last_id").String() != "test-claude-new" {
+		t.Fatalf("unexpected page %s", body.Raw)
+	}
+	_, body = get("/v1/models?limit=1&after_id=test-claude-new")
+	if body.Get("first_id").String() != "test-claude-old" || body.Get("has_more").Bool() {
+		t.Fatalf("unexpected next page %s", body.Raw)
+	}
+
+	if code, body = get("/v1/models/test-claude-old"); code != http.StatusOK || body.Get("display_name").String() != "Old" {
+		t.Fatalf("unexpected model %d %s", code, body.Raw)
+	}
+	if code, _ = get("/v1/models/test-claude-hidden"); code != http.StatusNotFound {
+		t.Fatalf("model outside the key policy returned %d", code)
+	}
+}
@@ -0,0 +1,382 @@
+package claude
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	claudeStreamPolicyDrop  = "drop"
+	claudeStreamPolicyBlock = "block"
+	claudeStreamPolicyGrow  = "grow"
+	claudeStreamPolicySpill = "spill"
+
+	claudeStreamDefaultBlockTimeout  = 5 * time.Second
+	claudeStreamDefaultGrowMaxBytes  = 16 << 20
+	claudeStreamDefaultSpillMaxBytes = 256 << 20
+)
+
+var errClaudeStreamSubscriberLagged = errors.New("stream delivery aborted: client could not keep up with the upstream response")
+
+// claudeStreamBackpressure describes how a subscriber reacts once its channel buffer is full.
+type claudeStreamBackpressure struct {
+	policy         string
+	blockTimeout   time.Duration
+	maxBufferBytes int
+	spillDir       string
+}
+
+func claudeStreamBackpressureFromConfig(cfg *config.SDKConfig) claudeStreamBackpressure {
+	bp := claudeStreamBackpressure{policy: claudeStreamPolicyDrop}
+	if cfg == nil {
+		return bp
+	}
+	raw := cfg.Streaming.Backpressure
+	switch policy := strings.ToLower(strings.TrimSpace(raw.Policy)); policy {
+	case claudeStreamPolicyBlock, claudeStreamPolicyGrow, claudeStreamPolicySpill:
+		bp.policy = policy
+	case "", claudeStreamPolicyDrop:
+	default:
+		log.Warnf("unknown streaming backpressure policy %q, falling back to %q", raw.Policy, claudeStreamPolicyDrop)
+	}
+	bp.blockTimeout = time.Duration(raw.BlockTimeoutMs) * time.Millisecond
+	if bp.blockTimeout <= 0 {
+		bp.blockTimeout = claudeStreamDefaultBlockTimeout
+	}
+	bp.maxBufferBytes = raw.MaxBufferBytes
+	if bp.maxBufferBytes <= 0 {
+		bp.maxBufferBytes = claudeStreamDefaultGrowMaxBytes
+		if bp.policy == claudeStreamPolicySpill {
+			bp.maxBufferBytes = claudeStreamDefaultSpillMaxBytes
+		}
+	}
+	bp.spillDir = strings.TrimSpace(raw.SpillDir)
+	return bp
+}
+
+// claudeStreamOverflow queues chunks a subscriber could not accept immediately. push takes over
+// the caller's reference when it succeeds; pop hands a reference to the caller.
+type claudeStreamOverflow interface {
+	push(chunk *claudeStreamChunk) error
+	pop() (*claudeStreamChunk, bool, error)
+	close()
+}
+
+var errClaudeStreamOverflowFull = errors.New("stream overflow buffer is full")
+
+type claudeStreamMemoryOverflow struct {
+	chunks   []*claudeStreamChunk
+	bytes    int
+	maxBytes int
+}
+
+func (o *claudeStreamMemoryOverflow) push(chunk *claudeStreamChunk) error {
+	if o.bytes+len(chunk.Bytes()) > o.maxBytes {
+		return errClaudeStreamOverflowFull
+	}
+	o.chunks = append(o.chunks, chunk)
+	o.bytes += len(chunk.Bytes())
+	return nil
+}
+
+func (o *claudeStreamMemoryOverflow) pop() (*claudeStreamChunk, bool, error) {
+	if len(o.chunks) == 0 {
+		return nil, false, nil
+	}
+	chunk := o.chunks[0]
+	o.chunks[0] = nil
+	o.chunks = o.chunks[1:]
+	o.bytes -= len(chunk.Bytes())
+	return chunk, true, nil
+}
+
+func (o *claudeStreamMemoryOverflow) close() {
+	for _, chunk := range o.chunks {
+		chunk.release()
+	}
+	o.chunks = nil
+	o.bytes = 0
+}
+
+// claudeStreamFileOverflow spills queued chunks to a temporary file as length-prefixed records.
+type claudeStreamFileOverflow struct {
+	file     *os.File
+	readOff  int64
+	writeOff int64
+	maxBytes int64
+}
+
+func newClaudeStreamFileOverflow(dir string, maxBytes int) (*claudeStreamFileOverflow, error) {
+	file, err := os.CreateTemp(dir, "cliproxy-stream-spill-*")
+	if err != nil {
+		return nil, err
+	}
+	return &claudeStreamFileOverflow{file: file, maxBytes: int64(maxBytes)}, nil
+}
+
+func (o *claudeStreamFileOverflow) push(chunk *claudeStreamChunk) error {
+	data := chunk.Bytes()
+	if o.writeOff-o.readOff+int64(len(data))+4 > o.maxBytes {
+		return errClaudeStreamOverflowFull
+	}
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(data)))
+	if _, err := o.file.WriteAt(header[:], o.writeOff); err != nil {
+		return err
+	}
+	if _, err := o.file.WriteAt(data, o.writeOff+4); err != nil {
+		return err
+	}
+	o.writeOff += int64(4 + len(data))
+	// The bytes now live on disk; the in-memory copy can go back to the pool.
+	chunk.release()
+	return nil
+}
+
+func (o *claudeStreamFileOverflow) pop() (*claudeStreamChunk, bool, error) {
+	if o.readOff >= o.writeOff {
+		return nil, false, nil
+	}
+	var header [4]byte
+	if _, err := o.file.ReadAt(header[:], o.readOff); err != nil {
+		return nil, false, err
+	}
+	chunk := allocClaudeStreamChunk(int(binary.BigEndian.Uint32(header[:])))
+	if _, err := o.file.ReadAt(chunk.data, o.readOff+4); err != nil && !errors.Is(err, io.EOF) {
+		chunk.release()
+		return nil, false, err
+	}
+	o.readOff += int64(4 + len(chunk.data))
+	if o.readOff == o.writeOff {
+		// Fully drained: reclaim disk space.
+		if err := o.file.Truncate(0); err == nil {
+			o.readOff, o.writeOff = 0, 0
+		}
+	}
+	return chunk, true, nil
+}
+
+func (o *claudeStreamFileOverflow) close() {
+	name := o.file.Name()
+	_ = o.file.Close()
+	_ = os.Remove(name)
+}
+
+// claudeStreamSubscriber delivers chunks of a shared stream to a single client, applying the
+// configured backpressure policy when the client falls behind.
+type claudeStreamSubscriber struct {
+	out      chan *claudeStreamChunk
+	bp       claudeStreamBackpressure
+	lagChunk []byte
+
+	gone     chan struct{}
+	goneOnce sync.Once
+
+	mu        sync.Mutex
+	closed    bool
+	finishing bool
+	pumping   bool
+	overflow  claudeStreamOverflow
+	terminal  []byte
+}
+
+// newClaudeStreamSubscriber builds a subscriber; lagChunk is the pre-encoded error event emitted
+// when the subscriber has to be dropped.
+func newClaudeStreamSubscriber(bp claudeStreamBackpressure, lagChunk []byte) *claudeStreamSubscriber {
+	return &claudeStreamSubscriber{
+		out:      make(chan *claudeStreamChunk, claudeStreamSubscriberBufSize),
+		bp:       bp,
+		lagChunk: lagChunk,
+		gone:     make(chan struct{}),
+	}
+}
+
+// C returns the channel carrying stream chunks; the receiver owns one reference to each chunk
+// and releases it once written. The channel is closed once the stream ends or the subscriber
+// is dropped; callers should then emit terminalChunk if it is non-nil.
+func (s *claudeStreamSubscriber) C() <-chan *claudeStreamChunk { return s.out }
+
+// terminalChunk returns the error event to emit after C is closed, if delivery was aborted.
+func (s *claudeStreamSubscriber) terminalChunk() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.terminal
+}
+
+// send delivers chunk according to the backpressure policy, taking its own reference when the
+// chunk is accepted. It returns false once the subscriber no longer accepts chunks and should be
+// detached from the stream.
+func (s *claudeStreamSubscriber) send(chunk *claudeStreamChunk) bool {
+	chunk.retain()
+	if s.deliver(chunk) {
+		return true
+	}
+	chunk.release()
+	return false
+}
+
+func (s *claudeStreamSubscriber) deliver(chunk *claudeStreamChunk) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.finishing {
+		return false
+	}
+	if s.pumping {
+		return s.enqueueLocked(chunk)
+	}
+	select {
+	case s.out <- chunk:
+		return true
+	default:
+	}
+
+	switch s.bp.policy {
+	case claudeStreamPolicyBlock, claudeStreamPolicyGrow, claudeStreamPolicySpill:
+		// The wait happens in the subscriber's own pump goroutine, so a slow client never
+		// delays the broadcast to other subscribers or the upstream reader.
+		if s.overflow == nil {
+			s.overflow = s.newOverflow()
+		}
+		if !s.enqueueLocked(chunk) {
+			return false
+		}
+		s.pumping = true
+		go s.pump()
+		return true
+	default:
+		s.abortLocked(errClaudeStreamSubscriberLagged)
+		return false
+	}
+}
+
+func (s *claudeStreamSubscriber) newOverflow() claudeStreamOverflow {
+	if s.bp.policy == claudeStreamPolicySpill {
+		overflow, err := newClaudeStreamFileOverflow(s.bp.spillDir, s.bp.maxBufferBytes)
+		if err == nil {
+			return overflow
+		}
+		log.Warnf("stream spill file unavailable, buffering in memory: %v", err)
+	}
+	return &claudeStreamMemoryOverflow{maxBytes: s.bp.maxBufferBytes}
+}
+
+func (s *claudeStreamSubscriber) enqueueLocked(chunk *claudeStreamChunk) bool {
+	if err := s.overflow.push(chunk); err != nil {
+		if !errors.Is(err, errClaudeStreamOverflowFull) {
+			log.Warnf("stream overflow write failed: %v", err)
+		}
+		s.abortLocked(errClaudeStreamSubscriberLagged)
+		return false
+	}
+	return true
+}
+
+// pump drains the overflow queue into the subscriber channel. While it runs it owns closing
+// the channel, so concurrent senders never race with close. Under the block policy a client that
+// does not accept a chunk within blockTimeout is dropped and the queued chunks are discarded.
+func (s *claudeStreamSubscriber) pump() {
+	for {
+		s.mu.Lock()
+		chunk, ok, err := s.overflow.pop()
+		if err != nil {
+			log.Warnf("stream overflow read failed: %v", err)
+			if s.terminal == nil {
+				s.terminal = s.lagChunk
+			}
+			s.finishing = true
+			ok = false
+		}
+		if !ok {
+			s.pumping = false
+			if s.finishing {
+				s.closeLocked()
+			}
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if s.bp.policy == claudeStreamPolicyBlock {
+			timer = time.NewTimer(s.bp.blockTimeout)
+			timeout = timer.C
+		}
+		select {
+		case s.out <- chunk:
+		case <-s.gone:
+			chunk.release()
+			s.mu.Lock()
+			s.pumping = false
+			s.closeLocked()
+			s.mu.Unlock()
+			return
+		case <-timeout:
+			chunk.release()
+			s.mu.Lock()
+			s.abortLocked(errClaudeStreamSubscriberLagged)
+			s.pumping = false
+			s.closeLocked()
+			s.mu.Unlock()
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// abortLocked stops accepting chunks and records an error event for the client. Chunks already
+// buffered are still delivered before the channel closes.
+func (s *claudeStreamSubscriber) abortLocked(err error) {
+	if s.terminal == nil {
+		s.terminal = s.lagChunk
+	}
+	log.Warnf("claude stream subscriber dropped (policy %s): %v", s.bp.policy, err)
+	s.finishLocked()
+}
+
+// finish marks the stream complete; the channel closes once pending chunks are delivered.
+func (s *claudeStreamSubscriber) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finishLocked()
+}
+
+func (s *claudeStreamSubscriber) finishLocked() {
+	s.finishing = true
+	if !s.pumping {
+		s.closeLocked()
+	}
+}
+
+func (s *claudeStreamSubscriber) closeLocked() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.out)
+	if s.overflow != nil {
+		s.overflow.close()
+		s.overflow = nil
+	}
+}
+
+// detach is called when the client goes away; it releases buffered data without blocking.
+func (s *claudeStreamSubscriber) detach() {
+	s.goneOnce.Do(func() { close(s.gone) })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finishing = true
+	if !s.pumping {
+		s.closeLocked()
+	}
+}
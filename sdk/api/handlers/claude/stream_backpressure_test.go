@@ -0,0 +1,125 @@
+package claude
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// sendString offers a fresh chunk to sub and drops the caller's reference, as broadcast does.
+func sendString(sub *claudeStreamSubscriber, s string) bool {
+	chunk := newClaudeStreamChunk([]byte(s))
+	defer chunk.release()
+	return sub.send(chunk)
+}
+
+func fillSubscriber(t *testing.T, sub *claudeStreamSubscriber, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		sendString(sub, fmt.Sprintf("chunk-%d\n", i))
+	}
+}
+
+func drainSubscriber(sub *claudeStreamSubscriber) []string {
+	var out []string
+	for chunk := range sub.C() {
+		out = append(out, string(chunk.Bytes()))
+		chunk.release()
+	}
+	return out
+}
+
+func TestClaudeStreamSubscriber_DropEmitsTerminalError(t *testing.T) {
+	sub := newClaudeStreamSubscriber(claudeStreamBackpressure{policy: claudeStreamPolicyDrop}, []byte("event: error\n\n"))
+	fillSubscriber(t, sub, claudeStreamSubscriberBufSize)
+	if sendString(sub, "overflow") {
+		t.Fatal("expected send to fail once the buffer is full")
+	}
+	got := drainSubscriber(sub)
+	if len(got) != claudeStreamSubscriberBufSize {
+		t.Fatalf("delivered %d chunks, want %d", len(got), claudeStreamSubscriberBufSize)
+	}
+	if string(sub.terminalChunk()) != "event: error\n\n" {
+		t.Fatalf("terminal chunk = %q, want error event", sub.terminalChunk())
+	}
+}
+
+func TestClaudeStreamSubscriber_GrowAndSpillDeliverEverything(t *testing.T) {
+	for _, policy := range []string{claudeStreamPolicyGrow, claudeStreamPolicySpill} {
+		t.Run(policy, func(t *testing.T) {
+			bp := claudeStreamBackpressure{policy: policy, maxBufferBytes: 1 << 20, spillDir: t.TempDir()}
+			sub := newClaudeStreamSubscriber(bp, []byte("event: error\n\n"))
+			total := claudeStreamSubscriberBufSize * 3
+			fillSubscriber(t, sub, total)
+			sub.finish()
+
+			got := drainSubscriber(sub)
+			if len(got) != total {
+				t.Fatalf("delivered %d chunks, want %d", len(got), total)
+			}
+			for i, chunk := range got {
+				if want := fmt.Sprintf("chunk-%d\n", i); chunk != want {
+					t.Fatalf("chunk %d = %q, want %q", i, chunk, want)
+				}
+			}
+			if sub.terminalChunk() != nil {
+				t.Fatalf("unexpected terminal chunk %q", sub.terminalChunk())
+			}
+		})
+	}
+}
+
+func TestClaudeStreamSubscriber_GrowCapAborts(t *testing.T) {
+	bp := claudeStreamBackpressure{policy: claudeStreamPolicyGrow, maxBufferBytes: 32}
+	sub := newClaudeStreamSubscriber(bp, []byte("event: error\n\n"))
+	fillSubscriber(t, sub, claudeStreamSubscriberBufSize+10)
+
+	got := drainSubscriber(sub)
+	if len(got) >= claudeStreamSubscriberBufSize+10 {
+		t.Fatalf("expected truncated delivery, got %d chunks", len(got))
+	}
+	if sub.terminalChunk() == nil {
+		t.Fatal("expected terminal error after exceeding the buffer cap")
+	}
+}
+
+func TestClaudeStreamSubscriber_BlockWaitsForReader(t *testing.T) {
+	bp := claudeStreamBackpressure{policy: claudeStreamPolicyBlock, blockTimeout: time.Second, maxBufferBytes: 1 << 20}
+	sub := newClaudeStreamSubscriber(bp, []byte("event: error\n\n"))
+	total := claudeStreamSubscriberBufSize + 5
+	fillSubscriber(t, sub, total)
+	sub.finish()
+
+	time.Sleep(20 * time.Millisecond)
+	if got := drainSubscriber(sub); len(got) != total {
+		t.Fatalf("delivered %d chunks, want %d", len(got), total)
+	}
+	if sub.terminalChunk() != nil {
+		t.Fatalf("unexpected terminal chunk %q", sub.terminalChunk())
+	}
+}
+
+func TestClaudeStreamSubscriber_BlockDoesNotStallBroadcast(t *testing.T) {
+	bp := claudeStreamBackpressure{policy: claudeStreamPolicyBlock, blockTimeout: 20 * time.Millisecond, maxBufferBytes: 1 << 20}
+	stalled := newClaudeStreamSubscriber(bp, []byte("event: error\n\n"))
+	fillSubscriber(t, stalled, claudeStreamSubscriberBufSize)
+
+	start := time.Now()
+	if !sendString(stalled, "late") {
+		t.Fatal("expected the chunk to be queued for the subscriber")
+	}
+	if elapsed := time.Since(start); elapsed >= bp.blockTimeout {
+		t.Fatalf("send waited %s for a stalled subscriber", elapsed)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if sendString(stalled, "later") {
+		t.Fatal("expected the stalled subscriber to be dropped after the block timeout")
+	}
+	if got := drainSubscriber(stalled); len(got) != claudeStreamSubscriberBufSize {
+		t.Fatalf("delivered %d chunks, want %d", len(got), claudeStreamSubscriberBufSize)
+	}
+	if stalled.terminalChunk() == nil {
+		t.Fatal("expected terminal error after block timeout")
+	}
+}
@@ -0,0 +1,80 @@
+package claude
+
+import (
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// claudeStreamChunkClasses are the pooled buffer capacities. Typical SSE events fit the smaller
+// classes; anything above the largest class is allocated directly and never pooled.
+var claudeStreamChunkClasses = [...]int{512, 4 << 10, 32 << 10}
+
+var claudeStreamChunkPools [len(claudeStreamChunkClasses)]sync.Pool
+
+// claudeStreamChunk is a reference-counted, pool-backed copy of one stream event. A single
+// chunk is shared by the replay log, every subscriber channel and the overflow queues; its
+// buffer returns to the pool when the last holder releases it. A chunk that is never released
+// is simply garbage collected.
+type claudeStreamChunk struct {
+	data  []byte
+	class int
+	refs  atomic.Int32
+}
+
+// newClaudeStreamChunk copies src into a pooled chunk holding one reference.
+func newClaudeStreamChunk(src []byte) *claudeStreamChunk {
+	c := allocClaudeStreamChunk(len(src))
+	copy(c.data, src)
+	return c
+}
+
+// allocClaudeStreamChunk returns a chunk of length n holding one reference.
+func allocClaudeStreamChunk(n int) *claudeStreamChunk {
+	for class, size := range claudeStreamChunkClasses {
+		if n > size {
+			continue
+		}
+		c, _ := claudeStreamChunkPools[class].Get().(*claudeStreamChunk)
+		if c == nil {
+			c = &claudeStreamChunk{data: make([]byte, 0, size), class: class}
+		}
+		c.data = c.data[:n]
+		c.refs.Store(1)
+		return c
+	}
+	c := &claudeStreamChunk{data: make([]byte, n), class: -1}
+	c.refs.Store(1)
+	return c
+}
+
+// Bytes returns the chunk payload. It must not be used after the caller's reference is released.
+func (c *claudeStreamChunk) Bytes() []byte {
+	if c == nil {
+		return nil
+	}
+	return c.data
+}
+
+func (c *claudeStreamChunk) retain() {
+	c.refs.Add(1)
+}
+
+func (c *claudeStreamChunk) release() {
+	if c == nil {
+		return
+	}
+	switch refs := c.refs.Add(-1); {
+	case refs > 0:
+		return
+	case refs < 0:
+		log.Errorf("claude stream chunk released %d times too often", -refs)
+		return
+	}
+	if c.class < 0 {
+		return
+	}
+	c.data = c.data[:0]
+	claudeStreamChunkPools[c.class].Put(c)
+}
@@ -0,0 +1,113 @@
+package claude
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestClaudeStream() *claudeStream {
+	now := time.Now()
+	return &claudeStream{
+		createdAt:   now,
+		updatedAt:   now,
+		subscribers: make(map[*claudeStreamSubscriber]struct{}),
+		doneCh:      make(chan struct{}),
+	}
+}
+
+func TestClaudeStreamChunk_PoolClasses(t *testing.T) {
+	small := newClaudeStreamChunk([]byte("data: {}\n\n"))
+	if small.class != 0 || string(small.Bytes()) != "data: {}\n\n" {
+		t.Fatalf("small chunk class=%d data=%q", small.class, small.Bytes())
+	}
+	small.release()
+
+	large := allocClaudeStreamChunk(claudeStreamChunkClasses[len(claudeStreamChunkClasses)-1] + 1)
+	if large.class != -1 {
+		t.Fatalf("oversized chunk pooled in class %d", large.class)
+	}
+	large.release()
+}
+
+func TestClaudeStream_SharesChunkAcrossReplayAndSubscribers(t *testing.T) {
+	stream := newTestClaudeStream()
+	_, first, unsubFirst := stream.subscribe(claudeStreamBackpressure{policy: claudeStreamPolicyDrop})
+	defer unsubFirst()
+	_, second, unsubSecond := stream.subscribe(claudeStreamBackpressure{policy: claudeStreamPolicyDrop})
+	defer unsubSecond()
+
+	stream.broadcast([]byte("event: ping\n\n"))
+
+	a, b := <-first.C(), <-second.C()
+	if a != b {
+		t.Fatal("subscribers received different chunk copies")
+	}
+	if len(stream.replay) != 1 || stream.replay[0] != a {
+		t.Fatal("replay log does not share the broadcast chunk")
+	}
+	// Replay log plus both subscribers.
+	if refs := a.refs.Load(); refs != 3 {
+		t.Fatalf("refs = %d, want 3", refs)
+	}
+
+	stream.finish()
+	replay, _, _ := stream.subscribe(claudeStreamBackpressure{policy: claudeStreamPolicyDrop})
+	if len(replay) != 1 || !bytes.Equal(replay[0].Bytes(), []byte("event: ping\n\n")) {
+		t.Fatalf("unexpected replay %v", replay)
+	}
+
+	a.release()
+	b.release()
+	replay[0].release()
+	stream.releaseReplay()
+	if refs := a.refs.Load(); refs != 0 {
+		t.Fatalf("refs after release = %d, want 0", refs)
+	}
+}
+
+// BenchmarkClaudeStreamBroadcast fans 64 events out to four subscribers on each of 128
+// concurrent streams, then evicts the streams the way the hub does after the cache TTL.
+func BenchmarkClaudeStreamBroadcast(b *testing.B) {
+	const (
+		streams     = 128
+		subscribers = 4
+		events      = 64
+	)
+	event := []byte(fmt.Sprintf("event: content_block_delta\ndata: %s\n\n", bytes.Repeat([]byte("x"), 900)))
+	bp := claudeStreamBackpressure{policy: claudeStreamPolicyGrow, maxBufferBytes: 16 << 20}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(streams * events * len(event)))
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for s := 0; s < streams; s++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				stream := newTestClaudeStream()
+				var readers sync.WaitGroup
+				for r := 0; r < subscribers; r++ {
+					_, sub, unsubscribe := stream.subscribe(bp)
+					readers.Add(1)
+					go func() {
+						defer readers.Done()
+						defer unsubscribe()
+						for chunk := range sub.C() {
+							chunk.release()
+						}
+					}()
+				}
+				for e := 0; e < events; e++ {
+					stream.broadcast(event)
+				}
+				stream.finish()
+				readers.Wait()
+				stream.releaseReplay()
+			}()
+		}
+		wg.Wait()
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 )
 
 type claudeStreamStarter func(ctx context.Context) (<-chan []byte, <-chan *interfaces.ErrorMessage)
@@ -19,8 +23,36 @@ const (
 	claudeStreamOrphanCancelAfter  = 30 * time.Second
 	claudeStreamCompletedCacheTTL  = 5 * time.Minute
 	claudeStreamPruneIntervalFloor = 30 * time.Second
+
+	// claudeStreamCancelImmediately as an orphan grace cancels the upstream request as soon as
+	// the last subscriber leaves.
+	claudeStreamCancelImmediately time.Duration = -1
 )
 
+// claudeStreamOrphanGraceFromConfig resolves the orphan grace period for a request path from
+// streaming.cancel.
+func claudeStreamOrphanGraceFromConfig(cfg *config.SDKConfig, path string) time.Duration {
+	if cfg == nil {
+		return claudeStreamOrphanCancelAfter
+	}
+	raw := cfg.Streaming.Cancel
+	seconds, immediate := raw.OrphanGraceSeconds, raw.Immediate
+	for _, route := range raw.Routes {
+		if util.MatchWildcard(strings.TrimSpace(route.Path), path) {
+			seconds, immediate = route.OrphanGraceSeconds, route.Immediate
+			break
+		}
+	}
+	switch {
+	case immediate:
+		return claudeStreamCancelImmediately
+	case seconds > 0:
+		return time.Duration(seconds) * time.Second
+	default:
+		return claudeStreamOrphanCancelAfter
+	}
+}
+
 var globalClaudeStreamHub = newClaudeStreamHub()
 
 func claudeStreamDedupeKey(authHeader, idempotencyKey string) string {
@@ -43,7 +75,7 @@ func newClaudeStreamHub() *claudeStreamHub {
 	}
 }
 
-func (h *claudeStreamHub) getOrCreate(key string, starter claudeStreamStarter, encodeErr claudeStreamErrorEncoder) *claudeStream {
+func (h *claudeStreamHub) getOrCreate(key string, orphanGrace time.Duration, starter claudeStreamStarter, encodeErr claudeStreamErrorEncoder) *claudeStream {
 	now := time.Now()
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -57,9 +89,10 @@ func (h *claudeStreamHub) getOrCreate(key string, starter claudeStreamStarter, e
 
 	s := &claudeStream{
 		key:         key,
+		orphanGrace: orphanGrace,
 		createdAt:   now,
 		updatedAt:   now,
-		subscribers: make(map[chan []byte]struct{}),
+		subscribers: make(map[*claudeStreamSubscriber]struct{}),
 		doneCh:      make(chan struct{}),
 	}
 	h.streams[key] = s
@@ -85,7 +118,7 @@ func (h *claudeStreamHub) pruneLocked(now time.Time) {
 			delete(h.streams, key)
 			continue
 		}
-		createdAt, doneAt, done := s.stateForPrune()
+		createdAt, updatedAt, doneAt, done := s.stateForPrune()
 		if !done {
 			// Cap runaway streams even if nobody retries with the same key.
 			if now.Sub(createdAt) > claudeStreamCompletedCacheTTL*2 {
@@ -93,8 +126,11 @@ func (h *claudeStreamHub) pruneLocked(now time.Time) {
 			}
 			continue
 		}
-		if !doneAt.IsZero() && now.Sub(doneAt) > claudeStreamCompletedCacheTTL {
+		// updatedAt is refreshed by getOrCreate under the hub lock, so a stream that was just
+		// handed to a retrying client is never evicted before it subscribes.
+		if !doneAt.IsZero() && now.Sub(doneAt) > claudeStreamCompletedCacheTTL && now.Sub(updatedAt) > claudeStreamCompletedCacheTTL {
 			delete(h.streams, key)
+			s.releaseReplay()
 		}
 	}
 }
@@ -107,11 +143,15 @@ type claudeStream struct {
 	updatedAt time.Time
 	doneAt    time.Time
 
-	subscribers map[chan []byte]struct{}
+	subscribers map[*claudeStreamSubscriber]struct{}
 	orphanTimer *time.Timer
+	// orphanGrace is how long the stream outlives its last subscriber; zero means the default
+	// and claudeStreamCancelImmediately cancels at once.
+	orphanGrace time.Duration
+	encodeErr   claudeStreamErrorEncoder
 
 	replayBytes int
-	replay      [][]byte
+	replay      []*claudeStreamChunk
 
 	done   bool
 	doneCh chan struct{}
@@ -125,10 +165,22 @@ func (s *claudeStream) touch(now time.Time) {
 	s.updatedAt = now
 }
 
-func (s *claudeStream) stateForPrune() (createdAt, doneAt time.Time, done bool) {
+func (s *claudeStream) stateForPrune() (createdAt, updatedAt, doneAt time.Time, done bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return s.createdAt, s.doneAt, s.done
+	return s.createdAt, s.updatedAt, s.doneAt, s.done
+}
+
+// releaseReplay drops the stream's references to its replay log once it leaves the hub.
+// Subscribers still writing replayed chunks hold their own references.
+func (s *claudeStream) releaseReplay() {
+	s.mu.Lock()
+	replay := s.replay
+	s.replay = nil
+	s.mu.Unlock()
+	for _, chunk := range replay {
+		chunk.release()
+	}
 }
 
 func (s *claudeStream) cancelOrphaned() {
@@ -144,6 +196,7 @@ func (s *claudeStream) start(starter claudeStreamStarter, encodeErr claudeStream
 	execCtx, cancel := context.WithCancel(context.Background())
 	s.mu.Lock()
 	s.cancel = cancel
+	s.encodeErr = encodeErr
 	s.mu.Unlock()
 
 	data, errs := starter(execCtx)
@@ -200,9 +253,9 @@ func (s *claudeStream) finish() {
 	s.doneAt = time.Now()
 	close(s.doneCh)
 
-	for ch := range s.subscribers {
-		close(ch)
-		delete(s.subscribers, ch)
+	for sub := range s.subscribers {
+		sub.finish()
+		delete(s.subscribers, sub)
 	}
 	if s.orphanTimer != nil {
 		s.orphanTimer.Stop()
@@ -211,15 +264,20 @@ func (s *claudeStream) finish() {
 	s.mu.Unlock()
 }
 
-func (s *claudeStream) subscribe() (replay [][]byte, sub <-chan []byte, unsubscribe func()) {
-	ch := make(chan []byte, claudeStreamSubscriberBufSize)
+// subscribe attaches a new subscriber. The caller owns one reference to each replayed chunk and
+// must release it once written.
+func (s *claudeStream) subscribe(bp claudeStreamBackpressure) (replay []*claudeStreamChunk, sub *claudeStreamSubscriber, unsubscribe func()) {
 	now := time.Now()
 
 	s.mu.Lock()
 	s.updatedAt = now
+	sub = newClaudeStreamSubscriber(bp, s.lagMessageLocked())
 
 	if len(s.replay) > 0 {
 		replay = append(replay, s.replay...)
+		for _, chunk := range replay {
+			chunk.retain()
+		}
 	}
 
 	if s.orphanTimer != nil {
@@ -228,29 +286,32 @@ func (s *claudeStream) subscribe() (replay [][]byte, sub <-chan []byte, unsubscr
 	}
 
 	if s.done {
-		close(ch)
-		sub = ch
+		sub.finish()
 		s.mu.Unlock()
 		return replay, sub, func() {}
 	}
 
-	s.subscribers[ch] = struct{}{}
-	sub = ch
+	s.subscribers[sub] = struct{}{}
 	s.mu.Unlock()
 
 	unsubscribe = func() {
+		sub.detach()
 		s.mu.Lock()
-		if _, ok := s.subscribers[ch]; ok {
-			delete(s.subscribers, ch)
-			close(ch)
-		}
+		delete(s.subscribers, sub)
 		shouldCancel := !s.done && len(s.subscribers) == 0 && s.orphanTimer == nil
-		if shouldCancel {
-			s.orphanTimer = time.AfterFunc(claudeStreamOrphanCancelAfter, func() {
+		grace := s.orphanGrace
+		if grace == 0 {
+			grace = claudeStreamOrphanCancelAfter
+		}
+		if shouldCancel && grace > 0 {
+			s.orphanTimer = time.AfterFunc(grace, func() {
 				s.cancelOrphaned()
 			})
 		}
 		s.mu.Unlock()
+		if shouldCancel && grace < 0 {
+			s.cancelOrphaned()
+		}
 	}
 
 	return replay, sub, unsubscribe
@@ -263,7 +324,7 @@ func (s *claudeStream) broadcast(chunk []byte) {
 
 	// Snapshot subscribers and decide on replay buffering under lock,
 	// then broadcast outside to avoid holding the lock during writes.
-	var subs []chan []byte
+	var subs []*claudeStreamSubscriber
 
 	s.mu.Lock()
 	if s.done {
@@ -271,12 +332,16 @@ func (s *claudeStream) broadcast(chunk []byte) {
 		return
 	}
 
+	// One pooled copy is shared by the replay log and every subscriber; each holder keeps its
+	// own reference and the buffer is recycled after the last one is released.
+	shared := newClaudeStreamChunk(chunk)
+	defer shared.release()
+
 	if s.replayBytes < claudeStreamReplayMaxBytes {
-		cloned := make([]byte, len(chunk))
-		copy(cloned, chunk)
-		if s.replayBytes+len(cloned) <= claudeStreamReplayMaxBytes {
-			s.replay = append(s.replay, cloned)
-			s.replayBytes += len(cloned)
+		if s.replayBytes+len(chunk) <= claudeStreamReplayMaxBytes {
+			shared.retain()
+			s.replay = append(s.replay, shared)
+			s.replayBytes += len(chunk)
 		} else {
 			// Stop buffering further once we hit the cap.
 			s.replayBytes = claudeStreamReplayMaxBytes
@@ -284,22 +349,26 @@ func (s *claudeStream) broadcast(chunk []byte) {
 	}
 
 	s.updatedAt = time.Now()
-	for ch := range s.subscribers {
-		subs = append(subs, ch)
+	for sub := range s.subscribers {
+		subs = append(subs, sub)
 	}
 	s.mu.Unlock()
 
-	for _, ch := range subs {
-		select {
-		case ch <- chunk:
-		default:
-			// Subscriber can't keep up; drop it.
-			s.mu.Lock()
-			if _, ok := s.subscribers[ch]; ok {
-				delete(s.subscribers, ch)
-				close(ch)
-			}
-			s.mu.Unlock()
+	for _, sub := range subs {
+		if sub.send(shared) {
+			continue
 		}
+		// Subscriber was dropped by its backpressure policy; it already carries an error event.
+		s.mu.Lock()
+		delete(s.subscribers, sub)
+		s.mu.Unlock()
+	}
+}
+
+// lagMessageLocked encodes the error event sent to a subscriber that had to be dropped.
+func (s *claudeStream) lagMessageLocked() []byte {
+	if s.encodeErr == nil {
+		return nil
 	}
+	return s.encodeErr(&interfaces.ErrorMessage{StatusCode: http.StatusServiceUnavailable, Error: errClaudeStreamSubscriberLagged})
 }
@@ -0,0 +1,67 @@
+package claude
+
+import (
+	"testing"
+	"time"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestClaudeStreamOrphanGraceFromConfig(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	if got := claudeStreamOrphanGraceFromConfig(cfg, "/v1/messages"); got != claudeStreamOrphanCancelAfter {
+		t.Fatalf("default grace = %v", got)
+	}
+	cfg.Streaming.Cancel = sdkconfig.StreamCancelConfig{
+		OrphanGraceSeconds: 10,
+		Routes: []sdkconfig.StreamCancelRoute{
+			{Path: "/v1/messages", Immediate: true},
+			{Path: "/api/provider/*", OrphanGraceSeconds: 5},
+			{Path: "/*/v1/messages", OrphanGraceSeconds: 7},
+		},
+	}
+	cases := map[string]time.Duration{
+		"/v1/messages":                          claudeStreamCancelImmediately,
+		"/api/provider/anthropic/v1/messages":   5 * time.Second,
+		"/api/provider/anthropic/v1/messages/x": 5 * time.Second,
+		"/custom/v1/messages":                   7 * time.Second,
+		"/other":                                10 * time.Second,
+	}
+	for path, want := range cases {
+		if got := claudeStreamOrphanGraceFromConfig(cfg, path); got != want {
+			t.Errorf("grace for %s = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestClaudeStreamImmediateCancel(t *testing.T) {
+	stream := newTestClaudeStream()
+	stream.orphanGrace = claudeStreamCancelImmediately
+	canceled := make(chan struct{})
+	stream.cancel = func() { close(canceled) }
+
+	_, _, unsubscribe := stream.subscribe(claudeStreamBackpressure{policy: claudeStreamPolicyDrop})
+	unsubscribe()
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("stream was not cancelled when its last subscriber left")
+	}
+}
+
+func TestClaudeStreamGraceKeepsStreamForRetry(t *testing.T) {
+	stream := newTestClaudeStream()
+	stream.orphanGrace = 50 * time.Millisecond
+	canceled := make(chan struct{})
+	stream.cancel = func() { close(canceled) }
+
+	_, _, unsubscribe := stream.subscribe(claudeStreamBackpressure{policy: claudeStreamPolicyDrop})
+	unsubscribe()
+	// A retrying client resubscribes within the grace period and stops the timer.
+	_, _, _ = stream.subscribe(claudeStreamBackpressure{policy: claudeStreamPolicyDrop})
+	select {
+	case <-canceled:
+		t.Fatal("stream cancelled although a client resubscribed")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
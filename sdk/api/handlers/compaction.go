@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/guardrail"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultCompactionThreshold = 0.8
+	defaultCompactionKeep      = 6
+	defaultMaxSummaryTokens    = 1024
+	// compactionCacheSize bounds the summaries kept for reuse across turns.
+	compactionCacheSize = 256
+)
+
+const compactionPrompt = "Summarize the earlier part of the conversation below so it can replace those turns. " +
+	"Keep every fact needed to continue: the user's goals and constraints, decisions made, file names, " +
+	"code identifiers, commands, tool results that matter, and open tasks. Write plain prose, no preamble."
+
+type compactionSkipKey struct{}
+
+var (
+	compactionMu    sync.Mutex
+	compactionCache = make(map[[32]byte]string)
+)
+
+// compactConversation replaces the older turns of a request that approaches the target model's
+// context window with a summary written by the compaction model. The request is returned
+// unchanged when compaction is disabled, not needed or fails.
+func (h *BaseAPIHandler) compactConversation(ctx context.Context, modelName string, rawJSON []byte) []byte {
+	if h.Cfg == nil || !h.Cfg.Compaction.Enable || strings.TrimSpace(h.Cfg.Compaction.Model) == "" {
+		return rawJSON
+	}
+	if skip, _ := ctx.Value(compactionSkipKey{}).(bool); skip {
+		return rawJSON
+	}
+	cfg := h.Cfg.Compaction
+	window := contextWindow(cfg, modelName)
+	if window <= 0 {
+		return rawJSON
+	}
+	threshold := cfg.Threshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultCompactionThreshold
+	}
+	estimated := estimateTokens(rawJSON)
+	if float64(estimated) < threshold*float64(window) {
+		return rawJSON
+	}
+	history, ok := parseTurnHistory(rawJSON)
+	if !ok {
+		return rawJSON
+	}
+	keep := cfg.KeepRecentMessages
+	if keep <= 0 {
+		keep = defaultCompactionKeep
+	}
+	if len(history.conversation) <= keep {
+		return rawJSON
+	}
+	cut := history.userTurnAt(len(history.conversation) - keep)
+	if cut >= len(history.conversation) {
+		// The recent messages are one long tool exchange; summarize up to its opening turn.
+		cut = len(history.conversation) - keep
+		for cut > 0 && !isUserTurnStart(history.items[history.conversation[cut]]) {
+			cut--
+		}
+	}
+	if cut <= 0 {
+		return rawJSON
+	}
+	summary, err := h.summarizeTurns(ctx, cfg, history.dropped(cut))
+	if err != nil {
+		log.Warnf("compaction: summarizing %d messages for %s failed: %v", cut, modelName, err)
+		return rawJSON
+	}
+	compacted, err := history.replace(rawJSON, cut, summaryTurns(history.path, summary)...)
+	if err != nil {
+		return rawJSON
+	}
+	log.Infof("compaction: replaced %d messages for %s (~%d of %d tokens) with a summary", cut, modelName, estimated, window)
+	return compacted
+}
+
+// contextWindow returns the context window of modelName in tokens, or 0 when unknown.
+func contextWindow(cfg config.CompactionConfig, modelName string) int {
+	for pattern, tokens := range cfg.ContextWindows {
+		if tokens > 0 && util.MatchWildcard(strings.TrimSpace(pattern), modelName) {
+			return tokens
+		}
+	}
+	info := registry.GetGlobalRegistry().GetModelInfo(modelName)
+	if info == nil {
+		return 0
+	}
+	if info.InputTokenLimit > 0 {
+		return info.InputTokenLimit
+	}
+	return info.ContextLength
+}
+
+// estimateTokens approximates the prompt size of a request at four characters per token.
+func estimateTokens(rawJSON []byte) int {
+	chars := 0
+	for _, text := range guardrail.ExtractText(rawJSON) {
+		chars += len(text)
+	}
+	return chars / 4
+}
+
+// summarizeTurns asks the compaction model for a summary of turns. Summaries are cached by the
+// summarized content so later turns of the same conversation reuse them.
+func (h *BaseAPIHandler) summarizeTurns(ctx context.Context, cfg config.CompactionConfig, turns []gjson.Result) (string, error) {
+	var transcript strings.Builder
+	for _, turn := range turns {
+		role := turn.Get("role").String()
+		if role == "" {
+			role = turn.Get("type").String()
+		}
+		text := strings.Join(guardrail.ExtractText([]byte(turn.Raw)), "\n")
+		if name := turn.Get("content.#(type==\"tool_use\").name").String(); name != "" {
+			text = strings.TrimSpace(text + "\n[called tool " + name + "]")
+		}
+		if text == "" {
+			continue
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", role, text)
+	}
+	key := sha256.Sum256([]byte(cfg.Model + "\x00" + transcript.String()))
+	compactionMu.Lock()
+	cached, ok := compactionCache[key]
+	compactionMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	maxTokens := cfg.MaxSummaryTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxSummaryTokens
+	}
+	body, _ := json.Marshal(map[string]any{
+		"model":      cfg.Model,
+		"max_tokens": maxTokens,
+		"messages": []map[string]string{
+			{"role": "system", "content": compactionPrompt},
+			{"role": "user", "content": transcript.String()},
+		},
+	})
+	summaryCtx := context.WithValue(guardrail.WithoutGuardrails(ctx), compactionSkipKey{}, true)
+	resp, errMsg := h.ExecuteWithAuthManager(summaryCtx, "openai", cfg.Model, body, "")
+	if errMsg != nil {
+		if errMsg.Error != nil {
+			return "", errMsg.Error
+		}
+		return "", fmt.Errorf("compaction model returned status %d", errMsg.StatusCode)
+	}
+	summary := strings.TrimSpace(gjson.GetBytes(resp, "choices.0.message.content").String())
+	if summary == "" {
+		return "", fmt.Errorf("compaction model returned an empty summary")
+	}
+	compactionMu.Lock()
+	if len(compactionCache) >= compactionCacheSize {
+		compactionCache = make(map[[32]byte]string)
+	}
+	compactionCache[key] = summary
+	compactionMu.Unlock()
+	return summary, nil
+}
+
+// summaryTurns renders the summary as a user turn followed by an assistant acknowledgement, so
+// role alternation holds before the next user turn.
+func summaryTurns(path, summary string) []string {
+	text := "[Summary of the earlier conversation]\n" + summary
+	quoted, _ := json.Marshal(text)
+	if path == "contents" || path == "request.contents" {
+		return []string{
+			`{"role":"user","parts":[{"text":` + string(quoted) + `}]}`,
+			`{"role":"model","parts":[{"text":"Understood."}]}`,
+		}
+	}
+	return []string{
+		`{"role":"user","content":` + string(quoted) + `}`,
+		`{"role":"assistant","content":"Understood."}`,
+	}
+}
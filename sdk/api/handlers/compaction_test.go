@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestSummaryReplacesOlderTurns(t *testing.T) {
+	raw := []byte(`{"contents":[` +
+		`{"role":"user","parts":[{"text":"one"}]},` +
+		`{"role":"model","parts":[{"functionCall":{"name":"ls"}}]},` +
+		`{"role":"user","parts":[{"functionResponse":{"name":"ls"}}]},` +
+		`{"role":"model","parts":[{"text":"done"}]},` +
+		`{"role":"user","parts":[{"text":"two"}]}]}`)
+	history, ok := parseTurnHistory(raw)
+	if !ok {
+		t.Fatal("expected a turn history")
+	}
+	// Keeping two messages would start at a function response; the cut must move to "two".
+	cut := history.userTurnAt(len(history.conversation) - 2)
+	if cut != 4 {
+		t.Fatalf("cut = %d, want 4", cut)
+	}
+	out, err := history.replace(raw, cut, summaryTurns(history.path, "the user listed files")...)
+	if err != nil {
+		t.Fatalf("replace() error = %v", err)
+	}
+	contents := gjson.GetBytes(out, "contents").Array()
+	if len(contents) != 3 {
+		t.Fatalf("expected summary, acknowledgement and last turn, got %s", gjson.GetBytes(out, "contents").Raw)
+	}
+	if contents[1].Get("role").String() != "model" || contents[2].Get("parts.0.text").String() != "two" {
+		t.Fatalf("unexpected compacted history: %s", gjson.GetBytes(out, "contents").Raw)
+	}
+}
+
+func TestContextWindowPrefersConfig(t *testing.T) {
+	cfg := config.CompactionConfig{ContextWindows: map[string]int{"local-*": 8192}}
+	if got := contextWindow(cfg, "local-llama"); got != 8192 {
+		t.Fatalf("contextWindow() = %d, want 8192", got)
+	}
+	if got := contextWindow(cfg, "unknown-model"); got != 0 {
+		t.Fatalf("contextWindow() = %d, want 0 for unknown models", got)
+	}
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pii"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ContextWarningHeader describes how a request that overflowed the context window was recovered.
+const ContextWarningHeader = "X-CLIProxy-Context-Warning"
+
+const (
+	defaultKeepRecentMessages = 4
+	defaultOverflowRetries    = 3
+)
+
+// contextOverflowMarkers are lower-cased fragments of upstream context-length errors.
+var contextOverflowMarkers = []string{
+	"context_length_exceeded",
+	"context length",
+	"context window",
+	"maximum context",
+	"prompt is too long",
+	"input is too long",
+	"too many tokens",
+	"input token count",
+	"exceeds the maximum number of tokens",
+	"reduce the length of the messages",
+}
+
+// conversationPaths locate the turn arrays of OpenAI, Claude, Gemini, Gemini CLI and Responses requests.
+var conversationPaths = []string{"messages", "contents", "request.contents", "input"}
+
+// isContextOverflow reports whether err is an upstream rejection for exceeding the context window.
+func isContextOverflow(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch statusFromError(err) {
+	case 0, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+	default:
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range contextOverflowMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// overflowRetry is the next attempt after a context overflow.
+type overflowRetry struct {
+	modelName string
+	rawJSON   []byte
+	warning   string
+}
+
+// nextOverflowRetry decides how to retry a request that overflowed the context window: first by
+// switching to a configured larger-context model, then by dropping the oldest turns. It returns
+// false when recovery is disabled or exhausted.
+func (h *BaseAPIHandler) nextOverflowRetry(modelName string, rawJSON []byte, attempt int, err error) (overflowRetry, bool) {
+	if h.Cfg == nil || !h.Cfg.ContextOverflow.Enable || !isContextOverflow(err) {
+		return overflowRetry{}, false
+	}
+	cfg := h.Cfg.ContextOverflow
+	maxRetries := cfg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultOverflowRetries
+	}
+	if attempt >= maxRetries {
+		return overflowRetry{}, false
+	}
+	if target := matchContextFallback(cfg, modelName); target != "" && !strings.EqualFold(target, modelName) {
+		log.Infof("context overflow: retrying %s with %s", modelName, target)
+		return overflowRetry{
+			modelName: target,
+			rawJSON:   setPayloadModel(rawJSON, target),
+			warning:   fmt.Sprintf("context window of %s exceeded; served by %s", modelName, target),
+		}, true
+	}
+	if !cfg.Truncate {
+		return overflowRetry{}, false
+	}
+	keep := cfg.KeepRecentMessages
+	if keep <= 0 {
+		keep = defaultKeepRecentMessages
+	}
+	truncated, dropped := truncateOldestTurns(rawJSON, keep)
+	if dropped == 0 {
+		return overflowRetry{}, false
+	}
+	log.Infof("context overflow: dropping %d oldest messages for %s", dropped, modelName)
+	return overflowRetry{
+		modelName: modelName,
+		rawJSON:   truncated,
+		warning:   fmt.Sprintf("context window of %s exceeded; dropped %d oldest messages", modelName, dropped),
+	}, true
+}
+
+// matchContextFallback returns the fallback model configured for modelName.
+func matchContextFallback(cfg config.ContextOverflowConfig, modelName string) string {
+	for _, fallback := range cfg.Fallbacks {
+		target := strings.TrimSpace(fallback.TargetModel)
+		if target == "" {
+			continue
+		}
+		for _, pattern := range fallback.Models {
+			if util.MatchWildcard(strings.TrimSpace(pattern), modelName) {
+				return target
+			}
+		}
+	}
+	return ""
+}
+
+// setPayloadModel updates the model field of a request body that carries one.
+func setPayloadModel(rawJSON []byte, model string) []byte {
+	if !gjson.GetBytes(rawJSON, "model").Exists() {
+		return rawJSON
+	}
+	updated, err := sjson.SetBytes(rawJSON, "model", model)
+	if err != nil {
+		return rawJSON
+	}
+	return updated
+}
+
+// truncateOldestTurns drops the oldest half of the droppable turns. System and developer
+// messages and the last keep messages are preserved, and the remaining history is advanced to
+// the next plain user turn so tool calls are never separated from their results.
+func truncateOldestTurns(rawJSON []byte, keep int) ([]byte, int) {
+	history, ok := parseTurnHistory(rawJSON)
+	if !ok {
+		return rawJSON, 0
+	}
+	droppable := len(history.conversation) - keep
+	if droppable <= 0 {
+		return rawJSON, 0
+	}
+	cut := history.userTurnAt((droppable + 1) / 2)
+	if cut >= len(history.conversation) {
+		return rawJSON, 0
+	}
+	updated, err := history.replace(rawJSON, cut)
+	if err != nil {
+		return rawJSON, 0
+	}
+	return updated, cut
+}
+
+// turnHistory is the turn array of a request, with the positions of its non-system messages.
+type turnHistory struct {
+	path         string
+	items        []gjson.Result
+	conversation []int
+}
+
+// parseTurnHistory locates the turn array of an OpenAI, Claude, Gemini or Responses request.
+func parseTurnHistory(rawJSON []byte) (turnHistory, bool) {
+	for _, path := range conversationPaths {
+		turns := gjson.GetBytes(rawJSON, path)
+		if !turns.IsArray() {
+			continue
+		}
+		history := turnHistory{path: path, items: turns.Array()}
+		for i, item := range history.items {
+			switch item.Get("role").String() {
+			case "system", "developer":
+			default:
+				history.conversation = append(history.conversation, i)
+			}
+		}
+		return history, true
+	}
+	return turnHistory{}, false
+}
+
+// userTurnAt returns the first conversation position at or after cut that starts a plain user
+// turn, or len(conversation) when there is none.
+func (t turnHistory) userTurnAt(cut int) int {
+	for cut < len(t.conversation) && !isUserTurnStart(t.items[t.conversation[cut]]) {
+		cut++
+	}
+	return cut
+}
+
+// dropped returns the conversation messages before position cut.
+func (t turnHistory) dropped(cut int) []gjson.Result {
+	out := make([]gjson.Result, 0, cut)
+	for _, idx := range t.conversation[:cut] {
+		out = append(out, t.items[idx])
+	}
+	return out
+}
+
+// replace removes the conversation messages before position cut and puts the raw insert items
+// where the first of them stood.
+func (t turnHistory) replace(rawJSON []byte, cut int, insert ...string) ([]byte, error) {
+	dropped := make(map[int]struct{}, cut)
+	for _, idx := range t.conversation[:cut] {
+		dropped[idx] = struct{}{}
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	first := true
+	write := func(raw string) {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.WriteString(raw)
+	}
+	for i, item := range t.items {
+		if _, skip := dropped[i]; skip {
+			if cut > 0 && i == t.conversation[0] {
+				for _, raw := range insert {
+					write(raw)
+				}
+			}
+			continue
+		}
+		write(item.Raw)
+	}
+	buf.WriteByte(']')
+	return sjson.SetRawBytes(rawJSON, t.path, buf.Bytes())
+}
+
+// isUserTurnStart reports whether item is a user message that does not carry tool results.
+func isUserTurnStart(item gjson.Result) bool {
+	if item.Get("role").String() != "user" {
+		return false
+	}
+	carriesResult := false
+	for _, path := range []string{"content", "parts"} {
+		item.Get(path).ForEach(func(_, part gjson.Result) bool {
+			if part.Get("type").String() == "tool_result" || part.Get("functionResponse").Exists() {
+				carriesResult = true
+				return false
+			}
+			return true
+		})
+	}
+	return !carriesResult
+}
+
+// overflowRequest rebuilds the upstream request for a context overflow retry.
+func (h *BaseAPIHandler) overflowRequest(ctx context.Context, handlerType string, retry overflowRetry, alt string, stream bool) ([]string, coreexecutor.Request, coreexecutor.Options, *pii.Session, bool) {
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(retry.modelName)
+	if errMsg != nil {
+		return nil, coreexecutor.Request{}, coreexecutor.Options{}, nil, false
+	}
+	trace.Record(ctx, "fallback", retry.warning, map[string]any{"model": retry.modelName, "providers": providers})
+	upstreamJSON, redaction := h.redactPrompt(providers, retry.rawJSON)
+	req := coreexecutor.Request{Model: normalizedModel, Payload: cloneBytes(upstreamJSON), Metadata: cloneMetadata(metadata)}
+	opts := coreexecutor.Options{
+		Stream:          stream,
+		Alt:             alt,
+		OriginalRequest: cloneBytes(upstreamJSON),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+		Metadata:        mergeMetadata(cloneMetadata(metadata), requestExecutionMetadata(ctx)),
+	}
+	return providers, req, opts, redaction, true
+}
+
+// setContextWarning reports a context overflow recovery to the client.
+func setContextWarning(ctx context.Context, warning string) {
+	if warning == "" {
+		return
+	}
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+		ginCtx.Header(ContextWarningHeader, warning)
+	}
+}
+
+// addContextWarning adds the recovery warning to a non-streaming JSON response body.
+func addContextWarning(payload []byte, warning string) []byte {
+	if warning == "" || !gjson.ValidBytes(payload) || !gjson.ParseBytes(payload).IsObject() {
+		return payload
+	}
+	updated, err := sjson.SetBytes(payload, "cliproxy_warning", warning)
+	if err != nil {
+		return payload
+	}
+	return updated
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestTruncateOldestTurnsKeepsToolPairs(t *testing.T) {
+	raw := []byte(`{"messages":[` +
+		`{"role":"system","content":"sys"},` +
+		`{"role":"user","content":"one"},` +
+		`{"role":"assistant","content":"a1"},` +
+		`{"role":"user","content":"two"},` +
+		`{"role":"assistant","content":[{"type":"tool_use","id":"t1"}]},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1"}]},` +
+		`{"role":"assistant","content":"a2"},` +
+		`{"role":"user","content":"three"},` +
+		`{"role":"assistant","content":"a3"},` +
+		`{"role":"user","content":"four"}]}`)
+	out, dropped := truncateOldestTurns(raw, 2)
+	// Dropping half of the seven droppable turns lands on the tool_result, so the cut advances
+	// past the tool exchange to the next plain user turn.
+	if dropped != 6 {
+		t.Fatalf("dropped = %d, want 6", dropped)
+	}
+	messages := gjson.GetBytes(out, "messages").Array()
+	if len(messages) != 4 || messages[0].Get("role").String() != "system" || messages[1].Get("content").String() != "three" {
+		t.Fatalf("unexpected history: %s", gjson.GetBytes(out, "messages").Raw)
+	}
+	if _, n := truncateOldestTurns(out, 4); n != 0 {
+		t.Fatalf("expected nothing left to drop, dropped %d", n)
+	}
+}
+
+func TestNextOverflowRetryPrefersFallback(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{ContextOverflow: config.ContextOverflowConfig{
+		Enable:    true,
+		Fallbacks: []config.ContextFallback{{Models: []string{"small-*"}, TargetModel: "large"}},
+		Truncate:  true,
+	}}}
+	errOverflow := errors.New(`{"error":{"code":"context_length_exceeded","message":"This model's maximum context length is 8192 tokens"}}`)
+	raw := []byte(`{"model":"small-1","messages":[{"role":"user","content":"hi"}]}`)
+
+	retry, ok := h.nextOverflowRetry("small-1", raw, 0, errOverflow)
+	if !ok || retry.modelName != "large" || gjson.GetBytes(retry.rawJSON, "model").String() != "large" {
+		t.Fatalf("expected fallback to large, got %+v (ok=%v)", retry, ok)
+	}
+	if _, ok = h.nextOverflowRetry("large", retry.rawJSON, 1, errOverflow); ok {
+		t.Fatal("expected no retry when nothing can be truncated")
+	}
+	if _, ok = h.nextOverflowRetry("small-1", raw, 0, errors.New("rate limited")); ok {
+		t.Fatal("expected unrelated errors to be ignored")
+	}
+}
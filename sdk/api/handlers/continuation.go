@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/analytics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// StreamingContinuationRetries returns how many times a stream that fails after sending output may
+// be continued from its partial output.
+func StreamingContinuationRetries(cfg *config.SDKConfig) int {
+	if cfg == nil || cfg.Streaming.ContinuationRetries < 0 {
+		return 0
+	}
+	return cfg.Streaming.ContinuationRetries
+}
+
+// streamContinuation resumes streams that die mid-generation. It tracks the output forwarded to
+// the client; when the upstream fails, the request is re-issued with that output as an assistant
+// prefill and the new stream is spliced into the client's stream as if it never broke. Only text
+// output is continued: a stream that already produced tool calls fails as before.
+type streamContinuation struct {
+	handlerType string
+	retries     int
+	output      *analytics.Assembler
+
+	// Claude content blocks as seen by the client.
+	openType  string
+	openIndex int
+	nextIndex int
+
+	splice *claudeSplice
+	// trimLeading drops whitespace at the start of the continuation, since trailing whitespace is
+	// removed from the prefill.
+	trimLeading bool
+}
+
+// claudeSplice maps the content block indices of a continuation stream onto the client's stream.
+type claudeSplice struct {
+	textIndex int
+	started   bool
+	indices   map[int64]int
+}
+
+// newStreamContinuation returns nil when continuation is disabled or the format is unsupported.
+func newStreamContinuation(cfg *config.SDKConfig, handlerType string) *streamContinuation {
+	retries := StreamingContinuationRetries(cfg)
+	if retries == 0 {
+		return nil
+	}
+	switch handlerType {
+	case constant.Claude, constant.OpenAI, constant.Gemini, constant.GeminiCLI:
+	default:
+		return nil
+	}
+	return &streamContinuation{handlerType: handlerType, retries: retries, output: analytics.NewAssembler(), openIndex: -1}
+}
+
+// observe records a part forwarded to the client.
+func (c *streamContinuation) observe(part []byte) {
+	if c == nil {
+		return
+	}
+	c.output.Add(part)
+	if c.handlerType != constant.Claude {
+		return
+	}
+	for _, block := range sse.SplitBlocks(part) {
+		event := gjson.ParseBytes(block.Payload)
+		switch event.Get("type").String() {
+		case "content_block_start":
+			c.openIndex = int(event.Get("index").Int())
+			c.openType = event.Get("content_block.type").String()
+			if c.openIndex >= c.nextIndex {
+				c.nextIndex = c.openIndex + 1
+			}
+		case "content_block_stop":
+			if int(event.Get("index").Int()) == c.openIndex {
+				c.openIndex, c.openType = -1, ""
+			}
+		}
+	}
+}
+
+// next returns the request continuing the stream of rawJSON, or false when the stream cannot
+// be continued.
+func (c *streamContinuation) next(rawJSON []byte) ([]byte, bool) {
+	if c == nil || c.retries == 0 {
+		return nil, false
+	}
+	out := c.output.Output()
+	if len(out.ToolCalls) > 0 || (c.openIndex >= 0 && c.openType != "text") {
+		return nil, false
+	}
+	prefill := strings.TrimRightFunc(out.Text, unicode.IsSpace)
+	if prefill == "" {
+		return nil, false
+	}
+	continued, ok := continuationRequest(c.handlerType, rawJSON, prefill)
+	if !ok {
+		return nil, false
+	}
+	c.retries--
+	c.trimLeading = len(prefill) < len(out.Text)
+	if c.handlerType == constant.Claude {
+		c.splice = &claudeSplice{textIndex: c.openIndex, indices: make(map[int64]int)}
+	}
+	return continued, true
+}
+
+// continuationRequest appends prefill to the assistant turn that ends the request, adding the
+// turn when the request does not end with one.
+func continuationRequest(handlerType string, rawJSON []byte, prefill string) ([]byte, bool) {
+	root := gjson.ParseBytes(rawJSON)
+	var out []byte
+	var err error
+	switch handlerType {
+	case constant.Claude:
+		messages := root.Get("messages").Array()
+		if len(messages) == 0 {
+			return nil, false
+		}
+		message := map[string]any{"role": "assistant", "content": translator.ClaudePrefill(rawJSON) + prefill}
+		if messages[len(messages)-1].Get("role").String() == "assistant" {
+			out, err = sjson.SetBytes(rawJSON, "messages."+strconv.Itoa(len(messages)-1), message)
+		} else {
+			out, err = sjson.SetBytes(rawJSON, "messages.-1", message)
+		}
+		if err == nil {
+			// Extended thinking cannot be combined with an assistant prefill.
+			out, err = sjson.DeleteBytes(out, "thinking")
+		}
+	case constant.OpenAI:
+		messages := root.Get("messages").Array()
+		if len(messages) == 0 {
+			return nil, false
+		}
+		last := messages[len(messages)-1]
+		if last.Get("role").String() == "assistant" && last.Get("content").Type == gjson.String && !last.Get("tool_calls").Exists() {
+			out, err = sjson.SetBytes(rawJSON, "messages."+strconv.Itoa(len(messages)-1)+".content", last.Get("content").String()+prefill)
+		} else {
+			out, err = sjson.SetBytes(rawJSON, "messages.-1", map[string]any{"role": "assistant", "content": prefill})
+		}
+	case constant.Gemini, constant.GeminiCLI:
+		path := "contents"
+		if !root.Get(path).Exists() && root.Get("request.contents").Exists() {
+			path = "request.contents"
+		}
+		contents := root.Get(path).Array()
+		if len(contents) == 0 {
+			return nil, false
+		}
+		if contents[len(contents)-1].Get("role").String() == "model" {
+			out, err = sjson.SetBytes(rawJSON, path+"."+strconv.Itoa(len(contents)-1)+".parts.-1", map[string]any{"text": prefill})
+		} else {
+			out, err = sjson.SetBytes(rawJSON, path+".-1", map[string]any{"role": "model", "parts": []any{map[string]any{"text": prefill}}})
+		}
+	default:
+		return nil, false
+	}
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// rewrite adapts a chunk of a continuation stream so that it extends the client's stream.
+func (c *streamContinuation) rewrite(chunk []byte) []byte {
+	if c == nil || (c.splice == nil && !c.trimLeading) {
+		return chunk
+	}
+	var out []byte
+	for _, block := range sse.SplitBlocks(chunk) {
+		for _, part := range c.spliceBlock(block) {
+			out = append(out, c.trimBlock(part)...)
+		}
+	}
+	return out
+}
+
+// spliceBlock re-indexes a Claude event of the continuation stream. The new message's start is
+// dropped and its leading text block joins the text block the client already has open.
+func (c *streamContinuation) spliceBlock(block *sse.Block) [][]byte {
+	s := c.splice
+	if s == nil || len(block.Payload) == 0 {
+		return [][]byte{block.Bytes()}
+	}
+	event := gjson.ParseBytes(block.Payload)
+	index := event.Get("index")
+	switch event.Get("type").String() {
+	case "message_start", "ping":
+		return nil
+	case "content_block_start":
+		first := !s.started
+		s.started = true
+		if first && s.textIndex >= 0 {
+			if event.Get("content_block.type").String() == "text" {
+				s.indices[index.Int()] = s.textIndex
+				return nil
+			}
+			// The continuation opened with another block: close the interrupted text block first.
+			stop := []byte("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":" + strconv.Itoa(s.textIndex) + "}\n\n")
+			return [][]byte{stop, c.reindex(block, index.Int())}
+		}
+		return [][]byte{c.reindex(block, index.Int())}
+	}
+	if index.Exists() {
+		return [][]byte{c.reindex(block, index.Int())}
+	}
+	return [][]byte{block.Bytes()}
+}
+
+func (c *streamContinuation) reindex(block *sse.Block, index int64) []byte {
+	mapped, ok := c.splice.indices[index]
+	if !ok {
+		mapped = c.nextIndex
+		c.nextIndex++
+		c.splice.indices[index] = mapped
+	}
+	payload, err := sjson.SetBytes(block.Payload, "index", mapped)
+	if err != nil {
+		return block.Bytes()
+	}
+	return block.WithPayload(payload)
+}
+
+// trimBlock removes leading whitespace from the continuation's text until it produces any.
+func (c *streamContinuation) trimBlock(part []byte) []byte {
+	if !c.trimLeading {
+		return part
+	}
+	blocks := sse.SplitBlocks(part)
+	if len(blocks) != 1 {
+		return part
+	}
+	path := sse.TextPath(blocks[0].Payload)
+	if path == "" {
+		return part
+	}
+	text := gjson.GetBytes(blocks[0].Payload, path).String()
+	trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+	if trimmed != "" {
+		c.trimLeading = false
+	}
+	if trimmed == text {
+		return part
+	}
+	return blocks[0].WithText(path, trimmed)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func claudeEvent(event, data string) string {
+	return "event: " + event + "\ndata: " + data + "\n\n"
+}
+
+type resetMidStreamExecutor struct {
+	mu       sync.Mutex
+	payloads [][]byte
+}
+
+func (e *resetMidStreamExecutor) Identifier() string { return "codex" }
+
+func (e *resetMidStreamExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *resetMidStreamExecutor) ExecuteStream(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.mu.Lock()
+	e.payloads = append(e.payloads, req.Payload)
+	call := len(e.payloads)
+	e.mu.Unlock()
+
+	ch := make(chan coreexecutor.StreamChunk, 8)
+	ch <- coreexecutor.StreamChunk{Payload: []byte(claudeEvent("message_start", `{"type":"message_start","message":{"id":"msg_`+string(rune('0'+call))+`"}}`))}
+	ch <- coreexecutor.StreamChunk{Payload: []byte(claudeEvent("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`))}
+	if call == 1 {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(claudeEvent("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello "}}`))}
+		ch <- coreexecutor.StreamChunk{Err: errors.New("connection reset by peer")}
+	} else {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(claudeEvent("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`))}
+		ch <- coreexecutor.StreamChunk{Payload: []byte(claudeEvent("content_block_stop", `{"type":"content_block_stop","index":0}`))}
+		ch <- coreexecutor.StreamChunk{Payload: []byte(claudeEvent("content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`))}
+		ch <- coreexecutor.StreamChunk{Payload: []byte(claudeEvent("message_stop", `{"type":"message_stop"}`))}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (e *resetMidStreamExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *resetMidStreamExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not implemented")
+}
+
+func TestExecuteStreamWithAuthManager_ContinuesAfterMidStreamReset(t *testing.T) {
+	executor := &resetMidStreamExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "continuation-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "continuation-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		Streaming: sdkconfig.StreamingConfig{ContinuationRetries: 1},
+	}, manager)
+	rawJSON := []byte(`{"model":"continuation-model","thinking":{"type":"enabled","budget_tokens":1024},"messages":[{"role":"user","content":"hi"}]}`)
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "claude", "continuation-model", rawJSON, "")
+
+	var got strings.Builder
+	for chunk := range dataChan {
+		got.Write(chunk)
+	}
+	for msg := range errChan {
+		if msg != nil {
+			t.Fatalf("unexpected error: %v", msg.Error)
+		}
+	}
+
+	out := got.String()
+	if n := strings.Count(out, "event: message_start"); n != 1 {
+		t.Fatalf("message_start count = %d, want 1:\n%s", n, out)
+	}
+	if n := strings.Count(out, `"type":"content_block_start","index":0`); n != 1 {
+		t.Fatalf("expected the text block to be opened once:\n%s", out)
+	}
+	if !strings.Contains(out, `"text":"world"`) {
+		t.Fatalf("expected the continuation's leading whitespace to be trimmed:\n%s", out)
+	}
+	if !strings.Contains(out, `"type":"content_block_start","index":1`) {
+		t.Fatalf("expected later blocks to follow the spliced text block:\n%s", out)
+	}
+
+	if len(executor.payloads) != 2 {
+		t.Fatalf("expected 2 upstream attempts, got %d", len(executor.payloads))
+	}
+	continued := gjson.ParseBytes(executor.payloads[1])
+	if last := continued.Get("messages.1"); last.Get("role").String() != "assistant" || last.Get("content").String() != "Hello" {
+		t.Fatalf("continuation prefill = %s", last.Raw)
+	}
+	if continued.Get("thinking").Exists() {
+		t.Fatalf("expected thinking to be removed from the continuation request")
+	}
+}
+
+func TestContinuationRequest(t *testing.T) {
+	out, ok := continuationRequest("claude", []byte(`{"messages":[{"role":"user","content":"hi"},{"role":"assistant","content":[{"type":"text","text":"Sure: "}]}]}`), "one")
+	if !ok || gjson.GetBytes(out, "messages.#").Int() != 2 || gjson.GetBytes(out, "messages.1.content").String() != "Sure: one" {
+		t.Fatalf("claude continuation = %s", out)
+	}
+
+	out, ok = continuationRequest("openai", []byte(`{"messages":[{"role":"user","content":"hi"}]}`), "one")
+	if !ok || gjson.GetBytes(out, "messages.1.role").String() != "assistant" || gjson.GetBytes(out, "messages.1.content").String() != "one" {
+		t.Fatalf("openai continuation = %s", out)
+	}
+
+	out, ok = continuationRequest("gemini-cli", []byte(`{"request":{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}}`), "one")
+	if !ok || gjson.GetBytes(out, "request.contents.1.role").String() != "model" || gjson.GetBytes(out, "request.contents.1.parts.0.text").String() != "one" {
+		t.Fatalf("gemini-cli continuation = %s", out)
+	}
+
+	if _, ok = continuationRequest("openai-response", []byte(`{"input":"hi"}`), "one"); ok {
+		t.Fatalf("expected responses requests not to be continued")
+	}
+}
+
+func TestStreamContinuation_SkipsToolCalls(t *testing.T) {
+	c := newStreamContinuation(&sdkconfig.SDKConfig{Streaming: sdkconfig.StreamingConfig{ContinuationRetries: 2}}, "openai")
+	c.observe([]byte(`{"choices":[{"delta":{"content":"Let me check."}}]}`))
+	c.observe([]byte(`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"lookup","arguments":"{"}}]}}]}`))
+	if _, ok := c.next([]byte(`{"messages":[{"role":"user","content":"hi"}]}`)); ok {
+		t.Fatalf("expected a stream with tool calls not to be continued")
+	}
+}
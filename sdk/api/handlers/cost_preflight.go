@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/guardrail"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+// Cost preflight response headers.
+const (
+	CostEstimateHeader  = "X-CLIProxy-Cost-Estimate"
+	CostDowngradeHeader = "X-CLIProxy-Cost-Downgrade"
+)
+
+// defaultPreflightOutputTokens is assumed for requests that do not cap their output.
+const defaultPreflightOutputTokens = 1024
+
+var (
+	preflightCodecOnce sync.Once
+	preflightCodec     tokenizer.Codec
+)
+
+// costEstimate is the preflight estimate of one request, returned to clients whose request
+// exceeds the cap so they can trim the context.
+type costEstimate struct {
+	Model          string  `json:"model"`
+	InputTokens    int64   `json:"input_tokens"`
+	OutputTokens   int64   `json:"output_tokens"`
+	CostUSD        float64 `json:"cost_usd"`
+	LimitUSD       float64 `json:"limit_usd"`
+	MaxInputTokens int64   `json:"max_input_tokens"`
+}
+
+// costExceededError is returned when the estimated cost of a request exceeds its cap.
+type costExceededError struct {
+	estimate costEstimate
+}
+
+func (e *costExceededError) Error() string {
+	payload, _ := json.Marshal(map[string]any{"error": map[string]any{
+		"message": fmt.Sprintf("Estimated request cost $%.4f exceeds the per-request limit of $%.4f; reduce the input to at most %d tokens or lower max_tokens",
+			e.estimate.CostUSD, e.estimate.LimitUSD, e.estimate.MaxInputTokens),
+		"type":     "invalid_request_error",
+		"code":     "request_cost_exceeded",
+		"estimate": e.estimate,
+	}})
+	return string(payload)
+}
+
+// preflightCost estimates the cost of the request from a local token count and the pricing table.
+// Requests above the cap are downgraded to a configured cheaper model when the action is
+// "downgrade" and one fits, and rejected otherwise. It returns the model to route. Requests for
+// models without a known price are not checked.
+func (h *BaseAPIHandler) preflightCost(ctx context.Context, modelName string, rawJSON []byte) (string, *interfaces.ErrorMessage) {
+	if h.Cfg == nil || !h.Cfg.CostPreflight.Enable || guardrail.Skipped(ctx) {
+		return modelName, nil
+	}
+	cfg := h.Cfg.CostPreflight
+	limit := cfg.MaxRequestUSD
+	if policy := h.keyPolicy(ctx, requestAPIKey(ctx)); policy != nil && policy.MaxRequestCostUSD > 0 {
+		limit = policy.MaxRequestCostUSD
+	}
+	input := countPromptTokens(rawJSON)
+	output := requestedOutputTokens(rawJSON, cfg.DefaultOutputTokens)
+	estimate, ok := estimateRequestCost(modelName, input, output, limit)
+	if !ok {
+		return modelName, nil
+	}
+	ginCtx := ginContextFrom(ctx)
+	if limit <= 0 || estimate.CostUSD <= limit {
+		if ginCtx != nil {
+			ginCtx.Header(CostEstimateHeader, formatUSD(estimate.CostUSD))
+		}
+		return modelName, nil
+	}
+	if strings.EqualFold(strings.TrimSpace(cfg.Action), "downgrade") {
+		if target, downgraded, ok := downgradeForCost(cfg, modelName, input, output, limit); ok {
+			if ginCtx != nil {
+				ginCtx.Header(CostEstimateHeader, formatUSD(downgraded.CostUSD))
+				ginCtx.Header(CostDowngradeHeader, modelName)
+			}
+			log.Debugf("cost preflight: downgrading %s ($%.4f) to %s ($%.4f)", modelName, estimate.CostUSD, target, downgraded.CostUSD)
+			trace.Record(ctx, "model.cost_downgrade", fmt.Sprintf("estimated cost $%.4f exceeds $%.4f, routed %s to %s", estimate.CostUSD, limit, modelName, target),
+				map[string]any{"from": modelName, "model": target, "estimate_usd": estimate.CostUSD, "downgraded_usd": downgraded.CostUSD, "limit_usd": limit})
+			return target, nil
+		}
+	}
+	if ginCtx != nil {
+		ginCtx.Header(CostEstimateHeader, formatUSD(estimate.CostUSD))
+	}
+	trace.Record(ctx, "cost.rejected", fmt.Sprintf("estimated cost $%.4f exceeds $%.4f", estimate.CostUSD, limit),
+		map[string]any{"model": modelName, "estimate_usd": estimate.CostUSD, "limit_usd": limit, "input_tokens": input, "output_tokens": output})
+	return modelName, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: &costExceededError{estimate: estimate}}
+}
+
+// downgradeForCost returns the first downgrade target for modelName whose estimate fits limit.
+func downgradeForCost(cfg config.CostPreflightConfig, modelName string, input, output int64, limit float64) (string, costEstimate, bool) {
+	for _, d := range cfg.Downgrades {
+		target := strings.TrimSpace(d.TargetModel)
+		if target == "" || strings.EqualFold(target, modelName) || !modelMatchesAny(d.Models, modelName) {
+			continue
+		}
+		if estimate, ok := estimateRequestCost(target, input, output, limit); ok && estimate.CostUSD <= limit {
+			return target, estimate, true
+		}
+	}
+	return "", costEstimate{}, false
+}
+
+// estimateRequestCost prices input and output tokens for model. MaxInputTokens is the input size
+// that would keep the request within limit at the same output size.
+func estimateRequestCost(model string, input, output int64, limit float64) (costEstimate, bool) {
+	cost, ok := pricing.Cost(model, coreusage.Detail{InputTokens: input, OutputTokens: output, TotalTokens: input + output})
+	if !ok {
+		return costEstimate{}, false
+	}
+	estimate := costEstimate{Model: model, InputTokens: input, OutputTokens: output, CostUSD: cost, LimitUSD: limit}
+	if price, okPrice := pricing.Lookup(model); okPrice && price.Input > 0 && limit > 0 {
+		if budget := limit*1e6 - float64(output)*price.Output; budget > 0 {
+			estimate.MaxInputTokens = int64(math.Floor(budget / price.Input))
+		}
+	}
+	return estimate, true
+}
+
+// countPromptTokens counts the prompt text and tool definitions of a request with the o200k
+// tokenizer, falling back to four characters per token.
+func countPromptTokens(rawJSON []byte) int64 {
+	texts := guardrail.ExtractText(rawJSON)
+	root := gjson.ParseBytes(rawJSON)
+	for _, path := range []string{"tools", "functions", "request.tools"} {
+		if v := root.Get(path); v.IsArray() {
+			texts = append(texts, v.Raw)
+		}
+	}
+	preflightCodecOnce.Do(func() {
+		codec, err := tokenizer.Get(tokenizer.O200kBase)
+		if err != nil {
+			log.Warnf("cost preflight: tokenizer unavailable, estimating by length: %v", err)
+			return
+		}
+		preflightCodec = codec
+	})
+	var total int64
+	for _, text := range texts {
+		if preflightCodec != nil {
+			if n, err := preflightCodec.Count(text); err == nil {
+				total += int64(n)
+				continue
+			}
+		}
+		total += int64(len(text) / 4)
+	}
+	return total
+}
+
+// requestedOutputTokens returns the output cap of the request, or fallback (default 1024).
+func requestedOutputTokens(rawJSON []byte, fallback int) int64 {
+	requested := firstExisting(gjson.ParseBytes(rawJSON), "max_tokens", "max_completion_tokens", "max_output_tokens",
+		"generationConfig.maxOutputTokens", "request.generationConfig.maxOutputTokens")
+	if n := requested.Int(); n > 0 {
+		return n
+	}
+	if fallback > 0 {
+		return int64(fallback)
+	}
+	return defaultPreflightOutputTokens
+}
+
+func formatUSD(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
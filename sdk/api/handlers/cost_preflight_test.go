@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestPreflightCostRejectsWithEstimate(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{CostPreflight: config.CostPreflightConfig{Enable: true, MaxRequestUSD: 0.01}}}
+	body := []byte(`{"model":"claude-opus-4","max_tokens":4000,"messages":[{"role":"user","content":"` + strings.Repeat("lorem ipsum ", 2000) + `"}]}`)
+	model, errMsg := h.preflightCost(context.Background(), "claude-opus-4", body)
+	if errMsg == nil {
+		t.Fatal("expected the request to be rejected")
+	}
+	if model != "claude-opus-4" || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected result: model=%s status=%d", model, errMsg.StatusCode)
+	}
+	var payload struct {
+		Error struct {
+			Code     string       `json:"code"`
+			Estimate costEstimate `json:"estimate"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(errMsg.Error.Error()), &payload); err != nil {
+		t.Fatalf("error payload is not JSON: %v", err)
+	}
+	estimate := payload.Error.Estimate
+	if payload.Error.Code != "request_cost_exceeded" || estimate.InputTokens < 2000 || estimate.OutputTokens != 4000 || estimate.CostUSD <= 0.01 {
+		t.Fatalf("unexpected estimate: %+v", payload.Error)
+	}
+	if estimate.MaxInputTokens != 0 {
+		t.Fatalf("output alone exceeds the limit, expected no input allowance, got %d", estimate.MaxInputTokens)
+	}
+}
+
+func TestPreflightCostDowngradesToFirstFittingModel(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{CostPreflight: config.CostPreflightConfig{
+		Enable:        true,
+		MaxRequestUSD: 0.1,
+		Action:        "downgrade",
+		Downgrades: []config.CostDowngrade{
+			{Models: []string{"claude-opus-*"}, TargetModel: "claude-sonnet-4"},
+			{Models: []string{"claude-*"}, TargetModel: "claude-haiku-4-5"},
+		},
+	}}}
+	body := []byte(`{"max_tokens":1000,"messages":[{"role":"user","content":"` + strings.Repeat("lorem ipsum ", 4000) + `"}]}`)
+	model, errMsg := h.preflightCost(context.Background(), "claude-opus-4", body)
+	if errMsg != nil {
+		t.Fatalf("expected a downgrade, got %v", errMsg.Error)
+	}
+	if model != "claude-sonnet-4" {
+		t.Fatalf("expected claude-sonnet-4, got %s", model)
+	}
+	if model, errMsg = h.preflightCost(context.Background(), "unpriced-model", body); errMsg != nil || model != "unpriced-model" {
+		t.Fatalf("models without a price must pass unchanged, got %s %v", model, errMsg)
+	}
+}
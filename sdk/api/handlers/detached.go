@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+// detachedEngine backs the gin contexts handed to background executions.
+var detachedEngine = gin.New()
+
+// discardResponseWriter accepts and drops everything a background execution writes.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func (w *discardResponseWriter) WriteHeader(int) {}
+
+// DetachedContext returns a context for executing work on behalf of c after the HTTP request has
+// completed, such as batch jobs. It carries the request's headers, request ID and gin keys (so
+// usage stays attributed to the client API key) but is not canceled when the request ends, and
+// anything written to its response writer is discarded.
+func (h *BaseAPIHandler) DetachedContext(handler interfaces.APIHandler, c *gin.Context, parent context.Context) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if c != nil && c.Request != nil && logging.GetRequestID(parent) == "" {
+		if requestID := logging.GetGinRequestID(c); requestID != "" {
+			parent = logging.WithRequestID(parent, requestID)
+		}
+	}
+	ctx, cancel := context.WithCancel(parent)
+	detached := gin.CreateTestContextOnly(&discardResponseWriter{}, detachedEngine)
+	if c != nil {
+		if c.Request != nil {
+			detached.Request = c.Request.Clone(ctx)
+		}
+		for key, value := range c.Keys {
+			detached.Set(key, value)
+		}
+	}
+	ctx = context.WithValue(ctx, "gin", detached)
+	ctx = context.WithValue(ctx, "handler", handler)
+	return ctx, cancel
+}
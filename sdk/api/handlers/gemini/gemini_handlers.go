@@ -211,6 +211,13 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 		h.handleStreamGenerateContent(c, action[0], rawJSON)
 	case "countTokens":
 		h.handleCountTokens(c, action[0], rawJSON)
+	default:
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("method %s is not supported; use generateContent, streamGenerateContent or countTokens", method),
+				Type:    "invalid_request_error",
+			},
+		})
 	}
 }
 
@@ -273,6 +280,9 @@ func (h *GeminiAPIHandler) handleStreamGenerateContent(c *gin.Context, modelName
 				// Closed without data
 				if alt == "" {
 					setSSEHeaders()
+				} else {
+					c.Header("Content-Type", "application/json")
+					_, _ = c.Writer.Write([]byte("[]"))
 				}
 				flusher.Flush()
 				cliCancel(nil)
@@ -282,14 +292,17 @@ func (h *GeminiAPIHandler) handleStreamGenerateContent(c *gin.Context, modelName
 			// Success! Set headers.
 			if alt == "" {
 				setSSEHeaders()
+			} else {
+				c.Header("Content-Type", "application/json")
 			}
 
-			// Write first chunk
+			// Write first chunk. Without SSE the chunks are streamed as the elements of a JSON array.
 			if alt == "" {
 				_, _ = c.Writer.Write([]byte("data: "))
 				_, _ = c.Writer.Write(chunk)
 				_, _ = c.Writer.Write([]byte("\n\n"))
 			} else {
+				_, _ = c.Writer.Write([]byte("["))
 				_, _ = c.Writer.Write(chunk)
 			}
 			flusher.Flush()
@@ -361,9 +374,15 @@ func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flus
 				_, _ = c.Writer.Write(chunk)
 				_, _ = c.Writer.Write([]byte("\n\n"))
 			} else {
+				_, _ = c.Writer.Write([]byte(",\r\n"))
 				_, _ = c.Writer.Write(chunk)
 			}
 		},
+		WriteDone: func() {
+			if alt != "" {
+				_, _ = c.Writer.Write([]byte("]"))
+			}
+		},
 		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
 			if errMsg == nil {
 				return
@@ -380,7 +399,9 @@ func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flus
 			if alt == "" {
 				_, _ = fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", string(body))
 			} else {
+				_, _ = c.Writer.Write([]byte(",\r\n"))
 				_, _ = c.Writer.Write(body)
+				_, _ = c.Writer.Write([]byte("]"))
 			}
 		},
 	})
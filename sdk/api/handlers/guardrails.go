@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/guardrail"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// GuardrailHeader reports annotate-policy verdicts to the client.
+const GuardrailHeader = "X-CLIProxy-Guardrail"
+
+// guardrailError is returned when a block policy flags content.
+type guardrailError struct {
+	policy     string
+	check      guardrail.Check
+	categories []string
+}
+
+func (e *guardrailError) Error() string {
+	message := fmt.Sprintf("The %s was blocked by content policy %q", e.check, e.policy)
+	if len(e.categories) > 0 {
+		message += " (" + strings.Join(e.categories, ", ") + ")"
+	}
+	payload, _ := json.Marshal(ErrorResponse{Error: ErrorDetail{
+		Message: message,
+		Type:    "invalid_request_error",
+		Code:    "content_policy_violation",
+	}})
+	return string(payload)
+}
+
+// ModerationInput builds moderator input for texts, wiring model-backed moderators to the
+// auth manager.
+func (h *BaseAPIHandler) ModerationInput(texts []string) guardrail.Input {
+	return guardrail.Input{Texts: texts, Complete: h.guardrailComplete}
+}
+
+// guardrailComplete sends a single-turn chat prompt through the proxy for model-backed moderators.
+func (h *BaseAPIHandler) guardrailComplete(ctx context.Context, model, prompt string) (string, error) {
+	body, _ := json.Marshal(map[string]any{
+		"model":    model,
+		"messages": []map[string]string{{"role": "user", "content": prompt}},
+	})
+	resp, errMsg := h.ExecuteWithAuthManager(guardrail.WithoutGuardrails(ctx), "openai", model, body, "")
+	if errMsg != nil {
+		if errMsg.Error != nil {
+			return "", errMsg.Error
+		}
+		return "", fmt.Errorf("moderation model returned status %d", errMsg.StatusCode)
+	}
+	return gjson.GetBytes(resp, "choices.0.message.content").String(), nil
+}
+
+// screenPrompt applies the prompt guardrail policies matching the inbound route and model.
+func (h *BaseAPIHandler) screenPrompt(ctx context.Context, modelName string, rawJSON []byte) *interfaces.ErrorMessage {
+	return h.screen(ctx, guardrail.CheckPrompt, modelName, rawJSON)
+}
+
+// screenResponse applies the response guardrail policies to a non-streaming client payload.
+// Streaming responses are not screened because their bytes reach the client as they arrive.
+func (h *BaseAPIHandler) screenResponse(ctx context.Context, modelName string, payload []byte) *interfaces.ErrorMessage {
+	return h.screen(ctx, guardrail.CheckResponse, modelName, payload)
+}
+
+func (h *BaseAPIHandler) screen(ctx context.Context, check guardrail.Check, modelName string, body []byte) *interfaces.ErrorMessage {
+	if h == nil || h.Cfg == nil || len(h.Cfg.Guardrails.Policies) == 0 || guardrail.Skipped(ctx) {
+		return nil
+	}
+	ginCtx := ginContextFrom(ctx)
+	if ginCtx == nil || ginCtx.Request == nil || ginCtx.Request.URL == nil {
+		return nil
+	}
+	policies := guardrail.MatchingPolicies(h.Cfg.Guardrails, check, ginCtx.Request.URL.Path, modelName)
+	if len(policies) == 0 {
+		return nil
+	}
+	texts := guardrail.ExtractText(body)
+	if len(texts) == 0 {
+		return nil
+	}
+	in := h.ModerationInput(texts)
+	for _, policy := range policies {
+		name := policyName(policy)
+		action := guardrail.PolicyAction(policy)
+		result, err := guardrail.Moderate(ctx, h.Cfg.Guardrails, policy.Moderators, in)
+		if err != nil {
+			log.Warnf("guardrail policy %q: %v", name, err)
+			if action == guardrail.ActionBlock {
+				return &interfaces.ErrorMessage{StatusCode: http.StatusServiceUnavailable, Error: fmt.Errorf("content policy %q is unavailable", name)}
+			}
+			continue
+		}
+		if !result.Flagged {
+			continue
+		}
+		categories := result.FlaggedCategories()
+		if action == guardrail.ActionAnnotate {
+			if ginCtx.Writer != nil {
+				ginCtx.Writer.Header().Add(GuardrailHeader, fmt.Sprintf("%s; check=%s; categories=%s", name, check, strings.Join(categories, ",")))
+			}
+			continue
+		}
+		ginCtx.Set("guardrailBlocked", name)
+		log.Infof("guardrail policy %q blocked %s for model %s (%s)", name, check, modelName, strings.Join(categories, ","))
+		return &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: &guardrailError{policy: name, check: check, categories: categories}}
+	}
+	return nil
+}
+
+func policyName(p config.GuardrailPolicy) string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return strings.Join(p.Moderators, "+")
+}
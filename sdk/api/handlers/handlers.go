@@ -6,8 +6,10 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -15,10 +17,13 @@ import (
 	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	"golang.org/x/net/context"
 )
@@ -48,6 +53,7 @@ const idempotencyKeyMetadataKey = "idempotency_key"
 const (
 	defaultStreamingKeepAliveSeconds = 0
 	defaultStreamingBootstrapRetries = 0
+	defaultStreamingCoalesceMaxBytes = 4096
 )
 
 // BuildErrorResponseBody builds an OpenAI-compatible JSON error response body.
@@ -113,6 +119,19 @@ func StreamingKeepAliveInterval(cfg *config.SDKConfig) time.Duration {
 	return time.Duration(seconds) * time.Second
 }
 
+// StreamingCoalesceWindow returns the flush interval and byte threshold for coalescing streamed
+// events. A zero interval disables coalescing (default when unset).
+func StreamingCoalesceWindow(cfg *config.SDKConfig) (time.Duration, int) {
+	if cfg == nil || cfg.Streaming.Coalesce.FlushIntervalMs <= 0 {
+		return 0, 0
+	}
+	maxBytes := cfg.Streaming.Coalesce.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultStreamingCoalesceMaxBytes
+	}
+	return time.Duration(cfg.Streaming.Coalesce.FlushIntervalMs) * time.Millisecond, maxBytes
+}
+
 // StreamingBootstrapRetries returns how many times a streaming request may be retried before any bytes are sent.
 func StreamingBootstrapRetries(cfg *config.SDKConfig) int {
 	retries := defaultStreamingBootstrapRetries
@@ -163,6 +182,12 @@ type BaseAPIHandler struct {
 
 	// Cfg holds the current application configuration.
 	Cfg *config.SDKConfig
+
+	// responseCache holds the lazily built response cache backend.
+	responseCache *responseCacheState
+
+	// semanticCache holds the lazily built semantic cache and embedder.
+	semanticCache *semanticCacheState
 }
 
 // NewBaseAPIHandlers creates a new API handlers instance.
@@ -176,8 +201,10 @@ type BaseAPIHandler struct {
 //   - *BaseAPIHandler: A new API handlers instance
 func NewBaseAPIHandlers(cfg *config.SDKConfig, authManager *coreauth.Manager) *BaseAPIHandler {
 	return &BaseAPIHandler{
-		Cfg:         cfg,
-		AuthManager: authManager,
+		Cfg:           cfg,
+		AuthManager:   authManager,
+		responseCache: &responseCacheState{},
+		semanticCache: &semanticCacheState{},
 	}
 }
 
@@ -240,13 +267,27 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 			parentCtx = logging.WithRequestID(parentCtx, requestID)
 		}
 	}
+	if requestCtx != nil && coreusage.TallyFromContext(parentCtx) == nil {
+		if tally := coreusage.TallyFromContext(requestCtx); tally != nil {
+			parentCtx = coreusage.WithTally(parentCtx, tally)
+		}
+	}
+	if requestCtx != nil && trace.FromContext(parentCtx) == nil {
+		parentCtx = trace.WithTrace(parentCtx, trace.FromContext(requestCtx))
+	}
+	if requestCtx != nil && ratelimit.FromContext(parentCtx) == nil {
+		if state := ratelimit.FromContext(requestCtx); state != nil {
+			parentCtx = ratelimit.WithState(parentCtx, state)
+		}
+	}
 	newCtx, cancel := context.WithCancel(parentCtx)
 	if requestCtx != nil && requestCtx != parentCtx {
+		done := newCtx.Done()
 		go func() {
 			select {
 			case <-requestCtx.Done():
 				cancel()
-			case <-newCtx.Done():
+			case <-done:
 			}
 		}()
 	}
@@ -318,14 +359,58 @@ func appendAPIResponse(c *gin.Context, data []byte) {
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	requestedModel := modelName
+	ctx = h.withRequestPriority(ctx)
+	ctx = h.withQueueKey(ctx)
+	ctx, modelName, overrides, errMsg := h.applyRequestOverrides(ctx, modelName, rawJSON)
+	ctx = h.withSessionAffinity(ctx, rawJSON)
+	if errMsg == nil {
+		errMsg = h.enforceKeyPolicy(ctx, modelName, rawJSON, false)
+	}
+	if errMsg == nil {
+		errMsg = enforceSpendBudget(ctx)
+	}
+	if errMsg == nil {
+		modelName, errMsg = h.preflightCost(ctx, modelName, rawJSON)
+	}
+	if errMsg != nil {
+		return nil, errMsg
+	}
 	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	if errMsg == nil {
+		providers, errMsg = overrides.filterProviders(modelName, providers)
+	}
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	traceRoute(ctx, requestedModel, modelName, normalizedModel, providers)
+	rawJSON = h.applyPreTranslateTransforms(ctx, handlerType, modelName, rawJSON, false)
+	rawJSON = h.compactConversation(ctx, modelName, rawJSON)
+	providers, errMsg = filterLogprobsProviders(handlerType, modelName, rawJSON, providers)
+	if errMsg == nil {
+		providers, errMsg = filterBetaProviders(ctx, handlerType, modelName, rawJSON, providers)
+	}
+	if errMsg == nil {
+		errMsg = h.screenPrompt(ctx, modelName, rawJSON)
+	}
 	if errMsg != nil {
 		return nil, errMsg
 	}
+	cached, cacheKey, cacheStore := h.lookupResponseCache(ctx, handlerType, modelName, rawJSON)
+	if cached != nil {
+		trace.Record(ctx, "cache.hit", "served from the response cache", nil)
+		return cached, nil
+	}
+	cached, semantic := h.lookupSemanticCache(ctx, handlerType, modelName, rawJSON)
+	if cached != nil {
+		trace.Record(ctx, "cache.hit", "served from the semantic cache", nil)
+		return cached, nil
+	}
+	upstreamJSON, redaction := h.redactPrompt(providers, rawJSON)
 	reqMeta := requestExecutionMetadata(ctx)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
-		Payload: cloneBytes(rawJSON),
+		Payload: cloneBytes(upstreamJSON),
 	}
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		req.Metadata = cloned
@@ -333,12 +418,30 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 	opts := coreexecutor.Options{
 		Stream:          false,
 		Alt:             alt,
-		OriginalRequest: cloneBytes(rawJSON),
+		OriginalRequest: cloneBytes(upstreamJSON),
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
+	shadow := h.startShadow(handlerType, modelName, rawJSON, alt, false)
+	capture := h.startCapture(ctx, handlerType, modelName, rawJSON, false)
 	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	warning := ""
+	for attempt := 0; err != nil; attempt++ {
+		retry, ok := h.nextOverflowRetry(modelName, rawJSON, attempt, err)
+		if !ok {
+			break
+		}
+		retryProviders, retryReq, retryOpts, retryRedaction, ok := h.overflowRequest(ctx, handlerType, retry, alt, false)
+		if !ok {
+			break
+		}
+		modelName, rawJSON, warning, redaction = retry.modelName, retry.rawJSON, retry.warning, retryRedaction
+		resp, err = h.AuthManager.Execute(ctx, retryProviders, retryReq, retryOpts)
+	}
 	if err != nil {
+		err = upstreamError(err)
+		shadow.finish(err)
+		capture.finish(nil, err)
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
 			if code := se.StatusCode(); code > 0 {
@@ -353,16 +456,51 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		}
 		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
-	return cloneBytes(resp.Payload), nil
+	payload := h.applyResponseTransforms(ctx, handlerType, modelName, redaction.Restore(cloneBytes(resp.Payload)), false)
+	shadow.observe(payload)
+	shadow.finish(nil)
+	if errMsg = h.screenResponse(ctx, modelName, payload); errMsg != nil {
+		capture.finish(payload, errMsg.Error)
+		return nil, errMsg
+	}
+	if payload, errMsg = h.filterResponse(ctx, modelName, payload); errMsg != nil {
+		capture.finish(nil, errMsg.Error)
+		return nil, errMsg
+	}
+	capture.finish(payload, nil)
+	if warning != "" {
+		setContextWarning(ctx, warning)
+		return addContextWarning(payload, warning), nil
+	}
+	h.storeResponseCache(ctx, cacheStore, cacheKey, payload)
+	h.storeSemanticCache(semantic, payload)
+	return payload, nil
 }
 
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	requestedModel := modelName
+	ctx, modelName, overrides, errMsg := h.applyRequestOverrides(ctx, modelName, rawJSON)
+	ctx = h.withSessionAffinity(ctx, rawJSON)
+	if errMsg == nil {
+		errMsg = h.enforceKeyModelPolicy(ctx, modelName)
+	}
+	if errMsg != nil {
+		return nil, errMsg
+	}
 	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	if errMsg == nil {
+		providers, errMsg = overrides.filterProviders(modelName, providers)
+	}
+	if errMsg == nil {
+		providers, errMsg = filterBetaProviders(ctx, handlerType, modelName, rawJSON, providers)
+	}
 	if errMsg != nil {
 		return nil, errMsg
 	}
+	traceRoute(ctx, requestedModel, modelName, normalizedModel, providers)
+	rawJSON, _ = h.redactPrompt(providers, rawJSON)
 	reqMeta := requestExecutionMetadata(ctx)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
@@ -380,6 +518,7 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
 	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
 	if err != nil {
+		err = upstreamError(err)
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
 			if code := se.StatusCode(); code > 0 {
@@ -400,17 +539,52 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	requestedModel := modelName
+	ctx = h.withRequestPriority(ctx)
+	ctx = h.withQueueKey(ctx)
+	ctx, modelName, overrides, errMsg := h.applyRequestOverrides(ctx, modelName, rawJSON)
+	ctx = h.withSessionAffinity(ctx, rawJSON)
+	if errMsg == nil {
+		errMsg = h.enforceKeyPolicy(ctx, modelName, rawJSON, true)
+	}
+	if errMsg == nil {
+		errMsg = enforceSpendBudget(ctx)
+	}
+	if errMsg == nil {
+		modelName, errMsg = h.preflightCost(ctx, modelName, rawJSON)
+	}
+	var providers []string
+	var normalizedModel string
+	var metadata map[string]any
+	if errMsg == nil {
+		providers, normalizedModel, metadata, errMsg = h.getRequestDetails(modelName)
+	}
+	if errMsg == nil {
+		providers, errMsg = overrides.filterProviders(modelName, providers)
+	}
+	if errMsg == nil {
+		traceRoute(ctx, requestedModel, modelName, normalizedModel, providers)
+		rawJSON = h.applyPreTranslateTransforms(ctx, handlerType, modelName, rawJSON, true)
+		rawJSON = h.compactConversation(ctx, modelName, rawJSON)
+		providers, errMsg = filterLogprobsProviders(handlerType, modelName, rawJSON, providers)
+	}
+	if errMsg == nil {
+		providers, errMsg = filterBetaProviders(ctx, handlerType, modelName, rawJSON, providers)
+	}
+	if errMsg == nil {
+		errMsg = h.screenPrompt(ctx, modelName, rawJSON)
+	}
 	if errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		errChan <- errMsg
 		close(errChan)
 		return nil, errChan
 	}
+	upstreamJSON, redaction := h.redactPrompt(providers, rawJSON)
 	reqMeta := requestExecutionMetadata(ctx)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
-		Payload: cloneBytes(rawJSON),
+		Payload: cloneBytes(upstreamJSON),
 	}
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		req.Metadata = cloned
@@ -418,12 +592,19 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	opts := coreexecutor.Options{
 		Stream:          true,
 		Alt:             alt,
-		OriginalRequest: cloneBytes(rawJSON),
+		OriginalRequest: cloneBytes(upstreamJSON),
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
+	ctx, stream := trackStream(ctx, handlerType, modelName, providers)
+	shadow := h.startShadow(handlerType, modelName, rawJSON, alt, true)
+	capture := h.startCapture(ctx, handlerType, modelName, rawJSON, true)
 	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
 	if err != nil {
+		err = upstreamError(err)
+		stream.Finish()
+		shadow.finish(err)
+		capture.finish(nil, err)
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -446,8 +627,25 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	go func() {
 		defer close(dataChan)
 		defer close(errChan)
+		defer stream.Finish()
+		var shadowErr error
+		defer func() {
+			shadow.finish(shadowErr)
+			capture.finish(nil, shadowErr)
+		}()
+		filtered := h.newResponseFilterStream()
+		pacer := newStreamPacer(h.Cfg)
+		continuation := newStreamContinuation(h.Cfg, handlerType)
+		send := func(part []byte) {
+			stream.Observe(len(part))
+			shadow.observe(part)
+			capture.observe(part)
+			continuation.observe(part)
+			dataChan <- part
+		}
 		sentPayload := false
 		bootstrapRetries := 0
+		overflowRetries := 0
 		maxBootstrapRetries := StreamingBootstrapRetries(h.Cfg)
 
 		bootstrapEligible := func(err error) bool {
@@ -472,6 +670,8 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 				if ctx != nil {
 					select {
 					case <-ctx.Done():
+						shadowErr = ctx.Err()
+						notifyStreamAborted(ctx, stream, "canceled", shadowErr)
 						return
 					case chunk, ok = <-chunks:
 					}
@@ -479,6 +679,9 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					chunk, ok = <-chunks
 				}
 				if !ok {
+					if tail := filtered.Flush(); len(tail) > 0 {
+						pacer.pace(ctx, tail, send)
+					}
 					return
 				}
 				if chunk.Err != nil {
@@ -486,8 +689,24 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					// Safe bootstrap recovery: if the upstream fails before any payload bytes are sent,
 					// retry a few times (to allow auth rotation / transient recovery) and then attempt model fallback.
 					if !sentPayload {
+						if retry, ok := h.nextOverflowRetry(modelName, rawJSON, overflowRetries, streamErr); ok {
+							overflowRetries++
+							if retryProviders, retryReq, retryOpts, retryRedaction, okReq := h.overflowRequest(ctx, handlerType, retry, alt, true); okReq {
+								retryChunks, retryErr := h.AuthManager.ExecuteStream(ctx, retryProviders, retryReq, retryOpts)
+								if retryErr == nil {
+									modelName, rawJSON, redaction = retry.modelName, retry.rawJSON, retryRedaction
+									providers, req, opts = retryProviders, retryReq, retryOpts
+									setContextWarning(ctx, retry.warning)
+									chunks = retryChunks
+									continue outer
+								}
+								streamErr = retryErr
+							}
+						}
 						if bootstrapRetries < maxBootstrapRetries && bootstrapEligible(streamErr) {
 							bootstrapRetries++
+							trace.Record(ctx, "retry", fmt.Sprintf("stream failed before any output; bootstrap retry %d", bootstrapRetries),
+								map[string]any{"error": streamErr.Error()})
 							retryChunks, retryErr := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
 							if retryErr == nil {
 								chunks = retryChunks
@@ -496,7 +715,26 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 							streamErr = retryErr
 						}
 					}
+					// Mid-stream recovery: continue the generation from the output the client already has.
+					if sentPayload && bootstrapEligible(streamErr) {
+						if continuedJSON, ok := continuation.next(rawJSON); ok {
+							trace.Record(ctx, "retry", "stream failed after output; continuing from partial output",
+								map[string]any{"error": streamErr.Error()})
+							upstreamJSON, continuedRedaction := h.redactPrompt(providers, continuedJSON)
+							continuedReq, continuedOpts := req, opts
+							continuedReq.Payload = cloneBytes(upstreamJSON)
+							continuedOpts.OriginalRequest = cloneBytes(upstreamJSON)
+							retryChunks, retryErr := h.AuthManager.ExecuteStream(ctx, providers, continuedReq, continuedOpts)
+							if retryErr == nil {
+								redaction = continuedRedaction
+								chunks = retryChunks
+								continue outer
+							}
+							streamErr = retryErr
+						}
+					}
 
+					streamErr = upstreamError(streamErr)
 					status := http.StatusInternalServerError
 					if se, ok := streamErr.(interface{ StatusCode() int }); ok && se != nil {
 						if code := se.StatusCode(); code > 0 {
@@ -509,12 +747,35 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 							addon = hdr.Clone()
 						}
 					}
+					shadowErr = streamErr
+					if sentPayload {
+						notifyStreamAborted(ctx, stream, "upstream_error", streamErr)
+					}
 					errChan <- &interfaces.ErrorMessage{StatusCode: status, Error: streamErr, Addon: addon}
 					return
 				}
 				if len(chunk.Payload) > 0 {
 					sentPayload = true
-					dataChan <- cloneBytes(chunk.Payload)
+					out := h.applyResponseTransforms(ctx, handlerType, modelName, redaction.Restore(cloneBytes(chunk.Payload)), true)
+					out = continuation.rewrite(out)
+					if len(out) == 0 {
+						continue
+					}
+					parts, blocked := filtered.Process(out)
+					if blocked != "" {
+						errMsg := responseFilterBlocked(ctx, modelName, blocked)
+						shadowErr = errMsg.Error
+						notifyStreamAborted(ctx, stream, "content_filtered", shadowErr)
+						errChan <- errMsg
+						return
+					}
+					for _, part := range parts {
+						if !pacer.pace(ctx, part, send) {
+							shadowErr = ctx.Err()
+							notifyStreamAborted(ctx, stream, "canceled", shadowErr)
+							return
+						}
+					}
 				}
 			}
 		}
@@ -522,6 +783,18 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	return dataChan, errChan
 }
 
+// upstreamError unwraps transport errors that carry their own status and error body, such as
+// upstream phase timeouts, from the *url.Error the HTTP client wraps them in.
+func upstreamError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if _, ok := urlErr.Err.(interface{ StatusCode() int }); ok {
+			return urlErr.Err
+		}
+	}
+	return err
+}
+
 func statusFromError(err error) int {
 	if err == nil {
 		return 0
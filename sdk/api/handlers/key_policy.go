@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/state"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/guardrail"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// keyUsageRetention keeps persisted monthly token counters a little longer than a month.
+const keyUsageRetention = 62 * 24 * time.Hour
+
+// keyPolicyError is returned when a request violates the client key's api-key-policies entry.
+type keyPolicyError struct {
+	code    string
+	message string
+}
+
+func (e *keyPolicyError) Error() string {
+	payload, _ := json.Marshal(ErrorResponse{Error: ErrorDetail{
+		Message: e.message,
+		Type:    "permission_error",
+		Code:    e.code,
+	}})
+	return string(payload)
+}
+
+func keyPolicyDenied(code, format string, args ...any) *interfaces.ErrorMessage {
+	return &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: &keyPolicyError{code: code, message: fmt.Sprintf(format, args...)}}
+}
+
+// requestAPIKey returns the authenticated client key of the request.
+func requestAPIKey(ctx context.Context) string {
+	ginCtx := ginContextFrom(ctx)
+	if ginCtx == nil {
+		return ""
+	}
+	v, _ := ginCtx.Get("apiKey")
+	key, _ := v.(string)
+	return key
+}
+
+// keyPolicy returns the first policy listing the client key. Requests the proxy issues on its
+// own behalf, such as moderation and compaction calls, are exempt.
+func (h *BaseAPIHandler) keyPolicy(ctx context.Context, key string) *config.APIKeyPolicy {
+	if h == nil || h.Cfg == nil || key == "" || guardrail.Skipped(ctx) {
+		return nil
+	}
+	for i := range h.Cfg.APIKeyPolicies {
+		policy := &h.Cfg.APIKeyPolicies[i]
+		for _, k := range policy.APIKeys {
+			if k == "*" || k == key {
+				return policy
+			}
+		}
+	}
+	return nil
+}
+
+// enforceKeyModelPolicy rejects models outside the client key's allowlist.
+func (h *BaseAPIHandler) enforceKeyModelPolicy(ctx context.Context, modelName string) *interfaces.ErrorMessage {
+	if keyPolicyAllowsModel(h.keyPolicy(ctx, requestAPIKey(ctx)), modelName) {
+		return nil
+	}
+	return keyPolicyDenied("model_not_allowed", "Model %s is not allowed for this API key", modelName)
+}
+
+// KeyAllowsModel reports whether the request's client key may use the model, so model listings
+// only show what the key can call.
+func (h *BaseAPIHandler) KeyAllowsModel(c *gin.Context, modelName string) bool {
+	if c == nil {
+		return true
+	}
+	key, _ := c.Get("apiKey")
+	apiKey, _ := key.(string)
+	return keyPolicyAllowsModel(h.keyPolicy(c.Request.Context(), apiKey), modelName)
+}
+
+func keyPolicyAllowsModel(policy *config.APIKeyPolicy, modelName string) bool {
+	if policy == nil || len(policy.Models) == 0 {
+		return true
+	}
+	for _, pattern := range policy.Models {
+		if util.MatchWildcard(strings.TrimSpace(pattern), modelName) {
+			return true
+		}
+	}
+	return false
+}
+
+// enforceKeyPolicy checks the request against the client key's policy before it is translated:
+// allowed models, requested output tokens, tools, image input, streaming and the monthly budget.
+func (h *BaseAPIHandler) enforceKeyPolicy(ctx context.Context, modelName string, rawJSON []byte, stream bool) *interfaces.ErrorMessage {
+	key := requestAPIKey(ctx)
+	policy := h.keyPolicy(ctx, key)
+	if policy == nil {
+		return nil
+	}
+	if errMsg := h.enforceKeyModelPolicy(ctx, modelName); errMsg != nil {
+		return errMsg
+	}
+	root := gjson.ParseBytes(rawJSON)
+	if policy.MaxTokens > 0 {
+		requested := firstExisting(root, "max_tokens", "max_completion_tokens", "max_output_tokens",
+			"generationConfig.maxOutputTokens", "request.generationConfig.maxOutputTokens")
+		if requested.Exists() && requested.Int() > int64(policy.MaxTokens) {
+			return keyPolicyDenied("max_tokens_exceeded", "Requested %d output tokens; this API key allows at most %d", requested.Int(), policy.MaxTokens)
+		}
+	}
+	if len(policy.Capabilities) > 0 {
+		if stream && !keyCapabilityAllowed(policy, "streaming") {
+			return keyPolicyDenied("streaming_not_allowed", "Streaming is not allowed for this API key")
+		}
+		if requestUsesTools(root) && !keyCapabilityAllowed(policy, "tools") {
+			return keyPolicyDenied("tools_not_allowed", "Tools are not allowed for this API key")
+		}
+		if requestHasImages(root) && !keyCapabilityAllowed(policy, "vision") {
+			return keyPolicyDenied("vision_not_allowed", "Image input is not allowed for this API key")
+		}
+	}
+	if policy.MonthlyTokenBudget > 0 {
+		now := time.Now()
+		used := defaultKeyUsage.used(ctx, key, now)
+		month := now.UTC()
+		ratelimit.FromContext(ctx).Limit(ratelimit.Tokens, ratelimit.Window{
+			Limit:     policy.MonthlyTokenBudget,
+			Remaining: policy.MonthlyTokenBudget - used,
+			Reset:     time.Date(month.Year(), month.Month()+1, 1, 0, 0, 0, 0, time.UTC),
+		})
+		if used >= policy.MonthlyTokenBudget {
+			return keyPolicyDenied("token_budget_exceeded", "This API key used %d of its %d monthly tokens", used, policy.MonthlyTokenBudget)
+		}
+	}
+	return nil
+}
+
+func keyCapabilityAllowed(policy *config.APIKeyPolicy, capability string) bool {
+	for _, c := range policy.Capabilities {
+		if strings.EqualFold(strings.TrimSpace(c), capability) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestUsesTools reports whether an OpenAI, Claude, Responses or Gemini request declares tools.
+func requestUsesTools(root gjson.Result) bool {
+	for _, path := range []string{"tools", "functions", "request.tools"} {
+		if v := root.Get(path); v.IsArray() && len(v.Array()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// requestHasImages reports whether any message of an OpenAI, Claude, Responses or Gemini request
+// carries image input.
+func requestHasImages(root gjson.Result) bool {
+	for _, path := range []string{"messages.#.content", "input.#.content"} {
+		for _, content := range root.Get(path).Array() {
+			for _, part := range content.Array() {
+				switch part.Get("type").String() {
+				case "image_url", "image", "input_image":
+					return true
+				}
+			}
+		}
+	}
+	for _, path := range []string{"contents.#.parts", "request.contents.#.parts"} {
+		for _, parts := range root.Get(path).Array() {
+			for _, part := range parts.Array() {
+				mime := firstExisting(part, "inlineData.mimeType", "inline_data.mime_type", "fileData.mimeType", "file_data.mime_type").String()
+				if strings.HasPrefix(strings.ToLower(mime), "image/") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// keyUsageTracker counts the tokens used by each client key in the current UTC month. Counters
+// are persisted to the state store when one is configured so budgets survive restarts.
+type keyUsageTracker struct {
+	mu      sync.Mutex
+	entries map[string]*keyUsageEntry
+}
+
+type keyUsageEntry struct {
+	tokens int64
+	loaded bool
+}
+
+var defaultKeyUsage = &keyUsageTracker{entries: make(map[string]*keyUsageEntry)}
+
+func init() {
+	coreusage.RegisterPlugin(defaultKeyUsage)
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (t *keyUsageTracker) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if record.APIKey == "" {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens <= 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	if tokens <= 0 {
+		return
+	}
+	at := record.RequestedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	// The request may already be finished; counters must still be persisted.
+	t.add(context.WithoutCancel(ctx), record.APIKey, at, tokens)
+}
+
+func keyUsageID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// used returns the tokens the key used in the month containing now.
+func (t *keyUsageTracker) used(ctx context.Context, key string, now time.Time) int64 {
+	id, month := keyUsageID(key), now.UTC().Format("2006-01")
+	t.mu.Lock()
+	if e := t.entries[id+":"+month]; e != nil && e.loaded {
+		tokens := e.tokens
+		t.mu.Unlock()
+		return tokens
+	}
+	t.mu.Unlock()
+
+	persisted := loadKeyUsage(ctx, id, month)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.entry(id, month)
+	if !e.loaded {
+		e.tokens += persisted
+		e.loaded = true
+	}
+	return e.tokens
+}
+
+func (t *keyUsageTracker) add(ctx context.Context, key string, at time.Time, tokens int64) {
+	t.used(ctx, key, at)
+	id, month := keyUsageID(key), at.UTC().Format("2006-01")
+	t.mu.Lock()
+	e := t.entry(id, month)
+	e.tokens += tokens
+	total := e.tokens
+	t.mu.Unlock()
+	if store := state.Default(); store != nil {
+		if err := store.Put(ctx, state.NamespaceKeyUsage, id+":"+month, []byte(strconv.FormatInt(total, 10)), keyUsageRetention); err != nil {
+			log.Warnf("api key usage: persist counter: %v", err)
+		}
+	}
+}
+
+// entry returns the counter for id in month. Counters of months that ended more than a month
+// ago are dropped when a new counter is created. Callers must hold t.mu.
+func (t *keyUsageTracker) entry(id, month string) *keyUsageEntry {
+	key := id + ":" + month
+	e := t.entries[key]
+	if e == nil {
+		cutoff := time.Now().UTC().AddDate(0, -1, 0).Format("2006-01")
+		for k := range t.entries {
+			if k[strings.LastIndexByte(k, ':')+1:] < cutoff {
+				delete(t.entries, k)
+			}
+		}
+		e = &keyUsageEntry{}
+		t.entries[key] = e
+	}
+	return e
+}
+
+func loadKeyUsage(ctx context.Context, id, month string) int64 {
+	store := state.Default()
+	if store == nil {
+		return 0
+	}
+	raw, ok, err := store.Get(ctx, state.NamespaceKeyUsage, id+":"+month)
+	if err != nil {
+		log.Warnf("api key usage: load counter: %v", err)
+		return 0
+	}
+	if !ok {
+		return 0
+	}
+	tokens, _ := strconv.ParseInt(string(raw), 10, 64)
+	return tokens
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestEnforceKeyPolicy(t *testing.T) {
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{APIKeyPolicies: []sdkconfig.APIKeyPolicy{
+		{
+			APIKeys:      []string{"limited"},
+			Models:       []string{"gpt-4o-mini", "claude-haiku-*"},
+			MaxTokens:    1024,
+			Capabilities: []string{"streaming"},
+		},
+	}}, nil)
+
+	cases := []struct {
+		name   string
+		key    string
+		model  string
+		body   string
+		stream bool
+		code   string
+	}{
+		{"allowed", "limited", "claude-haiku-4-5", `{"max_tokens":512,"messages":[{"role":"user","content":"hi"}]}`, true, ""},
+		{"other key unrestricted", "free", "gpt-5", `{"tools":[{"type":"function"}]}`, false, ""},
+		{"model", "limited", "gpt-5", `{}`, false, "model_not_allowed"},
+		{"max tokens", "limited", "gpt-4o-mini", `{"max_completion_tokens":4096}`, false, "max_tokens_exceeded"},
+		{"gemini max tokens", "limited", "gpt-4o-mini", `{"generationConfig":{"maxOutputTokens":2048}}`, false, "max_tokens_exceeded"},
+		{"tools", "limited", "gpt-4o-mini", `{"tools":[{"name":"lookup"}]}`, false, "tools_not_allowed"},
+		{"openai vision", "limited", "gpt-4o-mini", `{"messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:"}}]}]}`, false, "vision_not_allowed"},
+		{"gemini vision", "limited", "gpt-4o-mini", `{"contents":[{"parts":[{"inlineData":{"mimeType":"image/png","data":""}}]}]}`, false, "vision_not_allowed"},
+	}
+	for _, tc := range cases {
+		errMsg := h.enforceKeyPolicy(overrideContext(tc.key, nil), tc.model, []byte(tc.body), tc.stream)
+		if tc.code == "" {
+			if errMsg != nil {
+				t.Fatalf("%s: unexpected rejection: %v", tc.name, errMsg.Error)
+			}
+			continue
+		}
+		if errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+			t.Fatalf("%s: expected 403, got %+v", tc.name, errMsg)
+		}
+		if code := gjson.Get(errMsg.Error.Error(), "error.code").String(); code != tc.code {
+			t.Fatalf("%s: error code %q, want %q", tc.name, code, tc.code)
+		}
+	}
+}
+
+func TestEnforceKeyPolicyMonthlyBudget(t *testing.T) {
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{APIKeyPolicies: []sdkconfig.APIKeyPolicy{
+		{APIKeys: []string{"budgeted"}, MonthlyTokenBudget: 100},
+	}}, nil)
+	ctx := overrideContext("budgeted", nil)
+
+	if errMsg := h.enforceKeyPolicy(ctx, "m", []byte(`{}`), false); errMsg != nil {
+		t.Fatalf("unexpected rejection before usage: %v", errMsg.Error)
+	}
+	defaultKeyUsage.HandleUsage(context.Background(), coreusage.Record{APIKey: "budgeted", RequestedAt: time.Now(), Detail: coreusage.Detail{InputTokens: 60, OutputTokens: 50}})
+	errMsg := h.enforceKeyPolicy(ctx, "m", []byte(`{}`), false)
+	if errMsg == nil || gjson.Get(errMsg.Error.Error(), "error.code").String() != "token_budget_exceeded" {
+		t.Fatalf("expected budget rejection, got %+v", errMsg)
+	}
+
+	// Usage from a previous month does not count.
+	if used := defaultKeyUsage.used(context.Background(), "budgeted", time.Now().AddDate(0, 1, 0)); used != 0 {
+		t.Fatalf("expected fresh counter for next month, got %d", used)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/tidwall/gjson"
+)
+
+// logprobsRequested reports whether an OpenAI-format request asks for token log probabilities.
+func logprobsRequested(handlerType string, rawJSON []byte) bool {
+	switch handlerType {
+	case constant.OpenAI:
+		// Chat Completions uses a boolean; the legacy Completions API uses an integer.
+		lp := gjson.GetBytes(rawJSON, "logprobs")
+		return (lp.Type == gjson.True) || (lp.Type == gjson.Number && lp.Int() > 0)
+	case constant.OpenaiResponse:
+		for _, include := range gjson.GetBytes(rawJSON, "include").Array() {
+			if strings.HasSuffix(include.String(), ".logprobs") {
+				return true
+			}
+		}
+		return gjson.GetBytes(rawJSON, "top_logprobs").Int() > 0
+	}
+	return false
+}
+
+// providerSupportsLogprobs reports whether logprobs requested through handlerType can be
+// produced by the provider. Claude and Codex never return them; Gemini-family upstreams are
+// translated for Chat Completions only. OpenAI-compatible providers pass them through.
+func providerSupportsLogprobs(handlerType, provider string) bool {
+	switch provider {
+	case "claude", "codex":
+		return false
+	case "gemini", "vertex", "gemini-cli", "aistudio", "antigravity":
+		return handlerType == constant.OpenAI
+	}
+	return true
+}
+
+// filterLogprobsProviders restricts providers to those able to return logprobs when the client
+// asks for them, and reports a capability error instead of silently omitting the field when
+// none can.
+func filterLogprobsProviders(handlerType, modelName string, rawJSON []byte, providers []string) ([]string, *interfaces.ErrorMessage) {
+	if !logprobsRequested(handlerType, rawJSON) {
+		return providers, nil
+	}
+	capable := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if providerSupportsLogprobs(handlerType, provider) {
+			capable = append(capable, provider)
+		}
+	}
+	if len(capable) == 0 {
+		return nil, &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("logprobs are not supported for model %s: upstream provider %s does not return token log probabilities", modelName, strings.Join(providers, ", ")),
+		}
+	}
+	return capable, nil
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestFilterLogprobsProviders(t *testing.T) {
+	chat := []byte(`{"model":"m","logprobs":true,"top_logprobs":2}`)
+
+	providers, errMsg := filterLogprobsProviders("openai", "m", chat, []string{"claude", "gemini"})
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if !reflect.DeepEqual(providers, []string{"gemini"}) {
+		t.Fatalf("providers = %v, want [gemini]", providers)
+	}
+
+	if _, errMsg = filterLogprobsProviders("openai", "m", chat, []string{"claude", "codex"}); errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 capability error, got %+v", errMsg)
+	}
+
+	responses := []byte(`{"model":"m","include":["message.output_text.logprobs"]}`)
+	if _, errMsg = filterLogprobsProviders("openai-response", "m", responses, []string{"gemini"}); errMsg == nil {
+		t.Fatal("expected Responses logprobs on Gemini to be rejected")
+	}
+
+	plain := []byte(`{"model":"m","logprobs":false}`)
+	providers, errMsg = filterLogprobsProviders("openai", "m", plain, []string{"claude"})
+	if errMsg != nil || !reflect.DeepEqual(providers, []string{"claude"}) {
+		t.Fatalf("requests without logprobs must not be filtered: %v %+v", providers, errMsg)
+	}
+}
@@ -0,0 +1,207 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// defaultTranscriptionModel is used when a transcription request does not name a model.
+	defaultTranscriptionModel = "whisper-1"
+	// defaultSpeechModel is used when a speech request does not name a model.
+	defaultSpeechModel = "tts-1"
+	// audioChunkSize is the size of the writes used to stream generated audio to the client.
+	audioChunkSize = 32 * 1024
+)
+
+// speechContentTypes maps OpenAI speech response formats to their content types.
+var speechContentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"opus": "audio/ogg",
+	"aac":  "audio/aac",
+	"flac": "audio/flac",
+	"wav":  "audio/wav",
+	"pcm":  "audio/pcm",
+}
+
+// AudioTranscriptions handles the /v1/audio/transcriptions endpoint. The multipart upload is
+// converted to the proxy's internal JSON form, with the file base64-encoded, and routed like
+// any other model call: Whisper-compatible upstreams receive the multipart form again and
+// Gemini upstreams receive the audio inline.
+func (h *OpenAIAPIHandler) AudioTranscriptions(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: file is required: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	// Encode straight from the upload so the raw audio is never held in memory as well.
+	var encoded strings.Builder
+	encoder := base64.NewEncoder(base64.StdEncoding, &encoded)
+	_, err = io.Copy(encoder, file)
+	_ = file.Close()
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	rawJSON := []byte(`{"model":""}`)
+	modelName := strings.TrimSpace(c.PostForm("model"))
+	if modelName == "" {
+		modelName = defaultTranscriptionModel
+	}
+	rawJSON, _ = sjson.SetBytes(rawJSON, "model", modelName)
+	for _, field := range []string{"language", "prompt", "response_format", "chunking_strategy"} {
+		if value := c.PostForm(field); value != "" {
+			rawJSON, _ = sjson.SetBytes(rawJSON, field, value)
+		}
+	}
+	if value := c.PostForm("temperature"); value != "" {
+		if temperature, errParse := strconv.ParseFloat(value, 64); errParse == nil {
+			rawJSON, _ = sjson.SetBytes(rawJSON, "temperature", temperature)
+		}
+	}
+	for _, granularity := range c.PostFormArray("timestamp_granularities[]") {
+		rawJSON, _ = sjson.SetBytes(rawJSON, "timestamp_granularities.-1", granularity)
+	}
+	rawJSON, _ = sjson.SetBytes(rawJSON, "file.filename", fileHeader.Filename)
+	rawJSON, _ = sjson.SetBytes(rawJSON, "file.content_type", fileHeader.Header.Get("Content-Type"))
+	rawJSON, _ = sjson.SetBytes(rawJSON, "file.data", encoded.String())
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, constant.OpenAITranscription, modelName, rawJSON, "")
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	cliCancel()
+
+	if !gjson.ValidBytes(resp) {
+		// Whisper-compatible upstreams answer text, srt and vtt formats with plain text.
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", resp)
+		return
+	}
+	if gjson.GetBytes(resp, "error").Exists() {
+		c.Data(http.StatusBadRequest, "application/json", resp)
+		return
+	}
+	switch c.PostForm("response_format") {
+	case "text", "srt", "vtt":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(gjson.GetBytes(resp, "text").String()))
+	default:
+		c.Data(http.StatusOK, "application/json", resp)
+	}
+}
+
+// AudioSpeech handles the /v1/audio/speech endpoint. The generated audio is written to the
+// client in chunks as a binary response; Gemini speech models produce WAV or raw PCM
+// regardless of the requested format, and the content type reflects what was produced.
+func (h *OpenAIAPIHandler) AudioSpeech(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	if strings.TrimSpace(gjson.GetBytes(rawJSON, "input").String()) == "" {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "input is required",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	modelName := strings.TrimSpace(gjson.GetBytes(rawJSON, "model").String())
+	if modelName == "" {
+		modelName = defaultSpeechModel
+		rawJSON, _ = sjson.SetBytes(rawJSON, "model", modelName)
+	}
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, constant.OpenAISpeech, modelName, rawJSON, "")
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	cliCancel()
+
+	if len(resp) == 0 {
+		c.JSON(http.StatusBadGateway, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Upstream returned no audio",
+				Type:    "server_error",
+			},
+		})
+		return
+	}
+	if resp[0] == '{' && gjson.GetBytes(resp, "error").Exists() {
+		c.Data(http.StatusBadRequest, "application/json", resp)
+		return
+	}
+
+	c.Header("Content-Type", speechContentType(gjson.GetBytes(rawJSON, "response_format").String(), resp))
+	c.Status(http.StatusOK)
+	flusher, _ := c.Writer.(http.Flusher)
+	for offset := 0; offset < len(resp); offset += audioChunkSize {
+		end := min(offset+audioChunkSize, len(resp))
+		if _, errWrite := c.Writer.Write(resp[offset:end]); errWrite != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// speechContentType returns the content type of generated audio, preferring what the bytes
+// show over the requested format.
+func speechContentType(format string, audio []byte) string {
+	if bytes.HasPrefix(audio, []byte("RIFF")) {
+		return "audio/wav"
+	}
+	if contentType, ok := speechContentTypes[strings.ToLower(format)]; ok {
+		return contentType
+	}
+	return "audio/mpeg"
+}
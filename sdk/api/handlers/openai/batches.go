@@ -0,0 +1,619 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/state"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	batchStatusValidating = "validating"
+	batchStatusFailed     = "failed"
+	batchStatusInProgress = "in_progress"
+	batchStatusFinalizing = "finalizing"
+	batchStatusCompleted  = "completed"
+	batchStatusExpired    = "expired"
+	batchStatusCancelling = "cancelling"
+	batchStatusCancelled  = "cancelled"
+
+	batchCompletionWindow = "24h"
+	batchProcessingWindow = 24 * time.Hour
+
+	// batchPersistInterval throttles progress writes to the state store.
+	batchPersistInterval = time.Second
+)
+
+// batchEndpoints maps the endpoints a batch may target to the handler format executing them.
+var batchEndpoints = map[string]string{
+	"/v1/chat/completions": OpenAI,
+	"/v1/completions":      OpenAI,
+	"/v1/responses":        OpenaiResponse,
+}
+
+type batchError struct {
+	Code    string  `json:"code"`
+	Message string  `json:"message"`
+	Param   *string `json:"param"`
+	Line    *int    `json:"line"`
+}
+
+type batchErrors struct {
+	Object string       `json:"object"`
+	Data   []batchError `json:"data"`
+}
+
+type batchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// openAIBatch mirrors the OpenAI Batch object.
+type openAIBatch struct {
+	ID               string             `json:"id"`
+	Object           string             `json:"object"`
+	Endpoint         string             `json:"endpoint"`
+	Errors           *batchErrors       `json:"errors"`
+	InputFileID      string             `json:"input_file_id"`
+	CompletionWindow string             `json:"completion_window"`
+	Status           string             `json:"status"`
+	OutputFileID     *string            `json:"output_file_id"`
+	ErrorFileID      *string            `json:"error_file_id"`
+	CreatedAt        int64              `json:"created_at"`
+	InProgressAt     *int64             `json:"in_progress_at"`
+	ExpiresAt        int64              `json:"expires_at"`
+	FinalizingAt     *int64             `json:"finalizing_at"`
+	CompletedAt      *int64             `json:"completed_at"`
+	FailedAt         *int64             `json:"failed_at"`
+	ExpiredAt        *int64             `json:"expired_at"`
+	CancellingAt     *int64             `json:"cancelling_at"`
+	CancelledAt      *int64             `json:"cancelled_at"`
+	RequestCounts    batchRequestCounts `json:"request_counts"`
+	Metadata         map[string]string  `json:"metadata"`
+}
+
+type storedBatch struct {
+	Batch openAIBatch `json:"batch"`
+	Owner string      `json:"owner"`
+}
+
+type batchLine struct {
+	customID string
+	body     []byte
+}
+
+func unixNow() *int64 {
+	now := time.Now().Unix()
+	return &now
+}
+
+func (h *OpenAIBatchAPIHandler) saveBatch(ctx context.Context, stored *storedBatch) error {
+	_, _, retention := h.settings()
+	raw, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return h.store().Put(ctx, state.NamespaceOpenAIBatches, stored.Batch.ID, raw, retention)
+}
+
+// loadBatch returns the batch with id if it belongs to owner. Batches left unfinished by a
+// previous process are marked failed, since their execution state was lost.
+func (h *OpenAIBatchAPIHandler) loadBatch(ctx context.Context, id, owner string) (*storedBatch, bool, error) {
+	if id == storeIndexKey {
+		return nil, false, nil
+	}
+	raw, ok, err := h.store().Get(ctx, state.NamespaceOpenAIBatches, id)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	var stored storedBatch
+	if err = json.Unmarshal(raw, &stored); err != nil {
+		return nil, false, err
+	}
+	if stored.Owner != owner {
+		return nil, false, nil
+	}
+	if h.orphaned(&stored.Batch) {
+		stored.Batch.Status = batchStatusFailed
+		stored.Batch.FailedAt = unixNow()
+		stored.Batch.Errors = &batchErrors{Object: "list", Data: []batchError{{Code: "interrupted", Message: "The proxy restarted while the batch was running."}}}
+		if errSave := h.saveBatch(ctx, &stored); errSave != nil {
+			log.Warnf("failed to mark interrupted batch %s: %v", id, errSave)
+		}
+	}
+	return &stored, true, nil
+}
+
+func (h *OpenAIBatchAPIHandler) orphaned(batch *openAIBatch) bool {
+	switch batch.Status {
+	case batchStatusValidating, batchStatusInProgress, batchStatusFinalizing, batchStatusCancelling:
+	default:
+		return false
+	}
+	h.runningMu.Lock()
+	_, running := h.running[batch.ID]
+	h.runningMu.Unlock()
+	return !running
+}
+
+// CreateBatch handles POST /v1/batches.
+func (h *OpenAIBatchAPIHandler) CreateBatch(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	endpoint := gjson.GetBytes(rawJSON, "endpoint").String()
+	if _, ok := batchEndpoints[endpoint]; !ok {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("endpoint: unsupported endpoint %q", endpoint))
+		return
+	}
+	if window := gjson.GetBytes(rawJSON, "completion_window").String(); window != batchCompletionWindow {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("completion_window: must be %q", batchCompletionWindow))
+		return
+	}
+	var metadata map[string]string
+	if md := gjson.GetBytes(rawJSON, "metadata"); md.IsObject() {
+		if errMD := json.Unmarshal([]byte(md.Raw), &metadata); errMD != nil {
+			writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "metadata: values must be strings")
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	owner := ownerOf(c)
+	inputFileID := gjson.GetBytes(rawJSON, "input_file_id").String()
+	file, ok, err := h.getFile(ctx, inputFileID, owner)
+	if err != nil {
+		writeOpenAIError(c, http.StatusInternalServerError, "server_error", "failed to load input file")
+		return
+	}
+	if !ok || file.Purpose != filePurposeBatch {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("input_file_id: no batch file %q", inputFileID))
+		return
+	}
+	content, ok, err := h.getFileContent(ctx, file.ID)
+	if err != nil || !ok {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("input_file_id: content of %q is unavailable", inputFileID))
+		return
+	}
+
+	now := time.Now()
+	stored := &storedBatch{
+		Owner: owner,
+		Batch: openAIBatch{
+			ID:               "batch_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+			Object:           "batch",
+			Endpoint:         endpoint,
+			InputFileID:      file.ID,
+			CompletionWindow: batchCompletionWindow,
+			Status:           batchStatusValidating,
+			CreatedAt:        now.Unix(),
+			ExpiresAt:        now.Add(batchProcessingWindow).Unix(),
+			Metadata:         metadata,
+		},
+	}
+	lines, validationErrors := parseBatchInput(content, endpoint)
+	if len(validationErrors) > 0 {
+		stored.Batch.Status = batchStatusFailed
+		stored.Batch.FailedAt = unixNow()
+		stored.Batch.Errors = &batchErrors{Object: "list", Data: validationErrors}
+	} else {
+		stored.Batch.Status = batchStatusInProgress
+		stored.Batch.InProgressAt = unixNow()
+		stored.Batch.RequestCounts.Total = len(lines)
+	}
+	var execCtx context.Context
+	if stored.Batch.Status == batchStatusInProgress {
+		// Register the batch as running before it becomes visible, so lookups do not treat it as
+		// left over from a previous process.
+		var cancel, cancelDeadline context.CancelFunc
+		execCtx, cancel = h.DetachedContext(h, c, context.Background())
+		execCtx, cancelDeadline = context.WithDeadline(execCtx, now.Add(batchProcessingWindow))
+		execCtx = coreauth.WithPriority(execCtx, coreauth.PriorityBatch)
+		h.runningMu.Lock()
+		h.running[stored.Batch.ID] = func() {
+			cancelDeadline()
+			cancel()
+		}
+		h.runningMu.Unlock()
+	}
+	if err = h.saveBatch(ctx, stored); err == nil {
+		err = h.updateIndex(ctx, state.NamespaceOpenAIBatches, func(ids []string) []string { return append(ids, stored.Batch.ID) })
+	}
+	if err != nil {
+		log.Errorf("failed to store batch: %v", err)
+		h.stopBatch(stored.Batch.ID)
+		writeOpenAIError(c, http.StatusInternalServerError, "server_error", "failed to store batch")
+		return
+	}
+	if execCtx != nil {
+		snapshot := *stored
+		go h.runBatch(execCtx, &snapshot, lines)
+	}
+	c.JSON(http.StatusOK, stored.Batch)
+}
+
+// parseBatchInput validates the JSONL input file, reporting problems per line as OpenAI does.
+func parseBatchInput(content []byte, endpoint string) ([]batchLine, []batchError) {
+	var (
+		lines []batchLine
+		errs  []batchError
+	)
+	seen := make(map[string]struct{})
+	for i, raw := range bytes.Split(content, []byte("\n")) {
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+		lineNo := i + 1
+		fail := func(code, message string) {
+			errs = append(errs, batchError{Code: code, Message: message, Line: &lineNo})
+		}
+		if !gjson.ValidBytes(raw) {
+			fail("invalid_json_line", "This line is not parseable as valid JSON.")
+			continue
+		}
+		customID := gjson.GetBytes(raw, "custom_id").String()
+		if customID == "" {
+			fail("missing_required_parameter", "custom_id is required.")
+			continue
+		}
+		if _, dup := seen[customID]; dup {
+			fail("duplicate_custom_id", fmt.Sprintf("The custom_id %q is used more than once.", customID))
+			continue
+		}
+		seen[customID] = struct{}{}
+		if method := gjson.GetBytes(raw, "method").String(); !strings.EqualFold(method, http.MethodPost) {
+			fail("invalid_method", "Only POST requests are supported.")
+			continue
+		}
+		if url := gjson.GetBytes(raw, "url").String(); url != endpoint {
+			fail("mismatched_endpoint", fmt.Sprintf("The url %q does not match the batch endpoint %q.", url, endpoint))
+			continue
+		}
+		body := gjson.GetBytes(raw, "body")
+		if !body.IsObject() || body.Get("model").String() == "" {
+			fail("invalid_request", "body must be an object with a model.")
+			continue
+		}
+		if body.Get("stream").Bool() {
+			fail("invalid_request", "Streaming is not supported in batches.")
+			continue
+		}
+		lines = append(lines, batchLine{customID: customID, body: []byte(body.Raw)})
+	}
+	if len(lines) == 0 && len(errs) == 0 {
+		errs = append(errs, batchError{Code: "empty_file", Message: "The input file contains no requests."})
+	}
+	return lines, errs
+}
+
+// runBatch executes every request, then writes the output and error files.
+func (h *OpenAIBatchAPIHandler) runBatch(ctx context.Context, stored *storedBatch, lines []batchLine) {
+	defer h.stopBatch(stored.Batch.ID)
+	concurrency, _, _ := h.settings()
+	persistCtx := context.WithoutCancel(ctx)
+
+	var (
+		mu          sync.Mutex
+		outputs     = make([][]byte, len(lines))
+		failures    = make([][]byte, len(lines))
+		lastPersist time.Time
+	)
+	progress := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if time.Since(lastPersist) < batchPersistInterval {
+			return
+		}
+		lastPersist = time.Now()
+		h.mergeProgress(persistCtx, stored)
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range lines {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			line, ok := h.executeBatchLine(ctx, stored.Batch.Endpoint, lines[i])
+			if !ok && ctx.Err() != nil {
+				// Requests interrupted by cancellation or expiry are reported below.
+				return
+			}
+			mu.Lock()
+			if ok {
+				outputs[i] = line
+				stored.Batch.RequestCounts.Completed++
+			} else {
+				failures[i] = line
+				stored.Batch.RequestCounts.Failed++
+			}
+			mu.Unlock()
+			progress()
+		}(i)
+	}
+	wg.Wait()
+
+	finalStatus := batchStatusCompleted
+	if ctx.Err() != nil {
+		finalStatus = batchStatusCancelled
+		code, message := "batch_cancelled", "This request was not executed because the batch was cancelled."
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			finalStatus = batchStatusExpired
+			code, message = "batch_expired", "This request could not be executed before the completion window expired."
+		}
+		for i := range lines {
+			if outputs[i] == nil && failures[i] == nil {
+				failures[i] = batchErrorLine(lines[i].customID, nil, code, message)
+			}
+		}
+	}
+
+	stored.Batch.FinalizingAt = unixNow()
+	h.finalizeBatch(persistCtx, stored, finalStatus, outputs, failures)
+}
+
+// stopBatch cancels a running batch and forgets it.
+func (h *OpenAIBatchAPIHandler) stopBatch(id string) {
+	h.runningMu.Lock()
+	cancel := h.running[id]
+	delete(h.running, id)
+	h.runningMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// mergeProgress persists request counts without overwriting a concurrent cancellation request.
+func (h *OpenAIBatchAPIHandler) mergeProgress(ctx context.Context, stored *storedBatch) {
+	current, ok, err := h.loadBatch(ctx, stored.Batch.ID, stored.Owner)
+	if err != nil || !ok {
+		return
+	}
+	current.Batch.RequestCounts = stored.Batch.RequestCounts
+	if err = h.saveBatch(ctx, current); err != nil {
+		log.Warnf("failed to persist progress of batch %s: %v", stored.Batch.ID, err)
+	}
+}
+
+func (h *OpenAIBatchAPIHandler) finalizeBatch(ctx context.Context, stored *storedBatch, status string, outputs, failures [][]byte) {
+	current, ok, err := h.loadBatch(ctx, stored.Batch.ID, stored.Owner)
+	if err != nil || !ok {
+		log.Warnf("batch %s disappeared before it could be finalized: %v", stored.Batch.ID, err)
+		return
+	}
+	batch := &current.Batch
+	batch.RequestCounts = stored.Batch.RequestCounts
+	batch.FinalizingAt = stored.Batch.FinalizingAt
+
+	if output := joinLines(outputs); len(output) > 0 {
+		file, errPut := h.putFile(ctx, stored.Owner, batch.ID+"_output.jsonl", filePurposeBatchOutput, output)
+		if errPut != nil {
+			log.Errorf("failed to store output of batch %s: %v", batch.ID, errPut)
+		} else {
+			batch.OutputFileID = &file.ID
+		}
+	}
+	if errorsOut := joinLines(failures); len(errorsOut) > 0 {
+		file, errPut := h.putFile(ctx, stored.Owner, batch.ID+"_error.jsonl", filePurposeBatchOutput, errorsOut)
+		if errPut != nil {
+			log.Errorf("failed to store errors of batch %s: %v", batch.ID, errPut)
+		} else {
+			batch.ErrorFileID = &file.ID
+		}
+	}
+
+	batch.Status = status
+	switch status {
+	case batchStatusCompleted:
+		batch.CompletedAt = unixNow()
+	case batchStatusCancelled:
+		batch.CancelledAt = unixNow()
+	case batchStatusExpired:
+		batch.ExpiredAt = unixNow()
+	}
+	if err = h.saveBatch(ctx, current); err != nil {
+		log.Errorf("failed to finalize batch %s: %v", batch.ID, err)
+	}
+}
+
+func joinLines(lines [][]byte) []byte {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		if line == nil {
+			continue
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// executeBatchLine runs one request and returns its output line and whether it succeeded.
+func (h *OpenAIBatchAPIHandler) executeBatchLine(ctx context.Context, endpoint string, line batchLine) ([]byte, bool) {
+	handlerType := batchEndpoints[endpoint]
+	body := line.body
+	if endpoint == "/v1/completions" {
+		body = convertCompletionsRequestToChatCompletions(body)
+	}
+	modelName := gjson.GetBytes(body, "model").String()
+	resp, errMsg := h.ExecuteWithAuthManager(ctx, handlerType, modelName, body, "")
+	if errMsg != nil {
+		return batchErrorLine(line.customID, errMsg, "", ""), false
+	}
+	if endpoint == "/v1/completions" {
+		resp = convertChatCompletionsResponseToCompletions(resp)
+	}
+	if !gjson.ValidBytes(resp) {
+		return batchErrorLine(line.customID, &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: errors.New("upstream returned an invalid response")}, "", ""), false
+	}
+	out := batchOutputLine(line.customID)
+	out, _ = sjson.SetBytes(out, "response.status_code", http.StatusOK)
+	out, _ = sjson.SetRawBytes(out, "response.body", resp)
+	return out, true
+}
+
+func batchOutputLine(customID string) []byte {
+	out := []byte(`{"id":"","custom_id":"","response":{"status_code":0,"request_id":"","body":null},"error":null}`)
+	out, _ = sjson.SetBytes(out, "id", "batch_req_"+strings.ReplaceAll(uuid.NewString(), "-", ""))
+	out, _ = sjson.SetBytes(out, "custom_id", customID)
+	out, _ = sjson.SetBytes(out, "response.request_id", uuid.NewString())
+	return out
+}
+
+// batchErrorLine renders a failed request. Upstream failures carry the HTTP status and error body
+// in "response"; requests that never ran carry only a top-level "error".
+func batchErrorLine(customID string, msg *interfaces.ErrorMessage, code, message string) []byte {
+	out := batchOutputLine(customID)
+	if msg == nil {
+		out, _ = sjson.SetRawBytes(out, "response", []byte("null"))
+		out, _ = sjson.SetBytes(out, "error", map[string]string{"code": code, "message": message})
+		return out
+	}
+	status := msg.StatusCode
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	errText := "unknown error"
+	if msg.Error != nil {
+		errText = msg.Error.Error()
+	}
+	errBody := []byte(errText)
+	if !gjson.Get(errText, "error").Exists() {
+		errBody, _ = sjson.SetBytes([]byte(`{"error":{"message":"","type":"api_error"}}`), "error.message", errText)
+	}
+	out, _ = sjson.SetBytes(out, "response.status_code", status)
+	out, _ = sjson.SetRawBytes(out, "response.body", errBody)
+	return out
+}
+
+// ListBatches handles GET /v1/batches.
+func (h *OpenAIBatchAPIHandler) ListBatches(c *gin.Context) {
+	ctx := c.Request.Context()
+	ids, err := h.readIndex(ctx, state.NamespaceOpenAIBatches)
+	if err != nil {
+		writeOpenAIError(c, http.StatusInternalServerError, "server_error", "failed to list batches")
+		return
+	}
+	owner := ownerOf(c)
+	batches := make([]openAIBatch, 0, len(ids))
+	var expired []string
+	for _, id := range ids {
+		stored, ok, errLoad := h.loadBatch(ctx, id, owner)
+		if errLoad != nil {
+			continue
+		}
+		if !ok {
+			if _, present, _ := h.store().Get(ctx, state.NamespaceOpenAIBatches, id); !present {
+				expired = append(expired, id)
+			}
+			continue
+		}
+		batches = append(batches, stored.Batch)
+	}
+	if len(expired) > 0 {
+		_ = h.updateIndex(ctx, state.NamespaceOpenAIBatches, func(ids []string) []string {
+			for _, id := range expired {
+				ids = removeID(ids, id)
+			}
+			return ids
+		})
+	}
+	sort.SliceStable(batches, func(i, k int) bool { return batches[i].CreatedAt > batches[k].CreatedAt })
+	if after := c.Query("after"); after != "" {
+		for i := range batches {
+			if batches[i].ID == after {
+				batches = batches[i+1:]
+				break
+			}
+		}
+	}
+	limit := 20
+	if n, errAtoi := strconv.Atoi(c.Query("limit")); errAtoi == nil && n >= 1 && n <= 100 {
+		limit = n
+	}
+	hasMore := len(batches) > limit
+	if hasMore {
+		batches = batches[:limit]
+	}
+	resp := gin.H{"object": "list", "data": batches, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(batches) > 0 {
+		resp["first_id"] = batches[0].ID
+		resp["last_id"] = batches[len(batches)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *OpenAIBatchAPIHandler) lookupBatch(c *gin.Context) (*storedBatch, bool) {
+	stored, ok, err := h.loadBatch(c.Request.Context(), c.Param("id"), ownerOf(c))
+	if err != nil {
+		writeOpenAIError(c, http.StatusInternalServerError, "server_error", "failed to load batch")
+		return nil, false
+	}
+	if !ok {
+		writeOpenAIError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such Batch object: %s", c.Param("id")))
+	}
+	return stored, ok
+}
+
+// GetBatch handles GET /v1/batches/:id.
+func (h *OpenAIBatchAPIHandler) GetBatch(c *gin.Context) {
+	if stored, ok := h.lookupBatch(c); ok {
+		c.JSON(http.StatusOK, stored.Batch)
+	}
+}
+
+// CancelBatch handles POST /v1/batches/:id/cancel. Completed requests are kept in the output file
+// and the rest are reported in the error file once the batch is cancelled.
+func (h *OpenAIBatchAPIHandler) CancelBatch(c *gin.Context) {
+	stored, ok := h.lookupBatch(c)
+	if !ok {
+		return
+	}
+	if stored.Batch.Status != batchStatusInProgress && stored.Batch.Status != batchStatusValidating {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Cannot cancel a batch with status %q.", stored.Batch.Status))
+		return
+	}
+	stored.Batch.Status = batchStatusCancelling
+	stored.Batch.CancellingAt = unixNow()
+	if err := h.saveBatch(c.Request.Context(), stored); err != nil {
+		writeOpenAIError(c, http.StatusInternalServerError, "server_error", "failed to cancel batch")
+		return
+	}
+	h.runningMu.Lock()
+	cancel := h.running[stored.Batch.ID]
+	h.runningMu.Unlock()
+	if cancel != nil {
+		// The batch stays registered as running until runBatch has finalized it.
+		cancel()
+	}
+	c.JSON(http.StatusOK, stored.Batch)
+}
@@ -0,0 +1,110 @@
+package openai
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestParseBatchInput(t *testing.T) {
+	input := strings.Join([]string{
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m","messages":[]}}`,
+		``,
+		`{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"m"}}`,
+	}, "\n")
+	lines, errs := parseBatchInput([]byte(input), "/v1/chat/completions")
+	if len(errs) != 0 || len(lines) != 2 || lines[1].customID != "b" {
+		t.Fatalf("lines=%+v errs=%+v", lines, errs)
+	}
+
+	bad := strings.Join([]string{
+		`not json`,
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m"}}`,
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m"}}`,
+		`{"custom_id":"c","method":"GET","url":"/v1/chat/completions","body":{"model":"m"}}`,
+		`{"custom_id":"d","method":"POST","url":"/v1/responses","body":{"model":"m"}}`,
+		`{"custom_id":"e","method":"POST","url":"/v1/chat/completions","body":{"model":"m","stream":true}}`,
+	}, "\n")
+	_, errs = parseBatchInput([]byte(bad), "/v1/chat/completions")
+	want := []string{"invalid_json_line", "duplicate_custom_id", "invalid_method", "mismatched_endpoint", "invalid_request"}
+	if len(errs) != len(want) {
+		t.Fatalf("errs = %+v", errs)
+	}
+	for i, code := range want {
+		if errs[i].Code != code {
+			t.Errorf("error %d code = %q, want %q", i, errs[i].Code, code)
+		}
+	}
+	if *errs[0].Line != 1 || *errs[1].Line != 3 {
+		t.Errorf("unexpected line numbers: %d, %d", *errs[0].Line, *errs[1].Line)
+	}
+}
+
+func TestFilesAndBatchValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewOpenAIBatchAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("apiKey", c.GetHeader("X-Test-Key")) })
+	router.POST("/v1/files", h.UploadFile)
+	router.GET("/v1/files/:id", h.GetFile)
+	router.GET("/v1/files/:id/content", h.GetFileContent)
+	router.POST("/v1/batches", h.CreateBatch)
+	router.GET("/v1/batches", h.ListBatches)
+
+	do := func(req *http.Request, key string) *httptest.ResponseRecorder {
+		req.Header.Set("X-Test-Key", key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	content := `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m"}}` + "\n"
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("purpose", "batch")
+	fw, _ := mw.CreateFormFile("file", "input.jsonl")
+	_, _ = fw.Write([]byte(content))
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/files", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := do(req, "key-a")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload: %d %s", rec.Code, rec.Body)
+	}
+	fileID := gjson.Get(rec.Body.String(), "id").String()
+	if !strings.HasPrefix(fileID, "file-") || gjson.Get(rec.Body.String(), "bytes").Int() != int64(len(content)) {
+		t.Fatalf("unexpected file object: %s", rec.Body)
+	}
+
+	if rec = do(httptest.NewRequest(http.MethodGet, "/v1/files/"+fileID+"/content", nil), "key-a"); rec.Body.String() != content {
+		t.Fatalf("content = %q", rec.Body)
+	}
+	if rec = do(httptest.NewRequest(http.MethodGet, "/v1/files/"+fileID, nil), "key-b"); rec.Code != http.StatusNotFound {
+		t.Fatalf("files must be scoped to the uploading key, got %d", rec.Code)
+	}
+
+	rec = do(httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/embeddings","completion_window":"24h"}`)), "key-a")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unsupported endpoint must be rejected, got %d", rec.Code)
+	}
+	rec = do(httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/responses","completion_window":"24h"}`)), "key-a")
+	if rec.Code != http.StatusOK || gjson.Get(rec.Body.String(), "status").String() != batchStatusFailed ||
+		gjson.Get(rec.Body.String(), "errors.data.0.code").String() != "mismatched_endpoint" {
+		t.Fatalf("expected failed batch with validation errors, got %d %s", rec.Code, rec.Body)
+	}
+	rec = do(httptest.NewRequest(http.MethodGet, "/v1/batches", nil), "key-a")
+	if gjson.Get(rec.Body.String(), "data.#").Int() != 1 {
+		t.Fatalf("list = %s", rec.Body)
+	}
+	if rec = do(httptest.NewRequest(http.MethodGet, "/v1/batches", nil), "key-b"); gjson.Get(rec.Body.String(), "data.#").Int() != 0 {
+		t.Fatalf("batches must be scoped to the creating key: %s", rec.Body)
+	}
+}
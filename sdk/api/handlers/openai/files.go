@@ -0,0 +1,356 @@
+package openai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/state"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultOpenAIBatchConcurrency = 4
+	defaultOpenAIMaxFileBytes     = 100 << 20
+	defaultOpenAIBatchRetention   = 30 * 24 * time.Hour
+
+	filePurposeBatch       = "batch"
+	filePurposeBatchOutput = "batch_output"
+
+	// storeIndexKey holds the list of IDs in a namespace, since Store cannot enumerate keys.
+	storeIndexKey = "index"
+)
+
+// openAIFile mirrors the OpenAI File object.
+type openAIFile struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt *int64 `json:"expires_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+}
+
+type storedFile struct {
+	File  openAIFile `json:"file"`
+	Owner string     `json:"owner"`
+}
+
+// OpenAIBatchAPIHandler serves the emulated OpenAI Files (/v1/files) and Batch (/v1/batches) APIs.
+// Batch requests run through the regular routing and translation pipeline at batch priority, so
+// offline evaluations can target any configured upstream.
+type OpenAIBatchAPIHandler struct {
+	*handlers.BaseAPIHandler
+
+	// memory backs files and batches when no durable state store is configured.
+	memory *state.MemoryStore
+
+	// indexMu serializes read-modify-write cycles of the namespace indexes.
+	indexMu sync.Mutex
+
+	// running tracks batches executing in this process, keyed by batch ID.
+	runningMu sync.Mutex
+	running   map[string]context.CancelFunc
+}
+
+// NewOpenAIBatchAPIHandler creates the OpenAI Files and Batch API handler.
+func NewOpenAIBatchAPIHandler(apiHandlers *handlers.BaseAPIHandler) *OpenAIBatchAPIHandler {
+	return &OpenAIBatchAPIHandler{
+		BaseAPIHandler: apiHandlers,
+		memory:         state.NewMemoryStore(),
+		running:        make(map[string]context.CancelFunc),
+	}
+}
+
+// HandlerType returns the identifier for this handler implementation.
+func (h *OpenAIBatchAPIHandler) HandlerType() string {
+	return OpenAI
+}
+
+// Models returns no models; the batch API executes against models served by other handlers.
+func (h *OpenAIBatchAPIHandler) Models() []map[string]any {
+	return nil
+}
+
+func (h *OpenAIBatchAPIHandler) store() state.Store {
+	if store := state.Default(); store != nil {
+		return store
+	}
+	return h.memory
+}
+
+func (h *OpenAIBatchAPIHandler) settings() (concurrency int, maxFileBytes int64, retention time.Duration) {
+	concurrency, maxFileBytes, retention = defaultOpenAIBatchConcurrency, defaultOpenAIMaxFileBytes, defaultOpenAIBatchRetention
+	if h.Cfg == nil {
+		return
+	}
+	cfg := h.Cfg.OpenAIBatches
+	if cfg.MaxConcurrency > 0 {
+		concurrency = cfg.MaxConcurrency
+	}
+	if cfg.MaxFileBytes > 0 {
+		maxFileBytes = cfg.MaxFileBytes
+	}
+	if cfg.RetentionHours > 0 {
+		retention = time.Duration(cfg.RetentionHours) * time.Hour
+	}
+	return
+}
+
+// ownerOf identifies the client that owns files and batches by a hash of its API key, so raw keys
+// are never persisted.
+func ownerOf(c *gin.Context) string {
+	key := ""
+	if v, ok := c.Get("apiKey"); ok {
+		if s, okStr := v.(string); okStr {
+			key = s
+		}
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeOpenAIError(c *gin.Context, status int, errType, message string) {
+	c.JSON(status, handlers.ErrorResponse{Error: handlers.ErrorDetail{Message: message, Type: errType}})
+}
+
+func (h *OpenAIBatchAPIHandler) readIndex(ctx context.Context, namespace string) ([]string, error) {
+	raw, ok, err := h.store().Get(ctx, namespace, storeIndexKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var ids []string
+	if err = json.Unmarshal(raw, &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// updateIndex applies fn to the ID index of namespace.
+func (h *OpenAIBatchAPIHandler) updateIndex(ctx context.Context, namespace string, fn func([]string) []string) error {
+	h.indexMu.Lock()
+	defer h.indexMu.Unlock()
+	ids, err := h.readIndex(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(fn(ids))
+	if err != nil {
+		return err
+	}
+	return h.store().Put(ctx, namespace, storeIndexKey, raw, 0)
+}
+
+func removeID(ids []string, id string) []string {
+	out := ids[:0]
+	for _, existing := range ids {
+		if existing != id {
+			out = append(out, existing)
+		}
+	}
+	return out
+}
+
+func fileContentKey(id string) string { return "content:" + id }
+
+// putFile stores a file and its content and adds it to the index.
+func (h *OpenAIBatchAPIHandler) putFile(ctx context.Context, owner, filename, purpose string, content []byte) (openAIFile, error) {
+	_, _, retention := h.settings()
+	now := time.Now()
+	expiresAt := now.Add(retention).Unix()
+	file := openAIFile{
+		ID:        "file-" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Object:    "file",
+		Bytes:     int64(len(content)),
+		CreatedAt: now.Unix(),
+		ExpiresAt: &expiresAt,
+		Filename:  filename,
+		Purpose:   purpose,
+	}
+	raw, err := json.Marshal(storedFile{File: file, Owner: owner})
+	if err != nil {
+		return openAIFile{}, err
+	}
+	store := h.store()
+	if err = store.Put(ctx, state.NamespaceOpenAIFiles, fileContentKey(file.ID), content, retention); err != nil {
+		return openAIFile{}, err
+	}
+	if err = store.Put(ctx, state.NamespaceOpenAIFiles, file.ID, raw, retention); err != nil {
+		return openAIFile{}, err
+	}
+	err = h.updateIndex(ctx, state.NamespaceOpenAIFiles, func(ids []string) []string { return append(ids, file.ID) })
+	return file, err
+}
+
+// getFile loads the file with id if it belongs to owner.
+func (h *OpenAIBatchAPIHandler) getFile(ctx context.Context, id, owner string) (openAIFile, bool, error) {
+	if id == storeIndexKey || strings.HasPrefix(id, "content:") {
+		return openAIFile{}, false, nil
+	}
+	raw, ok, err := h.store().Get(ctx, state.NamespaceOpenAIFiles, id)
+	if err != nil || !ok {
+		return openAIFile{}, false, err
+	}
+	var stored storedFile
+	if err = json.Unmarshal(raw, &stored); err != nil {
+		return openAIFile{}, false, err
+	}
+	if stored.Owner != owner {
+		return openAIFile{}, false, nil
+	}
+	return stored.File, true, nil
+}
+
+func (h *OpenAIBatchAPIHandler) getFileContent(ctx context.Context, id string) ([]byte, bool, error) {
+	return h.store().Get(ctx, state.NamespaceOpenAIFiles, fileContentKey(id))
+}
+
+// UploadFile handles POST /v1/files. Only files with purpose "batch" are accepted.
+func (h *OpenAIBatchAPIHandler) UploadFile(c *gin.Context) {
+	_, maxFileBytes, _ := h.settings()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileBytes+1<<20)
+	purpose := strings.TrimSpace(c.PostForm("purpose"))
+	if purpose != filePurposeBatch {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("purpose: only %q is supported", filePurposeBatch))
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("file: %v", err))
+		return
+	}
+	if header.Size > maxFileBytes {
+		writeOpenAIError(c, http.StatusRequestEntityTooLarge, "invalid_request_error", fmt.Sprintf("file: exceeds the maximum size of %d bytes", maxFileBytes))
+		return
+	}
+	f, err := header.Open()
+	if err != nil {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("file: %v", err))
+		return
+	}
+	defer func() { _ = f.Close() }()
+	content, err := io.ReadAll(io.LimitReader(f, maxFileBytes+1))
+	if err != nil {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("file: %v", err))
+		return
+	}
+	if int64(len(content)) > maxFileBytes {
+		writeOpenAIError(c, http.StatusRequestEntityTooLarge, "invalid_request_error", fmt.Sprintf("file: exceeds the maximum size of %d bytes", maxFileBytes))
+		return
+	}
+	file, err := h.putFile(c.Request.Context(), ownerOf(c), header.Filename, purpose, content)
+	if err != nil {
+		log.Errorf("failed to store uploaded file: %v", err)
+		writeOpenAIError(c, http.StatusInternalServerError, "server_error", "failed to store file")
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+// ListFiles handles GET /v1/files.
+func (h *OpenAIBatchAPIHandler) ListFiles(c *gin.Context) {
+	ctx := c.Request.Context()
+	ids, err := h.readIndex(ctx, state.NamespaceOpenAIFiles)
+	if err != nil {
+		writeOpenAIError(c, http.StatusInternalServerError, "server_error", "failed to list files")
+		return
+	}
+	owner := ownerOf(c)
+	purpose := c.Query("purpose")
+	files := make([]openAIFile, 0, len(ids))
+	var expired []string
+	for _, id := range ids {
+		raw, ok, errGet := h.store().Get(ctx, state.NamespaceOpenAIFiles, id)
+		if errGet != nil {
+			continue
+		}
+		if !ok {
+			expired = append(expired, id)
+			continue
+		}
+		var stored storedFile
+		if json.Unmarshal(raw, &stored) != nil || stored.Owner != owner {
+			continue
+		}
+		if purpose == "" || stored.File.Purpose == purpose {
+			files = append(files, stored.File)
+		}
+	}
+	if len(expired) > 0 {
+		_ = h.updateIndex(ctx, state.NamespaceOpenAIFiles, func(ids []string) []string {
+			for _, id := range expired {
+				ids = removeID(ids, id)
+			}
+			return ids
+		})
+	}
+	sort.SliceStable(files, func(i, k int) bool { return files[i].CreatedAt > files[k].CreatedAt })
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": files, "has_more": false})
+}
+
+func (h *OpenAIBatchAPIHandler) lookupFile(c *gin.Context) (openAIFile, bool) {
+	file, ok, err := h.getFile(c.Request.Context(), c.Param("id"), ownerOf(c))
+	if err != nil {
+		writeOpenAIError(c, http.StatusInternalServerError, "server_error", "failed to load file")
+		return openAIFile{}, false
+	}
+	if !ok {
+		writeOpenAIError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such File object: %s", c.Param("id")))
+	}
+	return file, ok
+}
+
+// GetFile handles GET /v1/files/:id.
+func (h *OpenAIBatchAPIHandler) GetFile(c *gin.Context) {
+	if file, ok := h.lookupFile(c); ok {
+		c.JSON(http.StatusOK, file)
+	}
+}
+
+// GetFileContent handles GET /v1/files/:id/content.
+func (h *OpenAIBatchAPIHandler) GetFileContent(c *gin.Context) {
+	file, ok := h.lookupFile(c)
+	if !ok {
+		return
+	}
+	content, ok, err := h.getFileContent(c.Request.Context(), file.ID)
+	if err != nil || !ok {
+		writeOpenAIError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No content for File object: %s", file.ID))
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.Data(http.StatusOK, "application/jsonl", content)
+}
+
+// DeleteFile handles DELETE /v1/files/:id.
+func (h *OpenAIBatchAPIHandler) DeleteFile(c *gin.Context) {
+	file, ok := h.lookupFile(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	store := h.store()
+	errContent := store.Delete(ctx, state.NamespaceOpenAIFiles, fileContentKey(file.ID))
+	errMeta := store.Delete(ctx, state.NamespaceOpenAIFiles, file.ID)
+	errIndex := h.updateIndex(ctx, state.NamespaceOpenAIFiles, func(ids []string) []string { return removeID(ids, file.ID) })
+	if err := errors.Join(errContent, errMeta, errIndex); err != nil {
+		log.Errorf("failed to delete file %s: %v", file.ID, err)
+		writeOpenAIError(c, http.StatusInternalServerError, "server_error", "failed to delete file")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": file.ID, "object": "file", "deleted": true})
+}
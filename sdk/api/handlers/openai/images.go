@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// defaultImageModel is used when an image generation request does not name a model.
+const defaultImageModel = "gpt-image-1"
+
+// ImageGenerations handles the /v1/images/generations endpoint. The request is routed like any
+// other model call: Gemini and Imagen upstreams receive a translated request, OpenAI-compatible
+// upstreams receive it unchanged, and the result is returned in the OpenAI images format.
+func (h *OpenAIAPIHandler) ImageGenerations(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	if strings.TrimSpace(gjson.GetBytes(rawJSON, "prompt").String()) == "" {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "prompt is required",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	modelName := strings.TrimSpace(gjson.GetBytes(rawJSON, "model").String())
+	if modelName == "" {
+		modelName = defaultImageModel
+		rawJSON, _ = sjson.SetBytes(rawJSON, "model", modelName)
+	}
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, constant.OpenAIImage, modelName, rawJSON, "")
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	cliCancel()
+
+	status := http.StatusOK
+	switch {
+	case gjson.GetBytes(resp, "error").Exists():
+		status = http.StatusBadRequest
+	case len(gjson.GetBytes(resp, "data").Array()) == 0:
+		c.JSON(http.StatusBadGateway, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Upstream returned no images",
+				Type:    "server_error",
+			},
+		})
+		return
+	}
+	c.Data(status, "application/json", resp)
+}
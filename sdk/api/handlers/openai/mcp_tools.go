@@ -0,0 +1,189 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mcp"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const defaultMCPToolRounds = 8
+
+// mcpToolbox returns the MCP toolbox for rawJSON's model, or nil when no MCP tools apply.
+func (h *OpenAIAPIHandler) mcpToolbox(ctx context.Context, rawJSON []byte) (*mcp.Toolbox, []mcp.Tool) {
+	if h.Cfg == nil || !mcp.AppliesTo(h.Cfg.MCPClient, gjson.GetBytes(rawJSON, "model").String()) {
+		return nil, nil
+	}
+	toolbox := mcp.SharedToolbox(h.Cfg.MCPClient)
+	tools := toolbox.Tools(ctx)
+	if len(tools) == 0 {
+		return nil, nil
+	}
+	return toolbox, tools
+}
+
+// handleMCPTools serves a chat completion with the configured MCP tools merged in. The
+// upstream is called without streaming; streaming clients receive the final completion as
+// chunks.
+func (h *OpenAIAPIHandler) handleMCPTools(c *gin.Context, rawJSON []byte, stream bool, toolbox *mcp.Toolbox, tools []mcp.Tool) {
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	execute := func(body []byte) ([]byte, *interfaces.ErrorMessage) {
+		return h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, body, h.GetAlt(c))
+	}
+	call := func(name string, arguments []byte) mcp.ToolResult {
+		return toolbox.Call(cliCtx, name, arguments)
+	}
+	resp, errMsg := runMCPToolLoop(rawJSON, tools, toolbox.Has, h.Cfg.MCPClient.MaxIterations, execute, call)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	if stream {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.Write(completionToChunks(resp, gjson.GetBytes(rawJSON, "stream_options.include_usage").Bool()))
+	} else {
+		c.Header("Content-Type", "application/json")
+		_, _ = c.Writer.Write(resp)
+	}
+	cliCancel()
+}
+
+// runMCPToolLoop sends the request with the MCP tools added, executes every call of them and
+// continues the conversation until the model answers or calls one of the client's own tools.
+// MCP calls never reach the client: the returned completion carries the text of every round
+// and only the client's tool calls.
+func runMCPToolLoop(rawJSON []byte, tools []mcp.Tool, isMCPTool func(string) bool, maxRounds int, execute func([]byte) ([]byte, *interfaces.ErrorMessage), call func(string, []byte) mcp.ToolResult) ([]byte, *interfaces.ErrorMessage) {
+	if maxRounds <= 0 {
+		maxRounds = defaultMCPToolRounds
+	}
+	body, _ := sjson.SetBytes(rawJSON, "stream", false)
+	body, _ = sjson.DeleteBytes(body, "stream_options")
+	if !gjson.GetBytes(body, "tools").IsArray() {
+		body, _ = sjson.SetRawBytes(body, "tools", []byte("[]"))
+	}
+	for _, tool := range tools {
+		def := `{"type":"function","function":{"name":"","description":"","parameters":{}}}`
+		def, _ = sjson.Set(def, "function.name", tool.Name)
+		def, _ = sjson.Set(def, "function.description", tool.Description)
+		def, _ = sjson.SetRaw(def, "function.parameters", string(tool.InputSchema))
+		body, _ = sjson.SetRawBytes(body, "tools.-1", []byte(def))
+	}
+
+	var texts []string
+	var promptTokens, completionTokens int64
+	for round := 0; ; round++ {
+		resp, errMsg := execute(body)
+		if errMsg != nil {
+			return nil, errMsg
+		}
+		promptTokens += gjson.GetBytes(resp, "usage.prompt_tokens").Int()
+		completionTokens += gjson.GetBytes(resp, "usage.completion_tokens").Int()
+
+		message := gjson.GetBytes(resp, "choices.0.message")
+		var kept []string
+		mcpCalls := 0
+		for _, toolCall := range message.Get("tool_calls").Array() {
+			if isMCPTool(toolCall.Get("function.name").String()) {
+				mcpCalls++
+				continue
+			}
+			kept = append(kept, toolCall.Raw)
+		}
+		if mcpCalls == 0 || len(kept) > 0 || round >= maxRounds {
+			return finishMCPCompletion(resp, texts, kept, promptTokens, completionTokens), nil
+		}
+
+		if text := message.Get("content").String(); text != "" {
+			texts = append(texts, text)
+		}
+		body, _ = sjson.SetRawBytes(body, "messages.-1", []byte(message.Raw))
+		for _, toolCall := range message.Get("tool_calls").Array() {
+			result := call(toolCall.Get("function.name").String(), []byte(toolCall.Get("function.arguments").String()))
+			content := result.Text
+			if result.IsError {
+				content = "Error: " + content
+			}
+			toolMessage := `{"role":"tool","tool_call_id":"","content":""}`
+			toolMessage, _ = sjson.Set(toolMessage, "tool_call_id", toolCall.Get("id").String())
+			toolMessage, _ = sjson.Set(toolMessage, "content", content)
+			body, _ = sjson.SetRawBytes(body, "messages.-1", []byte(toolMessage))
+		}
+	}
+}
+
+// finishMCPCompletion builds the client completion from the last upstream response, the text
+// of earlier rounds and the client's own tool calls.
+func finishMCPCompletion(resp []byte, texts, toolCalls []string, promptTokens, completionTokens int64) []byte {
+	out := resp
+	if content := gjson.GetBytes(resp, "choices.0.message.content").String(); content != "" || len(texts) > 0 {
+		all := append(append([]string{}, texts...), content)
+		out, _ = sjson.SetBytes(out, "choices.0.message.content", strings.TrimSpace(strings.Join(all, "\n\n")))
+	}
+	if len(toolCalls) > 0 {
+		out, _ = sjson.SetRawBytes(out, "choices.0.message.tool_calls", []byte("["+strings.Join(toolCalls, ",")+"]"))
+	} else {
+		out, _ = sjson.DeleteBytes(out, "choices.0.message.tool_calls")
+		if gjson.GetBytes(out, "choices.0.finish_reason").String() == "tool_calls" {
+			out, _ = sjson.SetBytes(out, "choices.0.finish_reason", "stop")
+		}
+	}
+	if gjson.GetBytes(out, "usage").Exists() {
+		out, _ = sjson.SetBytes(out, "usage.prompt_tokens", promptTokens)
+		out, _ = sjson.SetBytes(out, "usage.completion_tokens", completionTokens)
+		out, _ = sjson.SetBytes(out, "usage.total_tokens", promptTokens+completionTokens)
+	}
+	return out
+}
+
+// completionToChunks renders a complete chat completion as the server-sent events of a
+// stream: one delta with the whole message, one with the finish reason, then [DONE].
+func completionToChunks(completion []byte, includeUsage bool) []byte {
+	var buf bytes.Buffer
+	write := func(chunk string) {
+		buf.WriteString("data: " + chunk + "\n\n")
+	}
+	base := `{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[]}`
+	base, _ = sjson.Set(base, "id", gjson.GetBytes(completion, "id").String())
+	base, _ = sjson.Set(base, "created", gjson.GetBytes(completion, "created").Int())
+	base, _ = sjson.Set(base, "model", gjson.GetBytes(completion, "model").String())
+
+	message := gjson.GetBytes(completion, "choices.0.message")
+	delta := `{"role":"assistant"}`
+	if content := message.Get("content"); content.Exists() {
+		delta, _ = sjson.Set(delta, "content", content.String())
+	}
+	if reasoning := message.Get("reasoning_content"); reasoning.Exists() {
+		delta, _ = sjson.Set(delta, "reasoning_content", reasoning.String())
+	}
+	message.Get("tool_calls").ForEach(func(key, toolCall gjson.Result) bool {
+		indexed, _ := sjson.Set(toolCall.Raw, "index", key.Int())
+		delta, _ = sjson.SetRaw(delta, "tool_calls.-1", indexed)
+		return true
+	})
+	chunk, _ := sjson.SetRaw(base, "choices.-1", fmt.Sprintf(`{"index":0,"delta":%s,"finish_reason":null}`, delta))
+	write(chunk)
+
+	finish, _ := sjson.SetRaw(base, "choices.-1", `{"index":0,"delta":{},"finish_reason":null}`)
+	finish, _ = sjson.Set(finish, "choices.0.finish_reason", gjson.GetBytes(completion, "choices.0.finish_reason").String())
+	write(finish)
+
+	if usage := gjson.GetBytes(completion, "usage"); includeUsage && usage.Exists() {
+		usageChunk, _ := sjson.SetRaw(base, "usage", usage.Raw)
+		write(usageChunk)
+	}
+	buf.WriteString("data: [DONE]\n\n")
+	return buf.Bytes()
+}
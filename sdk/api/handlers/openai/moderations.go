@@ -0,0 +1,125 @@
+package openai
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/guardrail"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// defaultModerationModel is reported when the client does not name a model.
+const defaultModerationModel = "cliproxy-guardrails"
+
+type moderationResult struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// Moderations handles the /v1/moderations endpoint. Each input is screened by the moderators
+// listed in guardrails.endpoint-moderators (all configured moderators when empty) and the
+// merged verdict is returned in the OpenAI moderation format.
+func (h *OpenAIAPIHandler) Moderations(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	if h.Cfg == nil || len(h.Cfg.Guardrails.Moderators) == 0 {
+		c.JSON(http.StatusNotImplemented, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "No moderators are configured on this proxy",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	inputs, errInput := moderationInputs(gjson.GetBytes(rawJSON, "input"))
+	if errInput != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: errInput.Error(),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	model := strings.TrimSpace(gjson.GetBytes(rawJSON, "model").String())
+	if model == "" {
+		model = defaultModerationModel
+	}
+
+	ctx := c.Request.Context()
+	results := make([]moderationResult, 0, len(inputs))
+	for _, text := range inputs {
+		res, errMod := guardrail.Moderate(ctx, h.Cfg.Guardrails, h.Cfg.Guardrails.EndpointModerators, h.ModerationInput([]string{text}))
+		if errMod != nil {
+			log.Warnf("moderations: %v", errMod)
+			c.JSON(http.StatusBadGateway, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{
+					Message: "Moderation backend failed",
+					Type:    "server_error",
+				},
+			})
+			return
+		}
+		entry := moderationResult{Flagged: res.Flagged, Categories: res.Categories, CategoryScores: res.Scores}
+		if entry.Categories == nil {
+			entry.Categories = map[string]bool{}
+		}
+		if entry.CategoryScores == nil {
+			entry.CategoryScores = map[string]float64{}
+		}
+		results = append(results, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      "modr-" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		"model":   model,
+		"results": results,
+	})
+}
+
+// moderationInputs accepts a string, an array of strings, or an array of multimodal parts of
+// which only text parts are screened.
+func moderationInputs(input gjson.Result) ([]string, error) {
+	switch {
+	case input.Type == gjson.String:
+		return []string{input.Str}, nil
+	case input.IsArray():
+		var out []string
+		var errPart error
+		input.ForEach(func(_, item gjson.Result) bool {
+			switch {
+			case item.Type == gjson.String:
+				out = append(out, item.Str)
+			case item.IsObject():
+				if item.Get("type").String() == "text" {
+					out = append(out, item.Get("text").String())
+				}
+			default:
+				errPart = fmt.Errorf("input items must be strings or content parts")
+				return false
+			}
+			return true
+		})
+		if errPart != nil {
+			return nil, errPart
+		}
+		if len(out) == 0 {
+			return nil, fmt.Errorf("input must contain at least one text item")
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("input is required")
+}
@@ -63,8 +63,11 @@ func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
 	allModels := h.Models()
 
 	// Filter to only include the 4 required fields: id, object, created, owned_by
-	filteredModels := make([]map[string]any, len(allModels))
-	for i, model := range allModels {
+	filteredModels := make([]map[string]any, 0, len(allModels))
+	for _, model := range allModels {
+		if id, _ := model["id"].(string); !h.KeyAllowsModel(c, id) {
+			continue
+		}
 		filteredModel := map[string]any{
 			"id":     model["id"],
 			"object": model["object"],
@@ -80,7 +83,7 @@ func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
 			filteredModel["owned_by"] = ownedBy
 		}
 
-		filteredModels[i] = filteredModel
+		filteredModels = append(filteredModels, filteredModel)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -89,6 +92,32 @@ func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
 	})
 }
 
+// OpenAIModel handles GET /v1/models/{model} for a single model.
+func (h *OpenAIAPIHandler) OpenAIModel(c *gin.Context) {
+	id := c.Param("id")
+	for _, model := range h.Models() {
+		if model["id"] != id || !h.KeyAllowsModel(c, id) {
+			continue
+		}
+		result := map[string]any{"id": model["id"], "object": model["object"]}
+		if created, exists := model["created"]; exists {
+			result["created"] = created
+		}
+		if ownedBy, exists := model["owned_by"]; exists {
+			result["owned_by"] = ownedBy
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: fmt.Sprintf("The model '%s' does not exist", id),
+			Type:    "invalid_request_error",
+			Code:    "model_not_found",
+		},
+	})
+}
+
 // ChatCompletions handles the /v1/chat/completions endpoint.
 // It determines whether the request is for a streaming or non-streaming response
 // and calls the appropriate handler based on the model provider.
@@ -120,6 +149,10 @@ func (h *OpenAIAPIHandler) ChatCompletions(c *gin.Context) {
 		stream = gjson.GetBytes(rawJSON, "stream").Bool()
 	}
 
+	if toolbox, tools := h.mcpToolbox(c.Request.Context(), rawJSON); toolbox != nil {
+		h.handleMCPTools(c, rawJSON, stream, toolbox, tools)
+		return
+	}
 	if stream {
 		h.handleStreamingResponse(c, rawJSON)
 	} else {
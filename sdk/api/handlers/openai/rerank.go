@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// Rerank handles the /v1/rerank endpoint. Requests use the common rerank schema (model, query,
+// documents, top_n, return_documents) and are translated to the Cohere, Voyage or Jina dialect
+// configured on the upstream provider.
+func (h *OpenAIAPIHandler) Rerank(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	modelName := strings.TrimSpace(gjson.GetBytes(rawJSON, "model").String())
+	var problem string
+	switch {
+	case modelName == "":
+		problem = "model is required"
+	case strings.TrimSpace(gjson.GetBytes(rawJSON, "query").String()) == "":
+		problem = "query is required"
+	case len(gjson.GetBytes(rawJSON, "documents").Array()) == 0:
+		problem = "documents must be a non-empty array"
+	}
+	if problem != "" {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: problem,
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, constant.Rerank, modelName, rawJSON, "")
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	cliCancel()
+	c.Data(http.StatusOK, "application/json", resp)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+)
+
+// Per-request override headers, honoured for keys listed in request-overrides.api-keys.
+const (
+	TargetModelHeader = "X-CLIProxy-Target-Model"
+	ProviderHeader    = "X-CLIProxy-Provider"
+	AuthHeader        = "X-CLIProxy-Auth"
+)
+
+// requestOverrides holds the overrides a request asked for.
+type requestOverrides struct {
+	model    string
+	provider string
+	authID   string
+}
+
+// pinned reports whether the request targets a specific provider or credential.
+func (o requestOverrides) pinned() bool {
+	return o.provider != "" || o.authID != ""
+}
+
+// applyRequestOverrides reads the override headers. It returns the model to route, a context
+// pinning the requested credential and the overrides, or a 403 when the client key is not allowed
+// to use them. Requests without override headers are subject to scheduled routing and, when no
+// schedule is active, canary routing. Tenant scoping and model aliases are applied first.
+func (h *BaseAPIHandler) applyRequestOverrides(ctx context.Context, modelName string, rawJSON []byte) (context.Context, string, requestOverrides, *interfaces.ErrorMessage) {
+	ctx, modelName = h.applyTenant(ctx, modelName)
+	var o requestOverrides
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil && ginCtx.Request != nil {
+		o.model = strings.TrimSpace(ginCtx.GetHeader(TargetModelHeader))
+		o.provider = strings.ToLower(strings.TrimSpace(ginCtx.GetHeader(ProviderHeader)))
+		o.authID = strings.TrimSpace(ginCtx.GetHeader(AuthHeader))
+	}
+	if o.model == "" && !o.pinned() {
+		var scheduled bool
+		if ctx, modelName, o, scheduled = h.applySchedule(ctx, modelName); scheduled {
+			return ctx, modelName, o, nil
+		}
+		modelName, o = h.applyCanary(ctx, modelName, rawJSON)
+		return ctx, modelName, o, nil
+	}
+	for _, requested := range []struct{ kind, header, value string }{
+		{"model", TargetModelHeader, o.model},
+		{"provider", ProviderHeader, o.provider},
+		{"auth", AuthHeader, o.authID},
+	} {
+		if requested.value != "" && !h.overrideAllowed(ctx, requested.kind) {
+			return ctx, modelName, o, &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("%s is not permitted for this API key", requested.header)}
+		}
+	}
+	if o.authID != "" {
+		if h.AuthManager == nil {
+			return ctx, modelName, o, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown auth %q", o.authID)}
+		}
+		auth, ok := h.AuthManager.GetByID(o.authID)
+		if !ok {
+			return ctx, modelName, o, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown auth %q", o.authID)}
+		}
+		if o.provider != "" && !strings.EqualFold(auth.Provider, o.provider) {
+			return ctx, modelName, o, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("auth %q does not belong to provider %s", o.authID, o.provider)}
+		}
+		o.provider = strings.ToLower(auth.Provider)
+		ctx = coreauth.WithPinnedAuth(ctx, o.authID)
+	}
+	trace.Record(ctx, "override", "request override headers applied",
+		map[string]any{"from": modelName, "model": o.model, "provider": o.provider, "auth_id": o.authID})
+	if o.model != "" {
+		modelName = o.model
+	}
+	return ctx, modelName, o, nil
+}
+
+// filterProviders restricts providers to the pinned provider.
+func (o requestOverrides) filterProviders(modelName string, providers []string) ([]string, *interfaces.ErrorMessage) {
+	if o.provider == "" {
+		return providers, nil
+	}
+	for _, p := range providers {
+		if strings.EqualFold(p, o.provider) {
+			return []string{p}, nil
+		}
+	}
+	return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("provider %s does not serve model %s", o.provider, modelName)}
+}
+
+// overrideAllowed reports whether the request's client key may use the given override kind.
+func (h *BaseAPIHandler) overrideAllowed(ctx context.Context, kind string) bool {
+	if h.Cfg == nil {
+		return false
+	}
+	cfg := h.Cfg.RequestOverrides
+	if len(cfg.Allow) > 0 {
+		allowed := false
+		for _, a := range cfg.Allow {
+			if strings.EqualFold(strings.TrimSpace(a), kind) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	key := ""
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+		if v, ok := ginCtx.Get("apiKey"); ok {
+			key, _ = v.(string)
+		}
+	}
+	for _, allowedKey := range cfg.APIKeys {
+		if allowedKey == "*" || (key != "" && allowedKey == key) {
+			return true
+		}
+	}
+	return false
+}
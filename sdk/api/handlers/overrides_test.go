@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func overrideContext(apiKey string, headers map[string]string) context.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	for k, v := range headers {
+		c.Request.Header.Set(k, v)
+	}
+	if apiKey != "" {
+		c.Set("apiKey", apiKey)
+	}
+	return context.WithValue(context.Background(), "gin", c)
+}
+
+func TestApplyRequestOverrides(t *testing.T) {
+	manager := coreauth.NewManager(nil, nil, nil)
+	if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: "claude-a", Provider: "claude"}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{RequestOverrides: sdkconfig.RequestOverridesConfig{
+		APIKeys: []string{"trusted"},
+		Allow:   []string{"model", "auth"},
+	}}, manager)
+
+	ctx, model, o, errMsg := h.applyRequestOverrides(overrideContext("trusted", map[string]string{
+		TargetModelHeader: "claude-sonnet-4",
+		AuthHeader:        "claude-a",
+	}), "gpt-4o", nil)
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if model != "claude-sonnet-4" || o.provider != "claude" || coreauth.PinnedAuthFromContext(ctx) != "claude-a" {
+		t.Fatalf("unexpected overrides: model=%s provider=%s pinned=%s", model, o.provider, coreauth.PinnedAuthFromContext(ctx))
+	}
+	if providers, errFilter := o.filterProviders(model, []string{"gemini", "claude"}); errFilter != nil || len(providers) != 1 || providers[0] != "claude" {
+		t.Fatalf("filterProviders = %v, %v", providers, errFilter)
+	}
+	if _, errFilter := o.filterProviders(model, []string{"gemini"}); errFilter == nil {
+		t.Fatal("expected error when the pinned provider does not serve the model")
+	}
+
+	if _, _, _, errMsg = h.applyRequestOverrides(overrideContext("other", map[string]string{TargetModelHeader: "x"}), "gpt-4o", nil); errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for untrusted key, got %+v", errMsg)
+	}
+	if _, _, _, errMsg = h.applyRequestOverrides(overrideContext("trusted", map[string]string{ProviderHeader: "claude"}), "gpt-4o", nil); errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a disallowed override kind, got %+v", errMsg)
+	}
+	if _, model, _, errMsg = h.applyRequestOverrides(overrideContext("other", nil), "gpt-4o", nil); errMsg != nil || model != "gpt-4o" {
+		t.Fatalf("requests without override headers must pass through, got %s %+v", model, errMsg)
+	}
+}
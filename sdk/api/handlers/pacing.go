@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// streamPacer releases streamed text no faster than a target token rate. Text deltas are split
+// into words and each word is sent once its slot is due; other events, including tool call
+// deltas, are sent immediately. A nil pacer forwards chunks unchanged.
+type streamPacer struct {
+	tokensPerSecond float64
+	next            time.Time
+}
+
+func newStreamPacer(cfg *config.SDKConfig) *streamPacer {
+	if cfg == nil || cfg.Streaming.Pacing.TokensPerSecond <= 0 {
+		return nil
+	}
+	return &streamPacer{tokensPerSecond: float64(cfg.Streaming.Pacing.TokensPerSecond)}
+}
+
+// pace sends chunk through send, split and delayed as needed. It returns false when ctx ends
+// while waiting.
+func (p *streamPacer) pace(ctx context.Context, chunk []byte, send func([]byte)) bool {
+	if p == nil {
+		send(chunk)
+		return true
+	}
+	for _, b := range sse.SplitBlocks(chunk) {
+		path := sse.TextPath(b.Payload)
+		if path == "" {
+			send(b.Bytes())
+			continue
+		}
+		text := gjson.GetBytes(b.Payload, path).String()
+		// Events that also carry the finish reason or usage are not split so those fields are
+		// sent exactly once.
+		pieces := []string{text}
+		if !sse.EndsContent(b.Payload) {
+			pieces = splitWords(text)
+		}
+		for _, piece := range pieces {
+			if !p.wait(ctx) {
+				return false
+			}
+			if len(pieces) == 1 {
+				send(b.Bytes())
+			} else {
+				send(b.WithText(path, piece))
+			}
+			tokens := max((utf8.RuneCountInString(piece)+3)/4, 1)
+			p.next = p.next.Add(time.Duration(float64(tokens) / p.tokensPerSecond * float64(time.Second)))
+		}
+	}
+	return true
+}
+
+// wait blocks until the next slot is due. Slots never accumulate while the upstream is slower
+// than the target rate.
+func (p *streamPacer) wait(ctx context.Context) bool {
+	now := time.Now()
+	if !p.next.After(now) {
+		p.next = now
+		return true
+	}
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	timer := time.NewTimer(p.next.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// splitWords splits text after each run of whitespace, keeping the whitespace with the word
+// before it so the pieces concatenate back to text.
+func splitWords(text string) []string {
+	var pieces []string
+	start := 0
+	inSpace := false
+	for i, r := range text {
+		space := unicode.IsSpace(r)
+		if inSpace && !space {
+			pieces = append(pieces, text[start:i])
+			start = i
+		}
+		inSpace = space
+	}
+	if start < len(text) || len(pieces) == 0 {
+		pieces = append(pieces, text[start:])
+	}
+	return pieces
+}
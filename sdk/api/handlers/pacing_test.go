@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestStreamPacerSplitsTextAndKeepsRate(t *testing.T) {
+	pacer := newStreamPacer(&config.SDKConfig{Streaming: config.StreamingConfig{Pacing: config.StreamPacingConfig{TokensPerSecond: 100}}})
+	var sent [][]byte
+	send := func(part []byte) { sent = append(sent, part) }
+
+	start := time.Now()
+	chunk := []byte(`{"choices":[{"index":0,"delta":{"content":"one two three four five six"},"finish_reason":null}]}`)
+	if !pacer.pace(context.Background(), chunk, send) {
+		t.Fatal("pace returned false")
+	}
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Fatalf("six words at 100 tokens/s took %v, want >= 50ms", elapsed)
+	}
+	if len(sent) != 6 {
+		t.Fatalf("sent %d events, want 6", len(sent))
+	}
+	var text strings.Builder
+	for _, part := range sent {
+		text.WriteString(gjson.GetBytes(part, "choices.0.delta.content").String())
+	}
+	if text.String() != "one two three four five six" {
+		t.Fatalf("text = %q", text.String())
+	}
+
+	tool := []byte(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"a\": 1, \"b\": 2}"}}]}}]}`)
+	sent = nil
+	pacer.pace(context.Background(), tool, send)
+	if len(sent) != 1 || string(sent[0]) != string(tool) {
+		t.Fatalf("tool call delta was modified: %q", sent)
+	}
+}
+
+func TestStreamPacerDisabledByDefault(t *testing.T) {
+	if newStreamPacer(&config.SDKConfig{}) != nil {
+		t.Fatal("pacing should be off by default")
+	}
+	var sent []byte
+	chunk := []byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"a b c\"}}\n\n")
+	(*streamPacer)(nil).pace(context.Background(), chunk, func(part []byte) { sent = part })
+	if string(sent) != string(chunk) {
+		t.Fatalf("sent = %q", sent)
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	got := splitWords("  hello  world\n!")
+	want := []string{"  ", "hello  ", "world\n", "!"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("splitWords = %q", got)
+	}
+}
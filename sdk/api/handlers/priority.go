@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// priorityHeader lets clients tag a request as "interactive" (default) or "batch".
+const priorityHeader = "X-CLIProxy-Priority"
+
+// withRequestPriority attaches the request's priority class to ctx unless the caller already set
+// one. Keys listed in request-priority.batch-api-keys are always batch; otherwise the priority
+// header decides.
+func (h *BaseAPIHandler) withRequestPriority(ctx context.Context) context.Context {
+	if _, ok := coreauth.LookupPriority(ctx); ok {
+		return ctx
+	}
+	ginCtx := ginContextFrom(ctx)
+	if ginCtx == nil {
+		return ctx
+	}
+	if h.Cfg != nil {
+		if key, ok := ginCtx.Get("apiKey"); ok {
+			if s, okStr := key.(string); okStr && s != "" {
+				for _, batchKey := range h.Cfg.RequestPriority.BatchAPIKeys {
+					if batchKey == s {
+						return coreauth.WithPriority(ctx, coreauth.PriorityBatch)
+					}
+				}
+			}
+		}
+		if h.Cfg.RequestPriority.IgnoreHeader {
+			return ctx
+		}
+	}
+	if ginCtx.Request == nil {
+		return ctx
+	}
+	if p, ok := coreauth.ParsePriority(ginCtx.Request.Header.Get(priorityHeader)); ok {
+		return coreauth.WithPriority(ctx, p)
+	}
+	return ctx
+}
+
+// withQueueKey schedules the request under the client API key for fair upstream queuing unless
+// the caller already set a key.
+func (h *BaseAPIHandler) withQueueKey(ctx context.Context) context.Context {
+	if coreauth.QueueKeyFromContext(ctx) != "" {
+		return ctx
+	}
+	ginCtx := ginContextFrom(ctx)
+	if ginCtx == nil {
+		return ctx
+	}
+	if key, ok := ginCtx.Get("apiKey"); ok {
+		if s, okStr := key.(string); okStr && s != "" {
+			return coreauth.WithQueueKey(ctx, s)
+		}
+	}
+	return ctx
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pii"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+var redactorState struct {
+	mu        sync.Mutex
+	signature string
+	redactor  *pii.Redactor
+}
+
+// redactorFor returns the PII redactor for the current configuration, rebuilding it when the
+// settings change. Invalid settings disable redaction and are logged once per change.
+func redactorFor(cfg *config.SDKConfig) *pii.Redactor {
+	if cfg == nil || !cfg.PIIRedaction.Enable {
+		return nil
+	}
+	raw, _ := json.Marshal(cfg.PIIRedaction)
+	signature := string(raw)
+
+	redactorState.mu.Lock()
+	defer redactorState.mu.Unlock()
+	if redactorState.signature == signature {
+		return redactorState.redactor
+	}
+	redactor, err := pii.New(cfg.PIIRedaction)
+	if err != nil {
+		log.Errorf("pii redaction disabled: %v", err)
+		redactor = nil
+	}
+	redactorState.signature = signature
+	redactorState.redactor = redactor
+	return redactor
+}
+
+// redactPrompt masks PII in the client request when any candidate provider is in scope. The
+// returned session restores placeholders in responses; it is nil when nothing was redacted.
+func (h *BaseAPIHandler) redactPrompt(providers []string, rawJSON []byte) ([]byte, *pii.Session) {
+	if h == nil {
+		return rawJSON, nil
+	}
+	redactor := redactorFor(h.Cfg)
+	if !redactor.AppliesTo(providers) {
+		return rawJSON, nil
+	}
+	session := redactor.NewSession()
+	redacted := session.RedactJSON(rawJSON)
+	if session.Len() == 0 {
+		return rawJSON, nil
+	}
+	log.Debugf("pii redaction masked %d value(s) before sending upstream", session.Len())
+	return redacted, session
+}
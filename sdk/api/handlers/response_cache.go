@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/state"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// ResponseCacheHeader reports the cache outcome (HIT, MISS, BYPASS) and accepts "bypass" on requests.
+	ResponseCacheHeader = "X-CLIProxy-Cache"
+
+	responseCacheHit    = "HIT"
+	responseCacheMiss   = "MISS"
+	responseCacheBypass = "BYPASS"
+
+	responseCacheKeyStrategyBody       = "body"
+	responseCacheKeyStrategyBodyPerKey = "body-per-key"
+)
+
+// responseCacheVolatileFields never influence the upstream answer and are always dropped from keys.
+var responseCacheVolatileFields = []string{"stream", "stream_options"}
+
+type responseCacheState struct {
+	mu        sync.Mutex
+	signature string
+	store     cache.ResponseStore
+}
+
+// responseCacheStore returns the store matching the current configuration, rebuilding it when the
+// backend settings change. It returns nil when caching is disabled or the backend cannot be built.
+func (s *responseCacheState) responseCacheStore(cfg *config.SDKConfig) cache.ResponseStore {
+	if s == nil || cfg == nil || !cfg.ResponseCache.Enable {
+		return nil
+	}
+	rc := cfg.ResponseCache
+	backend := strings.ToLower(strings.TrimSpace(rc.Backend))
+	signature := fmt.Sprintf("%s|%d|%s|%s|%d|%s", backend, rc.MaxEntries, rc.Redis.Addr, rc.Redis.Password, rc.Redis.DB, rc.Redis.KeyPrefix)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store != nil && s.signature == signature {
+		return s.store
+	}
+	if s.store != nil {
+		_ = s.store.Close()
+		s.store = nil
+	}
+
+	var store cache.ResponseStore
+	switch backend {
+	case "redis":
+		redisStore, err := cache.NewRedisResponseStore(cache.RedisResponseStoreConfig{
+			Addr:      rc.Redis.Addr,
+			Password:  rc.Redis.Password,
+			DB:        rc.Redis.DB,
+			KeyPrefix: rc.Redis.KeyPrefix,
+		})
+		if err != nil {
+			log.Errorf("response cache disabled: %v", err)
+			return nil
+		}
+		store = redisStore
+	case "", "memory":
+		store = cache.NewMemoryResponseStore(rc.MaxEntries)
+	case "state":
+		durable := state.Default()
+		if durable == nil {
+			log.Errorf("response cache disabled: backend \"state\" requires state-store to be enabled")
+			return nil
+		}
+		store = cache.NewStateResponseStore(durable)
+	default:
+		log.Errorf("response cache disabled: unsupported backend %q", rc.Backend)
+		return nil
+	}
+	s.store = store
+	s.signature = signature
+	return store
+}
+
+func responseCacheTTL(cfg *config.SDKConfig) time.Duration {
+	if cfg == nil || cfg.ResponseCache.TTLSeconds <= 0 {
+		return cache.DefaultResponseCacheTTL
+	}
+	return time.Duration(cfg.ResponseCache.TTLSeconds) * time.Second
+}
+
+// isDeterministicRequest reports whether the payload explicitly pins temperature to 0 and
+// declares no tools, which makes a cached answer a faithful substitute for a fresh one.
+func isDeterministicRequest(rawJSON []byte) bool {
+	root := gjson.ParseBytes(rawJSON)
+	temperature := firstExisting(root, "temperature", "generationConfig.temperature", "request.generationConfig.temperature")
+	if !temperature.Exists() || temperature.Type != gjson.Number || temperature.Float() != 0 {
+		return false
+	}
+	for _, path := range []string{"tools", "functions", "request.tools"} {
+		if tools := root.Get(path); tools.Exists() && tools.IsArray() && len(tools.Array()) > 0 {
+			return false
+		}
+	}
+	if n := root.Get("n"); n.Exists() && n.Int() > 1 {
+		return false
+	}
+	return true
+}
+
+func firstExisting(root gjson.Result, paths ...string) gjson.Result {
+	for _, path := range paths {
+		if v := root.Get(path); v.Exists() {
+			return v
+		}
+	}
+	return gjson.Result{}
+}
+
+// normalizeCacheBody canonicalises a JSON request body so semantically identical payloads
+// (differing only in key order, whitespace or ignored fields) produce the same bytes.
+func normalizeCacheBody(rawJSON []byte, ignoreFields []string) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(rawJSON))
+	decoder.UseNumber()
+	var body map[string]any
+	if err := decoder.Decode(&body); err != nil || body == nil {
+		return nil, false
+	}
+	for _, field := range responseCacheVolatileFields {
+		delete(body, field)
+	}
+	for _, field := range ignoreFields {
+		delete(body, strings.TrimSpace(field))
+	}
+	normalized, err := json.Marshal(body)
+	if err != nil {
+		return nil, false
+	}
+	return normalized, true
+}
+
+// responseCacheKey derives the cache key for a request according to the configured strategy.
+func responseCacheKey(rc config.ResponseCacheConfig, handlerType, modelName, clientKey string, rawJSON []byte) (string, bool) {
+	normalized, ok := normalizeCacheBody(rawJSON, rc.IgnoreFields)
+	if !ok {
+		return "", false
+	}
+	h := sha256.New()
+	_, _ = h.Write([]byte(handlerType))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(modelName))
+	_, _ = h.Write([]byte{0})
+	switch strings.ToLower(strings.TrimSpace(rc.KeyStrategy)) {
+	case "", responseCacheKeyStrategyBody:
+	case responseCacheKeyStrategyBodyPerKey:
+		_, _ = h.Write([]byte(clientKey))
+		_, _ = h.Write([]byte{0})
+	default:
+		return "", false
+	}
+	_, _ = h.Write(normalized)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// cacheBypassRequested honours Cache-Control: no-cache/no-store and X-CLIProxy-Cache: bypass.
+// Requests pinned to a provider or credential also bypass the cache so A/B results stay distinct.
+func cacheBypassRequested(c *gin.Context) bool {
+	if c == nil || c.Request == nil {
+		return false
+	}
+	if strings.EqualFold(strings.TrimSpace(c.GetHeader(ResponseCacheHeader)), "bypass") {
+		return true
+	}
+	if c.GetHeader(ProviderHeader) != "" || c.GetHeader(AuthHeader) != "" {
+		return true
+	}
+	for _, directive := range strings.Split(c.GetHeader("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache", "no-store":
+			return true
+		}
+	}
+	return false
+}
+
+func ginContextFrom(ctx context.Context) *gin.Context {
+	if ctx == nil {
+		return nil
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	return ginCtx
+}
+
+func setResponseCacheHeader(c *gin.Context, outcome string) {
+	if c == nil || c.Writer == nil || c.Writer.Written() {
+		return
+	}
+	c.Header(ResponseCacheHeader, outcome)
+}
+
+// lookupResponseCache returns a cached payload when available. On a miss it returns the key and
+// store the caller should use to persist the fresh response; both are empty when caching does not apply.
+func (h *BaseAPIHandler) lookupResponseCache(ctx context.Context, handlerType, modelName string, rawJSON []byte) (cached []byte, key string, store cache.ResponseStore) {
+	if h == nil || h.Cfg == nil || !h.Cfg.ResponseCache.Enable {
+		return nil, "", nil
+	}
+	ginCtx := ginContextFrom(ctx)
+	if !isDeterministicRequest(rawJSON) {
+		return nil, "", nil
+	}
+	if cacheBypassRequested(ginCtx) {
+		setResponseCacheHeader(ginCtx, responseCacheBypass)
+		return nil, "", nil
+	}
+	store = h.responseCache.responseCacheStore(h.Cfg)
+	if store == nil {
+		return nil, "", nil
+	}
+	clientKey := ""
+	if ginCtx != nil {
+		if v, exists := ginCtx.Get("apiKey"); exists {
+			if s, ok := v.(string); ok {
+				clientKey = s
+			}
+		}
+	}
+	cacheModel := modelName
+	if tenant := requestTenant(ctx); tenant != "" {
+		// Tenants never share cached responses.
+		cacheModel = tenant + "\x00" + modelName
+	}
+	key, ok := responseCacheKey(h.Cfg.ResponseCache, handlerType, cacheModel, clientKey, rawJSON)
+	if !ok {
+		return nil, "", nil
+	}
+	payload, found, err := store.Get(ctx, key)
+	if err != nil {
+		log.Warnf("response cache lookup failed: %v", err)
+		return nil, "", nil
+	}
+	if found {
+		setResponseCacheHeader(ginCtx, responseCacheHit)
+		return payload, "", nil
+	}
+	setResponseCacheHeader(ginCtx, responseCacheMiss)
+	return nil, key, store
+}
+
+func (h *BaseAPIHandler) storeResponseCache(ctx context.Context, store cache.ResponseStore, key string, payload []byte) {
+	if store == nil || key == "" || len(payload) == 0 {
+		return
+	}
+	if err := store.Set(ctx, key, payload, responseCacheTTL(h.Cfg)); err != nil {
+		log.Warnf("response cache store failed: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+type countingExecutor struct {
+	calls atomic.Int32
+}
+
+func (e *countingExecutor) Identifier() string { return "codex" }
+
+func (e *countingExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.calls.Add(1)
+	return coreexecutor.Response{Payload: []byte(`{"ok":true}`)}, nil
+}
+
+func (e *countingExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *countingExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *countingExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func TestIsDeterministicRequest(t *testing.T) {
+	cases := map[string]bool{
+		`{"temperature":0,"messages":[]}`:                                     true,
+		`{"temperature":0.2,"messages":[]}`:                                   false,
+		`{"messages":[]}`:                                                     false,
+		`{"temperature":0,"tools":[{"type":"function"}]}`:                     false,
+		`{"temperature":0,"tools":[]}`:                                        true,
+		`{"generationConfig":{"temperature":0},"contents":[]}`:                true,
+		`{"request":{"generationConfig":{"temperature":0},"tools":[{}]}}`:     false,
+		`{"temperature":0,"n":2}`:                                             false,
+		`{"temperature":"0"}`:                                                 false,
+		`{"request":{"generationConfig":{"temperature":0}},"model":"gemini"}`: true,
+	}
+	for body, want := range cases {
+		if got := isDeterministicRequest([]byte(body)); got != want {
+			t.Errorf("isDeterministicRequest(%s) = %v, want %v", body, got, want)
+		}
+	}
+}
+
+func TestResponseCacheKeyNormalization(t *testing.T) {
+	rc := sdkconfig.ResponseCacheConfig{IgnoreFields: []string{"user"}}
+	a, ok := responseCacheKey(rc, "openai", "m", "k1", []byte(`{"temperature":0,"messages":[{"role":"user","content":"hi"}],"stream":false,"user":"a"}`))
+	if !ok {
+		t.Fatal("expected key for request a")
+	}
+	b, ok := responseCacheKey(rc, "openai", "m", "k2", []byte(`{"messages":[{"content":"hi","role":"user"}], "user":"b", "temperature":0}`))
+	if !ok {
+		t.Fatal("expected key for request b")
+	}
+	if a != b {
+		t.Fatalf("expected equal keys for equivalent payloads, got %s and %s", a, b)
+	}
+
+	rc.KeyStrategy = "body-per-key"
+	c, _ := responseCacheKey(rc, "openai", "m", "k1", []byte(`{"temperature":0}`))
+	d, _ := responseCacheKey(rc, "openai", "m", "k2", []byte(`{"temperature":0}`))
+	if c == d {
+		t.Fatal("expected body-per-key strategy to scope keys by client key")
+	}
+
+	rc.KeyStrategy = "unknown"
+	if _, ok = responseCacheKey(rc, "openai", "m", "k1", []byte(`{"temperature":0}`)); ok {
+		t.Fatal("expected unknown strategy to disable caching")
+	}
+}
+
+func TestExecuteWithAuthManager_ResponseCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	executor := &countingExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+
+	auth := &coreauth.Auth{ID: "cache-auth", Provider: "codex", Status: coreauth.StatusActive, Metadata: map[string]any{"email": "cache@example.com"}}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "cache-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		ResponseCache: sdkconfig.ResponseCacheConfig{Enable: true},
+	}, manager)
+
+	run := func(body string, header http.Header) string {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		for k, v := range header {
+			c.Request.Header[k] = v
+		}
+		ctx := context.WithValue(context.Background(), "gin", c)
+		if _, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "cache-model", []byte(body), ""); errMsg != nil {
+			t.Fatalf("unexpected error: %v", errMsg.Error)
+		}
+		return recorder.Header().Get(ResponseCacheHeader)
+	}
+
+	deterministic := `{"model":"cache-model","temperature":0,"messages":[{"role":"user","content":"hi"}]}`
+	if got := run(deterministic, nil); got != "MISS" {
+		t.Fatalf("first call cache header = %q, want MISS", got)
+	}
+	if got := run(deterministic, nil); got != "HIT" {
+		t.Fatalf("second call cache header = %q, want HIT", got)
+	}
+	if got := run(deterministic, http.Header{"Cache-Control": {"no-cache"}}); got != "BYPASS" {
+		t.Fatalf("bypass call cache header = %q, want BYPASS", got)
+	}
+	if got := run(`{"model":"cache-model","temperature":1}`, nil); got != "" {
+		t.Fatalf("non-deterministic call cache header = %q, want empty", got)
+	}
+	if calls := executor.calls.Load(); calls != 3 {
+		t.Fatalf("executor calls = %d, want 3", calls)
+	}
+}
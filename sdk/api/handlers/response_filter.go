@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/dlp"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+var responseFilterState struct {
+	mu        sync.Mutex
+	signature string
+	filter    *dlp.Filter
+}
+
+// responseFilterFor returns the response filter for the current configuration, rebuilding it
+// when the settings change. Invalid settings disable the filter and are logged once per change.
+func responseFilterFor(cfg *config.SDKConfig) *dlp.Filter {
+	if cfg == nil || !cfg.ResponseFilter.Enable || len(cfg.ResponseFilter.Rules) == 0 {
+		return nil
+	}
+	raw, _ := json.Marshal(cfg.ResponseFilter)
+	signature := string(raw)
+
+	responseFilterState.mu.Lock()
+	defer responseFilterState.mu.Unlock()
+	if responseFilterState.signature == signature {
+		return responseFilterState.filter
+	}
+	filter, err := dlp.New(cfg.ResponseFilter)
+	if err != nil {
+		log.Errorf("response filter disabled: %v", err)
+		filter = nil
+	}
+	responseFilterState.signature = signature
+	responseFilterState.filter = filter
+	return filter
+}
+
+// responseFilterError is returned when a block rule of the response filter matches.
+type responseFilterError struct {
+	rule string
+}
+
+func (e *responseFilterError) Error() string {
+	payload, _ := json.Marshal(ErrorResponse{Error: ErrorDetail{
+		Message: fmt.Sprintf("The response was blocked by content filter %q", e.rule),
+		Type:    "invalid_request_error",
+		Code:    "content_filtered",
+	}})
+	return string(payload)
+}
+
+func (e *responseFilterError) StatusCode() int { return http.StatusBadRequest }
+
+// filterResponse applies the response filter to a complete response body.
+func (h *BaseAPIHandler) filterResponse(ctx context.Context, modelName string, payload []byte) ([]byte, *interfaces.ErrorMessage) {
+	filter := responseFilterFor(h.Cfg)
+	if filter == nil {
+		return payload, nil
+	}
+	out, blocked := filter.Apply(payload)
+	if blocked != "" {
+		return nil, responseFilterBlocked(ctx, modelName, blocked)
+	}
+	return out, nil
+}
+
+// newResponseFilterStream starts filtering a streamed response. The result is nil, and passes
+// chunks through unchanged, when the filter is disabled.
+func (h *BaseAPIHandler) newResponseFilterStream() *dlp.Stream {
+	return responseFilterFor(h.Cfg).NewStream()
+}
+
+func responseFilterBlocked(ctx context.Context, modelName, rule string) *interfaces.ErrorMessage {
+	log.Infof("response filter rule %q blocked a response from model %s", rule, modelName)
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+		ginCtx.Set("responseFilterBlocked", rule)
+	}
+	return &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: &responseFilterError{rule: rule}}
+}
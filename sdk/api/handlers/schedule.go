@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// ScheduleHeader names the scheduled rule that routed a response.
+const ScheduleHeader = "X-CLIProxy-Schedule"
+
+// scheduleNow returns the current time; tests replace it.
+var scheduleNow = time.Now
+
+// scheduleLocations caches loaded time zones by name. Zones that fail to load are cached as nil
+// so the warning is logged once.
+var scheduleLocations sync.Map
+
+var weekdayNames = map[string][]time.Weekday{
+	"sun":      {time.Sunday},
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+// applySchedule routes the request according to the first scheduled rule active at the current
+// time. It returns the context restricting credential selection, the model to route, the provider
+// pin and whether a rule applied.
+func (h *BaseAPIHandler) applySchedule(ctx context.Context, modelName string) (context.Context, string, requestOverrides, bool) {
+	if h.Cfg == nil || len(h.Cfg.ScheduledRouting.Rules) == 0 {
+		return ctx, modelName, requestOverrides{}, false
+	}
+	apiKey := ""
+	ginCtx := ginContextFrom(ctx)
+	if ginCtx != nil {
+		if v, ok := ginCtx.Get("apiKey"); ok {
+			apiKey, _ = v.(string)
+		}
+	}
+	rule := matchScheduledRule(h.Cfg.ScheduledRouting, modelName, apiKey, scheduleNow())
+	if rule == nil {
+		return ctx, modelName, requestOverrides{}, false
+	}
+	o := requestOverrides{provider: strings.ToLower(strings.TrimSpace(rule.Provider))}
+	target := modelName
+	if m := strings.TrimSpace(rule.TargetModel); m != "" {
+		target = m
+	}
+	if strings.TrimSpace(rule.AuthKind) != "" || len(rule.Credentials) > 0 {
+		ctx = coreauth.WithCredentialFilter(ctx, coreauth.CredentialFilter{Kind: rule.AuthKind, Patterns: rule.Credentials})
+	}
+	if ginCtx != nil {
+		ginCtx.Header(ScheduleHeader, rule.Name)
+	}
+	log.Debugf("schedule %s: routing %s to %s", rule.Name, modelName, target)
+	trace.Record(ctx, "model.schedule", fmt.Sprintf("schedule %s routed %s to %s", rule.Name, modelName, target),
+		map[string]any{"rule": rule.Name, "from": modelName, "model": target, "provider": o.provider, "auth_kind": rule.AuthKind})
+	return ctx, target, o, true
+}
+
+// matchScheduledRule returns the first rule matching modelName and apiKey that is active at now.
+func matchScheduledRule(cfg config.ScheduledRoutingConfig, modelName, apiKey string, now time.Time) *config.ScheduledRule {
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if strings.TrimSpace(rule.TargetModel) == "" && strings.TrimSpace(rule.Provider) == "" &&
+			strings.TrimSpace(rule.AuthKind) == "" && len(rule.Credentials) == 0 {
+			continue
+		}
+		if !modelMatchesAny(rule.Models, modelName) || !scheduleKeyMatches(rule.APIKeys, apiKey) {
+			continue
+		}
+		zone := strings.TrimSpace(rule.Timezone)
+		if zone == "" {
+			zone = strings.TrimSpace(cfg.Timezone)
+		}
+		loc := scheduleLocation(zone)
+		if loc == nil {
+			continue
+		}
+		if scheduleActive(rule, now.In(loc)) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// modelMatchesAny reports whether modelName matches one of the wildcard patterns.
+func modelMatchesAny(patterns []string, modelName string) bool {
+	for _, pattern := range patterns {
+		if util.MatchWildcard(strings.TrimSpace(pattern), modelName) {
+			return true
+		}
+	}
+	return false
+}
+
+func scheduleKeyMatches(keys []string, apiKey string) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	for _, key := range keys {
+		if key == "*" || (apiKey != "" && key == apiKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleLocation loads the named time zone, using the server's local zone for "".
+func scheduleLocation(name string) *time.Location {
+	if name == "" || strings.EqualFold(name, "local") {
+		return time.Local
+	}
+	if cached, ok := scheduleLocations.Load(name); ok {
+		loc, _ := cached.(*time.Location)
+		return loc
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Warnf("scheduled-routing: unknown timezone %q, rules using it are disabled: %v", name, err)
+		loc = nil
+	}
+	scheduleLocations.Store(name, loc)
+	return loc
+}
+
+// scheduleActive reports whether local, already converted to the rule's zone, falls within the
+// rule's schedule. A window spanning midnight is checked against the day it started on.
+func scheduleActive(rule *config.ScheduledRule, local time.Time) bool {
+	start, okStart := parseClock(rule.Start, 0)
+	end, okEnd := parseClock(rule.End, 24*60)
+	if !okStart || !okEnd {
+		return false
+	}
+	minute := local.Hour()*60 + local.Minute()
+	day := local
+	switch {
+	case start == end:
+		// All day.
+	case start < end:
+		if minute < start || minute >= end {
+			return false
+		}
+	default:
+		if minute < end {
+			day = local.AddDate(0, 0, -1)
+		} else if minute < start {
+			return false
+		}
+	}
+	return scheduleDayMatches(rule, day)
+}
+
+// scheduleDayMatches checks the weekday and calendar restrictions of the rule against day.
+func scheduleDayMatches(rule *config.ScheduledRule, day time.Time) bool {
+	if len(rule.Days) > 0 {
+		matched := false
+		for _, name := range rule.Days {
+			for _, weekday := range weekdayNames[strings.ToLower(strings.TrimSpace(name))] {
+				if weekday == day.Weekday() {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	date := day.Format("2006-01-02")
+	if len(rule.Dates) > 0 && !dateListContains(rule.Dates, date) {
+		return false
+	}
+	return !dateListContains(rule.ExcludeDates, date)
+}
+
+// dateListContains reports whether date ("YYYY-MM-DD") equals one of the entries or lies within
+// a "YYYY-MM-DD..YYYY-MM-DD" range. Dates in this format compare correctly as strings.
+func dateListContains(entries []string, date string) bool {
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if from, to, isRange := strings.Cut(entry, ".."); isRange {
+			if date >= strings.TrimSpace(from) && date <= strings.TrimSpace(to) {
+				return true
+			}
+			continue
+		}
+		if entry == date {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses "HH:MM" into minutes past midnight, returning fallback for an empty value.
+// "24:00" is accepted as the end of the day.
+func parseClock(value string, fallback int) (int, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fallback, true
+	}
+	hours, minutes, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, false
+	}
+	h, errH := strconv.Atoi(hours)
+	m, errM := strconv.Atoi(minutes)
+	if errH != nil || errM != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, false
+	}
+	return h*60 + m, true
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestMatchScheduledRuleHonoursWindowsAndTimezones(t *testing.T) {
+	cfg := config.ScheduledRoutingConfig{
+		Timezone: "America/New_York",
+		Rules: []config.ScheduledRule{
+			{Name: "work-hours", Models: []string{"claude-*"}, Days: []string{"weekdays"}, Start: "09:00", End: "18:00", AuthKind: "api-key"},
+			{Name: "overnight", Models: []string{"claude-*"}, Start: "22:00", End: "06:00", ExcludeDates: []string{"2026-12-24..2026-12-26"}, AuthKind: "oauth"},
+		},
+	}
+	cases := []struct {
+		at   string
+		want string
+	}{
+		{"2026-10-14T14:00:00Z", "work-hours"}, // Wednesday 10:00 in New York
+		{"2026-10-17T14:00:00Z", ""},           // Saturday
+		{"2026-10-14T22:00:00Z", ""},           // 18:00, end is exclusive
+		{"2026-10-15T03:00:00Z", "overnight"},  // 23:00
+		{"2026-10-15T09:30:00Z", "overnight"},  // 05:30 the next morning
+		{"2026-12-25T04:00:00Z", ""},           // 23:00 on an excluded date
+		{"2026-12-27T09:00:00Z", ""},           // 04:00 in a window that started on an excluded date
+	}
+	for _, tc := range cases {
+		now, err := time.Parse(time.RFC3339, tc.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if rule := matchScheduledRule(cfg, "claude-sonnet-4", "", now); rule != nil {
+			got = rule.Name
+		}
+		if got != tc.want {
+			t.Errorf("at %s: expected %q, got %q", tc.at, tc.want, got)
+		}
+	}
+}
+
+func TestMatchScheduledRuleFiltersModelsAndKeys(t *testing.T) {
+	cfg := config.ScheduledRoutingConfig{Rules: []config.ScheduledRule{
+		{Name: "batch", Models: []string{"gpt-*"}, APIKeys: []string{"batch-key"}, TargetModel: "gpt-5-mini"},
+		{Name: "bad-zone", Models: []string{"*"}, Timezone: "Nowhere/Invalid", Provider: "codex"},
+	}}
+	now := time.Now()
+	if rule := matchScheduledRule(cfg, "gpt-5", "batch-key", now); rule == nil || rule.Name != "batch" {
+		t.Fatalf("expected batch rule, got %+v", rule)
+	}
+	if rule := matchScheduledRule(cfg, "gpt-5", "other-key", now); rule != nil {
+		t.Fatalf("expected no rule for another key, got %s", rule.Name)
+	}
+	if rule := matchScheduledRule(cfg, "claude-sonnet-4", "batch-key", now); rule != nil {
+		t.Fatalf("rule with an invalid timezone must not apply, got %s", rule.Name)
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// responseCacheSemanticHit marks responses served from the semantic cache.
+const responseCacheSemanticHit = "SEMANTIC-HIT"
+
+// semanticCacheTimeout bounds embedding calls so a slow embedding backend never stalls requests.
+const semanticCacheTimeout = 5 * time.Second
+
+type semanticCacheState struct {
+	mu        sync.Mutex
+	signature string
+	cache     *cache.SemanticCache
+	embedder  cache.Embedder
+}
+
+// semanticCacheFor returns the cache and embedder for the current configuration, rebuilding
+// them when the relevant settings change.
+func (s *semanticCacheState) semanticCacheFor(cfg *config.SDKConfig) (*cache.SemanticCache, cache.Embedder) {
+	if s == nil || cfg == nil || !cfg.SemanticCache.Enable {
+		return nil, nil
+	}
+	sc := cfg.SemanticCache
+	provider := strings.ToLower(strings.TrimSpace(sc.Embedding.Provider))
+	signature := fmt.Sprintf("%g|%d|%s|%s|%s|%s|%s", sc.Threshold, sc.MaxEntries, provider, sc.Embedding.BaseURL, sc.Embedding.APIKey, sc.Embedding.Model, cfg.ProxyURL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache != nil && s.signature == signature {
+		return s.cache, s.embedder
+	}
+
+	var embedder cache.Embedder
+	switch provider {
+	case "", "local":
+		embedder = cache.HashingEmbedder{}
+	case "openai":
+		embedder = &cache.OpenAIEmbedder{
+			BaseURL: sc.Embedding.BaseURL,
+			APIKey:  sc.Embedding.APIKey,
+			Model:   sc.Embedding.Model,
+			Client:  util.SetProxy(cfg, &http.Client{Timeout: semanticCacheTimeout}),
+		}
+	default:
+		log.Errorf("semantic cache disabled: unsupported embedding provider %q", sc.Embedding.Provider)
+		s.cache, s.embedder, s.signature = nil, nil, ""
+		return nil, nil
+	}
+	s.cache = cache.NewSemanticCache(sc.Threshold, sc.MaxEntries)
+	s.embedder = embedder
+	s.signature = signature
+	return s.cache, s.embedder
+}
+
+func semanticCacheTTL(cfg *config.SDKConfig) time.Duration {
+	if cfg == nil || cfg.SemanticCache.TTLSeconds <= 0 {
+		return cache.DefaultSemanticCacheTTL
+	}
+	return time.Duration(cfg.SemanticCache.TTLSeconds) * time.Second
+}
+
+// semanticCacheRouteEnabled reports whether the request path is listed in the configured routes.
+func semanticCacheRouteEnabled(routes []string, c *gin.Context) bool {
+	if c == nil || c.Request == nil {
+		return false
+	}
+	fullPath := c.FullPath()
+	requestPath := ""
+	if c.Request.URL != nil {
+		requestPath = c.Request.URL.Path
+	}
+	for _, route := range routes {
+		switch strings.TrimSpace(route) {
+		case "":
+		case "*", fullPath, requestPath:
+			return true
+		}
+	}
+	return false
+}
+
+// semanticPromptText flattens the conversational text of an OpenAI, Claude, Responses or
+// Gemini payload into a single string. Requests with tools are never considered.
+func semanticPromptText(rawJSON []byte) string {
+	root := gjson.ParseBytes(rawJSON)
+	for _, path := range []string{"tools", "functions", "request.tools"} {
+		if tools := root.Get(path); tools.IsArray() && len(tools.Array()) > 0 {
+			return ""
+		}
+	}
+	var parts []string
+	collect := func(v gjson.Result) {
+		switch {
+		case v.Type == gjson.String:
+			parts = append(parts, v.String())
+		case v.IsArray():
+			for _, item := range v.Array() {
+				if item.Type == gjson.String {
+					parts = append(parts, item.String())
+				} else if text := item.Get("text"); text.Type == gjson.String {
+					parts = append(parts, text.String())
+				}
+			}
+		}
+	}
+	collect(root.Get("system"))
+	collect(root.Get("instructions"))
+	collect(root.Get("prompt"))
+	for _, msg := range root.Get("messages").Array() {
+		parts = append(parts, msg.Get("role").String()+":")
+		collect(msg.Get("content"))
+	}
+	input := root.Get("input")
+	if input.Type == gjson.String {
+		parts = append(parts, input.String())
+	} else {
+		for _, item := range input.Array() {
+			if item.Type == gjson.String {
+				parts = append(parts, item.String())
+				continue
+			}
+			parts = append(parts, item.Get("role").String()+":")
+			collect(item.Get("content"))
+		}
+	}
+	for _, base := range []string{"", "request."} {
+		collect(root.Get(base + "systemInstruction.parts"))
+		for _, content := range root.Get(base + "contents").Array() {
+			parts = append(parts, content.Get("role").String()+":")
+			collect(content.Get("parts"))
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n"))
+}
+
+// semanticLookup carries the state needed to store a fresh response after a semantic miss.
+type semanticLookup struct {
+	cache     *cache.SemanticCache
+	partition string
+	vector    []float32
+}
+
+// lookupSemanticCache returns a cached payload for a near-duplicate prompt when available.
+// On a miss it returns a non-nil lookup that storeSemanticCache uses to remember the response.
+func (h *BaseAPIHandler) lookupSemanticCache(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, *semanticLookup) {
+	if h == nil || h.Cfg == nil || !h.Cfg.SemanticCache.Enable {
+		return nil, nil
+	}
+	ginCtx := ginContextFrom(ctx)
+	if !semanticCacheRouteEnabled(h.Cfg.SemanticCache.Routes, ginCtx) || cacheBypassRequested(ginCtx) {
+		return nil, nil
+	}
+	semCache, embedder := h.semanticCache.semanticCacheFor(h.Cfg)
+	if semCache == nil || embedder == nil {
+		return nil, nil
+	}
+	text := semanticPromptText(rawJSON)
+	if text == "" {
+		return nil, nil
+	}
+	embedCtx, cancel := context.WithTimeout(ctx, semanticCacheTimeout)
+	defer cancel()
+	vector, err := embedder.Embed(embedCtx, text)
+	if err != nil {
+		log.Warnf("semantic cache embedding failed: %v", err)
+		return nil, nil
+	}
+	partition := handlerType + "\x00" + modelName
+	if tenant := requestTenant(ctx); tenant != "" {
+		partition += "\x00" + tenant
+	}
+	if payload, score, ok := semCache.Lookup(partition, vector); ok {
+		log.Debugf("semantic cache hit for model %s (similarity %.4f)", modelName, score)
+		setResponseCacheHeader(ginCtx, responseCacheSemanticHit)
+		return payload, nil
+	}
+	return nil, &semanticLookup{cache: semCache, partition: partition, vector: vector}
+}
+
+func (h *BaseAPIHandler) storeSemanticCache(lookup *semanticLookup, payload []byte) {
+	if lookup == nil || len(payload) == 0 {
+		return
+	}
+	lookup.cache.Store(lookup.partition, lookup.vector, payload, semanticCacheTTL(h.Cfg))
+}
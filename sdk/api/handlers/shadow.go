@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/guardrail"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// shadowTimeout bounds a mirrored request and the wait for the primary response.
+const shadowTimeout = 10 * time.Minute
+
+// ShadowComparison is the logged outcome of one mirrored request.
+type ShadowComparison struct {
+	Rule             string    `json:"rule,omitempty"`
+	Model            string    `json:"model"`
+	TargetModel      string    `json:"target_model"`
+	Stream           bool      `json:"stream"`
+	Timestamp        time.Time `json:"timestamp"`
+	PrimaryLatencyMs int64     `json:"primary_latency_ms"`
+	ShadowLatencyMs  int64     `json:"shadow_latency_ms"`
+	PrimaryChars     int       `json:"primary_chars"`
+	ShadowChars      int       `json:"shadow_chars"`
+	Similarity       float64   `json:"similarity"`
+	PrimaryError     string    `json:"primary_error,omitempty"`
+	ShadowError      string    `json:"shadow_error,omitempty"`
+}
+
+// shadowRun collects the primary response of a mirrored request.
+type shadowRun struct {
+	started time.Time
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	output  bytes.Buffer
+	latency time.Duration
+	err     error
+}
+
+// observe records a primary response payload or stream chunk.
+func (r *shadowRun) observe(payload []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.output.Write(payload)
+	r.output.WriteByte('\n')
+	r.mu.Unlock()
+}
+
+// finish marks the primary response complete.
+func (r *shadowRun) finish(err error) {
+	if r == nil {
+		return
+	}
+	r.once.Do(func() {
+		r.mu.Lock()
+		r.latency = time.Since(r.started)
+		r.err = err
+		r.mu.Unlock()
+		close(r.done)
+	})
+}
+
+// matchShadowRule returns the first shadow rule matching modelName.
+func matchShadowRule(cfg config.ShadowTrafficConfig, modelName string) *config.ShadowRule {
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if strings.TrimSpace(rule.TargetModel) == "" || rule.Percent <= 0 {
+			continue
+		}
+		for _, pattern := range rule.Models {
+			if util.MatchWildcard(strings.TrimSpace(pattern), modelName) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// startShadow samples the request against the shadow rules. When selected, the mirrored request
+// starts immediately on a detached context and the returned run must be fed the primary response.
+// It returns nil when the request is not mirrored.
+func (h *BaseAPIHandler) startShadow(handlerType, modelName string, rawJSON []byte, alt string, stream bool) *shadowRun {
+	if h.Cfg == nil || len(h.Cfg.ShadowTraffic.Rules) == 0 || h.AuthManager == nil {
+		return nil
+	}
+	rule := matchShadowRule(h.Cfg.ShadowTraffic, modelName)
+	if rule == nil || rand.Float64()*100 >= rule.Percent {
+		return nil
+	}
+	run := &shadowRun{started: time.Now(), done: make(chan struct{})}
+	ruleCopy := *rule
+	logFile := h.Cfg.ShadowTraffic.LogFile
+	payload := cloneBytes(rawJSON)
+	go func() {
+		ctx, cancel := context.WithTimeout(guardrail.WithoutGuardrails(context.Background()), shadowTimeout)
+		defer cancel()
+		shadowStart := time.Now()
+		output, errShadow := h.executeShadow(ctx, ruleCopy, handlerType, payload, alt, stream)
+		shadowLatency := time.Since(shadowStart)
+
+		select {
+		case <-run.done:
+		case <-ctx.Done():
+			run.finish(errors.New("primary response did not complete"))
+		}
+		run.mu.Lock()
+		primary, primaryLatency, errPrimary := run.output.Bytes(), run.latency, run.err
+		run.mu.Unlock()
+
+		comparison := compareShadow(primary, output)
+		comparison.Rule = ruleCopy.Name
+		comparison.Model = modelName
+		comparison.TargetModel = ruleCopy.TargetModel
+		comparison.Stream = stream
+		comparison.Timestamp = time.Now()
+		comparison.PrimaryLatencyMs = primaryLatency.Milliseconds()
+		comparison.ShadowLatencyMs = shadowLatency.Milliseconds()
+		if errPrimary != nil {
+			comparison.PrimaryError = errPrimary.Error()
+		}
+		if errShadow != nil {
+			comparison.ShadowError = errShadow.Error()
+		}
+		recordShadowComparison(logFile, comparison)
+	}()
+	return run
+}
+
+// executeShadow sends the mirrored request to the rule's target model and returns the response
+// body, or the concatenated stream chunks.
+func (h *BaseAPIHandler) executeShadow(ctx context.Context, rule config.ShadowRule, handlerType string, rawJSON []byte, alt string, stream bool) ([]byte, error) {
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(strings.TrimSpace(rule.TargetModel))
+	if errMsg == nil {
+		providers, errMsg = requestOverrides{provider: strings.ToLower(strings.TrimSpace(rule.Provider))}.filterProviders(rule.TargetModel, providers)
+	}
+	if errMsg != nil {
+		return nil, errMsg.Error
+	}
+	upstreamJSON, redaction := h.redactPrompt(providers, rawJSON)
+	req := coreexecutor.Request{Model: normalizedModel, Payload: cloneBytes(upstreamJSON), Metadata: cloneMetadata(metadata)}
+	opts := coreexecutor.Options{
+		Stream:          stream,
+		Alt:             alt,
+		OriginalRequest: cloneBytes(upstreamJSON),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+		Metadata:        mergeMetadata(cloneMetadata(metadata), requestExecutionMetadata(ctx)),
+	}
+	if !stream {
+		resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+		if err != nil {
+			return nil, err
+		}
+		return redaction.Restore(resp.Payload), nil
+	}
+	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			return out.Bytes(), chunk.Err
+		}
+		out.Write(redaction.Restore(chunk.Payload))
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// shadowText extracts the visible text of a response body or of SSE / newline-separated chunks.
+func shadowText(body []byte) string {
+	var parts []string
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if bytes.HasPrefix(line, []byte("data:")) {
+			line = bytes.TrimSpace(line[5:])
+		}
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		parts = append(parts, guardrail.ExtractText(line)...)
+	}
+	if len(parts) == 0 {
+		parts = guardrail.ExtractText(body)
+	}
+	return strings.Join(parts, "")
+}
+
+// compareShadow measures the primary and mirrored responses. Similarity is the Jaccard index of
+// their lower-cased word sets.
+func compareShadow(primary, shadow []byte) ShadowComparison {
+	primaryText, mirroredText := shadowText(primary), shadowText(shadow)
+	comparison := ShadowComparison{PrimaryChars: len([]rune(primaryText)), ShadowChars: len([]rune(mirroredText))}
+	a, b := wordSet(primaryText), wordSet(mirroredText)
+	if len(a) == 0 && len(b) == 0 {
+		comparison.Similarity = 1
+		return comparison
+	}
+	shared := 0
+	for w := range a {
+		if _, ok := b[w]; ok {
+			shared++
+		}
+	}
+	comparison.Similarity = float64(shared) / float64(len(a)+len(b)-shared)
+	return comparison
+}
+
+func wordSet(text string) map[string]struct{} {
+	words := strings.Fields(strings.ToLower(text))
+	set := make(map[string]struct{}, len(words))
+	for _, w := range words {
+		set[strings.Trim(w, ".,;:!?\"'()[]{}`")] = struct{}{}
+	}
+	delete(set, "")
+	return set
+}
+
+var shadowLogMu sync.Mutex
+
+// recordShadowComparison logs the comparison and appends it to logFile when configured.
+func recordShadowComparison(logFile string, comparison ShadowComparison) {
+	log.WithFields(log.Fields{
+		"rule":               comparison.Rule,
+		"model":              comparison.Model,
+		"target_model":       comparison.TargetModel,
+		"primary_latency_ms": comparison.PrimaryLatencyMs,
+		"shadow_latency_ms":  comparison.ShadowLatencyMs,
+		"primary_chars":      comparison.PrimaryChars,
+		"shadow_chars":       comparison.ShadowChars,
+		"similarity":         comparison.Similarity,
+		"primary_error":      comparison.PrimaryError,
+		"shadow_error":       comparison.ShadowError,
+	}).Info("shadow traffic comparison")
+	logFile = strings.TrimSpace(logFile)
+	if logFile == "" {
+		return
+	}
+	line, err := json.Marshal(comparison)
+	if err != nil {
+		return
+	}
+	shadowLogMu.Lock()
+	defer shadowLogMu.Unlock()
+	if errDir := os.MkdirAll(filepath.Dir(logFile), 0o755); errDir != nil {
+		log.Warnf("shadow traffic: create log directory: %v", errDir)
+		return
+	}
+	f, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Warnf("shadow traffic: open log file: %v", err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+	if _, errWrite := f.Write(append(line, '\n')); errWrite != nil {
+		log.Warnf("shadow traffic: write log file: %v", errWrite)
+	}
+}
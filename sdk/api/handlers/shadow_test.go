@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"testing"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestMatchShadowRule(t *testing.T) {
+	cfg := sdkconfig.ShadowTrafficConfig{Rules: []sdkconfig.ShadowRule{
+		{Name: "off", Models: []string{"*"}, Percent: 0, TargetModel: "x"},
+		{Name: "sonnet", Models: []string{"claude-sonnet-*"}, Percent: 10, TargetModel: "gemini-2.5-pro"},
+	}}
+	if rule := matchShadowRule(cfg, "claude-sonnet-4"); rule == nil || rule.Name != "sonnet" {
+		t.Fatalf("expected sonnet rule, got %+v", rule)
+	}
+	if rule := matchShadowRule(cfg, "gpt-4o"); rule != nil {
+		t.Fatalf("expected no rule, got %+v", rule)
+	}
+}
+
+func TestCompareShadow(t *testing.T) {
+	primary := []byte(`{"choices":[{"message":{"role":"assistant","content":"The sky is blue."}}]}`)
+	stream := []byte("data: {\"choices\":[{\"delta\":{\"content\":\"The sky \"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"is grey.\"}}]}\n\ndata: [DONE]\n")
+	got := compareShadow(primary, stream)
+	if got.PrimaryChars != len("The sky is blue.") || got.ShadowChars != len("The sky is grey.") {
+		t.Fatalf("unexpected lengths: %+v", got)
+	}
+	// {the, sky, is} shared out of {the, sky, is, blue, grey}.
+	if got.Similarity < 0.59 || got.Similarity > 0.61 {
+		t.Fatalf("similarity = %v, want 0.6", got.Similarity)
+	}
+}
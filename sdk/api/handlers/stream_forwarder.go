@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"bytes"
 	"net/http"
 	"time"
 
@@ -61,6 +62,10 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 		keepAliveC = keepAlive.C
 	}
 
+	coalesceInterval, coalesceMaxBytes := StreamingCoalesceWindow(h.Cfg)
+	coalescer := &streamCoalescer{writer: c.Writer, flusher: flusher, interval: coalesceInterval, maxBytes: coalesceMaxBytes}
+	defer coalescer.stop()
+
 	var terminalErr *interfaces.ErrorMessage
 	for {
 		select {
@@ -94,8 +99,9 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 				cancel(nil)
 				return
 			}
-			writeChunk(chunk)
-			flusher.Flush()
+			coalescer.write(chunk, writeChunk)
+		case <-coalescer.C():
+			coalescer.flush()
 		case errMsg, ok := <-errs:
 			if !ok {
 				continue
@@ -115,7 +121,88 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 			return
 		case <-keepAliveC:
 			writeKeepAlive()
-			flusher.Flush()
+			coalescer.flush()
+		}
+	}
+}
+
+// coalescedBoundaryMarkers identify events that carry tool calls across the supported formats.
+// They are flushed immediately so clients never wait on a coalescing window to act on a call.
+var coalescedBoundaryMarkers = [][]byte{
+	[]byte(`"tool_calls"`),
+	[]byte(`"tool_use"`),
+	[]byte(`"input_json_delta"`),
+	[]byte(`"functionCall"`),
+	[]byte(`function_call`),
+}
+
+// streamCoalescer delays flushes so several small events share one write to the connection.
+// Events are always written whole and in order, so event boundaries are unaffected.
+type streamCoalescer struct {
+	writer   gin.ResponseWriter
+	flusher  http.Flusher
+	interval time.Duration
+	maxBytes int
+
+	started bool
+	pending int
+	timer   *time.Timer
+	armed   bool
+}
+
+func (s *streamCoalescer) write(chunk []byte, writeChunk func([]byte)) {
+	before := max(s.writer.Size(), 0)
+	writeChunk(chunk)
+	if s.interval <= 0 || !s.started || isCoalesceBoundary(chunk) {
+		// The first event is never delayed so time-to-first-token is unchanged.
+		s.started = true
+		s.flush()
+		return
+	}
+	s.pending += max(s.writer.Size(), 0) - before
+	if s.pending >= s.maxBytes {
+		s.flush()
+		return
+	}
+	if s.armed {
+		return
+	}
+	if s.timer == nil {
+		s.timer = time.NewTimer(s.interval)
+	} else {
+		s.timer.Reset(s.interval)
+	}
+	s.armed = true
+}
+
+// C fires when pending events have waited for the full interval.
+func (s *streamCoalescer) C() <-chan time.Time {
+	if !s.armed {
+		return nil
+	}
+	return s.timer.C
+}
+
+func (s *streamCoalescer) flush() {
+	s.flusher.Flush()
+	s.pending = 0
+	if s.armed {
+		s.timer.Stop()
+		s.armed = false
+	}
+}
+
+func (s *streamCoalescer) stop() {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+func isCoalesceBoundary(chunk []byte) bool {
+	for _, marker := range coalescedBoundaryMarkers {
+		if bytes.Contains(chunk, marker) {
+			return true
 		}
 	}
+	return false
 }
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+type countingFlusher struct {
+	recorder *httptest.ResponseRecorder
+	flushes  []string
+}
+
+func (f *countingFlusher) Flush() {
+	f.flushes = append(f.flushes, f.recorder.Body.String())
+}
+
+func forwardForTest(t *testing.T, cfg *sdkconfig.SDKConfig, chunks []string) *countingFlusher {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	flusher := &countingFlusher{recorder: recorder}
+
+	data := make(chan []byte, len(chunks))
+	for _, chunk := range chunks {
+		data <- []byte(chunk)
+	}
+	close(data)
+	errs := make(chan *interfaces.ErrorMessage)
+
+	handler := &BaseAPIHandler{Cfg: cfg}
+	handler.ForwardStream(c, flusher, func(error) {}, data, errs, StreamForwardOptions{
+		WriteChunk: func(chunk []byte) { _, _ = c.Writer.Write(chunk) },
+	})
+	return flusher
+}
+
+func TestForwardStream_FlushesEveryChunkByDefault(t *testing.T) {
+	flusher := forwardForTest(t, &sdkconfig.SDKConfig{}, []string{"a", "b", "c"})
+	if len(flusher.flushes) != 4 {
+		t.Fatalf("flushes = %d, want 4 (one per chunk plus done)", len(flusher.flushes))
+	}
+}
+
+func TestForwardStream_CoalescesSmallChunks(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Streaming.Coalesce = sdkconfig.StreamCoalesceConfig{FlushIntervalMs: 60_000, MaxBytes: 8}
+	flusher := forwardForTest(t, cfg, []string{
+		"first",
+		"aaa", "bbb", "ccc", // crosses MaxBytes on the third chunk
+		"dd",
+		`{"tool_calls":[]}`,
+		"tail",
+	})
+
+	want := []string{
+		"first",
+		"firstaaabbbccc",
+		`firstaaabbbcccdd{"tool_calls":[]}`,
+		`firstaaabbbcccdd{"tool_calls":[]}tail`,
+	}
+	if len(flusher.flushes) != len(want) {
+		t.Fatalf("flushes = %q, want %q", flusher.flushes, want)
+	}
+	for i := range want {
+		if flusher.flushes[i] != want[i] {
+			t.Fatalf("flush %d = %q, want %q", i, flusher.flushes[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/events"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/streams"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// trackStream registers a streaming request with the active stream registry and derives a
+// context that is canceled once the stream finishes.
+func trackStream(ctx context.Context, handlerType, modelName string, providers []string) (context.Context, *streams.Stream) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	info := streams.Info{
+		Handler:   handlerType,
+		Model:     modelName,
+		Providers: append([]string(nil), providers...),
+	}
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+		if ginCtx.Request != nil && ginCtx.Request.URL != nil {
+			info.Route = ginCtx.Request.URL.Path
+		}
+		if key, ok := ginCtx.Get("apiKey"); ok {
+			if s, okStr := key.(string); okStr && s != "" {
+				info.APIKey = util.HideAPIKey(s)
+			}
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stream := streams.GetRegistry().Start(info, cancel)
+	notifyStreamStarted(ctx, stream)
+	return ctx, stream
+}
+
+// streamAbortedEvent is the data of a stream.aborted event.
+type streamAbortedEvent struct {
+	streams.Info
+	Reason     string `json:"reason"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// streamEvent builds a live event about stream.
+func streamEvent(ctx context.Context, eventType, summary string, info streams.Info, data any) events.Event {
+	event := events.Event{Type: eventType, Summary: summary, Model: info.Model, Data: data}
+	if len(info.Providers) == 1 {
+		event.Upstream = info.Providers[0]
+	}
+	return event.WithClient(ctx)
+}
+
+// notifyStreamStarted publishes a newly registered stream.
+func notifyStreamStarted(ctx context.Context, stream *streams.Stream) {
+	if stream == nil || !events.Wanted(events.TypeStreamStarted) {
+		return
+	}
+	info := stream.Info()
+	summary := fmt.Sprintf("stream %s started for %s on %s", info.ID, info.Model, info.Route)
+	events.Publish(streamEvent(ctx, events.TypeStreamStarted, summary, info, info))
+}
+
+// notifyStreamAborted reports a stream that ended before the upstream completed it, either
+// because the client went away or was canceled ("canceled") or because the upstream failed
+// mid-stream ("upstream_error").
+func notifyStreamAborted(ctx context.Context, stream *streams.Stream, reason string, err error) {
+	if stream == nil || !events.Wanted(events.TypeStreamAborted) {
+		return
+	}
+	data := streamAbortedEvent{Info: stream.Info(), Reason: reason}
+	data.DurationMs = time.Since(data.StartedAt).Milliseconds()
+	if err != nil {
+		data.Error = err.Error()
+	}
+	summary := fmt.Sprintf("stream %s for %s aborted after %d chunks: %s", data.ID, data.Model, data.Chunks, reason)
+	events.Publish(streamEvent(ctx, events.TypeStreamAborted, summary, data.Info, data))
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+)
+
+// requestTenant returns the tenant resolved for the request, if any.
+func requestTenant(ctx context.Context) string {
+	ginCtx := ginContextFrom(ctx)
+	if ginCtx == nil {
+		return ""
+	}
+	tenant, _ := ginCtx.Get("tenant")
+	name, _ := tenant.(string)
+	return name
+}
+
+// applyTenant scopes credential selection to the request's tenant and resolves the tenant's
+// model aliases. Requests without a tenant are scoped to credentials no tenant owns.
+func (h *BaseAPIHandler) applyTenant(ctx context.Context, modelName string) (context.Context, string) {
+	name := requestTenant(ctx)
+	if ctx == nil || h.Cfg == nil || len(h.Cfg.Tenants) == 0 {
+		return ctx, modelName
+	}
+	ctx = coreauth.WithTenant(ctx, name)
+	tenant, ok := h.Cfg.Tenant(name)
+	if !ok {
+		return ctx, modelName
+	}
+	for _, alias := range tenant.ModelAliases {
+		if target := strings.TrimSpace(alias.Name); target != "" && strings.EqualFold(strings.TrimSpace(alias.Alias), modelName) {
+			trace.Record(ctx, "model.alias", fmt.Sprintf("tenant %s alias %s resolved to %s", name, modelName, target),
+				map[string]any{"tenant": name, "alias": modelName, "model": target})
+			return ctx, target
+		}
+	}
+	return ctx, modelName
+}
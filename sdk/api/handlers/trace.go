@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+)
+
+// traceRoute records how the requested model was resolved to a model and candidate providers.
+func traceRoute(ctx context.Context, requestedModel, modelName, normalizedModel string, providers []string) {
+	t := trace.FromContext(ctx)
+	if t == nil {
+		return
+	}
+	data := map[string]any{"requested_model": requestedModel, "model": normalizedModel, "providers": providers}
+	if modelName != normalizedModel {
+		data["resolved_model"] = modelName
+	}
+	t.Add("route", fmt.Sprintf("%s routed as %s to %s", requestedModel, normalizedModel, strings.Join(providers, ", ")), data)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/transcript"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+var transcriptState struct {
+	mu        sync.Mutex
+	signature string
+	recorder  *transcript.Recorder
+}
+
+// transcriptRecorderFor returns the transcript recorder for the current configuration,
+// rebuilding it when the settings change. The replaced recorder drains in the background.
+func transcriptRecorderFor(cfg *config.SDKConfig) *transcript.Recorder {
+	signature := ""
+	if cfg != nil && cfg.Transcripts.Enable {
+		raw, _ := json.Marshal(cfg.Transcripts)
+		signature = string(raw)
+		if cfg.Transcripts.Redact {
+			rawPII, _ := json.Marshal(cfg.PIIRedaction)
+			signature += string(rawPII)
+		}
+	}
+
+	transcriptState.mu.Lock()
+	defer transcriptState.mu.Unlock()
+	if transcriptState.signature == signature {
+		return transcriptState.recorder
+	}
+	if previous := transcriptState.recorder; previous != nil {
+		go previous.Close()
+	}
+	transcriptState.signature, transcriptState.recorder = signature, nil
+	if signature == "" {
+		return nil
+	}
+	recorder, err := transcript.NewRecorder(cfg.Transcripts, cfg.PIIRedaction)
+	if err != nil {
+		log.Errorf("transcripts disabled: %v", err)
+		return nil
+	}
+	transcriptState.recorder = recorder
+	return recorder
+}
+
+// transcriptRecorder returns the recorder when the request's client key opted in to transcripts.
+func (h *BaseAPIHandler) transcriptRecorder(ctx context.Context) *transcript.Recorder {
+	if h == nil || h.Cfg == nil || !h.Cfg.Transcripts.Enable {
+		return nil
+	}
+	policy := h.keyPolicy(ctx, requestAPIKey(ctx))
+	if policy == nil || !policy.RecordTranscripts {
+		return nil
+	}
+	return transcriptRecorderFor(h.Cfg)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/transform"
+)
+
+// applyPreTranslateTransforms runs the pre-translate transformers configured for the inbound
+// route on the client request before it is handed to the translators.
+func (h *BaseAPIHandler) applyPreTranslateTransforms(ctx context.Context, handlerType, modelName string, rawJSON []byte, stream bool) []byte {
+	return h.applyTransforms(ctx, transform.StagePreTranslate, handlerType, modelName, rawJSON, stream)
+}
+
+// applyResponseTransforms runs the response transformers configured for the inbound route on a
+// translated client payload. For streams it is called once per chunk.
+func (h *BaseAPIHandler) applyResponseTransforms(ctx context.Context, handlerType, modelName string, payload []byte, stream bool) []byte {
+	return h.applyTransforms(ctx, transform.StageResponse, handlerType, modelName, payload, stream)
+}
+
+func (h *BaseAPIHandler) applyTransforms(ctx context.Context, stage transform.Stage, handlerType, modelName string, payload []byte, stream bool) []byte {
+	if h == nil || h.Cfg == nil || !transform.HasStage(h.Cfg.Transforms, stage) {
+		return payload
+	}
+	p := &transform.Payload{
+		Stage:  stage,
+		Model:  modelName,
+		Format: handlerType,
+		Stream: stream,
+		Body:   payload,
+	}
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+		if ginCtx.Request != nil && ginCtx.Request.URL != nil {
+			p.Route = ginCtx.Request.URL.Path
+		}
+		if stage == transform.StageResponse && ginCtx.Writer != nil {
+			p.Header = ginCtx.Writer.Header()
+		}
+	}
+	transform.Apply(ctx, h.Cfg.Transforms, p)
+	return p.Body
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type codedTestError struct{}
+
+func (codedTestError) Error() string   { return `{"error":{"code":"upstream_idle_timeout"}}` }
+func (codedTestError) StatusCode() int { return http.StatusGatewayTimeout }
+
+func TestUpstreamErrorUnwrapsCodedTransportErrors(t *testing.T) {
+	wrapped := &url.Error{Op: "Post", URL: "https://upstream.example/v1", Err: codedTestError{}}
+	if got := upstreamError(wrapped); got != (codedTestError{}) || statusFromError(got) != http.StatusGatewayTimeout {
+		t.Fatalf("upstreamError = %v", got)
+	}
+	plain := &url.Error{Op: "Post", URL: "https://upstream.example/v1", Err: errors.New("connection refused")}
+	if got := upstreamError(plain); got != error(plain) {
+		t.Fatalf("plain transport error changed: %v", got)
+	}
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSessionAffinityTTL is how long an idle conversation stays bound to its credential.
+	defaultSessionAffinityTTL = time.Hour
+	// sessionAffinitySweepSize is the binding count at which expired bindings are first swept.
+	sessionAffinitySweepSize = 1024
+)
+
+type sessionAffinityContextKey struct{}
+
+type sessionAffinityRequest struct {
+	key string
+	ttl time.Duration
+}
+
+// WithSessionAffinity returns a context that keeps requests sharing key on the same credential,
+// so follow-up turns of a conversation hit the provider-side prompt cache. The binding expires
+// after ttl without requests; a non-positive ttl uses one hour.
+func WithSessionAffinity(ctx context.Context, key string, ttl time.Duration) context.Context {
+	if key == "" {
+		return ctx
+	}
+	if ttl <= 0 {
+		ttl = defaultSessionAffinityTTL
+	}
+	return context.WithValue(ctx, sessionAffinityContextKey{}, sessionAffinityRequest{key: key, ttl: ttl})
+}
+
+// SessionAffinityFromContext returns the affinity key set by WithSessionAffinity, if any.
+func SessionAffinityFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	req, _ := ctx.Value(sessionAffinityContextKey{}).(sessionAffinityRequest)
+	return req.key
+}
+
+type affinityBinding struct {
+	authID  string
+	expires time.Time
+}
+
+// sessionAffinity maps conversation keys to the credential that served them.
+type sessionAffinity struct {
+	mu       sync.Mutex
+	bindings map[string]affinityBinding
+	sweepAt  int
+}
+
+func affinityBindingKey(key, provider, model string) string {
+	return key + "\x00" + provider + "\x00" + model
+}
+
+// lookup returns the credential bound to key, if the binding is still live.
+func (a *sessionAffinity) lookup(key string, now time.Time) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	binding, ok := a.bindings[key]
+	if !ok || now.After(binding.expires) {
+		return ""
+	}
+	return binding.authID
+}
+
+// bind records that key is served by authID and extends the binding by ttl.
+func (a *sessionAffinity) bind(key, authID string, ttl time.Duration, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.bindings == nil {
+		a.bindings = make(map[string]affinityBinding)
+	}
+	a.bindings[key] = affinityBinding{authID: authID, expires: now.Add(ttl)}
+	if a.sweepAt < sessionAffinitySweepSize {
+		a.sweepAt = sessionAffinitySweepSize
+	}
+	if len(a.bindings) < a.sweepAt {
+		return
+	}
+	for k, binding := range a.bindings {
+		if now.After(binding.expires) {
+			delete(a.bindings, k)
+		}
+	}
+	a.sweepAt = 2 * len(a.bindings)
+}
+
+// affinityPick returns the candidate bound to the request's conversation when it can serve model.
+// Otherwise it returns nil and the caller falls back to the selector.
+func (m *Manager) affinityPick(ctx context.Context, provider, model string, candidates []*Auth, now time.Time) *Auth {
+	req, ok := ctx.Value(sessionAffinityContextKey{}).(sessionAffinityRequest)
+	if !ok || req.key == "" {
+		return nil
+	}
+	authID := m.affinity.lookup(affinityBindingKey(req.key, provider, model), now)
+	if authID == "" {
+		return nil
+	}
+	for _, candidate := range candidates {
+		if candidate.ID != authID {
+			continue
+		}
+		if blocked, _, _ := isAuthBlockedForModel(candidate, model, now); blocked {
+			return nil
+		}
+		return candidate
+	}
+	return nil
+}
+
+// bindAffinity records the credential chosen for the request's conversation.
+func (m *Manager) bindAffinity(ctx context.Context, provider, model, authID string, now time.Time) {
+	req, ok := ctx.Value(sessionAffinityContextKey{}).(sessionAffinityRequest)
+	if !ok || req.key == "" {
+		return
+	}
+	m.affinity.bind(affinityBindingKey(req.key, provider, model), authID, req.ttl, now)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"path/filepath"
 	"strconv"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/credcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
@@ -118,8 +120,25 @@ type Manager struct {
 	// Optional HTTP RoundTripper provider injected by host.
 	rtProvider RoundTripperProvider
 
+	// Per-upstream concurrency limiters, created lazily for providers with a configured limit.
+	limiterMu         sync.Mutex
+	concurrencyLimits map[string]ConcurrencyLimit
+	limiters          map[string]*upstreamLimiter
+
 	// Auto refresh state
 	refreshCancel context.CancelFunc
+
+	// Background upstream health probes.
+	health healthProber
+
+	// Automatic removal of credentials that keep failing authentication or quota checks.
+	credentialHealth credentialHealthTracker
+
+	// Conversation to credential bindings for session affinity.
+	affinity sessionAffinity
+
+	// Hedged stream settings.
+	hedging atomic.Pointer[HedgingConfig]
 }
 
 // NewManager constructs a manager with optional custom selector and hook.
@@ -210,6 +229,9 @@ func (m *Manager) Register(ctx context.Context, auth *Auth) (*Auth, error) {
 	if auth.ID == "" {
 		auth.ID = uuid.NewString()
 	}
+	if err := openCredentials(auth); err != nil {
+		return nil, err
+	}
 	auth.EnsureIndex()
 	m.mu.Lock()
 	m.auths[auth.ID] = auth.Clone()
@@ -224,6 +246,9 @@ func (m *Manager) Update(ctx context.Context, auth *Auth) (*Auth, error) {
 	if auth == nil || auth.ID == "" {
 		return nil, nil
 	}
+	if err := openCredentials(auth); err != nil {
+		return nil, err
+	}
 	m.mu.Lock()
 	if existing, ok := m.auths[auth.ID]; ok && existing != nil && !auth.indexAssigned && auth.Index == "" {
 		auth.Index = existing.Index
@@ -253,12 +278,27 @@ func (m *Manager) Load(ctx context.Context) error {
 		if auth == nil || auth.ID == "" {
 			continue
 		}
+		if errOpen := openCredentials(auth); errOpen != nil {
+			log.Warnf("skipping auth: %v", errOpen)
+			continue
+		}
 		auth.EnsureIndex()
 		m.auths[auth.ID] = auth.Clone()
 	}
 	return nil
 }
 
+// openCredentials decrypts the secret fields of auth.Metadata that were encrypted at rest.
+// Credentials stay encrypted in the store and are only decrypted once registered for use.
+func openCredentials(auth *Auth) error {
+	metadata, err := credcrypt.Open(auth.Metadata)
+	if err != nil {
+		return fmt.Errorf("auth %s: %w", auth.ID, err)
+	}
+	auth.Metadata = metadata
+	return nil
+}
+
 // Execute performs a non-streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
 func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
@@ -287,6 +327,7 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 		if !shouldRetry {
 			break
 		}
+		traceRetry(ctx, attempt+1, wait, errExec)
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return cliproxyexecutor.Response{}, errWait
 		}
@@ -325,6 +366,7 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 		if !shouldRetry {
 			break
 		}
+		traceRetry(ctx, attempt+1, wait, errExec)
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return cliproxyexecutor.Response{}, errWait
 		}
@@ -363,6 +405,7 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 		if !shouldRetry {
 			break
 		}
+		traceRetry(ctx, attempt+1, wait, errStream)
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return nil, errWait
 		}
@@ -377,9 +420,15 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 	if provider == "" {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
+	release, errAcquire := m.acquireUpstream(ctx, provider)
+	if errAcquire != nil {
+		return cliproxyexecutor.Response{}, errAcquire
+	}
+	defer release()
 	routeModel := req.Model
 	tried := make(map[string]struct{})
 	var lastErr error
+	var lastAuthID string
 	for {
 		auth, executor, errPick := m.pickNext(ctx, provider, routeModel, opts, tried)
 		if errPick != nil {
@@ -393,6 +442,8 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 		debugLogAuthSelection(entry, auth, provider, req.Model)
 
 		tried[auth.ID] = struct{}{}
+		notifyRouting(ctx, provider, routeModel, auth, len(tried), lastAuthID, lastErr)
+		lastAuthID = auth.ID
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
@@ -401,8 +452,14 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 		execReq := req
 		execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
 		execReq.Model, execReq.Metadata = m.applyOAuthModelMapping(auth, execReq.Model, execReq.Metadata)
+		traceSelection(ctx, provider, routeModel, execReq.Model, auth, len(tried))
+		started := time.Now()
 		resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
+		traceAttempt(ctx, "upstream.attempt", auth.ID, started, errExec)
 		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
+		if errExec == nil {
+			defaultLatency.observe(auth.ID, provider, time.Since(started), len(resp.Payload), 0)
+		}
 		if errExec != nil {
 			result.Error = &Error{Message: errExec.Error()}
 			var se cliproxyexecutor.StatusError
@@ -428,6 +485,7 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 	routeModel := req.Model
 	tried := make(map[string]struct{})
 	var lastErr error
+	var lastAuthID string
 	for {
 		auth, executor, errPick := m.pickNext(ctx, provider, routeModel, opts, tried)
 		if errPick != nil {
@@ -441,6 +499,8 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 		debugLogAuthSelection(entry, auth, provider, req.Model)
 
 		tried[auth.ID] = struct{}{}
+		notifyRouting(ctx, provider, routeModel, auth, len(tried), lastAuthID, lastErr)
+		lastAuthID = auth.ID
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
@@ -449,6 +509,7 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 		execReq := req
 		execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
 		execReq.Model, execReq.Metadata = m.applyOAuthModelMapping(auth, execReq.Model, execReq.Metadata)
+		traceSelection(ctx, provider, routeModel, execReq.Model, auth, len(tried))
 		resp, errExec := executor.CountTokens(execCtx, auth, execReq, opts)
 		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 		if errExec != nil {
@@ -473,12 +534,26 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 	if provider == "" {
 		return nil, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
+	if delay := m.hedgeDelay(provider); delay > 0 {
+		return m.hedgedStream(ctx, provider, req, opts, delay)
+	}
+	return m.openStream(ctx, provider, req, opts, make(map[string]struct{}))
+}
+
+// openStream starts a stream on the next untried auth of provider, adding every auth it tries to
+// tried.
+func (m *Manager) openStream(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, tried map[string]struct{}) (<-chan cliproxyexecutor.StreamChunk, error) {
+	release, errAcquire := m.acquireUpstream(ctx, provider)
+	if errAcquire != nil {
+		return nil, errAcquire
+	}
 	routeModel := req.Model
-	tried := make(map[string]struct{})
 	var lastErr error
+	var lastAuthID string
 	for {
 		auth, executor, errPick := m.pickNext(ctx, provider, routeModel, opts, tried)
 		if errPick != nil {
+			release()
 			if lastErr != nil {
 				return nil, lastErr
 			}
@@ -489,6 +564,8 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 		debugLogAuthSelection(entry, auth, provider, req.Model)
 
 		tried[auth.ID] = struct{}{}
+		notifyRouting(ctx, provider, routeModel, auth, len(tried), lastAuthID, lastErr)
+		lastAuthID = auth.ID
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
@@ -497,7 +574,14 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 		execReq := req
 		execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
 		execReq.Model, execReq.Metadata = m.applyOAuthModelMapping(auth, execReq.Model, execReq.Metadata)
+		traceSelection(ctx, provider, routeModel, execReq.Model, auth, len(tried))
+		started := time.Now()
 		chunks, errStream := executor.ExecuteStream(execCtx, auth, execReq, opts)
+		traceAttempt(ctx, "upstream.connect", auth.ID, started, errStream)
+		if errStream != nil && hedgeLost(ctx) {
+			release()
+			return nil, errStream
+		}
 		if errStream != nil {
 			rerr := &Error{Message: errStream.Error()}
 			var se cliproxyexecutor.StatusError
@@ -513,10 +597,22 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 		out := make(chan cliproxyexecutor.StreamChunk)
 		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
 			defer close(out)
+			// The upstream slot is held until the stream has been fully relayed.
+			defer release()
 			var failed bool
+			var streamErr error
+			var firstChunk time.Time
+			var streamed int
 			for chunk := range streamChunks {
-				if chunk.Err != nil && !failed {
+				if len(chunk.Payload) > 0 {
+					if firstChunk.IsZero() {
+						firstChunk = time.Now()
+					}
+					streamed += len(chunk.Payload)
+				}
+				if chunk.Err != nil && !failed && !hedgeLost(streamCtx) {
 					failed = true
+					streamErr = chunk.Err
 					rerr := &Error{Message: chunk.Err.Error()}
 					var se cliproxyexecutor.StatusError
 					if errors.As(chunk.Err, &se) && se != nil {
@@ -526,7 +622,11 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 				}
 				out <- chunk
 			}
-			if !failed {
+			traceAttempt(streamCtx, "upstream.stream", streamAuth.ID, started, streamErr)
+			if !failed && !hedgeLost(streamCtx) {
+				if !firstChunk.IsZero() {
+					defaultLatency.observe(streamAuth.ID, streamProvider, firstChunk.Sub(started), streamed, time.Since(firstChunk))
+				}
 				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: true})
 			}
 		}(execCtx, auth.Clone(), provider, chunks)
@@ -760,9 +860,13 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	suspendReason := ""
 	clearModelQuota := false
 	setModelQuota := false
+	var unauthorized *Auth
 
 	m.mu.Lock()
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
+		if !result.Success && statusCodeFromResult(result.Error) == 401 {
+			unauthorized = auth.Clone()
+		}
 		now := time.Now()
 
 		if result.Success {
@@ -848,10 +952,18 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 			}
 		}
 
+		m.trackCredentialHealth(auth, result, now)
+
 		_ = m.persist(ctx, auth)
 	}
 	m.mu.Unlock()
 
+	notifyUpstreamError(ctx, result)
+	if unauthorized != nil {
+		notifyCredentialExpired(unauthorized, "unauthorized", result.Error.Message)
+	} else if result.Success {
+		clearCredentialExpired(result.AuthID)
+	}
 	if clearModelQuota && result.Model != "" {
 		registry.GetGlobalRegistry().ClearModelQuotaExceeded(result.AuthID, result.Model)
 	}
@@ -1138,7 +1250,26 @@ func (m *Manager) GetByID(id string) (*Auth, bool) {
 	return auth.Clone(), true
 }
 
+type pinnedAuthContextKey struct{}
+
+// WithPinnedAuth returns a context that restricts auth selection to the auth with the given ID.
+func WithPinnedAuth(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, pinnedAuthContextKey{}, id)
+}
+
+// PinnedAuthFromContext returns the auth ID pinned by WithPinnedAuth, if any.
+func PinnedAuthFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(pinnedAuthContextKey{}).(string)
+	return id
+}
+
 func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (*Auth, ProviderExecutor, error) {
+	pinned := PinnedAuthFromContext(ctx)
+	tenant := TenantFromContext(ctx)
+	filter, hasFilter := CredentialFilterFromContext(ctx)
 	m.mu.RLock()
 	executor, okExecutor := m.executors[provider]
 	if !okExecutor {
@@ -1152,6 +1283,15 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		if candidate.Provider != provider || candidate.Disabled {
 			continue
 		}
+		if pinned != "" && candidate.ID != pinned {
+			continue
+		}
+		if !TenantAllows(tenant, candidate) {
+			continue
+		}
+		if hasFilter && !filter.Allows(candidate) {
+			continue
+		}
 		if _, used := tried[candidate.ID]; used {
 			continue
 		}
@@ -1164,15 +1304,21 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		m.mu.RUnlock()
 		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
-	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
-	if errPick != nil {
-		m.mu.RUnlock()
-		return nil, nil, errPick
-	}
+	now := time.Now()
+	selected := m.affinityPick(ctx, provider, model, candidates, now)
 	if selected == nil {
-		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "auth_not_found", Message: "selector returned no auth"}
+		var errPick error
+		selected, errPick = m.selector.Pick(ctx, provider, model, opts, candidates)
+		if errPick != nil {
+			m.mu.RUnlock()
+			return nil, nil, errPick
+		}
+		if selected == nil {
+			m.mu.RUnlock()
+			return nil, nil, &Error{Code: "auth_not_found", Message: "selector returned no auth"}
+		}
 	}
+	m.bindAffinity(ctx, provider, model, selected.ID, now)
 	authCopy := selected.Clone()
 	m.mu.RUnlock()
 	if !selected.indexAssigned {
@@ -1524,6 +1670,7 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 			m.auths[id] = current
 		}
 		m.mu.Unlock()
+		notifyCredentialExpired(auth, "refresh_failed", err.Error())
 		return
 	}
 	if updated == nil {
@@ -1539,6 +1686,7 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	updated.LastError = nil
 	updated.UpdatedAt = now
 	_, _ = m.Update(ctx, updated)
+	clearCredentialExpired(id)
 }
 
 func (m *Manager) executorFor(provider string) ProviderExecutor {
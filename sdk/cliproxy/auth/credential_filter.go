@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+// CredentialFilter restricts auth selection to a subset of a provider's credentials.
+type CredentialFilter struct {
+	// Kind limits selection to "api-key" or "oauth" credentials, as reported by AccountInfo.
+	Kind string
+	// Patterns match credential IDs or file names as globs, or credential prefixes exactly.
+	Patterns []string
+}
+
+type credentialFilterContextKey struct{}
+
+// WithCredentialFilter returns a context that restricts auth selection to credentials allowed by filter.
+func WithCredentialFilter(ctx context.Context, filter CredentialFilter) context.Context {
+	return context.WithValue(ctx, credentialFilterContextKey{}, filter)
+}
+
+// CredentialFilterFromContext returns the filter set by WithCredentialFilter, if any.
+func CredentialFilterFromContext(ctx context.Context) (CredentialFilter, bool) {
+	if ctx == nil {
+		return CredentialFilter{}, false
+	}
+	filter, ok := ctx.Value(credentialFilterContextKey{}).(CredentialFilter)
+	return filter, ok
+}
+
+// Allows reports whether the filter admits the auth.
+func (f CredentialFilter) Allows(auth *Auth) bool {
+	if auth == nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(f.Kind)) {
+	case "":
+	case "api-key", "api_key", "apikey":
+		if kind, _ := auth.AccountInfo(); kind != "api_key" {
+			return false
+		}
+	case "oauth":
+		if kind, _ := auth.AccountInfo(); kind != "oauth" {
+			return false
+		}
+	}
+	if len(f.Patterns) > 0 && !credentialMatches(auth, f.Patterns) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultCredentialFailureThreshold = 3
+	defaultCredentialRecheckInterval  = 5 * time.Minute
+	defaultCredentialMaxRecheck       = time.Hour
+	// credentialRecheckTick is how often the re-check loop looks for credentials due a probe.
+	credentialRecheckTick = 15 * time.Second
+)
+
+// Credential health failure reasons.
+const (
+	CredentialFailureAuth  = "auth"
+	CredentialFailureQuota = "quota"
+)
+
+// quotaExhaustedMarkers identify 429 responses reporting an exhausted quota or plan limit rather
+// than a short-lived rate limit.
+var quotaExhaustedMarkers = []string{
+	"insufficient_quota",
+	"exceeded your current quota",
+	"quota exceeded",
+	"quota_exceeded",
+	"usage_limit_reached",
+	"billing",
+	"credit balance",
+}
+
+// CredentialHealthConfig controls automatic disabling of credentials that keep failing.
+type CredentialHealthConfig struct {
+	// Enabled turns tracking on.
+	Enabled bool
+	// FailureThreshold is the number of consecutive authentication or quota-exhausted failures
+	// that mark a credential unhealthy.
+	FailureThreshold int
+	// RecheckInterval is the delay before the first re-check of an unhealthy credential; it
+	// doubles after every failed re-check up to MaxRecheckInterval.
+	RecheckInterval    time.Duration
+	MaxRecheckInterval time.Duration
+	// ProbeTimeout bounds a single re-check.
+	ProbeTimeout time.Duration
+	// Models maps a provider to the model used for re-checks, as in HealthProbeConfig.
+	Models map[string]string
+}
+
+// CredentialHealth tracks consecutive credential-level failures of an auth. Unhealthy credentials
+// are skipped by selection until a re-check or a request through them succeeds.
+type CredentialHealth struct {
+	// ConsecutiveFailures counts authentication or quota-exhausted failures since the last success.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// Reason is the kind of the latest counted failure: "auth" or "quota".
+	Reason string `json:"reason,omitempty"`
+	// LastStatus is the HTTP status of the latest counted failure.
+	LastStatus int `json:"last_status,omitempty"`
+	// Unhealthy removes the credential from rotation.
+	Unhealthy bool `json:"unhealthy"`
+	// Since is when the credential was marked unhealthy.
+	Since time.Time `json:"since,omitzero"`
+	// NextCheckAt is when the next re-check is due.
+	NextCheckAt time.Time `json:"next_check_at,omitzero"`
+	// Rechecks counts the failed re-checks since the credential was marked unhealthy.
+	Rechecks int `json:"rechecks,omitempty"`
+}
+
+// credentialHealthTracker holds the settings and re-check loop of a Manager.
+type credentialHealthTracker struct {
+	cfg    atomic.Pointer[CredentialHealthConfig]
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// SetCredentialHealth applies the credential health settings and starts or stops the re-check
+// loop. Disabling tracking returns every unhealthy credential to rotation.
+func (m *Manager) SetCredentialHealth(cfg CredentialHealthConfig) {
+	if m == nil {
+		return
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultCredentialFailureThreshold
+	}
+	if cfg.RecheckInterval <= 0 {
+		cfg.RecheckInterval = defaultCredentialRecheckInterval
+	}
+	if cfg.MaxRecheckInterval < cfg.RecheckInterval {
+		cfg.MaxRecheckInterval = max(defaultCredentialMaxRecheck, cfg.RecheckInterval)
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = defaultHealthProbeTimeout
+	}
+	m.credentialHealth.cfg.Store(&cfg)
+
+	m.credentialHealth.mu.Lock()
+	defer m.credentialHealth.mu.Unlock()
+	if m.credentialHealth.cancel != nil {
+		m.credentialHealth.cancel()
+		m.credentialHealth.cancel = nil
+	}
+	if !cfg.Enabled {
+		m.mu.Lock()
+		for _, auth := range m.auths {
+			auth.Health = CredentialHealth{}
+		}
+		m.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.credentialHealth.cancel = cancel
+	go m.recheckCredentials(ctx)
+}
+
+// StopCredentialHealth stops the re-check loop. Tracked state is kept.
+func (m *Manager) StopCredentialHealth() {
+	if m == nil {
+		return
+	}
+	m.credentialHealth.mu.Lock()
+	defer m.credentialHealth.mu.Unlock()
+	if m.credentialHealth.cancel != nil {
+		m.credentialHealth.cancel()
+		m.credentialHealth.cancel = nil
+	}
+}
+
+// ResetCredentialHealth returns a credential to rotation immediately. It reports whether the
+// credential exists.
+func (m *Manager) ResetCredentialHealth(id string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	auth, ok := m.auths[id]
+	if !ok || auth == nil {
+		return false
+	}
+	if auth.Health.Unhealthy {
+		log.Infof("credential health: %s credential %s returned to rotation by operator", auth.Provider, id)
+	}
+	auth.Health = CredentialHealth{}
+	return true
+}
+
+// trackCredentialHealth updates auth.Health with a request outcome. Callers hold m.mu.
+func (m *Manager) trackCredentialHealth(auth *Auth, result Result, now time.Time) {
+	cfg := m.credentialHealth.cfg.Load()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	health := &auth.Health
+	if result.Success {
+		if health.Unhealthy {
+			log.Infof("credential health: %s credential %s is healthy again", auth.Provider, auth.ID)
+		}
+		*health = CredentialHealth{}
+		return
+	}
+	reason := credentialFailureReason(result.Error)
+	if reason == "" {
+		// Transient upstream errors neither count towards nor reset the streak.
+		return
+	}
+	health.ConsecutiveFailures++
+	health.Reason = reason
+	health.LastStatus = statusCodeFromResult(result.Error)
+	if health.Unhealthy {
+		health.Rechecks++
+		health.NextCheckAt = now.Add(credentialRecheckDelay(cfg, health.Rechecks))
+		return
+	}
+	if health.ConsecutiveFailures >= cfg.FailureThreshold {
+		health.Unhealthy = true
+		health.Since = now
+		health.Rechecks = 0
+		health.NextCheckAt = now.Add(cfg.RecheckInterval)
+		log.Warnf("credential health: %s credential %s removed from rotation after %d consecutive %s failures (status %d); re-check at %s",
+			auth.Provider, auth.ID, health.ConsecutiveFailures, reason, health.LastStatus, health.NextCheckAt.Format(time.RFC3339))
+	}
+}
+
+// credentialFailureReason classifies a failure as an authentication or quota-exhausted failure,
+// or returns "" for failures that say nothing about the credential itself.
+func credentialFailureReason(err *Error) string {
+	switch statusCodeFromResult(err) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return CredentialFailureAuth
+	case http.StatusPaymentRequired:
+		return CredentialFailureQuota
+	case http.StatusTooManyRequests:
+		message := strings.ToLower(err.Message)
+		for _, marker := range quotaExhaustedMarkers {
+			if strings.Contains(message, marker) {
+				return CredentialFailureQuota
+			}
+		}
+	}
+	return ""
+}
+
+// credentialRecheckDelay doubles the re-check interval for every failed re-check.
+func credentialRecheckDelay(cfg *CredentialHealthConfig, rechecks int) time.Duration {
+	delay := cfg.RecheckInterval
+	for i := 0; i < rechecks && delay < cfg.MaxRecheckInterval; i++ {
+		delay *= 2
+	}
+	return min(delay, cfg.MaxRecheckInterval)
+}
+
+// recheckCredentials probes unhealthy credentials when their re-check is due.
+func (m *Manager) recheckCredentials(ctx context.Context) {
+	ticker := time.NewTicker(credentialRecheckTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cfg := m.credentialHealth.cfg.Load()
+		if cfg == nil || !cfg.Enabled {
+			return
+		}
+		for _, auth := range m.dueCredentialRechecks(cfg, time.Now()) {
+			if ctx.Err() != nil {
+				return
+			}
+			status := m.probeAuth(ctx, auth, HealthProbeConfig{Timeout: cfg.ProbeTimeout, Models: cfg.Models})
+			if status.Healthy {
+				// probeAuth reports successes to MarkResult, but a probe the upstream rejected for
+				// reasons unrelated to the credential does not reach it.
+				m.ResetCredentialHealth(auth.ID)
+				continue
+			}
+			log.Debugf("credential health: re-check of %s credential %s failed: %s", auth.Provider, auth.ID, status.Error)
+		}
+	}
+}
+
+// dueCredentialRechecks returns unhealthy credentials whose re-check is due and schedules their
+// next one, so a probe that cannot report a result is not retried on every tick.
+func (m *Manager) dueCredentialRechecks(cfg *CredentialHealthConfig, now time.Time) []*Auth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []*Auth
+	for _, auth := range m.auths {
+		if auth == nil || auth.Disabled || !auth.Health.Unhealthy || auth.Health.NextCheckAt.After(now) {
+			continue
+		}
+		auth.Health.NextCheckAt = now.Add(credentialRecheckDelay(cfg, auth.Health.Rechecks+1))
+		due = append(due, auth.Clone())
+	}
+	return due
+}
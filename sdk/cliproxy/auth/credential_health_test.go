@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newCredentialHealthManager(t *testing.T, ids ...string) *Manager {
+	t.Helper()
+	m := NewManager(nil, nil, nil)
+	m.SetCredentialHealth(CredentialHealthConfig{Enabled: true, FailureThreshold: 2, RecheckInterval: time.Minute, MaxRecheckInterval: 3 * time.Minute})
+	t.Cleanup(m.StopCredentialHealth)
+	for _, id := range ids {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "probe"}); err != nil {
+			t.Fatalf("Register(%s) error = %v", id, err)
+		}
+	}
+	return m
+}
+
+func markFailure(m *Manager, id string, status int, message string) {
+	m.MarkResult(context.Background(), Result{AuthID: id, Provider: "probe", Model: "probe-model", Error: &Error{HTTPStatus: status, Message: message}})
+}
+
+func TestCredentialHealthRemovesFailingCredential(t *testing.T) {
+	m := newCredentialHealthManager(t, "a")
+
+	markFailure(m, "a", http.StatusUnauthorized, "invalid token")
+	if auth, _ := m.GetByID("a"); auth.Health.Unhealthy || auth.Health.ConsecutiveFailures != 1 {
+		t.Fatalf("health after one failure = %+v, want one failure and healthy", auth.Health)
+	}
+	markFailure(m, "a", http.StatusInternalServerError, "upstream error")
+	markFailure(m, "a", http.StatusForbidden, "forbidden")
+
+	auth, _ := m.GetByID("a")
+	if !auth.Health.Unhealthy || auth.Health.Reason != CredentialFailureAuth || auth.Health.LastStatus != http.StatusForbidden {
+		t.Fatalf("health = %+v, want unhealthy after auth failures", auth.Health)
+	}
+	// Unhealthy credentials stay out of rotation even for models without a cooldown.
+	if blocked, _, next := isAuthBlockedForModel(auth, "other-model", time.Now()); !blocked || !next.Equal(auth.Health.NextCheckAt) {
+		t.Fatalf("isAuthBlockedForModel() = %v, %v; want blocked until the re-check", blocked, next)
+	}
+
+	m.MarkResult(context.Background(), Result{AuthID: "a", Provider: "probe", Model: "probe-model", Success: true})
+	if auth, _ = m.GetByID("a"); auth.Health != (CredentialHealth{}) {
+		t.Fatalf("health after success = %+v, want reset", auth.Health)
+	}
+}
+
+func TestCredentialHealthQuotaClassification(t *testing.T) {
+	m := newCredentialHealthManager(t, "a", "b")
+
+	for i := 0; i < 3; i++ {
+		markFailure(m, "a", http.StatusTooManyRequests, "rate limit reached for requests per minute")
+		markFailure(m, "b", http.StatusTooManyRequests, `{"error":{"type":"insufficient_quota","message":"You exceeded your current quota"}}`)
+	}
+	if auth, _ := m.GetByID("a"); auth.Health.ConsecutiveFailures != 0 {
+		t.Fatalf("rate limited credential health = %+v, want untracked", auth.Health)
+	}
+	if auth, _ := m.GetByID("b"); !auth.Health.Unhealthy || auth.Health.Reason != CredentialFailureQuota {
+		t.Fatalf("quota exhausted credential health = %+v, want unhealthy for quota", auth.Health)
+	}
+}
+
+func TestCredentialHealthRecheckBackoff(t *testing.T) {
+	m := newCredentialHealthManager(t, "a")
+	cfg := m.credentialHealth.cfg.Load()
+	markFailure(m, "a", http.StatusUnauthorized, "")
+	markFailure(m, "a", http.StatusUnauthorized, "")
+
+	now := time.Now()
+	if due := m.dueCredentialRechecks(cfg, now); len(due) != 0 {
+		t.Fatalf("dueCredentialRechecks() before the interval = %d, want 0", len(due))
+	}
+	due := m.dueCredentialRechecks(cfg, now.Add(time.Minute+time.Second))
+	if len(due) != 1 || due[0].ID != "a" {
+		t.Fatalf("dueCredentialRechecks() = %d credentials, want a", len(due))
+	}
+	// The failed re-check doubles the interval, capped at the maximum.
+	for i, want := range []time.Duration{2 * time.Minute, 3 * time.Minute} {
+		before := time.Now()
+		markFailure(m, "a", http.StatusUnauthorized, "")
+		auth, _ := m.GetByID("a")
+		if auth.Health.Rechecks != i+1 || auth.Health.NextCheckAt.Sub(before) < want || auth.Health.NextCheckAt.Sub(before) > want+time.Second {
+			t.Fatalf("after re-check %d health = %+v, want next check in %v", i+1, auth.Health, want)
+		}
+	}
+
+	if !m.ResetCredentialHealth("a") {
+		t.Fatal("ResetCredentialHealth() = false, want true")
+	}
+	if auth, _ := m.GetByID("a"); auth.Health.Unhealthy {
+		t.Fatal("credential still unhealthy after reset")
+	}
+	if m.ResetCredentialHealth("missing") {
+		t.Fatal("ResetCredentialHealth(missing) = true, want false")
+	}
+}
+
+func TestCredentialHealthDisabledIsNoop(t *testing.T) {
+	m := newCredentialHealthManager(t, "a")
+	markFailure(m, "a", http.StatusUnauthorized, "")
+	markFailure(m, "a", http.StatusUnauthorized, "")
+	m.SetCredentialHealth(CredentialHealthConfig{})
+
+	auth, _ := m.GetByID("a")
+	if auth.Health.Unhealthy {
+		t.Fatal("disabling tracking left the credential out of rotation")
+	}
+	markFailure(m, "a", http.StatusUnauthorized, "")
+	if auth, _ = m.GetByID("a"); auth.Health.ConsecutiveFailures != 0 {
+		t.Fatalf("health with tracking disabled = %+v, want untracked", auth.Health)
+	}
+}
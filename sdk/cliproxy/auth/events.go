@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/events"
+)
+
+// credentialExpiredInterval limits credential.expired events to one per credential per interval
+// while it keeps failing.
+const credentialExpiredInterval = time.Hour
+
+var credentialExpiredSent sync.Map // auth ID -> time.Time
+
+// routingEvent is the data of routing.decision and upstream.failover events.
+type routingEvent struct {
+	FromAuthID string `json:"from_auth_id,omitempty"`
+	Attempt    int    `json:"attempt"`
+	Error      string `json:"error,omitempty"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+}
+
+// upstreamErrorEvent is the data of an upstream.error event.
+type upstreamErrorEvent struct {
+	HTTPStatus int    `json:"http_status,omitempty"`
+	Error      string `json:"error"`
+}
+
+// credentialEvent is the data of a credential.expired event.
+type credentialEvent struct {
+	Label  string `json:"label,omitempty"`
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+}
+
+// notifyRouting publishes the credential selected for an upstream attempt and, when a previous
+// credential failed, the failover away from it.
+func notifyRouting(ctx context.Context, provider, model string, auth *Auth, attempt int, fromID string, lastErr error) {
+	data := routingEvent{FromAuthID: fromID, Attempt: attempt}
+	if lastErr != nil {
+		data.Error = lastErr.Error()
+		data.HTTPStatus = statusCodeFromError(lastErr)
+	}
+	if events.Wanted(events.TypeRoutingDecision) {
+		events.Publish(events.Event{
+			Type:     events.TypeRoutingDecision,
+			Summary:  fmt.Sprintf("%s request for %s routed to %s (attempt %d)", provider, model, auth.ID, attempt),
+			Model:    model,
+			Upstream: provider,
+			AuthID:   auth.ID,
+			Data:     data,
+		}.WithClient(ctx))
+	}
+	if fromID != "" && events.Wanted(events.TypeUpstreamFailover) {
+		events.Publish(events.Event{
+			Type:     events.TypeUpstreamFailover,
+			Summary:  fmt.Sprintf("%s request for %s failed over from %s to %s", provider, model, fromID, auth.ID),
+			Model:    model,
+			Upstream: provider,
+			AuthID:   auth.ID,
+			Data:     data,
+		}.WithClient(ctx))
+	}
+}
+
+// notifyUpstreamError publishes a failed upstream attempt.
+func notifyUpstreamError(ctx context.Context, result Result) {
+	if result.Success || result.Error == nil || !events.Wanted(events.TypeUpstreamError) {
+		return
+	}
+	events.Publish(events.Event{
+		Type:     events.TypeUpstreamError,
+		Summary:  fmt.Sprintf("%s credential %s failed for %s: %s", result.Provider, result.AuthID, result.Model, result.Error.Message),
+		Model:    result.Model,
+		Upstream: result.Provider,
+		AuthID:   result.AuthID,
+		Data:     upstreamErrorEvent{HTTPStatus: statusCodeFromResult(result.Error), Error: result.Error.Message},
+	}.WithClient(ctx))
+}
+
+// notifyCredentialExpired reports a credential the upstream rejected as unauthorized or that
+// could not be refreshed.
+func notifyCredentialExpired(auth *Auth, reason string, errMsg string) {
+	if auth == nil || !events.Wanted(events.TypeCredentialExpired) {
+		return
+	}
+	now := time.Now()
+	if last, ok := credentialExpiredSent.Load(auth.ID); ok && now.Sub(last.(time.Time)) < credentialExpiredInterval {
+		return
+	}
+	credentialExpiredSent.Store(auth.ID, now)
+	events.Publish(events.Event{
+		Type:     events.TypeCredentialExpired,
+		Summary:  fmt.Sprintf("%s credential %s needs attention: %s", auth.Provider, auth.ID, reason),
+		Upstream: auth.Provider,
+		AuthID:   auth.ID,
+		Data:     credentialEvent{Label: auth.Label, Reason: reason, Error: errMsg},
+	})
+}
+
+// clearCredentialExpired re-arms credential.expired events once the credential works again.
+func clearCredentialExpired(authID string) {
+	credentialExpiredSent.Delete(authID)
+}
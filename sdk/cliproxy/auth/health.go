@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultHealthProbeInterval = 60 * time.Second
+	defaultHealthProbeTimeout  = 30 * time.Second
+	// healthProbeConcurrency bounds the probes in flight during one round.
+	healthProbeConcurrency = 4
+)
+
+// HealthProbeConfig configures the background upstream health probes.
+type HealthProbeConfig struct {
+	// Interval is the time between probe rounds.
+	Interval time.Duration
+	// Timeout bounds a single probe.
+	Timeout time.Duration
+	// Models maps a provider to the model used to probe its credentials. Providers without an
+	// entry are probed with the first model registered for the credential.
+	Models map[string]string
+}
+
+// UpstreamHealth reports the last probe result of one credential.
+type UpstreamHealth struct {
+	AuthID    string `json:"auth_id"`
+	Provider  string `json:"provider"`
+	Label     string `json:"label,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Healthy   bool   `json:"healthy"`
+	Status    int    `json:"status,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	// ConsecutiveFailures counts the failed probes since the last success.
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	CheckedAt           time.Time `json:"checked_at"`
+}
+
+// healthProber holds the probe loop state of a Manager.
+type healthProber struct {
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	results map[string]UpstreamHealth
+	rounds  int
+}
+
+// StartHealthProbes launches a background loop that sends a minimal completion through every
+// enabled credential each interval. Results feed MarkResult, so failing credentials enter the
+// usual cooldown and recovered ones return to rotation. Starting again replaces the running loop.
+func (m *Manager) StartHealthProbes(parent context.Context, cfg HealthProbeConfig) {
+	if m == nil {
+		return
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultHealthProbeInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultHealthProbeTimeout
+	}
+	m.health.mu.Lock()
+	if m.health.cancel != nil {
+		m.health.cancel()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	m.health.cancel = cancel
+	m.health.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		m.probeUpstreams(ctx, cfg)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.probeUpstreams(ctx, cfg)
+			}
+		}
+	}()
+}
+
+// StopHealthProbes cancels the probe loop and forgets its results.
+func (m *Manager) StopHealthProbes() {
+	if m == nil {
+		return
+	}
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	if m.health.cancel != nil {
+		m.health.cancel()
+		m.health.cancel = nil
+	}
+	m.health.results = nil
+	m.health.rounds = 0
+}
+
+// HealthProbesRunning reports whether the probe loop is active.
+func (m *Manager) HealthProbesRunning() bool {
+	if m == nil {
+		return false
+	}
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	return m.health.cancel != nil
+}
+
+// UpstreamHealth returns the latest probe results ordered by provider and credential, and the
+// number of completed probe rounds.
+func (m *Manager) UpstreamHealth() ([]UpstreamHealth, int) {
+	if m == nil {
+		return nil, 0
+	}
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	out := make([]UpstreamHealth, 0, len(m.health.results))
+	for _, status := range m.health.results {
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].AuthID < out[j].AuthID
+	})
+	return out, m.health.rounds
+}
+
+// probeUpstreams probes every enabled credential once and records the results.
+func (m *Manager) probeUpstreams(ctx context.Context, cfg HealthProbeConfig) {
+	auths := m.snapshotAuths()
+	results := make(map[string]UpstreamHealth, len(auths))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, healthProbeConcurrency)
+	for _, auth := range auths {
+		if auth == nil || auth.Disabled || auth.Status == StatusDisabled {
+			continue
+		}
+		wg.Add(1)
+		go func(auth *Auth) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+			status := m.probeAuth(ctx, auth, cfg)
+			mu.Lock()
+			results[auth.ID] = status
+			mu.Unlock()
+		}(auth)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	for id, status := range results {
+		previous, known := m.health.results[id]
+		if !status.Healthy {
+			status.ConsecutiveFailures = previous.ConsecutiveFailures + 1
+		}
+		if known && previous.Healthy != status.Healthy {
+			if status.Healthy {
+				log.Infof("health probe: %s credential %s is healthy again", status.Provider, id)
+			} else {
+				log.Warnf("health probe: %s credential %s is unhealthy: %s", status.Provider, id, status.Error)
+			}
+		}
+		results[id] = status
+	}
+	m.health.results = results
+	m.health.rounds++
+}
+
+// probeAuth sends a one-token completion through auth, bypassing selection so credentials in
+// cooldown are probed too, and reports the outcome to MarkResult.
+func (m *Manager) probeAuth(ctx context.Context, auth *Auth, cfg HealthProbeConfig) UpstreamHealth {
+	status := UpstreamHealth{AuthID: auth.ID, Provider: auth.Provider, Label: auth.Label, CheckedAt: time.Now()}
+	executor := m.executorFor(auth.Provider)
+	if executor == nil {
+		status.Error = "no executor registered for provider"
+		return status
+	}
+	model := probeModel(auth, cfg.Models)
+	if model == "" {
+		status.Error = "no model available to probe"
+		return status
+	}
+	status.Model = model
+
+	probeCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	if rt := m.roundTripperFor(auth); rt != nil {
+		probeCtx = context.WithValue(probeCtx, roundTripperContextKey{}, rt)
+		probeCtx = context.WithValue(probeCtx, "cliproxy.roundtripper", rt)
+	}
+	payload, _ := sjson.SetBytes([]byte(`{"messages":[{"role":"user","content":"ping"}],"max_tokens":1,"stream":false}`), "model", model)
+	req := cliproxyexecutor.Request{Model: model, Payload: payload}
+	req.Model, req.Metadata = rewriteModelForAuth(model, nil, auth)
+	req.Model, req.Metadata = m.applyOAuthModelMapping(auth, req.Model, req.Metadata)
+	opts := cliproxyexecutor.Options{OriginalRequest: payload, SourceFormat: sdktranslator.FromString("openai")}
+
+	started := time.Now()
+	_, errExec := executor.Execute(probeCtx, auth, req, opts)
+	status.LatencyMs = time.Since(started).Milliseconds()
+	if ctx.Err() != nil {
+		return status
+	}
+	status.Status = statusCodeFromError(errExec)
+	status.Healthy = probeHealthy(errExec, status.Status)
+	result := Result{AuthID: auth.ID, Provider: auth.Provider, Model: model, Success: errExec == nil}
+	if errExec != nil {
+		status.Error = errExec.Error()
+		if status.Healthy {
+			// The upstream answered and accepted the credential but rejected the probe itself;
+			// do not penalise the credential for it.
+			return status
+		}
+		result.Error = &Error{Message: errExec.Error(), HTTPStatus: status.Status}
+		result.RetryAfter = retryAfterFromError(errExec)
+	}
+	m.MarkResult(ctx, result)
+	return status
+}
+
+// probeHealthy reports whether a probe outcome shows a usable credential. Client errors other than
+// authentication, permission and rate-limit failures mean the upstream rejected the probe request
+// itself, for example a model that refuses max_tokens=1, and still count as healthy.
+func probeHealthy(err error, status int) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusPaymentRequired, http.StatusTooManyRequests:
+		return false
+	}
+	return status >= 400 && status < 500
+}
+
+// probeModel picks the model used to probe auth.
+func probeModel(auth *Auth, models map[string]string) string {
+	for provider, model := range models {
+		if strings.EqualFold(strings.TrimSpace(provider), auth.Provider) && strings.TrimSpace(model) != "" {
+			return strings.TrimSpace(model)
+		}
+	}
+	registered := registry.GetGlobalRegistry().GetModelsForClient(auth.ID)
+	ids := make([]string, 0, len(registered))
+	for _, info := range registered {
+		if info != nil && info.ID != "" {
+			ids = append(ids, info.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	sort.Strings(ids)
+	return ids[0]
+}
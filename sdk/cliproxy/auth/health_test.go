@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type probeStatusError struct{ code int }
+
+func (e probeStatusError) Error() string   { return http.StatusText(e.code) }
+func (e probeStatusError) StatusCode() int { return e.code }
+
+// probeExecutor fails requests for the credentials listed in failing.
+type probeExecutor struct {
+	failing map[string]int
+}
+
+func (e *probeExecutor) Identifier() string { return "probe" }
+
+func (e *probeExecutor) Execute(_ context.Context, auth *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if code, ok := e.failing[auth.ID]; ok {
+		return cliproxyexecutor.Response{}, probeStatusError{code: code}
+	}
+	return cliproxyexecutor.Response{Payload: []byte(`{"model":"` + req.Model + `"}`)}, nil
+}
+
+func (e *probeExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *probeExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *probeExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, errors.New("not implemented")
+}
+
+func TestProbeUpstreamsMarksFailingCredentials(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(&probeExecutor{failing: map[string]int{"b": http.StatusUnauthorized, "c": http.StatusBadRequest}})
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "probe"}); err != nil {
+			t.Fatalf("Register(%s) error = %v", id, err)
+		}
+	}
+	m.probeUpstreams(context.Background(), HealthProbeConfig{Timeout: defaultHealthProbeTimeout, Models: map[string]string{"probe": "probe-model"}})
+
+	results, rounds := m.UpstreamHealth()
+	if rounds != 1 || len(results) != 3 {
+		t.Fatalf("UpstreamHealth() = %d results after %d rounds, want 3 after 1", len(results), rounds)
+	}
+	want := map[string]bool{"a": true, "b": false, "c": true}
+	for _, status := range results {
+		if status.Healthy != want[status.AuthID] {
+			t.Fatalf("%s healthy = %v, want %v (%s)", status.AuthID, status.Healthy, want[status.AuthID], status.Error)
+		}
+		if status.Model != "probe-model" {
+			t.Fatalf("%s probed with %q, want probe-model", status.AuthID, status.Model)
+		}
+	}
+	if auth, _ := m.GetByID("b"); auth == nil || auth.ModelStates["probe-model"] == nil || !auth.ModelStates["probe-model"].Unavailable {
+		t.Fatal("failing credential was not put into cooldown")
+	}
+
+	m.probeUpstreams(context.Background(), HealthProbeConfig{Timeout: defaultHealthProbeTimeout, Models: map[string]string{"probe": "probe-model"}})
+	results, _ = m.UpstreamHealth()
+	for _, status := range results {
+		if status.AuthID == "b" && status.ConsecutiveFailures != 2 {
+			t.Fatalf("consecutive failures = %d, want 2", status.ConsecutiveFailures)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// HedgingConfig controls hedged streaming requests.
+type HedgingConfig struct {
+	// Enabled turns hedging on.
+	Enabled bool
+	// Delay is how long a stream may go without its first chunk before the same request is sent
+	// through another auth of the provider.
+	Delay time.Duration
+	// Providers limits hedging to these providers; empty hedges every provider.
+	Providers []string
+}
+
+// HedgeStats counts hedged streams since startup.
+type HedgeStats struct {
+	// Hedged counts streams that fired a second request.
+	Hedged int64 `json:"hedged"`
+	// HedgeWins counts hedged streams answered first by the second request.
+	HedgeWins int64 `json:"hedge_wins"`
+}
+
+var (
+	hedgesFired atomic.Int64
+	hedgeWins   atomic.Int64
+)
+
+// GetHedgeStats returns the hedging counters.
+func GetHedgeStats() HedgeStats {
+	return HedgeStats{Hedged: hedgesFired.Load(), HedgeWins: hedgeWins.Load()}
+}
+
+// errHedgeLost cancels the slower request of a hedged pair. Streams ended by it are not counted
+// as failures of their auth.
+var errHedgeLost = errors.New("hedged request lost to a faster upstream")
+
+// hedgeLost reports whether ctx belongs to the losing request of a hedged pair.
+func hedgeLost(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errHedgeLost)
+}
+
+// SetHedging applies the hedging settings to subsequent streams.
+func (m *Manager) SetHedging(cfg HedgingConfig) {
+	if m == nil {
+		return
+	}
+	providers := make([]string, 0, len(cfg.Providers))
+	for _, provider := range cfg.Providers {
+		if provider = strings.ToLower(strings.TrimSpace(provider)); provider != "" {
+			providers = append(providers, provider)
+		}
+	}
+	cfg.Providers = providers
+	m.hedging.Store(&cfg)
+}
+
+// hedgeDelay returns the first-chunk deadline for streams to provider, or 0 when they are not
+// hedged.
+func (m *Manager) hedgeDelay(provider string) time.Duration {
+	cfg := m.hedging.Load()
+	if cfg == nil || !cfg.Enabled || cfg.Delay <= 0 {
+		return 0
+	}
+	if len(cfg.Providers) == 0 {
+		return cfg.Delay
+	}
+	provider = strings.ToLower(provider)
+	for _, p := range cfg.Providers {
+		if p == provider {
+			return cfg.Delay
+		}
+	}
+	return 0
+}
+
+// hedgeRun is one of the requests of a hedged stream.
+type hedgeRun struct {
+	chunks <-chan cliproxyexecutor.StreamChunk
+	cancel context.CancelCauseFunc
+}
+
+// abandon cancels the run and drains its chunks so its relay goroutine can finish and release
+// its upstream slot.
+func (r *hedgeRun) abandon() {
+	r.cancel(errHedgeLost)
+	go func() {
+		for range r.chunks {
+		}
+	}()
+}
+
+// hedgedStream starts a stream and, when no chunk arrives within delay, sends the same request
+// through another auth of the provider. The stream that produces output first is relayed and
+// the other is cancelled.
+func (m *Manager) hedgedStream(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, delay time.Duration) (<-chan cliproxyexecutor.StreamChunk, error) {
+	tried := make(map[string]struct{})
+	primaryCtx, cancelPrimary := context.WithCancelCause(ctx)
+	chunks, err := m.openStream(primaryCtx, provider, req, opts, tried)
+	if err != nil {
+		cancelPrimary(nil)
+		return nil, err
+	}
+	primary := &hedgeRun{chunks: chunks, cancel: cancelPrimary}
+
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		timeout := timer.C
+
+		var secondary *hedgeRun
+		var secondaryReady chan *hedgeRun
+		var cancelSecondary context.CancelCauseFunc
+		primaryChunks, secondaryChunks := primary.chunks, (<-chan cliproxyexecutor.StreamChunk)(nil)
+		var lastErr *cliproxyexecutor.StreamChunk
+
+		// finish forwards the winner's first chunk and the rest of its stream.
+		finish := func(winner *hedgeRun, first cliproxyexecutor.StreamChunk) {
+			if winner == primary {
+				if secondary != nil {
+					secondary.abandon()
+				} else if secondaryReady != nil {
+					cancelSecondary(errHedgeLost)
+					go func(ready <-chan *hedgeRun) {
+						if run := <-ready; run != nil {
+							run.abandon()
+						}
+					}(secondaryReady)
+				}
+			} else {
+				primary.abandon()
+				hedgeWins.Add(1)
+			}
+			out <- first
+			for chunk := range winner.chunks {
+				out <- chunk
+			}
+		}
+
+		for {
+			select {
+			case <-timeout:
+				timeout = nil
+				hedgesFired.Add(1)
+				logEntryWithRequestID(ctx).Debugf("hedging %s stream after %s without a first chunk", provider, delay)
+				var secondaryCtx context.Context
+				secondaryCtx, cancelSecondary = context.WithCancelCause(ctx)
+				secondaryReady = make(chan *hedgeRun, 1)
+				go func(ready chan<- *hedgeRun, cancel context.CancelCauseFunc) {
+					// tried is only touched here once the primary has started.
+					hedged, errHedge := m.openStream(secondaryCtx, provider, req, opts, tried)
+					if errHedge != nil {
+						cancel(nil)
+						ready <- nil
+						return
+					}
+					ready <- &hedgeRun{chunks: hedged, cancel: cancel}
+				}(secondaryReady, cancelSecondary)
+			case run := <-secondaryReady:
+				secondaryReady = nil
+				if run != nil {
+					secondary = run
+					secondaryChunks = run.chunks
+				} else if primaryChunks == nil {
+					if lastErr != nil {
+						out <- *lastErr
+					}
+					return
+				}
+			case chunk, ok := <-primaryChunks:
+				switch {
+				case ok && chunk.Err == nil && len(chunk.Payload) == 0:
+					continue
+				case ok && chunk.Err == nil:
+					finish(primary, chunk)
+					return
+				case ok:
+					lastErr = &chunk
+					primary.abandon()
+				}
+				primaryChunks = nil
+				if secondaryChunks == nil && secondaryReady == nil {
+					if lastErr != nil {
+						out <- *lastErr
+					}
+					return
+				}
+			case chunk, ok := <-secondaryChunks:
+				switch {
+				case ok && chunk.Err == nil && len(chunk.Payload) == 0:
+					continue
+				case ok && chunk.Err == nil:
+					finish(secondary, chunk)
+					return
+				case ok:
+					lastErr = &chunk
+					secondary.abandon()
+				}
+				secondaryChunks = nil
+				if primaryChunks == nil {
+					if lastErr != nil {
+						out <- *lastErr
+					}
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// hedgeExecutor stalls the first stream it opens until its context ends and answers every
+// later one immediately.
+type hedgeExecutor struct {
+	mu        sync.Mutex
+	calls     int
+	stalledID string
+	cancelled chan struct{}
+}
+
+func (e *hedgeExecutor) Identifier() string { return "hedge" }
+
+func (e *hedgeExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *hedgeExecutor) ExecuteStream(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	e.mu.Lock()
+	e.calls++
+	stall := e.calls == 1
+	if stall {
+		e.stalledID = auth.ID
+	}
+	e.mu.Unlock()
+	out := make(chan cliproxyexecutor.StreamChunk, 2)
+	go func() {
+		defer close(out)
+		if stall {
+			<-ctx.Done()
+			close(e.cancelled)
+			out <- cliproxyexecutor.StreamChunk{Err: ctx.Err()}
+			return
+		}
+		out <- cliproxyexecutor.StreamChunk{Payload: []byte("from " + auth.ID)}
+		out <- cliproxyexecutor.StreamChunk{Payload: []byte("done")}
+	}()
+	return out, nil
+}
+
+func (e *hedgeExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *hedgeExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, errors.New("not implemented")
+}
+
+func TestHedgedStreamRelaysFasterRequestAndCancelsSlower(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	exec := &hedgeExecutor{cancelled: make(chan struct{})}
+	m.RegisterExecutor(exec)
+	for _, id := range []string{"a", "b"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "hedge"}); err != nil {
+			t.Fatalf("Register(%s) error = %v", id, err)
+		}
+	}
+	m.SetHedging(HedgingConfig{Enabled: true, Delay: 20 * time.Millisecond, Providers: []string{"Hedge"}})
+	before := GetHedgeStats()
+
+	chunks, err := m.ExecuteStream(context.Background(), []string{"hedge"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{Stream: true})
+	if err != nil {
+		t.Fatalf("ExecuteStream() error = %v", err)
+	}
+	var got []string
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("unexpected stream error: %v", chunk.Err)
+		}
+		got = append(got, string(chunk.Payload))
+	}
+	if len(got) != 2 || got[0] == "from "+exec.stalledID || got[1] != "done" {
+		t.Fatalf("relayed %q, want the non-stalled stream", got)
+	}
+
+	select {
+	case <-exec.cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("slower request was not cancelled")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if stalled, _ := m.GetByID(exec.stalledID); stalled.LastError != nil || stalled.Unavailable {
+		t.Fatalf("cancelled hedge counted against %s: %+v", exec.stalledID, stalled.LastError)
+	}
+	after := GetHedgeStats()
+	if after.Hedged-before.Hedged != 1 || after.HedgeWins-before.HedgeWins != 1 {
+		t.Fatalf("hedge stats = %+v, before %+v", after, before)
+	}
+}
+
+func TestHedgeDelayHonoursProviders(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	if m.hedgeDelay("claude") != 0 {
+		t.Fatal("hedging must be off by default")
+	}
+	m.SetHedging(HedgingConfig{Enabled: true, Delay: time.Second, Providers: []string{" Claude "}})
+	if m.hedgeDelay("claude") != time.Second || m.hedgeDelay("gemini") != 0 {
+		t.Fatal("hedging must apply only to the listed providers")
+	}
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const (
+	// latencyWindowSize is the number of recent requests kept per credential.
+	latencyWindowSize = 64
+	// latencyMinSamples is the number of samples required before a credential is ranked.
+	latencyMinSamples = 5
+	// latencyEnterMargin admits a credential to the fast set when its score is within this
+	// fraction of the best score.
+	latencyEnterMargin = 0.25
+	// latencyExitMargin removes a credential from the fast set once its score is more than this
+	// fraction above the best score. The gap between the margins prevents flapping.
+	latencyExitMargin = 0.5
+	// latencyExploreEvery sends every Nth pick to any available credential so the statistics of
+	// slower credentials stay current.
+	latencyExploreEvery = 20
+	// latencyReferenceBytes is the response size used to weigh throughput against first-token time.
+	latencyReferenceBytes = 4096
+)
+
+// LatencyStats summarises the recent timings of one credential.
+type LatencyStats struct {
+	AuthID   string `json:"auth_id"`
+	Provider string `json:"provider"`
+	Samples  int    `json:"samples"`
+	// TTFTP50Ms and TTFTP95Ms are the median and 95th percentile time to first token.
+	TTFTP50Ms int64 `json:"ttft_p50_ms"`
+	TTFTP95Ms int64 `json:"ttft_p95_ms"`
+	// ThroughputBps is the mean streamed bytes per second after the first token.
+	ThroughputBps float64 `json:"throughput_bps"`
+}
+
+// score estimates the seconds needed to deliver a typical response; lower is faster.
+func (s LatencyStats) score() float64 {
+	score := (float64(s.TTFTP50Ms) + 0.25*float64(s.TTFTP95Ms)) / 1000
+	if s.ThroughputBps > 0 {
+		score += latencyReferenceBytes / s.ThroughputBps
+	}
+	return score
+}
+
+type latencySample struct {
+	ttft       time.Duration
+	bytes      int
+	streamTime time.Duration
+}
+
+type latencyWindow struct {
+	provider string
+	samples  [latencyWindowSize]latencySample
+	count    int
+	next     int
+}
+
+// latencyTracker keeps rolling request timings per credential.
+type latencyTracker struct {
+	mu      sync.Mutex
+	windows map[string]*latencyWindow
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{windows: make(map[string]*latencyWindow)}
+}
+
+// defaultLatency is shared by the Manager, which records timings, and LatencySelector.
+var defaultLatency = newLatencyTracker()
+
+// observe records one successful request. streamTime is the time spent streaming after the
+// first token and is zero for non-streaming requests.
+func (t *latencyTracker) observe(authID, provider string, ttft time.Duration, bytes int, streamTime time.Duration) {
+	if authID == "" || ttft <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w := t.windows[authID]
+	if w == nil {
+		w = &latencyWindow{}
+		t.windows[authID] = w
+	}
+	w.provider = provider
+	w.samples[w.next] = latencySample{ttft: ttft, bytes: bytes, streamTime: streamTime}
+	w.next = (w.next + 1) % latencyWindowSize
+	if w.count < latencyWindowSize {
+		w.count++
+	}
+}
+
+func (t *latencyTracker) stats(authID string) (LatencyStats, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w := t.windows[authID]
+	if w == nil || w.count == 0 {
+		return LatencyStats{AuthID: authID}, false
+	}
+	return w.stats(authID), true
+}
+
+func (w *latencyWindow) stats(authID string) LatencyStats {
+	ttfts := make([]time.Duration, 0, w.count)
+	var bytes int
+	var streamTime time.Duration
+	for i := 0; i < w.count; i++ {
+		sample := w.samples[i]
+		ttfts = append(ttfts, sample.ttft)
+		if sample.streamTime > 0 {
+			bytes += sample.bytes
+			streamTime += sample.streamTime
+		}
+	}
+	sort.Slice(ttfts, func(i, j int) bool { return ttfts[i] < ttfts[j] })
+	stats := LatencyStats{
+		AuthID:    authID,
+		Provider:  w.provider,
+		Samples:   w.count,
+		TTFTP50Ms: ttfts[(len(ttfts)-1)*50/100].Milliseconds(),
+		TTFTP95Ms: ttfts[(len(ttfts)-1)*95/100].Milliseconds(),
+	}
+	if streamTime > 0 {
+		stats.ThroughputBps = float64(bytes) / streamTime.Seconds()
+	}
+	return stats
+}
+
+func (t *latencyTracker) snapshot() []LatencyStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]LatencyStats, 0, len(t.windows))
+	for id, w := range t.windows {
+		if w.count > 0 {
+			out = append(out, w.stats(id))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AuthID < out[j].AuthID })
+	return out
+}
+
+// LatencySnapshot returns the rolling timing statistics of every credential that served requests.
+func LatencySnapshot() []LatencyStats {
+	return defaultLatency.snapshot()
+}
+
+// LatencySelector biases selection toward the credentials with the lowest time to first token
+// and highest throughput. Credentials within a margin of the fastest form a fast set that is
+// served round-robin; hysteresis between entering and leaving the set avoids flapping. New
+// credentials are included until they have enough samples, and a small share of picks explores
+// the remaining credentials.
+type LatencySelector struct {
+	mu        sync.Mutex
+	fast      map[string]map[string]struct{}
+	cursors   map[string]int
+	tracker   *latencyTracker
+	exploreAt int
+}
+
+// Pick selects the next auth for the provider from the fast set.
+func (s *LatencySelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	available, err := getAvailableAuths(auths, provider, model, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(available) == 1 {
+		return available[0], nil
+	}
+	tracker := s.tracker
+	if tracker == nil {
+		tracker = defaultLatency
+	}
+	scores := make(map[string]float64, len(available))
+	best := -1.0
+	for _, candidate := range available {
+		stats, ok := tracker.stats(candidate.ID)
+		if !ok || stats.Samples < latencyMinSamples {
+			continue
+		}
+		score := stats.score()
+		scores[candidate.ID] = score
+		if best < 0 || score < best {
+			best = score
+		}
+	}
+
+	key := provider + ":" + model
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fast == nil {
+		s.fast = make(map[string]map[string]struct{})
+		s.cursors = make(map[string]int)
+	}
+	set := s.fast[key]
+	if set == nil {
+		set = make(map[string]struct{})
+		s.fast[key] = set
+	}
+	present := make(map[string]struct{}, len(available))
+	for _, candidate := range available {
+		present[candidate.ID] = struct{}{}
+		score, ranked := scores[candidate.ID]
+		if !ranked {
+			continue
+		}
+		switch {
+		case score <= best*(1+latencyEnterMargin):
+			set[candidate.ID] = struct{}{}
+		case score > best*(1+latencyExitMargin):
+			delete(set, candidate.ID)
+		}
+	}
+	for id := range set {
+		if _, ok := present[id]; !ok {
+			delete(set, id)
+		}
+	}
+
+	index := s.cursors[key]
+	if index >= 2_147_483_640 {
+		index = 0
+	}
+	s.cursors[key] = index + 1
+	exploreEvery := s.exploreAt
+	if exploreEvery <= 0 {
+		exploreEvery = latencyExploreEvery
+	}
+	if index%exploreEvery == exploreEvery-1 {
+		return available[(index/exploreEvery)%len(available)], nil
+	}
+	candidates := make([]*Auth, 0, len(available))
+	for _, candidate := range available {
+		_, ranked := scores[candidate.ID]
+		if _, fast := set[candidate.ID]; fast || !ranked {
+			candidates = append(candidates, candidate)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = available
+	}
+	return candidates[index%len(candidates)], nil
+}
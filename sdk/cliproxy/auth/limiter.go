@@ -0,0 +1,487 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultQueueTimeout bounds queue waits when a limit does not configure its own timeout.
+const defaultQueueTimeout = 30 * time.Second
+
+// Priority classifies requests for upstream queueing.
+type Priority int
+
+const (
+	// PriorityInteractive is the default class for latency-sensitive requests.
+	PriorityInteractive Priority = iota
+	// PriorityBatch marks background work that yields to interactive requests.
+	PriorityBatch
+
+	priorityClasses = int(PriorityBatch) + 1
+)
+
+// String returns the configuration name of the priority class.
+func (p Priority) String() string {
+	if p == PriorityBatch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+// ParsePriority parses "interactive" or "batch", case-insensitively.
+func ParsePriority(value string) (Priority, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "interactive":
+		return PriorityInteractive, true
+	case "batch":
+		return PriorityBatch, true
+	}
+	return PriorityInteractive, false
+}
+
+type priorityContextKey struct{}
+
+// WithPriority returns a context carrying the request priority class.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, p)
+}
+
+// LookupPriority returns the priority class carried by ctx, if any.
+func LookupPriority(ctx context.Context) (Priority, bool) {
+	if ctx == nil {
+		return PriorityInteractive, false
+	}
+	p, ok := ctx.Value(priorityContextKey{}).(Priority)
+	return p, ok
+}
+
+// PriorityFromContext returns the request priority class, defaulting to interactive.
+func PriorityFromContext(ctx context.Context) Priority {
+	p, _ := LookupPriority(ctx)
+	return p
+}
+
+type queueKeyContextKey struct{}
+
+// WithQueueKey returns a context carrying the key fair queuing schedules the request under,
+// usually the client API key.
+func WithQueueKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, queueKeyContextKey{}, key)
+}
+
+// QueueKeyFromContext returns the key set by WithQueueKey, if any.
+func QueueKeyFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	key, _ := ctx.Value(queueKeyContextKey{}).(string)
+	return key
+}
+
+// ConcurrencyLimit caps the requests in flight to one upstream provider.
+type ConcurrencyLimit struct {
+	// MaxInFlight is the maximum number of concurrent upstream requests. Zero disables the limit.
+	MaxInFlight int
+	// QueueSize bounds the number of requests waiting for a slot.
+	QueueSize int
+	// QueueTimeout bounds how long a request waits for a slot.
+	QueueTimeout time.Duration
+	// Fair serves queued requests of a priority class by weighted fair queuing across queue keys
+	// instead of in arrival order.
+	Fair bool
+	// Weights gives queue keys a larger share of the freed slots; keys without an entry weigh 1.
+	Weights map[string]int
+}
+
+// QueueStats reports the state of one upstream limiter.
+type QueueStats struct {
+	Provider    string `json:"provider"`
+	InFlight    int    `json:"in_flight"`
+	Queued      int    `json:"queued"`
+	QueuedBatch int    `json:"queued_batch"`
+	MaxInFlight int    `json:"max_in_flight"`
+	QueueSize   int    `json:"queue_size"`
+	Rejected    uint64 `json:"rejected"`
+	TimedOut    uint64 `json:"timed_out"`
+	Preempted   uint64 `json:"preempted"`
+}
+
+// upstreamLimiter is a counting semaphore with a bounded wait queue per priority class. Released
+// slots are handed directly to a waiter of the most urgent class, so interactive requests
+// overtake queued batch work. Within a class waiters are served in arrival order or, with fair
+// queuing, by the smallest virtual finish time so every queue key gets its weighted share.
+type upstreamLimiter struct {
+	mu        sync.Mutex
+	limit     ConcurrencyLimit
+	inFlight  int
+	waiters   [priorityClasses]*list.List
+	rejected  uint64
+	timedOut  uint64
+	preempted uint64
+	// avgHold is a moving average of slot hold times used to estimate Retry-After.
+	avgHold time.Duration
+	// virtual is the fair queuing clock: the finish time of the last waiter served.
+	virtual float64
+	// lastFinish is the finish time of the latest waiter queued under each key.
+	lastFinish map[string]float64
+}
+
+type limiterWaiter struct {
+	ready   chan struct{}
+	granted bool
+	// err is set instead of granted when the waiter is evicted from the queue.
+	err error
+	// finish is the waiter's virtual finish time under fair queuing.
+	finish float64
+}
+
+func newUpstreamLimiter(limit ConcurrencyLimit) *upstreamLimiter {
+	l := &upstreamLimiter{limit: limit}
+	for i := range l.waiters {
+		l.waiters[i] = list.New()
+	}
+	return l
+}
+
+func (l *upstreamLimiter) queuedLocked() int {
+	n := 0
+	for _, q := range l.waiters {
+		n += q.Len()
+	}
+	return n
+}
+
+// nextWaiterLocked removes and returns the next waiter of the most urgent class: the oldest one,
+// or under fair queuing the one with the smallest finish time.
+func (l *upstreamLimiter) nextWaiterLocked() *limiterWaiter {
+	for _, q := range l.waiters {
+		next := q.Front()
+		if next == nil {
+			continue
+		}
+		if l.limit.Fair {
+			for e := next.Next(); e != nil; e = e.Next() {
+				if e.Value.(*limiterWaiter).finish < next.Value.(*limiterWaiter).finish {
+					next = e
+				}
+			}
+		}
+		w := q.Remove(next).(*limiterWaiter)
+		if l.limit.Fair {
+			l.virtual = math.Max(l.virtual, w.finish)
+			l.pruneFinishLocked()
+		}
+		return w
+	}
+	return nil
+}
+
+// finishLocked assigns the virtual finish time of a new waiter queued under key. Each request of
+// a key advances that key's clock by 1/weight, so a key with weight 2 is served twice as often
+// as a key with weight 1 while both have requests queued.
+func (l *upstreamLimiter) finishLocked(key string) float64 {
+	weight := l.limit.Weights[key]
+	if weight <= 0 {
+		weight = 1
+	}
+	if l.lastFinish == nil {
+		l.lastFinish = make(map[string]float64)
+	}
+	finish := math.Max(l.virtual, l.lastFinish[key]) + 1/float64(weight)
+	l.lastFinish[key] = finish
+	return finish
+}
+
+// pruneFinishLocked forgets keys whose queued requests have all been served.
+func (l *upstreamLimiter) pruneFinishLocked() {
+	if len(l.lastFinish) <= 64 {
+		return
+	}
+	for key, finish := range l.lastFinish {
+		if finish <= l.virtual {
+			delete(l.lastFinish, key)
+		}
+	}
+}
+
+// acquire blocks until a slot is available, the queue wait times out or ctx is done. The returned
+// release function must be called exactly once when the upstream request finishes.
+func (l *upstreamLimiter) acquire(ctx context.Context, provider string, priority Priority, key string) (func(), error) {
+	if int(priority) < 0 || int(priority) >= priorityClasses {
+		priority = PriorityInteractive
+	}
+	l.mu.Lock()
+	if l.inFlight < l.limit.MaxInFlight && l.queuedLocked() == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return l.releaser(time.Now()), nil
+	}
+	if l.queuedLocked() >= l.limit.QueueSize && !l.preemptLocked(provider, priority) {
+		l.rejected++
+		retryAfter := l.retryAfterLocked()
+		l.mu.Unlock()
+		return nil, &queueFullError{provider: provider, retryAfter: retryAfter}
+	}
+	w := &limiterWaiter{ready: make(chan struct{})}
+	if l.limit.Fair {
+		w.finish = l.finishLocked(key)
+	}
+	queue := l.waiters[priority]
+	elem := queue.PushBack(w)
+	timeout := l.limit.QueueTimeout
+	l.mu.Unlock()
+
+	if timeout <= 0 {
+		timeout = defaultQueueTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var errWait error
+	select {
+	case <-w.ready:
+		if w.err != nil {
+			return nil, w.err
+		}
+		return l.releaser(time.Now()), nil
+	case <-timer.C:
+		errWait = &queueFullError{provider: provider, timedOut: true}
+	case <-ctx.Done():
+		errWait = ctx.Err()
+	}
+
+	l.mu.Lock()
+	if w.granted {
+		// The slot was handed over while we were giving up; pass it on.
+		l.mu.Unlock()
+		l.releaser(time.Now())()
+		return nil, errWait
+	}
+	if w.err != nil {
+		l.mu.Unlock()
+		return nil, w.err
+	}
+	queue.Remove(elem)
+	if qe, ok := errWait.(*queueFullError); ok {
+		l.timedOut++
+		qe.retryAfter = l.retryAfterLocked()
+	}
+	l.mu.Unlock()
+	return nil, errWait
+}
+
+func (l *upstreamLimiter) releaser(start time.Time) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			held := time.Since(start)
+			if l.avgHold == 0 {
+				l.avgHold = held
+			} else {
+				l.avgHold = (l.avgHold*7 + held) / 8
+			}
+			if l.inFlight <= l.limit.MaxInFlight {
+				if w := l.nextWaiterLocked(); w != nil {
+					w.granted = true
+					close(w.ready)
+					return
+				}
+			}
+			l.inFlight--
+		})
+	}
+}
+
+// setLimit applies a new limit, admitting queued requests when capacity grows.
+func (l *upstreamLimiter) setLimit(limit ConcurrencyLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	for l.inFlight < l.limit.MaxInFlight {
+		w := l.nextWaiterLocked()
+		if w == nil {
+			break
+		}
+		w.granted = true
+		close(w.ready)
+		l.inFlight++
+	}
+}
+
+// preemptLocked makes room in a full queue for a request of the given priority by evicting the
+// most recently queued request of a less urgent class. It reports whether a waiter was evicted.
+func (l *upstreamLimiter) preemptLocked(provider string, priority Priority) bool {
+	for class := priorityClasses - 1; class > int(priority); class-- {
+		back := l.waiters[class].Back()
+		if back == nil {
+			continue
+		}
+		w := l.waiters[class].Remove(back).(*limiterWaiter)
+		l.preempted++
+		w.err = &queueFullError{provider: provider, preempted: true, retryAfter: l.retryAfterLocked()}
+		close(w.ready)
+		return true
+	}
+	return false
+}
+
+// retryAfterLocked estimates how long until the queue drains, from the average slot hold time.
+func (l *upstreamLimiter) retryAfterLocked() time.Duration {
+	hold := l.avgHold
+	if hold <= 0 {
+		hold = time.Second
+	}
+	slots := l.limit.MaxInFlight
+	if slots < 1 {
+		slots = 1
+	}
+	return hold * time.Duration(l.queuedLocked()+1) / time.Duration(slots)
+}
+
+func (l *upstreamLimiter) stats(provider string) QueueStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return QueueStats{
+		Provider:    provider,
+		InFlight:    l.inFlight,
+		Queued:      l.queuedLocked(),
+		QueuedBatch: l.waiters[PriorityBatch].Len(),
+		MaxInFlight: l.limit.MaxInFlight,
+		QueueSize:   l.limit.QueueSize,
+		Rejected:    l.rejected,
+		TimedOut:    l.timedOut,
+		Preempted:   l.preempted,
+	}
+}
+
+// queueFullError is returned when an upstream queue is full or a queued request times out.
+type queueFullError struct {
+	provider   string
+	timedOut   bool
+	preempted  bool
+	retryAfter time.Duration
+}
+
+func (e *queueFullError) Error() string {
+	message := fmt.Sprintf("Upstream %s is at capacity; request queue is full", e.provider)
+	code := "upstream_queue_full"
+	switch {
+	case e.timedOut:
+		message = fmt.Sprintf("Upstream %s is at capacity; timed out waiting in the request queue", e.provider)
+		code = "upstream_queue_timeout"
+	case e.preempted:
+		message = fmt.Sprintf("Upstream %s is at capacity; batch request was preempted by interactive traffic", e.provider)
+		code = "upstream_queue_preempted"
+	}
+	data, err := json.Marshal(map[string]any{"error": map[string]any{"code": code, "message": message, "provider": e.provider}})
+	if err != nil {
+		return message
+	}
+	return string(data)
+}
+
+func (e *queueFullError) StatusCode() int { return http.StatusTooManyRequests }
+
+func (e *queueFullError) Headers() http.Header {
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/json")
+	seconds := int(math.Ceil(e.retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	headers.Set("Retry-After", strconv.Itoa(seconds))
+	return headers
+}
+
+// SetConcurrencyLimits replaces the per-provider concurrency limits. The "*" key applies to every
+// provider without its own entry. Requests already queued keep their place.
+func (m *Manager) SetConcurrencyLimits(limits map[string]ConcurrencyLimit) {
+	if m == nil {
+		return
+	}
+	normalized := make(map[string]ConcurrencyLimit, len(limits))
+	for provider, limit := range limits {
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		if provider == "" || limit.MaxInFlight <= 0 {
+			continue
+		}
+		if limit.QueueSize < 0 {
+			limit.QueueSize = 0
+		}
+		normalized[provider] = limit
+	}
+	m.limiterMu.Lock()
+	defer m.limiterMu.Unlock()
+	m.concurrencyLimits = normalized
+	for provider, limiter := range m.limiters {
+		limit, ok := m.limitForLocked(provider)
+		if !ok {
+			// Unlimited now: lift the cap so waiters drain, then forget the limiter.
+			limiter.setLimit(ConcurrencyLimit{MaxInFlight: math.MaxInt32})
+			delete(m.limiters, provider)
+			continue
+		}
+		limiter.setLimit(limit)
+	}
+}
+
+// QueueStats reports in-flight and queued request counts for every limited upstream.
+func (m *Manager) QueueStats() []QueueStats {
+	if m == nil {
+		return nil
+	}
+	m.limiterMu.Lock()
+	providers := make([]string, 0, len(m.limiters))
+	limiters := make(map[string]*upstreamLimiter, len(m.limiters))
+	for provider, limiter := range m.limiters {
+		providers = append(providers, provider)
+		limiters[provider] = limiter
+	}
+	m.limiterMu.Unlock()
+	sort.Strings(providers)
+	out := make([]QueueStats, 0, len(providers))
+	for _, provider := range providers {
+		out = append(out, limiters[provider].stats(provider))
+	}
+	return out
+}
+
+func (m *Manager) limitForLocked(provider string) (ConcurrencyLimit, bool) {
+	if limit, ok := m.concurrencyLimits[provider]; ok {
+		return limit, true
+	}
+	limit, ok := m.concurrencyLimits["*"]
+	return limit, ok
+}
+
+// acquireUpstream reserves a concurrency slot for provider, queueing by the priority class and
+// queue key carried in ctx. Unlimited providers get a no-op release.
+func (m *Manager) acquireUpstream(ctx context.Context, provider string) (func(), error) {
+	key := strings.ToLower(strings.TrimSpace(provider))
+	m.limiterMu.Lock()
+	limiter := m.limiters[key]
+	if limiter == nil {
+		limit, ok := m.limitForLocked(key)
+		if !ok {
+			m.limiterMu.Unlock()
+			return func() {}, nil
+		}
+		if m.limiters == nil {
+			m.limiters = make(map[string]*upstreamLimiter)
+		}
+		limiter = newUpstreamLimiter(limit)
+		m.limiters[key] = limiter
+	}
+	m.limiterMu.Unlock()
+	return limiter.acquire(ctx, provider, PriorityFromContext(ctx), QueueKeyFromContext(ctx))
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpstreamLimiterQueuesInOrder(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.SetConcurrencyLimits(map[string]ConcurrencyLimit{"claude": {MaxInFlight: 1, QueueSize: 1, QueueTimeout: time.Second}})
+	ctx := context.Background()
+
+	release, err := m.acquireUpstream(ctx, "claude")
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	granted := make(chan func(), 1)
+	go func() {
+		next, errNext := m.acquireUpstream(ctx, "claude")
+		if errNext != nil {
+			t.Errorf("queued acquire: %v", errNext)
+			close(granted)
+			return
+		}
+		granted <- next
+	}()
+	waitFor(t, func() bool { return m.QueueStats()[0].Queued == 1 })
+
+	_, err = m.acquireUpstream(ctx, "claude")
+	var qe *queueFullError
+	if !errors.As(err, &qe) || qe.StatusCode() != http.StatusTooManyRequests || qe.Headers().Get("Retry-After") == "" {
+		t.Fatalf("expected queue full error with Retry-After, got %v", err)
+	}
+
+	release()
+	next := <-granted
+	if stats := m.QueueStats()[0]; stats.InFlight != 1 || stats.Queued != 0 || stats.Rejected != 1 {
+		t.Fatalf("unexpected stats after hand-over: %+v", stats)
+	}
+	next()
+	if stats := m.QueueStats()[0]; stats.InFlight != 0 {
+		t.Fatalf("slot leaked: %+v", stats)
+	}
+
+	if _, err = m.acquireUpstream(ctx, "gemini"); err != nil {
+		t.Fatalf("unlimited provider must not be limited: %v", err)
+	}
+}
+
+func TestUpstreamLimiterQueueTimeout(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.SetConcurrencyLimits(map[string]ConcurrencyLimit{"*": {MaxInFlight: 1, QueueSize: 4, QueueTimeout: 20 * time.Millisecond}})
+	release, err := m.acquireUpstream(context.Background(), "codex")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+	_, err = m.acquireUpstream(context.Background(), "codex")
+	var qe *queueFullError
+	if !errors.As(err, &qe) || !qe.timedOut {
+		t.Fatalf("expected queue timeout, got %v", err)
+	}
+	if stats := m.QueueStats()[0]; stats.Queued != 0 || stats.TimedOut != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestUpstreamLimiterFairQueuingSharesSlotsAcrossKeys(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.SetConcurrencyLimits(map[string]ConcurrencyLimit{"claude": {MaxInFlight: 1, QueueSize: 16, QueueTimeout: time.Second, Fair: true, Weights: map[string]int{"vip": 2}}})
+
+	release, err := m.acquireUpstream(context.Background(), "claude")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	served := make(chan string, 16)
+	enqueue := func(key string) {
+		queued := m.QueueStats()[0].Queued
+		go func() {
+			next, errNext := m.acquireUpstream(WithQueueKey(context.Background(), key), "claude")
+			if errNext != nil {
+				t.Errorf("acquire %s: %v", key, errNext)
+				served <- ""
+				return
+			}
+			served <- key
+			next()
+		}()
+		waitFor(t, func() bool { return m.QueueStats()[0].Queued == queued+1 })
+	}
+	for i := 0; i < 4; i++ {
+		enqueue("hog")
+	}
+	enqueue("quiet")
+	enqueue("vip")
+	enqueue("vip")
+
+	release()
+	var order []string
+	for range 7 {
+		order = append(order, <-served)
+	}
+	// Finish times: hog 1,2,3,4; quiet 1; vip 0.5,1. Ties go to the earlier arrival.
+	want := []string{"vip", "hog", "quiet", "vip", "hog", "hog", "hog"}
+	if strings.Join(order, ",") != strings.Join(want, ",") {
+		t.Fatalf("served %v, want %v", order, want)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUpstreamLimiterPrefersInteractive(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.SetConcurrencyLimits(map[string]ConcurrencyLimit{"claude": {MaxInFlight: 1, QueueSize: 2, QueueTimeout: time.Second}})
+	ctx := context.Background()
+	batchCtx := WithPriority(ctx, PriorityBatch)
+
+	release, err := m.acquireUpstream(ctx, "claude")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	type outcome struct {
+		name    string
+		release func()
+		err     error
+	}
+	results := make(chan outcome, 3)
+	enqueue := func(name string, c context.Context) {
+		go func() {
+			r, errAcquire := m.acquireUpstream(c, "claude")
+			results <- outcome{name, r, errAcquire}
+		}()
+	}
+	enqueue("batch-1", batchCtx)
+	waitFor(t, func() bool { return m.QueueStats()[0].Queued == 1 })
+	enqueue("batch-2", batchCtx)
+	waitFor(t, func() bool { return m.QueueStats()[0].Queued == 2 })
+
+	// The queue is full: an interactive request evicts the newest batch request.
+	enqueue("interactive", ctx)
+	evicted := <-results
+	var qe *queueFullError
+	if evicted.name != "batch-2" || !errors.As(evicted.err, &qe) || !qe.preempted {
+		t.Fatalf("expected batch-2 to be preempted, got %s: %v", evicted.name, evicted.err)
+	}
+	waitFor(t, func() bool { return m.QueueStats()[0].Queued == 2 })
+
+	// A batch request cannot evict anything.
+	if _, err = m.acquireUpstream(batchCtx, "claude"); !errors.As(err, &qe) || qe.preempted {
+		t.Fatalf("expected batch request to be rejected, got %v", err)
+	}
+
+	// Released slots go to the interactive request even though batch-1 queued first.
+	release()
+	first := <-results
+	if first.name != "interactive" || first.err != nil {
+		t.Fatalf("expected interactive request to be served first, got %s: %v", first.name, first.err)
+	}
+	first.release()
+	second := <-results
+	if second.name != "batch-1" || second.err != nil {
+		t.Fatalf("expected batch-1 next, got %s: %v", second.name, second.err)
+	}
+	second.release()
+	if stats := m.QueueStats()[0]; stats.InFlight != 0 || stats.Preempted != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
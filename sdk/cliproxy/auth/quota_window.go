@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultQuotaReserve is the share of a usage window kept in reserve when none is configured.
+	defaultQuotaReserve = 0.1
+	// quotaWindowStaleAfter discards windows without a reset time that have not been refreshed
+	// for this long.
+	quotaWindowStaleAfter = time.Hour
+)
+
+// QuotaWindow is the consumption of one rolling usage window reported by a subscription upstream,
+// such as the Claude five-hour window or the Codex weekly limit.
+type QuotaWindow struct {
+	// Name identifies the window within the provider, for example "5h", "7d" or "primary".
+	Name string `json:"name"`
+	// Used is the consumed share of the window between 0 and 1.
+	Used float64 `json:"used"`
+	// WindowMinutes is the window length when the upstream reports it.
+	WindowMinutes int `json:"window_minutes,omitempty"`
+	// ResetsAt is when the window resets.
+	ResetsAt time.Time `json:"resets_at,omitzero"`
+}
+
+// QuotaWindowStatus lists the usage windows last reported for a credential.
+type QuotaWindowStatus struct {
+	AuthID    string        `json:"auth_id"`
+	Provider  string        `json:"provider"`
+	Windows   []QuotaWindow `json:"windows"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	// Reserved is set while a window is within the reserve and selection prefers other credentials.
+	Reserved bool `json:"reserved"`
+}
+
+// QuotaReserveConfig controls pre-emptive rotation away from credentials close to their limits.
+type QuotaReserveConfig struct {
+	// Enabled turns rotation on. Windows are tracked either way.
+	Enabled bool
+	// Reserve is the share of every window kept unused, between 0 and 1.
+	Reserve float64
+	// Providers overrides Reserve per provider.
+	Providers map[string]float64
+}
+
+// quotaWindowTracker keeps the latest usage windows per credential.
+type quotaWindowTracker struct {
+	mu      sync.RWMutex
+	cfg     QuotaReserveConfig
+	entries map[string]*QuotaWindowStatus
+}
+
+func newQuotaWindowTracker() *quotaWindowTracker {
+	return &quotaWindowTracker{entries: make(map[string]*QuotaWindowStatus)}
+}
+
+// defaultQuotaWindows is fed by the executors and consulted by every selector.
+var defaultQuotaWindows = newQuotaWindowTracker()
+
+// SetQuotaReserve configures pre-emptive rotation globally.
+func SetQuotaReserve(cfg QuotaReserveConfig) {
+	defaultQuotaWindows.configure(cfg)
+}
+
+// RecordQuotaWindows stores the usage windows an upstream reported for a credential. Windows not
+// listed keep their previous values.
+func RecordQuotaWindows(authID, provider string, windows []QuotaWindow) {
+	defaultQuotaWindows.record(authID, provider, windows, time.Now())
+}
+
+// QuotaWindowSnapshot returns the usage windows of every credential that reported them.
+func QuotaWindowSnapshot() []QuotaWindowStatus {
+	return defaultQuotaWindows.snapshot(time.Now())
+}
+
+func (t *quotaWindowTracker) configure(cfg QuotaReserveConfig) {
+	if cfg.Reserve <= 0 || cfg.Reserve >= 1 {
+		cfg.Reserve = defaultQuotaReserve
+	}
+	providers := make(map[string]float64, len(cfg.Providers))
+	for provider, reserve := range cfg.Providers {
+		if reserve > 0 && reserve < 1 {
+			providers[strings.ToLower(strings.TrimSpace(provider))] = reserve
+		}
+	}
+	cfg.Providers = providers
+	t.mu.Lock()
+	t.cfg = cfg
+	t.mu.Unlock()
+}
+
+func (t *quotaWindowTracker) record(authID, provider string, windows []QuotaWindow, now time.Time) {
+	if authID == "" || len(windows) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry := t.entries[authID]
+	if entry == nil {
+		entry = &QuotaWindowStatus{AuthID: authID}
+		t.entries[authID] = entry
+	}
+	entry.Provider = provider
+	entry.UpdatedAt = now
+	for _, window := range windows {
+		replaced := false
+		for i := range entry.Windows {
+			if entry.Windows[i].Name == window.Name {
+				entry.Windows[i] = window
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			entry.Windows = append(entry.Windows, window)
+		}
+	}
+	sort.Slice(entry.Windows, func(i, j int) bool { return entry.Windows[i].Name < entry.Windows[j].Name })
+}
+
+// reserveFor returns the reserve of provider. Callers hold t.mu.
+func (t *quotaWindowTracker) reserveFor(provider string) float64 {
+	if reserve, ok := t.cfg.Providers[strings.ToLower(provider)]; ok {
+		return reserve
+	}
+	return t.cfg.Reserve
+}
+
+// reserved reports whether a current window of entry is within the reserve. Callers hold t.mu.
+func (t *quotaWindowTracker) reserved(entry *QuotaWindowStatus, now time.Time) bool {
+	if entry == nil {
+		return false
+	}
+	limit := 1 - t.reserveFor(entry.Provider)
+	for _, window := range entry.Windows {
+		if window.ResetsAt.IsZero() {
+			if now.Sub(entry.UpdatedAt) > quotaWindowStaleAfter {
+				continue
+			}
+		} else if !window.ResetsAt.After(now) {
+			continue
+		}
+		if window.Used >= limit {
+			return true
+		}
+	}
+	return false
+}
+
+// preferHeadroom drops credentials within their reserve from available, unless that would leave
+// none; the upstream then decides when the limit is reached.
+func (t *quotaWindowTracker) preferHeadroom(available []*Auth, now time.Time) []*Auth {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.cfg.Enabled || len(t.entries) == 0 {
+		return available
+	}
+	kept := make([]*Auth, 0, len(available))
+	for _, auth := range available {
+		if !t.reserved(t.entries[auth.ID], now) {
+			kept = append(kept, auth)
+		}
+	}
+	if len(kept) == 0 || len(kept) == len(available) {
+		return available
+	}
+	return kept
+}
+
+func (t *quotaWindowTracker) snapshot(now time.Time) []QuotaWindowStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make([]QuotaWindowStatus, 0, len(t.entries))
+	for _, entry := range t.entries {
+		status := *entry
+		status.Windows = append([]QuotaWindow(nil), entry.Windows...)
+		status.Reserved = t.cfg.Enabled && t.reserved(entry, now)
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AuthID < out[j].AuthID })
+	return out
+}
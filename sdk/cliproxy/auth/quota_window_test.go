@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuotaWindowsPreferHeadroom(t *testing.T) {
+	now := time.Now()
+	tracker := newQuotaWindowTracker()
+	tracker.configure(QuotaReserveConfig{Enabled: true, Reserve: 0.1, Providers: map[string]float64{"codex": 0.02}})
+	tracker.record("near", "claude", []QuotaWindow{{Name: "5h", Used: 0.95, ResetsAt: now.Add(time.Hour)}}, now)
+	tracker.record("fresh", "claude", []QuotaWindow{{Name: "5h", Used: 0.3, ResetsAt: now.Add(time.Hour)}}, now)
+	tracker.record("reset", "claude", []QuotaWindow{{Name: "5h", Used: 0.99, ResetsAt: now.Add(-time.Minute)}}, now)
+	tracker.record("codex", "codex", []QuotaWindow{{Name: "secondary", Used: 0.95}}, now)
+
+	auths := []*Auth{{ID: "codex"}, {ID: "fresh"}, {ID: "near"}, {ID: "reset"}, {ID: "unknown"}}
+	got := tracker.preferHeadroom(auths, now)
+	var ids []string
+	for _, auth := range got {
+		ids = append(ids, auth.ID)
+	}
+	if want := "codex fresh reset unknown"; strings.Join(ids, " ") != want {
+		t.Fatalf("preferHeadroom() = %q, want %q", strings.Join(ids, " "), want)
+	}
+
+	// With every credential in reserve selection falls back to the full list.
+	if got := tracker.preferHeadroom([]*Auth{{ID: "near"}}, now); len(got) != 1 {
+		t.Fatalf("preferHeadroom() with no headroom = %d credentials, want 1", len(got))
+	}
+
+	// Windows without a reset time expire once stale.
+	if got := tracker.preferHeadroom([]*Auth{{ID: "codex"}, {ID: "near"}}, now.Add(2*time.Hour)); len(got) != 2 {
+		t.Fatalf("preferHeadroom() after the windows passed = %d credentials, want 2", len(got))
+	}
+
+	tracker.configure(QuotaReserveConfig{})
+	if got := tracker.preferHeadroom(auths, now); len(got) != len(auths) {
+		t.Fatalf("preferHeadroom() disabled = %d credentials, want %d", len(got), len(auths))
+	}
+}
+
+func TestQuotaWindowsRecordMergesWindows(t *testing.T) {
+	now := time.Now()
+	tracker := newQuotaWindowTracker()
+	tracker.record("a", "codex", []QuotaWindow{{Name: "primary", Used: 0.1}, {Name: "secondary", Used: 0.4}}, now)
+	tracker.record("a", "codex", []QuotaWindow{{Name: "primary", Used: 0.2}}, now)
+
+	snapshot := tracker.snapshot(now)
+	if len(snapshot) != 1 || len(snapshot[0].Windows) != 2 {
+		t.Fatalf("snapshot() = %+v, want one credential with two windows", snapshot)
+	}
+	if snapshot[0].Windows[0].Used != 0.2 || snapshot[0].Windows[1].Used != 0.4 {
+		t.Fatalf("windows = %+v, want primary updated and secondary kept", snapshot[0].Windows)
+	}
+}
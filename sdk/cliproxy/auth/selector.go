@@ -142,7 +142,7 @@ func getAvailableAuths(auths []*Auth, provider, model string, now time.Time) ([]
 		return nil, &Error{Code: "auth_unavailable", Message: "no auth available"}
 	}
 
-	return available, nil
+	return defaultQuotaWindows.preferHeadroom(available, now), nil
 }
 
 // Pick selects the next available auth for the provider in a round-robin manner.
@@ -190,6 +190,9 @@ func isAuthBlockedForModel(auth *Auth, model string, now time.Time) (bool, block
 	if auth.Disabled || auth.Status == StatusDisabled {
 		return true, blockReasonDisabled, time.Time{}
 	}
+	if auth.Health.Unhealthy {
+		return true, blockReasonOther, auth.Health.NextCheckAt
+	}
 	if model != "" {
 		if len(auth.ModelStates) > 0 {
 			if state, ok := auth.ModelStates[model]; ok && state != nil {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 )
@@ -111,3 +112,80 @@ func TestRoundRobinSelectorPick_Concurrent(t *testing.T) {
 	default:
 	}
 }
+
+func TestLatencySelectorPrefersFastCredentials(t *testing.T) {
+	t.Parallel()
+
+	tracker := newLatencyTracker()
+	for i := 0; i < latencyMinSamples; i++ {
+		tracker.observe("fast", "claude", 200*time.Millisecond, 0, 0)
+		tracker.observe("slow", "claude", 2*time.Second, 0, 0)
+	}
+	selector := &LatencySelector{tracker: tracker, exploreAt: 1 << 30}
+	auths := []*Auth{{ID: "slow"}, {ID: "fast"}, {ID: "new"}}
+
+	counts := map[string]int{}
+	for i := 0; i < 10; i++ {
+		got, err := selector.Pick(context.Background(), "claude", "m", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() error = %v", err)
+		}
+		counts[got.ID]++
+	}
+	if counts["slow"] != 0 {
+		t.Fatalf("slow credential picked %d times", counts["slow"])
+	}
+	if counts["fast"] == 0 || counts["new"] == 0 {
+		t.Fatalf("expected fast and unsampled credentials to share traffic, got %v", counts)
+	}
+
+	// Hysteresis: a credential 40% slower than the best stays in the fast set once admitted but
+	// is not admitted while outside the entry margin.
+	for i := 0; i < latencyWindowSize; i++ {
+		tracker.observe("slow", "claude", 280*time.Millisecond, 0, 0)
+	}
+	if _, err := selector.Pick(context.Background(), "claude", "m", cliproxyexecutor.Options{}, auths); err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if _, ok := selector.fast["claude:m"]["slow"]; ok {
+		t.Fatal("credential outside the entry margin must not join the fast set")
+	}
+}
+
+func TestSessionAffinityKeepsConversationOnCredential(t *testing.T) {
+	m := NewManager(nil, &RoundRobinSelector{}, nil)
+	m.RegisterExecutor(&probeExecutor{})
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "probe"}); err != nil {
+			t.Fatalf("Register(%s) error = %v", id, err)
+		}
+	}
+	ctx := WithSessionAffinity(context.Background(), "conversation", time.Minute)
+	first, _, err := m.pickNext(ctx, "probe", "", cliproxyexecutor.Options{}, map[string]struct{}{})
+	if err != nil {
+		t.Fatalf("pickNext() error = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		// Interleave unrelated traffic to advance the round-robin cursor.
+		if _, _, err = m.pickNext(context.Background(), "probe", "", cliproxyexecutor.Options{}, map[string]struct{}{}); err != nil {
+			t.Fatalf("pickNext() error = %v", err)
+		}
+		got, _, errPick := m.pickNext(ctx, "probe", "", cliproxyexecutor.Options{}, map[string]struct{}{})
+		if errPick != nil {
+			t.Fatalf("pickNext() error = %v", errPick)
+		}
+		if got.ID != first.ID {
+			t.Fatalf("turn %d served by %s, want %s", i, got.ID, first.ID)
+		}
+	}
+
+	// A failed credential is skipped and the conversation rebinds to the replacement.
+	second, _, err := m.pickNext(ctx, "probe", "", cliproxyexecutor.Options{}, map[string]struct{}{first.ID: {}})
+	if err != nil || second.ID == first.ID {
+		t.Fatalf("pickNext() after failure = %v, %v", second, err)
+	}
+	got, _, err := m.pickNext(ctx, "probe", "", cliproxyexecutor.Options{}, map[string]struct{}{})
+	if err != nil || got.ID != second.ID {
+		t.Fatalf("pickNext() after rebind = %v, %v; want %s", got, err, second.ID)
+	}
+}
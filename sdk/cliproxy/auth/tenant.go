@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// TenantCredentials describes which upstream credentials a tenant owns.
+type TenantCredentials struct {
+	// Name identifies the tenant.
+	Name string
+	// Patterns match credential IDs or file names as wildcards, or credential prefixes exactly.
+	Patterns []string
+	// Shared lets the tenant also use credentials that no tenant owns.
+	Shared bool
+}
+
+var tenantCredentials atomic.Pointer[[]TenantCredentials]
+
+// SetTenants replaces the tenant credential ownership used during selection.
+// Credentials owned by a tenant are only selected for requests of that tenant, and requests
+// without a tenant only use credentials no tenant owns.
+func SetTenants(tenants []TenantCredentials) {
+	if len(tenants) == 0 {
+		tenantCredentials.Store(nil)
+		return
+	}
+	cloned := make([]TenantCredentials, len(tenants))
+	copy(cloned, tenants)
+	tenantCredentials.Store(&cloned)
+}
+
+type tenantContextKey struct{}
+
+// WithTenant returns a context that restricts auth selection to credentials available to the tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, if any.
+func TenantFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// CredentialTenant returns the tenant owning the auth, or "" when it is shared.
+func CredentialTenant(auth *Auth) string {
+	if auth == nil {
+		return ""
+	}
+	tenants := tenantCredentials.Load()
+	if tenants == nil {
+		return ""
+	}
+	if declared := strings.TrimSpace(auth.Attributes["tenant"]); declared != "" {
+		return declared
+	}
+	for _, tenant := range *tenants {
+		if credentialMatches(auth, tenant.Patterns) {
+			return tenant.Name
+		}
+	}
+	return ""
+}
+
+// credentialMatches reports whether the auth's ID or file name matches one of the wildcard
+// patterns, or its prefix equals one of them.
+func credentialMatches(auth *Auth, patterns []string) bool {
+	fileName := ""
+	if p := auth.Attributes["path"]; p != "" {
+		fileName = filepath.Base(p)
+	}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if auth.Prefix != "" && pattern == auth.Prefix {
+			return true
+		}
+		if util.MatchWildcard(pattern, auth.ID) {
+			return true
+		}
+		if fileName != "" && util.MatchWildcard(pattern, fileName) {
+			return true
+		}
+	}
+	return false
+}
+
+// TenantAllows reports whether requests of tenant may use the auth. Requests without a tenant
+// may only use credentials that no tenant owns.
+func TenantAllows(tenant string, auth *Auth) bool {
+	tenants := tenantCredentials.Load()
+	if tenants == nil {
+		return true
+	}
+	owner := CredentialTenant(auth)
+	if owner == tenant {
+		return true
+	}
+	if owner != "" || tenant == "" {
+		return false
+	}
+	for _, t := range *tenants {
+		if t.Name == tenant {
+			return t.Shared
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestTenantScopesCredentialSelection(t *testing.T) {
+	SetTenants([]TenantCredentials{
+		{Name: "a", Patterns: []string{"a-*.json"}},
+		{Name: "b", Patterns: []string{"team-b"}, Shared: true},
+	})
+	t.Cleanup(func() { SetTenants(nil) })
+
+	m := NewManager(nil, &RoundRobinSelector{}, nil)
+	m.RegisterExecutor(&probeExecutor{})
+	for _, auth := range []*Auth{
+		{ID: "a-1.json", Provider: "probe"},
+		{ID: "prefixed", Provider: "probe", Prefix: "team-b"},
+		{ID: "declared", Provider: "probe", Attributes: map[string]string{"tenant": "c"}},
+		{ID: "shared", Provider: "probe"},
+	} {
+		if _, err := m.Register(context.Background(), auth); err != nil {
+			t.Fatalf("Register(%s) error = %v", auth.ID, err)
+		}
+	}
+
+	pickAll := func(ctx context.Context) map[string]bool {
+		seen := make(map[string]bool)
+		tried := make(map[string]struct{})
+		for {
+			auth, _, err := m.pickNext(ctx, "probe", "", cliproxyexecutor.Options{}, tried)
+			if err != nil {
+				return seen
+			}
+			seen[auth.ID] = true
+			tried[auth.ID] = struct{}{}
+		}
+	}
+	cases := []struct {
+		tenant string
+		want   []string
+	}{
+		{"", []string{"shared"}},
+		{"a", []string{"a-1.json"}},
+		{"b", []string{"prefixed", "shared"}},
+		{"c", []string{"declared"}},
+	}
+	for _, tc := range cases {
+		got := pickAll(WithTenant(context.Background(), tc.tenant))
+		if len(got) != len(tc.want) {
+			t.Fatalf("tenant %q selected %v, want %v", tc.tenant, got, tc.want)
+		}
+		for _, id := range tc.want {
+			if !got[id] {
+				t.Fatalf("tenant %q selected %v, want %v", tc.tenant, got, tc.want)
+			}
+		}
+	}
+
+	// Without tenants every credential is available.
+	SetTenants(nil)
+	if got := pickAll(context.Background()); len(got) != 4 {
+		t.Fatalf("expected all credentials without tenants, got %v", got)
+	}
+}
+
+func TestCredentialFilterAllows(t *testing.T) {
+	apiKey := &Auth{ID: "claude-key-1", Attributes: map[string]string{"api_key": "sk-x"}}
+	oauth := &Auth{ID: "claude-oauth.json", Metadata: map[string]any{"email": "batch@example.com"}, Attributes: map[string]string{"path": "/auths/team-batch.json"}}
+	if !(CredentialFilter{Kind: "api-key"}).Allows(apiKey) || (CredentialFilter{Kind: "api-key"}).Allows(oauth) {
+		t.Fatal("api-key filter must only admit API key credentials")
+	}
+	if !(CredentialFilter{Kind: "oauth"}).Allows(oauth) || (CredentialFilter{Kind: "oauth"}).Allows(apiKey) {
+		t.Fatal("oauth filter must only admit OAuth credentials")
+	}
+	if !(CredentialFilter{Patterns: []string{"team-*"}}).Allows(oauth) || (CredentialFilter{Patterns: []string{"team-*"}}).Allows(apiKey) {
+		t.Fatal("pattern filter must match credential file names")
+	}
+}
+
+func TestTenantAllowsScopesOwnedCredentials(t *testing.T) {
+	SetTenants([]TenantCredentials{{Name: "team-a", Patterns: []string{"team-a/*"}}, {Name: "team-b", Patterns: []string{"team-b-*"}, Shared: true}})
+	t.Cleanup(func() { SetTenants(nil) })
+
+	owned := &Auth{ID: "team-a/nested/cred.json"}
+	shared := &Auth{ID: "shared.json"}
+	cases := []struct {
+		tenant string
+		auth   *Auth
+		want   bool
+	}{
+		{"team-a", owned, true},
+		{"team-b", owned, false},
+		{"", owned, false},
+		{"team-a", shared, false},
+		{"team-b", shared, true},
+		{"", shared, true},
+	}
+	for _, tc := range cases {
+		if got := TenantAllows(tc.tenant, tc.auth); got != tc.want {
+			t.Errorf("TenantAllows(%q, %s) = %v, want %v", tc.tenant, tc.auth.ID, got, tc.want)
+		}
+	}
+}
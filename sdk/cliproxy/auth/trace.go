@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
+)
+
+// traceSelection records the credential picked for an upstream attempt and the model name sent
+// to it after prefix stripping and OAuth model mappings.
+func traceSelection(ctx context.Context, provider, model, upstreamModel string, auth *Auth, attempt int) {
+	t := trace.FromContext(ctx)
+	if t == nil || auth == nil {
+		return
+	}
+	data := map[string]any{
+		"provider": provider,
+		"auth_id":  auth.ID,
+		"attempt":  attempt,
+		"model":    model,
+	}
+	if upstreamModel != model {
+		data["upstream_model"] = upstreamModel
+	}
+	if auth.Label != "" {
+		data["label"] = auth.Label
+	}
+	if auth.Prefix != "" {
+		data["prefix"] = auth.Prefix
+	}
+	t.Add("credential.selected", fmt.Sprintf("%s credential %s selected for %s", provider, auth.ID, upstreamModel), data)
+}
+
+// traceAttempt records the outcome and duration of an upstream attempt.
+func traceAttempt(ctx context.Context, kind, authID string, started time.Time, err error) {
+	t := trace.FromContext(ctx)
+	if t == nil {
+		return
+	}
+	data := map[string]any{"auth_id": authID}
+	message := "succeeded"
+	if err != nil {
+		message = "failed: " + err.Error()
+		data["error"] = err.Error()
+		if status := statusCodeFromError(err); status > 0 {
+			data["http_status"] = status
+		}
+	}
+	t.AddTimed(kind, message, started, data)
+}
+
+// traceRetry records that every credential failed and the request is retried after wait.
+func traceRetry(ctx context.Context, attempt int, wait time.Duration, err error) {
+	t := trace.FromContext(ctx)
+	if t == nil {
+		return
+	}
+	data := map[string]any{"retry": attempt, "wait_ms": wait.Milliseconds()}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	t.Add("retry", fmt.Sprintf("all credentials failed; retry %d after %s", attempt, wait), data)
+}
@@ -58,6 +58,9 @@ type Auth struct {
 	NextRetryAfter time.Time `json:"next_retry_after"`
 	// ModelStates tracks per-model runtime availability data.
 	ModelStates map[string]*ModelState `json:"model_states,omitempty"`
+	// Health tracks consecutive authentication and quota failures; unhealthy credentials are
+	// skipped by selection until a re-check succeeds.
+	Health CredentialHealth `json:"health,omitzero"`
 
 	// Runtime carries non-serialisable data used during execution (in-memory only).
 	Runtime any `json:"-"`
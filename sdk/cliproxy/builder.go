@@ -207,6 +207,8 @@ func (b *Builder) Build() (*Service, error) {
 		switch strategy {
 		case "fill-first", "fillfirst", "ff":
 			selector = &coreauth.FillFirstSelector{}
+		case "latency", "least-latency", "fastest":
+			selector = &coreauth.LatencySelector{}
 		default:
 			selector = &coreauth.RoundRobinSelector{}
 		}
@@ -0,0 +1,35 @@
+package cliproxy
+
+import (
+	certaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/cert_access"
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/grpcapi"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// Client runs completion requests through an embedded service without a network hop.
+type Client = grpcapi.LocalClient
+
+// Request is a completion request in one of the client-facing formats
+// ("openai", "openai-response", "claude" or "gemini").
+type Request = grpcapi.Request
+
+// Response is the buffered result of Client.Complete.
+type Response = grpcapi.Response
+
+// Chunk is one streamed event delivered by Client.Stream.
+type Chunk = grpcapi.Chunk
+
+// ProviderExecutor is the interface a provider implements to serve requests for its auths.
+// Register implementations on the core auth manager with RegisterExecutor.
+type ProviderExecutor = coreauth.ProviderExecutor
+
+// NewService builds a service from cfg with the default providers, token stores and watcher,
+// and registers the built-in api-keys and client-certificate access providers. configPath is
+// watched for changes; use NewBuilder to customise any component.
+func NewService(cfg *config.Config, configPath string) (*Service, error) {
+	configaccess.Register()
+	certaccess.Register()
+	return NewBuilder().WithConfig(cfg).WithConfigPath(configPath).Build()
+}
@@ -0,0 +1,94 @@
+package cliproxy
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mock"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestEmbeddedServiceClient(t *testing.T) {
+	upstream := httptest.NewServer(mock.NewHandler(mock.Options{Text: "hello from mock", Models: []string{"mock-model"}}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 0\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := &config.Config{
+		AuthDir: filepath.Join(dir, "auth"),
+		OpenAICompatibility: []config.OpenAICompatibility{{
+			Name:          "mock",
+			BaseURL:       upstream.URL + "/v1",
+			APIKeyEntries: []config.OpenAICompatibilityAPIKey{{APIKey: "upstream-key"}},
+			Models:        []config.OpenAICompatibilityModel{{Name: "mock-model", Alias: "embedded-model"}},
+		}},
+	}
+	cfg.APIKeys = []string{"client-key"}
+
+	svc, err := NewService(cfg, configPath)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if _, err = svc.Handler(); !errors.Is(err, ErrNotStarted) {
+		t.Fatalf("Handler() before Start error = %v, want ErrNotStarted", err)
+	}
+	if _, err = svc.Client("client-key"); !errors.Is(err, ErrNotStarted) {
+		t.Fatalf("Client() before Start error = %v, want ErrNotStarted", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	if err = svc.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = svc.Shutdown(context.Background()) }()
+	client, err := svc.Client("client-key")
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+
+	request := &Request{Format: "openai", Model: "embedded-model", Body: []byte(`{"messages":[{"role":"user","content":"hi"}]}`)}
+	// The configured credentials are registered by the watcher shortly after Start.
+	var resp *Response
+	for {
+		if resp, err = client.Complete(ctx, request); err == nil || ctx.Err() != nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got := gjson.GetBytes(resp.Body, "choices.0.message.content").String(); got != "hello from mock" {
+		t.Fatalf("Complete content = %q, body %s", got, resp.Body)
+	}
+
+	var text strings.Builder
+	err = client.Stream(ctx, &Request{Format: "claude", Model: "embedded-model", Body: []byte(`{"max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`)}, func(c *Chunk) error {
+		if delta := gjson.GetBytes(c.Data, "delta.text"); delta.Exists() {
+			text.WriteString(delta.String())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if got := strings.TrimSpace(text.String()); got != "hello from mock" {
+		t.Fatalf("Stream text = %q", got)
+	}
+
+	unauthenticated, _ := svc.Client("")
+	if _, err = unauthenticated.Complete(ctx, request); err == nil || !strings.Contains(err.Error(), "Unauthenticated") {
+		t.Fatalf("Complete without key error = %v, want Unauthenticated", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package provider defines the contract for upstream providers supplied by external Go modules.
+// A registered Provider is wrapped in an executor that handles translation, proxies, request
+// logging, usage accounting and streaming, so an implementation only describes how to talk to
+// its upstream.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sse"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// ModelInfo re-exports the registry model info structure.
+type ModelInfo = registry.ModelInfo
+
+// Provider describes an upstream service. Auth entries whose Provider field equals Identifier
+// are served by it.
+type Provider interface {
+	// Identifier returns the provider key matched against Auth.Provider.
+	Identifier() string
+	// Format returns the schema the upstream speaks. Requests are translated into it and
+	// responses translated back out of it, so a translator pair must be registered for every
+	// client format that should reach this provider.
+	Format() sdktranslator.Format
+	// Authorize applies the credentials held by auth to an outbound request.
+	Authorize(req *http.Request, auth *coreauth.Auth) error
+	// BuildRequest creates the upstream request for a translated payload. The body and URL are
+	// up to the provider; Authorize is applied afterwards.
+	BuildRequest(ctx context.Context, auth *coreauth.Auth, model string, payload []byte, stream bool) (*http.Request, error)
+	// DecodeStream splits a streaming response body into frames handed to the response
+	// translator, stopping early when emit returns an error.
+	DecodeStream(body io.Reader, emit func(frame []byte) error) error
+	// MapError converts a non-2xx upstream response into an error. Errors exposing
+	// StatusCode() int drive cooldowns and retries; StatusError is a ready-made one.
+	MapError(status int, header http.Header, body []byte) error
+}
+
+// Refresher is implemented by providers whose credentials expire.
+type Refresher interface {
+	Refresh(ctx context.Context, auth *coreauth.Auth) (*coreauth.Auth, error)
+}
+
+// ModelLister is implemented by providers that publish models for their auths in /v1/models.
+type ModelLister interface {
+	Models(auth *coreauth.Auth) []*ModelInfo
+}
+
+// UsageParser is implemented by providers whose usage reporting differs from their Format's.
+// The frame form is called for every streamed frame and reports ok once usage is present.
+type UsageParser interface {
+	ParseUsage(payload []byte) usage.Detail
+	ParseStreamUsage(frame []byte) (usage.Detail, bool)
+}
+
+var (
+	registryMu sync.RWMutex
+	providers  = make(map[string]Provider)
+)
+
+// Register adds p to the registry, replacing any provider with the same identifier. Register
+// before the service starts so auths loaded at startup bind to it.
+func Register(p Provider) {
+	if p == nil {
+		return
+	}
+	key := normalizeKey(p.Identifier())
+	if key == "" {
+		return
+	}
+	registryMu.Lock()
+	providers[key] = p
+	registryMu.Unlock()
+}
+
+// Unregister removes the provider registered under id.
+func Unregister(id string) {
+	registryMu.Lock()
+	delete(providers, normalizeKey(id))
+	registryMu.Unlock()
+}
+
+// Lookup returns the provider registered under id.
+func Lookup(id string) (Provider, bool) {
+	registryMu.RLock()
+	p, ok := providers[normalizeKey(id)]
+	registryMu.RUnlock()
+	return p, ok
+}
+
+// Registered returns the identifiers of all registered providers in sorted order.
+func Registered() []string {
+	registryMu.RLock()
+	ids := make([]string, 0, len(providers))
+	for id := range providers {
+		ids = append(ids, id)
+	}
+	registryMu.RUnlock()
+	sort.Strings(ids)
+	return ids
+}
+
+func normalizeKey(id string) string {
+	return strings.ToLower(strings.TrimSpace(id))
+}
+
+// DecodeLines emits every non-empty line of body, the framing expected by the built-in
+// translators for server-sent events.
+func DecodeLines(body io.Reader, emit func(frame []byte) error) error {
+	scanner := sse.NewScanner(body)
+	defer scanner.Release()
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if err := emit(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// StatusError is an upstream error carrying its HTTP status code.
+type StatusError struct {
+	Code int
+	Body []byte
+}
+
+// NewStatusError returns a StatusError for status and body.
+func NewStatusError(status int, body []byte) *StatusError {
+	return &StatusError{Code: status, Body: body}
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Body) > 0 {
+		return string(e.Body)
+	}
+	return fmt.Sprintf("status %d", e.Code)
+}
+
+// StatusCode returns the upstream HTTP status code.
+func (e *StatusError) StatusCode() int { return e.Code }
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+type stubProvider struct{ id string }
+
+func (p stubProvider) Identifier() string                             { return p.id }
+func (stubProvider) Format() sdktranslator.Format                     { return sdktranslator.FormatOpenAI }
+func (stubProvider) Authorize(*http.Request, *coreauth.Auth) error    { return nil }
+func (stubProvider) DecodeStream(io.Reader, func([]byte) error) error { return nil }
+func (stubProvider) MapError(status int, _ http.Header, b []byte) error {
+	return NewStatusError(status, b)
+}
+func (stubProvider) BuildRequest(ctx context.Context, _ *coreauth.Auth, _ string, _ []byte, _ bool) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodPost, "http://example.invalid", nil)
+}
+
+func TestRegisterLookupUnregister(t *testing.T) {
+	Register(stubProvider{id: " Stub-B "})
+	Register(stubProvider{id: "stub-a"})
+	Register(stubProvider{id: ""})
+	t.Cleanup(func() {
+		Unregister("stub-a")
+		Unregister("stub-b")
+	})
+
+	if _, ok := Lookup("STUB-B"); !ok {
+		t.Fatal("expected lookup to ignore case and surrounding space")
+	}
+	if got := Registered(); !reflect.DeepEqual(got, []string{"stub-a", "stub-b"}) {
+		t.Fatalf("registered = %v", got)
+	}
+	Unregister("stub-a")
+	if _, ok := Lookup("stub-a"); ok {
+		t.Fatal("expected stub-a to be unregistered")
+	}
+}
+
+func TestDecodeLinesSkipsBlankLinesAndStopsOnEmitError(t *testing.T) {
+	body := "data: {\"a\":1}\n\ndata: {\"a\":2}\n\ndata: [DONE]\n"
+	var frames []string
+	stop := errors.New("stop")
+	err := DecodeLines(strings.NewReader(body), func(frame []byte) error {
+		frames = append(frames, string(frame))
+		if len(frames) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("err = %v, want stop", err)
+	}
+	if want := []string{`data: {"a":1}`, `data: {"a":2}`}; !reflect.DeepEqual(frames, want) {
+		t.Fatalf("frames = %q, want %q", frames, want)
+	}
+}
+
+func TestStatusErrorCarriesStatus(t *testing.T) {
+	err := stubProvider{}.MapError(http.StatusTooManyRequests, nil, nil)
+	var status interface{ StatusCode() int }
+	if !errors.As(err, &status) || status.StatusCode() != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %v", err)
+	}
+	if err.Error() != "status 429" {
+		t.Fatalf("error text = %q", err.Error())
+	}
+}
@@ -7,20 +7,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/credcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
-	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/state"
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/wsrelay"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/grpcapi"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyprovider "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/provider"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	log "github.com/sirupsen/logrus"
@@ -86,6 +92,12 @@ type Service struct {
 
 	// wsGateway manages websocket Gemini providers.
 	wsGateway *wsrelay.Manager
+
+	// stateStore is the durable backend shared by stateful subsystems, when enabled.
+	stateStore state.Store
+
+	// stateCancel stops background persistence into the state store.
+	stateCancel context.CancelFunc
 }
 
 // RegisterUsagePlugin registers a usage plugin on the global usage manager.
@@ -282,6 +294,7 @@ func (s *Service) applyCoreAuthAddOrUpdate(ctx context.Context, auth *coreauth.A
 		auth.CreatedAt = existing.CreatedAt
 		auth.LastRefreshedAt = existing.LastRefreshedAt
 		auth.NextRefreshAfter = existing.NextRefreshAfter
+		auth.Health = existing.Health
 		if _, err := s.coreManager.Update(ctx, auth); err != nil {
 			log.Errorf("failed to update auth %s: %v", auth.ID, err)
 		}
@@ -315,6 +328,34 @@ func (s *Service) applyRetryConfig(cfg *config.Config) {
 	}
 	maxInterval := time.Duration(cfg.MaxRetryInterval) * time.Second
 	s.coreManager.SetRetryConfig(cfg.RequestRetry, maxInterval)
+	s.applyConcurrencyConfig(cfg)
+	s.applyHedgingConfig(cfg)
+}
+
+func (s *Service) applyHedgingConfig(cfg *config.Config) {
+	delay := time.Duration(cfg.Hedging.FirstTokenMs) * time.Millisecond
+	if delay <= 0 {
+		delay = 3 * time.Second
+	}
+	s.coreManager.SetHedging(coreauth.HedgingConfig{
+		Enabled:   cfg.Hedging.Enable,
+		Delay:     delay,
+		Providers: cfg.Hedging.Providers,
+	})
+}
+
+func (s *Service) applyConcurrencyConfig(cfg *config.Config) {
+	limits := make(map[string]coreauth.ConcurrencyLimit, len(cfg.Concurrency.Upstreams))
+	for _, upstream := range cfg.Concurrency.Upstreams {
+		limits[upstream.Provider] = coreauth.ConcurrencyLimit{
+			MaxInFlight:  upstream.MaxInFlight,
+			QueueSize:    upstream.QueueSize,
+			QueueTimeout: time.Duration(upstream.QueueTimeoutSeconds) * time.Second,
+			Fair:         cfg.Concurrency.FairQueuing,
+			Weights:      cfg.Concurrency.KeyWeights,
+		}
+	}
+	s.coreManager.SetConcurrencyLimits(limits)
 }
 
 func openAICompatInfoFromAuth(a *coreauth.Auth) (providerKey string, compatName string, ok bool) {
@@ -347,6 +388,10 @@ func (s *Service) ensureExecutorsForAuth(a *coreauth.Auth) {
 	if a.Disabled {
 		return
 	}
+	if p, ok := cliproxyprovider.Lookup(a.Provider); ok {
+		s.coreManager.RegisterExecutor(executor.NewProviderExecutor(p, s.cfg))
+		return
+	}
 	if compatProviderKey, _, isCompat := openAICompatInfoFromAuth(a); isCompat {
 		if compatProviderKey == "" {
 			compatProviderKey = strings.ToLower(strings.TrimSpace(a.Provider))
@@ -426,11 +471,106 @@ func (s *Service) Run(ctx context.Context) error {
 		}
 	}()
 
+	if err := s.prepare(ctx); err != nil {
+		return err
+	}
+
+	if s.hooks.OnBeforeStart != nil {
+		s.hooks.OnBeforeStart(s.cfg)
+	}
+
+	s.serverErr = make(chan error, 1)
+	go func() {
+		if errStart := s.server.Start(); errStart != nil {
+			s.serverErr <- errStart
+		} else {
+			s.serverErr <- nil
+		}
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	fmt.Printf("API server started successfully on: %s:%d\n", s.cfg.Host, s.cfg.Port)
+
+	if s.hooks.OnAfterStart != nil {
+		s.hooks.OnAfterStart(s)
+	}
+
+	if err := s.startBackground(ctx); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Debug("service context cancelled, shutting down...")
+		return ctx.Err()
+	case err := <-s.serverErr:
+		return err
+	}
+}
+
+// Start initialises the service without opening any listener, for programs that serve Handler
+// from their own HTTP server or call the proxy through Client. Config and auth watching, token
+// refresh and health checks run until Shutdown is called.
+func (s *Service) Start(ctx context.Context) error {
+	if s == nil {
+		return fmt.Errorf("cliproxy: service is nil")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	usage.StartDefault(ctx)
+	if err := s.prepare(ctx); err != nil {
+		return err
+	}
+	if s.hooks.OnBeforeStart != nil {
+		s.hooks.OnBeforeStart(s.cfg)
+	}
+	if err := s.startBackground(ctx); err != nil {
+		return err
+	}
+	if s.hooks.OnAfterStart != nil {
+		s.hooks.OnAfterStart(s)
+	}
+	return nil
+}
+
+// ErrNotStarted is returned by Handler and Client before Start or Run has initialised the service.
+var ErrNotStarted = errors.New("cliproxy: service not started; call Start or Run first")
+
+// Handler returns the HTTP handler serving every route of the proxy, including the management
+// API when it is enabled. The handler exists once Start or Run has initialised the service;
+// before that Handler returns ErrNotStarted.
+func (s *Service) Handler() (http.Handler, error) {
+	if s == nil || s.server == nil {
+		return nil, ErrNotStarted
+	}
+	return s.server.Handler(), nil
+}
+
+// Client returns a client that runs requests through the service in-process, with the same
+// routing, translation, authentication and quotas as HTTP callers. apiKey authenticates the
+// calls when the configuration requires API keys. Like Handler, it returns ErrNotStarted until
+// Start or Run has initialised the service.
+func (s *Service) Client(apiKey string) (*Client, error) {
+	handler, err := s.Handler()
+	if err != nil {
+		return nil, err
+	}
+	return grpcapi.NewLocalClient(handler, apiKey), nil
+}
+
+// prepare loads credentials and builds the HTTP server without starting it.
+func (s *Service) prepare(ctx context.Context) error {
 	if err := s.ensureAuthDir(); err != nil {
 		return err
 	}
 
 	s.applyRetryConfig(s.cfg)
+	executor.ConfigureUpstreamTransport(s.cfg)
+
+	if err := s.openStateStore(ctx); err != nil {
+		return err
+	}
 
 	if s.coreManager != nil {
 		if errLoad := s.coreManager.Load(ctx); errLoad != nil {
@@ -484,32 +624,19 @@ func (s *Service) Run(ctx context.Context) error {
 		})
 	}
 
-	if s.hooks.OnBeforeStart != nil {
-		s.hooks.OnBeforeStart(s.cfg)
-	}
-
-	s.serverErr = make(chan error, 1)
-	go func() {
-		if errStart := s.server.Start(); errStart != nil {
-			s.serverErr <- errStart
-		} else {
-			s.serverErr <- nil
-		}
-	}()
-
-	time.Sleep(100 * time.Millisecond)
-	fmt.Printf("API server started successfully on: %s:%d\n", s.cfg.Host, s.cfg.Port)
-
-	if s.hooks.OnAfterStart != nil {
-		s.hooks.OnAfterStart(s)
-	}
+	return nil
+}
 
+// startBackground starts config and auth watching, token refresh and health checks.
+func (s *Service) startBackground(ctx context.Context) error {
 	var watcherWrapper *WatcherWrapper
 	reloadCallback := func(newCfg *config.Config) {
 		previousStrategy := ""
+		var previousCfg *config.Config
 		s.cfgMu.RLock()
 		if s.cfg != nil {
 			previousStrategy = strings.ToLower(strings.TrimSpace(s.cfg.Routing.Strategy))
+			previousCfg = s.cfg
 		}
 		s.cfgMu.RUnlock()
 
@@ -527,6 +654,8 @@ func (s *Service) Run(ctx context.Context) error {
 			switch strategy {
 			case "fill-first", "fillfirst", "ff":
 				return "fill-first"
+			case "latency", "least-latency", "fastest":
+				return "latency"
 			default:
 				return "round-robin"
 			}
@@ -538,6 +667,8 @@ func (s *Service) Run(ctx context.Context) error {
 			switch nextStrategy {
 			case "fill-first":
 				selector = &coreauth.FillFirstSelector{}
+			case "latency":
+				selector = &coreauth.LatencySelector{}
 			default:
 				selector = &coreauth.RoundRobinSelector{}
 			}
@@ -545,7 +676,17 @@ func (s *Service) Run(ctx context.Context) error {
 			log.Infof("routing strategy updated to %s", nextStrategy)
 		}
 
+		if previousCfg != nil && newCfg.CredentialEncryption != previousCfg.CredentialEncryption {
+			if errCrypt := credcrypt.Configure(newCfg.CredentialEncryption); errCrypt != nil {
+				log.Errorf("failed to reconfigure credential encryption, keeping the previous key: %v", errCrypt)
+			} else {
+				log.Info("credential encryption settings updated")
+			}
+		}
 		s.applyRetryConfig(newCfg)
+		executor.ConfigureUpstreamTransport(newCfg)
+		s.applyHealthCheck(newCfg)
+		s.applyCredentialHealth(newCfg)
 		if s.server != nil {
 			s.server.UpdateClients(newCfg)
 		}
@@ -558,7 +699,7 @@ func (s *Service) Run(ctx context.Context) error {
 		s.rebindExecutors()
 	}
 
-	watcherWrapper, err = s.watcherFactory(s.configPath, s.cfg.AuthDir, reloadCallback)
+	watcherWrapper, err := s.watcherFactory(s.configPath, s.cfg.AuthDir, reloadCallback)
 	if err != nil {
 		return fmt.Errorf("cliproxy: failed to create watcher: %w", err)
 	}
@@ -582,14 +723,9 @@ func (s *Service) Run(ctx context.Context) error {
 		s.coreManager.StartAutoRefresh(context.Background(), interval)
 		log.Infof("core auth auto-refresh started (interval=%s)", interval)
 	}
-
-	select {
-	case <-ctx.Done():
-		log.Debug("service context cancelled, shutting down...")
-		return ctx.Err()
-	case err = <-s.serverErr:
-		return err
-	}
+	s.applyHealthCheck(s.cfg)
+	s.applyCredentialHealth(s.cfg)
+	return nil
 }
 
 // Shutdown gracefully stops background workers and the HTTP server.
@@ -618,6 +754,8 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 		if s.coreManager != nil {
 			s.coreManager.StopAutoRefresh()
+			s.coreManager.StopHealthProbes()
+			s.coreManager.StopCredentialHealth()
 		}
 		if s.watcher != nil {
 			if err := s.watcher.Stop(); err != nil {
@@ -652,10 +790,99 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 
 		usage.StopDefault()
+		executor.StopUpstreamTransport()
+		s.closeStateStore(ctx)
 	})
 	return shutdownErr
 }
 
+// applyHealthCheck starts, reconfigures or stops the upstream health probes.
+func (s *Service) applyHealthCheck(cfg *config.Config) {
+	if s.coreManager == nil || cfg == nil {
+		return
+	}
+	hc := cfg.HealthCheck
+	if !hc.Enable {
+		if s.coreManager.HealthProbesRunning() {
+			s.coreManager.StopHealthProbes()
+			log.Info("upstream health probes stopped")
+		}
+		return
+	}
+	s.coreManager.StartHealthProbes(context.Background(), coreauth.HealthProbeConfig{
+		Interval: time.Duration(hc.IntervalSeconds) * time.Second,
+		Timeout:  time.Duration(hc.TimeoutSeconds) * time.Second,
+		Models:   hc.Models,
+	})
+	log.Debug("upstream health probes started")
+}
+
+// applyCredentialHealth applies the credential health settings to the core manager.
+func (s *Service) applyCredentialHealth(cfg *config.Config) {
+	if s.coreManager == nil || cfg == nil {
+		return
+	}
+	ch := cfg.CredentialHealth
+	s.coreManager.SetCredentialHealth(coreauth.CredentialHealthConfig{
+		Enabled:            ch.Enable,
+		FailureThreshold:   ch.FailureThreshold,
+		RecheckInterval:    time.Duration(ch.RecheckIntervalSeconds) * time.Second,
+		MaxRecheckInterval: time.Duration(ch.MaxRecheckIntervalSeconds) * time.Second,
+		Models:             cfg.HealthCheck.Models,
+	})
+}
+
+// openStateStore connects the configured durable state backend, restores persisted usage
+// statistics and starts periodic persistence.
+func (s *Service) openStateStore(ctx context.Context) error {
+	if s.cfg == nil || !s.cfg.StateStore.Enable {
+		return nil
+	}
+	sc := s.cfg.StateStore
+	dsn := sc.DSN
+	if !strings.EqualFold(strings.TrimSpace(sc.Driver), state.DriverPostgres) {
+		dsn = strings.TrimSpace(sc.Path)
+		if dsn == "" {
+			dsn = filepath.Join(s.cfg.AuthDir, "state.db")
+		}
+	}
+	store, err := state.Open(ctx, sc.Driver, dsn)
+	if err != nil {
+		return fmt.Errorf("cliproxy: failed to open state store: %w", err)
+	}
+	s.stateStore = store
+	state.SetDefault(store)
+
+	stats := internalusage.GetRequestStatistics()
+	if result, errLoad := stats.LoadFromStore(ctx, store); errLoad != nil {
+		log.Warnf("failed to restore usage statistics: %v", errLoad)
+	} else if result.Added > 0 {
+		log.Infof("restored %d usage records from state store", result.Added)
+	}
+	persistCtx, cancel := context.WithCancel(context.Background())
+	s.stateCancel = cancel
+	stats.StartPersistence(persistCtx, store, time.Duration(sc.UsageFlushSeconds)*time.Second)
+	log.Infof("state store enabled (%s)", store.Driver())
+	return nil
+}
+
+func (s *Service) closeStateStore(ctx context.Context) {
+	if s.stateStore == nil {
+		return
+	}
+	if s.stateCancel != nil {
+		s.stateCancel()
+	}
+	if err := internalusage.GetRequestStatistics().SaveToStore(ctx, s.stateStore); err != nil {
+		log.Warnf("failed to persist usage statistics: %v", err)
+	}
+	state.SetDefault(nil)
+	if err := s.stateStore.Close(); err != nil {
+		log.Warnf("failed to close state store: %v", err)
+	}
+	s.stateStore = nil
+}
+
 func (s *Service) ensureAuthDir() error {
 	info, err := os.Stat(s.cfg.AuthDir)
 	if err != nil {
@@ -706,6 +933,17 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 	}
 	excluded := s.oauthExcludedModels(provider, authKind)
 	var models []*ModelInfo
+	if p, ok := cliproxyprovider.Lookup(provider); ok && !compatDetected {
+		if lister, okList := p.(cliproxyprovider.ModelLister); okList {
+			models = applyExcludedModels(lister.Models(a), excluded)
+		}
+		if len(models) > 0 {
+			GlobalModelRegistry().RegisterClient(a.ID, provider, applyModelPrefixes(models, a.Prefix, s.cfg != nil && s.cfg.ForceModelPrefix))
+		} else {
+			GlobalModelRegistry().UnregisterClient(a.ID)
+		}
+		return
+	}
 	switch provider {
 	case "gemini":
 		models = registry.GetGeminiModels()
@@ -13,6 +13,7 @@ type Record struct {
 	Provider    string
 	Model       string
 	APIKey      string
+	Tenant      string
 	AuthID      string
 	AuthIndex   string
 	Source      string
@@ -109,6 +110,9 @@ func (m *Manager) Publish(ctx context.Context, record Record) {
 	if m == nil {
 		return
 	}
+	if tally := TallyFromContext(ctx); tally != nil {
+		tally.Add(record)
+	}
 	// ensure worker is running even if Start was not called explicitly
 	m.Start(context.Background())
 	m.mu.Lock()
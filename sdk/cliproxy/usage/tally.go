@@ -0,0 +1,62 @@
+package usage
+
+import (
+	"context"
+	"sync"
+)
+
+type tallyKey struct{}
+
+// Tally accumulates the usage records published for one inbound request. Records are added
+// synchronously when published, so the tally is complete once the handler returns.
+type Tally struct {
+	mu       sync.Mutex
+	attempts int
+	failed   int
+	last     Record
+	detail   Detail
+}
+
+// WithTally returns a context whose published usage records are added to tally.
+func WithTally(ctx context.Context, tally *Tally) context.Context {
+	return context.WithValue(ctx, tallyKey{}, tally)
+}
+
+// TallyFromContext returns the tally attached to ctx, or nil.
+func TallyFromContext(ctx context.Context) *Tally {
+	if ctx == nil {
+		return nil
+	}
+	tally, _ := ctx.Value(tallyKey{}).(*Tally)
+	return tally
+}
+
+// Add folds record into the tally.
+func (t *Tally) Add(record Record) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attempts++
+	if record.Failed {
+		t.failed++
+	}
+	t.last = record
+	t.detail.InputTokens += record.Detail.InputTokens
+	t.detail.OutputTokens += record.Detail.OutputTokens
+	t.detail.ReasoningTokens += record.Detail.ReasoningTokens
+	t.detail.CachedTokens += record.Detail.CachedTokens
+	t.detail.TotalTokens += record.Detail.TotalTokens
+}
+
+// Snapshot returns the number of upstream attempts, how many failed, the most recent record and
+// the summed token usage.
+func (t *Tally) Snapshot() (attempts, failed int, last Record, detail Detail) {
+	if t == nil {
+		return 0, 0, Record{}, Detail{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.attempts, t.failed, t.last, t.detail
+}
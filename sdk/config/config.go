@@ -13,6 +13,49 @@ type AccessProvider = internalconfig.AccessProvider
 type Config = internalconfig.Config
 
 type StreamingConfig = internalconfig.StreamingConfig
+type StreamBackpressureConfig = internalconfig.StreamBackpressureConfig
+type StreamCoalesceConfig = internalconfig.StreamCoalesceConfig
+type StreamPacingConfig = internalconfig.StreamPacingConfig
+type StreamCancelConfig = internalconfig.StreamCancelConfig
+type StreamCancelRoute = internalconfig.StreamCancelRoute
+type ResponseCacheConfig = internalconfig.ResponseCacheConfig
+type ResponseCacheRedisConfig = internalconfig.ResponseCacheRedisConfig
+type SemanticCacheConfig = internalconfig.SemanticCacheConfig
+type SemanticCacheEmbeddingConfig = internalconfig.SemanticCacheEmbeddingConfig
+type TransformRule = internalconfig.TransformRule
+type TransformStep = internalconfig.TransformStep
+type RequestPriorityConfig = internalconfig.RequestPriorityConfig
+type MessageBatchesConfig = internalconfig.MessageBatchesConfig
+type OpenAIBatchesConfig = internalconfig.OpenAIBatchesConfig
+type GuardrailsConfig = internalconfig.GuardrailsConfig
+type GuardrailModerator = internalconfig.GuardrailModerator
+type GuardrailPolicy = internalconfig.GuardrailPolicy
+type PIIRedactionConfig = internalconfig.PIIRedactionConfig
+type PIIPattern = internalconfig.PIIPattern
+type ResponseFilterConfig = internalconfig.ResponseFilterConfig
+type ResponseFilterRule = internalconfig.ResponseFilterRule
+type RequestOverridesConfig = internalconfig.RequestOverridesConfig
+type ShadowTrafficConfig = internalconfig.ShadowTrafficConfig
+type ShadowRule = internalconfig.ShadowRule
+type AnalyticsTeeConfig = internalconfig.AnalyticsTeeConfig
+type AnalyticsSinkConfig = internalconfig.AnalyticsSinkConfig
+type ObjectStorageConfig = internalconfig.ObjectStorageConfig
+type TranscriptsConfig = internalconfig.TranscriptsConfig
+type CanaryRoutingConfig = internalconfig.CanaryRoutingConfig
+type CanaryRule = internalconfig.CanaryRule
+type ScheduledRoutingConfig = internalconfig.ScheduledRoutingConfig
+type ScheduledRule = internalconfig.ScheduledRule
+type SessionAffinityConfig = internalconfig.SessionAffinityConfig
+type ContextOverflowConfig = internalconfig.ContextOverflowConfig
+type ContextFallback = internalconfig.ContextFallback
+type CompactionConfig = internalconfig.CompactionConfig
+type CodeExecutionConfig = internalconfig.CodeExecutionConfig
+type APIKeyPolicy = internalconfig.APIKeyPolicy
+type CostPreflightConfig = internalconfig.CostPreflightConfig
+type CostDowngrade = internalconfig.CostDowngrade
+type TenantConfig = internalconfig.TenantConfig
+type TenantModelAlias = internalconfig.TenantModelAlias
+type TenantQuota = internalconfig.TenantQuota
 type TLSConfig = internalconfig.TLSConfig
 type RemoteManagement = internalconfig.RemoteManagement
 type AmpCode = internalconfig.AmpCode
@@ -33,9 +76,10 @@ type OpenAICompatibilityModel = internalconfig.OpenAICompatibilityModel
 type TLS = internalconfig.TLSConfig
 
 const (
-	AccessProviderTypeConfigAPIKey = internalconfig.AccessProviderTypeConfigAPIKey
-	DefaultAccessProviderName      = internalconfig.DefaultAccessProviderName
-	DefaultPanelGitHubRepository   = internalconfig.DefaultPanelGitHubRepository
+	AccessProviderTypeConfigAPIKey      = internalconfig.AccessProviderTypeConfigAPIKey
+	AccessProviderTypeClientCertificate = internalconfig.AccessProviderTypeClientCertificate
+	DefaultAccessProviderName           = internalconfig.DefaultAccessProviderName
+	DefaultPanelGitHubRepository        = internalconfig.DefaultPanelGitHubRepository
 )
 
 func MakeInlineAPIKeyProvider(keys []string) *AccessProvider {
@@ -0,0 +1,243 @@
+package guardrail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func init() {
+	Register("keyword", newKeywordModerator)
+	Register("http", newHTTPModerator)
+	Register("llm", newLLMModerator)
+}
+
+func stringArg(args map[string]any, key string) string {
+	if v, ok := args[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func intArg(args map[string]any, key string) int {
+	switch v := args[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+type keywordRule struct {
+	category string
+	pattern  *regexp.Regexp
+}
+
+// newKeywordModerator flags text matching args.rules, a list of {category, pattern} or
+// {category, keywords} entries. Keywords match case-insensitively on word boundaries.
+func newKeywordModerator(args map[string]any) (Moderator, error) {
+	rawRules, _ := args["rules"].([]any)
+	if len(rawRules) == 0 {
+		return nil, fmt.Errorf("keyword moderator requires at least one rule")
+	}
+	rules := make([]keywordRule, 0, len(rawRules))
+	for i, raw := range rawRules {
+		entry, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("rule %d must be a mapping", i)
+		}
+		category := strings.TrimSpace(stringArg(entry, "category"))
+		if category == "" {
+			category = "custom"
+		}
+		expr := stringArg(entry, "pattern")
+		if expr == "" {
+			words, _ := entry["keywords"].([]any)
+			quoted := make([]string, 0, len(words))
+			for _, w := range words {
+				if s, ok := w.(string); ok && strings.TrimSpace(s) != "" {
+					quoted = append(quoted, regexp.QuoteMeta(strings.TrimSpace(s)))
+				}
+			}
+			if len(quoted) == 0 {
+				return nil, fmt.Errorf("rule %d requires a pattern or keywords", i)
+			}
+			expr = `(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		rules = append(rules, keywordRule{category: category, pattern: re})
+	}
+	return Func(func(_ context.Context, in Input) (Result, error) {
+		res := Result{Categories: make(map[string]bool), Scores: make(map[string]float64)}
+		for _, rule := range rules {
+			if _, seen := res.Categories[rule.category]; !seen {
+				res.Categories[rule.category] = false
+				res.Scores[rule.category] = 0
+			}
+			for _, text := range in.Texts {
+				if rule.pattern.MatchString(text) {
+					res.Flagged = true
+					res.Categories[rule.category] = true
+					res.Scores[rule.category] = 1
+					break
+				}
+			}
+		}
+		return res, nil
+	}), nil
+}
+
+// newHTTPModerator posts {"input": [...]} to args.url and expects an OpenAI-compatible
+// moderation response.
+func newHTTPModerator(args map[string]any) (Moderator, error) {
+	url := strings.TrimSpace(stringArg(args, "url"))
+	if url == "" {
+		return nil, fmt.Errorf("http moderator requires a url")
+	}
+	apiKey := stringArg(args, "api-key")
+	model := stringArg(args, "model")
+	timeout := time.Duration(intArg(args, "timeout-seconds")) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	return Func(func(ctx context.Context, in Input) (Result, error) {
+		payload := map[string]any{"input": in.Texts}
+		if model != "" {
+			payload["model"] = model
+		}
+		body, _ := json.Marshal(payload)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return Result{}, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return Result{}, err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return Result{}, err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return Result{}, fmt.Errorf("moderator returned status %d", resp.StatusCode)
+		}
+		return ParseOpenAIResult(data), nil
+	}), nil
+}
+
+// ParseOpenAIResult merges the results of an OpenAI moderation response body.
+func ParseOpenAIResult(data []byte) Result {
+	var res Result
+	gjson.GetBytes(data, "results").ForEach(func(_, item gjson.Result) bool {
+		var one Result
+		one.Flagged = item.Get("flagged").Bool()
+		item.Get("categories").ForEach(func(k, v gjson.Result) bool {
+			if one.Categories == nil {
+				one.Categories = make(map[string]bool)
+			}
+			one.Categories[k.Str] = v.Bool()
+			return true
+		})
+		item.Get("category_scores").ForEach(func(k, v gjson.Result) bool {
+			if one.Scores == nil {
+				one.Scores = make(map[string]float64)
+			}
+			one.Scores[k.Str] = v.Float()
+			return true
+		})
+		res.Merge(one)
+		return true
+	})
+	return res
+}
+
+// llamaGuardCategories maps the Llama Guard 3 hazard codes to category names.
+var llamaGuardCategories = map[string]string{
+	"S1":  "violent-crimes",
+	"S2":  "non-violent-crimes",
+	"S3":  "sex-related-crimes",
+	"S4":  "child-sexual-exploitation",
+	"S5":  "defamation",
+	"S6":  "specialized-advice",
+	"S7":  "privacy",
+	"S8":  "intellectual-property",
+	"S9":  "indiscriminate-weapons",
+	"S10": "hate",
+	"S11": "suicide-self-harm",
+	"S12": "sexual-content",
+	"S13": "elections",
+	"S14": "code-interpreter-abuse",
+}
+
+// newLLMModerator asks a Llama Guard style model (args.model), served by one of the configured
+// upstreams, to classify the text. The model must answer "safe" or "unsafe" followed by a line
+// of comma-separated hazard codes.
+func newLLMModerator(args map[string]any) (Moderator, error) {
+	model := strings.TrimSpace(stringArg(args, "model"))
+	if model == "" {
+		return nil, fmt.Errorf("llm moderator requires a model")
+	}
+	template := stringArg(args, "prompt")
+	return Func(func(ctx context.Context, in Input) (Result, error) {
+		if in.Complete == nil {
+			return Result{}, fmt.Errorf("llm moderator is unavailable in this context")
+		}
+		content := strings.Join(in.Texts, "\n\n")
+		prompt := content
+		if template != "" {
+			prompt = strings.ReplaceAll(template, "{{input}}", content)
+		}
+		reply, err := in.Complete(WithoutGuardrails(ctx), model, prompt)
+		if err != nil {
+			return Result{}, err
+		}
+		return parseLlamaGuard(reply), nil
+	}), nil
+}
+
+func parseLlamaGuard(reply string) Result {
+	res := Result{Categories: make(map[string]bool), Scores: make(map[string]float64)}
+	lines := strings.Split(strings.TrimSpace(reply), "\n")
+	if len(lines) == 0 || !strings.EqualFold(strings.TrimSpace(lines[0]), "unsafe") {
+		return res
+	}
+	res.Flagged = true
+	if len(lines) < 2 {
+		res.Categories["unsafe"] = true
+		res.Scores["unsafe"] = 1
+		return res
+	}
+	for _, code := range strings.Split(lines[1], ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		name, ok := llamaGuardCategories[code]
+		if !ok {
+			name = strings.ToLower(code)
+		}
+		res.Categories[name] = true
+		res.Scores[name] = 1
+	}
+	return res
+}
@@ -0,0 +1,316 @@
+// Package guardrail provides pluggable content moderators and the policy engine that applies
+// them to proxied traffic.
+//
+// Operators declare moderators and policies in the "guardrails" configuration block; SDK users
+// extend the set of available moderator types by registering their own factories. Moderators
+// back the /v1/moderations endpoint and, through policies, can block or annotate prompts and
+// non-streaming responses inline.
+package guardrail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// Check identifies what a policy screens.
+type Check string
+
+const (
+	// CheckPrompt screens client requests before they are sent upstream.
+	CheckPrompt Check = "prompt"
+	// CheckResponse screens non-streaming responses before they are returned.
+	CheckResponse Check = "response"
+)
+
+// Action identifies what happens when a policy flags content.
+type Action string
+
+const (
+	// ActionBlock rejects the request with a content policy error.
+	ActionBlock Action = "block"
+	// ActionAnnotate lets the request through and reports the verdict in a response header.
+	ActionAnnotate Action = "annotate"
+)
+
+// CompleteFunc sends a single-turn chat prompt to model through the proxy and returns the reply.
+type CompleteFunc func(ctx context.Context, model, prompt string) (string, error)
+
+// Input is the content handed to a moderator.
+type Input struct {
+	// Texts holds the text fragments to screen.
+	Texts []string
+	// Complete lets model-backed moderators query an upstream model. It may be nil.
+	Complete CompleteFunc
+}
+
+// Result is a moderator verdict.
+type Result struct {
+	Flagged    bool
+	Categories map[string]bool
+	Scores     map[string]float64
+}
+
+// FlaggedCategories returns the sorted names of the flagged categories.
+func (r Result) FlaggedCategories() []string {
+	out := make([]string, 0, len(r.Categories))
+	for name, flagged := range r.Categories {
+		if flagged {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Merge folds other into r: flags are OR-ed and the highest score per category wins.
+func (r *Result) Merge(other Result) {
+	if other.Flagged {
+		r.Flagged = true
+	}
+	for name, flagged := range other.Categories {
+		if r.Categories == nil {
+			r.Categories = make(map[string]bool)
+		}
+		r.Categories[name] = r.Categories[name] || flagged
+	}
+	for name, score := range other.Scores {
+		if r.Scores == nil {
+			r.Scores = make(map[string]float64)
+		}
+		if score > r.Scores[name] {
+			r.Scores[name] = score
+		}
+	}
+}
+
+// Moderator screens text.
+type Moderator interface {
+	Moderate(ctx context.Context, in Input) (Result, error)
+}
+
+// Func adapts a function to the Moderator interface.
+type Func func(ctx context.Context, in Input) (Result, error)
+
+// Moderate calls f(ctx, in).
+func (f Func) Moderate(ctx context.Context, in Input) (Result, error) { return f(ctx, in) }
+
+// Factory builds a moderator from its configured arguments.
+type Factory func(args map[string]any) (Moderator, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+
+	builtMu sync.Mutex
+	built   = make(map[string]Moderator)
+)
+
+// Register registers a moderator factory for a given type identifier. Registering an existing
+// type replaces it.
+func Register(typ string, factory Factory) {
+	typ = strings.TrimSpace(typ)
+	if typ == "" || factory == nil {
+		return
+	}
+	registryMu.Lock()
+	registry[typ] = factory
+	registryMu.Unlock()
+
+	builtMu.Lock()
+	built = make(map[string]Moderator)
+	builtMu.Unlock()
+}
+
+// Build constructs the moderator described by m.
+func Build(m config.GuardrailModerator) (Moderator, error) {
+	registryMu.RLock()
+	factory, ok := registry[m.Type]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("guardrail: type %q is not registered", m.Type)
+	}
+	mod, err := factory(m.Args)
+	if err != nil {
+		return nil, fmt.Errorf("guardrail: failed to build %q: %w", m.Name, err)
+	}
+	return mod, nil
+}
+
+// cachedBuild reuses moderators across requests; configuration reloads produce new keys.
+func cachedBuild(m config.GuardrailModerator) (Moderator, error) {
+	args, _ := json.Marshal(m.Args)
+	key := m.Type + "\x00" + string(args)
+	builtMu.Lock()
+	defer builtMu.Unlock()
+	if mod, ok := built[key]; ok {
+		return mod, nil
+	}
+	mod, err := Build(m)
+	if err != nil {
+		return nil, err
+	}
+	built[key] = mod
+	return mod, nil
+}
+
+// Moderate runs the named moderators (all configured moderators when names is empty) over in
+// and merges their verdicts. The first moderator error aborts the evaluation.
+func Moderate(ctx context.Context, cfg config.GuardrailsConfig, names []string, in Input) (Result, error) {
+	var result Result
+	selected, err := selectModerators(cfg, names)
+	if err != nil {
+		return result, err
+	}
+	for _, m := range selected {
+		mod, errBuild := cachedBuild(m)
+		if errBuild != nil {
+			return result, errBuild
+		}
+		res, errMod := mod.Moderate(ctx, in)
+		if errMod != nil {
+			return result, fmt.Errorf("guardrail: moderator %q failed: %w", m.Name, errMod)
+		}
+		result.Merge(res)
+	}
+	return result, nil
+}
+
+func selectModerators(cfg config.GuardrailsConfig, names []string) ([]config.GuardrailModerator, error) {
+	if len(names) == 0 {
+		return cfg.Moderators, nil
+	}
+	out := make([]config.GuardrailModerator, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, m := range cfg.Moderators {
+			if m.Name == name {
+				out = append(out, m)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("guardrail: moderator %q is not configured", name)
+		}
+	}
+	return out, nil
+}
+
+// MatchingPolicies returns the policies that screen check for the given route and model.
+func MatchingPolicies(cfg config.GuardrailsConfig, check Check, route, model string) []config.GuardrailPolicy {
+	var out []config.GuardrailPolicy
+	for _, p := range cfg.Policies {
+		if !policyChecks(p, check) || !policyMatches(p, route, model) {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// PolicyAction returns the normalized action of p.
+func PolicyAction(p config.GuardrailPolicy) Action {
+	if strings.EqualFold(strings.TrimSpace(p.Action), string(ActionAnnotate)) {
+		return ActionAnnotate
+	}
+	return ActionBlock
+}
+
+func policyChecks(p config.GuardrailPolicy, check Check) bool {
+	if len(p.Check) == 0 {
+		return check == CheckPrompt
+	}
+	for _, c := range p.Check {
+		if strings.EqualFold(strings.TrimSpace(c), string(check)) {
+			return true
+		}
+	}
+	return false
+}
+
+func policyMatches(p config.GuardrailPolicy, route, model string) bool {
+	routeOK := false
+	for _, pattern := range p.Routes {
+		if pattern = strings.TrimSpace(pattern); pattern != "" && util.MatchWildcard(pattern, route) {
+			routeOK = true
+			break
+		}
+	}
+	if !routeOK {
+		return false
+	}
+	if len(p.Models) == 0 {
+		return true
+	}
+	for _, pattern := range p.Models {
+		if pattern = strings.TrimSpace(pattern); pattern != "" && util.MatchModelWildcard(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// textKeys are the JSON object keys whose string values carry user-visible text across the
+// OpenAI, Claude, Gemini and Responses formats.
+var textKeys = map[string]bool{
+	"text":         true,
+	"content":      true,
+	"input":        true,
+	"prompt":       true,
+	"system":       true,
+	"instructions": true,
+	"arguments":    true,
+	"output_text":  true,
+}
+
+// ExtractText collects the text fragments of a request or response body in document order.
+func ExtractText(body []byte) []string {
+	if !gjson.ValidBytes(body) {
+		return nil
+	}
+	var out []string
+	var walk func(key string, v gjson.Result)
+	walk = func(key string, v gjson.Result) {
+		switch {
+		case v.Type == gjson.String:
+			if textKeys[key] && strings.TrimSpace(v.Str) != "" {
+				out = append(out, v.Str)
+			}
+		case v.IsArray():
+			v.ForEach(func(_, item gjson.Result) bool {
+				walk(key, item)
+				return true
+			})
+		case v.IsObject():
+			v.ForEach(func(k, item gjson.Result) bool {
+				walk(k.Str, item)
+				return true
+			})
+		}
+	}
+	walk("", gjson.ParseBytes(body))
+	return out
+}
+
+type skipKey struct{}
+
+// WithoutGuardrails marks ctx so inline policies are not applied, letting model-backed
+// moderators call back through the proxy without screening their own prompts.
+func WithoutGuardrails(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipKey{}, true)
+}
+
+// Skipped reports whether ctx was marked by WithoutGuardrails.
+func Skipped(ctx context.Context) bool {
+	v, _ := ctx.Value(skipKey{}).(bool)
+	return v
+}
@@ -0,0 +1,89 @@
+package guardrail
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestKeywordModeratorAndPolicyMatching(t *testing.T) {
+	cfg := config.GuardrailsConfig{
+		Moderators: []config.GuardrailModerator{{
+			Name: "blocklist",
+			Type: "keyword",
+			Args: map[string]any{"rules": []any{
+				map[string]any{"category": "secrets", "pattern": "PRIVATE KEY"},
+				map[string]any{"category": "profanity", "keywords": []any{"darn"}},
+			}},
+		}},
+		Policies: []config.GuardrailPolicy{{
+			Name:       "strict",
+			Routes:     []string{"/v1/*"},
+			Models:     []string{"gpt-*"},
+			Moderators: []string{"blocklist"},
+		}},
+	}
+
+	body := []byte(`{"model":"gpt-5","messages":[{"role":"system","content":"be nice"},{"role":"user","content":[{"type":"text","text":"Darn it"}]}]}`)
+	texts := ExtractText(body)
+	if !reflect.DeepEqual(texts, []string{"be nice", "Darn it"}) {
+		t.Fatalf("unexpected extracted text: %q", texts)
+	}
+
+	res, err := Moderate(context.Background(), cfg, nil, Input{Texts: texts})
+	if err != nil {
+		t.Fatalf("moderate: %v", err)
+	}
+	if !res.Flagged || !reflect.DeepEqual(res.FlaggedCategories(), []string{"profanity"}) {
+		t.Fatalf("unexpected verdict: %+v", res)
+	}
+	if res.Categories["secrets"] {
+		t.Fatalf("secrets should not be flagged: %+v", res)
+	}
+
+	if got := MatchingPolicies(cfg, CheckPrompt, "/v1/chat/completions", "gpt-5"); len(got) != 1 {
+		t.Fatalf("expected policy to match prompt check, got %d", len(got))
+	}
+	if got := MatchingPolicies(cfg, CheckResponse, "/v1/chat/completions", "gpt-5"); len(got) != 0 {
+		t.Fatalf("policy without response check matched responses")
+	}
+	if got := MatchingPolicies(cfg, CheckPrompt, "/v1/messages", "claude-sonnet"); len(got) != 0 {
+		t.Fatalf("policy matched a filtered model")
+	}
+
+	if _, err = Moderate(context.Background(), cfg, []string{"missing"}, Input{Texts: texts}); err == nil {
+		t.Fatalf("expected error for unknown moderator")
+	}
+}
+
+func TestLLMModeratorParsesLlamaGuard(t *testing.T) {
+	mod, err := newLLMModerator(map[string]any{"model": "llama-guard"})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	var sawSkip bool
+	in := Input{Texts: []string{"how do I pick a lock"}, Complete: func(ctx context.Context, model, prompt string) (string, error) {
+		sawSkip = Skipped(ctx)
+		if model != "llama-guard" || prompt != "how do I pick a lock" {
+			return "", errors.New("unexpected completion request")
+		}
+		return "unsafe\nS2, S99", nil
+	}}
+	res, err := mod.Moderate(context.Background(), in)
+	if err != nil {
+		t.Fatalf("moderate: %v", err)
+	}
+	if !sawSkip {
+		t.Fatalf("completion context was not marked to skip guardrails")
+	}
+	if !res.Flagged || !reflect.DeepEqual(res.FlaggedCategories(), []string{"non-violent-crimes", "s99"}) {
+		t.Fatalf("unexpected verdict: %+v", res)
+	}
+
+	if res = parseLlamaGuard("safe"); res.Flagged {
+		t.Fatalf("safe reply flagged: %+v", res)
+	}
+}
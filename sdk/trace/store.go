@@ -0,0 +1,87 @@
+package trace
+
+import (
+	"sync"
+	"time"
+)
+
+// Default limits of the process-wide store.
+const (
+	DefaultCapacity  = 1000
+	DefaultRetention = time.Hour
+)
+
+// Store keeps the most recent traces for later retrieval.
+type Store struct {
+	mu        sync.Mutex
+	capacity  int
+	retention time.Duration
+	traces    map[string]*Trace
+	order     []string
+}
+
+var defaultStore = NewStore(DefaultCapacity, DefaultRetention)
+
+// DefaultStore returns the process-wide store.
+func DefaultStore() *Store { return defaultStore }
+
+// NewStore returns a store holding at most capacity traces for at most retention.
+func NewStore(capacity int, retention time.Duration) *Store {
+	s := &Store{traces: make(map[string]*Trace)}
+	s.SetLimits(capacity, retention)
+	return s
+}
+
+// SetLimits changes the capacity and retention. Non-positive values select the defaults.
+func (s *Store) SetLimits(capacity int, retention time.Duration) {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	s.mu.Lock()
+	s.capacity, s.retention = capacity, retention
+	s.evictLocked(time.Now())
+	s.mu.Unlock()
+}
+
+// Put stores t, replacing any trace with the same ID. The oldest traces are evicted beyond the
+// capacity.
+func (s *Store) Put(t *Trace) {
+	if t == nil || t.id == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.traces[t.id]; !exists {
+		s.order = append(s.order, t.id)
+	}
+	s.traces[t.id] = t
+	s.evictLocked(time.Now())
+}
+
+// Get returns the trace with the given ID.
+func (s *Store) Get(id string) (*Trace, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictLocked(time.Now())
+	t, ok := s.traces[id]
+	return t, ok
+}
+
+// evictLocked drops expired traces and the oldest ones beyond the capacity. Callers hold s.mu.
+func (s *Store) evictLocked(now time.Time) {
+	drop := 0
+	for drop < len(s.order) {
+		t := s.traces[s.order[drop]]
+		if len(s.order)-drop <= s.capacity && t != nil && now.Sub(t.started) <= s.retention {
+			break
+		}
+		delete(s.traces, s.order[drop])
+		drop++
+	}
+	if drop > 0 {
+		s.order = append(s.order[:0:0], s.order[drop:]...)
+	}
+}
@@ -0,0 +1,197 @@
+// Package trace records per-request routing decision traces: how the requested model was
+// resolved, which credentials were tried, retries and fallbacks, and how long translation took.
+//
+// Tracing is opt-in per request. The HTTP layer attaches a Trace to the request context; every
+// recording function is a no-op for contexts without one, so call sites need no checks.
+package trace
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Step is one decision or event in a trace.
+type Step struct {
+	// AtMs is the offset from the start of the request in milliseconds.
+	AtMs float64 `json:"at_ms"`
+	// DurationMs is set for timed steps such as upstream attempts.
+	DurationMs float64 `json:"duration_ms,omitempty"`
+	Kind       string  `json:"kind"`
+	Message    string  `json:"message,omitempty"`
+	Data       any     `json:"data,omitempty"`
+}
+
+// Timing aggregates a repeated operation, such as translating every chunk of a stream.
+type Timing struct {
+	Count   int64   `json:"count"`
+	TotalMs float64 `json:"total_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// Trace is the decision trace of one request. It is safe for concurrent use.
+type Trace struct {
+	mu       sync.Mutex
+	id       string
+	started  time.Time
+	finished time.Time
+	method   string
+	path     string
+	status   int
+	steps    []Step
+	timings  map[string]*Timing
+}
+
+// Snapshot is the JSON form of a trace.
+type Snapshot struct {
+	ID         string             `json:"id"`
+	StartedAt  time.Time          `json:"started_at"`
+	DurationMs float64            `json:"duration_ms,omitempty"`
+	Method     string             `json:"method,omitempty"`
+	Path       string             `json:"path,omitempty"`
+	Status     int                `json:"status,omitempty"`
+	Complete   bool               `json:"complete"`
+	Steps      []Step             `json:"steps"`
+	Timings    map[string]*Timing `json:"timings,omitempty"`
+}
+
+// maxSteps bounds a trace so a long retry loop cannot grow it without limit.
+const maxSteps = 256
+
+// New starts a trace for a request.
+func New(id, method, path string) *Trace {
+	return &Trace{id: id, started: time.Now(), method: method, path: path}
+}
+
+// ID returns the trace identifier.
+func (t *Trace) ID() string {
+	if t == nil {
+		return ""
+	}
+	return t.id
+}
+
+// Add appends a step.
+func (t *Trace) Add(kind, message string, data any) {
+	t.add(Step{Kind: kind, Message: message, Data: data}, time.Now())
+}
+
+// AddTimed appends a step covering the time since started.
+func (t *Trace) AddTimed(kind, message string, started time.Time, data any) {
+	now := time.Now()
+	t.add(Step{Kind: kind, Message: message, Data: data, DurationMs: ms(now.Sub(started))}, now)
+}
+
+func (t *Trace) add(step Step, now time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.steps) >= maxSteps {
+		return
+	}
+	step.AtMs = ms(now.Sub(t.started))
+	t.steps = append(t.steps, step)
+}
+
+// Observe adds d to the aggregated timing name.
+func (t *Trace) Observe(name string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timings == nil {
+		t.timings = make(map[string]*Timing)
+	}
+	timing := t.timings[name]
+	if timing == nil {
+		timing = &Timing{}
+		t.timings[name] = timing
+	}
+	timing.Count++
+	timing.TotalMs += ms(d)
+	if v := ms(d); v > timing.MaxMs {
+		timing.MaxMs = v
+	}
+}
+
+// Finish records the response status and marks the trace complete.
+func (t *Trace) Finish(status int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.status = status
+	t.finished = time.Now()
+	t.mu.Unlock()
+}
+
+// Snapshot returns a copy of the trace.
+func (t *Trace) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := Snapshot{
+		ID:        t.id,
+		StartedAt: t.started.UTC(),
+		Method:    t.method,
+		Path:      t.path,
+		Status:    t.status,
+		Complete:  !t.finished.IsZero(),
+		Steps:     append([]Step{}, t.steps...),
+	}
+	if s.Complete {
+		s.DurationMs = ms(t.finished.Sub(t.started))
+	}
+	if len(t.timings) > 0 {
+		s.Timings = make(map[string]*Timing, len(t.timings))
+		for name, timing := range t.timings {
+			copied := *timing
+			s.Timings[name] = &copied
+		}
+	}
+	sort.SliceStable(s.Steps, func(i, j int) bool { return s.Steps[i].AtMs < s.Steps[j].AtMs })
+	return s
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+type contextKey struct{}
+
+// WithTrace returns a context carrying t.
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the trace carried by ctx, or nil.
+func FromContext(ctx context.Context) *Trace {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(contextKey{}).(*Trace)
+	return t
+}
+
+// Record appends a step to the trace carried by ctx.
+func Record(ctx context.Context, kind, message string, data any) {
+	FromContext(ctx).Add(kind, message, data)
+}
+
+// RecordTimed appends a step covering the time since started to the trace carried by ctx.
+func RecordTimed(ctx context.Context, kind, message string, started time.Time, data any) {
+	FromContext(ctx).AddTimed(kind, message, started, data)
+}
+
+// Observe adds the time since started to the aggregated timing name of the trace carried by ctx.
+func Observe(ctx context.Context, name string, started time.Time) {
+	if t := FromContext(ctx); t != nil {
+		t.Observe(name, time.Since(started))
+	}
+}
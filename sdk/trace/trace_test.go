@@ -0,0 +1,62 @@
+package trace
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecordingWithoutTraceIsNoop(t *testing.T) {
+	ctx := context.Background()
+	Record(ctx, "route", "ignored", nil)
+	RecordTimed(ctx, "upstream.attempt", "ignored", time.Now(), nil)
+	Observe(ctx, "translate.response", time.Now())
+}
+
+func TestTraceSnapshot(t *testing.T) {
+	tr := New("t1", "POST", "/v1/messages")
+	ctx := WithTrace(context.Background(), tr)
+	Record(ctx, "route", "routed", map[string]any{"model": "m"})
+	RecordTimed(ctx, "upstream.attempt", "succeeded", time.Now().Add(-5*time.Millisecond), nil)
+	Observe(ctx, "translate.response.stream", time.Now())
+	Observe(ctx, "translate.response.stream", time.Now())
+	if tr.Snapshot().Complete {
+		t.Fatal("trace complete before Finish")
+	}
+	tr.Finish(200)
+
+	snap := tr.Snapshot()
+	if !snap.Complete || snap.Status != 200 || len(snap.Steps) != 2 {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	if snap.Steps[1].DurationMs < 5 {
+		t.Fatalf("timed step duration = %v; want >= 5ms", snap.Steps[1].DurationMs)
+	}
+	if timing := snap.Timings["translate.response.stream"]; timing == nil || timing.Count != 2 {
+		t.Fatalf("timings = %+v", snap.Timings)
+	}
+}
+
+func TestStoreEvictsOldestBeyondCapacity(t *testing.T) {
+	s := NewStore(2, time.Hour)
+	for _, id := range []string{"a", "b", "c"} {
+		s.Put(New(id, "", ""))
+	}
+	if _, ok := s.Get("a"); ok {
+		t.Fatal("oldest trace should be evicted")
+	}
+	for _, id := range []string{"b", "c"} {
+		if _, ok := s.Get(id); !ok {
+			t.Fatalf("trace %s missing", id)
+		}
+	}
+}
+
+func TestStoreExpiresTraces(t *testing.T) {
+	s := NewStore(10, time.Millisecond)
+	s.Put(New("a", "", ""))
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := s.Get("a"); ok {
+		t.Fatal("expired trace still retrievable")
+	}
+}
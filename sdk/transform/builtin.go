@@ -0,0 +1,234 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+func init() {
+	Register("system-preamble", newSystemPreamble)
+	Register("rename-tool", newRenameTool)
+	Register("set", newSetPath)
+	Register("delete", newDeletePath)
+	Register("strip-header", newStripHeader)
+	Register("starlark", newStarlark)
+}
+
+func stringArg(args map[string]any, key string) string {
+	if v, ok := args[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// EditJSON applies fn to the JSON carried by p.Body. Plain JSON bodies are edited as a whole;
+// SSE chunks have each "data:" line edited individually.
+func EditJSON(p *Payload, fn func(body []byte) []byte) {
+	trimmed := bytes.TrimSpace(p.Body)
+	if len(trimmed) == 0 {
+		return
+	}
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		p.Body = fn(p.Body)
+		return
+	}
+	lines := bytes.Split(p.Body, []byte("\n"))
+	for i, line := range lines {
+		rest, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		data := bytes.TrimSpace(rest)
+		if !gjson.ValidBytes(data) {
+			continue
+		}
+		lines[i] = append([]byte("data: "), fn(data)...)
+	}
+	p.Body = bytes.Join(lines, []byte("\n"))
+}
+
+// requestRoot returns the JSON path prefix wrapping the request for envelope formats.
+func requestRoot(format string) string {
+	switch format {
+	case "gemini-cli", "antigravity":
+		return "request."
+	}
+	return ""
+}
+
+// newSystemPreamble prepends args.text to the system prompt of a translated request.
+func newSystemPreamble(args map[string]any) (Transformer, error) {
+	text := stringArg(args, "text")
+	if text == "" {
+		return nil, fmt.Errorf("system-preamble requires a non-empty text argument")
+	}
+	return Func(func(_ context.Context, p *Payload) error {
+		if p.Stage != StageRequest {
+			return nil
+		}
+		body, err := prependSystem(p.Format, p.Body, text)
+		if err != nil {
+			return err
+		}
+		p.Body = body
+		return nil
+	}), nil
+}
+
+func prependSystem(format string, body []byte, text string) ([]byte, error) {
+	switch format {
+	case "claude":
+		system := gjson.GetBytes(body, "system")
+		switch {
+		case system.IsArray():
+			block, _ := sjson.Set(`{"type":"text","text":""}`, "text", text)
+			return prependArray(body, "system", system, block)
+		case system.Type == gjson.String && system.String() != "":
+			return sjson.SetBytes(body, "system", text+"\n\n"+system.String())
+		default:
+			return sjson.SetBytes(body, "system", text)
+		}
+	case "openai":
+		msg, _ := sjson.Set(`{"role":"system","content":""}`, "content", text)
+		return prependArray(body, "messages", gjson.GetBytes(body, "messages"), msg)
+	case "openai-response", "codex":
+		if existing := gjson.GetBytes(body, "instructions").String(); existing != "" {
+			return sjson.SetBytes(body, "instructions", text+"\n\n"+existing)
+		}
+		return sjson.SetBytes(body, "instructions", text)
+	case "gemini", "gemini-cli", "antigravity":
+		root := requestRoot(format)
+		part, _ := sjson.Set(`{"text":""}`, "text", text)
+		parts := gjson.GetBytes(body, root+"systemInstruction.parts")
+		if !parts.Exists() {
+			return sjson.SetRawBytes(body, root+"systemInstruction", []byte(`{"role":"user","parts":[`+part+`]}`))
+		}
+		return prependArray(body, root+"systemInstruction.parts", parts, part)
+	}
+	return body, fmt.Errorf("system-preamble does not support format %q", format)
+}
+
+func prependArray(body []byte, path string, existing gjson.Result, item string) ([]byte, error) {
+	items := []string{item}
+	for _, v := range existing.Array() {
+		items = append(items, v.Raw)
+	}
+	return sjson.SetRawBytes(body, path, []byte("["+strings.Join(items, ",")+"]"))
+}
+
+// newRenameTool rewrites every "name" field equal to args.from into args.to. Configure it on the
+// request stage to rename tools sent upstream, and with swapped arguments on the response stage
+// to restore the client-facing name in tool calls.
+func newRenameTool(args map[string]any) (Transformer, error) {
+	from, to := stringArg(args, "from"), stringArg(args, "to")
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("rename-tool requires from and to arguments")
+	}
+	return Func(func(_ context.Context, p *Payload) error {
+		EditJSON(p, func(body []byte) []byte {
+			var paths []string
+			collectNamePaths(gjson.ParseBytes(body), "", from, &paths)
+			for _, path := range paths {
+				if updated, err := sjson.SetBytes(body, path, to); err == nil {
+					body = updated
+				}
+			}
+			return body
+		})
+		return nil
+	}), nil
+}
+
+func collectNamePaths(node gjson.Result, prefix, name string, paths *[]string) {
+	if !node.IsObject() && !node.IsArray() {
+		return
+	}
+	index := 0
+	node.ForEach(func(key, value gjson.Result) bool {
+		var segment string
+		if node.IsArray() {
+			segment = fmt.Sprintf("%d", index)
+			index++
+		} else {
+			segment = escapePathKey(key.String())
+		}
+		path := segment
+		if prefix != "" {
+			path = prefix + "." + segment
+		}
+		if node.IsObject() && key.String() == "name" && value.Type == gjson.String && value.String() == name {
+			*paths = append(*paths, path)
+			return true
+		}
+		collectNamePaths(value, path, name, paths)
+		return true
+	})
+}
+
+func escapePathKey(key string) string {
+	replacer := strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`, "|", `\|`, "#", `\#`, "@", `\@`)
+	return replacer.Replace(key)
+}
+
+// newSetPath sets args.path to args.value in the JSON payload.
+func newSetPath(args map[string]any) (Transformer, error) {
+	path := stringArg(args, "path")
+	if path == "" {
+		return nil, fmt.Errorf("set requires a path argument")
+	}
+	value, ok := args["value"]
+	if !ok {
+		return nil, fmt.Errorf("set requires a value argument")
+	}
+	return Func(func(_ context.Context, p *Payload) error {
+		var setErr error
+		EditJSON(p, func(body []byte) []byte {
+			updated, err := sjson.SetBytes(body, path, value)
+			if err != nil {
+				setErr = err
+				return body
+			}
+			return updated
+		})
+		return setErr
+	}), nil
+}
+
+// newDeletePath removes args.path from the JSON payload.
+func newDeletePath(args map[string]any) (Transformer, error) {
+	path := stringArg(args, "path")
+	if path == "" {
+		return nil, fmt.Errorf("delete requires a path argument")
+	}
+	return Func(func(_ context.Context, p *Payload) error {
+		EditJSON(p, func(body []byte) []byte {
+			if !gjson.GetBytes(body, path).Exists() {
+				return body
+			}
+			if updated, err := sjson.DeleteBytes(body, path); err == nil {
+				return updated
+			}
+			return body
+		})
+		return nil
+	}), nil
+}
+
+// newStripHeader removes args.name from the headers returned to the client.
+func newStripHeader(args map[string]any) (Transformer, error) {
+	name := strings.TrimSpace(stringArg(args, "name"))
+	if name == "" {
+		return nil, fmt.Errorf("strip-header requires a name argument")
+	}
+	return Func(func(_ context.Context, p *Payload) error {
+		if p.Header != nil {
+			p.Header.Del(name)
+		}
+		return nil
+	}), nil
+}
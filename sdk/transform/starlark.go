@@ -0,0 +1,167 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// defaultScriptMaxSteps bounds the work a single hook invocation may perform.
+const defaultScriptMaxSteps = 1_000_000
+
+// scriptHook returns the Starlark function invoked for the payload's pipeline stage.
+func scriptHook(p *Payload) string {
+	switch p.Stage {
+	case StagePreTranslate:
+		return "pre_translate"
+	case StageRequest:
+		return "post_translate"
+	case StageResponse:
+		if p.Stream {
+			return "on_chunk"
+		}
+		return "on_response"
+	}
+	return ""
+}
+
+// starlarkScript runs hook functions defined by an operator-supplied Starlark script. Scripts are
+// sandboxed: they have no file, network or clock access, cannot load modules and are bounded by
+// an execution step budget. Only the json module is predeclared.
+type starlarkScript struct {
+	name     string
+	globals  starlark.StringDict
+	maxSteps uint64
+}
+
+// newStarlark loads the script named by args.path (or inline args.source). Each hook receives
+// the decoded JSON body and a meta struct (stage, route, model, format, stream) and returns the
+// replacement body, or None to keep it unchanged.
+func newStarlark(args map[string]any) (Transformer, error) {
+	path, source := stringArg(args, "path"), stringArg(args, "source")
+	var src any
+	name := path
+	switch {
+	case path != "":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("starlark: read script: %w", err)
+		}
+		src = data
+	case source != "":
+		src, name = source, "inline.star"
+	default:
+		return nil, fmt.Errorf("starlark requires a path or source argument")
+	}
+
+	maxSteps := uint64(defaultScriptMaxSteps)
+	switch v := args["max-steps"].(type) {
+	case int:
+		if v > 0 {
+			maxSteps = uint64(v)
+		}
+	case float64:
+		if v > 0 {
+			maxSteps = uint64(v)
+		}
+	}
+
+	s := &starlarkScript{name: name, maxSteps: maxSteps}
+	thread := s.newThread()
+	predeclared := starlark.StringDict{"json": starlarkjson.Module}
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{Set: true}, thread, name, src, predeclared)
+	if err != nil {
+		return nil, fmt.Errorf("starlark: load %s: %w", name, err)
+	}
+	globals.Freeze()
+	s.globals = globals
+
+	hooks := 0
+	for _, fn := range []string{"pre_translate", "post_translate", "on_chunk", "on_response"} {
+		if v, ok := globals[fn]; ok {
+			if _, callable := v.(starlark.Callable); !callable {
+				return nil, fmt.Errorf("starlark: %s in %s is not a function", fn, name)
+			}
+			hooks++
+		}
+	}
+	if hooks == 0 {
+		return nil, fmt.Errorf("starlark: %s defines no hook functions", name)
+	}
+	return s, nil
+}
+
+func (s *starlarkScript) newThread() *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: s.name,
+		Print: func(_ *starlark.Thread, msg string) {
+			log.Debugf("starlark %s: %s", s.name, msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(s.maxSteps)
+	return thread
+}
+
+// Transform implements Transformer.
+func (s *starlarkScript) Transform(ctx context.Context, p *Payload) error {
+	hook, ok := s.globals[scriptHook(p)].(starlark.Callable)
+	if !ok {
+		return nil
+	}
+
+	meta := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"stage":  starlark.String(p.Stage),
+		"route":  starlark.String(p.Route),
+		"model":  starlark.String(p.Model),
+		"format": starlark.String(p.Format),
+		"stream": starlark.Bool(p.Stream),
+	})
+
+	var callErr error
+	EditJSON(p, func(body []byte) []byte {
+		if callErr != nil {
+			return body
+		}
+		out, err := s.call(ctx, hook, body, meta)
+		if err != nil {
+			callErr = err
+			return body
+		}
+		return out
+	})
+	return callErr
+}
+
+func (s *starlarkScript) call(ctx context.Context, hook starlark.Callable, body []byte, meta starlark.Value) ([]byte, error) {
+	thread := s.newThread()
+	if ctx != nil {
+		stop := context.AfterFunc(ctx, func() { thread.Cancel("request canceled") })
+		defer stop()
+	}
+	decoded, err := starlark.Call(thread, starlarkjson.Module.Members["decode"], starlark.Tuple{starlark.String(body)}, nil)
+	if err != nil {
+		return body, fmt.Errorf("starlark: decode payload: %w", err)
+	}
+	result, err := starlark.Call(thread, hook, starlark.Tuple{decoded, meta}, nil)
+	if err != nil {
+		return body, fmt.Errorf("starlark: %s: %w", hook.Name(), err)
+	}
+	if result == starlark.None {
+		return body, nil
+	}
+	encoded, err := starlark.Call(thread, starlarkjson.Module.Members["encode"], starlark.Tuple{result}, nil)
+	if err != nil {
+		return body, fmt.Errorf("starlark: encode %s result: %w", hook.Name(), err)
+	}
+	out, ok := starlark.AsString(encoded)
+	if !ok {
+		return body, fmt.Errorf("starlark: %s returned a non-JSON value", hook.Name())
+	}
+	return []byte(out), nil
+}
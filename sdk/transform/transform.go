@@ -0,0 +1,191 @@
+// Package transform provides an ordered request/response transformation pipeline.
+//
+// Operators attach transformers to inbound routes in the "transforms" configuration block;
+// SDK users extend the set of available transformers by registering their own factories.
+// Pre-translate transformers see the client request before translation, request transformers
+// see the translated upstream payload, and response transformers see the translated payload
+// about to be returned to the client.
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Stage identifies where in the request lifecycle a transformer runs.
+type Stage string
+
+const (
+	// StagePreTranslate runs on client requests before they are translated.
+	StagePreTranslate Stage = "pre-translate"
+	// StageRequest runs on translated upstream requests.
+	StageRequest Stage = "request"
+	// StageResponse runs on translated client responses.
+	StageResponse Stage = "response"
+)
+
+// Payload is the message handed to transformers. Transformers mutate it in place.
+type Payload struct {
+	Stage Stage
+	// Route is the inbound request path, e.g. "/v1/chat/completions".
+	Route string
+	// Model is the model name the request targets.
+	Model string
+	// Format is the translator format of Body: the upstream format for translated requests and
+	// the client format otherwise.
+	Format string
+	// Stream reports whether Body is a single chunk of a streaming response.
+	Stream bool
+	// Body is the JSON payload, or raw SSE lines for streaming chunks.
+	Body []byte
+	// Header holds the headers returned to the client. It is nil for the request stage.
+	Header http.Header
+}
+
+// Transformer mutates a payload.
+type Transformer interface {
+	Transform(ctx context.Context, p *Payload) error
+}
+
+// Func adapts a function to the Transformer interface.
+type Func func(ctx context.Context, p *Payload) error
+
+// Transform calls f(ctx, p).
+func (f Func) Transform(ctx context.Context, p *Payload) error { return f(ctx, p) }
+
+// Factory builds a transformer from its configured arguments.
+type Factory func(args map[string]any) (Transformer, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+
+	builtMu sync.Mutex
+	built   = make(map[string]Transformer)
+)
+
+// Register registers a transformer factory for a given type identifier. Registering an existing
+// type replaces it.
+func Register(typ string, factory Factory) {
+	typ = strings.TrimSpace(typ)
+	if typ == "" || factory == nil {
+		return
+	}
+	registryMu.Lock()
+	registry[typ] = factory
+	registryMu.Unlock()
+
+	builtMu.Lock()
+	built = make(map[string]Transformer)
+	builtMu.Unlock()
+}
+
+// Build constructs the transformer described by step.
+func Build(step config.TransformStep) (Transformer, error) {
+	registryMu.RLock()
+	factory, ok := registry[step.Type]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("transform: type %q is not registered", step.Type)
+	}
+	t, err := factory(step.Args)
+	if err != nil {
+		return nil, fmt.Errorf("transform: failed to build %q: %w", step.Type, err)
+	}
+	return t, nil
+}
+
+// cachedBuild reuses transformers across requests; configuration reloads produce new keys.
+func cachedBuild(step config.TransformStep) (Transformer, error) {
+	args, _ := json.Marshal(step.Args)
+	key := step.Type + "\x00" + string(args)
+	builtMu.Lock()
+	defer builtMu.Unlock()
+	if t, ok := built[key]; ok {
+		return t, nil
+	}
+	t, err := Build(step)
+	if err != nil {
+		return nil, err
+	}
+	built[key] = t
+	return t, nil
+}
+
+// Apply runs every transformer of the payload's stage whose rule matches the payload route and
+// model, in configuration order. Failing transformers are logged and skipped so a broken rule
+// never fails the request.
+func Apply(ctx context.Context, rules []config.TransformRule, p *Payload) {
+	if p == nil || len(rules) == 0 {
+		return
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if !ruleMatches(rule, p.Route, p.Model) {
+			continue
+		}
+		for _, step := range stageSteps(rule, p.Stage) {
+			t, err := cachedBuild(step)
+			if err != nil {
+				log.Warnf("transform rule %q: %v", rule.Name, err)
+				continue
+			}
+			if err = t.Transform(ctx, p); err != nil {
+				log.Warnf("transform rule %q step %q failed: %v", rule.Name, step.Type, err)
+			}
+		}
+	}
+}
+
+// HasStage reports whether any rule declares transformers for stage, letting callers skip
+// payload copies when nothing is configured.
+func HasStage(rules []config.TransformRule, stage Stage) bool {
+	for i := range rules {
+		if len(stageSteps(&rules[i], stage)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func stageSteps(rule *config.TransformRule, stage Stage) []config.TransformStep {
+	switch stage {
+	case StagePreTranslate:
+		return rule.PreTranslate
+	case StageRequest:
+		return rule.Request
+	case StageResponse:
+		return rule.Response
+	}
+	return nil
+}
+
+func ruleMatches(rule *config.TransformRule, route, model string) bool {
+	routeOK := false
+	for _, pattern := range rule.Routes {
+		if pattern = strings.TrimSpace(pattern); pattern != "" && util.MatchWildcard(pattern, route) {
+			routeOK = true
+			break
+		}
+	}
+	if !routeOK {
+		return false
+	}
+	if len(rule.Models) == 0 {
+		return true
+	}
+	for _, pattern := range rule.Models {
+		if pattern = strings.TrimSpace(pattern); pattern != "" && util.MatchModelWildcard(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
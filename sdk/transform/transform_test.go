@@ -0,0 +1,107 @@
+package transform
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplySystemPreambleAndMatching(t *testing.T) {
+	rules := []config.TransformRule{{
+		Name:   "preamble",
+		Routes: []string{"/v1/*"},
+		Models: []string{"claude-*"},
+		Request: []config.TransformStep{
+			{Type: "system-preamble", Args: map[string]any{"text": "Be brief."}},
+		},
+	}}
+
+	p := &Payload{Stage: StageRequest, Route: "/v1/messages", Model: "claude-sonnet", Format: "gemini-cli",
+		Body: []byte(`{"request":{"systemInstruction":{"parts":[{"text":"orig"}]}}}`)}
+	Apply(context.Background(), rules, p)
+	parts := gjson.GetBytes(p.Body, "request.systemInstruction.parts")
+	if got := parts.Get("0.text").String(); got != "Be brief." {
+		t.Fatalf("preamble not prepended: %s", p.Body)
+	}
+	if got := parts.Get("1.text").String(); got != "orig" {
+		t.Fatalf("original system part lost: %s", p.Body)
+	}
+
+	other := &Payload{Stage: StageRequest, Route: "/v1/messages", Model: "gpt-5", Format: "claude", Body: []byte(`{}`)}
+	Apply(context.Background(), rules, other)
+	if string(other.Body) != `{}` {
+		t.Fatalf("non-matching model was transformed: %s", other.Body)
+	}
+}
+
+func TestApplyResponseRenameToolAndStripHeader(t *testing.T) {
+	rules := []config.TransformRule{{
+		Routes: []string{"/v1/chat/completions"},
+		Response: []config.TransformStep{
+			{Type: "rename-tool", Args: map[string]any{"from": "web_search", "to": "search"}},
+			{Type: "strip-header", Args: map[string]any{"name": "X-Upstream-Id"}},
+			{Type: "unknown-type"},
+		},
+	}}
+	header := http.Header{}
+	header.Set("X-Upstream-Id", "abc")
+	p := &Payload{Stage: StageResponse, Route: "/v1/chat/completions", Format: "openai", Stream: true, Header: header,
+		Body: []byte(`data: {"choices":[{"delta":{"tool_calls":[{"function":{"name":"web_search"}}]}}]}`)}
+	Apply(context.Background(), rules, p)
+
+	data := strings.TrimPrefix(string(p.Body), "data: ")
+	if got := gjson.Get(data, "choices.0.delta.tool_calls.0.function.name").String(); got != "search" {
+		t.Fatalf("tool name = %q, body %s", got, p.Body)
+	}
+	if header.Get("X-Upstream-Id") != "" {
+		t.Fatal("header was not stripped")
+	}
+}
+
+func TestStarlarkScriptHooks(t *testing.T) {
+	source := `
+def post_translate(body, meta):
+    body["metadata"] = {"model": meta.model}
+    return body
+
+def on_chunk(body, meta):
+    if meta.stream:
+        body["patched"] = True
+    return body
+
+def on_response(body, meta):
+    for i in range(100000000):
+        pass
+`
+	rules := []config.TransformRule{{
+		Routes:   []string{"*"},
+		Request:  []config.TransformStep{{Type: "starlark", Args: map[string]any{"source": source}}},
+		Response: []config.TransformStep{{Type: "starlark", Args: map[string]any{"source": source, "max-steps": 1000}}},
+	}}
+
+	req := &Payload{Stage: StageRequest, Route: "/v1/messages", Model: "m1", Format: "claude", Body: []byte(`{"a":1}`)}
+	Apply(context.Background(), rules, req)
+	if got := gjson.GetBytes(req.Body, "metadata.model").String(); got != "m1" || gjson.GetBytes(req.Body, "a").Int() != 1 {
+		t.Fatalf("post_translate result = %s", req.Body)
+	}
+
+	chunk := &Payload{Stage: StageResponse, Route: "/v1/messages", Stream: true, Body: []byte("event: x\ndata: {\"b\":2}\n")}
+	Apply(context.Background(), rules, chunk)
+	if !strings.Contains(string(chunk.Body), `"patched":true`) || !strings.HasPrefix(string(chunk.Body), "event: x\n") {
+		t.Fatalf("on_chunk result = %q", chunk.Body)
+	}
+
+	resp := &Payload{Stage: StageResponse, Route: "/v1/messages", Body: []byte(`{"c":3}`)}
+	Apply(context.Background(), rules, resp)
+	if string(resp.Body) != `{"c":3}` {
+		t.Fatalf("script exceeding its step budget must leave the body unchanged: %s", resp.Body)
+	}
+
+	if _, err := Build(config.TransformStep{Type: "starlark", Args: map[string]any{"source": "load('x.star', 'y')\ndef on_chunk(b, m):\n    return b\n"}}); err == nil {
+		t.Fatal("expected load statements to be rejected")
+	}
+}
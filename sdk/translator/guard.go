@@ -0,0 +1,49 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"runtime/debug"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// malformedStreamLine reports whether a single upstream stream line carries a payload no
+// translator can use: anything other than SSE framing, the [DONE] marker or a JSON document.
+// Inputs spanning several lines are left to the translators.
+func malformedStreamLine(raw []byte) bool {
+	line := bytes.TrimSpace(raw)
+	if len(line) == 0 || bytes.IndexByte(line, '\n') >= 0 || line[0] == ':' {
+		return false
+	}
+	for _, field := range [][]byte{[]byte("event:"), []byte("id:"), []byte("retry:")} {
+		if bytes.HasPrefix(line, field) {
+			return false
+		}
+	}
+	if payload, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+		line = bytes.TrimSpace(payload)
+	}
+	if len(line) == 0 || bytes.Equal(line, []byte("[DONE]")) {
+		return false
+	}
+	return !gjson.ValidBytes(line)
+}
+
+// guardedStream runs a stream translator for one upstream chunk. Malformed lines are dropped
+// and a translator panic is logged instead of taking down the executor goroutine that feeds
+// the client stream.
+func guardedStream(fn ResponseStreamTransform, ctx context.Context, from, to Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) (out []string) {
+	if malformedStreamLine(rawJSON) {
+		log.Debugf("translator: dropping malformed %s stream chunk for %s: %.200q", from, to, rawJSON)
+		return nil
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Errorf("translator: %s to %s stream translator panicked on chunk %.200q: %v\n%s", from, to, rawJSON, recovered, debug.Stack())
+			out = nil
+		}
+	}()
+	return fn(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+}
@@ -0,0 +1,52 @@
+package translator
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMalformedStreamLine(t *testing.T) {
+	cases := map[string]bool{
+		"":                         false,
+		"event: message_start":     false,
+		": keep-alive":             false,
+		"data: [DONE]":             false,
+		"[DONE]":                   false,
+		`data: {"type":"ping"}`:    false,
+		`{"candidates":[]}`:        false,
+		"data: {\"a\":1}\n\ndata:": false,
+		"data: {bad":               true,
+		"00":                       true,
+		"<html>":                   true,
+	}
+	for input, want := range cases {
+		if got := malformedStreamLine([]byte(input)); got != want {
+			t.Errorf("malformedStreamLine(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestTranslateStreamGuardsTranslator(t *testing.T) {
+	registry := NewRegistry()
+	calls := 0
+	registry.Register("client", "provider", nil, ResponseTransform{
+		Stream: func(_ context.Context, _ string, _, _, rawJSON []byte, _ *any) []string {
+			calls++
+			if string(rawJSON) == `{"boom":true}` {
+				panic("boom")
+			}
+			return []string{string(rawJSON)}
+		},
+	})
+
+	var param any
+	if out := registry.TranslateStream(context.Background(), "provider", "client", "m", nil, nil, []byte("data: {oops"), &param); len(out) != 0 || calls != 0 {
+		t.Fatalf("malformed line reached translator: out=%q calls=%d", out, calls)
+	}
+	if out := registry.TranslateStream(context.Background(), "provider", "client", "m", nil, nil, []byte(`{"boom":true}`), &param); len(out) != 0 {
+		t.Fatalf("panicking translator output = %q, want none", out)
+	}
+	if out := registry.TranslateStream(context.Background(), "provider", "client", "m", nil, nil, []byte(`{"ok":true}`), &param); len(out) != 1 {
+		t.Fatalf("output after recovered panic = %q", out)
+	}
+}
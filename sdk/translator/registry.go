@@ -2,7 +2,11 @@ package translator
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/trace"
 )
 
 // Registry manages translation functions across schemas.
@@ -10,13 +14,22 @@ type Registry struct {
 	mu        sync.RWMutex
 	requests  map[Format]map[Format]RequestTransform
 	responses map[Format]map[Format]ResponseTransform
+	// contextRequests override requests for callers that pass the request context.
+	contextRequests map[Format]map[Format]RequestContextTransform
+	// custom holds formats declared with RegisterFormat; pairs involving them may be composed.
+	custom map[Format]struct{}
+
+	routeMu sync.Mutex
+	routes  map[Pair][]Format
 }
 
 // NewRegistry constructs an empty translator registry.
 func NewRegistry() *Registry {
 	return &Registry{
-		requests:  make(map[Format]map[Format]RequestTransform),
-		responses: make(map[Format]map[Format]ResponseTransform),
+		requests:        make(map[Format]map[Format]RequestTransform),
+		responses:       make(map[Format]map[Format]ResponseTransform),
+		contextRequests: make(map[Format]map[Format]RequestContextTransform),
+		custom:          make(map[Format]struct{}),
 	}
 }
 
@@ -36,6 +49,31 @@ func (r *Registry) Register(from, to Format, request RequestTransform, response
 		r.responses[from] = make(map[Format]ResponseTransform)
 	}
 	r.responses[from][to] = response
+	r.resetRoutes()
+}
+
+// RegisterRequestContext stores a context-aware request transform between two formats. It is
+// used by TranslateRequestContext in place of the transform given to Register.
+func (r *Registry) RegisterRequestContext(from, to Format, request RequestContextTransform) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.contextRequests[from]; !ok {
+		r.contextRequests[from] = make(map[Format]RequestContextTransform)
+	}
+	r.contextRequests[from][to] = request
+}
+
+// TranslateRequestContext converts a payload like TranslateRequest, preferring a transform
+// registered with RegisterRequestContext for the pair.
+func (r *Registry) TranslateRequestContext(ctx context.Context, from, to Format, model string, rawJSON []byte, stream bool) []byte {
+	r.mu.RLock()
+	fn := r.contextRequests[from][to]
+	r.mu.RUnlock()
+	if fn != nil {
+		return fn(ctx, model, rawJSON, stream)
+	}
+	return r.TranslateRequest(from, to, model, rawJSON, stream)
 }
 
 // TranslateRequest converts a payload between schemas, returning the original payload
@@ -49,9 +87,25 @@ func (r *Registry) TranslateRequest(from, to Format, model string, rawJSON []byt
 			return fn(model, rawJSON, stream)
 		}
 	}
+	if route := r.composedRoute(from, to); route != nil {
+		return r.composeRequest(route, model, rawJSON, stream)
+	}
 	return rawJSON
 }
 
+// HasRequestTransformer indicates whether a request translator exists.
+func (r *Registry) HasRequestTransformer(from, to Format) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if byTarget, ok := r.requests[from]; ok {
+		if fn, isOk := byTarget[to]; isOk && fn != nil {
+			return true
+		}
+	}
+	return r.composedRoute(from, to) != nil
+}
+
 // HasResponseTransformer indicates whether a response translator exists.
 func (r *Registry) HasResponseTransformer(from, to Format) bool {
 	r.mu.RLock()
@@ -62,19 +116,26 @@ func (r *Registry) HasResponseTransformer(from, to Format) bool {
 			return true
 		}
 	}
-	return false
+	return r.composedRoute(from, to) != nil
 }
 
-// TranslateStream applies the registered streaming response translator.
+// TranslateStream applies the registered streaming response translator. Single upstream lines
+// that are neither SSE framing nor JSON are dropped, and a panicking translator yields no output
+// for the chunk instead of crashing the stream.
 func (r *Registry) TranslateStream(ctx context.Context, from, to Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	if byTarget, ok := r.responses[to]; ok {
 		if fn, isOk := byTarget[from]; isOk && fn.Stream != nil {
-			return fn.Stream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+			defer trace.Observe(ctx, "translate.response.stream", time.Now())
+			return guardedStream(fn.Stream, ctx, from, to, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
 		}
 	}
+	if route := r.composedRoute(to, from); route != nil {
+		defer trace.Observe(ctx, "translate.response.stream", time.Now())
+		return r.composeStream(ctx, route, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+	}
 	return []string{string(rawJSON)}
 }
 
@@ -85,13 +146,18 @@ func (r *Registry) TranslateNonStream(ctx context.Context, from, to Format, mode
 
 	if byTarget, ok := r.responses[to]; ok {
 		if fn, isOk := byTarget[from]; isOk && fn.NonStream != nil {
+			defer trace.Observe(ctx, "translate.response", time.Now())
 			return fn.NonStream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
 		}
 	}
+	if route := r.composedRoute(to, from); route != nil {
+		defer trace.Observe(ctx, "translate.response", time.Now())
+		return r.composeNonStream(ctx, route, model, originalRequestRawJSON, requestRawJSON, rawJSON)
+	}
 	return string(rawJSON)
 }
 
-// TranslateNonStream applies the registered non-stream response translator.
+// TranslateTokenCount applies the registered token count translator.
 func (r *Registry) TranslateTokenCount(ctx context.Context, from, to Format, count int64, rawJSON []byte) string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -101,9 +167,42 @@ func (r *Registry) TranslateTokenCount(ctx context.Context, from, to Format, cou
 			return fn.TokenCount(ctx, count)
 		}
 	}
+	if route := r.composedRoute(to, from); route != nil {
+		// Only the final hop shapes the count the client sees.
+		if fn := r.responses[route[0]][route[1]]; fn.TokenCount != nil {
+			return fn.TokenCount(ctx, count)
+		}
+	}
 	return string(rawJSON)
 }
 
+// Pair identifies a registered translation between a client format (From) and an upstream
+// format (To), mirroring the arguments of Register.
+type Pair struct {
+	From Format
+	To   Format
+}
+
+// Pairs lists the registered format pairs in a stable order.
+func (r *Registry) Pairs() []Pair {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var pairs []Pair
+	for from, byTarget := range r.responses {
+		for to := range byTarget {
+			pairs = append(pairs, Pair{From: from, To: to})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].From != pairs[j].From {
+			return pairs[i].From < pairs[j].From
+		}
+		return pairs[i].To < pairs[j].To
+	})
+	return pairs
+}
+
 var defaultRegistry = NewRegistry()
 
 // Default exposes the package-level registry for shared use.
@@ -121,6 +220,21 @@ func TranslateRequest(from, to Format, model string, rawJSON []byte, stream bool
 	return defaultRegistry.TranslateRequest(from, to, model, rawJSON, stream)
 }
 
+// RegisterRequestContext attaches a context-aware request transform to the default registry.
+func RegisterRequestContext(from, to Format, request RequestContextTransform) {
+	defaultRegistry.RegisterRequestContext(from, to, request)
+}
+
+// TranslateRequestContext is a helper on the default registry.
+func TranslateRequestContext(ctx context.Context, from, to Format, model string, rawJSON []byte, stream bool) []byte {
+	return defaultRegistry.TranslateRequestContext(ctx, from, to, model, rawJSON, stream)
+}
+
+// HasRequestTransformer inspects the default registry.
+func HasRequestTransformer(from, to Format) bool {
+	return defaultRegistry.HasRequestTransformer(from, to)
+}
+
 // HasResponseTransformer inspects the default registry.
 func HasResponseTransformer(from, to Format) bool {
 	return defaultRegistry.HasResponseTransformer(from, to)
@@ -140,3 +254,18 @@ func TranslateNonStream(ctx context.Context, from, to Format, model string, orig
 func TranslateTokenCount(ctx context.Context, from, to Format, count int64, rawJSON []byte) string {
 	return defaultRegistry.TranslateTokenCount(ctx, from, to, count, rawJSON)
 }
+
+// Pairs lists the format pairs registered on the default registry.
+func Pairs() []Pair {
+	return defaultRegistry.Pairs()
+}
+
+// RegisterFormat declares a custom format on the default registry.
+func RegisterFormat(format Format) {
+	defaultRegistry.RegisterFormat(format)
+}
+
+// Route reports how the default registry translates requests from one format to another.
+func Route(from, to Format) []Format {
+	return defaultRegistry.Route(from, to)
+}
@@ -0,0 +1,213 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"sort"
+)
+
+// RegisterFormat declares a custom format. Translation between a custom format and any format
+// it has no direct translator for is composed from registered pairs along the shortest chain,
+// so a new format only needs translators to and from one existing format (typically openai) to
+// reach every other. Pairs between built-in formats are never composed; they keep their direct
+// translator or pass payloads through unchanged.
+func (r *Registry) RegisterFormat(format Format) {
+	if format == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.custom[format] = struct{}{}
+	r.resetRoutes()
+}
+
+// Route reports the formats a request passes through when translated from one format to
+// another: the two formats for a registered pair, a longer chain for a composed translation,
+// and nil when payloads pass through unchanged.
+func (r *Registry) Route(from, to Format) []Format {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.requests[from][to] != nil {
+		return []Format{from, to}
+	}
+	if route := r.composedRoute(from, to); route != nil {
+		return append([]Format(nil), route...)
+	}
+	return nil
+}
+
+// resetRoutes drops cached routes. Callers hold r.mu for writing.
+func (r *Registry) resetRoutes() {
+	r.routeMu.Lock()
+	r.routes = nil
+	r.routeMu.Unlock()
+}
+
+// composedRoute returns the chain of formats from the client format to the upstream format
+// when the pair has no direct translator and either end is a custom format. Every hop has both
+// a request and a response translator so requests and responses follow the same chain.
+// Callers hold r.mu.
+func (r *Registry) composedRoute(from, to Format) []Format {
+	if from == to {
+		return nil
+	}
+	if _, ok := r.custom[from]; !ok {
+		if _, ok = r.custom[to]; !ok {
+			return nil
+		}
+	}
+	key := Pair{From: from, To: to}
+	r.routeMu.Lock()
+	defer r.routeMu.Unlock()
+	if route, ok := r.routes[key]; ok {
+		return route
+	}
+
+	previous := map[Format]Format{from: ""}
+	queue := []Format{from}
+	for len(queue) > 0 && previous[to] == "" {
+		current := queue[0]
+		queue = queue[1:]
+		neighbours := make([]Format, 0, len(r.requests[current]))
+		for f, fn := range r.requests[current] {
+			if _, seen := previous[f]; seen || fn == nil {
+				continue
+			}
+			if _, ok := r.responses[current][f]; ok {
+				neighbours = append(neighbours, f)
+			}
+		}
+		sort.Slice(neighbours, func(i, j int) bool { return neighbours[i] < neighbours[j] })
+		for _, next := range neighbours {
+			previous[next] = current
+			queue = append(queue, next)
+		}
+	}
+	var route []Format
+	if _, ok := previous[to]; ok {
+		for f := to; f != ""; f = previous[f] {
+			route = append([]Format{f}, route...)
+		}
+	}
+	if len(route) < 3 {
+		route = nil
+	}
+	if r.routes == nil {
+		r.routes = make(map[Pair][]Format)
+	}
+	r.routes[key] = route
+	return route
+}
+
+func (r *Registry) composeRequest(route []Format, model string, rawJSON []byte, stream bool) []byte {
+	for i := 0; i+1 < len(route); i++ {
+		rawJSON = r.requests[route[i]][route[i+1]](model, rawJSON, stream)
+	}
+	return rawJSON
+}
+
+// routeRequests returns the request as seen by each format along route, starting with the
+// client's original and ending with the payload actually sent upstream.
+func (r *Registry) routeRequests(route []Format, model string, originalRequestRawJSON, requestRawJSON []byte, stream bool) [][]byte {
+	requests := make([][]byte, len(route))
+	requests[0] = originalRequestRawJSON
+	for i := 1; i < len(route)-1; i++ {
+		requests[i] = r.requests[route[i-1]][route[i]](model, bytes.Clone(requests[i-1]), stream)
+	}
+	requests[len(route)-1] = requestRawJSON
+	return requests
+}
+
+// composedStreamState carries per-hop translator state across the chunks of one stream.
+type composedStreamState struct {
+	requests [][]byte
+	params   []any
+	done     []bool
+}
+
+// composeStream runs an upstream chunk back along route, handing each hop's output to the
+// next as SSE data lines.
+func (r *Registry) composeStream(ctx context.Context, route []Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+	state, ok := (*param).(*composedStreamState)
+	if !ok {
+		state = &composedStreamState{
+			requests: r.routeRequests(route, model, originalRequestRawJSON, requestRawJSON, true),
+			params:   make([]any, len(route)-1),
+			done:     make([]bool, len(route)-1),
+		}
+		*param = state
+	}
+	terminal := isDoneChunk(rawJSON)
+	inputs := [][]byte{rawJSON}
+	var out []string
+	for hop := len(route) - 2; hop >= 0; hop-- {
+		fn := r.responses[route[hop]][route[hop+1]]
+		out = nil
+		for _, input := range inputs {
+			if isDoneChunk(input) {
+				if state.done[hop] {
+					continue
+				}
+				state.done[hop] = true
+			}
+			if fn.Stream == nil {
+				out = append(out, string(input))
+				continue
+			}
+			out = append(out, guardedStream(fn.Stream, ctx, route[hop+1], route[hop], model, state.requests[hop], state.requests[hop+1], input, &state.params[hop])...)
+		}
+		if hop == 0 {
+			break
+		}
+		inputs = dataLines(out)
+		if terminal {
+			inputs = append(inputs, []byte("data: [DONE]"))
+		}
+	}
+	return out
+}
+
+// composeNonStream runs a buffered upstream response back along route.
+func (r *Registry) composeNonStream(ctx context.Context, route []Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte) string {
+	requests := r.routeRequests(route, model, originalRequestRawJSON, requestRawJSON, false)
+	out := string(rawJSON)
+	for hop := len(route) - 2; hop >= 0; hop-- {
+		fn := r.responses[route[hop]][route[hop+1]]
+		if fn.NonStream == nil {
+			continue
+		}
+		var param any
+		out = fn.NonStream(ctx, model, requests[hop], requests[hop+1], []byte(out), &param)
+	}
+	return out
+}
+
+func isDoneChunk(raw []byte) bool {
+	line := bytes.TrimSpace(raw)
+	if payload, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+		line = bytes.TrimSpace(payload)
+	}
+	return bytes.Equal(line, []byte("[DONE]"))
+}
+
+// dataLines reframes translator output as the "data:" lines stream translators consume.
+// Event names, comments and blank lines are dropped; bare JSON gains a data prefix.
+func dataLines(chunks []string) [][]byte {
+	var lines [][]byte
+	for _, chunk := range chunks {
+		for _, line := range bytes.Split([]byte(chunk), []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			switch {
+			case len(line) == 0, line[0] == ':',
+				bytes.HasPrefix(line, []byte("event:")), bytes.HasPrefix(line, []byte("id:")), bytes.HasPrefix(line, []byte("retry:")):
+				continue
+			case bytes.HasPrefix(line, []byte("data:")):
+				lines = append(lines, line)
+			default:
+				lines = append(lines, append([]byte("data: "), line...))
+			}
+		}
+	}
+	return lines
+}
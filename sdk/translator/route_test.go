@@ -0,0 +1,118 @@
+package translator
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// tagRegistry registers from→to pairs whose transforms append a marker so composed chains
+// are visible in the output.
+func tagRegistry(pairs ...Pair) *Registry {
+	r := NewRegistry()
+	for _, p := range pairs {
+		p := p
+		r.Register(p.From, p.To,
+			func(model string, raw []byte, stream bool) []byte {
+				return append(raw, []byte(">"+p.To.String())...)
+			},
+			ResponseTransform{
+				Stream: func(ctx context.Context, model string, original, translated, raw []byte, param *any) []string {
+					payload := strings.TrimSpace(strings.TrimPrefix(string(raw), "data:"))
+					if payload == "[DONE]" {
+						return []string{"event: end\ndata: " + strconv.Quote(p.From.String()+"-done") + "\n\n"}
+					}
+					if unquoted, err := strconv.Unquote(payload); err == nil {
+						payload = unquoted
+					}
+					return []string{"event: x\ndata: " + strconv.Quote(payload+"<"+p.From.String()) + "\n\n"}
+				},
+				NonStream: func(ctx context.Context, model string, original, translated, raw []byte, param *any) string {
+					return string(raw) + "<" + p.From.String() + "[" + string(original) + "]"
+				},
+				TokenCount: func(ctx context.Context, count int64) string { return "count@" + p.From.String() },
+			},
+		)
+	}
+	return r
+}
+
+func TestRouteComposesOnlyForCustomFormats(t *testing.T) {
+	r := tagRegistry(Pair{"claude", "openai"}, Pair{"openai", "claude"}, Pair{"openai", "myprov"}, Pair{"openai", "gemini"})
+
+	if got := r.Route("claude", "gemini"); got != nil {
+		t.Fatalf("built-in pair composed: %v", got)
+	}
+	if got := r.TranslateRequest("claude", "gemini", "m", []byte("req"), false); string(got) != "req" {
+		t.Fatalf("built-in pair translated: %q", got)
+	}
+	if r.HasRequestTransformer("claude", "myprov") {
+		t.Fatal("myprov routed before it was declared")
+	}
+
+	r.RegisterFormat("myprov")
+	if got, want := r.Route("claude", "myprov"), []Format{"claude", "openai", "myprov"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("route = %v, want %v", got, want)
+	}
+	if got := r.Route("openai", "myprov"); !reflect.DeepEqual(got, []Format{"openai", "myprov"}) {
+		t.Fatalf("direct route = %v", got)
+	}
+	if !r.HasRequestTransformer("claude", "myprov") || !r.HasResponseTransformer("claude", "myprov") {
+		t.Fatal("expected composed translators to be reported")
+	}
+	if got := r.TranslateRequest("claude", "myprov", "m", []byte("req"), false); string(got) != "req>openai>myprov" {
+		t.Fatalf("composed request = %q", got)
+	}
+}
+
+func TestComposedResponses(t *testing.T) {
+	r := tagRegistry(Pair{"claude", "openai"}, Pair{"openai", "myprov"})
+	r.RegisterFormat("myprov")
+	ctx := context.Background()
+
+	got := r.TranslateNonStream(ctx, "myprov", "claude", "m", []byte("orig"), []byte("sent"), []byte("resp"), nil)
+	if want := "resp<openai[orig>openai]<claude[orig]"; got != want {
+		t.Fatalf("non-stream = %q, want %q", got, want)
+	}
+
+	var param any
+	var out []string
+	for _, chunk := range []string{`data: "a"`, `data: "b"`, "[DONE]"} {
+		out = append(out, r.TranslateStream(ctx, "myprov", "claude", "m", []byte("orig"), []byte("sent"), []byte(chunk), &param)...)
+	}
+	want := []string{
+		"event: x\ndata: \"a<openai<claude\"\n\n",
+		"event: x\ndata: \"b<openai<claude\"\n\n",
+		"event: x\ndata: \"openai-done<claude\"\n\n",
+		"event: end\ndata: \"claude-done\"\n\n",
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Fatalf("stream = %q, want %q", out, want)
+	}
+
+	if got := r.TranslateTokenCount(ctx, "myprov", "claude", 3, []byte("{}")); got != "count@claude" {
+		t.Fatalf("token count = %q", got)
+	}
+}
+
+type requestScopeKey struct{}
+
+func TestTranslateRequestContextPrefersContextTransform(t *testing.T) {
+	r := tagRegistry(Pair{From: "a", To: "b"})
+	r.RegisterRequestContext("a", "b", func(ctx context.Context, model string, raw []byte, stream bool) []byte {
+		scope, _ := ctx.Value(requestScopeKey{}).(string)
+		return append(raw, []byte(">b@"+scope)...)
+	})
+	ctx := context.WithValue(context.Background(), requestScopeKey{}, "client")
+	if got := string(r.TranslateRequestContext(ctx, "a", "b", "m", []byte("x"), false)); got != "x>b@client" {
+		t.Fatalf("TranslateRequestContext(a→b) = %q", got)
+	}
+	if got := string(r.TranslateRequest("a", "b", "m", []byte("x"), false)); got != "x>b" {
+		t.Fatalf("TranslateRequest(a→b) = %q", got)
+	}
+	if got := string(r.TranslateRequestContext(ctx, "b", "a", "m", []byte("x"), false)); got != "x" {
+		t.Fatalf("TranslateRequestContext without translator = %q", got)
+	}
+}
@@ -8,6 +8,10 @@ import "context"
 // It returns the converted request payload as a byte slice.
 type RequestTransform func(model string, rawJSON []byte, stream bool) []byte
 
+// RequestContextTransform is a RequestTransform that also receives the request context, for
+// translators whose output depends on the calling client.
+type RequestContextTransform func(ctx context.Context, model string, rawJSON []byte, stream bool) []byte
+
 // ResponseStreamTransform is a function type that converts a streaming response from a source schema to a target schema.
 // It takes a context, the model name, the raw JSON of the original and converted requests, the raw JSON of the current response chunk, and an optional parameter.
 // It returns a slice of strings, where each string is a chunk of the converted streaming response.